@@ -0,0 +1,172 @@
+package telefonistka
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+)
+
+const (
+	// Payloads are logged up to this size
+	maxLoggedPayloadBytes = 4096
+	// Only payloads up to this size are buffered to be redacted, a truncated JSON payload can't be
+	maxRedactedPayloadBytes = 256 << 10
+	redactedValue           = "[REDACTED]"
+)
+
+// Payload fields whose name matches are replaced with redactedValue
+var redactedFieldRegex = regexp.MustCompile(`(?i)token|secret|password|passwd|authorization|signature|private_?key|credential|cookie`)
+
+// The probes and metrics scrapes are only logged at debug level, they would drown the webhook requests
+var quietRoutes = map[string]bool{
+	"/metrics": true,
+	"/live":    true,
+	"/ready":   true,
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+type accessLogOptions struct {
+	enabled  bool
+	payloads bool
+}
+
+func accessLogOptionsFromEnv() accessLogOptions {
+	enabled, _ := strconv.ParseBool(getEnv("HTTP_ACCESS_LOG_ENABLED", "false"))
+	payloads, _ := strconv.ParseBool(getEnv("HTTP_ACCESS_LOG_PAYLOADS", "false"))
+	return accessLogOptions{enabled: enabled, payloads: payloads}
+}
+
+// statusRecorder keeps the response code and size, handlers that never call WriteHeader respond with 200
+type statusRecorder struct {
+	http.ResponseWriter
+	code  int
+	bytes int
+}
+
+func (sr *statusRecorder) WriteHeader(code int) {
+	sr.code = code
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	n, err := sr.ResponseWriter.Write(b)
+	sr.bytes += n
+	return n, err
+}
+
+// redactValue replaces the values of the sensitive fields of a decoded JSON payload, recursively
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, fieldValue := range v {
+			if redactedFieldRegex.MatchString(key) {
+				v[key] = redactedValue
+			} else {
+				v[key] = redactValue(fieldValue)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = redactValue(v[i])
+		}
+	}
+	return value
+}
+
+// redactPayload returns the payload with its sensitive fields redacted, truncated to maxLoggedPayloadBytes. Payloads that aren't JSON(e.g. form encoded webhooks) are not logged
+func redactPayload(payload []byte) string {
+	var decoded interface{}
+	if err := json.Unmarshal(payload, &decoded); err != nil {
+		return "[non-JSON payload omitted]"
+	}
+	redacted, err := json.Marshal(redactValue(decoded))
+	if err != nil {
+		return "[payload omitted]"
+	}
+	if len(redacted) > maxLoggedPayloadBytes {
+		return string(redacted[:maxLoggedPayloadBytes]) + "...[truncated]"
+	}
+	return string(redacted)
+}
+
+// requestDeliveryID returns the webhook delivery ID of the supported providers
+func requestDeliveryID(r *http.Request) string {
+	for _, header := range []string{"X-GitHub-Delivery", "X-Request-UUID", "X-Request-Id", "X-Gitea-Delivery"} {
+		if id := r.Header.Get(header); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// withAccessLog instruments every request with Prometheus metrics and, when enabled, logs it: method, route, delivery ID, response code and latency.
+// Headers aren't logged, payloads only when enabled and with their secrets redacted
+func withAccessLog(next http.Handler, options accessLogOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		var payload []byte
+		payloadTooBig := false
+		if options.enabled && options.payloads && r.Body != nil {
+			// The payload is redacted as a whole, the handler gets the buffered part followed by the rest of the body
+			payload, _ = io.ReadAll(io.LimitReader(r.Body, maxRedactedPayloadBytes+1))
+			payloadTooBig = len(payload) > maxRedactedPayloadBytes
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(payload), r.Body), r.Body}
+		}
+		recorder := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		duration := time.Since(start)
+
+		// ServeMux sets the matched pattern on the request
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		prom.InstrumentHTTPRequest(route, r.Method, recorder.code, duration)
+		if !options.enabled {
+			return
+		}
+		fields := log.Fields{
+			"method":      r.Method,
+			"path":        r.URL.Path,
+			"route":       route,
+			"code":        recorder.code,
+			"bytes":       recorder.bytes,
+			"latency_ms":  duration.Milliseconds(),
+			"remote_addr": r.RemoteAddr,
+		}
+		if deliveryID := requestDeliveryID(r); deliveryID != "" {
+			fields["delivery_id"] = deliveryID
+		}
+		if event := r.Header.Get("X-GitHub-Event"); event != "" {
+			fields["event"] = event
+		}
+		switch {
+		case payloadTooBig:
+			fields["payload"] = "[payload larger than " + strconv.Itoa(maxRedactedPayloadBytes) + " bytes omitted]"
+		case payload != nil:
+			fields["payload"] = redactPayload(payload)
+		}
+		logger := log.WithFields(fields)
+		switch {
+		case quietRoutes[route]:
+			logger.Debug("HTTP request")
+		case recorder.code >= http.StatusInternalServerError:
+			logger.Error("HTTP request")
+		case recorder.code >= http.StatusBadRequest:
+			logger.Warn("HTTP request")
+		default:
+			logger.Info("HTTP request")
+		}
+	})
+}
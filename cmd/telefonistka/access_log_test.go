@@ -0,0 +1,80 @@
+package telefonistka
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactPayload(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		payload  string
+		expected string
+	}{
+		"Secrets are redacted recursively": {
+			payload:  `{"action":"opened","installation":{"id":1,"access_token":"ghs_abc"},"hook":{"config":{"secret":"s3cr3t","url":"https://telefonistka/webhook"}}}`,
+			expected: `{"action":"opened","hook":{"config":{"secret":"[REDACTED]","url":"https://telefonistka/webhook"}},"installation":{"access_token":"[REDACTED]","id":1}}`,
+		},
+		"Secrets in arrays are redacted": {
+			payload:  `[{"Token":"abc"},{"name":"x"}]`,
+			expected: `[{"Token":"[REDACTED]"},{"name":"x"}]`,
+		},
+		"Non JSON payloads are omitted": {
+			payload:  `payload=%7B%22token%22%3A%22abc%22%7D`,
+			expected: `[non-JSON payload omitted]`,
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, redactPayload([]byte(tc.payload)))
+		})
+	}
+}
+
+func TestRedactPayloadTruncates(t *testing.T) {
+	t.Parallel()
+	redacted := redactPayload([]byte(`{"body":"` + strings.Repeat("a", 2*maxLoggedPayloadBytes) + `"}`))
+	assert.Len(t, redacted, maxLoggedPayloadBytes+len("...[truncated]"))
+}
+
+func TestWithAccessLog(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /webhook", func(w http.ResponseWriter, r *http.Request) {
+		// The handler still gets the whole payload
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, `{"secret":"s3cr3t"}`, string(body))
+		w.WriteHeader(http.StatusConflict)
+	})
+	handler := withAccessLog(mux, accessLogOptions{enabled: true, payloads: true})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewBufferString(`{"secret":"s3cr3t"}`))
+	r.Header.Set("X-GitHub-Delivery", "72d3162e-cc78-11e3-81ab-4c9367dc0958")
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "POST /webhook", r.Pattern)
+	assert.Equal(t, "72d3162e-cc78-11e3-81ab-4c9367dc0958", requestDeliveryID(r))
+
+	// Payloads too big to be redacted aren't buffered whole
+	bigPayload := `{"body":"` + strings.Repeat("a", 2*maxRedactedPayloadBytes) + `"}`
+	mux.HandleFunc("POST /big", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, bigPayload, string(body))
+	})
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/big", strings.NewReader(bigPayload)))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/unknown", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
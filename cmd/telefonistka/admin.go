@@ -0,0 +1,338 @@
+package telefonistka
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	lru "github.com/hashicorp/golang-lru/v2"
+	log "github.com/sirupsen/logrus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/githubapi"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/inflight"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/maintenance"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/secrets"
+)
+
+var (
+	errAdminUnauthorized = errors.New("missing or invalid admin credentials")
+	errAdminForbidden    = errors.New("the caller isn't allowed to use this admin action")
+)
+
+// adminPrincipal is an authenticated caller of the admin API
+type adminPrincipal struct {
+	Name string
+	// OIDC groups claim values, or the "org/team" GitHub teams of GitHub tokens
+	Groups []string
+	// ADMIN_API_TOKEN callers can use all the actions
+	Admin bool
+}
+
+// adminAuth authenticates the callers of the admin API with ADMIN_API_TOKEN, an OIDC ID token(e.g. from a CI workload identity or an SSO login)
+// or a GitHub OAuth token, then authorizes them by their groups
+type adminAuth struct {
+	token    string
+	verifier *oidc.IDTokenVerifier
+	// The ID token claim that lists the groups of its subject
+	groupsClaim string
+	// Subjects or emails allowed to use the admin API with an ID token
+	allowedSubjects []string
+	// GitHub tokens of members of these "org/team" teams are accepted
+	githubTeams []string
+	// Groups(or teams) allowed to use all the actions, and the ones only allowed to use the read-only(GET) actions.
+	// Callers without one of them are denied
+	allowedGroups  []string
+	readOnlyGroups []string
+	// Replaced in tests
+	githubUserTeams func(ctx context.Context, token string, teams []string) (string, []string, error)
+}
+
+func splitEnvList(key string) []string {
+	list := []string{}
+	for _, item := range strings.Split(getEnv(key, ""), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// newAdminAuthFromEnv returns nil when neither ADMIN_API_TOKEN, ADMIN_OIDC_ISSUER_URL nor ADMIN_GITHUB_TEAMS is set, the admin API is disabled then
+func newAdminAuthFromEnv(ctx context.Context) (*adminAuth, error) {
+	auth := &adminAuth{
+		token:           secrets.Get("ADMIN_API_TOKEN", ""),
+		groupsClaim:     getEnv("ADMIN_OIDC_GROUPS_CLAIM", "groups"),
+		allowedSubjects: splitEnvList("ADMIN_OIDC_ALLOWED_SUBJECTS"),
+		githubTeams:     splitEnvList("ADMIN_GITHUB_TEAMS"),
+		allowedGroups:   splitEnvList("ADMIN_ALLOWED_GROUPS"),
+		readOnlyGroups:  splitEnvList("ADMIN_READONLY_GROUPS"),
+		githubUserTeams: githubapi.GithubUserTeams,
+	}
+	if issuer := getEnv("ADMIN_OIDC_ISSUER_URL", ""); issuer != "" {
+		audience := getEnv("ADMIN_OIDC_AUDIENCE", "")
+		if audience == "" {
+			return nil, errors.New("ADMIN_OIDC_AUDIENCE is required with ADMIN_OIDC_ISSUER_URL")
+		}
+		// Any account of the IdP can get an ID token for the audience, the groups are what grants access
+		if len(auth.allowedGroups)+len(auth.readOnlyGroups) == 0 {
+			return nil, errors.New("ADMIN_ALLOWED_GROUPS or ADMIN_READONLY_GROUPS is required with ADMIN_OIDC_ISSUER_URL")
+		}
+		provider, err := oidc.NewProvider(ctx, issuer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to discover OIDC issuer %s: %w", issuer, err)
+		}
+		auth.verifier = provider.Verifier(&oidc.Config{ClientID: audience})
+	}
+	for _, team := range auth.githubTeams {
+		if !strings.Contains(team, "/") {
+			return nil, fmt.Errorf("ADMIN_GITHUB_TEAMS entries should be org/team-slug, got %s", team)
+		}
+	}
+	if len(auth.githubTeams) > 0 && len(auth.allowedGroups)+len(auth.readOnlyGroups) == 0 {
+		// Members of the configured teams are the admins
+		auth.allowedGroups = auth.githubTeams
+	}
+	if auth.token == "" && auth.verifier == nil && len(auth.githubTeams) == 0 {
+		return nil, nil
+	}
+	return auth, nil
+}
+
+// authenticate returns who is calling the admin API, for the audit log and the authorization
+func (a *adminAuth) authenticate(r *http.Request) (adminPrincipal, error) {
+	bearer, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found || bearer == "" {
+		return adminPrincipal{}, errAdminUnauthorized
+	}
+	if a.token != "" && subtle.ConstantTimeCompare([]byte(bearer), []byte(a.token)) == 1 {
+		return adminPrincipal{Name: "admin-api-token", Admin: true}, nil
+	}
+	// ID tokens are JWTs, GitHub tokens have no dots
+	if a.verifier != nil && strings.Count(bearer, ".") == 2 {
+		return a.authenticateIDToken(r.Context(), bearer)
+	}
+	if len(a.githubTeams) > 0 {
+		login, teams, err := a.githubUserTeams(r.Context(), bearer, a.githubTeams)
+		if err != nil {
+			return adminPrincipal{}, fmt.Errorf("%w: %w", errAdminUnauthorized, err)
+		}
+		return adminPrincipal{Name: "github:" + login, Groups: teams}, nil
+	}
+	return adminPrincipal{}, errAdminUnauthorized
+}
+
+func (a *adminAuth) authenticateIDToken(ctx context.Context, rawIDToken string) (adminPrincipal, error) {
+	idToken, err := a.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return adminPrincipal{}, fmt.Errorf("%w: %w", errAdminUnauthorized, err)
+	}
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return adminPrincipal{}, fmt.Errorf("%w: %w", errAdminUnauthorized, err)
+	}
+	email, _ := claims["email"].(string)
+	if len(a.allowedSubjects) > 0 && !slices.Contains(a.allowedSubjects, idToken.Subject) && (email == "" || !slices.Contains(a.allowedSubjects, email)) {
+		return adminPrincipal{}, fmt.Errorf("%w: subject %s isn't allowed", errAdminUnauthorized, idToken.Subject)
+	}
+	principal := adminPrincipal{Name: idToken.Subject, Groups: claimGroups(claims[a.groupsClaim])}
+	if email != "" {
+		principal.Name = email
+	}
+	return principal, nil
+}
+
+// claimGroups reads a groups claim, IdPs send a list or, with a single group, a string
+func claimGroups(claim any) []string {
+	switch groups := claim.(type) {
+	case string:
+		return []string{groups}
+	case []any:
+		result := []string{}
+		for _, group := range groups {
+			if g, ok := group.(string); ok {
+				result = append(result, g)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// authorize checks the principal can use an action, read-only actions are the GET ones. Callers without an allowed group are denied
+func (a *adminAuth) authorize(principal adminPrincipal, readOnly bool) error {
+	if principal.Admin {
+		return nil
+	}
+	for _, group := range principal.Groups {
+		if slices.Contains(a.allowedGroups, group) || (readOnly && slices.Contains(a.readOnlyGroups, group)) {
+			return nil
+		}
+	}
+	return errAdminForbidden
+}
+
+// adminAction handles an authenticated admin API request, it writes the response and returns its status and the object(e.g. repo) it acted on
+type adminAction func(w http.ResponseWriter, r *http.Request) (status int, target string)
+
+// handle authenticates, authorizes and audit logs the requests of an admin action, including the rejected ones
+func (a *adminAuth) handle(action string, handler adminAction) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, err := a.authenticate(r)
+		if err != nil {
+			auditAdminAction(r, "", action, "", http.StatusUnauthorized, err)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if err := a.authorize(principal, r.Method == http.MethodGet); err != nil {
+			auditAdminAction(r, principal.Name, action, "", http.StatusForbidden, err)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		status, target := handler(w, r)
+		auditAdminAction(r, principal.Name, action, target, status, nil)
+	}
+}
+
+func auditAdminAction(r *http.Request, principal string, action string, target string, status int, err error) {
+	fields := log.Fields{
+		"audit":     true,
+		"action":    action,
+		"principal": principal,
+		"target":    target,
+		"status":    status,
+		"remote":    r.RemoteAddr,
+	}
+	if err != nil {
+		fields["error"] = err.Error()
+	}
+	log.WithFields(fields).Info("Admin API request")
+	prom.InstrumentAdminAction(action, strconv.Itoa(status))
+}
+
+// serverState serves the /debug/state dump, so it's reachable with SSO instead of only on the debug listener
+func serverState(caches map[string]*lru.Cache[string, githubapi.GhClientPair]) adminAction {
+	handler := handleDebugState(caches)
+	return func(w http.ResponseWriter, r *http.Request) (int, string) {
+		handler(w, r)
+		return http.StatusOK, ""
+	}
+}
+
+func listInFlightEvents(w http.ResponseWriter, _ *http.Request) (int, string) {
+	return writeJSON(w, inflight.Snapshot()), ""
+}
+
+// flushCaches drops the cached GitHub clients(so credentials are re-read) and makes the next secret lookups re-read Vault
+func flushCaches(caches map[string]*lru.Cache[string, githubapi.GhClientPair]) adminAction {
+	return func(w http.ResponseWriter, _ *http.Request) (int, string) {
+		flushed := map[string]int{}
+		for name, cache := range caches {
+			flushed[name] = cache.Len()
+			cache.Purge()
+		}
+		secrets.Flush()
+		return writeJSON(w, map[string]any{"flushed": flushed}), ""
+	}
+}
+
+func replayDelivery(mainGhClientCache *lru.Cache[string, githubapi.GhClientPair], prApproverGhClientCache *lru.Cache[string, githubapi.GhClientPair]) adminAction {
+	return func(w http.ResponseWriter, r *http.Request) (int, string) {
+		deliveryID, err := strconv.ParseInt(r.PathValue("deliveryID"), 10, 64)
+		if err != nil {
+			http.Error(w, "delivery ID should be a number", http.StatusBadRequest)
+			return http.StatusBadRequest, r.PathValue("deliveryID")
+		}
+		if err := githubapi.ReplayHookDelivery(deliveryID, mainGhClientCache, prApproverGhClientCache, false); err != nil {
+			log.Errorf("error replaying webhook delivery %d: %v", deliveryID, err)
+			http.Error(w, "Failed to replay webhook delivery", http.StatusBadGateway)
+			return http.StatusBadGateway, r.PathValue("deliveryID")
+		}
+		w.WriteHeader(http.StatusAccepted)
+		return http.StatusAccepted, r.PathValue("deliveryID")
+	}
+}
+
+func pauseRepo(w http.ResponseWriter, r *http.Request) (int, string) {
+	repoSlug := r.PathValue("owner") + "/" + r.PathValue("repo")
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&body); err != nil {
+			http.Error(w, `Body should be like {"reason": "migration"}`, http.StatusBadRequest)
+			return http.StatusBadRequest, repoSlug
+		}
+	}
+	paused, err := maintenance.PauseRepo(repoSlug, body.Reason)
+	if err != nil {
+		log.Errorf("error pausing %s: %v", repoSlug, err)
+		http.Error(w, "Failed to store the paused repo", http.StatusBadGateway)
+		return http.StatusBadGateway, repoSlug
+	}
+	return writeJSON(w, paused), repoSlug
+}
+
+func resumeRepo(w http.ResponseWriter, r *http.Request) (int, string) {
+	repoSlug := r.PathValue("owner") + "/" + r.PathValue("repo")
+	resumed, err := maintenance.ResumeRepo(repoSlug)
+	if err != nil {
+		log.Errorf("error resuming %s: %v", repoSlug, err)
+		http.Error(w, "Failed to store the resumed repo", http.StatusBadGateway)
+		return http.StatusBadGateway, repoSlug
+	}
+	if !resumed {
+		http.Error(w, "Repo isn't paused", http.StatusNotFound)
+		return http.StatusNotFound, repoSlug
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return http.StatusNoContent, repoSlug
+}
+
+func listPausedRepos(w http.ResponseWriter, _ *http.Request) (int, string) {
+	return writeJSON(w, maintenance.PausedRepos()), ""
+}
+
+// adminMaintenance reports(GET) or sets(PUT with a maintenance.State JSON body) the maintenance mode
+func adminMaintenance(w http.ResponseWriter, r *http.Request) (int, string) {
+	state := maintenance.Get()
+	if r.Method == http.MethodPut {
+		var requested maintenance.State
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&requested); err != nil {
+			http.Error(w, `Body should be like {"enabled": true, "reason": "ArgoCD upgrade"}`, http.StatusBadRequest)
+			return http.StatusBadRequest, ""
+		}
+		var err error
+		state, err = maintenance.Set(requested.Enabled, requested.Reason)
+		if err != nil {
+			log.Errorf("error setting the maintenance mode: %v", err)
+			http.Error(w, "Failed to store the maintenance mode", http.StatusBadGateway)
+			return http.StatusBadGateway, ""
+		}
+	}
+	return writeJSON(w, state), ""
+}
+
+// registerAdminHandlers adds the admin API endpoints, every request is audit logged
+func registerAdminHandlers(mux *http.ServeMux, auth *adminAuth, mainGhClientCache *lru.Cache[string, githubapi.GhClientPair], prApproverGhClientCache *lru.Cache[string, githubapi.GhClientPair]) {
+	caches := map[string]*lru.Cache[string, githubapi.GhClientPair]{
+		"main":       mainGhClientCache,
+		"prApprover": prApproverGhClientCache,
+	}
+	mux.HandleFunc("GET /admin/v1/state", auth.handle("get_state", serverState(caches)))
+	mux.HandleFunc("GET /admin/v1/events", auth.handle("list_events", listInFlightEvents))
+	mux.HandleFunc("POST /admin/v1/caches/flush", auth.handle("flush_caches", flushCaches(caches)))
+	mux.HandleFunc("POST /admin/v1/deliveries/{deliveryID}/replay", auth.handle("replay_delivery", replayDelivery(mainGhClientCache, prApproverGhClientCache)))
+	mux.HandleFunc("GET /admin/v1/repos/paused", auth.handle("list_paused_repos", listPausedRepos))
+	mux.HandleFunc("PUT /admin/v1/repos/{owner}/{repo}/pause", auth.handle("pause_repo", pauseRepo))
+	mux.HandleFunc("DELETE /admin/v1/repos/{owner}/{repo}/pause", auth.handle("resume_repo", resumeRepo))
+	mux.HandleFunc("GET /admin/v1/maintenance", auth.handle("get_maintenance", adminMaintenance))
+	mux.HandleFunc("PUT /admin/v1/maintenance", auth.handle("set_maintenance", adminMaintenance))
+	log.Infoln("Admin API is enabled")
+}
@@ -0,0 +1,181 @@
+package telefonistka
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/githubapi"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/maintenance"
+)
+
+func newAdminTestMux(t *testing.T) (*http.ServeMux, *lru.Cache[string, githubapi.GhClientPair]) {
+	t.Helper()
+	mainGhClientCache, _ := lru.New[string, githubapi.GhClientPair](128)
+	prApproverGhClientCache, _ := lru.New[string, githubapi.GhClientPair](128)
+	mux := http.NewServeMux()
+	registerAdminHandlers(mux, &adminAuth{token: "s3cr3t"}, mainGhClientCache, prApproverGhClientCache)
+	return mux, mainGhClientCache
+}
+
+func adminRequest(mux *http.ServeMux, method string, target string, body string, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	return w
+}
+
+func TestAdminAPIRejectsUnauthorized(t *testing.T) {
+	t.Parallel()
+	mux, _ := newAdminTestMux(t)
+	tests := map[string]string{
+		"Missing token": "",
+		"Wrong token":   "nope",
+	}
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			w := adminRequest(mux, http.MethodPost, "/admin/v1/caches/flush", "", token)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+		})
+	}
+}
+
+func TestAdminAPIFlushCaches(t *testing.T) {
+	t.Parallel()
+	mux, mainGhClientCache := newAdminTestMux(t)
+	mainGhClientCache.Add("owner", githubapi.GhClientPair{})
+	w := adminRequest(mux, http.MethodPost, "/admin/v1/caches/flush", "", "s3cr3t")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"flushed": {"main": 1, "prApprover": 0}}`, w.Body.String())
+	assert.Equal(t, 0, mainGhClientCache.Len())
+}
+
+func TestAdminAPIPauseRepo(t *testing.T) {
+	t.Parallel()
+	mux, _ := newAdminTestMux(t)
+	w := adminRequest(mux, http.MethodPut, "/admin/v1/repos/owner/admin-test/pause", `{"reason": "migration"}`, "s3cr3t")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, maintenance.ForRepo("owner/admin-test").Enabled)
+	assert.Equal(t, "migration", maintenance.ForRepo("owner/admin-test").Reason)
+
+	w = adminRequest(mux, http.MethodGet, "/admin/v1/repos/paused", "", "s3cr3t")
+	assert.Contains(t, w.Body.String(), `"repo":"owner/admin-test"`)
+
+	w = adminRequest(mux, http.MethodDelete, "/admin/v1/repos/owner/admin-test/pause", "", "s3cr3t")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.False(t, maintenance.ForRepo("owner/admin-test").Enabled)
+
+	w = adminRequest(mux, http.MethodDelete, "/admin/v1/repos/owner/admin-test/pause", "", "s3cr3t")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// Not parallel, the maintenance mode is global
+func TestAdminAPIMaintenance(t *testing.T) {
+	mux, _ := newAdminTestMux(t)
+	w := adminRequest(mux, http.MethodPut, "/admin/v1/maintenance", `{"enabled": true}`, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.False(t, maintenance.Enabled())
+
+	w = adminRequest(mux, http.MethodPut, "/admin/v1/maintenance", `enabled`, "s3cr3t")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = adminRequest(mux, http.MethodPut, "/admin/v1/maintenance", `{"enabled": true, "reason": "ArgoCD upgrade"}`, "s3cr3t")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, maintenance.Enabled())
+	assert.Contains(t, w.Body.String(), `"reason":"ArgoCD upgrade"`)
+
+	w = adminRequest(mux, http.MethodGet, "/admin/v1/maintenance", "", "s3cr3t")
+	assert.Contains(t, w.Body.String(), `"enabled":true`)
+
+	w = adminRequest(mux, http.MethodPut, "/admin/v1/maintenance", `{"enabled": false}`, "s3cr3t")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, maintenance.Enabled())
+}
+
+func TestAdminAPIReplayRejectsBadDeliveryID(t *testing.T) {
+	t.Parallel()
+	mux, _ := newAdminTestMux(t)
+	w := adminRequest(mux, http.MethodPost, "/admin/v1/deliveries/abc/replay", "", "s3cr3t")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAdminAuthorize(t *testing.T) {
+	t.Parallel()
+	auth := &adminAuth{allowedGroups: []string{"sre"}, readOnlyGroups: []string{"developers"}}
+	tests := map[string]struct {
+		principal adminPrincipal
+		readOnly  bool
+		allowed   bool
+	}{
+		"Admin token":             {principal: adminPrincipal{Admin: true}, allowed: true},
+		"Allowed group":           {principal: adminPrincipal{Groups: []string{"devs", "sre"}}, allowed: true},
+		"Read-only group reading": {principal: adminPrincipal{Groups: []string{"developers"}}, readOnly: true, allowed: true},
+		"Read-only group writing": {principal: adminPrincipal{Groups: []string{"developers"}}, allowed: false},
+		"Other group":             {principal: adminPrincipal{Groups: []string{"marketing"}}, readOnly: true, allowed: false},
+		"No groups":               {principal: adminPrincipal{}, readOnly: true, allowed: false},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			err := auth.authorize(tc.principal, tc.readOnly)
+			if tc.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, errAdminForbidden)
+			}
+		})
+	}
+	assert.ErrorIs(t, (&adminAuth{}).authorize(adminPrincipal{Groups: []string{"sre"}}, true), errAdminForbidden, "without allow lists only the admin token is allowed")
+}
+
+// Not parallel, it sets env vars
+func TestNewAdminAuthFromEnvRequiresOIDCGroups(t *testing.T) {
+	t.Setenv("ADMIN_OIDC_ISSUER_URL", "https://issuer.example.com")
+	t.Setenv("ADMIN_OIDC_AUDIENCE", "telefonistka")
+	t.Setenv("ADMIN_ALLOWED_GROUPS", "")
+	t.Setenv("ADMIN_READONLY_GROUPS", "")
+	_, err := newAdminAuthFromEnv(context.Background())
+	assert.ErrorContains(t, err, "ADMIN_ALLOWED_GROUPS or ADMIN_READONLY_GROUPS is required")
+}
+
+func TestClaimGroups(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, []string{"sre"}, claimGroups("sre"))
+	assert.Equal(t, []string{"sre", "developers"}, claimGroups([]any{"sre", 7, "developers"}))
+	assert.Nil(t, claimGroups(nil))
+}
+
+func TestAdminAPIGithubTeams(t *testing.T) {
+	t.Parallel()
+	mainGhClientCache, _ := lru.New[string, githubapi.GhClientPair](128)
+	auth := &adminAuth{
+		githubTeams:    []string{"acme/sre", "acme/developers"},
+		allowedGroups:  []string{"acme/sre"},
+		readOnlyGroups: []string{"acme/developers"},
+		githubUserTeams: func(_ context.Context, token string, _ []string) (string, []string, error) {
+			switch token {
+			case "gho_sre":
+				return "alice", []string{"acme/sre"}, nil
+			case "gho_dev":
+				return "bob", []string{"acme/developers"}, nil
+			}
+			return "", nil, errors.New("Bad credentials")
+		},
+	}
+	mux := http.NewServeMux()
+	registerAdminHandlers(mux, auth, mainGhClientCache, mainGhClientCache)
+
+	assert.Equal(t, http.StatusOK, adminRequest(mux, http.MethodGet, "/admin/v1/events", "", "gho_dev").Code)
+	assert.Equal(t, http.StatusForbidden, adminRequest(mux, http.MethodPost, "/admin/v1/caches/flush", "", "gho_dev").Code)
+	assert.Equal(t, http.StatusOK, adminRequest(mux, http.MethodPost, "/admin/v1/caches/flush", "", "gho_sre").Code)
+	assert.Equal(t, http.StatusUnauthorized, adminRequest(mux, http.MethodGet, "/admin/v1/state", "", "gho_nope").Code)
+}
@@ -0,0 +1,290 @@
+package telefonistka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v62/github"
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/hexops/gotextdiff"
+	"github.com/hexops/gotextdiff/myers"
+	"github.com/hexops/gotextdiff/span"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/githubapi"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/logging"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// bumpTargetRepo is a single entry of the --repos-config file
+type bumpTargetRepo struct {
+	Name   string            `yaml:"name"`
+	Labels map[string]string `yaml:"labels"`
+}
+
+type bumpTargetReposConfig struct {
+	Repos []bumpTargetRepo `yaml:"repos"`
+}
+
+// bumpResult is the outcome of a bump in a single target repo, it's also the structured(--output json|yaml) output of the bump commands
+type bumpResult struct {
+	Repo     string           `json:"repo" yaml:"repo"`
+	PrNumber int              `json:"prNumber,omitempty" yaml:"prNumber,omitempty"`
+	PrURL    string           `json:"prUrl,omitempty" yaml:"prUrl,omitempty"`
+	Branch   string           `json:"branch,omitempty" yaml:"branch,omitempty"`
+	Status   string           `json:"status,omitempty" yaml:"status,omitempty"` // The last stage the bump reached, see waitForBump
+	Files    []bumpFileChange `json:"files,omitempty" yaml:"files,omitempty"`
+	Error    string           `json:"error,omitempty" yaml:"error,omitempty"`
+	Err      error            `json:"-" yaml:"-"`
+}
+
+// bumpFileChange is the diff summary of a single bumped file
+type bumpFileChange struct {
+	Path         string `json:"path" yaml:"path"`
+	LinesAdded   int    `json:"linesAdded" yaml:"linesAdded"`
+	LinesRemoved int    `json:"linesRemoved" yaml:"linesRemoved"`
+}
+
+// diffBumpFile returns the diff summary and the unified diff of a bumped file
+func diffBumpFile(filePath string, before string, after string) (bumpFileChange, gotextdiff.Unified) {
+	edits := myers.ComputeEdits(span.URIFromPath(""), before, after)
+	unified := gotextdiff.ToUnified("Before", "After", before, edits)
+	change := bumpFileChange{Path: filePath}
+	for _, hunk := range unified.Hunks {
+		for _, line := range hunk.Lines {
+			switch line.Kind {
+			case gotextdiff.Insert:
+				change.LinesAdded++
+			case gotextdiff.Delete:
+				change.LinesRemoved++
+			case gotextdiff.Equal:
+			}
+		}
+	}
+	return change, unified
+}
+
+// parseRepoSelector parses a "key=value,key2=value2" label selector
+func parseRepoSelector(selector string) (map[string]string, error) {
+	labels := map[string]string{}
+	for _, term := range strings.Split(selector, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		key, value, found := strings.Cut(term, "=")
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid repo selector term %q, expected key=value", term)
+		}
+		labels[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return labels, nil
+}
+
+// resolveTargetRepos returns the explicitly listed repos and the repos of the config file matching all the selector labels, deduplicated
+func resolveTargetRepos(targetRepos []string, selector string, reposConfig string) ([]string, error) {
+	repos := []string{}
+	seen := map[string]bool{}
+	add := func(repo string) error {
+		repo = strings.TrimSpace(repo)
+		if repo == "" || seen[repo] {
+			return nil
+		}
+		if owner, name, found := strings.Cut(repo, "/"); !found || owner == "" || name == "" {
+			return fmt.Errorf("invalid target repo %q, expected org-name/repo-name", repo)
+		}
+		seen[repo] = true
+		repos = append(repos, repo)
+		return nil
+	}
+	for _, repo := range targetRepos {
+		if err := add(repo); err != nil {
+			return nil, err
+		}
+	}
+
+	if selector != "" {
+		if reposConfig == "" {
+			return nil, fmt.Errorf("--repo-selector requires --repos-config")
+		}
+		labels, err := parseRepoSelector(selector)
+		if err != nil {
+			return nil, err
+		}
+		b, err := os.ReadFile(reposConfig)
+		if err != nil {
+			return nil, fmt.Errorf("read repos config %s: %w", reposConfig, err)
+		}
+		config := bumpTargetReposConfig{}
+		err = yaml.Unmarshal(b, &config)
+		if err != nil {
+			return nil, fmt.Errorf("parse repos config %s: %w", reposConfig, err)
+		}
+		for _, r := range config.Repos {
+			if repoMatchesSelector(r, labels) {
+				if err := add(r.Name); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if len(repos) == 0 {
+		return nil, fmt.Errorf("no target repos were provided or matched the selector")
+	}
+	return repos, nil
+}
+
+func repoMatchesSelector(repo bumpTargetRepo, labels map[string]string) bool {
+	for k, v := range labels {
+		if repo.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// newBumpGhPrClientDetails creates the GitHub client details used by the bump commands for a target repo
+func newBumpGhPrClientDetails(targetRepo string, githubHost string) githubapi.GhPrClientDetails {
+	ctx := context.Background()
+	var githubRestAltURL string
+
+	if githubHost != "" {
+		githubRestAltURL = "https://" + githubHost + "/api/v3"
+		log.Infof("Github REST API endpoint is configured to %s", githubRestAltURL)
+	}
+	var mainGithubClientPair githubapi.GhClientPair
+	mainGhClientCache, _ := lru.New[string, githubapi.GhClientPair](128)
+
+	mainGithubClientPair.GetAndCache(mainGhClientCache, githubapi.MainCredentialEnvVars(ctx), strings.Split(targetRepo, "/")[0], ctx)
+
+	var ghPrClientDetails githubapi.GhPrClientDetails
+
+	ghPrClientDetails.GhClientPair = &mainGithubClientPair
+	ghPrClientDetails.Ctx = ctx
+	ghPrClientDetails.Owner = strings.Split(targetRepo, "/")[0]
+	ghPrClientDetails.Repo = strings.Split(targetRepo, "/")[1]
+	ghPrClientDetails.PrLogger = logging.ForRepo(targetRepo)
+	if githubHost == "" {
+		githubHost = "github.com"
+	}
+	ghPrClientDetails.RepoURL = "https://" + githubHost + "/" + targetRepo
+	return ghPrClientDetails
+}
+
+// bumpAllRepos runs bump(and the requested waits) for every target repo, a failure in one repo doesn't stop the others
+func bumpAllRepos(targetRepos []string, githubHost string, waitOptions bumpWaitOptions, targetFiles []string, bump func(ghPrClientDetails githubapi.GhPrClientDetails) (*github.PullRequest, []bumpFileChange, error)) []bumpResult {
+	results := []bumpResult{}
+	for _, repo := range targetRepos {
+		ghPrClientDetails := newBumpGhPrClientDetails(repo, githubHost)
+		result := bumpResult{Repo: repo}
+		pr, files, err := bump(ghPrClientDetails)
+		result.PrNumber = pr.GetNumber()
+		result.PrURL = pr.GetHTMLURL()
+		result.Branch = pr.GetHead().GetRef()
+		result.Files = files
+		if err == nil {
+			result.Status = bumpStatusOpened
+			result.Status, err = waitForBump(ghPrClientDetails, pr, waitOptions, targetFiles)
+		}
+		if err != nil {
+			log.Errorf("Failed to bump version in %s: %v", repo, err)
+			result.Err = err
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// bumpSummaryTable renders the bump results as a markdown table
+func bumpSummaryTable(results []bumpResult) string {
+	sorted := append([]bumpResult{}, results...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Repo < sorted[j].Repo })
+	var sb strings.Builder
+	sb.WriteString("| Repo | Status | PR |\n|------|--------|----|\n")
+	for _, r := range sorted {
+		status := "✅ " + r.Status
+		if r.Err != nil {
+			status = "❌ " + strings.ReplaceAll(r.Err.Error(), "|", "\\|")
+		}
+		fmt.Fprintf(&sb, "| %s | %s | %s |\n", r.Repo, status, r.PrURL)
+	}
+	return sb.String()
+}
+
+// formatBumpResults renders the bump results in the requested --output format
+func formatBumpResults(results []bumpResult, output string) (string, error) {
+	switch output {
+	case "", "text":
+		return bumpSummaryTable(results), nil
+	case "json":
+		b, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(b) + "\n", nil
+	case "yaml":
+		b, err := yaml.Marshal(results)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	default:
+		return "", fmt.Errorf("unknown output format %q, expected text, json or yaml", output)
+	}
+}
+
+// reportBumpResults prints the results in the requested format(and appends the summary table to the GitHub Actions job summary if available) and exits with the highest exit code of the failed bumps
+func reportBumpResults(results []bumpResult, output string) {
+	formatted, err := formatBumpResults(results, output)
+	if err != nil {
+		log.Errorf("Failed to format bump results: %v", err)
+		os.Exit(bumpExitCodeFailure)
+	}
+	fmt.Print(formatted)
+	if summaryFile := os.Getenv("GITHUB_STEP_SUMMARY"); summaryFile != "" {
+		f, err := os.OpenFile(summaryFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			log.Errorf("Failed to open job summary file %s: %v", summaryFile, err)
+		} else {
+			_, _ = f.WriteString(bumpSummaryTable(results))
+			f.Close()
+		}
+	}
+	exitCode := 0
+	for _, r := range results {
+		if r.Err != nil {
+			exitCode = max(exitCode, bumpExitCode(r.Err))
+		}
+	}
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
+}
+
+// validateOutputFormat is used before the bump starts so a typo doesn't fail the command after the PRs were opened
+func validateOutputFormat(output string) error {
+	_, err := formatBumpResults(nil, output)
+	return err
+}
+
+// addTargetRepoFlags registers the flags selecting the repos a bump command opens PRs in
+func addTargetRepoFlags(cmd *cobra.Command, targetRepos *[]string, repoSelector *string, reposConfig *string) {
+	var defaultTargetRepos []string
+	if targetRepo := getEnv("TARGET_REPO", ""); targetRepo != "" {
+		defaultTargetRepos = strings.Split(targetRepo, ",")
+	}
+	cmd.Flags().StringSliceVarP(targetRepos, "target-repo", "t", defaultTargetRepos, "Target Git repository slug(e.g. org-name/repo-name), defaults to TARGET_REPO env var. Can be repeated or comma separated to open a PR in each repo.")
+	cmd.Flags().StringVar(repoSelector, "repo-selector", "", "Label selector(e.g. team=payments,tier=prod) of additional target repos, resolved with --repos-config.")
+	cmd.Flags().StringVar(reposConfig, "repos-config", getEnv("TARGET_REPOS_CONFIG", ""), "YAML file listing target repos and their labels, defaults to TARGET_REPOS_CONFIG env var.")
+}
+
+// addOutputFlag registers the --output flag of the bump commands
+func addOutputFlag(cmd *cobra.Command, output *string) {
+	cmd.Flags().StringVarP(output, "output", "o", "text", "Output format of the bump results, one of text(markdown table), json or yaml. Logs are written to stderr.")
+}
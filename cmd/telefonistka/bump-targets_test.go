@@ -0,0 +1,134 @@
+package telefonistka
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveTargetRepos(t *testing.T) {
+	t.Parallel()
+	reposConfig := filepath.Join(t.TempDir(), "repos.yaml")
+	err := os.WriteFile(reposConfig, []byte(`repos:
+- name: org/payments-prod
+  labels:
+    team: payments
+    tier: prod
+- name: org/payments-dev
+  labels:
+    team: payments
+    tier: dev
+- name: org/search-prod
+  labels:
+    team: search
+    tier: prod
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		targetRepos []string
+		selector    string
+		reposConfig string
+		want        []string
+		wantErr     bool
+	}{
+		"Explicit repos": {
+			targetRepos: []string{"org/a", "org/b"},
+			want:        []string{"org/a", "org/b"},
+		},
+		"Selector matches all labels": {
+			selector:    "team=payments, tier=prod",
+			reposConfig: reposConfig,
+			want:        []string{"org/payments-prod"},
+		},
+		"Explicit repos and selector are deduplicated": {
+			targetRepos: []string{"org/payments-dev"},
+			selector:    "team=payments",
+			reposConfig: reposConfig,
+			want:        []string{"org/payments-dev", "org/payments-prod"},
+		},
+		"Selector without config": {
+			selector: "team=payments",
+			wantErr:  true,
+		},
+		"Invalid selector": {
+			selector:    "team",
+			reposConfig: reposConfig,
+			wantErr:     true,
+		},
+		"Invalid repo slug": {
+			targetRepos: []string{"just-a-name"},
+			wantErr:     true,
+		},
+		"Nothing matched": {
+			selector:    "team=billing",
+			reposConfig: reposConfig,
+			wantErr:     true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got, err := resolveTargetRepos(tc.targetRepos, tc.selector, tc.reposConfig)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestBumpSummaryTable(t *testing.T) {
+	t.Parallel()
+	results := []bumpResult{
+		{Repo: "org/b", Err: errors.New("fetch values.yaml content: 404 Not Found")},
+		{Repo: "org/a", PrURL: "https://github.com/org/a/pull/1", Status: bumpStatusOpened},
+	}
+	want := "| Repo | Status | PR |\n" +
+		"|------|--------|----|\n" +
+		"| org/a | ✅ Opened | https://github.com/org/a/pull/1 |\n" +
+		"| org/b | ❌ fetch values.yaml content: 404 Not Found |  |\n"
+	assert.Equal(t, want, bumpSummaryTable(results))
+}
+
+func TestFormatBumpResults(t *testing.T) {
+	t.Parallel()
+	results := []bumpResult{
+		{
+			Repo:     "org/a",
+			PrNumber: 1,
+			PrURL:    "https://github.com/org/a/pull/1",
+			Branch:   "artifact_version_bump/org/lib/abc",
+			Status:   bumpStatusMerged,
+			Files:    []bumpFileChange{{Path: "values.yaml", LinesAdded: 1, LinesRemoved: 1}},
+		},
+		{Repo: "org/b", Error: "boom", Err: errors.New("boom")},
+	}
+
+	got, err := formatBumpResults(results, "json")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"repo": "org/a", "prNumber": 1, "prUrl": "https://github.com/org/a/pull/1", "branch": "artifact_version_bump/org/lib/abc", "status": "Merged", "files": [{"path": "values.yaml", "linesAdded": 1, "linesRemoved": 1}]},
+		{"repo": "org/b", "error": "boom"}
+	]`, got)
+
+	got, err = formatBumpResults(results, "yaml")
+	assert.NoError(t, err)
+	assert.Contains(t, got, "- repo: org/b\n  error: boom\n")
+
+	_, err = formatBumpResults(results, "xml")
+	assert.Error(t, err)
+}
+
+func TestDiffBumpFile(t *testing.T) {
+	t.Parallel()
+	change, _ := diffBumpFile("values.yaml", "image:\n  tag: v1\n", "image:\n  tag: v2\n  pullPolicy: Always\n")
+	assert.Equal(t, bumpFileChange{Path: "values.yaml", LinesAdded: 2, LinesRemoved: 1}, change)
+}
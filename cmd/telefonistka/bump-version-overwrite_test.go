@@ -0,0 +1,66 @@
+package telefonistka
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverwriteBumpFiles(t *testing.T) {
+	t.Parallel()
+	manifest := filepath.Join(t.TempDir(), "manifest.yaml")
+	err := os.WriteFile(manifest, []byte("- targetFile: charts/foo/Chart.yaml\n  file: Chart.yaml\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		targetFiles []string
+		files       []string
+		manifest    string
+		want        []overwriteBumpFile
+		wantErr     bool
+	}{
+		"Single pair": {
+			targetFiles: []string{"env/prod/values.yaml"},
+			files:       []string{"version.yaml"},
+			want:        []overwriteBumpFile{{TargetFile: "env/prod/values.yaml", File: "version.yaml"}},
+		},
+		"Pairs and manifest": {
+			targetFiles: []string{"env/prod/values.yaml"},
+			files:       []string{"version.yaml"},
+			manifest:    manifest,
+			want: []overwriteBumpFile{
+				{TargetFile: "env/prod/values.yaml", File: "version.yaml"},
+				{TargetFile: "charts/foo/Chart.yaml", File: "Chart.yaml"},
+			},
+		},
+		"Unpaired flags": {
+			targetFiles: []string{"a.yaml", "b.yaml"},
+			files:       []string{"a.yaml"},
+			wantErr:     true,
+		},
+		"Duplicate target file": {
+			targetFiles: []string{"a.yaml", "a.yaml"},
+			files:       []string{"a.yaml", "b.yaml"},
+			wantErr:     true,
+		},
+		"Nothing to bump": {
+			wantErr: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got, err := overwriteBumpFiles(tc.targetFiles, tc.files, tc.manifest)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -1,15 +1,11 @@
 package telefonistka
 
 import (
-	"context"
+	"fmt"
 	"os"
 	"regexp"
-	"strings"
 
-	lru "github.com/hashicorp/golang-lru/v2"
-	"github.com/hexops/gotextdiff"
-	"github.com/hexops/gotextdiff/myers"
-	"github.com/hexops/gotextdiff/span"
+	"github.com/google/go-github/v62/github"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/wayfair-incubator/telefonistka/internal/pkg/githubapi"
@@ -17,7 +13,9 @@ import (
 
 // This is still(https://github.com/spf13/cobra/issues/1862) the documented way to use cobra
 func init() { //nolint:gochecknoinits
-	var targetRepo string
+	var targetRepos []string
+	var repoSelector string
+	var reposConfig string
 	var targetFile string
 	var regex string
 	var replacement string
@@ -26,16 +24,27 @@ func init() { //nolint:gochecknoinits
 	var triggeringRepoSHA string
 	var triggeringActor string
 	var autoMerge bool
+	var waitOptions bumpWaitOptions
+	var output string
 	eventCmd := &cobra.Command{
 		Use:   "bump-regex",
 		Short: "Bump artifact version in a file using regex",
-		Long:  "Bump artifact version in a file using regex.\nThis open a pull request in the target repo.\n",
+		Long:  "Bump artifact version in a file using regex.\nThis open a pull request in the target repo.\nSeveral target repos can be bumped, each gets its own PR.\n",
 		Args:  cobra.ExactArgs(0),
 		Run: func(cmd *cobra.Command, args []string) {
-			bumpVersionRegex(targetRepo, targetFile, regex, replacement, githubHost, triggeringRepo, triggeringRepoSHA, triggeringActor, autoMerge)
+			if err := validateOutputFormat(output); err != nil {
+				log.Errorf("Invalid --output: %v", err)
+				os.Exit(1)
+			}
+			repos, err := resolveTargetRepos(targetRepos, repoSelector, reposConfig)
+			if err != nil {
+				log.Errorf("Invalid target repos: %v", err)
+				os.Exit(1)
+			}
+			bumpVersionRegex(repos, targetFile, regex, replacement, githubHost, triggeringRepo, triggeringRepoSHA, triggeringActor, autoMerge, waitOptions, output)
 		},
 	}
-	eventCmd.Flags().StringVarP(&targetRepo, "target-repo", "t", getEnv("TARGET_REPO", ""), "Target Git repository slug(e.g. org-name/repo-name), defaults to TARGET_REPO env var.")
+	addTargetRepoFlags(eventCmd, &targetRepos, &repoSelector, &reposConfig)
 	eventCmd.Flags().StringVarP(&targetFile, "target-file", "f", getEnv("TARGET_FILE", ""), "Target file path(from repo root), defaults to TARGET_FILE env var.")
 	eventCmd.Flags().StringVarP(&regex, "regex-string", "r", "", "Regex used to replace artifact version, e.g. 'tag:\\s*(\\S*)'.")
 	eventCmd.Flags().StringVarP(&replacement, "replacement-string", "n", "", "Replacement string that includes the version of new artifact, e.g. 'tag: v2.7.1'.")
@@ -44,46 +53,28 @@ func init() { //nolint:gochecknoinits
 	eventCmd.Flags().StringVarP(&triggeringRepoSHA, "triggering-repo-sha", "s", getEnv("GITHUB_SHA", ""), "Git SHA of triggering repo, defaults to GITHUB_SHA env var.")
 	eventCmd.Flags().StringVarP(&triggeringActor, "triggering-actor", "a", getEnv("GITHUB_ACTOR", ""), "GitHub user of the person/bot who triggered the bump, defaults to GITHUB_ACTOR env var.")
 	eventCmd.Flags().BoolVar(&autoMerge, "auto-merge", false, "Automatically merges the created PR, defaults to false.")
+	addBumpWaitFlags(eventCmd, &waitOptions)
+	addOutputFlag(eventCmd, &output)
 	rootCmd.AddCommand(eventCmd)
 }
 
-func bumpVersionRegex(targetRepo string, targetFile string, regex string, replacement string, githubHost string, triggeringRepo string, triggeringRepoSHA string, triggeringActor string, autoMerge bool) {
-	ctx := context.Background()
-	var githubRestAltURL string
-
-	if githubHost != "" {
-		githubRestAltURL = "https://" + githubHost + "/api/v3"
-		log.Infof("Github REST API endpoint is configured to %s", githubRestAltURL)
-	}
-	var mainGithubClientPair githubapi.GhClientPair
-	mainGhClientCache, _ := lru.New[string, githubapi.GhClientPair](128)
-
-	mainGithubClientPair.GetAndCache(mainGhClientCache, "GITHUB_APP_ID", "GITHUB_APP_PRIVATE_KEY_PATH", "GITHUB_OAUTH_TOKEN", strings.Split(targetRepo, "/")[0], ctx)
-
-	var ghPrClientDetails githubapi.GhPrClientDetails
-
-	ghPrClientDetails.GhClientPair = &mainGithubClientPair
-	ghPrClientDetails.Ctx = ctx
-	ghPrClientDetails.Owner = strings.Split(targetRepo, "/")[0]
-	ghPrClientDetails.Repo = strings.Split(targetRepo, "/")[1]
-	ghPrClientDetails.PrLogger = log.WithFields(log.Fields{}) // TODO what fields should be here?
-
+func bumpVersionRegex(targetRepos []string, targetFile string, regex string, replacement string, githubHost string, triggeringRepo string, triggeringRepoSHA string, triggeringActor string, autoMerge bool, waitOptions bumpWaitOptions, output string) {
 	r := regexp.MustCompile(regex)
-	defaultBranch, _ := ghPrClientDetails.GetDefaultBranch()
 
-	initialFileContent, _, err := githubapi.GetFileContent(ghPrClientDetails, defaultBranch, targetFile)
-	if err != nil {
-		ghPrClientDetails.PrLogger.Errorf("Fail to fetch file content:%s\n", err)
-		os.Exit(1)
-	}
-	newFileContent := r.ReplaceAllString(initialFileContent, replacement)
+	results := bumpAllRepos(targetRepos, githubHost, waitOptions, []string{targetFile}, func(ghPrClientDetails githubapi.GhPrClientDetails) (*github.PullRequest, []bumpFileChange, error) {
+		defaultBranch, _ := ghPrClientDetails.GetDefaultBranch()
 
-	edits := myers.ComputeEdits(span.URIFromPath(""), initialFileContent, newFileContent)
-	ghPrClientDetails.PrLogger.Infof("Diff:\n%s", gotextdiff.ToUnified("Before", "After", initialFileContent, edits))
+		initialFileContent, _, err := githubapi.GetFileContent(ghPrClientDetails, defaultBranch, targetFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("fetch %s content: %w", targetFile, err)
+		}
+		newFileContent := r.ReplaceAllString(initialFileContent, replacement)
 
-	err = githubapi.BumpVersion(ghPrClientDetails, "main", targetFile, newFileContent, triggeringRepo, triggeringRepoSHA, triggeringActor, autoMerge)
-	if err != nil {
-		log.Errorf("Failed to bump version: %v", err)
-		os.Exit(1)
-	}
+		fileChange, diff := diffBumpFile(targetFile, initialFileContent, newFileContent)
+		ghPrClientDetails.PrLogger.Infof("Diff:\n%s", diff)
+
+		pr, err := githubapi.BumpVersion(ghPrClientDetails, "main", targetFile, newFileContent, triggeringRepo, triggeringRepoSHA, triggeringActor, autoMerge)
+		return pr, []bumpFileChange{fileChange}, err
+	})
+	reportBumpResults(results, output)
 }
@@ -0,0 +1,148 @@
+package telefonistka
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/mikefarah/yq/v4/pkg/yqlib"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/githubapi"
+)
+
+// This is still(https://github.com/spf13/cobra/issues/1862) the documented way to use cobra
+func init() { //nolint:gochecknoinits
+	var targetRepo string
+	var targetFile string
+	var level string
+	var regex string
+	var address string
+	var githubHost string
+	var triggeringRepo string
+	var triggeringRepoSHA string
+	var triggeringActor string
+	var autoMerge bool
+	var waitOptions bumpWaitOptions
+	var output string
+	eventCmd := &cobra.Command{
+		Use:   "bump-semver",
+		Short: "Bump the semantic version in a file",
+		Long: `Bump the semantic version in a file.
+The current version is read from the target file using a regex(first capture group) or a yq selector, the next version is computed based on --level.
+This will open a pull request in the target repo.
+`,
+		Args: cobra.ExactArgs(0),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := validateOutputFormat(output); err != nil {
+				log.Errorf("Invalid --output: %v", err)
+				os.Exit(1)
+			}
+			if (regex == "") == (address == "") {
+				log.Errorf("Exactly one of --regex-string or --address is required")
+				os.Exit(1)
+			}
+			bumpVersionSemver(targetRepo, targetFile, level, regex, address, githubHost, triggeringRepo, triggeringRepoSHA, triggeringActor, autoMerge, waitOptions, output)
+		},
+	}
+	eventCmd.Flags().StringVarP(&targetRepo, "target-repo", "t", getEnv("TARGET_REPO", ""), "Target Git repository slug(e.g. org-name/repo-name), defaults to TARGET_REPO env var.")
+	eventCmd.Flags().StringVarP(&targetFile, "target-file", "f", getEnv("TARGET_FILE", ""), "Target file path(from repo root), defaults to TARGET_FILE env var.")
+	eventCmd.Flags().StringVarP(&level, "level", "l", "patch", "Semver level to bump, one of patch, minor or major.")
+	eventCmd.Flags().StringVarP(&regex, "regex-string", "r", "", "Regex with a single capture group matching the current version, e.g. 'version:\\s*(\\S*)'.")
+	eventCmd.Flags().StringVar(&address, "address", "", "Yaml value address of the current version described as a yq selector, e.g. '.image.tag'.")
+	eventCmd.Flags().StringVarP(&githubHost, "github-host", "g", "", "GitHub instance HOSTNAME, defaults to \"github.com\". This is used for GitHub Enterprise Server instances.")
+	eventCmd.Flags().StringVarP(&triggeringRepo, "triggering-repo", "p", getEnv("GITHUB_REPOSITORY", ""), "Github repo triggering the version bump(e.g. `octocat/Hello-World`) defaults to GITHUB_REPOSITORY env var.")
+	eventCmd.Flags().StringVarP(&triggeringRepoSHA, "triggering-repo-sha", "s", getEnv("GITHUB_SHA", ""), "Git SHA of triggering repo, defaults to GITHUB_SHA env var.")
+	eventCmd.Flags().StringVarP(&triggeringActor, "triggering-actor", "a", getEnv("GITHUB_ACTOR", ""), "GitHub user of the person/bot who triggered the bump, defaults to GITHUB_ACTOR env var.")
+	eventCmd.Flags().BoolVar(&autoMerge, "auto-merge", false, "Automatically merges the created PR, defaults to false.")
+	addBumpWaitFlags(eventCmd, &waitOptions)
+	addOutputFlag(eventCmd, &output)
+	rootCmd.AddCommand(eventCmd)
+}
+
+var semverRegex = regexp.MustCompile(`^(v?)(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(?:-[0-9A-Za-z.-]+)?(?:\+[0-9A-Za-z.-]+)?$`)
+
+// nextSemver bumps the version by level, pre-release and build metadata are dropped and a "v" prefix is kept
+func nextSemver(version string, level string) (string, error) {
+	m := semverRegex.FindStringSubmatch(version)
+	if m == nil {
+		return "", fmt.Errorf("%q is not a semantic version", version)
+	}
+	major, _ := strconv.Atoi(m[2])
+	minor, _ := strconv.Atoi(m[3])
+	patch, _ := strconv.Atoi(m[4])
+	switch level {
+	case "major":
+		major, minor, patch = major+1, 0, 0
+	case "minor":
+		minor, patch = minor+1, 0
+	case "patch":
+		patch++
+	default:
+		return "", fmt.Errorf("unknown semver level %q, expected patch, minor or major", level)
+	}
+	return fmt.Sprintf("%s%d.%d.%d", m[1], major, minor, patch), nil
+}
+
+// bumpSemverRegex replaces the first capture group of the first regex match with the next version
+func bumpSemverRegex(content string, regex string, level string) (newContent string, newVersion string, err error) {
+	r, err := regexp.Compile(regex)
+	if err != nil {
+		return "", "", err
+	}
+	loc := r.FindStringSubmatchIndex(content)
+	if loc == nil || len(loc) < 4 || loc[2] == -1 {
+		return "", "", fmt.Errorf("regex %s didn't match a version(capture group 1)", regex)
+	}
+	newVersion, err = nextSemver(content[loc[2]:loc[3]], level)
+	if err != nil {
+		return "", "", err
+	}
+	return content[:loc[2]] + newVersion + content[loc[3]:], newVersion, nil
+}
+
+// bumpSemverYaml reads the current version with a yq selector and updates it with the next version
+func bumpSemverYaml(content string, address string, level string) (newContent string, newVersion string, err error) {
+	preferences := yqlib.NewDefaultYamlPreferences()
+	currentVersion, err := yqlib.NewStringEvaluator().Evaluate(address, content, yqlib.NewYamlEncoder(preferences), yqlib.NewYamlDecoder(preferences))
+	if err != nil {
+		return "", "", err
+	}
+	newVersion, err = nextSemver(strings.TrimSpace(currentVersion), level)
+	if err != nil {
+		return "", "", err
+	}
+	newContent, err = updateYaml(content, address, newVersion)
+	return newContent, newVersion, err
+}
+
+func bumpVersionSemver(targetRepo string, targetFile string, level string, regex string, address string, githubHost string, triggeringRepo string, triggeringRepoSHA string, triggeringActor string, autoMerge bool, waitOptions bumpWaitOptions, output string) {
+	results := bumpAllRepos([]string{targetRepo}, githubHost, waitOptions, []string{targetFile}, func(ghPrClientDetails githubapi.GhPrClientDetails) (*github.PullRequest, []bumpFileChange, error) {
+		defaultBranch, _ := ghPrClientDetails.GetDefaultBranch()
+
+		initialFileContent, _, err := githubapi.GetFileContent(ghPrClientDetails, defaultBranch, targetFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("fetch %s content: %w", targetFile, err)
+		}
+		var newFileContent, newVersion string
+		if regex != "" {
+			newFileContent, newVersion, err = bumpSemverRegex(initialFileContent, regex, level)
+		} else {
+			newFileContent, newVersion, err = bumpSemverYaml(initialFileContent, address, level)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		ghPrClientDetails.PrLogger.Infof("Bumping %s version to %s", level, newVersion)
+
+		fileChange, diff := diffBumpFile(targetFile, initialFileContent, newFileContent)
+		ghPrClientDetails.PrLogger.Infof("Diff:\n%s", diff)
+
+		pr, err := githubapi.BumpVersion(ghPrClientDetails, "main", targetFile, newFileContent, triggeringRepo, triggeringRepoSHA, triggeringActor, autoMerge)
+		return pr, []bumpFileChange{fileChange}, err
+	})
+	reportBumpResults(results, output)
+}
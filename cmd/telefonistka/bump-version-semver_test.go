@@ -0,0 +1,58 @@
+package telefonistka
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextSemver(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		version string
+		level   string
+		want    string
+		wantErr bool
+	}{
+		"Patch":                      {version: "1.2.3", level: "patch", want: "1.2.4"},
+		"Minor resets patch":         {version: "1.2.3", level: "minor", want: "1.3.0"},
+		"Major resets minor":         {version: "v1.2.3", level: "major", want: "v2.0.0"},
+		"Pre-release is dropped":     {version: "1.2.3-rc.1+build5", level: "patch", want: "1.2.4"},
+		"Not a semver":               {version: "latest", level: "patch", wantErr: true},
+		"Unknown level":              {version: "1.2.3", level: "huge", wantErr: true},
+		"Leading zeros are rejected": {version: "01.2.3", level: "patch", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got, err := nextSemver(tc.version, tc.level)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestBumpSemverRegex(t *testing.T) {
+	t.Parallel()
+	content := "image:\n  tag: v1.4.2\nchart:\n  version: 0.3.0\n"
+	got, newVersion, err := bumpSemverRegex(content, `tag:\s*(\S*)`, "minor")
+	assert.NoError(t, err)
+	assert.Equal(t, "v1.5.0", newVersion)
+	assert.Equal(t, "image:\n  tag: v1.5.0\nchart:\n  version: 0.3.0\n", got)
+
+	_, _, err = bumpSemverRegex(content, `appVersion:\s*(\S*)`, "minor")
+	assert.Error(t, err)
+}
+
+func TestBumpSemverYaml(t *testing.T) {
+	t.Parallel()
+	content := "image:\n  tag: 1.4.2\n"
+	got, newVersion, err := bumpSemverYaml(content, ".image.tag", "patch")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.4.3", newVersion)
+	assert.Equal(t, "image:\n  tag: 1.4.3\n", got)
+}
@@ -1,15 +1,10 @@
 package telefonistka
 
 import (
-	"context"
 	"fmt"
 	"os"
-	"strings"
 
-	lru "github.com/hashicorp/golang-lru/v2"
-	"github.com/hexops/gotextdiff"
-	"github.com/hexops/gotextdiff/myers"
-	"github.com/hexops/gotextdiff/span"
+	"github.com/google/go-github/v62/github"
 	"github.com/mikefarah/yq/v4/pkg/yqlib"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -27,6 +22,8 @@ func init() { //nolint:gochecknoinits
 	var triggeringRepoSHA string
 	var triggeringActor string
 	var autoMerge bool
+	var waitOptions bumpWaitOptions
+	var output string
 	eventCmd := &cobra.Command{
 		Use:   "bump-yaml",
 		Short: "Bump artifact version in a file using yaml selector",
@@ -36,7 +33,11 @@ This command uses yq selector to find the yaml value to replace.
 `,
 		Args: cobra.ExactArgs(0),
 		Run: func(cmd *cobra.Command, args []string) {
-			bumpVersionYaml(targetRepo, targetFile, address, replacement, githubHost, triggeringRepo, triggeringRepoSHA, triggeringActor, autoMerge)
+			if err := validateOutputFormat(output); err != nil {
+				log.Errorf("Invalid --output: %v", err)
+				os.Exit(1)
+			}
+			bumpVersionYaml(targetRepo, targetFile, address, replacement, githubHost, triggeringRepo, triggeringRepoSHA, triggeringActor, autoMerge, waitOptions, output)
 		},
 	}
 	eventCmd.Flags().StringVarP(&targetRepo, "target-repo", "t", getEnv("TARGET_REPO", ""), "Target Git repository slug(e.g. org-name/repo-name), defaults to TARGET_REPO env var.")
@@ -48,51 +49,31 @@ This command uses yq selector to find the yaml value to replace.
 	eventCmd.Flags().StringVarP(&triggeringRepoSHA, "triggering-repo-sha", "s", getEnv("GITHUB_SHA", ""), "Git SHA of triggering repo, defaults to GITHUB_SHA env var.")
 	eventCmd.Flags().StringVarP(&triggeringActor, "triggering-actor", "a", getEnv("GITHUB_ACTOR", ""), "GitHub user of the person/bot who triggered the bump, defaults to GITHUB_ACTOR env var.")
 	eventCmd.Flags().BoolVar(&autoMerge, "auto-merge", false, "Automatically merges the created PR, defaults to false.")
+	addBumpWaitFlags(eventCmd, &waitOptions)
+	addOutputFlag(eventCmd, &output)
 	rootCmd.AddCommand(eventCmd)
 }
 
-func bumpVersionYaml(targetRepo string, targetFile string, address string, value string, githubHost string, triggeringRepo string, triggeringRepoSHA string, triggeringActor string, autoMerge bool) {
-	ctx := context.Background()
-	var githubRestAltURL string
+func bumpVersionYaml(targetRepo string, targetFile string, address string, value string, githubHost string, triggeringRepo string, triggeringRepoSHA string, triggeringActor string, autoMerge bool, waitOptions bumpWaitOptions, output string) {
+	results := bumpAllRepos([]string{targetRepo}, githubHost, waitOptions, []string{targetFile}, func(ghPrClientDetails githubapi.GhPrClientDetails) (*github.PullRequest, []bumpFileChange, error) {
+		defaultBranch, _ := ghPrClientDetails.GetDefaultBranch()
 
-	if githubHost != "" {
-		githubRestAltURL = "https://" + githubHost + "/api/v3"
-		log.Infof("Github REST API endpoint is configured to %s", githubRestAltURL)
-	}
-	var mainGithubClientPair githubapi.GhClientPair
-	mainGhClientCache, _ := lru.New[string, githubapi.GhClientPair](128)
-
-	mainGithubClientPair.GetAndCache(mainGhClientCache, "GITHUB_APP_ID", "GITHUB_APP_PRIVATE_KEY_PATH", "GITHUB_OAUTH_TOKEN", strings.Split(targetRepo, "/")[0], ctx)
-
-	var ghPrClientDetails githubapi.GhPrClientDetails
-
-	ghPrClientDetails.GhClientPair = &mainGithubClientPair
-	ghPrClientDetails.Ctx = ctx
-	ghPrClientDetails.Owner = strings.Split(targetRepo, "/")[0]
-	ghPrClientDetails.Repo = strings.Split(targetRepo, "/")[1]
-	ghPrClientDetails.PrLogger = log.WithFields(log.Fields{}) // TODO what fields should be here?
-
-	defaultBranch, _ := ghPrClientDetails.GetDefaultBranch()
-
-	initialFileContent, _, err := githubapi.GetFileContent(ghPrClientDetails, defaultBranch, targetFile)
-	if err != nil {
-		ghPrClientDetails.PrLogger.Errorf("Fail to fetch file content:%s\n", err)
-		os.Exit(1)
-	}
-	newFileContent, err := updateYaml(initialFileContent, address, value)
-	if err != nil {
-		ghPrClientDetails.PrLogger.Errorf("Fail to update yaml:%s\n", err)
-		os.Exit(1)
-	}
+		initialFileContent, _, err := githubapi.GetFileContent(ghPrClientDetails, defaultBranch, targetFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("fetch %s content: %w", targetFile, err)
+		}
+		newFileContent, err := updateYaml(initialFileContent, address, value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("update yaml: %w", err)
+		}
 
-	edits := myers.ComputeEdits(span.URIFromPath(""), initialFileContent, newFileContent)
-	ghPrClientDetails.PrLogger.Infof("Diff:\n%s", gotextdiff.ToUnified("Before", "After", initialFileContent, edits))
+		fileChange, diff := diffBumpFile(targetFile, initialFileContent, newFileContent)
+		ghPrClientDetails.PrLogger.Infof("Diff:\n%s", diff)
 
-	err = githubapi.BumpVersion(ghPrClientDetails, "main", targetFile, newFileContent, triggeringRepo, triggeringRepoSHA, triggeringActor, autoMerge)
-	if err != nil {
-		log.Errorf("Failed to bump version: %v", err)
-		os.Exit(1)
-	}
+		pr, err := githubapi.BumpVersion(ghPrClientDetails, "main", targetFile, newFileContent, triggeringRepo, triggeringRepoSHA, triggeringActor, autoMerge)
+		return pr, []bumpFileChange{fileChange}, err
+	})
+	reportBumpResults(results, output)
 }
 
 func updateYaml(yamlContent string, address string, value string) (string, error) {
@@ -0,0 +1,121 @@
+package telefonistka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/spf13/cobra"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/argocd"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/githubapi"
+)
+
+// Exit codes of the bump commands, CI pipelines can use these to tell why a gated bump failed
+const (
+	bumpExitCodeFailure      = 1
+	bumpExitCodeMergeTimeout = 2
+	bumpExitCodePrClosed     = 3
+	bumpExitCodeSyncTimeout  = 4
+	bumpExitCodeSyncFailed   = 5
+)
+
+const (
+	bumpStatusOpened = "Opened"
+	bumpStatusMerged = "Merged"
+	bumpStatusSynced = "Synced"
+)
+
+const bumpWaitPollInterval = 10 * time.Second
+
+// bumpWaitOptions controls what a bump command waits for after the PR is opened
+type bumpWaitOptions struct {
+	Wait           bool
+	WaitForSync    bool
+	Timeout        time.Duration
+	ComponentPaths []string
+	UseSHALabel    bool
+}
+
+// bumpExitError is a bump failure with a specific exit code
+type bumpExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *bumpExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *bumpExitError) Unwrap() error {
+	return e.Err
+}
+
+func bumpExitCode(err error) int {
+	var exitErr *bumpExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	return bumpExitCodeFailure
+}
+
+func addBumpWaitFlags(cmd *cobra.Command, waitOptions *bumpWaitOptions) {
+	cmd.Flags().BoolVar(&waitOptions.Wait, "wait", false, "Wait until the created PR is merged(usually with --auto-merge), defaults to false.")
+	cmd.Flags().BoolVar(&waitOptions.WaitForSync, "wait-for-sync", false, "Wait until the ArgoCD apps of the bumped components are Synced and Healthy at the merge commit, implies --wait.")
+	cmd.Flags().DurationVar(&waitOptions.Timeout, "wait-timeout", 30*time.Minute, "Overall timeout of --wait and --wait-for-sync.")
+	cmd.Flags().StringArrayVar(&waitOptions.ComponentPaths, "argocd-component-path", nil, "Component path(from repo root) of the ArgoCD app to wait for, defaults to the directories of the target files. Can be repeated.")
+	cmd.Flags().BoolVar(&waitOptions.UseSHALabel, "argocd-use-sha-label", false, "Use the SHA1 label for ArgoCD app discovery, like the useSHALabelForAppDiscovery repo configuration.")
+}
+
+// componentPathsToWaitFor returns the explicitly configured component paths or the directories of the target files
+func (o bumpWaitOptions) componentPathsToWaitFor(targetFiles []string) []string {
+	if len(o.ComponentPaths) > 0 {
+		return o.ComponentPaths
+	}
+	componentPaths := []string{}
+	seen := map[string]bool{}
+	for _, targetFile := range targetFiles {
+		dir := path.Dir(targetFile)
+		if !seen[dir] {
+			seen[dir] = true
+			componentPaths = append(componentPaths, dir)
+		}
+	}
+	return componentPaths
+}
+
+// waitForBump waits for the PR to be merged and its components' ArgoCD apps to be synced as requested and returns the last stage that was reached
+func waitForBump(ghPrClientDetails githubapi.GhPrClientDetails, pr *github.PullRequest, waitOptions bumpWaitOptions, targetFiles []string) (string, error) {
+	if !waitOptions.Wait && !waitOptions.WaitForSync {
+		return bumpStatusOpened, nil
+	}
+	ctx, cancel := context.WithTimeout(ghPrClientDetails.Ctx, waitOptions.Timeout)
+	defer cancel()
+	ghPrClientDetails.Ctx = ctx
+
+	ghPrClientDetails.PrLogger.Infof("Waiting for PR %s to be merged", pr.GetHTMLURL())
+	mergeCommitSHA, err := githubapi.WaitForPrMerge(ghPrClientDetails, pr.GetNumber(), bumpWaitPollInterval)
+	if errors.Is(err, githubapi.ErrPrClosedWithoutMerge) {
+		return bumpStatusOpened, &bumpExitError{Code: bumpExitCodePrClosed, Err: err}
+	} else if err != nil {
+		return bumpStatusOpened, &bumpExitError{Code: bumpExitCodeMergeTimeout, Err: err}
+	}
+	if !waitOptions.WaitForSync {
+		return bumpStatusMerged, nil
+	}
+
+	for _, componentPath := range waitOptions.componentPathsToWaitFor(targetFiles) {
+		ghPrClientDetails.PrLogger.Infof("Waiting for the ArgoCD app of %s to be Synced and Healthy at %s", componentPath, mergeCommitSHA)
+		result := argocd.WaitForComponentAppRevision(ctx, componentPath, ghPrClientDetails.RepoURL, waitOptions.UseSHALabel, mergeCommitSHA, bumpWaitPollInterval)
+		if result.Err != nil {
+			code := bumpExitCodeSyncFailed
+			if errors.Is(result.Err, context.DeadlineExceeded) {
+				code = bumpExitCodeSyncTimeout
+			}
+			return bumpStatusMerged, &bumpExitError{Code: code, Err: fmt.Errorf("ArgoCD app of %s(sync: %s, health: %s): %w", componentPath, result.SyncStatus, result.HealthStatus, result.Err)}
+		}
+	}
+	return bumpStatusSynced, nil
+}
@@ -0,0 +1,35 @@
+package telefonistka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBumpExitCode(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		err  error
+		want int
+	}{
+		"Plain error":   {err: errors.New("boom"), want: bumpExitCodeFailure},
+		"Sync timeout":  {err: &bumpExitError{Code: bumpExitCodeSyncTimeout, Err: context.DeadlineExceeded}, want: bumpExitCodeSyncTimeout},
+		"Wrapped error": {err: fmt.Errorf("bump: %w", &bumpExitError{Code: bumpExitCodePrClosed, Err: errors.New("closed")}), want: bumpExitCodePrClosed},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.want, bumpExitCode(tc.err))
+		})
+	}
+}
+
+func TestComponentPathsToWaitFor(t *testing.T) {
+	t.Parallel()
+	targetFiles := []string{"env/prod/foo/values.yaml", "env/prod/foo/version.yaml", "env/prod/bar/values.yaml"}
+	assert.Equal(t, []string{"env/prod/foo", "env/prod/bar"}, bumpWaitOptions{}.componentPathsToWaitFor(targetFiles))
+	assert.Equal(t, []string{"env/prod/foo"}, bumpWaitOptions{ComponentPaths: []string{"env/prod/foo"}}.componentPathsToWaitFor(targetFiles))
+}
@@ -0,0 +1,81 @@
+package telefonistka
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sort"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	log "github.com/sirupsen/logrus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/githubapi"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/inflight"
+)
+
+type cacheState struct {
+	Name string   `json:"name"`
+	Len  int      `json:"len"`
+	Keys []string `json:"keys"`
+}
+
+type debugState struct {
+	Goroutines       int                `json:"goroutines"`
+	Caches           []cacheState       `json:"caches"`
+	InFlightHandlers []inflight.Handler `json:"inFlightHandlers"`
+}
+
+func newCacheState(name string, cache *lru.Cache[string, githubapi.GhClientPair]) cacheState {
+	keys := cache.Keys()
+	sort.Strings(keys)
+	return cacheState{Name: name, Len: cache.Len(), Keys: keys}
+}
+
+// handleDebugState dumps the GitHub client caches and the running event handlers with their ages
+func handleDebugState(caches map[string]*lru.Cache[string, githubapi.GhClientPair]) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		state := debugState{
+			Goroutines:       runtime.NumGoroutine(),
+			Caches:           []cacheState{},
+			InFlightHandlers: inflight.Snapshot(),
+		}
+		for name, cache := range caches {
+			state.Caches = append(state.Caches, newCacheState(name, cache))
+		}
+		sort.Slice(state.Caches, func(i, j int) bool { return state.Caches[i].Name < state.Caches[j].Name })
+
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(state)
+		if err != nil {
+			log.Errorf("Failed to encode debug state: %v", err)
+		}
+	}
+}
+
+// registerDebugHandlers adds the pprof and /debug/state endpoints, these expose internal details so they are served on their own listener(see serveDebug)
+func registerDebugHandlers(mux *http.ServeMux, caches map[string]*lru.Cache[string, githubapi.GhClientPair]) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/state", handleDebugState(caches))
+	log.Infoln("Debug endpoints are enabled")
+}
+
+// serveDebug serves the debug endpoints on addr, which defaults to localhost so they aren't reachable through the webhook listener.
+// There is no write timeout as CPU profiles and traces stream for 30 seconds by default
+func serveDebug(addr string, caches map[string]*lru.Cache[string, githubapi.GhClientPair]) {
+	mux := http.NewServeMux()
+	registerDebugHandlers(mux, caches)
+	srv := &http.Server{
+		Handler:     mux,
+		Addr:        addr,
+		ReadTimeout: 10 * time.Second,
+	}
+	log.Infof("Debug endpoints listening on %s", addr)
+	if err := srv.ListenAndServe(); err != nil {
+		log.Errorf("Debug endpoints listener failed: %v", err)
+	}
+}
@@ -0,0 +1,43 @@
+package telefonistka
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/githubapi"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/inflight"
+)
+
+func TestHandleDebugState(t *testing.T) {
+	t.Parallel()
+	mainGhClientCache, _ := lru.New[string, githubapi.GhClientPair](128)
+	mainGhClientCache.Add("org-b", githubapi.GhClientPair{})
+	mainGhClientCache.Add("org-a", githubapi.GhClientPair{})
+	prApproverGhClientCache, _ := lru.New[string, githubapi.GhClientPair](128)
+
+	done := inflight.Track("github", "pull_request", "org-a/repo")
+	defer done()
+
+	w := httptest.NewRecorder()
+	handleDebugState(map[string]*lru.Cache[string, githubapi.GhClientPair]{
+		"main":       mainGhClientCache,
+		"prApprover": prApproverGhClientCache,
+	})(w, httptest.NewRequest(http.MethodGet, "/debug/state", nil))
+
+	var state debugState
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &state))
+	assert.Positive(t, state.Goroutines)
+	assert.Equal(t, []cacheState{
+		{Name: "main", Len: 2, Keys: []string{"org-a", "org-b"}},
+		{Name: "prApprover", Len: 0, Keys: []string{}},
+	}, state.Caches)
+	repos := []string{}
+	for _, h := range state.InFlightHandlers {
+		repos = append(repos, h.Repo)
+	}
+	assert.Contains(t, repos, "org-a/repo")
+}
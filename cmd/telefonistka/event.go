@@ -4,6 +4,7 @@ import (
 	"os"
 
 	lru "github.com/hashicorp/golang-lru/v2"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/wayfair-incubator/telefonistka/internal/pkg/githubapi"
 )
@@ -23,13 +24,38 @@ func init() { //nolint:gochecknoinits
 	}
 	eventCmd.Flags().StringVarP(&eventType, "type", "t", getEnv("GITHUB_EVENT_NAME", ""), "Event type, defaults to GITHUB_EVENT_NAME env var")
 	eventCmd.Flags().StringVarP(&eventFilePath, "file", "f", getEnv("GITHUB_EVENT_PATH", ""), "File path for event JSON, defaults to GITHUB_EVENT_PATH env var")
+
+	var deliveryID int64
+	replayCmd := &cobra.Command{
+		Use:   "replay",
+		Short: "Handles a GitHub App webhook delivery again, fetched by its delivery ID",
+		Long:  "Handles a GitHub App webhook delivery again, fetched by its delivery ID.\nThe delivery is fetched with the GitHub App credentials(GITHUB_APP_ID/GITHUB_APP_PRIVATE_KEY_PATH env vars), delivery IDs are listed in the App \"Advanced\" settings page",
+		Args:  cobra.ExactArgs(0),
+		Run: func(cmd *cobra.Command, args []string) {
+			replayEvent(deliveryID)
+		},
+	}
+	replayCmd.Flags().Int64VarP(&deliveryID, "delivery-id", "d", 0, "GitHub App webhook delivery ID")
+	_ = replayCmd.MarkFlagRequired("delivery-id")
+	eventCmd.AddCommand(replayCmd)
+
 	rootCmd.AddCommand(eventCmd)
 }
 
 func event(eventType string, eventFilePath string) {
 	mainGhClientCache, _ := lru.New[string, githubapi.GhClientPair](128)
 	prApproverGhClientCache, _ := lru.New[string, githubapi.GhClientPair](128)
-	githubapi.ReciveEventFile(eventFilePath, eventType, mainGhClientCache, prApproverGhClientCache)
+	githubapi.ReciveEventFile(eventType, eventFilePath, mainGhClientCache, prApproverGhClientCache)
+}
+
+func replayEvent(deliveryID int64) {
+	mainGhClientCache, _ := lru.New[string, githubapi.GhClientPair](128)
+	prApproverGhClientCache, _ := lru.New[string, githubapi.GhClientPair](128)
+	err := githubapi.ReplayHookDelivery(deliveryID, mainGhClientCache, prApproverGhClientCache, true)
+	if err != nil {
+		log.Errorf("Failed to replay webhook delivery %d: %v", deliveryID, err)
+		os.Exit(1)
+	}
 }
 
 func getEnv(key, fallback string) string {
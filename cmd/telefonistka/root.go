@@ -4,8 +4,8 @@ import (
 	"fmt"
 	"os"
 
-	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/logging"
 )
 
 var rootCmd = &cobra.Command{
@@ -19,28 +19,28 @@ see https://github.com/wayfair-incubator/telefonistka`,
 	},
 }
 
-func Execute() {
-	switch getEnv("LOG_LEVEL", "info") {
-	case "debug":
-		log.SetLevel(log.DebugLevel)
-		log.SetReportCaller(true)
-	case "info":
-		log.SetLevel(log.InfoLevel)
-	case "warn":
-		log.SetLevel(log.WarnLevel)
-	case "error":
-		log.SetLevel(log.ErrorLevel)
-	case "fatal":
-		log.SetLevel(log.FatalLevel)
-	case "panic":
-		log.SetLevel(log.PanicLevel)
+func init() { //nolint:gochecknoinits
+	rootCmd.PersistentFlags().String("log-level", "", "Log level(trace, debug, info, warn, error, fatal or panic), defaults to the LOG_LEVEL env var or info")
+	rootCmd.PersistentFlags().String("log-format", "", "Log format(text or json), defaults to the LOG_FORMAT env var or text")
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		return configureLogging(cmd)
 	}
+}
 
-	log.SetFormatter(&log.TextFormatter{
-		DisableColors: false,
-		// ForceColors: true,
-		FullTimestamp: true,
-	}) // TimestampFormat
+// configureLogging applies the log flags, falling back to their env vars. Unset settings can still be set by the server configuration
+func configureLogging(cmd *cobra.Command) error {
+	level, _ := cmd.Flags().GetString("log-level")
+	if level == "" {
+		level = getEnv("LOG_LEVEL", "")
+	}
+	format, _ := cmd.Flags().GetString("log-format")
+	if format == "" {
+		format = getEnv("LOG_FORMAT", "")
+	}
+	return logging.Configure(logging.Config{Level: level, Format: format, DebugRepos: splitEnvList("LOG_DEBUG_REPOS")})
+}
+
+func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Whoops. There was an error while executing your CLI '%s'", err)
 		os.Exit(1)
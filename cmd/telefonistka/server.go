@@ -1,8 +1,14 @@
 package telefonistka
 
 import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/alexliesenfeld/health"
@@ -10,11 +16,20 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/argocd"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
 	"github.com/wayfair-incubator/telefonistka/internal/pkg/githubapi"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/leader"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/maintenance"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/scm"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/scm/bitbucket"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/scm/gitea"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/secrets"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/tenancy"
 )
 
 func getCrucialEnv(key string) string {
-	if value, ok := os.LookupEnv(key); ok {
+	if value, ok := secrets.Lookup(key); ok {
 		return value
 	}
 	log.Fatalf("%s environment variable is required", key)
@@ -36,11 +51,70 @@ func init() { //nolint:gochecknoinits
 	rootCmd.AddCommand(serveCmd)
 }
 
-func handleWebhook(githubWebhookSecret []byte, mainGhClientCache *lru.Cache[string, githubapi.GhClientPair], prApproverGhClientCache *lru.Cache[string, githubapi.GhClientPair]) func(http.ResponseWriter, *http.Request) {
+// handleWebhook resolves the GitHub webhook secret on every request so a secret rotated in its secret store is picked up without a restart
+func handleWebhook(githubWebhookSecretName string, webhookGuard *githubapi.WebhookGuard, mainGhClientCache *lru.Cache[string, githubapi.GhClientPair], prApproverGhClientCache *lru.Cache[string, githubapi.GhClientPair], bitbucketProvider *bitbucket.Provider) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		err := githubapi.ReciveWebhook(r, mainGhClientCache, prApproverGhClientCache, githubWebhookSecret)
+		var err error
+		// Bitbucket webhooks can be sent to the generic endpoint, they are detected by their event header.
+		// Gitea webhooks also send X-GitHub-Event, so they are only accepted on /webhook/gitea
+		if bitbucketProvider != nil && r.Header.Get(bitbucket.EventKeyHeader) != "" && r.Header.Get("X-GitHub-Event") == "" {
+			// The event header is attacker controlled, so these requests get the same source check as GitHub webhooks on this endpoint
+			err = webhookGuard.CheckSource(r)
+			if err == nil {
+				err = scm.ReceiveWebhook(bitbucketProvider, r)
+			}
+		} else {
+			err = githubapi.ReciveWebhook(r, mainGhClientCache, prApproverGhClientCache, []byte(secrets.Get(githubWebhookSecretName, "")), webhookGuard)
+		}
+		writeWebhookResponse(w, err)
+	}
+}
+
+// handleTenantWebhook serves the webhook endpoints of the tenants, see tenancy.Tenant.WebhookPath. The tenant is looked up on every request so reloaded configurations apply
+func handleTenantWebhook(webhookGuard *githubapi.WebhookGuard, mainGhClientCache *lru.Cache[string, githubapi.GhClientPair], prApproverGhClientCache *lru.Cache[string, githubapi.GhClientPair]) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant := tenancy.ForWebhookPath(r.PathValue("webhookPath"))
+		if tenant == nil {
+			http.NotFound(w, r)
+			return
+		}
+		writeWebhookResponse(w, githubapi.ReceiveTenantWebhook(r, tenant, mainGhClientCache, prApproverGhClientCache, webhookGuard))
+	}
+}
+
+// handleConfigSchema serves the JSON Schema of telefonistka.yaml, it matches the configuration this version accepts
+func handleConfigSchema(w http.ResponseWriter, r *http.Request) {
+	schema, err := cfg.JSONSchema()
+	if err != nil {
+		log.Errorf("Failed to generate the configuration schema: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/schema+json")
+	_, _ = w.Write(schema)
+}
+
+func writeWebhookResponse(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, githubapi.ErrWebhookSourceNotAllowed), errors.Is(err, githubapi.ErrWebhookTenantMismatch):
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	case errors.Is(err, githubapi.ErrDuplicateWebhookDelivery):
+		http.Error(w, "Duplicate delivery", http.StatusConflict)
+		return
+	case err != nil:
+		log.Errorf("error handling webhook: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func handleProviderWebhook(provider scm.Provider) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := scm.ReceiveWebhook(provider, r)
 		if err != nil {
-			log.Errorf("error handling webhook: %v", err)
+			log.Errorf("error handling %s webhook: %v", provider.Name(), err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
@@ -48,10 +122,126 @@ func handleWebhook(githubWebhookSecret []byte, mainGhClientCache *lru.Cache[stri
 	}
 }
 
+func bearerTokenAuthorized(r *http.Request, apiToken string) bool {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return found && subtle.ConstantTimeCompare([]byte(token), []byte(apiToken)) == 1
+}
+
+// handleDriftDetection refreshes the drift reports of the open PRs of a repo(or of the PR in the pr query parameter), the caller must present API_TOKEN as a bearer token
+func handleDriftDetection(apiToken string, mainGhClientCache *lru.Cache[string, githubapi.GhClientPair]) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !bearerTokenAuthorized(r, apiToken) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		prNumber := 0
+		if pr := r.URL.Query().Get("pr"); pr != "" {
+			var err error
+			prNumber, err = strconv.Atoi(pr)
+			if err != nil || prNumber <= 0 {
+				http.Error(w, "pr query parameter should be a PR number", http.StatusBadRequest)
+				return
+			}
+		}
+		err := githubapi.TriggerDriftDetection(r.PathValue("owner"), r.PathValue("repo"), prNumber, mainGhClientCache)
+		switch {
+		case errors.Is(err, githubapi.ErrDriftTargetNotFound):
+			http.Error(w, "Repo or open PR not found", http.StatusNotFound)
+			return
+		case err != nil:
+			log.Errorf("error triggering drift detection of %s/%s: %v", r.PathValue("owner"), r.PathValue("repo"), err)
+			http.Error(w, "Failed to trigger drift detection", http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) int {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Errorf("Failed to encode response: %v", err)
+	}
+	return http.StatusOK
+}
+
+// handleArgocdNotification receives the ArgoCD Notifications webhooks of app health changes, the notification service must present ARGOCD_NOTIFICATIONS_TOKEN as a bearer token
+func handleArgocdNotification(token string, mainGhClientCache *lru.Cache[string, githubapi.GhClientPair]) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !bearerTokenAuthorized(r, token) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var notification githubapi.ArgocdNotification
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&notification); err != nil {
+			http.Error(w, "Body should be the JSON of the Telefonistka notification template", http.StatusBadRequest)
+			return
+		}
+		if err := githubapi.HandleArgocdNotification(notification, mainGhClientCache); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// readinessChecks verifies the GitHub credentials and, when configured, the ArgoCD API, periodically so probes don't hammer the APIs
+func readinessChecks() []health.CheckerOption {
+	checkInterval := 60 * time.Second
+	if seconds, err := strconv.Atoi(getEnv("READINESS_CHECK_INTERVAL_SECONDS", "60")); err == nil && seconds > 0 {
+		checkInterval = time.Duration(seconds) * time.Second
+	}
+	options := []health.CheckerOption{
+		health.WithTimeout(10 * time.Second),
+		health.WithPeriodicCheck(checkInterval, 0, health.Check{
+			Name:    "github",
+			Timeout: 10 * time.Second,
+			Check:   githubapi.CheckGithubCredentials,
+		}),
+	}
+	if getEnv("ARGOCD_SERVER_ADDR", "") != "" {
+		options = append(options, health.WithPeriodicCheck(checkInterval, 0, health.Check{
+			Name:    "argocd",
+			Timeout: 10 * time.Second,
+			Check:   argocd.CheckArgoCDConnectivity,
+		}))
+	}
+	return options
+}
+
 func serve() {
-	githubWebhookSecret := []byte(getCrucialEnv("GITHUB_WEBHOOK_SECRET"))
-	livenessChecker := health.NewChecker() // No checks for the moment, other then the http server availability
-	readinessChecker := health.NewChecker()
+	if err := tenancy.LoadFromEnv(); err != nil {
+		log.Fatalf("Failed to load server configuration: %v", err)
+	}
+	if err := tenancy.WatchFromEnv(context.Background()); err != nil {
+		log.Fatalf("Failed to watch server configuration: %v", err)
+	}
+	if err := maintenance.ShareFromEnv(); err != nil {
+		log.Fatalf("Failed to share the maintenance mode: %v", err)
+	}
+	if err := maintenance.LoadFromEnv(); err != nil {
+		log.Fatalf("Failed to load maintenance mode: %v", err)
+	}
+	// The admin API requests reach a single replica, the maintenance mode and paused repos they set have to reach all of them
+	if leaderElection, _ := strconv.ParseBool(getEnv("LEADER_ELECTION_ENABLED", "false")); leaderElection && !maintenance.Shared() {
+		log.Fatalf("LEADER_ELECTION_ENABLED requires CACHE_REDIS_ADDR, the replicas share the maintenance mode and paused repos through it")
+	}
+	// With more than one replica only the leader runs the periodic jobs, webhooks are handled by all of them
+	if err := leader.RunFromEnv(context.Background()); err != nil {
+		log.Fatalf("Failed to start leader election: %v", err)
+	}
+	// Fail early when the webhook secret is missing from all secret sources, with tenants it can be set per tenant instead
+	if !tenancy.Configured() {
+		getCrucialEnv("GITHUB_WEBHOOK_SECRET")
+	}
+	// Unsigned promotion PR metadata can be forged by anyone able to edit a PR description
+	if err := githubapi.CheckPrMetadataSigningKey(); err != nil {
+		log.Fatalf("%v", err)
+	}
+	githubapi.CheckGistDiffUpload()
+	// Liveness deliberately doesn't check dependencies, a GitHub or ArgoCD outage shouldn't restart all the pods
+	livenessChecker := health.NewChecker()
+	readinessChecker := health.NewChecker(readinessChecks()...)
 
 	// mainGhClientCache := map[string]githubapi.GhClientPair{} //GH apps use a per-account/org client
 	mainGhClientCache, _ := lru.New[string, githubapi.GhClientPair](128)
@@ -59,14 +249,70 @@ func serve() {
 
 	go githubapi.MainGhMetricsLoop(mainGhClientCache)
 
+	// Temporary ArgoCD apps are kept when their diff fails, so they need to be cleaned up eventually
+	if tempAppMaxAge, err := strconv.Atoi(getEnv("ARGOCD_TEMP_APP_MAX_AGE_MINUTES", "0")); err == nil && tempAppMaxAge > 0 {
+		gcInterval, err := strconv.Atoi(getEnv("ARGOCD_TEMP_APP_GC_INTERVAL_MINUTES", "10"))
+		if err != nil || gcInterval <= 0 {
+			log.Fatalf("ARGOCD_TEMP_APP_GC_INTERVAL_MINUTES should be a positive integer: %v", err)
+		}
+		go argocd.TempAppGarbageCollectorLoop(time.Duration(gcInterval)*time.Minute, time.Duration(tempAppMaxAge)*time.Minute)
+	}
+
+	if janitorInterval, err := strconv.Atoi(getEnv("PROMOTION_PR_JANITOR_INTERVAL_MINUTES", "0")); err == nil && janitorInterval > 0 {
+		go githubapi.PromotionPrJanitorLoop(mainGhClientCache, time.Duration(janitorInterval)*time.Minute)
+	}
+
+	if trainInterval, err := strconv.Atoi(getEnv("PROMOTION_TRAIN_INTERVAL_MINUTES", "0")); err == nil && trainInterval > 0 {
+		go githubapi.PromotionTrainLoop(mainGhClientCache, prApproverGhClientCache, time.Duration(trainInterval)*time.Minute)
+	}
+
+	if driftScanInterval, err := strconv.Atoi(getEnv("DRIFT_SCAN_INTERVAL_MINUTES", "0")); err == nil && driftScanInterval > 0 {
+		go githubapi.DriftScanLoop(mainGhClientCache, time.Duration(driftScanInterval)*time.Minute)
+	}
+
+	bitbucketProvider := bitbucket.NewFromEnv()
+	giteaProvider := gitea.NewFromEnv()
+
 	mux := http.NewServeMux()
-	mux.HandleFunc("/webhook", handleWebhook(githubWebhookSecret, mainGhClientCache, prApproverGhClientCache))
+	webhookGuard := githubapi.NewWebhookGuardFromEnv(context.Background())
+	mux.HandleFunc("/webhook", handleWebhook("GITHUB_WEBHOOK_SECRET", webhookGuard, mainGhClientCache, prApproverGhClientCache, bitbucketProvider))
+	mux.HandleFunc("/webhook/github/{webhookPath}", handleTenantWebhook(webhookGuard, mainGhClientCache, prApproverGhClientCache))
+	if bitbucketProvider != nil {
+		mux.HandleFunc("/webhook/bitbucket", handleProviderWebhook(bitbucketProvider))
+	}
+	if giteaProvider != nil {
+		mux.HandleFunc("/webhook/gitea", handleProviderWebhook(giteaProvider))
+	}
+	// Same for the API endpoints
+	if apiToken := secrets.Get("API_TOKEN", ""); apiToken != "" {
+		mux.HandleFunc("POST /api/v1/drift/{owner}/{repo}", handleDriftDetection(apiToken, mainGhClientCache))
+	}
+	// And for the ArgoCD Notifications webhook
+	if argocdNotificationsToken := secrets.Get("ARGOCD_NOTIFICATIONS_TOKEN", ""); argocdNotificationsToken != "" {
+		mux.HandleFunc("POST /webhook/argocd", handleArgocdNotification(argocdNotificationsToken, mainGhClientCache))
+	}
+	adminAuth, err := newAdminAuthFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to configure the admin API: %v", err)
+	}
+	if adminAuth != nil {
+		registerAdminHandlers(mux, adminAuth, mainGhClientCache, prApproverGhClientCache)
+	}
+	if debugEndpoints, _ := strconv.ParseBool(getEnv("DEBUG_ENDPOINTS_ENABLED", "false")); debugEndpoints {
+		go serveDebug(getEnv("DEBUG_LISTEN_ADDR", "localhost:6060"), map[string]*lru.Cache[string, githubapi.GhClientPair]{
+			"main":       mainGhClientCache,
+			"prApprover": prApproverGhClientCache,
+		})
+	}
+	mux.HandleFunc("GET /schema/telefonistka.json", handleConfigSchema)
 	mux.Handle("/metrics", promhttp.Handler())
 	mux.Handle("/live", health.NewHandler(livenessChecker))
 	mux.Handle("/ready", health.NewHandler(readinessChecker))
+	mux.Handle("/healthz", health.NewHandler(livenessChecker))
+	mux.Handle("/readyz", health.NewHandler(readinessChecker))
 
 	srv := &http.Server{
-		Handler:      mux,
+		Handler:      withAccessLog(mux, accessLogOptionsFromEnv()),
 		Addr:         ":8080",
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
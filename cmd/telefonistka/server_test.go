@@ -0,0 +1,95 @@
+package telefonistka
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/scm/bitbucket"
+)
+
+func TestBearerTokenAuthorized(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		authorization string
+		want          bool
+	}{
+		"Matching token":   {authorization: "Bearer s3cr3t", want: true},
+		"Wrong token":      {authorization: "Bearer nope", want: false},
+		"Missing header":   {authorization: "", want: false},
+		"Not bearer token": {authorization: "Basic s3cr3t", want: false},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/drift/owner/repo", nil)
+			if tc.authorization != "" {
+				r.Header.Set("Authorization", tc.authorization)
+			}
+			assert.Equal(t, tc.want, bearerTokenAuthorized(r, "s3cr3t"))
+		})
+	}
+}
+
+func TestHandleConfigSchema(t *testing.T) {
+	t.Parallel()
+	w := httptest.NewRecorder()
+	handleConfigSchema(w, httptest.NewRequest(http.MethodGet, "/schema/telefonistka.json", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/schema+json", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `"autoMergeNoDiffPRs"`)
+}
+
+func TestHandleDriftDetectionRejectsBadRequests(t *testing.T) {
+	t.Parallel()
+	w := httptest.NewRecorder()
+	handleDriftDetection("s3cr3t", nil)(w, httptest.NewRequest(http.MethodPost, "/api/v1/drift/owner/repo", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/drift/owner/repo?pr=abc", nil)
+	r.Header.Set("Authorization", "Bearer s3cr3t")
+	handleDriftDetection("s3cr3t", nil)(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandleArgocdNotificationRejectsBadRequests(t *testing.T) {
+	t.Parallel()
+	w := httptest.NewRecorder()
+	handleArgocdNotification("s3cr3t", nil)(w, httptest.NewRequest(http.MethodPost, "/webhook/argocd", strings.NewReader(`{}`)))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	tests := map[string]string{
+		"Not JSON":         `app=c1`,
+		"Missing fields":   `{"app": "c1", "health": "Degraded"}`,
+		"Unknown repo URL": `{"app": "c1", "repoURL": "not-a-url", "path": "env/prod/c1", "health": "Degraded", "revision": "abc"}`,
+	}
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/webhook/argocd", strings.NewReader(body))
+			r.Header.Set("Authorization", "Bearer s3cr3t")
+			handleArgocdNotification("s3cr3t", nil)(w, r)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+
+	// Healthy apps are accepted and ignored
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/webhook/argocd", strings.NewReader(`{"app": "c1", "repoURL": "https://github.com/owner/repo.git", "path": "env/prod/c1", "health": "Healthy"}`))
+	r.Header.Set("Authorization", "Bearer s3cr3t")
+	handleArgocdNotification("s3cr3t", nil)(w, r)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+}
+
+func TestHandleWebhookRejectsUnsignedBitbucketWebhook(t *testing.T) {
+	t.Parallel()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{"pullrequest": {"id": 1}, "repository": {"full_name": "owner/repo"}}`))
+	r.Header.Set(bitbucket.EventKeyHeader, "pullrequest:fulfilled")
+	handleWebhook("GITHUB_WEBHOOK_SECRET", nil, nil, nil, bitbucket.New("", "", "token", "secret"))(w, r)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
@@ -6,16 +6,19 @@ import (
 	"crypto/sha1" //nolint:gosec // G505: Blocklisted import crypto/sha1: weak cryptographic primitive (gosec), this is not a cryptographic use case
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/argoproj/argo-cd/v2/applicationset/utils"
 	cmdutil "github.com/argoproj/argo-cd/v2/cmd/util"
+	argocdcommon "github.com/argoproj/argo-cd/v2/common"
 	"github.com/argoproj/argo-cd/v2/pkg/apiclient"
 	"github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
 	applicationsetpkg "github.com/argoproj/argo-cd/v2/pkg/apiclient/applicationset"
@@ -28,10 +31,18 @@ import (
 	"github.com/gonvenience/ytbx"
 	"github.com/homeport/dyff/pkg/dyff"
 	log "github.com/sirupsen/logrus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/maintenance"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/tenancy"
 	yaml3 "gopkg.in/yaml.v3"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
+const (
+	defaultDiffConcurrency      = 10
+	defaultComponentDiffTimeout = 5 * time.Minute
+)
+
 type argoCdClients struct {
 	app     application.ApplicationServiceClient
 	project projectpkg.ProjectServiceClient
@@ -46,6 +57,45 @@ type DiffElement struct {
 	ObjectKind      string
 	ObjectNamespace string
 	Diff            string
+	ImageChanges    []ImageChange
+	ChangeType      ChangeType
+	// Pod resource requests of the live and target objects, zero for deleted/added objects
+	LiveRequests   ResourceRequests
+	TargetRequests ResourceRequests
+}
+
+// ChangeType describes what will happen to a k8s object when the diff is applied
+type ChangeType string
+
+const (
+	ObjectAdded   ChangeType = "added"
+	ObjectChanged ChangeType = "changed"
+	ObjectDeleted ChangeType = "deleted"
+)
+
+// highRiskKinds are object kinds where a change can have a cluster wide or availability impact
+var highRiskKinds = map[string]string{
+	"CustomResourceDefinition": "CRD",
+	"ClusterRole":              "RBAC",
+	"ClusterRoleBinding":       "RBAC",
+	"Role":                     "RBAC",
+	"RoleBinding":              "RBAC",
+	"PodDisruptionBudget":      "PodDisruptionBudget",
+	"Namespace":                "Namespace",
+}
+
+// RiskReason returns why the change of this object should get extra scrutiny from reviewers, or an empty string if it's not considered risky
+func (d DiffElement) RiskReason() string {
+	reason, highRiskKind := highRiskKinds[d.ObjectKind]
+	switch {
+	case d.ChangeType == ObjectDeleted && highRiskKind:
+		return reason + " deletion"
+	case d.ChangeType == ObjectDeleted:
+		return "deletion"
+	case highRiskKind:
+		return reason + " change"
+	}
+	return ""
 }
 
 // DiffResult struct to store diff result
@@ -63,9 +113,37 @@ type DiffResult struct {
 	ArgoCdAppAutoSyncEnabled bool
 }
 
+// DiffSettings holds the (optional) repo level diff normalization settings, these are applied on top of the app's own ignoreDifferences and the ArgoCD resource overrides
+type DiffSettings struct {
+	IgnoreDifferences     []argoappv1.ResourceIgnoreDifferences
+	IgnoreAggregatedRoles bool
+	// ServerSideDiff predicts the live state like a server-side apply by the ArgoCD controller, based on the live objects managedFields.
+	ServerSideDiff bool
+	TempApp        TempAppSettings
+	Normalization  NormalizationSettings
+	// How many components are diffed at once and how long a single component diff can take, zero means the default
+	Concurrency      int
+	ComponentTimeout time.Duration
+}
+
+// TempAppSettings overrides fields of the temporary app objects created for new components, empty fields keep the ApplicationSet template values
+type TempAppSettings struct {
+	Project   string
+	Namespace string
+	Labels    map[string]string
+}
+
+// appUsesServerSideDiff checks if the app opted in to ArgoCD server-side diff or server-side apply via the compare/sync options
+func appUsesServerSideDiff(app *argoappv1.Application) bool {
+	if strings.Contains(app.Annotations["argocd.argoproj.io/compare-options"], "ServerSideDiff=true") {
+		return true
+	}
+	return app.Spec.SyncPolicy != nil && app.Spec.SyncPolicy.SyncOptions.HasOption("ServerSideApply=true")
+}
+
 // Mostly copied from  https://github.com/argoproj/argo-cd/blob/4f6a8dce80f0accef7ed3b5510e178a6b398b331/cmd/argocd/commands/app.go#L1255C6-L1338
 // But instead of printing the diff to stdout, we return it as a string in a struct so we can format it in a nice PR comment.
-func generateArgocdAppDiff(ctx context.Context, keepDiffData bool, app *argoappv1.Application, proj *argoappv1.AppProject, resources *application.ManagedResourcesResponse, argoSettings *settings.Settings, diffOptions *DifferenceOption) (foundDiffs bool, diffElements []DiffElement, err error) {
+func generateArgocdAppDiff(ctx context.Context, keepDiffData bool, app *argoappv1.Application, proj *argoappv1.AppProject, resources *application.ManagedResourcesResponse, argoSettings *settings.Settings, diffOptions *DifferenceOption, diffSettings DiffSettings) (foundDiffs bool, diffElements []DiffElement, err error) {
 	liveObjs, err := cmdutil.LiveObjects(resources.Items)
 	if err != nil {
 		return false, nil, fmt.Errorf("Failed to get live objects: %w", err)
@@ -100,14 +178,18 @@ func generateArgocdAppDiff(ctx context.Context, keepDiffData bool, app *argoappv
 			overrides[k] = *val
 		}
 
-		ignoreAggregatedRoles := false
+		ignoreDifferences := append(append([]argoappv1.ResourceIgnoreDifferences{}, app.Spec.IgnoreDifferences...), diffSettings.IgnoreDifferences...)
 		ignoreNormalizerOpts := normalizers.IgnoreNormalizerOpts{}
-		diffConfig, err := argodiff.NewDiffConfigBuilder().
-			WithDiffSettings(app.Spec.IgnoreDifferences, overrides, ignoreAggregatedRoles, ignoreNormalizerOpts).
+		diffConfigBuilder := argodiff.NewDiffConfigBuilder().
+			WithDiffSettings(ignoreDifferences, overrides, diffSettings.IgnoreAggregatedRoles, ignoreNormalizerOpts).
 			WithTracking(argoSettings.AppLabelKey, argoSettings.TrackingMethod).
 			WithNoCache().
-			WithStructuredMergeDiff(true).
-			Build()
+			WithStructuredMergeDiff(true)
+		if diffSettings.ServerSideDiff || appUsesServerSideDiff(app) {
+			// A real server-side dry-run requires cluster access, so we let structured merge diff apply the manifests as the ArgoCD controller field manager
+			diffConfigBuilder = diffConfigBuilder.WithManager(argocdcommon.ArgoCDSSAManager)
+		}
+		diffConfig, err := diffConfigBuilder.Build()
 		if err != nil {
 			return false, nil, fmt.Errorf("Failed to build diff config: %w", err)
 		}
@@ -121,16 +203,38 @@ func generateArgocdAppDiff(ctx context.Context, keepDiffData bool, app *argoappv
 			diffElement.ObjectKind = item.key.Kind
 			diffElement.ObjectNamespace = item.key.Namespace
 			diffElement.ObjectName = item.key.Name
+			switch {
+			case item.live == nil:
+				diffElement.ChangeType = ObjectAdded
+			case item.target == nil:
+				diffElement.ChangeType = ObjectDeleted
+			default:
+				diffElement.ChangeType = ObjectChanged
+			}
 
 			var live *unstructured.Unstructured
 			var target *unstructured.Unstructured
 			if item.target != nil && item.live != nil {
+				// Both sides are normalized(ignoreDifferences, known types...) so the rendered diff only shows what ArgoCD would actually change
 				target = &unstructured.Unstructured{}
-				live = item.live
+				live = &unstructured.Unstructured{}
 				err = json.Unmarshal(diffRes.PredictedLive, target)
 				if err != nil {
 					return false, nil, fmt.Errorf("Failed to unmarshal predicted live object: %w", err)
 				}
+				err = json.Unmarshal(diffRes.NormalizedLive, live)
+				if err != nil {
+					return false, nil, fmt.Errorf("Failed to unmarshal normalized live object: %w", err)
+				}
+				// managedFields are bookkeeping of the API server and ArgoCD never syncs them
+				unstructured.RemoveNestedField(live.Object, "metadata", "managedFields")
+				unstructured.RemoveNestedField(target.Object, "metadata", "managedFields")
+				if normalizedEqual(live, target, diffSettings.Normalization) {
+					log.Debugf("Diff of %s/%s/%s is only normalized noise, skipping it", item.key.Namespace, item.key.Kind, item.key.Name)
+					continue
+				}
+				live = normalizeObject(live, diffSettings.Normalization)
+				target = normalizeObject(target, diffSettings.Normalization)
 			} else {
 				live = item.live
 				target = item.target
@@ -141,6 +245,9 @@ func generateArgocdAppDiff(ctx context.Context, keepDiffData bool, app *argoappv
 
 			if keepDiffData {
 				diffElement.Diff, err = diffLiveVsTargetObject(live, target)
+				diffElement.ImageChanges = diffImages(live, target)
+				diffElement.LiveRequests = podRequests(live)
+				diffElement.TargetRequests = podRequests(target)
 			} else {
 				diffElement.Diff = "✂️ ✂️  Redacted ✂️ ✂️ \nUnset component-level configuration key `disableArgoCDDiff` to see diff content."
 			}
@@ -228,13 +335,14 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
-func CreateArgoCdClients() (ac argoCdClients, err error) {
-	plaintext, _ := strconv.ParseBool(getEnv("ARGOCD_PLAINTEXT", "false"))
-	insecure, _ := strconv.ParseBool(getEnv("ARGOCD_INSECURE", "false"))
+// CreateArgoCdClients connects to the ArgoCD instance of the tenant in ctx, or the one configured by the server env vars
+func CreateArgoCdClients(ctx context.Context) (ac argoCdClients, err error) {
+	plaintext, _ := strconv.ParseBool(tenancy.Getenv(ctx, "ARGOCD_PLAINTEXT", "false"))
+	insecure, _ := strconv.ParseBool(tenancy.Getenv(ctx, "ARGOCD_INSECURE", "false"))
 
 	opts := &apiclient.ClientOptions{
-		ServerAddr: getEnv("ARGOCD_SERVER_ADDR", "localhost:8080"),
-		AuthToken:  getEnv("ARGOCD_TOKEN", ""),
+		ServerAddr: tenancy.Getenv(ctx, "ARGOCD_SERVER_ADDR", "localhost:8080"),
+		AuthToken:  tenancy.Getenv(ctx, "ARGOCD_TOKEN", ""),
 		PlainText:  plaintext,
 		Insecure:   insecure,
 	}
@@ -267,6 +375,42 @@ func CreateArgoCdClients() (ac argoCdClients, err error) {
 	return
 }
 
+// gitGeneratorsOf returns the git generators of an ApplicationSet generator, including the ones nested in matrix and merge generators(up to the two levels ArgoCD supports)
+func gitGeneratorsOf(generator argoappv1.ApplicationSetGenerator) []*argoappv1.GitGenerator {
+	gitGenerators := []*argoappv1.GitGenerator{}
+	if generator.Git != nil {
+		gitGenerators = append(gitGenerators, generator.Git)
+	}
+	var nestedGenerators []argoappv1.ApplicationSetNestedGenerator
+	if generator.Matrix != nil {
+		nestedGenerators = append(nestedGenerators, generator.Matrix.Generators...)
+	}
+	if generator.Merge != nil {
+		nestedGenerators = append(nestedGenerators, generator.Merge.Generators...)
+	}
+	for _, nested := range nestedGenerators {
+		if nested.Git != nil {
+			gitGenerators = append(gitGenerators, nested.Git)
+		}
+		var terminalGenerators []argoappv1.ApplicationSetTerminalGenerator
+		if nestedMatrix, err := argoappv1.ToNestedMatrixGenerator(nested.Matrix); err == nil && nestedMatrix != nil {
+			terminalGenerators = append(terminalGenerators, nestedMatrix.Generators...)
+		}
+		if nestedMerge, err := argoappv1.ToNestedMergeGenerator(nested.Merge); err == nil && nestedMerge != nil {
+			terminalGenerators = append(terminalGenerators, nestedMerge.Generators...)
+		}
+		for _, terminal := range terminalGenerators {
+			if terminal.Git != nil {
+				gitGenerators = append(gitGenerators, terminal.Git)
+			}
+		}
+	}
+	return gitGenerators
+}
+
+// errNoAppSetFound is returned by findRelevantAppSetByPath when no ApplicationSet generates the component path
+var errNoAppSetFound = errors.New("No ArgoCD ApplicationSet found")
+
 // This function will search for an ApplicationSet by the componentPath and repo name by comparing the componentPath with the ApplicationSet's spec.generators.[]git.directories
 func findRelevantAppSetByPath(ctx context.Context, componentPath string, repo string, appSetClient applicationsetpkg.ApplicationSetServiceClient) (appSet *argoappv1.ApplicationSet, err error) {
 	appSetQuery := applicationsetpkg.ApplicationSetListQuery{}
@@ -278,8 +422,11 @@ func findRelevantAppSetByPath(ctx context.Context, componentPath string, repo st
 	for _, appSet := range foundAppSets.Items {
 		for _, generator := range appSet.Spec.Generators {
 			log.Debugf("Checking ApplicationSet %s for component path %s(repo %s)", appSet.Name, componentPath, repo)
-			if generator.Git != nil && generator.Git.RepoURL == repo {
-				for _, dir := range generator.Git.Directories {
+			for _, gitGenerator := range gitGeneratorsOf(generator) {
+				if gitGenerator.RepoURL != repo {
+					continue
+				}
+				for _, dir := range gitGenerator.Directories {
 					match, _ := path.Match(dir.Path, componentPath)
 					if match {
 						log.Debugf("Found ArgoCD ApplicationSet %s for component path %s(repo %s)", appSet.Name, componentPath, repo)
@@ -312,7 +459,7 @@ func findRelevantAppSetByPath(ctx context.Context, componentPath string, repo st
 			}
 		}
 	}
-	return nil, fmt.Errorf("No ArgoCD ApplicationSet found for component path %s(repo %s)", componentPath, repo)
+	return nil, fmt.Errorf("%w for component path %s(repo %s)", errNoAppSetFound, componentPath, repo)
 }
 
 // findArgocdAppBySHA1Label finds an ArgoCD application by the SHA1 label of the component path it's supposed to avoid performance issues with the "manifest-generate-paths" annotation method which requires pulling all ArgoCD applications(!) on every PR event.
@@ -386,33 +533,144 @@ func findArgocdAppByManifestPathAnnotation(ctx context.Context, componentPath st
 	return nil, nil
 }
 
-func findArgocdApp(ctx context.Context, componentPath string, repo string, appClient application.ApplicationServiceClient, useSHALabelForArgoDicovery bool) (app *argoappv1.Application, err error) {
+// isAppOwnedByAppSet checks the ownerReferences the ApplicationSet controller sets on the applications it generates
+func isAppOwnedByAppSet(app argoappv1.Application, appSetName string) bool {
+	for _, owner := range app.OwnerReferences {
+		if owner.Kind == "ApplicationSet" && owner.Name == appSetName {
+			return true
+		}
+	}
+	return false
+}
+
+// appSourcePaths returns the source paths of single and multi source applications
+func appSourcePaths(app argoappv1.Application) []string {
+	if app.Spec.HasMultipleSources() {
+		paths := []string{}
+		for _, source := range app.Spec.Sources {
+			paths = append(paths, source.Path)
+		}
+		return paths
+	}
+	if app.Spec.Source == nil {
+		return nil
+	}
+	return []string{app.Spec.Source.Path}
+}
+
+// findAppSetGeneratedApp picks the application generated by appSetName for componentPath, apps are expected to be pre-filtered by repo
+func findAppSetGeneratedApp(apps []argoappv1.Application, appSetName string, componentPath string) *argoappv1.Application {
+	for i := range apps {
+		if !isAppOwnedByAppSet(apps[i], appSetName) {
+			continue
+		}
+		for _, sourcePath := range appSourcePaths(apps[i]) {
+			if path.Clean(sourcePath) == path.Clean(componentPath) {
+				return &apps[i]
+			}
+		}
+	}
+	return nil
+}
+
+// findArgocdAppByAppSet is the fallback discovery method for applications generated by ApplicationSets(including matrix/merge generators) that don't carry
+// the SHA1 label or manifest-generate-paths annotation, it finds the relevant ApplicationSet by its git generators and then the application it generated for the component path.
+func findArgocdAppByAppSet(ctx context.Context, componentPath string, repo string, ac argoCdClients) (app *argoappv1.Application, err error) {
+	appSet, err := findRelevantAppSetByPath(ctx, componentPath, repo, ac.appSet)
+	if errors.Is(err, errNoAppSetFound) {
+		log.Debugf("No ApplicationSet based application found for %s: %v", componentPath, err)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	appQuery := application.ApplicationQuery{
+		Repo: &repo,
+	}
+	allRepoApps, err := ac.app.List(ctx, &appQuery)
+	if err != nil {
+		return nil, fmt.Errorf("Error listing ArgoCD applications: %w", err)
+	}
+	app = findAppSetGeneratedApp(allRepoApps.Items, appSet.Name, componentPath)
+	if app == nil {
+		log.Infof("ApplicationSet %s matches %s but no application was generated for it(yet)", appSet.Name, componentPath)
+	} else {
+		log.Debugf("Found app %s generated by ApplicationSet %s for %s", app.Name, appSet.Name, componentPath)
+	}
+	return app, nil
+}
+
+func findArgocdApp(ctx context.Context, componentPath string, repo string, ac argoCdClients, useSHALabelForArgoDicovery bool) (app *argoappv1.Application, err error) {
 	f := findArgocdAppByManifestPathAnnotation
 	if useSHALabelForArgoDicovery {
 		f = findArgocdAppBySHA1Label
 	}
-	return f(ctx, componentPath, repo, appClient)
+	app, err = f(ctx, componentPath, repo, ac.app)
+	if err != nil || app != nil {
+		return app, err
+	}
+	return findArgocdAppByAppSet(ctx, componentPath, repo, ac)
 }
 
 func SetArgoCDAppRevision(ctx context.Context, componentPath string, revision string, repo string, useSHALabelForArgoDicovery bool) error {
-	var foundApp *argoappv1.Application
-	var err error
-	ac, err := CreateArgoCdClients()
-	if err != nil {
-		return fmt.Errorf("Error creating ArgoCD clients: %w", err)
+	if maintenance.Skip("argocd_set_revision") {
+		return maintenance.ErrEnabled
 	}
-	foundApp, err = findArgocdApp(ctx, componentPath, repo, ac.app, useSHALabelForArgoDicovery)
+	ac, foundApp, err := findComponentApp(ctx, componentPath, repo, useSHALabelForArgoDicovery)
 	if err != nil {
-		return fmt.Errorf("error finding ArgoCD application for component path %s: %w", componentPath, err)
-	}
-	if foundApp == nil {
-		return fmt.Errorf("no ArgoCD application was found for component path: %s", componentPath)
+		return err
 	}
 	if foundApp.Spec.Source.TargetRevision == revision {
 		log.Infof("App %s already has revision %s", foundApp.Name, revision)
 		return nil
 	}
+	err = patchAppRevision(ctx, ac.app, foundApp, revision)
+	instrumentBranchSync(componentPath, "set", err)
+	return err
+}
+
+func instrumentBranchSync(componentPath string, operation string, err error) {
+	status := "success"
+	if err != nil {
+		status = "failure"
+	}
+	prom.InstrumentArgocdBranchSync(componentPath, operation, status)
+}
+
+// RevertArgoCDAppRevision sets the app revision back to revision, but only if the app currently points at fromRevision(e.g. the branch of a closed PR)
+func RevertArgoCDAppRevision(ctx context.Context, componentPath string, fromRevision string, revision string, repo string, useSHALabelForArgoDicovery bool) (reverted bool, appName string, err error) {
+	if maintenance.Skip("argocd_revert_revision") {
+		return false, "", maintenance.ErrEnabled
+	}
+	ac, foundApp, err := findComponentApp(ctx, componentPath, repo, useSHALabelForArgoDicovery)
+	if err != nil {
+		return false, "", err
+	}
+	if foundApp.Spec.Source.TargetRevision != fromRevision {
+		log.Debugf("App %s revision is %s, not %s, nothing to revert", foundApp.Name, foundApp.Spec.Source.TargetRevision, fromRevision)
+		return false, foundApp.Name, nil
+	}
+	err = patchAppRevision(ctx, ac.app, foundApp, revision)
+	instrumentBranchSync(componentPath, "revert", err)
+	return err == nil, foundApp.Name, err
+}
+
+func findComponentApp(ctx context.Context, componentPath string, repo string, useSHALabelForArgoDicovery bool) (ac argoCdClients, foundApp *argoappv1.Application, err error) {
+	ac, err = CreateArgoCdClients(ctx)
+	if err != nil {
+		return ac, nil, fmt.Errorf("Error creating ArgoCD clients: %w", err)
+	}
+	foundApp, err = findArgocdApp(ctx, componentPath, repo, ac, useSHALabelForArgoDicovery)
+	if err != nil {
+		return ac, nil, fmt.Errorf("error finding ArgoCD application for component path %s: %w", componentPath, err)
+	}
+	if foundApp == nil {
+		return ac, nil, fmt.Errorf("no ArgoCD application was found for component path: %s", componentPath)
+	}
+	return ac, foundApp, nil
+}
 
+func patchAppRevision(ctx context.Context, appClient application.ApplicationServiceClient, foundApp *argoappv1.Application, revision string) error {
 	patchObject := struct {
 		Spec struct {
 			Source struct {
@@ -426,7 +684,7 @@ func SetArgoCDAppRevision(ctx context.Context, componentPath string, revision st
 	log.Debugf("Patching app %s/%s with: %s", foundApp.Namespace, foundApp.Name, patch)
 
 	patchType := "merge"
-	_, err = ac.app.Patch(ctx, &application.ApplicationPatchRequest{
+	_, err := appClient.Patch(ctx, &application.ApplicationPatchRequest{
 		Name:         &foundApp.Name,
 		AppNamespace: &foundApp.Namespace,
 		PatchType:    &patchType,
@@ -469,7 +727,7 @@ func generateAppSetGitGeneratorParams(p string) map[string]interface{} {
 	return params
 }
 
-func createTempAppObjectFroNewApp(ctx context.Context, componentPath string, repo string, prBranch string, ac argoCdClients) (app *argoappv1.Application, err error) {
+func createTempAppObjectFroNewApp(ctx context.Context, componentPath string, repo string, prBranch string, ac argoCdClients, tempAppSettings TempAppSettings) (app *argoappv1.Application, err error) {
 	log.Debug("Didn't find ArgoCD App, trying to find a relevant  ApplicationSet")
 	appSetOfcomponent, err := findRelevantAppSetByPath(ctx, componentPath, repo, ac.appSet)
 	if appSetOfcomponent != nil {
@@ -489,6 +747,7 @@ func createTempAppObjectFroNewApp(ctx context.Context, componentPath string, rep
 		// We need to remove the automated sync policy, we just want to create a temporary app object, run a diff and remove it.
 		newAppObject.Spec.SyncPolicy.Automated = nil
 		newAppObject.Spec.Source.TargetRevision = prBranch
+		applyTempAppSettings(ctx, newAppObject, tempAppSettings)
 
 		validateTempApp := false
 		appCreateRequest := application.ApplicationCreateRequest{
@@ -504,36 +763,38 @@ func createTempAppObjectFroNewApp(ctx context.Context, componentPath string, rep
 	}
 }
 
-func generateDiffOfAComponent(ctx context.Context, commentDiff bool, componentPath string, prBranch string, repo string, ac argoCdClients, argoSettings *settings.Settings, useSHALabelForArgoDicovery bool, createTempAppObjectFromNewApps bool) (componentDiffResult DiffResult) {
+func generateDiffOfAComponent(ctx context.Context, commentDiff bool, componentPath string, prBranch string, repo string, ac argoCdClients, argoSettings *settings.Settings, useSHALabelForArgoDicovery bool, createTempAppObjectFromNewApps bool, diffSettings DiffSettings) (componentDiffResult DiffResult) {
 	componentDiffResult.ComponentPath = componentPath
+	startTime := time.Now()
+	defer func() {
+		result := "no_diff"
+		if componentDiffResult.DiffError != nil {
+			result = "error"
+		} else if componentDiffResult.HasDiff {
+			result = "diff"
+		}
+		prom.InstrumentArgocdDiff(componentPath, componentDiffResult.ArgoCdAppName, result, time.Since(startTime))
+	}()
 
 	// Find ArgoCD application by the path SHA1 label selector and repo name
 	// At the moment we assume one to one mapping between Telefonistka components and ArgoCD application
 
-	var app *argoappv1.Application
-	var err error
-	if useSHALabelForArgoDicovery {
-		app, err = findArgocdAppBySHA1Label(ctx, componentPath, repo, ac.app)
-		if err != nil {
-			componentDiffResult.DiffError = err
-			return componentDiffResult
-		}
-	} else {
-		app, err = findArgocdAppByManifestPathAnnotation(ctx, componentPath, repo, ac.app)
-		if err != nil {
-			componentDiffResult.DiffError = err
-			return componentDiffResult
-		}
+	app, err := findArgocdApp(ctx, componentPath, repo, ac, useSHALabelForArgoDicovery)
+	if err != nil {
+		componentDiffResult.DiffError = err
+		return componentDiffResult
 	}
 	if app == nil {
 		if createTempAppObjectFromNewApps {
-			app, err = createTempAppObjectFroNewApp(ctx, componentPath, repo, prBranch, ac)
+			app, err = createTempAppObjectFroNewApp(ctx, componentPath, repo, prBranch, ac, diffSettings.TempApp)
 
 			if err != nil {
+				prom.InstrumentArgocdTempAppCreation(componentPath, "failure")
 				log.Errorf("Error creating temporary app object: %v", err)
 				componentDiffResult.DiffError = err
 				return componentDiffResult
 			} else {
+				prom.InstrumentArgocdTempAppCreation(componentPath, "success")
 				log.Debugf("Created temporary app object: %s", app.Name)
 				componentDiffResult.AppWasTemporarilyCreated = true
 			}
@@ -605,7 +866,7 @@ func generateDiffOfAComponent(ctx context.Context, commentDiff bool, componentPa
 	}
 
 	log.Debugf("Generating diff for component %s", componentPath)
-	componentDiffResult.HasDiff, componentDiffResult.DiffElements, componentDiffResult.DiffError = generateArgocdAppDiff(ctx, commentDiff, app, detailedProject.Project, resources, argoSettings, diffOption)
+	componentDiffResult.HasDiff, componentDiffResult.DiffElements, componentDiffResult.DiffError = generateArgocdAppDiff(ctx, commentDiff, app, detailedProject.Project, resources, argoSettings, diffOption, diffSettings)
 
 	// only delete the temprorary app object if it was created and there was no error on diff
 	// otherwise let's keep it for investigation
@@ -624,34 +885,77 @@ func generateDiffOfAComponent(ctx context.Context, commentDiff bool, componentPa
 }
 
 // GenerateDiffOfChangedComponents generates diff of changed components
-func GenerateDiffOfChangedComponents(ctx context.Context, componentsToDiff map[string]bool, prBranch string, repo string, useSHALabelForArgoDicovery bool, createTempAppObjectFromNewApps bool, argoClients argoCdClients) (hasComponentDiff bool, hasComponentDiffErrors bool, diffResults []DiffResult, err error) {
+func GenerateDiffOfChangedComponents(ctx context.Context, componentsToDiff map[string]bool, prBranch string, repo string, useSHALabelForArgoDicovery bool, createTempAppObjectFromNewApps bool, diffSettings DiffSettings, argoClients argoCdClients) (hasComponentDiff bool, hasComponentDiffErrors bool, diffResults []DiffResult, err error) {
 	hasComponentDiff = false
 	hasComponentDiffErrors = false
 
+	breaker := endpointCircuitBreaker(ctx)
+	err = breaker.allow()
+	if err != nil {
+		return false, true, nil, err
+	}
 	argoSettings, err := argoClients.setting.Get(ctx, &settings.SettingsQuery{})
+	breaker.record(err)
 	if err != nil {
 		log.Errorf("error getting ArgoCD settings: %v", err)
 		return false, true, nil, err
 	}
 
-	diffResult := make(chan DiffResult)
-	for componentPath, shouldIDiff := range componentsToDiff {
+	concurrency := diffSettings.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultDiffConcurrency
+	}
+	componentTimeout := diffSettings.ComponentTimeout
+	if componentTimeout <= 0 {
+		componentTimeout = defaultComponentDiffTimeout
+	}
+	workers := make(chan struct{}, concurrency)
+	diffResult := make(chan DiffResult, len(componentsToDiff))
+	for componentPath, shouldDiff := range componentsToDiff {
 		go func(componentPath string, shouldDiff bool) {
-			diffResult <- generateDiffOfAComponent(ctx, shouldIDiff, componentPath, prBranch, repo, argoClients, argoSettings, useSHALabelForArgoDicovery, createTempAppObjectFromNewApps)
-		}(componentPath, shouldIDiff)
+			workers <- struct{}{}
+			defer func() { <-workers }()
+			// The breaker can open while this component waits for a worker
+			if err := breaker.allow(); err != nil {
+				diffResult <- DiffResult{ComponentPath: componentPath, DiffError: err}
+				return
+			}
+			result := generateDiffOfAComponentWithTimeout(ctx, componentTimeout, componentPath, func(componentCtx context.Context) DiffResult {
+				return generateDiffOfAComponent(componentCtx, shouldDiff, componentPath, prBranch, repo, argoClients, argoSettings, useSHALabelForArgoDicovery, createTempAppObjectFromNewApps, diffSettings)
+			})
+			breaker.record(result.DiffError)
+			diffResult <- result
+		}(componentPath, shouldDiff)
 	}
 
+	// A failed component is reported in its DiffResult, the other components diffs are still returned
 	for range componentsToDiff {
 		currentDiffResult := <-diffResult
 		if currentDiffResult.DiffError != nil {
 			log.Errorf("Error generating diff for component %s: %v", currentDiffResult.ComponentPath, currentDiffResult.DiffError)
 			hasComponentDiffErrors = true
-			err = currentDiffResult.DiffError
 		}
 		if currentDiffResult.HasDiff {
 			hasComponentDiff = true
 		}
 		diffResults = append(diffResults, currentDiffResult)
 	}
-	return hasComponentDiff, hasComponentDiffErrors, diffResults, err
+	sort.Slice(diffResults, func(i, j int) bool { return diffResults[i].ComponentPath < diffResults[j].ComponentPath })
+	return hasComponentDiff, hasComponentDiffErrors, diffResults, nil
+}
+
+// generateDiffOfAComponentWithTimeout runs generateDiff with a deadline, a diff that doesn't return in time(e.g. a hung ArgoCD call that ignores the context) is reported as failed
+func generateDiffOfAComponentWithTimeout(ctx context.Context, timeout time.Duration, componentPath string, generateDiff func(ctx context.Context) DiffResult) DiffResult {
+	componentCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	result := make(chan DiffResult, 1)
+	go func() {
+		result <- generateDiff(componentCtx)
+	}()
+	select {
+	case r := <-result:
+		return r
+	case <-componentCtx.Done():
+		return DiffResult{ComponentPath: componentPath, DiffError: fmt.Errorf("diff of %s didn't finish in %v: %w", componentPath, timeout, componentCtx.Err())}
+	}
 }
@@ -3,6 +3,7 @@ package argocd
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/argoproj/argo-cd/v2/pkg/apiclient"
 	"github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
+	applicationsetpkg "github.com/argoproj/argo-cd/v2/pkg/apiclient/applicationset"
 	"github.com/argoproj/argo-cd/v2/pkg/apiclient/project"
 	"github.com/argoproj/argo-cd/v2/pkg/apiclient/settings"
 	argoappv1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
@@ -21,6 +23,7 @@ import (
 	"github.com/wayfair-incubator/telefonistka/internal/pkg/mocks"
 	"github.com/wayfair-incubator/telefonistka/internal/pkg/testutils"
 	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
@@ -462,6 +465,7 @@ func TestFetchArgoDiffConcurrently(t *testing.T) {
 		"test-repo",
 		true,
 		false,
+		DiffSettings{},
 		argoClients,
 	)
 
@@ -471,3 +475,265 @@ func TestFetchArgoDiffConcurrently(t *testing.T) {
 	// assert that the entire run takes less than numComponents * 1 second
 	assert.Less(t, elapsed, time.Duration(numComponents)*time.Second)
 }
+
+func TestGitGeneratorsOf(t *testing.T) {
+	t.Parallel()
+	plainGit := &argoappv1.GitGenerator{RepoURL: "repo-a"}
+	matrixGit := &argoappv1.GitGenerator{RepoURL: "repo-b"}
+	mergeGit := &argoappv1.GitGenerator{RepoURL: "repo-c"}
+	generator := argoappv1.ApplicationSetGenerator{
+		Git: plainGit,
+		Matrix: &argoappv1.MatrixGenerator{
+			Generators: []argoappv1.ApplicationSetNestedGenerator{
+				{Git: matrixGit},
+				{Clusters: &argoappv1.ClusterGenerator{}},
+			},
+		},
+		Merge: &argoappv1.MergeGenerator{
+			Generators: []argoappv1.ApplicationSetNestedGenerator{
+				{Git: mergeGit},
+			},
+		},
+	}
+
+	assert.Equal(t, []*argoappv1.GitGenerator{plainGit, matrixGit, mergeGit}, gitGeneratorsOf(generator))
+	assert.Empty(t, gitGeneratorsOf(argoappv1.ApplicationSetGenerator{List: &argoappv1.ListGenerator{}}))
+}
+
+// fakeAppSetClient lists appSets, or fails with err
+type fakeAppSetClient struct {
+	applicationsetpkg.ApplicationSetServiceClient
+	appSets []argoappv1.ApplicationSet
+	err     error
+}
+
+func (c fakeAppSetClient) List(_ context.Context, _ *applicationsetpkg.ApplicationSetListQuery, _ ...grpc.CallOption) (*argoappv1.ApplicationSetList, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &argoappv1.ApplicationSetList{Items: c.appSets}, nil
+}
+
+func TestFindArgocdAppByAppSetErrors(t *testing.T) {
+	t.Parallel()
+	listErr := errors.New("connection refused")
+	app, err := findArgocdAppByAppSet(context.Background(), "env/prod/c1/component", "some-repo", argoCdClients{appSet: fakeAppSetClient{err: listErr}})
+	assert.ErrorIs(t, err, listErr, "failing to list the ApplicationSets isn't the same as not finding one")
+	assert.Nil(t, app)
+
+	app, err = findArgocdAppByAppSet(context.Background(), "env/prod/c1/component", "some-repo", argoCdClients{appSet: fakeAppSetClient{}})
+	assert.NoError(t, err)
+	assert.Nil(t, app)
+}
+
+func TestFindAppSetGeneratedApp(t *testing.T) {
+	t.Parallel()
+	ownedBy := func(appSetName string) []metav1.OwnerReference {
+		return []metav1.OwnerReference{{Kind: "ApplicationSet", Name: appSetName}}
+	}
+	apps := []argoappv1.Application{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "other-appset-app", OwnerReferences: ownedBy("other-appset")},
+			Spec:       argoappv1.ApplicationSpec{Source: &argoappv1.ApplicationSource{Path: "env/prod/c1/component"}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "wrong-path-app", OwnerReferences: ownedBy("my-appset")},
+			Spec:       argoappv1.ApplicationSpec{Source: &argoappv1.ApplicationSource{Path: "env/prod/c2/component"}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "right-app", OwnerReferences: ownedBy("my-appset")},
+			Spec: argoappv1.ApplicationSpec{Sources: argoappv1.ApplicationSources{
+				{RepoURL: "some-helm-repo", Chart: "chart"},
+				{Path: "env/prod/c1/component/"},
+			}},
+		},
+	}
+
+	app := findAppSetGeneratedApp(apps, "my-appset", "env/prod/c1/component")
+	if assert.NotNil(t, app) {
+		assert.Equal(t, "right-app", app.Name)
+	}
+	assert.Nil(t, findAppSetGeneratedApp(apps, "my-appset", "env/staging/c1/component"))
+}
+
+func TestAppUsesServerSideDiff(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		app      *argoappv1.Application
+		expected bool
+	}{
+		"Plain app": {
+			app:      &argoappv1.Application{},
+			expected: false,
+		},
+		"Compare option annotation": {
+			app: &argoappv1.Application{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+				"argocd.argoproj.io/compare-options": "IncludeMutationWebhook=true,ServerSideDiff=true",
+			}}},
+			expected: true,
+		},
+		"Server-side apply sync option": {
+			app: &argoappv1.Application{Spec: argoappv1.ApplicationSpec{SyncPolicy: &argoappv1.SyncPolicy{
+				SyncOptions: argoappv1.SyncOptions{"CreateNamespace=true", "ServerSideApply=true"},
+			}}},
+			expected: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, appUsesServerSideDiff(tc.app))
+		})
+	}
+}
+
+func deploymentWithImages(images map[string]string) *unstructured.Unstructured {
+	containers := []interface{}{}
+	for name, image := range images {
+		containers = append(containers, map[string]interface{}{"name": name, "image": image})
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"kind": "Deployment",
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{"containers": containers},
+			},
+		},
+	}}
+}
+
+func TestDiffImages(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		live     *unstructured.Unstructured
+		target   *unstructured.Unstructured
+		expected []ImageChange
+	}{
+		"Tag bump": {
+			live:     deploymentWithImages(map[string]string{"app": "registry:5000/app:1.0", "sidecar": "envoy:1.2"}),
+			target:   deploymentWithImages(map[string]string{"app": "registry:5000/app:1.1", "sidecar": "envoy:1.2"}),
+			expected: []ImageChange{{Container: "app", OldImage: "registry:5000/app:1.0", NewImage: "registry:5000/app:1.1"}},
+		},
+		"New object": {
+			live:     nil,
+			target:   deploymentWithImages(map[string]string{"app": "app:1.0"}),
+			expected: []ImageChange{{Container: "app", NewImage: "app:1.0"}},
+		},
+		"Removed container": {
+			live:     deploymentWithImages(map[string]string{"app": "app:1.0", "sidecar": "envoy:1.2"}),
+			target:   deploymentWithImages(map[string]string{"app": "app:1.0"}),
+			expected: []ImageChange{{Container: "sidecar", OldImage: "envoy:1.2"}},
+		},
+		"Object without containers": {
+			live:     &unstructured.Unstructured{Object: map[string]interface{}{"kind": "ConfigMap"}},
+			target:   &unstructured.Unstructured{Object: map[string]interface{}{"kind": "ConfigMap"}},
+			expected: []ImageChange{},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, diffImages(tc.live, tc.target))
+		})
+	}
+}
+
+func TestSplitImage(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		image              string
+		expectedRepository string
+		expectedTag        string
+	}{
+		"Simple":        {image: "nginx:1.25", expectedRepository: "nginx", expectedTag: "1.25"},
+		"Registry port": {image: "registry:5000/team/app:v2", expectedRepository: "registry:5000/team/app", expectedTag: "v2"},
+		"No tag":        {image: "registry:5000/team/app", expectedRepository: "registry:5000/team/app", expectedTag: ""},
+		"Digest":        {image: "app@sha256:abcd", expectedRepository: "app", expectedTag: "sha256:abcd"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			repository, tag := SplitImage(tc.image)
+			assert.Equal(t, tc.expectedRepository, repository)
+			assert.Equal(t, tc.expectedTag, tag)
+		})
+	}
+}
+
+func TestDiffElementRiskReason(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		diffElement DiffElement
+		expected    string
+	}{
+		"Changed Deployment":  {diffElement: DiffElement{ObjectKind: "Deployment", ChangeType: ObjectChanged}, expected: ""},
+		"Deleted Deployment":  {diffElement: DiffElement{ObjectKind: "Deployment", ChangeType: ObjectDeleted}, expected: "deletion"},
+		"Added CRD":           {diffElement: DiffElement{ObjectKind: "CustomResourceDefinition", ChangeType: ObjectAdded}, expected: "CRD change"},
+		"Deleted Namespace":   {diffElement: DiffElement{ObjectKind: "Namespace", ChangeType: ObjectDeleted}, expected: "Namespace deletion"},
+		"Changed ClusterRole": {diffElement: DiffElement{ObjectKind: "ClusterRole", ChangeType: ObjectChanged}, expected: "RBAC change"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, tc.diffElement.RiskReason())
+		})
+	}
+}
+
+func TestPodRequests(t *testing.T) {
+	t.Parallel()
+	deployment := &unstructured.Unstructured{Object: map[string]interface{}{
+		"kind": "Deployment",
+		"spec": map[string]interface{}{
+			"replicas": int64(3),
+			"template": map[string]interface{}{"spec": map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{"name": "app", "resources": map[string]interface{}{"requests": map[string]interface{}{"cpu": "500m", "memory": "1Gi"}}},
+					map[string]interface{}{"name": "sidecar", "resources": map[string]interface{}{"requests": map[string]interface{}{"cpu": int64(1)}}},
+				},
+			}},
+		},
+	}}
+	pod := &unstructured.Unstructured{Object: map[string]interface{}{
+		"kind": "Pod",
+		"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{"name": "app", "resources": map[string]interface{}{"requests": map[string]interface{}{"memory": "512Mi"}}},
+			},
+		},
+	}}
+	tests := map[string]struct {
+		obj      *unstructured.Unstructured
+		expected ResourceRequests
+	}{
+		"Deployment replicas": {obj: deployment, expected: ResourceRequests{CPUMilli: 4500, MemoryBytes: 3 << 30}},
+		"Pod":                 {obj: pod, expected: ResourceRequests{MemoryBytes: 512 << 20}},
+		"Deleted object":      {obj: nil, expected: ResourceRequests{}},
+		"ConfigMap":           {obj: &unstructured.Unstructured{Object: map[string]interface{}{"kind": "ConfigMap"}}, expected: ResourceRequests{}},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, podRequests(tc.obj))
+		})
+	}
+}
+
+func TestGenerateDiffOfAComponentWithTimeout(t *testing.T) {
+	t.Parallel()
+	result := generateDiffOfAComponentWithTimeout(context.Background(), time.Second, "env/prod/c1", func(ctx context.Context) DiffResult {
+		return DiffResult{ComponentPath: "env/prod/c1", HasDiff: true}
+	})
+	assert.NoError(t, result.DiffError)
+	assert.True(t, result.HasDiff)
+
+	hung := make(chan struct{})
+	defer close(hung)
+	result = generateDiffOfAComponentWithTimeout(context.Background(), 10*time.Millisecond, "env/prod/c2", func(ctx context.Context) DiffResult {
+		<-hung
+		return DiffResult{ComponentPath: "env/prod/c2"}
+	})
+	assert.Equal(t, "env/prod/c2", result.ComponentPath)
+	assert.ErrorIs(t, result.DiffError, context.DeadlineExceeded)
+}
@@ -0,0 +1,105 @@
+package argocd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/tenancy"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrCircuitOpen is returned(wrapped) for diffs skipped because their ArgoCD endpoint is marked unhealthy
+var ErrCircuitOpen = errors.New("ArgoCD endpoint is marked unhealthy")
+
+// circuitBreaker marks an ArgoCD endpoint unhealthy after threshold consecutive failures, so the following diffs fail fast until cooldown passes
+type circuitBreaker struct {
+	mu                  sync.Mutex
+	threshold           int
+	cooldown            time.Duration
+	consecutiveFailures int
+	openUntil           time.Time
+	now                 func() time.Time
+}
+
+func (b *circuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.now().Before(b.openUntil) {
+		return fmt.Errorf("%w after %d consecutive failures, diffs are skipped until %s", ErrCircuitOpen, b.threshold, b.openUntil.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func (b *circuitBreaker) record(err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !isEndpointFailure(err) {
+		b.consecutiveFailures = 0
+		return
+	}
+	b.consecutiveFailures++
+	if b.consecutiveFailures >= b.threshold {
+		b.openUntil = b.now().Add(b.cooldown)
+		b.consecutiveFailures = 0
+		log.Warnf("ArgoCD endpoint failed %d times in a row, skipping its diffs until %s", b.threshold, b.openUntil.Format(time.RFC3339))
+	}
+}
+
+// isEndpointFailure tells failures of the ArgoCD endpoint itself(unreachable, timeouts) from component level errors like a missing app
+func isEndpointFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
+
+var (
+	circuitBreakersMu sync.Mutex
+	circuitBreakers   = map[string]*circuitBreaker{}
+)
+
+// endpointCircuitBreaker returns the(possibly nil, i.e. disabled) circuit breaker of the ArgoCD endpoint of the tenant in ctx,
+// configured by ARGOCD_CIRCUIT_BREAKER_THRESHOLD(default 5, 0 disables it) and ARGOCD_CIRCUIT_BREAKER_COOLDOWN_SECONDS(default 120)
+func endpointCircuitBreaker(ctx context.Context) *circuitBreaker {
+	threshold, err := strconv.Atoi(tenancy.Getenv(ctx, "ARGOCD_CIRCUIT_BREAKER_THRESHOLD", "5"))
+	if err != nil || threshold <= 0 {
+		return nil
+	}
+	cooldownSeconds, err := strconv.Atoi(tenancy.Getenv(ctx, "ARGOCD_CIRCUIT_BREAKER_COOLDOWN_SECONDS", "120"))
+	if err != nil {
+		cooldownSeconds = 120
+	}
+	endpoint := tenancy.Getenv(ctx, "ARGOCD_SERVER_ADDR", "localhost:8080")
+	circuitBreakersMu.Lock()
+	defer circuitBreakersMu.Unlock()
+	b, ok := circuitBreakers[endpoint]
+	if !ok {
+		b = &circuitBreaker{now: time.Now}
+		circuitBreakers[endpoint] = b
+	}
+	b.mu.Lock()
+	b.threshold = threshold
+	b.cooldown = time.Duration(cooldownSeconds) * time.Second
+	b.mu.Unlock()
+	return b
+}
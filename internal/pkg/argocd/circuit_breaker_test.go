@@ -0,0 +1,39 @@
+package argocd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := &circuitBreaker{threshold: 2, cooldown: time.Minute, now: func() time.Time { return now }}
+	unavailable := fmt.Errorf("get app: %w", status.Error(codes.Unavailable, "connection refused"))
+
+	b.record(unavailable)
+	b.record(errors.New("app not found")) // component errors reset the count
+	b.record(unavailable)
+	assert.NoError(t, b.allow())
+
+	b.record(unavailable)
+	assert.ErrorIs(t, b.allow(), ErrCircuitOpen)
+
+	now = now.Add(2 * time.Minute)
+	assert.NoError(t, b.allow())
+}
+
+func TestIsEndpointFailure(t *testing.T) {
+	t.Parallel()
+	assert.True(t, isEndpointFailure(fmt.Errorf("diff: %w", context.DeadlineExceeded)))
+	assert.True(t, isEndpointFailure(status.Error(codes.DeadlineExceeded, "timeout")))
+	assert.False(t, isEndpointFailure(status.Error(codes.NotFound, "app not found")))
+	assert.False(t, isEndpointFailure(nil))
+}
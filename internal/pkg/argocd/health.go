@@ -0,0 +1,25 @@
+package argocd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/settings"
+)
+
+// CheckArgoCDConnectivity verifies the configured ArgoCD API endpoint responds with the configured credentials
+func CheckArgoCDConnectivity(ctx context.Context) error {
+	ac, err := CreateArgoCdClients(ctx)
+	if err != nil {
+		return err
+	}
+	return checkArgoCDSettings(ctx, ac.setting)
+}
+
+func checkArgoCDSettings(ctx context.Context, settingClient settings.SettingsServiceClient) error {
+	_, err := settingClient.Get(ctx, &settings.SettingsQuery{})
+	if err != nil {
+		return fmt.Errorf("ArgoCD API check failed: %w", err)
+	}
+	return nil
+}
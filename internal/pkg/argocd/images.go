@@ -0,0 +1,86 @@
+package argocd
+
+import (
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ImageChange represents a container image change of a single container in a k8s object
+type ImageChange struct {
+	Container string
+	OldImage  string
+	NewImage  string
+}
+
+// podSpecPaths lists where the pod spec lives in the common workload kinds(Pod, Deployment/StatefulSet/DaemonSet/ReplicaSet/Job/Rollout and CronJob)
+var podSpecPaths = [][]string{
+	{"spec"},
+	{"spec", "template", "spec"},
+	{"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// containerImages returns a map of container name to image, init containers are prefixed with "init:"
+func containerImages(obj *unstructured.Unstructured) map[string]string {
+	images := map[string]string{}
+	if obj == nil {
+		return images
+	}
+	for _, podSpecPath := range podSpecPaths {
+		for _, containersKey := range []string{"initContainers", "containers"} {
+			containers, found, err := unstructured.NestedSlice(obj.Object, append(append([]string{}, podSpecPath...), containersKey)...)
+			if !found || err != nil {
+				continue
+			}
+			for _, c := range containers {
+				container, ok := c.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				name, _, _ := unstructured.NestedString(container, "name")
+				image, _, _ := unstructured.NestedString(container, "image")
+				if containersKey == "initContainers" {
+					name = "init:" + name
+				}
+				images[name] = image
+			}
+		}
+	}
+	return images
+}
+
+// diffImages compares the container images of the live and target objects, a nil live/target object means the object is created/deleted
+func diffImages(live *unstructured.Unstructured, target *unstructured.Unstructured) []ImageChange {
+	liveImages := containerImages(live)
+	targetImages := containerImages(target)
+
+	changes := []ImageChange{}
+	for container, newImage := range targetImages {
+		if oldImage := liveImages[container]; oldImage != newImage {
+			changes = append(changes, ImageChange{Container: container, OldImage: oldImage, NewImage: newImage})
+		}
+	}
+	for container, oldImage := range liveImages {
+		if _, found := targetImages[container]; !found {
+			changes = append(changes, ImageChange{Container: container, OldImage: oldImage})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Container < changes[j].Container
+	})
+	return changes
+}
+
+// SplitImage splits a container image reference to repository and tag(or digest), the tag is empty if the reference has neither.
+func SplitImage(image string) (repository string, tag string) {
+	if i := strings.Index(image, "@"); i != -1 {
+		return image[:i], image[i+1:]
+	}
+	// A colon before the last slash is a registry port, not a tag
+	lastSlash := strings.LastIndex(image, "/")
+	if i := strings.LastIndex(image, ":"); i > lastSlash {
+		return image[:i], image[i+1:]
+	}
+	return image, ""
+}
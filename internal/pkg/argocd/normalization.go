@@ -0,0 +1,86 @@
+package argocd
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// NormalizationSettings strip fields that change without an effect on the cluster from both sides of a diff, objects left identical aren't reported as changed
+type NormalizationSettings struct {
+	// metadata.generation, resourceVersion, uid and creationTimestamp
+	StripMetadataNoise bool
+	StripStatus        bool
+	// Lists are sorted, so reordering list items isn't a change
+	IgnoreListOrder bool
+}
+
+var metadataNoiseFields = []string{"generation", "resourceVersion", "uid", "creationTimestamp"}
+
+func (n NormalizationSettings) enabled() bool {
+	return n.StripMetadataNoise || n.StripStatus || n.IgnoreListOrder
+}
+
+// normalizeObject applies the normalization to a copy of obj
+func normalizeObject(obj *unstructured.Unstructured, settings NormalizationSettings) *unstructured.Unstructured {
+	if obj == nil || !settings.enabled() {
+		return obj
+	}
+	normalized := obj.DeepCopy()
+	if settings.StripMetadataNoise {
+		for _, field := range metadataNoiseFields {
+			unstructured.RemoveNestedField(normalized.Object, "metadata", field)
+		}
+	}
+	if settings.StripStatus {
+		unstructured.RemoveNestedField(normalized.Object, "status")
+	}
+	if settings.IgnoreListOrder {
+		normalized.Object = sortLists(normalized.Object).(map[string]interface{})
+	}
+	return normalized
+}
+
+// sortLists sorts every list nested in value by the JSON encoding of its items, which gives a stable order for lists of maps too
+func sortLists(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = sortLists(item)
+		}
+		return v
+	case []interface{}:
+		keys := make([]string, len(v))
+		for i, item := range v {
+			v[i] = sortLists(item)
+			encoded, _ := json.Marshal(v[i])
+			keys[i] = string(encoded)
+		}
+		sort.Sort(byKey{items: v, keys: keys})
+		return v
+	default:
+		return value
+	}
+}
+
+type byKey struct {
+	items []interface{}
+	keys  []string
+}
+
+func (b byKey) Len() int           { return len(b.items) }
+func (b byKey) Less(i, j int) bool { return b.keys[i] < b.keys[j] }
+func (b byKey) Swap(i, j int) {
+	b.items[i], b.items[j] = b.items[j], b.items[i]
+	b.keys[i], b.keys[j] = b.keys[j], b.keys[i]
+}
+
+// normalizedEqual reports whether live and target are the same once normalized, i.e. ArgoCD's diff is only noise
+func normalizedEqual(live *unstructured.Unstructured, target *unstructured.Unstructured, settings NormalizationSettings) bool {
+	if live == nil || target == nil || !settings.enabled() {
+		return false
+	}
+	return reflect.DeepEqual(normalizeObject(live, settings).Object, normalizeObject(target, settings).Object)
+}
@@ -0,0 +1,82 @@
+package argocd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func normalizationTestObject(resourceVersion string, ports []interface{}, status map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Service",
+		"metadata":   map[string]interface{}{"name": "svc", "resourceVersion": resourceVersion, "generation": int64(2)},
+		"spec":       map[string]interface{}{"ports": ports},
+		"status":     status,
+	}}
+}
+
+func TestNormalizedEqual(t *testing.T) {
+	t.Parallel()
+	http := map[string]interface{}{"name": "http", "port": int64(80)}
+	https := map[string]interface{}{"name": "https", "port": int64(443)}
+	tests := map[string]struct {
+		live     *unstructured.Unstructured
+		target   *unstructured.Unstructured
+		settings NormalizationSettings
+		expected bool
+	}{
+		"metadata noise": {
+			live:     normalizationTestObject("1", []interface{}{http}, nil),
+			target:   normalizationTestObject("2", []interface{}{http}, nil),
+			settings: NormalizationSettings{StripMetadataNoise: true},
+			expected: true,
+		},
+		"metadata noise not stripped": {
+			live:     normalizationTestObject("1", []interface{}{http}, nil),
+			target:   normalizationTestObject("2", []interface{}{http}, nil),
+			settings: NormalizationSettings{StripStatus: true},
+			expected: false,
+		},
+		"status": {
+			live:     normalizationTestObject("1", []interface{}{http}, map[string]interface{}{"loadBalancer": map[string]interface{}{}}),
+			target:   normalizationTestObject("1", []interface{}{http}, nil),
+			settings: NormalizationSettings{StripStatus: true},
+			expected: true,
+		},
+		"reordered list": {
+			live:     normalizationTestObject("1", []interface{}{http, https}, nil),
+			target:   normalizationTestObject("1", []interface{}{https, http}, nil),
+			settings: NormalizationSettings{IgnoreListOrder: true},
+			expected: true,
+		},
+		"real change": {
+			live:     normalizationTestObject("1", []interface{}{http}, nil),
+			target:   normalizationTestObject("2", []interface{}{https}, nil),
+			settings: NormalizationSettings{StripMetadataNoise: true, StripStatus: true, IgnoreListOrder: true},
+			expected: false,
+		},
+		"added object": {
+			target:   normalizationTestObject("1", []interface{}{http}, nil),
+			settings: NormalizationSettings{StripMetadataNoise: true},
+			expected: false,
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, normalizedEqual(tc.live, tc.target, tc.settings))
+		})
+	}
+}
+
+func TestNormalizeObjectDoesntModifyTheInput(t *testing.T) {
+	t.Parallel()
+	obj := normalizationTestObject("1", []interface{}{"b", "a"}, map[string]interface{}{"phase": "Active"})
+	normalized := normalizeObject(obj, NormalizationSettings{StripMetadataNoise: true, StripStatus: true, IgnoreListOrder: true})
+	assert.Equal(t, []interface{}{"a", "b"}, normalized.Object["spec"].(map[string]interface{})["ports"])
+	assert.Equal(t, []interface{}{"b", "a"}, obj.Object["spec"].(map[string]interface{})["ports"])
+	assert.Contains(t, obj.Object, "status")
+}
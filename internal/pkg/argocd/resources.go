@@ -0,0 +1,72 @@
+package argocd
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ResourceRequests are the CPU and memory requests of all the pods of a k8s object, DaemonSets are counted per node
+type ResourceRequests struct {
+	CPUMilli    int64
+	MemoryBytes int64
+}
+
+// podReplicas returns how many pods the object runs, based on spec.replicas(workloads) or spec.parallelism(Jobs)
+func podReplicas(obj *unstructured.Unstructured) int64 {
+	for _, replicasField := range [][]string{{"spec", "replicas"}, {"spec", "parallelism"}} {
+		if replicas, found, err := unstructured.NestedInt64(obj.Object, replicasField...); found && err == nil {
+			return replicas
+		}
+	}
+	return 1
+}
+
+// requestQuantity parses a container resource request, unquoted YAML numbers(e.g. cpu: 1) are accepted too
+func requestQuantity(container map[string]interface{}, resourceName string) (resource.Quantity, bool) {
+	value, found, err := unstructured.NestedFieldNoCopy(container, "resources", "requests", resourceName)
+	if !found || err != nil {
+		return resource.Quantity{}, false
+	}
+	q, err := resource.ParseQuantity(fmt.Sprint(value))
+	if err != nil {
+		return resource.Quantity{}, false
+	}
+	return q, true
+}
+
+// podRequests sums the requests of the object containers(init containers don't run alongside them so they are ignored) times its replicas
+func podRequests(obj *unstructured.Unstructured) ResourceRequests {
+	requests := ResourceRequests{}
+	if obj == nil {
+		return requests
+	}
+	for _, podSpecPath := range podSpecPaths {
+		containers, found, err := unstructured.NestedSlice(obj.Object, append(append([]string{}, podSpecPath...), "containers")...)
+		if !found || err != nil {
+			continue
+		}
+		for _, c := range containers {
+			container, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if cpu, ok := requestQuantity(container, "cpu"); ok {
+				requests.CPUMilli += cpu.MilliValue()
+			}
+			if memory, ok := requestQuantity(container, "memory"); ok {
+				requests.MemoryBytes += memory.Value()
+			}
+		}
+		replicas := int64(1)
+		if len(podSpecPath) > 1 {
+			// Pods have no replicas, the other kinds have the pod spec in a template
+			replicas = podReplicas(obj)
+		}
+		requests.CPUMilli *= replicas
+		requests.MemoryBytes *= replicas
+		break
+	}
+	return requests
+}
@@ -0,0 +1,219 @@
+package argocd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
+	argoappv1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"github.com/argoproj/gitops-engine/pkg/health"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	argoRolloutsGroup = "argoproj.io"
+	rolloutKind       = "Rollout"
+	analysisRunKind   = "AnalysisRun"
+)
+
+// RolloutAnalysisMetric is the result of a single metric of an AnalysisRun
+type RolloutAnalysisMetric struct {
+	Name    string
+	Phase   string
+	Message string
+}
+
+// RolloutAnalysis is the outcome of an Argo Rollouts AnalysisRun
+type RolloutAnalysis struct {
+	Name    string
+	Phase   string
+	Message string
+	Metrics []RolloutAnalysisMetric
+}
+
+// RolloutResult describes how the Argo Rollouts of a component app progressed after a promotion was synced
+type RolloutResult struct {
+	ComponentPath string
+	AppName       string
+	Rollouts      []string
+	// Completed is set when all the rollouts are Healthy, Aborted when one of them is Degraded(Argo Rollouts aborted it back to the stable version)
+	Completed bool
+	Aborted   bool
+	Message   string
+	Analyses  []RolloutAnalysis
+	Err       error
+}
+
+// appRollouts returns the Argo Rollouts managed by the app
+func appRollouts(app *argoappv1.Application) []argoappv1.ResourceStatus {
+	rollouts := []argoappv1.ResourceStatus{}
+	for _, resource := range app.Status.Resources {
+		if resource.Group == argoRolloutsGroup && resource.Kind == rolloutKind {
+			rollouts = append(rollouts, resource)
+		}
+	}
+	return rollouts
+}
+
+// rolloutsDone checks the ArgoCD health of the rollouts, message is the health message of the aborted rollout.
+// Suspended(paused) and Progressing rollouts are still rolling out
+func rolloutsDone(rollouts []argoappv1.ResourceStatus) (completed bool, aborted bool, message string) {
+	completed = true
+	for _, rollout := range rollouts {
+		if rollout.Health == nil {
+			completed = false
+			continue
+		}
+		switch rollout.Health.Status {
+		case health.HealthStatusDegraded:
+			return false, true, fmt.Sprintf("%s: %s", rollout.Name, rollout.Health.Message)
+		case health.HealthStatusHealthy:
+		default:
+			completed = false
+		}
+	}
+	return completed, false, ""
+}
+
+// parseAnalysisRun extracts the outcome of an AnalysisRun from its live manifest
+func parseAnalysisRun(manifest string) (RolloutAnalysis, error) {
+	var analysisRun struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Status struct {
+			Phase         string `json:"phase"`
+			Message       string `json:"message"`
+			MetricResults []struct {
+				Name    string `json:"name"`
+				Phase   string `json:"phase"`
+				Message string `json:"message"`
+			} `json:"metricResults"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal([]byte(manifest), &analysisRun); err != nil {
+		return RolloutAnalysis{}, err
+	}
+	analysis := RolloutAnalysis{Name: analysisRun.Metadata.Name, Phase: analysisRun.Status.Phase, Message: analysisRun.Status.Message}
+	for _, metric := range analysisRun.Status.MetricResults {
+		analysis.Metrics = append(analysis.Metrics, RolloutAnalysisMetric{Name: metric.Name, Phase: metric.Phase, Message: metric.Message})
+	}
+	return analysis, nil
+}
+
+// rolloutAnalyses returns the AnalysisRuns the rollouts of the app created since the promotion, AnalysisRuns aren't managed by ArgoCD so they are looked up in the resource tree
+func rolloutAnalyses(ctx context.Context, appClient application.ApplicationServiceClient, app *argoappv1.Application, since time.Time) ([]RolloutAnalysis, error) {
+	tree, err := appClient.ResourceTree(ctx, &application.ResourcesQuery{ApplicationName: &app.Name, AppNamespace: &app.Namespace})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the resource tree of app %s: %w", app.Name, err)
+	}
+	analyses := []RolloutAnalysis{}
+	for _, node := range tree.Nodes {
+		if node.Group != argoRolloutsGroup || node.Kind != analysisRunKind || (node.CreatedAt != nil && node.CreatedAt.Time.Before(since)) {
+			continue
+		}
+		resource, err := appClient.GetResource(ctx, &application.ApplicationResourceRequest{
+			Name:         &app.Name,
+			AppNamespace: &app.Namespace,
+			Namespace:    &node.Namespace,
+			ResourceName: &node.Name,
+			Group:        &node.Group,
+			Version:      &node.Version,
+			Kind:         &node.Kind,
+		})
+		if err != nil {
+			return analyses, fmt.Errorf("failed to get AnalysisRun %s: %w", node.Name, err)
+		}
+		analysis, err := parseAnalysisRun(resource.GetManifest())
+		if err != nil {
+			return analyses, fmt.Errorf("failed to parse AnalysisRun %s: %w", node.Name, err)
+		}
+		analyses = append(analyses, analysis)
+	}
+	sort.Slice(analyses, func(i, j int) bool { return analyses[i].Name < analyses[j].Name })
+	return analyses, nil
+}
+
+// waitForRollouts polls the app until it's at revision and its rollouts completed or aborted, the wait is bound by ctx.
+// since filters out the AnalysisRuns of earlier rollouts
+func waitForRollouts(ctx context.Context, appClient application.ApplicationServiceClient, app *argoappv1.Application, revision string, since time.Time, pollInterval time.Duration) (result RolloutResult) {
+	result.AppName = app.Name
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			result.Err = fmt.Errorf("timed out waiting for the rollouts of app %s at revision %s: %w", app.Name, revision, ctx.Err())
+			return result
+		case <-ticker.C:
+		}
+		refreshType := string(argoappv1.RefreshTypeNormal)
+		currentApp, err := appClient.Get(ctx, &application.ApplicationQuery{Name: &app.Name, AppNamespace: &app.Namespace, Refresh: &refreshType})
+		if err != nil {
+			log.Warnf("Failed to get app %s while waiting for its rollouts: %v", app.Name, err)
+			continue
+		}
+		if !appAtRevision(currentApp, revision) {
+			continue
+		}
+		if op := currentApp.Status.OperationState; op != nil && !op.Phase.Completed() {
+			continue
+		}
+		rollouts := appRollouts(currentApp)
+		result.Rollouts = []string{}
+		for _, rollout := range rollouts {
+			result.Rollouts = append(result.Rollouts, rollout.Name)
+		}
+		result.Completed, result.Aborted, result.Message = rolloutsDone(rollouts)
+		if !result.Completed && !result.Aborted {
+			continue
+		}
+		result.Analyses, err = rolloutAnalyses(ctx, appClient, currentApp, since)
+		if err != nil {
+			// The rollout outcome is known, missing analyses only make the report less detailed
+			log.Warnf("Failed to get the rollout analyses of app %s: %v", app.Name, err)
+		}
+		return result
+	}
+}
+
+// ComponentAppRollouts returns the names of the Argo Rollouts of the ArgoCD app of a component, none means it doesn't use progressive delivery
+func ComponentAppRollouts(ctx context.Context, componentPath string, repo string, useSHALabelForArgoDicovery bool) ([]string, error) {
+	ac, err := CreateArgoCdClients(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating ArgoCD clients: %w", err)
+	}
+	app, err := findArgocdApp(ctx, componentPath, repo, ac, useSHALabelForArgoDicovery)
+	if err != nil {
+		return nil, fmt.Errorf("error finding ArgoCD application for component path %s: %w", componentPath, err)
+	}
+	if app == nil {
+		return nil, nil
+	}
+	rollouts := []string{}
+	for _, rollout := range appRollouts(app) {
+		rollouts = append(rollouts, rollout.Name)
+	}
+	return rollouts, nil
+}
+
+// WaitForComponentRollouts waits for the Argo Rollouts of the ArgoCD app of a component to complete or abort at revision, the wait is bound by ctx
+func WaitForComponentRollouts(ctx context.Context, componentPath string, repo string, useSHALabelForArgoDicovery bool, revision string, since time.Time, pollInterval time.Duration) (result RolloutResult) {
+	ac, err := CreateArgoCdClients(ctx)
+	if err != nil {
+		return RolloutResult{ComponentPath: componentPath, Err: fmt.Errorf("Error creating ArgoCD clients: %w", err)}
+	}
+	app, err := findArgocdApp(ctx, componentPath, repo, ac, useSHALabelForArgoDicovery)
+	if err != nil {
+		return RolloutResult{ComponentPath: componentPath, Err: fmt.Errorf("error finding ArgoCD application for component path %s: %w", componentPath, err)}
+	}
+	if app == nil {
+		return RolloutResult{ComponentPath: componentPath, Err: fmt.Errorf("no ArgoCD application was found for component path: %s", componentPath)}
+	}
+	result = waitForRollouts(ctx, ac.app, app, revision, since, pollInterval)
+	result.ComponentPath = componentPath
+	return result
+}
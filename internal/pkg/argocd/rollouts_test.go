@@ -0,0 +1,106 @@
+package argocd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
+	argoappv1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"github.com/argoproj/gitops-engine/pkg/health"
+	synccommon "github.com/argoproj/gitops-engine/pkg/sync/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/mocks"
+	"go.uber.org/mock/gomock"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func rolloutStatus(name string, healthStatus health.HealthStatusCode, message string) argoappv1.ResourceStatus {
+	return argoappv1.ResourceStatus{Group: "argoproj.io", Kind: "Rollout", Name: name, Health: &argoappv1.HealthStatus{Status: healthStatus, Message: message}}
+}
+
+func TestRolloutsDone(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		rollouts          []argoappv1.ResourceStatus
+		expectedCompleted bool
+		expectedAborted   bool
+		expectedMessage   string
+	}{
+		"All Healthy": {
+			rollouts:          []argoappv1.ResourceStatus{rolloutStatus("a", health.HealthStatusHealthy, ""), rolloutStatus("b", health.HealthStatusHealthy, "")},
+			expectedCompleted: true,
+		},
+		"Paused": {
+			rollouts: []argoappv1.ResourceStatus{rolloutStatus("a", health.HealthStatusHealthy, ""), rolloutStatus("b", health.HealthStatusSuspended, "CanaryPauseStep")},
+		},
+		"Aborted": {
+			rollouts:        []argoappv1.ResourceStatus{rolloutStatus("a", health.HealthStatusProgressing, ""), rolloutStatus("b", health.HealthStatusDegraded, "RolloutAborted: metric error-rate assessed Failed")},
+			expectedAborted: true,
+			expectedMessage: "b: RolloutAborted: metric error-rate assessed Failed",
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			completed, aborted, message := rolloutsDone(tc.rollouts)
+			assert.Equal(t, tc.expectedCompleted, completed)
+			assert.Equal(t, tc.expectedAborted, aborted)
+			assert.Equal(t, tc.expectedMessage, message)
+		})
+	}
+}
+
+const failedAnalysisRunManifest = `{
+  "apiVersion": "argoproj.io/v1alpha1",
+  "kind": "AnalysisRun",
+  "metadata": {"name": "c1-6d4f-2"},
+  "status": {
+    "phase": "Failed",
+    "message": "Metric \"error-rate\" assessed Failed due to failed (3) > failureLimit (2)",
+    "metricResults": [{"name": "error-rate", "phase": "Failed", "message": ""}]
+  }
+}`
+
+func TestWaitForRollouts(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockApplicationClient := mocks.NewMockApplicationServiceClient(ctrl)
+
+	since := time.Date(2024, 6, 5, 12, 0, 0, 0, time.UTC)
+	progressing := appWithStatus(argoappv1.SyncStatusCodeSynced, health.HealthStatusProgressing, synccommon.OperationSucceeded)
+	progressing.Status.Sync.Revision = "new"
+	progressing.Status.Resources = []argoappv1.ResourceStatus{rolloutStatus("c1", health.HealthStatusProgressing, "")}
+	aborted := appWithStatus(argoappv1.SyncStatusCodeSynced, health.HealthStatusDegraded, synccommon.OperationSucceeded)
+	aborted.Status.Sync.Revision = "new"
+	aborted.Status.Resources = []argoappv1.ResourceStatus{rolloutStatus("c1", health.HealthStatusDegraded, "RolloutAborted")}
+	gomock.InOrder(
+		mockApplicationClient.EXPECT().Get(gomock.Any(), gomock.Any()).Return(progressing, nil),
+		mockApplicationClient.EXPECT().Get(gomock.Any(), gomock.Any()).Return(aborted, nil),
+	)
+	mockApplicationClient.EXPECT().ResourceTree(gomock.Any(), gomock.Any()).Return(&argoappv1.ApplicationTree{Nodes: []argoappv1.ResourceNode{
+		{ResourceRef: argoappv1.ResourceRef{Group: "argoproj.io", Version: "v1alpha1", Kind: "AnalysisRun", Namespace: "c1", Name: "c1-6d4f-1"}, CreatedAt: &metav1.Time{Time: since.Add(-time.Hour)}},
+		{ResourceRef: argoappv1.ResourceRef{Group: "argoproj.io", Version: "v1alpha1", Kind: "AnalysisRun", Namespace: "c1", Name: "c1-6d4f-2"}, CreatedAt: &metav1.Time{Time: since.Add(time.Minute)}},
+		{ResourceRef: argoappv1.ResourceRef{Group: "apps", Version: "v1", Kind: "ReplicaSet", Namespace: "c1", Name: "c1-6d4f"}},
+	}}, nil)
+	manifest := failedAnalysisRunManifest
+	// Only the AnalysisRun created since the promotion is fetched
+	mockApplicationClient.EXPECT().GetResource(gomock.Any(), gomock.Any()).Return(&application.ApplicationResourceResponse{Manifest: &manifest}, nil)
+
+	result := waitForRollouts(ctx, mockApplicationClient, progressing, "new", since, time.Millisecond)
+	assert.NoError(t, result.Err)
+	assert.Equal(t, []string{"c1"}, result.Rollouts)
+	assert.False(t, result.Completed)
+	assert.True(t, result.Aborted)
+	assert.Equal(t, "c1: RolloutAborted", result.Message)
+	assert.Equal(t, []RolloutAnalysis{{
+		Name:    "c1-6d4f-2",
+		Phase:   "Failed",
+		Message: `Metric "error-rate" assessed Failed due to failed (3) > failureLimit (2)`,
+		Metrics: []RolloutAnalysisMetric{{Name: "error-rate", Phase: "Failed"}},
+	}}, result.Analyses)
+}
@@ -0,0 +1,191 @@
+package argocd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
+	argoappv1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"github.com/argoproj/gitops-engine/pkg/health"
+	log "github.com/sirupsen/logrus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/maintenance"
+)
+
+// AppSyncResult describes the outcome of a post merge sync of a component ArgoCD app
+type AppSyncResult struct {
+	ComponentPath string
+	AppName       string
+	SyncTriggered bool
+	HealthStatus  string
+	SyncStatus    string
+	Err           error
+}
+
+func (r *AppSyncResult) updateStatus(app *argoappv1.Application) {
+	r.HealthStatus = string(app.Status.Health.Status)
+	r.SyncStatus = string(app.Status.Sync.Status)
+}
+
+// isAppSyncedAndHealthy returns done=true when the app reached Synced/Healthy and has no running sync operation, or when the last sync operation failed
+func isAppSyncedAndHealthy(app *argoappv1.Application) (done bool, err error) {
+	if op := app.Status.OperationState; op != nil {
+		if op.Phase.Completed() && !op.Phase.Successful() {
+			return true, fmt.Errorf("sync operation %s: %s", op.Phase, op.Message)
+		}
+		if !op.Phase.Completed() {
+			return false, nil
+		}
+	}
+	return app.Status.Sync.Status == argoappv1.SyncStatusCodeSynced && app.Status.Health.Status == health.HealthStatusHealthy, nil
+}
+
+// syncAndWait triggers a sync of apps without auto-sync and optionally polls the app until it's Synced and Healthy or ctx is done
+func syncAndWait(ctx context.Context, appClient application.ApplicationServiceClient, app *argoappv1.Application, wait bool, pollInterval time.Duration) (result AppSyncResult) {
+	result.AppName = app.Name
+	result.updateStatus(app)
+
+	if app.Spec.SyncPolicy == nil || app.Spec.SyncPolicy.Automated == nil {
+		_, err := appClient.Sync(ctx, &application.ApplicationSyncRequest{Name: &app.Name, AppNamespace: &app.Namespace})
+		if err != nil {
+			result.Err = fmt.Errorf("failed to sync app %s: %w", app.Name, err)
+			return result
+		}
+		log.Infof("Triggered sync of ArgoCD app %s", app.Name)
+		result.SyncTriggered = true
+	}
+	if !wait {
+		return result
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			result.Err = fmt.Errorf("timed out waiting for app %s to be Synced and Healthy", app.Name)
+			return result
+		case <-ticker.C:
+		}
+		refreshType := string(argoappv1.RefreshTypeNormal)
+		currentApp, err := appClient.Get(ctx, &application.ApplicationQuery{Name: &app.Name, AppNamespace: &app.Namespace, Refresh: &refreshType})
+		if err != nil {
+			log.Warnf("Failed to get app %s while waiting for sync: %v", app.Name, err)
+			continue
+		}
+		result.updateStatus(currentApp)
+		done, err := isAppSyncedAndHealthy(currentApp)
+		if done {
+			result.Err = err
+			return result
+		}
+	}
+}
+
+// SyncAndWaitForComponentApp triggers a sync of the ArgoCD app of a component(unless it has auto-sync enabled) and optionally waits for it to be Synced and Healthy, the wait is bound by ctx
+func SyncAndWaitForComponentApp(ctx context.Context, componentPath string, repo string, useSHALabelForArgoDicovery bool, wait bool, pollInterval time.Duration) (result AppSyncResult) {
+	if maintenance.Skip("argocd_sync") {
+		return AppSyncResult{ComponentPath: componentPath, Err: maintenance.ErrEnabled}
+	}
+	ac, err := CreateArgoCdClients(ctx)
+	if err != nil {
+		return AppSyncResult{ComponentPath: componentPath, Err: fmt.Errorf("Error creating ArgoCD clients: %w", err)}
+	}
+	app, err := findArgocdApp(ctx, componentPath, repo, ac, useSHALabelForArgoDicovery)
+	if err != nil {
+		return AppSyncResult{ComponentPath: componentPath, Err: fmt.Errorf("error finding ArgoCD application for component path %s: %w", componentPath, err)}
+	}
+	if app == nil {
+		return AppSyncResult{ComponentPath: componentPath, Err: fmt.Errorf("no ArgoCD application was found for component path: %s", componentPath)}
+	}
+	result = syncAndWait(ctx, ac.app, app, wait, pollInterval)
+	result.ComponentPath = componentPath
+	return result
+}
+
+// HardRefreshComponentApp asks ArgoCD to hard refresh the app of a component, i.e. re-render its manifests instead of using the cached ones, and returns the app name
+func HardRefreshComponentApp(ctx context.Context, componentPath string, repo string, useSHALabelForArgoDicovery bool) (string, error) {
+	if maintenance.Skip("argocd_hard_refresh") {
+		return "", maintenance.ErrEnabled
+	}
+	ac, err := CreateArgoCdClients(ctx)
+	if err != nil {
+		return "", fmt.Errorf("Error creating ArgoCD clients: %w", err)
+	}
+	app, err := findArgocdApp(ctx, componentPath, repo, ac, useSHALabelForArgoDicovery)
+	if err != nil {
+		return "", fmt.Errorf("error finding ArgoCD application for component path %s: %w", componentPath, err)
+	}
+	if app == nil {
+		return "", fmt.Errorf("no ArgoCD application was found for component path: %s", componentPath)
+	}
+	refreshType := string(argoappv1.RefreshTypeHard)
+	_, err = ac.app.Get(ctx, &application.ApplicationQuery{Name: &app.Name, AppNamespace: &app.Namespace, Refresh: &refreshType})
+	if err != nil {
+		return app.Name, fmt.Errorf("failed to hard refresh app %s: %w", app.Name, err)
+	}
+	return app.Name, nil
+}
+
+// appAtRevision returns true if the app's last comparison was made against revision(any of the sources for multi-source apps)
+func appAtRevision(app *argoappv1.Application, revision string) bool {
+	if app.Status.Sync.Revision == revision {
+		return true
+	}
+	for _, r := range app.Status.Sync.Revisions {
+		if r == revision {
+			return true
+		}
+	}
+	return false
+}
+
+// waitForAppRevision polls the app until it's Synced and Healthy at revision, the wait is bound by ctx
+func waitForAppRevision(ctx context.Context, appClient application.ApplicationServiceClient, app *argoappv1.Application, revision string, pollInterval time.Duration) (result AppSyncResult) {
+	result.AppName = app.Name
+	result.updateStatus(app)
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			result.Err = fmt.Errorf("timed out waiting for app %s to be Synced and Healthy at revision %s: %w", app.Name, revision, ctx.Err())
+			return result
+		case <-ticker.C:
+		}
+		refreshType := string(argoappv1.RefreshTypeNormal)
+		currentApp, err := appClient.Get(ctx, &application.ApplicationQuery{Name: &app.Name, AppNamespace: &app.Namespace, Refresh: &refreshType})
+		if err != nil {
+			log.Warnf("Failed to get app %s while waiting for revision %s: %v", app.Name, revision, err)
+			continue
+		}
+		result.updateStatus(currentApp)
+		if !appAtRevision(currentApp, revision) {
+			continue
+		}
+		done, err := isAppSyncedAndHealthy(currentApp)
+		if done {
+			result.Err = err
+			return result
+		}
+	}
+}
+
+// WaitForComponentAppRevision waits for the ArgoCD app of a component to be Synced and Healthy at revision without triggering a sync, the wait is bound by ctx
+func WaitForComponentAppRevision(ctx context.Context, componentPath string, repo string, useSHALabelForArgoDicovery bool, revision string, pollInterval time.Duration) (result AppSyncResult) {
+	ac, err := CreateArgoCdClients(ctx)
+	if err != nil {
+		return AppSyncResult{ComponentPath: componentPath, Err: fmt.Errorf("Error creating ArgoCD clients: %w", err)}
+	}
+	app, err := findArgocdApp(ctx, componentPath, repo, ac, useSHALabelForArgoDicovery)
+	if err != nil {
+		return AppSyncResult{ComponentPath: componentPath, Err: fmt.Errorf("error finding ArgoCD application for component path %s: %w", componentPath, err)}
+	}
+	if app == nil {
+		return AppSyncResult{ComponentPath: componentPath, Err: fmt.Errorf("no ArgoCD application was found for component path: %s", componentPath)}
+	}
+	result = waitForAppRevision(ctx, ac.app, app, revision, pollInterval)
+	result.ComponentPath = componentPath
+	return result
+}
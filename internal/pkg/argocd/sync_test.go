@@ -0,0 +1,122 @@
+package argocd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	argoappv1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"github.com/argoproj/gitops-engine/pkg/health"
+	synccommon "github.com/argoproj/gitops-engine/pkg/sync/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/mocks"
+	"go.uber.org/mock/gomock"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func appWithStatus(syncStatus argoappv1.SyncStatusCode, healthStatus health.HealthStatusCode, phase synccommon.OperationPhase) *argoappv1.Application {
+	app := &argoappv1.Application{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "argocd"}}
+	app.Status.Sync.Status = syncStatus
+	app.Status.Health.Status = healthStatus
+	if phase != "" {
+		app.Status.OperationState = &argoappv1.OperationState{Phase: phase}
+	}
+	return app
+}
+
+func TestIsAppSyncedAndHealthy(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		app          *argoappv1.Application
+		expectedDone bool
+		expectErr    bool
+	}{
+		"Synced and Healthy":    {app: appWithStatus(argoappv1.SyncStatusCodeSynced, health.HealthStatusHealthy, synccommon.OperationSucceeded), expectedDone: true},
+		"Sync still running":    {app: appWithStatus(argoappv1.SyncStatusCodeSynced, health.HealthStatusHealthy, synccommon.OperationRunning), expectedDone: false},
+		"Progressing":           {app: appWithStatus(argoappv1.SyncStatusCodeSynced, health.HealthStatusProgressing, ""), expectedDone: false},
+		"Sync operation failed": {app: appWithStatus(argoappv1.SyncStatusCodeOutOfSync, health.HealthStatusHealthy, synccommon.OperationFailed), expectedDone: true, expectErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			done, err := isAppSyncedAndHealthy(tc.app)
+			assert.Equal(t, tc.expectedDone, done)
+			assert.Equal(t, tc.expectErr, err != nil)
+		})
+	}
+}
+
+func TestSyncAndWait(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockApplicationClient := mocks.NewMockApplicationServiceClient(ctrl)
+
+	app := appWithStatus(argoappv1.SyncStatusCodeOutOfSync, health.HealthStatusHealthy, "")
+	mockApplicationClient.EXPECT().Sync(gomock.Any(), gomock.Any()).Return(app, nil)
+	gomock.InOrder(
+		mockApplicationClient.EXPECT().Get(gomock.Any(), gomock.Any()).Return(appWithStatus(argoappv1.SyncStatusCodeSynced, health.HealthStatusProgressing, synccommon.OperationSucceeded), nil),
+		mockApplicationClient.EXPECT().Get(gomock.Any(), gomock.Any()).Return(appWithStatus(argoappv1.SyncStatusCodeSynced, health.HealthStatusHealthy, synccommon.OperationSucceeded), nil),
+	)
+
+	result := syncAndWait(ctx, mockApplicationClient, app, true, time.Millisecond)
+	assert.NoError(t, result.Err)
+	assert.True(t, result.SyncTriggered)
+	assert.Equal(t, "Synced", result.SyncStatus)
+	assert.Equal(t, "Healthy", result.HealthStatus)
+}
+
+func TestSyncAndWaitSkipsSyncOfAutoSyncedApps(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockApplicationClient := mocks.NewMockApplicationServiceClient(ctrl)
+
+	app := appWithStatus(argoappv1.SyncStatusCodeOutOfSync, health.HealthStatusHealthy, "")
+	app.Spec.SyncPolicy = &argoappv1.SyncPolicy{Automated: &argoappv1.SyncPolicyAutomated{}}
+
+	result := syncAndWait(context.Background(), mockApplicationClient, app, false, time.Millisecond)
+	assert.NoError(t, result.Err)
+	assert.False(t, result.SyncTriggered)
+}
+
+func TestWaitForAppRevision(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockApplicationClient := mocks.NewMockApplicationServiceClient(ctrl)
+
+	oldRevision := appWithStatus(argoappv1.SyncStatusCodeSynced, health.HealthStatusHealthy, synccommon.OperationSucceeded)
+	oldRevision.Status.Sync.Revision = "old"
+	newRevision := appWithStatus(argoappv1.SyncStatusCodeSynced, health.HealthStatusHealthy, synccommon.OperationSucceeded)
+	newRevision.Status.Sync.Revision = "new"
+	gomock.InOrder(
+		mockApplicationClient.EXPECT().Get(gomock.Any(), gomock.Any()).Return(oldRevision, nil),
+		mockApplicationClient.EXPECT().Get(gomock.Any(), gomock.Any()).Return(newRevision, nil),
+	)
+
+	result := waitForAppRevision(ctx, mockApplicationClient, oldRevision, "new", time.Millisecond)
+	assert.NoError(t, result.Err)
+	assert.False(t, result.SyncTriggered)
+	assert.Equal(t, "Synced", result.SyncStatus)
+}
+
+func TestWaitForAppRevisionTimeout(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockApplicationClient := mocks.NewMockApplicationServiceClient(ctrl)
+
+	app := appWithStatus(argoappv1.SyncStatusCodeSynced, health.HealthStatusHealthy, synccommon.OperationSucceeded)
+	app.Status.Sync.Revision = "old"
+	mockApplicationClient.EXPECT().Get(gomock.Any(), gomock.Any()).Return(app, nil).AnyTimes()
+
+	result := waitForAppRevision(ctx, mockApplicationClient, app, "new", time.Millisecond)
+	assert.ErrorIs(t, result.Err, context.DeadlineExceeded)
+}
@@ -0,0 +1,100 @@
+package argocd
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
+	argoappv1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/leader"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/maintenance"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/tenancy"
+)
+
+// TempAppLabel marks the app objects Telefonistka creates to diff new components, the garbage collector only deletes apps with this label
+const TempAppLabel = "telefonistka.io/temporary-app"
+
+// tempAppOverrideAllowed checks a project or namespace override of the in-repo configuration against the comma separated allowlist in envKey,
+// repos would otherwise be able to create apps in projects(and namespaces) that grant them more than the ApplicationSet does
+func tempAppOverrideAllowed(ctx context.Context, envKey string, value string) bool {
+	for _, allowed := range strings.Split(tenancy.Getenv(ctx, envKey, ""), ",") {
+		if strings.TrimSpace(allowed) == value {
+			return true
+		}
+	}
+	return false
+}
+
+// applyTempAppSettings labels the temporary app and applies the configured labels, and the project/namespace overrides the server allows
+func applyTempAppSettings(ctx context.Context, app *argoappv1.Application, tempAppSettings TempAppSettings) {
+	if app.Labels == nil {
+		app.Labels = map[string]string{}
+	}
+	for k, v := range tempAppSettings.Labels {
+		app.Labels[k] = v
+	}
+	app.Labels[TempAppLabel] = "true"
+	if tempAppSettings.Project != "" {
+		if tempAppOverrideAllowed(ctx, "ARGOCD_TEMP_APP_ALLOWED_PROJECTS", tempAppSettings.Project) {
+			app.Spec.Project = tempAppSettings.Project
+		} else {
+			log.Warnf("Ignoring the argocd.tempAppObject.project %s of temporary app %s, it's not in ARGOCD_TEMP_APP_ALLOWED_PROJECTS", tempAppSettings.Project, app.Name)
+		}
+	}
+	if tempAppSettings.Namespace != "" {
+		if tempAppOverrideAllowed(ctx, "ARGOCD_TEMP_APP_ALLOWED_NAMESPACES", tempAppSettings.Namespace) {
+			app.Namespace = tempAppSettings.Namespace
+		} else {
+			log.Warnf("Ignoring the argocd.tempAppObject.namespace %s of temporary app %s, it's not in ARGOCD_TEMP_APP_ALLOWED_NAMESPACES", tempAppSettings.Namespace, app.Name)
+		}
+	}
+}
+
+// collectTempApps deletes temporary apps older than maxAge, these are left behind when a diff fails(they are kept for investigation) or Telefonistka was restarted mid diff
+func collectTempApps(ctx context.Context, appClient application.ApplicationServiceClient, maxAge time.Duration, now time.Time) (deleted int, err error) {
+	selector := TempAppLabel + "=true"
+	apps, err := appClient.List(ctx, &application.ApplicationQuery{Selector: &selector})
+	if err != nil {
+		return 0, err
+	}
+	for _, app := range apps.Items {
+		if now.Sub(app.CreationTimestamp.Time) < maxAge {
+			continue
+		}
+		_, err := appClient.Delete(ctx, &application.ApplicationDeleteRequest{Name: &app.Name, AppNamespace: &app.Namespace})
+		if err != nil {
+			log.Errorf("Failed to delete temporary app %s(created %s): %v", app.Name, app.CreationTimestamp, err)
+			prom.InstrumentTempAppCleanup("failure")
+			continue
+		}
+		log.Infof("Deleted temporary app %s(created %s)", app.Name, app.CreationTimestamp)
+		prom.InstrumentTempAppCleanup("success")
+		deleted++
+	}
+	return deleted, nil
+}
+
+// TempAppGarbageCollectorLoop periodically deletes temporary apps older than maxAge, it's meant to run in its own goroutine
+func TempAppGarbageCollectorLoop(interval time.Duration, maxAge time.Duration) {
+	for range time.Tick(interval) {
+		if leader.Skip("argocd_temp_app_gc") || maintenance.Skip("argocd_temp_app_gc") {
+			continue
+		}
+		ac, err := CreateArgoCdClients(context.Background())
+		if err != nil {
+			log.Errorf("Temp app garbage collector failed to create ArgoCD clients: %v", err)
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		deleted, err := collectTempApps(ctx, ac.app, maxAge, time.Now())
+		cancel()
+		if err != nil {
+			log.Errorf("Temp app garbage collector failed to list temporary apps: %v", err)
+			continue
+		}
+		log.Debugf("Temp app garbage collector deleted %d apps", deleted)
+	}
+}
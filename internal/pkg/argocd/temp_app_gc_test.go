@@ -0,0 +1,60 @@
+package argocd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
+	argoappv1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/mocks"
+	"go.uber.org/mock/gomock"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Not parallel, it sets env vars
+func TestApplyTempAppSettings(t *testing.T) {
+	t.Setenv("ARGOCD_TEMP_APP_ALLOWED_PROJECTS", "previews, sandbox")
+	t.Setenv("ARGOCD_TEMP_APP_ALLOWED_NAMESPACES", "")
+	newApp := func() *argoappv1.Application {
+		return &argoappv1.Application{
+			ObjectMeta: metav1.ObjectMeta{Name: "temp-foo", Namespace: "argocd", Labels: map[string]string{"team": "a"}},
+			Spec:       argoappv1.ApplicationSpec{Project: "default"},
+		}
+	}
+	app := newApp()
+	applyTempAppSettings(context.Background(), app, TempAppSettings{Project: "previews", Namespace: "team-a", Labels: map[string]string{"purpose": "diff"}})
+	assert.Equal(t, map[string]string{"team": "a", "purpose": "diff", TempAppLabel: "true"}, app.Labels)
+	assert.Equal(t, "previews", app.Spec.Project)
+	assert.Equal(t, "argocd", app.Namespace, "namespaces that aren't allowed are ignored")
+
+	app = newApp()
+	applyTempAppSettings(context.Background(), app, TempAppSettings{Project: "platform-admin"})
+	assert.Equal(t, "default", app.Spec.Project, "projects that aren't allowed are ignored")
+}
+
+func TestCollectTempApps(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockApplicationClient := mocks.NewMockApplicationServiceClient(ctrl)
+	now := time.Now()
+	mockApplicationClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(&argoappv1.ApplicationList{
+		Items: []argoappv1.Application{
+			{ObjectMeta: metav1.ObjectMeta{Name: "temp-old", CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Hour))}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "temp-new", CreationTimestamp: metav1.NewTime(now.Add(-time.Minute))}},
+		},
+	}, nil)
+	oldAppName, oldAppNamespace := "temp-old", ""
+	mockApplicationClient.EXPECT().
+		Delete(gomock.Any(), &application.ApplicationDeleteRequest{Name: &oldAppName, AppNamespace: &oldAppNamespace}).
+		Return(&application.ApplicationResponse{}, nil)
+
+	deleted, err := collectTempApps(ctx, mockApplicationClient, 30*time.Minute, now)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	assert.Equal(t, 1, deleted)
+}
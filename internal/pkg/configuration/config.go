@@ -1,6 +1,13 @@
 package configuration
 
 import (
+	"errors"
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+
 	yaml "gopkg.in/yaml.v2"
 )
 
@@ -23,6 +30,34 @@ type Condition struct {
 type PromotionPr struct {
 	TargetDescription string   `yaml:"targetDescription"`
 	TargetPaths       []string `yaml:"targetPaths"`
+	// Promote to the target paths in steps(canary), the promotion PR of each step is opened once the one of the previous step was merged
+	Steps []PromotionStep `yaml:"steps"`
+}
+
+// PromotionStep is a step of a canary promotion, it promotes to the cumulative percentage of the target paths(in the configured order, rounded up)
+type PromotionStep struct {
+	Percent int `yaml:"percent"`
+}
+
+// RequiredApprovers maps promotion target paths(regex) to the GitHub users and teams whose review is required before Telefonistka auto-merges a promotion PR
+type RequiredApprovers struct {
+	TargetPathRegex string   `yaml:"targetPathRegex"`
+	Users           []string `yaml:"users"`
+	Teams           []string `yaml:"teams"`
+}
+
+// PromotionPrRouting sets the reviewers and assignees of promotion PRs targeting matching paths(regex), PRs without routed assignees are assigned to the original PR author
+type PromotionPrRouting struct {
+	TargetPathRegex string   `yaml:"targetPathRegex"`
+	Reviewers       []string `yaml:"reviewers"`
+	TeamReviewers   []string `yaml:"teamReviewers"`
+	Assignees       []string `yaml:"assignees"`
+}
+
+// ApproveCommandConfig lists who can approve and merge promotion PRs with a "/telefonistka approve" comment, the command is disabled when both are empty
+type ApproveCommandConfig struct {
+	Users []string `yaml:"users"`
+	Teams []string `yaml:"teams"`
 }
 
 type PromotionPath struct {
@@ -35,15 +70,458 @@ type PromotionPath struct {
 type Config struct {
 	// What paths trigger promotion to which paths
 	PromotionPaths []PromotionPath `yaml:"promotionPaths"`
+	// Environments are promoted in the order they are listed, they are converted to PromotionPaths that come after the explicit ones
+	Environments []Environment `yaml:"environments"`
+
+	// Components that are promoted together are split into ordered promotion PRs, e.g. CRDs before their controllers
+	ComponentDependencies []ComponentDependency  `yaml:"componentDependencies"`
+	AtomicPromotions      AtomicPromotionsConfig `yaml:"atomicPromotions"`
 
 	// Generic configuration
-	PromtionPrLables             []string               `yaml:"promtionPRlables"`
-	DryRunMode                   bool                   `yaml:"dryRunMode"`
-	AutoApprovePromotionPrs      bool                   `yaml:"autoApprovePromotionPrs"`
-	ToggleCommitStatus           map[string]string      `yaml:"toggleCommitStatus"`
-	WebhookEndpointRegexs        []WebhookEndpointRegex `yaml:"webhookEndpointRegexs"`
-	WhProxtSkipTLSVerifyUpstream bool                   `yaml:"whProxtSkipTLSVerifyUpstream"`
-	Argocd                       ArgocdConfig           `yaml:"argocd"`
+	PromtionPrLables []string `yaml:"promtionPRlables"` // Extra labels added to promotion PRs
+	// Labels of the original PR that match one of these regexes are copied to its promotion PRs
+	PropagatedPrLabels []string `yaml:"propagatedPrLabels"`
+	// Appended to the message of promotion commits, e.g. "[skip ci]"
+	PromotionCommitMessageSuffix string `yaml:"promotionCommitMessageSuffix"`
+	DryRunMode                   bool   `yaml:"dryRunMode"`
+	// Only comment what would have been done, GitHub and ArgoCD writes other than comments are skipped. Implies DryRunMode
+	ShadowMode              bool `yaml:"shadowMode"`
+	AutoApprovePromotionPrs bool `yaml:"autoApprovePromotionPrs"`
+	// Rebuild open promotion PRs that conflict with the default branch after a PR is merged
+	AutoRebaseConflictingPromotionPrs bool                     `yaml:"autoRebaseConflictingPromotionPrs"`
+	PromotionPrJanitor                PromotionPrJanitorConfig `yaml:"promotionPrJanitor"`
+	DriftScan                         DriftScanConfig          `yaml:"driftScan"`
+	PromotionFilePolicy               PromotionFilePolicy      `yaml:"promotionFilePolicy"`
+	SecretScanning                    SecretScanningConfig     `yaml:"secretScanning"`
+	CodeOwners                        CodeOwnersConfig         `yaml:"codeOwners"`
+	// What to do when a new promotion PR promotes the same source and target paths as an open one: "update" the open PR branch in place
+	// or "close" it as superseded by the new PR, by default both PRs are left open
+	SupersedeOpenPromotionPrs    string                   `yaml:"supersedeOpenPromotionPrs"`
+	ToggleCommitStatus           map[string]string        `yaml:"toggleCommitStatus"`
+	WebhookEndpointRegexs        []WebhookEndpointRegex   `yaml:"webhookEndpointRegexs"`
+	WhProxtSkipTLSVerifyUpstream bool                     `yaml:"whProxtSkipTLSVerifyUpstream"`
+	Argocd                       ArgocdConfig             `yaml:"argocd"`
+	RequiredApprovers            []RequiredApprovers      `yaml:"requiredApprovers"`
+	PromotionPrRouting           []PromotionPrRouting     `yaml:"promotionPrRouting"`
+	ApproveCommand               ApproveCommandConfig     `yaml:"approveCommand"`
+	EventFilters                 EventFilters             `yaml:"eventFilters"`
+	Hotfix                       HotfixConfig             `yaml:"hotfix"`
+	PromotionTrains              []PromotionTrain         `yaml:"promotionTrains"`
+	GrafanaAnnotations           []GrafanaAnnotation      `yaml:"grafanaAnnotations"`
+	IssueTracker                 IssueTrackerConfig       `yaml:"issueTracker"`
+	ManifestValidation           ManifestValidationConfig `yaml:"manifestValidation"`
+	HelmDiff                     HelmDiffConfig           `yaml:"helmDiff"`
+	CostEstimation               CostEstimationConfig     `yaml:"costEstimation"`
+	Terraform                    TerraformConfig          `yaml:"terraform"`
+	DiffProviders                []DiffProviderConfig     `yaml:"diffProviders"`
+	// Keep a single comment per PR summarizing the diffs, checks, drift and promotion plan of its last change
+	PrSummaryComment     bool                      `yaml:"prSummaryComment"`
+	CheckRunAnnotations  CheckRunAnnotationsConfig `yaml:"checkRunAnnotations"`
+	CommitStatusContexts CommitStatusContexts      `yaml:"commitStatusContexts"`
+	PushActions          []PushAction              `yaml:"pushActions"`
+	// PRs labeled preview-config are checked with the telefonistka.yaml of their branch, so configuration changes can be tried before merging.
+	// Only read from the default branch, a PR can't opt itself in
+	ConfigPreview bool `yaml:"configPreview"`
+}
+
+const (
+	DiffProviderArgoCD    = "argocd"
+	DiffProviderHelm      = "helm"
+	DiffProviderKustomize = "kustomize"
+	DiffProviderTerraform = "terraform"
+)
+
+// DiffProviderConfig routes the changed components matching PathRegex to Provider, the first matching entry wins
+type DiffProviderConfig struct {
+	PathRegex string `yaml:"pathRegex"`
+	Provider  string `yaml:"provider"`
+}
+
+// TerraformConfig comments the terraform plan of PRs changing components matching PathRegexes, the plan is produced by the runner configured with TERRAFORM_PLAN_RUNNER
+type TerraformConfig struct {
+	PathRegexes []string `yaml:"pathRegexes"`
+}
+
+// CostEstimationConfig comments the pod resource requests delta(based on the ArgoCD diff) of PRs changing matching components
+type CostEstimationConfig struct {
+	PathRegexes []string `yaml:"pathRegexes"`
+	// Optional monthly prices, when set the comment includes the estimated monthly cost delta
+	CPUCoreMonthlyCost   float64 `yaml:"cpuCoreMonthlyCost"`
+	MemoryGiBMonthlyCost float64 `yaml:"memoryGiBMonthlyCost"`
+	Currency             string  `yaml:"currency"` // Defaults to "$"
+}
+
+// HelmDiffConfig renders the changed components that are Helm charts(have a Chart.yaml) with a local helm binary and comments the diff, for setups where Telefonistka can't reach ArgoCD
+type HelmDiffConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Extra values files, relative to the component path, passed to helm template when present, the chart values.yaml is always used
+	ValuesFiles []string `yaml:"valuesFiles"`
+	Namespace   string   `yaml:"namespace"`
+}
+
+// ManifestValidationConfig checks the Kubernetes manifests changed in PRs, so typos are caught before ArgoCD fails to apply them post-merge
+type ManifestValidationConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Changed files matching one of these regexes aren't validated, e.g. Helm values files
+	IgnoreFileRegexes []string `yaml:"ignoreFileRegexes"`
+	// If set, a commit status with this context reports the validation result, so branch protection can require it
+	CommitStatusContext string `yaml:"commitStatusContext"`
+}
+
+// CheckRunAnnotationsConfig reports the problems Telefonistka finds in a PR as check-run annotations, so they show inline in the Files Changed tab
+type CheckRunAnnotationsConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Skip the manifest validation and configuration lint comments, the annotations replace them
+	ReplaceComments bool `yaml:"replaceComments"`
+}
+
+// CommitStatusContexts adds a commit status per feature next to the "telefonistka" one, so repos can require only some of them, an empty context disables that status
+type CommitStatusContexts struct {
+	// Fails when the diff of a changed component couldn't be generated
+	Diff string `yaml:"diff"`
+	// Fails when a policy check(currently the manifest validation) finds problems
+	Policy string `yaml:"policy"`
+	// Set on promotion PRs, pending until their required approvals are received
+	Promotion string `yaml:"promotion"`
+	// Set on PRs changing telefonistka.yaml, fails when the changed configuration is invalid
+	Config string `yaml:"config"`
+}
+
+// PushAction runs when a push to the default branch changes files matching PathRegex
+type PushAction struct {
+	PathRegex string `yaml:"pathRegex"`
+	// Scan the repo for drift between its promotion paths and update the drift issue, without waiting for the next periodic scan
+	RefreshDrift bool `yaml:"refreshDrift"`
+	// Hard refresh the ArgoCD apps of the changed components, so ArgoCD re-renders their manifests without waiting for its next poll
+	ArgocdHardRefresh bool `yaml:"argocdHardRefresh"`
+}
+
+// IssueTrackerConfig controls what happens to the issues mentioned in the original PR, the tracker itself is configured on the server(JIRA_URL)
+type IssueTrackerConfig struct {
+	// Transition(or target status name) applied to the issues when a promotion PR to target paths with no further promotion step is merged, e.g. "In Production"
+	FinalPromotionTransition string `yaml:"finalPromotionTransition"`
+}
+
+// GrafanaAnnotation marks merges of promotion PRs to matching target paths on Grafana dashboards
+type GrafanaAnnotation struct {
+	TargetPathRegex string `yaml:"targetPathRegex"`
+	Environment     string `yaml:"environment"` // Used in the "environment:" tag
+	URL             string `yaml:"url"`
+	// Name of the Telefonistka server env var that holds the Grafana service account token(it must start with GRAFANA_), so the token isn't stored in the repo
+	TokenEnvVar  string   `yaml:"tokenEnvVar"`
+	DashboardUID string   `yaml:"dashboardUID"` // Optional, annotations are organization wide by default
+	Tags         []string `yaml:"tags"`
+}
+
+// PromotionTrain holds promotions to matching target paths until its window is open, the held promotions are opened together by the server promotion train job
+type PromotionTrain struct {
+	TargetPathRegex string   `yaml:"targetPathRegex"`
+	Days            []string `yaml:"days"`      // Three letter day names, e.g. "Mon", empty means every day
+	StartTime       string   `yaml:"startTime"` // "15:04" format, inclusive
+	EndTime         string   `yaml:"endTime"`   // "15:04" format, exclusive
+	TimeZone        string   `yaml:"timeZone"`  // IANA time zone name, defaults to UTC
+}
+
+// HotfixConfig allows PRs with the hotfix label to be promoted directly to the terminal environments, skipping the intermediate promotion targets
+type HotfixConfig struct {
+	Label             string   `yaml:"label"` // Defaults to "hotfix"
+	TargetPaths       []string `yaml:"targetPaths"`
+	TargetDescription string   `yaml:"targetDescription"`
+	// Open a PR syncing the hotfixed paths back to the skipped intermediate paths once the hotfix promotion PR is merged
+	BackPromotion      bool   `yaml:"backPromotion"`
+	BackPromotionLabel string `yaml:"backPromotionLabel"` // Defaults to "back-promotion"
+}
+
+const (
+	FanOutTogether = "together"
+	FanOutPerPath  = "perPath"
+)
+
+// ComponentDependency declares that when a promotion includes Component and one of the components it depends on, Component's promotion PR is opened once the promotion PR of its dependencies was merged.
+// Components are named like in the promotion PR titles, relative to the source path
+type ComponentDependency struct {
+	Component string   `yaml:"component"`
+	DependsOn []string `yaml:"dependsOn"`
+}
+
+// AtomicPromotionsConfig combines the promotions of a merged PR that share a source path into a single PR, so their targets are never left half-updated
+type AtomicPromotionsConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Added to combined promotion PRs that a gate blocked from auto-merging(default "promotion-blocked")
+	BlockedLabel string `yaml:"blockedLabel"`
+}
+
+// Environment is a named group of paths, e.g. "prod" with a path per region
+type Environment struct {
+	Name  string   `yaml:"name"`
+	Paths []string `yaml:"paths"`
+	// How promotions into this environment are split: "together"(default) opens a single PR to all the paths, "perPath" opens a PR per path
+	FanOut string `yaml:"fanOut"`
+	// Canary steps of the promotions into this environment, only used with the "together" fanOut
+	Steps                   []PromotionStep `yaml:"steps"`
+	ComponentPathExtraDepth int             `yaml:"componentPathExtraDepth"` // Used when promoting from this environment
+	Conditions              Condition       `yaml:"conditions"`              // Used when promoting from this environment
+}
+
+// EnvironmentPathNames maps the environment paths to display names, the environment name or "name (last path element)" for environments with several paths
+func (c *Config) EnvironmentPathNames() map[string]string {
+	if len(c.Environments) == 0 {
+		return nil
+	}
+	names := map[string]string{}
+	for _, env := range c.Environments {
+		for _, envPath := range env.Paths {
+			if len(env.Paths) == 1 {
+				names[envPath] = env.Name
+			} else {
+				names[envPath] = fmt.Sprintf("%s (%s)", env.Name, path.Base(strings.TrimSuffix(envPath, "/")))
+			}
+		}
+	}
+	return names
+}
+
+func (c *Config) validateEnvironments() error {
+	for i, env := range c.Environments {
+		if env.Name == "" {
+			return fmt.Errorf("environments[%d] has no name", i)
+		}
+		if len(env.Paths) == 0 {
+			return fmt.Errorf("environment %s has no paths", env.Name)
+		}
+		if env.FanOut != "" && env.FanOut != FanOutTogether && env.FanOut != FanOutPerPath {
+			return fmt.Errorf("environment %s has an unknown fanOut %q, it should be %q or %q", env.Name, env.FanOut, FanOutTogether, FanOutPerPath)
+		}
+	}
+	return nil
+}
+
+// validatePromotionSteps checks the step percentages are between 1 and 100 and increasing
+func validatePromotionSteps(steps []PromotionStep) error {
+	previous := 0
+	for i, step := range steps {
+		if step.Percent <= previous || step.Percent > 100 {
+			return fmt.Errorf("steps[%d] percent %d should be larger than the previous step and at most 100", i, step.Percent)
+		}
+		previous = step.Percent
+	}
+	return nil
+}
+
+func (c *Config) validatePromotionSteps() error {
+	for i, promotionPath := range c.PromotionPaths {
+		for j, promotionPr := range promotionPath.PromotionPrs {
+			if err := validatePromotionSteps(promotionPr.Steps); err != nil {
+				return fmt.Errorf("promotionPaths[%d].promotionPrs[%d].%w", i, j, err)
+			}
+		}
+	}
+	for _, env := range c.Environments {
+		if len(env.Steps) > 0 && env.FanOut == FanOutPerPath {
+			return fmt.Errorf("environment %s has steps, they can't be used with the %q fanOut", env.Name, FanOutPerPath)
+		}
+		if err := validatePromotionSteps(env.Steps); err != nil {
+			return fmt.Errorf("environment %s %w", env.Name, err)
+		}
+	}
+	return nil
+}
+
+// validateComponentDependencies rejects dependency cycles, the components of a cycle could never be promoted
+func (c *Config) validateComponentDependencies() error {
+	dependencies := map[string][]string{}
+	for i, dependency := range c.ComponentDependencies {
+		if dependency.Component == "" {
+			return fmt.Errorf("componentDependencies[%d] has no component", i)
+		}
+		dependencies[dependency.Component] = append(dependencies[dependency.Component], dependency.DependsOn...)
+	}
+	// 0 is unvisited, 1 is on the current path and 2 is done
+	state := map[string]int{}
+	var visit func(component string) error
+	visit = func(component string) error {
+		switch state[component] {
+		case 1:
+			return fmt.Errorf("componentDependencies has a cycle through %s", component)
+		case 2:
+			return nil
+		}
+		state[component] = 1
+		for _, dependency := range dependencies[component] {
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+		state[component] = 2
+		return nil
+	}
+	for _, dependency := range c.ComponentDependencies {
+		if err := visit(dependency.Component); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Config) validateDiffProviders() error {
+	for i, diffProvider := range c.DiffProviders {
+		switch diffProvider.Provider {
+		case DiffProviderArgoCD, DiffProviderHelm, DiffProviderKustomize, DiffProviderTerraform:
+		default:
+			return fmt.Errorf("diffProviders[%d] has an unknown provider %q", i, diffProvider.Provider)
+		}
+		_, err := regexp.Compile(diffProvider.PathRegex)
+		if err != nil {
+			return fmt.Errorf("diffProviders[%d] pathRegex: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (c *Config) validatePromotionFilePolicy() error {
+	for i, pattern := range c.PromotionFilePolicy.AllowedFilePatterns {
+		_, err := path.Match(pattern, "")
+		if err != nil {
+			return fmt.Errorf("promotionFilePolicy.allowedFilePatterns[%d] %q: %w", i, pattern, err)
+		}
+	}
+	if c.PromotionFilePolicy.MaxFileSizeMB < 0 {
+		return fmt.Errorf("promotionFilePolicy.maxFileSizeMB can't be negative")
+	}
+	return nil
+}
+
+func (c *Config) validateSecretScanning() error {
+	switch c.SecretScanning.Mode {
+	case "", SecretScanningBlock, SecretScanningWarn:
+	default:
+		return fmt.Errorf("secretScanning has an unknown mode %q, it should be %q or %q", c.SecretScanning.Mode, SecretScanningBlock, SecretScanningWarn)
+	}
+	for i, rule := range c.SecretScanning.Rules {
+		if rule.Name == "" {
+			return fmt.Errorf("secretScanning.rules[%d] has no name", i)
+		}
+		_, err := regexp.Compile(rule.Regex)
+		if err != nil {
+			return fmt.Errorf("secretScanning rule %s regex: %w", rule.Name, err)
+		}
+	}
+	for i, pathRegex := range c.SecretScanning.IgnorePathRegexes {
+		_, err := regexp.Compile(pathRegex)
+		if err != nil {
+			return fmt.Errorf("secretScanning.ignorePathRegexes[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (c *Config) validatePromotionPrRouting() error {
+	for i, routing := range c.PromotionPrRouting {
+		_, err := regexp.Compile(routing.TargetPathRegex)
+		if err != nil {
+			return fmt.Errorf("promotionPrRouting[%d] targetPathRegex: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (c *Config) validatePushActions() error {
+	for i, action := range c.PushActions {
+		_, err := regexp.Compile(action.PathRegex)
+		if err != nil {
+			return fmt.Errorf("pushActions[%d] pathRegex: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// environmentPromotionPaths promotes every path of each environment to the next environment
+func (c *Config) environmentPromotionPaths() []PromotionPath {
+	names := c.EnvironmentPathNames()
+	var promotionPaths []PromotionPath
+	for i := 0; i < len(c.Environments)-1; i++ {
+		nextEnv := c.Environments[i+1]
+		var promotionPrs []PromotionPr
+		if nextEnv.FanOut == FanOutPerPath {
+			for _, targetPath := range nextEnv.Paths {
+				promotionPrs = append(promotionPrs, PromotionPr{TargetDescription: names[targetPath], TargetPaths: []string{targetPath}})
+			}
+		} else {
+			promotionPrs = []PromotionPr{{TargetDescription: nextEnv.Name, TargetPaths: append([]string{}, nextEnv.Paths...), Steps: nextEnv.Steps}}
+		}
+		for _, sourcePath := range c.Environments[i].Paths {
+			promotionPaths = append(promotionPaths, PromotionPath{
+				SourcePath:              regexp.QuoteMeta(sourcePath),
+				ComponentPathExtraDepth: c.Environments[i].ComponentPathExtraDepth,
+				Conditions:              c.Environments[i].Conditions,
+				PromotionPrs:            promotionPrs,
+			})
+		}
+	}
+	return promotionPaths
+}
+
+// PromotionPrJanitorConfig controls closing abandoned promotion PRs, it's only used when the server runs the janitor(PROMOTION_PR_JANITOR_INTERVAL_MINUTES)
+type PromotionPrJanitorConfig struct {
+	// Promotion PRs opened more than this number of days ago are closed, 0 disables age based closing
+	MaxAgeDays int `yaml:"maxAgeDays"`
+	// Close promotion PRs when a newer promotion PR of the same source and target paths is open
+	CloseSuperseded bool `yaml:"closeSuperseded"`
+}
+
+// DriftScanConfig keeps an issue listing the drift between the promotion paths of the default branch, it's only used when the server runs the drift scan(DRIFT_SCAN_INTERVAL_MINUTES)
+type DriftScanConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Only the components matching one of these regexes are scanned, all the components of the promotion source paths are scanned when empty
+	PathRegexes []string `yaml:"pathRegexes"`
+}
+
+// PromotionFilePolicy restricts which files can be promoted, promotions of source paths with files that violate it aren't opened
+type PromotionFilePolicy struct {
+	// When set, only files with a name matching one of these globs(e.g. "*.yaml") can be promoted
+	AllowedFilePatterns []string `yaml:"allowedFilePatterns"`
+	// Files larger than this can't be promoted, 0 disables the limit
+	MaxFileSizeMB int  `yaml:"maxFileSizeMB"`
+	BlockSymlinks bool `yaml:"blockSymlinks"`
+}
+
+const (
+	SecretScanningBlock = "block"
+	SecretScanningWarn  = "warn"
+)
+
+// SecretScanningConfig scans the promoted files for credentials before promotion PRs are opened
+type SecretScanningConfig struct {
+	// "block" doesn't open promotions with findings, "warn" only comments them, scanning is disabled when empty
+	Mode string `yaml:"mode"`
+	// Extra rules, on top of the built-in ones for well known credential formats
+	Rules []SecretScanningRule `yaml:"rules"`
+	// Strings with a higher Shannon entropy(bits per character) are reported as possible secrets, 0 disables the entropy check
+	EntropyThreshold float64 `yaml:"entropyThreshold"`
+	// Files matching one of these regexes(e.g. sealed secrets) aren't scanned
+	IgnorePathRegexes []string `yaml:"ignorePathRegexes"`
+}
+
+type SecretScanningRule struct {
+	Name  string `yaml:"name"`
+	Regex string `yaml:"regex"`
+}
+
+// CodeOwnersConfig routes promotion PRs and diff errors to the owners of the components in the repo CODEOWNERS file
+type CodeOwnersConfig struct {
+	// Request reviews from the owners of the promoted paths on promotion PRs
+	RequestReviews bool `yaml:"requestReviews"`
+	// Mention the owners of components whose diff failed in the PR
+	MentionOnDiffErrors bool `yaml:"mentionOnDiffErrors"`
+}
+
+// EventFilters restrict which PRs Telefonistka processes, all the values are regexes and empty lists don't filter anything
+type EventFilters struct {
+	TargetBranches []string `yaml:"targetBranches"` // The PR base branch must match one of these
+	IgnoreAuthors  []string `yaml:"ignoreAuthors"`
+	IgnoreLabels   []string `yaml:"ignoreLabels"`
+	RequiredLabels []string `yaml:"requiredLabels"` // The PR must have at least one matching label
+	Paths          []string `yaml:"paths"`          // The PR must change at least one matching file
 }
 
 type ArgocdConfig struct {
@@ -52,12 +530,160 @@ type ArgocdConfig struct {
 	AllowSyncfromBranchPathRegex  string `yaml:"allowSyncfromBranchPathRegex"`
 	UseSHALabelForAppDiscovery    bool   `yaml:"useSHALabelForAppDiscovery"`
 	CreateTempAppObjectFroNewApps bool   `yaml:"createTempAppObjectFromNewApps"`
+	// These are applied on top of each app's own spec.ignoreDifferences
+	IgnoreDifferences     []IgnoreDifferences `yaml:"ignoreDifferences"`
+	IgnoreAggregatedRoles bool                `yaml:"ignoreAggregatedRoles"`
+	ServerSideDiff        bool                `yaml:"serverSideDiff"`
+	OversizedDiffUpload   string              `yaml:"oversizedDiffUpload"` // "gist"(when the server enables GIST_DIFF_UPLOAD_ENABLED, checkRun otherwise) or "checkRun", empty means the concise diff comment is used
+	TempAppObject         TempAppObjectConfig `yaml:"tempAppObject"`
+	PostMergeSync         PostMergeSyncConfig `yaml:"postMergeSync"`
+	NoDiff                NoDiffConfig        `yaml:"noDiff"`
+	// Reacts to the ArgoCD Notifications health webhooks sent to the server(ARGOCD_NOTIFICATIONS_TOKEN)
+	DegradedPromotion DegradedPromotionConfig `yaml:"degradedPromotion"`
+	Rollouts          RolloutsConfig          `yaml:"rollouts"`
+	// Comment a local kustomize build diff of the components ArgoCD didn't diff(disableArgoCDDiff, diff errors or ArgoCD unavailable)
+	KustomizeDiffFallback bool                    `yaml:"kustomizeDiffFallback"`
+	DiffNormalization     DiffNormalizationConfig `yaml:"diffNormalization"`
+	// How many components are diffed in parallel(default 10) and how long a single component diff can take(default 300), a component that times out is reported as a diff error
+	DiffConcurrency             int `yaml:"diffConcurrency"`
+	ComponentDiffTimeoutSeconds int `yaml:"componentDiffTimeoutSeconds"`
 }
 
+// DiffNormalizationConfig strips fields that change without an effect on the cluster before objects are diffed, objects left without a diff aren't reported
+type DiffNormalizationConfig struct {
+	StripMetadataNoise bool `yaml:"stripMetadataNoise"` // metadata.generation, resourceVersion, uid and creationTimestamp
+	StripStatus        bool `yaml:"stripStatus"`
+	IgnoreListOrder    bool `yaml:"ignoreListOrder"`
+}
+
+// NoDiffConfig controls what happens to PRs that are not expected to change the target clusters(empty ArgoCD diff)
+type NoDiffConfig struct {
+	// Label applied to the PR, defaults to "noop"
+	Label string `yaml:"label"`
+	// Close(without merging) non-promotion PRs with an empty diff
+	AutoCloseNonPromotionPrs bool `yaml:"autoCloseNonPromotionPrs"`
+	// When set, a successful commit status with this context is set on PRs with an empty diff, so CI can skip expensive steps
+	CommitStatusContext string `yaml:"commitStatusContext"`
+}
+
+// PostMergeSyncConfig controls syncing(and optionally waiting for) the ArgoCD apps of merged PR components
+type PostMergeSyncConfig struct {
+	Enabled        bool   `yaml:"enabled"`
+	PathRegex      string `yaml:"pathRegex"`
+	Wait           bool   `yaml:"wait"`
+	TimeoutMinutes int    `yaml:"timeoutMinutes"`
+}
+
+// RolloutsConfig holds the promotions of merged PRs until the Argo Rollouts of their components completed(or aborted)
+type RolloutsConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// How long to wait for the rollouts(default 60), the promotions aren't opened when they don't complete in time
+	TimeoutMinutes int `yaml:"timeoutMinutes"`
+}
+
+// DegradedPromotionConfig controls what happens when the ArgoCD app of a component becomes Degraded shortly after a promotion PR synced it
+type DegradedPromotionConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Only promotion PRs merged in the last WindowMinutes(default 60) are considered the cause
+	WindowMinutes int `yaml:"windowMinutes"`
+	// Commit promotion-paused marker files that stop the promotions of the component to the next environments
+	PauseDownstream bool `yaml:"pauseDownstream"`
+}
+
+// TempAppObjectConfig overrides fields of the temporary app objects created by createTempAppObjectFromNewApps
+type TempAppObjectConfig struct {
+	Project   string            `yaml:"project"`
+	Namespace string            `yaml:"namespace"`
+	Labels    map[string]string `yaml:"labels"`
+}
+
+// IgnoreDifferences mirrors ArgoCD's ResourceIgnoreDifferences
+type IgnoreDifferences struct {
+	Group                 string   `yaml:"group"`
+	Kind                  string   `yaml:"kind"`
+	Name                  string   `yaml:"name"`
+	Namespace             string   `yaml:"namespace"`
+	JSONPointers          []string `yaml:"jsonPointers"`
+	JQPathExpressions     []string `yaml:"jqPathExpressions"`
+	ManagedFieldsManagers []string `yaml:"managedFieldsManagers"`
+}
+
+var (
+	// The keys of the configuration types by type name, used to suggest the key an unknown key probably meant
+	configFields = func() map[string][]string {
+		fields := map[string][]string{}
+		knownFields(reflect.TypeOf(Config{}), fields)
+		return fields
+	}()
+	// The yaml error of an unknown key, e.g. "line 3: field autoMergeNoDiffPrs not found in type configuration.ArgocdConfig"
+	unknownFieldRegex = regexp.MustCompile(`field (\S+) not found in type configuration\.(\w+)$`)
+)
+
+// withKeySuggestions adds the key an unknown key probably meant(the same key in a different case) to the unknown key errors
+func withKeySuggestions(err error) error {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return err
+	}
+	suggested := &yaml.TypeError{}
+	for _, e := range typeErr.Errors {
+		if match := unknownFieldRegex.FindStringSubmatch(e); match != nil {
+			for _, known := range configFields[match[2]] {
+				if strings.EqualFold(known, match[1]) {
+					e += fmt.Sprintf(", did you mean %s?", known)
+					break
+				}
+			}
+		}
+		suggested.Errors = append(suggested.Errors, e)
+	}
+	return suggested
+}
+
+// ParseConfigFromYaml parses and validates a telefonistka.yaml, unknown keys are rejected(with their line) since they would silently do nothing
 func ParseConfigFromYaml(y string) (*Config, error) {
 	config := &Config{}
 
-	err := yaml.Unmarshal([]byte(y), config)
+	err := yaml.UnmarshalStrict([]byte(y), config)
+	if err != nil {
+		return config, withKeySuggestions(err)
+	}
+
+	err = config.validateEnvironments()
+	if err != nil {
+		return config, err
+	}
+	err = config.validatePromotionSteps()
+	if err != nil {
+		return config, err
+	}
+	err = config.validateComponentDependencies()
+	if err != nil {
+		return config, err
+	}
+	err = config.validateDiffProviders()
+	if err != nil {
+		return config, err
+	}
+	err = config.validatePromotionFilePolicy()
+	if err != nil {
+		return config, err
+	}
+	err = config.validateSecretScanning()
+	if err != nil {
+		return config, err
+	}
+	err = config.validatePromotionPrRouting()
+	if err != nil {
+		return config, err
+	}
+	err = config.validatePushActions()
+	if err != nil {
+		return config, err
+	}
+	config.PromotionPaths = append(config.PromotionPaths, config.environmentPromotionPaths()...)
+	// Shadow mode comments the promotion plan like dry run mode, and skips the other flows dry run mode skips
+	config.DryRunMode = config.DryRunMode || config.ShadowMode
 
-	return config, err
+	return config, nil
 }
@@ -78,3 +78,198 @@ func TestConfigurationParse(t *testing.T) {
 		t.Error(diff)
 	}
 }
+
+func TestEnvironmentsParse(t *testing.T) {
+	t.Parallel()
+
+	configurationFileContent, _ := os.ReadFile("tests/testEnvironmentsParsing.yaml")
+
+	config, err := ParseConfigFromYaml(string(configurationFileContent))
+	if err != nil {
+		t.Fatalf("config parsing failed: err=%s", err)
+	}
+
+	expectedPromotionPaths := []PromotionPath{
+		{
+			SourcePath: "env/dev/",
+			PromotionPrs: []PromotionPr{
+				{TargetDescription: "staging", TargetPaths: []string{"env/staging/"}},
+			},
+		},
+		{
+			SourcePath: "env/staging/",
+			Conditions: Condition{AutoMerge: true},
+			PromotionPrs: []PromotionPr{
+				{TargetDescription: "prod (prod-us)", TargetPaths: []string{"env/prod-us/"}},
+				{TargetDescription: "prod (prod-eu)", TargetPaths: []string{"env/prod-eu/"}},
+			},
+		},
+	}
+	if diff := deep.Equal(expectedPromotionPaths, config.PromotionPaths); diff != nil {
+		t.Error(diff)
+	}
+
+	expectedNames := map[string]string{
+		"env/dev/":     "dev",
+		"env/staging/": "staging",
+		"env/prod-us/": "prod (prod-us)",
+		"env/prod-eu/": "prod (prod-eu)",
+	}
+	if diff := deep.Equal(expectedNames, config.EnvironmentPathNames()); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestEnvironmentsValidation(t *testing.T) {
+	t.Parallel()
+	tests := map[string]string{
+		"missing name":   "environments:\n  - paths: [env/dev/]\n",
+		"missing paths":  "environments:\n  - name: dev\n",
+		"unknown fanOut": "environments:\n  - name: dev\n    paths: [env/dev/]\n    fanOut: random\n",
+	}
+	for name, configYaml := range tests {
+		configYaml := configYaml
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := ParseConfigFromYaml(configYaml); err == nil {
+				t.Error("expected a validation error")
+			}
+		})
+	}
+}
+
+func TestDiffProvidersValidation(t *testing.T) {
+	t.Parallel()
+	tests := map[string]string{
+		"unknown provider": "diffProviders:\n  - pathRegex: ^clusters/\n    provider: flux\n",
+		"invalid regex":    "diffProviders:\n  - pathRegex: ^clusters/(\n    provider: helm\n",
+	}
+	for name, configYaml := range tests {
+		configYaml := configYaml
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := ParseConfigFromYaml(configYaml); err == nil {
+				t.Error("expected a validation error")
+			}
+		})
+	}
+}
+
+func TestPromotionFilePolicyValidation(t *testing.T) {
+	t.Parallel()
+	tests := map[string]string{
+		"invalid pattern":   "promotionFilePolicy:\n  allowedFilePatterns: [\"[*.yaml\"]\n",
+		"negative max size": "promotionFilePolicy:\n  maxFileSizeMB: -1\n",
+	}
+	for name, configYaml := range tests {
+		configYaml := configYaml
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := ParseConfigFromYaml(configYaml); err == nil {
+				t.Error("expected a validation error")
+			}
+		})
+	}
+}
+
+func TestSecretScanningValidation(t *testing.T) {
+	t.Parallel()
+	tests := map[string]string{
+		"unknown mode":        "secretScanning:\n  mode: fail\n",
+		"rule without a name": "secretScanning:\n  mode: warn\n  rules:\n    - regex: secret\n",
+		"invalid rule regex":  "secretScanning:\n  mode: block\n  rules:\n    - name: internal\n      regex: \"tok(\"\n",
+	}
+	for name, configYaml := range tests {
+		configYaml := configYaml
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := ParseConfigFromYaml(configYaml); err == nil {
+				t.Error("expected a validation error")
+			}
+		})
+	}
+}
+
+func TestPromotionPrRoutingValidation(t *testing.T) {
+	t.Parallel()
+	_, err := ParseConfigFromYaml("promotionPrRouting:\n  - targetPathRegex: ^env/(prod\n    teamReviewers: [sre]\n")
+	if err == nil {
+		t.Error("expected a validation error")
+	}
+}
+
+func TestPushActionsValidation(t *testing.T) {
+	t.Parallel()
+	_, err := ParseConfigFromYaml("pushActions:\n  - pathRegex: ^env/(prod\n    argocdHardRefresh: true\n")
+	if err == nil {
+		t.Error("expected a validation error")
+	}
+}
+
+func TestPromotionStepsValidation(t *testing.T) {
+	t.Parallel()
+	tests := map[string]string{
+		"decreasing percent":  "promotionPaths:\n  - sourcePath: env/staging/\n    promotionPrs:\n      - targetPaths: [env/prod/a/, env/prod/b/]\n        steps: [{percent: 50}, {percent: 20}]\n",
+		"percent above 100":   "promotionPaths:\n  - sourcePath: env/staging/\n    promotionPrs:\n      - targetPaths: [env/prod/a/, env/prod/b/]\n        steps: [{percent: 150}]\n",
+		"perPath environment": "environments:\n  - name: staging\n    paths: [env/staging/]\n  - name: prod\n    paths: [env/prod/a/, env/prod/b/]\n    fanOut: perPath\n    steps: [{percent: 50}]\n",
+	}
+	for name, configYaml := range tests {
+		configYaml := configYaml
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := ParseConfigFromYaml(configYaml); err == nil {
+				t.Error("expected a validation error")
+			}
+		})
+	}
+}
+
+func TestComponentDependenciesValidation(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		configYaml    string
+		expectedError bool
+	}{
+		"valid":             {configYaml: "componentDependencies:\n  - component: controller\n    dependsOn: [crds]\n  - component: app\n    dependsOn: [controller, crds]\n"},
+		"missing component": {configYaml: "componentDependencies:\n  - dependsOn: [crds]\n", expectedError: true},
+		"cycle":             {configYaml: "componentDependencies:\n  - component: a\n    dependsOn: [b]\n  - component: b\n    dependsOn: [c]\n  - component: c\n    dependsOn: [a]\n", expectedError: true},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			_, err := ParseConfigFromYaml(tc.configYaml)
+			if tc.expectedError && err == nil {
+				t.Error("expected a validation error")
+			}
+			if !tc.expectedError && err != nil {
+				t.Errorf("unexpected validation error: %v", err)
+			}
+		})
+	}
+}
+
+func TestShadowModeImpliesDryRunMode(t *testing.T) {
+	t.Parallel()
+	config, err := ParseConfigFromYaml("shadowMode: true\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !config.DryRunMode {
+		t.Error("expected shadowMode to enable dryRunMode")
+	}
+}
+
+func TestUnknownKeysRejected(t *testing.T) {
+	t.Parallel()
+	_, err := ParseConfigFromYaml("argocd:\n  commentDiffonPR: true\n  autoMergeNoDiffPrs: true\nmystery: 1\n")
+	if err == nil {
+		t.Fatal("expected unknown keys to be rejected")
+	}
+	expected := "yaml: unmarshal errors:\n" +
+		"  line 3: field autoMergeNoDiffPrs not found in type configuration.ArgocdConfig, did you mean autoMergeNoDiffPRs?\n" +
+		"  line 4: field mystery not found in type configuration.Config"
+	if err.Error() != expected {
+		t.Errorf("unexpected error:\n%s\nexpected:\n%s", err, expected)
+	}
+}
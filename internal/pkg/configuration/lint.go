@@ -0,0 +1,235 @@
+package configuration
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+const (
+	// The configuration is rejected(or parts of it never work)
+	LintError = "error"
+	// The configuration is accepted but probably doesn't do what was intended
+	LintWarning = "warning"
+)
+
+var (
+	// YAML errors name their line, e.g. "yaml: line 3: mapping values are not allowed in this context"
+	lintLineRegex = regexp.MustCompile(`\bline (\d+)\b`)
+	// Removed from the messages, LintProblem.Line has it
+	lintLinePrefixRegex = regexp.MustCompile(`^(yaml: )?line \d+: `)
+	// The configuration path validation errors start with, e.g. promotionPaths[0].promotionPrs[1].steps[0]
+	lintPathRegex = regexp.MustCompile(`^[A-Za-z]+(\[\d+\])?(\.[A-Za-z]+(\[\d+\])?)*`)
+)
+
+// LintProblem is a problem found by Lint, Line is 0 when it can't be attributed to a line
+type LintProblem struct {
+	Line     int
+	Severity string
+	Message  string
+}
+
+// Lint checks a telefonistka.yaml: the validation ParseConfigFromYaml does(reported per unknown key), plus the mistakes it accepts(invalid source path regexes,
+// unreachable promotion paths, promotion PRs without targets and diffProviders regexes overlapping on the configured paths)
+func Lint(content string) []LintProblem {
+	var root yaml3.Node
+	if err := yaml3.Unmarshal([]byte(content), &root); err != nil {
+		return []LintProblem{{Line: lintErrorLine(nil, err.Error()), Severity: LintError, Message: lintMessage(err.Error())}}
+	}
+	problems := []LintProblem{}
+	config, err := ParseConfigFromYaml(content)
+	if err != nil {
+		messages := []string{err.Error()}
+		// One problem per unknown key or type error
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			messages = typeErr.Errors
+		}
+		for _, message := range messages {
+			problems = append(problems, LintProblem{Line: lintErrorLine(&root, message), Severity: LintError, Message: lintMessage(message)})
+		}
+		return sortLintProblems(problems)
+	}
+	problems = append(problems, config.lintPromotionPaths(&root)...)
+	problems = append(problems, config.lintDiffProviders(&root)...)
+	return sortLintProblems(problems)
+}
+
+func sortLintProblems(problems []LintProblem) []LintProblem {
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Line < problems[j].Line })
+	return problems
+}
+
+func lintMessage(message string) string {
+	return lintLinePrefixRegex.ReplaceAllString(message, "")
+}
+
+// lintErrorLine returns the line a YAML or validation error refers to, the line it names or the line of the configuration path it starts with
+func lintErrorLine(root *yaml3.Node, message string) int {
+	if match := lintLineRegex.FindStringSubmatch(message); match != nil {
+		line, _ := strconv.Atoi(match[1])
+		return line
+	}
+	if configPath := lintPathRegex.FindString(message); configPath != "" && root != nil {
+		return nodeLine(root, configPath)
+	}
+	return 0
+}
+
+// nodeLine returns the line of a configuration path(e.g. promotionPaths[0].sourcePath), or of its deepest existing parent
+func nodeLine(root *yaml3.Node, configPath string) int {
+	node := root
+	if node.Kind == yaml3.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	line := 0
+	for _, part := range strings.Split(configPath, ".") {
+		key, index := part, -1
+		if open := strings.Index(part, "["); open >= 0 {
+			key = part[:open]
+			index, _ = strconv.Atoi(strings.TrimSuffix(part[open+1:], "]"))
+		}
+		if node.Kind != yaml3.MappingNode {
+			return line
+		}
+		var value *yaml3.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				line = node.Content[i].Line
+				value = node.Content[i+1]
+				break
+			}
+		}
+		if value == nil {
+			return line
+		}
+		node = value
+		if index >= 0 {
+			if node.Kind != yaml3.SequenceNode || index >= len(node.Content) {
+				return line
+			}
+			node = node.Content[index]
+			line = node.Line
+		}
+	}
+	return line
+}
+
+// promotionPathOrigins returns the configuration path of the source path of each PromotionPaths entry, the explicit ones come before the ones of the environments
+func (c *Config) promotionPathOrigins() []string {
+	origins := []string{}
+	explicit := len(c.PromotionPaths) - len(c.environmentPromotionPaths())
+	for i := 0; i < explicit; i++ {
+		origins = append(origins, fmt.Sprintf("promotionPaths[%d].sourcePath", i))
+	}
+	for i := 0; i < len(c.Environments)-1; i++ {
+		for j := range c.Environments[i].Paths {
+			origins = append(origins, fmt.Sprintf("environments[%d].paths[%d]", i, j))
+		}
+	}
+	return origins
+}
+
+// componentSourcePath returns the source path a changed file is promoted from by promotionPath, it mirrors how the promotion plan finds the components of the changed files
+func componentSourcePath(promotionPath PromotionPath, changedFile string) string {
+	componentPathRegexSubStrings := []string{}
+	for i := 0; i <= promotionPath.ComponentPathExtraDepth; i++ {
+		componentPathRegexSubStrings = append(componentPathRegexSubStrings, "[^/]*")
+	}
+	componentRegex, err := regexp.Compile("^" + promotionPath.SourcePath + "(" + strings.Join(componentPathRegexSubStrings, "/") + ")/.*")
+	if err != nil {
+		return ""
+	}
+	componentName := componentRegex.ReplaceAllString(changedFile, "${1}")
+	sourcePathRegex, err := regexp.Compile("^(" + promotionPath.SourcePath + ")" + regexp.QuoteMeta(componentName) + "/.*")
+	if err != nil {
+		return ""
+	}
+	return sourcePathRegex.ReplaceAllString(changedFile, "${1}")
+}
+
+// lintPromotionPaths reports invalid source path regexes, promotion PRs without targets and literal source paths whose files are matched by an earlier promotion path
+// that doesn't promote them(a changed file only belongs to the first promotion path matching it)
+func (c *Config) lintPromotionPaths(root *yaml3.Node) []LintProblem {
+	problems := []LintProblem{}
+	origins := c.promotionPathOrigins()
+	for j, promotionPath := range c.PromotionPaths {
+		sourcePathRegex, err := regexp.Compile(promotionPath.SourcePath)
+		if err != nil {
+			problems = append(problems, LintProblem{Line: nodeLine(root, origins[j]), Severity: LintError, Message: fmt.Sprintf("%s is an invalid regex: %v", origins[j], err)})
+			continue
+		}
+		for k, promotionPr := range promotionPath.PromotionPrs {
+			if len(promotionPr.TargetPaths) == 0 {
+				configPath := fmt.Sprintf("promotionPaths[%d].promotionPrs[%d]", j, k)
+				problems = append(problems, LintProblem{Line: nodeLine(root, configPath), Severity: LintWarning, Message: configPath + " has no targetPaths, it never opens a PR"})
+			}
+		}
+		literal, complete := sourcePathRegex.LiteralPrefix()
+		if !complete || literal == "" {
+			continue
+		}
+		changedFile := strings.TrimSuffix(literal, "/") + "/component/file.yaml"
+		for i := 0; i < j; i++ {
+			if match, _ := regexp.MatchString("^"+c.PromotionPaths[i].SourcePath+".*", changedFile); !match {
+				continue
+			}
+			if match, _ := regexp.MatchString(promotionPath.SourcePath, componentSourcePath(c.PromotionPaths[i], changedFile)); !match {
+				problems = append(problems, LintProblem{
+					Line:     nodeLine(root, origins[j]),
+					Severity: LintWarning,
+					Message:  fmt.Sprintf("%s %q is unreachable, its files are matched by the earlier %s %q first", origins[j], promotionPath.SourcePath, origins[i], c.PromotionPaths[i].SourcePath),
+				})
+			}
+			break
+		}
+	}
+	return problems
+}
+
+// lintDiffProviders reports diffProviders entries matching the same configured path(a source or target path) with different providers, only the first one is used
+func (c *Config) lintDiffProviders(root *yaml3.Node) []LintProblem {
+	paths := []string{}
+	for _, promotionPath := range c.PromotionPaths {
+		if sourcePathRegex, err := regexp.Compile(promotionPath.SourcePath); err == nil {
+			if literal, complete := sourcePathRegex.LiteralPrefix(); complete && literal != "" {
+				paths = append(paths, literal)
+			}
+		}
+		for _, promotionPr := range promotionPath.PromotionPrs {
+			paths = append(paths, promotionPr.TargetPaths...)
+		}
+	}
+	problems := []LintProblem{}
+	reported := map[int]bool{}
+	for _, p := range paths {
+		componentPath := strings.TrimSuffix(p, "/") + "/component"
+		first := -1
+		for j, diffProvider := range c.DiffProviders {
+			if match, _ := regexp.MatchString(diffProvider.PathRegex, componentPath); !match {
+				continue
+			}
+			if first < 0 {
+				first = j
+				continue
+			}
+			if reported[j] || diffProvider.Provider == c.DiffProviders[first].Provider {
+				continue
+			}
+			reported[j] = true
+			configPath := fmt.Sprintf("diffProviders[%d]", j)
+			problems = append(problems, LintProblem{
+				Line:     nodeLine(root, configPath+".pathRegex"),
+				Severity: LintWarning,
+				Message:  fmt.Sprintf("%s pathRegex overlaps diffProviders[%d] on %s, its components use the first one(%s)", configPath, first, p, c.DiffProviders[first].Provider),
+			})
+		}
+	}
+	return problems
+}
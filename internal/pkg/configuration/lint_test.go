@@ -0,0 +1,60 @@
+package configuration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLint(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		content  string
+		expected []LintProblem
+	}{
+		"Valid": {
+			content:  "promotionPaths:\n  - sourcePath: env/staging/\n    promotionPrs:\n      - targetPaths: [env/prod/]\n",
+			expected: []LintProblem{},
+		},
+		"YAML syntax error": {
+			content:  "dryRunMode: true\nshadowMode: true: false\n",
+			expected: []LintProblem{{Line: 2, Severity: LintError, Message: "mapping values are not allowed in this context"}},
+		},
+		"Unknown key": {
+			content:  "promotionPaths:\n  - sourcePath: env/staging/\n    promotionPRs:\n      - targetPaths: [env/prod/]\n",
+			expected: []LintProblem{{Line: 3, Severity: LintError, Message: "field promotionPRs not found in type configuration.PromotionPath, did you mean promotionPrs?"}},
+		},
+		"Validation error": {
+			content:  "diffProviders:\n  - pathRegex: ^env/\n    provider: helm\n  - pathRegex: ^env/\n    provider: jsonnet\n",
+			expected: []LintProblem{{Line: 4, Severity: LintError, Message: `diffProviders[1] has an unknown provider "jsonnet"`}},
+		},
+		"Invalid source path regex": {
+			content:  "promotionPaths:\n  - sourcePath: env/(staging/\n    promotionPrs:\n      - targetPaths: [env/prod/]\n",
+			expected: []LintProblem{{Line: 2, Severity: LintError, Message: "promotionPaths[0].sourcePath is an invalid regex: error parsing regexp: missing closing ): `env/(staging/`"}},
+		},
+		"Promotion PR without targets": {
+			content:  "promotionPaths:\n  - sourcePath: env/staging/\n    promotionPrs:\n      - targetDescription: prod\n",
+			expected: []LintProblem{{Line: 4, Severity: LintWarning, Message: "promotionPaths[0].promotionPrs[0] has no targetPaths, it never opens a PR"}},
+		},
+		"Unreachable promotion path": {
+			content:  "promotionPaths:\n  - sourcePath: env/\n    promotionPrs:\n      - targetPaths: [prod/]\n  - sourcePath: env/staging/\n    promotionPrs:\n      - targetPaths: [prod/]\n",
+			expected: []LintProblem{{Line: 5, Severity: LintWarning, Message: `promotionPaths[1].sourcePath "env/staging/" is unreachable, its files are matched by the earlier promotionPaths[0].sourcePath "env/" first`}},
+		},
+		"Same source path with other conditions is reachable": {
+			content:  "promotionPaths:\n  - sourcePath: env/staging/\n    conditions:\n      prHasLabels: [hotfix]\n    promotionPrs:\n      - targetPaths: [env/prod/]\n  - sourcePath: env/staging/\n    promotionPrs:\n      - targetPaths: [env/qa/]\n",
+			expected: []LintProblem{},
+		},
+		"Overlapping diff providers": {
+			content: "environments:\n  - name: staging\n    paths: [env/staging/]\n  - name: prod\n    paths: [env/prod/]\n" +
+				"diffProviders:\n  - pathRegex: ^env/\n    provider: helm\n  - pathRegex: ^env/prod/\n    provider: kustomize\n  - pathRegex: ^env/prod/\n    provider: helm\n",
+			expected: []LintProblem{{Line: 9, Severity: LintWarning, Message: "diffProviders[1] pathRegex overlaps diffProviders[0] on env/prod/, its components use the first one(helm)"}},
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, Lint(tc.content))
+		})
+	}
+}
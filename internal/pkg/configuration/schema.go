@@ -0,0 +1,104 @@
+package configuration
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// JSONSchema returns the JSON Schema of telefonistka.yaml, it's generated from Config so it always matches what the parser accepts.
+// Editors(e.g. with a "# yaml-language-server: $schema=<url>" comment) use it to validate and complete the configuration
+func JSONSchema() ([]byte, error) {
+	g := schemaGenerator{defs: map[string]any{}}
+	schema := g.typeSchema(reflect.TypeOf(Config{}))
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "Telefonistka in-repo configuration(telefonistka.yaml)"
+	schema["$defs"] = g.defs
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// yamlFieldName returns the key of a struct field, empty for fields the parser skips
+func yamlFieldName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		// The yaml package default
+		return strings.ToLower(field.Name)
+	}
+	return name
+}
+
+// schemaGenerator collects the schemas of the nested structs as definitions, referenced by their type name
+type schemaGenerator struct {
+	defs map[string]any
+}
+
+func (g schemaGenerator) typeSchema(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.typeSchema(t.Elem())
+	case reflect.Struct:
+		return g.structSchema(t)
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.typeSchema(t.Elem())}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	}
+	return map[string]any{}
+}
+
+// structSchema returns the schema of the root struct, nested structs are referenced from the definitions
+func (g schemaGenerator) structSchema(t reflect.Type) map[string]any {
+	if t != reflect.TypeOf(Config{}) {
+		if _, ok := g.defs[t.Name()]; !ok {
+			// Set first, so recursive types reference themselves instead of looping
+			g.defs[t.Name()] = nil
+			g.defs[t.Name()] = g.objectSchema(t)
+		}
+		return map[string]any{"$ref": "#/$defs/" + t.Name()}
+	}
+	return g.objectSchema(t)
+}
+
+func (g schemaGenerator) objectSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	for i := 0; i < t.NumField(); i++ {
+		if name := yamlFieldName(t.Field(i)); name != "" {
+			properties[name] = g.typeSchema(t.Field(i).Type)
+		}
+	}
+	// Unknown keys are rejected by the parser
+	return map[string]any{"type": "object", "properties": properties, "additionalProperties": false}
+}
+
+// knownFields returns the keys of each struct type reachable from t, by type name
+func knownFields(t reflect.Type, fields map[string][]string) {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		knownFields(t.Elem(), fields)
+	case reflect.Struct:
+		if _, ok := fields[t.Name()]; ok {
+			return
+		}
+		fields[t.Name()] = []string{}
+		for i := 0; i < t.NumField(); i++ {
+			if name := yamlFieldName(t.Field(i)); name != "" {
+				fields[t.Name()] = append(fields[t.Name()], name)
+				knownFields(t.Field(i).Type, fields)
+			}
+		}
+	}
+}
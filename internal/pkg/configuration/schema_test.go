@@ -0,0 +1,35 @@
+package configuration
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONSchema(t *testing.T) {
+	t.Parallel()
+	content, err := JSONSchema()
+	if err != nil {
+		t.Fatalf("JSONSchema: %v", err)
+	}
+	var schema struct {
+		Properties           map[string]map[string]any `json:"properties"`
+		AdditionalProperties bool                      `json:"additionalProperties"`
+		Defs                 map[string]struct {
+			Properties           map[string]map[string]any `json:"properties"`
+			AdditionalProperties bool                      `json:"additionalProperties"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(content, &schema); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	assert.False(t, schema.AdditionalProperties)
+	assert.Equal(t, map[string]any{"type": "boolean"}, schema.Properties["dryRunMode"])
+	assert.Equal(t, map[string]any{"type": "array", "items": map[string]any{"$ref": "#/$defs/PromotionPath"}}, schema.Properties["promotionPaths"])
+	assert.Equal(t, map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "string"}}, schema.Properties["toggleCommitStatus"])
+	assert.Equal(t, map[string]any{"$ref": "#/$defs/ArgocdConfig"}, schema.Properties["argocd"])
+	assert.False(t, schema.Defs["ArgocdConfig"].AdditionalProperties)
+	assert.Equal(t, map[string]any{"type": "boolean"}, schema.Defs["ArgocdConfig"].Properties["autoMergeNoDiffPRs"])
+	assert.Equal(t, map[string]any{"type": "integer"}, schema.Defs["PromotionPath"].Properties["componentPathExtraDepth"])
+}
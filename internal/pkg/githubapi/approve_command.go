@@ -0,0 +1,90 @@
+package githubapi
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v62/github"
+	log "github.com/sirupsen/logrus"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+)
+
+const approveCommand = "/telefonistka approve"
+
+// commentHasCommand checks the comment has a line with just the command
+func commentHasCommand(commentBody string, command string) bool {
+	for _, line := range strings.Split(commentBody, "\n") {
+		if strings.TrimSpace(line) == command {
+			return true
+		}
+	}
+	return false
+}
+
+// isApproveCommand checks the comment has a "/telefonistka approve" line
+func isApproveCommand(commentBody string) bool {
+	return commentHasCommand(commentBody, approveCommand)
+}
+
+// isApproveCommandAuthorized checks the commenter is one of the approveCommand users or an active member of one of its teams
+func isApproveCommandAuthorized(ghPrClientDetails GhPrClientDetails, approveCommandConfig cfg.ApproveCommandConfig, commenter string) bool {
+	for _, user := range approveCommandConfig.Users {
+		if strings.EqualFold(strings.TrimPrefix(user, "@"), commenter) {
+			return true
+		}
+	}
+	for _, team := range approveCommandConfig.Teams {
+		if isActiveTeamMember(ghPrClientDetails, normalizeTeamSlug(team), commenter) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleApproveCommand approves(with the approver client) and merges a promotion PR on behalf of an authorized commenter, so they don't need merge rights on the repo.
+// Every attempt is logged with the audit field set, the approving review and merge commit record the commenter as well
+func handleApproveCommand(ghPrClientDetails GhPrClientDetails, config *cfg.Config, prApproverGithubClient *github.Client, labels []*github.Label, commenter string) {
+	auditLogger := ghPrClientDetails.PrLogger.WithFields(log.Fields{"audit": true, "action": "approve_command", "actor": commenter})
+	if len(config.ApproveCommand.Users) == 0 && len(config.ApproveCommand.Teams) == 0 {
+		_ = commentPR(ghPrClientDetails, "Approving PRs with a comment is not enabled in this repo(`approveCommand`)")
+		return
+	}
+	if !DoesPrHasLabel(labels, "promotion") {
+		_ = commentPR(ghPrClientDetails, fmt.Sprintf("@%s only promotion PRs can be approved with `%s`", commenter, approveCommand))
+		return
+	}
+	if !isApproveCommandAuthorized(ghPrClientDetails, config.ApproveCommand, commenter) {
+		auditLogger.Warnf("Refusing approve command of %s on PR %d, the user isn't authorized", commenter, ghPrClientDetails.PrNumber)
+		_ = commentPR(ghPrClientDetails, fmt.Sprintf("@%s you are not allowed to approve promotion PRs with `%s`(`approveCommand`)", commenter, approveCommand))
+		return
+	}
+	auditLogger.Infof("Approving and merging PR %d on behalf of %s", ghPrClientDetails.PrNumber, commenter)
+	onBehalf := fmt.Sprintf("Approved on behalf of @%s(`%s`)", commenter, approveCommand)
+	err := approvePrWithBody(prApproverGithubClient, ghPrClientDetails, &ghPrClientDetails.PrNumber, onBehalf)
+	if err != nil {
+		auditLogger.Errorf("Approving PR %d failed: err=%v", ghPrClientDetails.PrNumber, err)
+		_ = commentPR(ghPrClientDetails, fmt.Sprintf("@%s approving this PR failed\n```\n%s\n```\n", commenter, err))
+		return
+	}
+	paths, err := generateListOfChangedComponentPaths(ghPrClientDetails, config)
+	if err != nil {
+		auditLogger.Errorf("Failed to get the changed components of PR %d: err=%v", ghPrClientDetails.PrNumber, err)
+		return
+	}
+	// Required approvers still need to approve, the PR is merged once they do
+	approved, err := checkRequiredApprovals(ghPrClientDetails, config, ghPrClientDetails.PrNumber, paths)
+	if err != nil {
+		auditLogger.Errorf("Failed to check the required approvals of PR %d: err=%v", ghPrClientDetails.PrNumber, err)
+		return
+	}
+	if !approved {
+		return
+	}
+	err = mergePrWithMessage(ghPrClientDetails, &ghPrClientDetails.PrNumber, onBehalf)
+	if err != nil {
+		auditLogger.Errorf("Merging PR %d failed: err=%v", ghPrClientDetails.PrNumber, err)
+		_ = commentPR(ghPrClientDetails, fmt.Sprintf("@%s the PR was approved but merging it failed\n```\n%s\n```\n", commenter, err))
+		return
+	}
+	auditLogger.Infof("PR %d was approved and merged on behalf of %s", ghPrClientDetails.PrNumber, commenter)
+}
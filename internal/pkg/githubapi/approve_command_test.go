@@ -0,0 +1,112 @@
+package githubapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+)
+
+func TestIsApproveCommand(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		commentBody string
+		expected    bool
+	}{
+		"command only":     {commentBody: "/telefonistka approve", expected: true},
+		"command in reply": {commentBody: "LGTM, the canary is healthy\n/telefonistka approve\n", expected: true},
+		"mentioned inline": {commentBody: "Please /telefonistka approve this", expected: false},
+		"other command":    {commentBody: "/telefonistka drift", expected: false},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, isApproveCommand(tc.commentBody))
+		})
+	}
+}
+
+func approveCommandTestClientDetails(mockedHTTPClient *http.Client) GhPrClientDetails {
+	ghPrClientDetails := GhPrClientDetails{
+		Ctx:          context.Background(),
+		GhClientPair: &GhClientPair{v3Client: github.NewClient(mockedHTTPClient)},
+		Owner:        "AnOwner",
+		Repo:         "Arepo",
+		PrNumber:     7,
+		PrLogger:     log.WithFields(log.Fields{"repo": "AnOwner/Arepo", "prNumber": 7}),
+	}
+	ghPrClientDetails.PrMetadata.PromotedPaths = []string{"env/prod/app"}
+	return ghPrClientDetails
+}
+
+func TestHandleApproveCommandApprovesAndMerges(t *testing.T) {
+	t.Parallel()
+	var review github.PullRequestReviewRequest
+	var merge struct {
+		CommitMessage string `json:"commit_message"`
+	}
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.PostReposPullsReviewsByOwnerByRepoByPullNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_ = json.NewDecoder(r.Body).Decode(&review)
+				_, _ = w.Write(mock.MustMarshal(github.PullRequestReview{}))
+			}),
+		),
+		mock.WithRequestMatchHandler(
+			mock.PutReposPullsMergeByOwnerByRepoByPullNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_ = json.NewDecoder(r.Body).Decode(&merge)
+				_, _ = w.Write(mock.MustMarshal(github.PullRequestMergeResult{Merged: github.Bool(true)}))
+			}),
+		),
+	)
+	ghPrClientDetails := approveCommandTestClientDetails(mockedHTTPClient)
+	config := &cfg.Config{ApproveCommand: cfg.ApproveCommandConfig{Users: []string{"@Alice"}}}
+
+	handleApproveCommand(ghPrClientDetails, config, github.NewClient(mockedHTTPClient), []*github.Label{{Name: github.String("promotion")}}, "alice")
+
+	assert.Equal(t, "APPROVE", review.GetEvent())
+	assert.Contains(t, review.GetBody(), "on behalf of @alice")
+	assert.Contains(t, merge.CommitMessage, "on behalf of @alice")
+}
+
+func TestHandleApproveCommandRefusesUnauthorizedUsers(t *testing.T) {
+	t.Parallel()
+	var commentBody string
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetOrgsTeamsMembershipsByOrgByTeamSlugByUsername,
+			github.Membership{State: github.String("pending")},
+		),
+		mock.WithRequestMatchHandler(
+			mock.PostReposIssuesCommentsByOwnerByRepoByIssueNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				comment := github.IssueComment{}
+				_ = json.NewDecoder(r.Body).Decode(&comment)
+				commentBody = comment.GetBody()
+				_, _ = w.Write(mock.MustMarshal(comment))
+			}),
+		),
+		mock.WithRequestMatchHandler(
+			mock.PostReposPullsReviewsByOwnerByRepoByPullNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				t.Error("an unauthorized user shouldn't get the PR approved")
+				_, _ = w.Write(mock.MustMarshal(github.PullRequestReview{}))
+			}),
+		),
+	)
+	ghPrClientDetails := approveCommandTestClientDetails(mockedHTTPClient)
+	config := &cfg.Config{ApproveCommand: cfg.ApproveCommandConfig{Users: []string{"alice"}, Teams: []string{"AnOwner/sre"}}}
+
+	handleApproveCommand(ghPrClientDetails, config, github.NewClient(mockedHTTPClient), []*github.Label{{Name: github.String("promotion")}}, "mallory")
+
+	assert.Contains(t, commentBody, "@mallory you are not allowed to approve")
+}
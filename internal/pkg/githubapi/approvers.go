@@ -0,0 +1,239 @@
+package githubapi
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v62/github"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+	"golang.org/x/exp/maps"
+)
+
+// Added to PRs whose auto-merge waits for required approvals, the PR is merged by the review event that completes them
+const pendingApprovalsLabel = "auto-merge-pending-approvals"
+
+// requiredApprovers holds the (deduplicated) users and team slugs that need to approve a PR before Telefonistka can auto-merge it
+type requiredApprovers struct {
+	Users []string
+	Teams []string
+}
+
+func (ra requiredApprovers) isEmpty() bool {
+	return len(ra.Users) == 0 && len(ra.Teams) == 0
+}
+
+// normalizeTeamSlug allows the config to reference teams as "team-slug", "org/team-slug" or "@org/team-slug"(CODEOWNERS style)
+// GitHub API calls expect just the slug, and the team must belong to the repo owner org.
+func normalizeTeamSlug(team string) string {
+	team = strings.TrimPrefix(team, "@")
+	if i := strings.LastIndex(team, "/"); i != -1 {
+		team = team[i+1:]
+	}
+	return team
+}
+
+// generateRequiredApprovers returns the approvers required for any of the provided paths, based on the requiredApprovers in-repo configuration.
+func generateRequiredApprovers(config *cfg.Config, paths []string) requiredApprovers {
+	users := map[string]bool{}
+	teams := map[string]bool{}
+	for _, ra := range config.RequiredApprovers {
+		r, err := regexp.Compile(ra.TargetPathRegex)
+		if err != nil {
+			// An invalid regex should not silently remove approval requirements, so we treat it as matching everything.
+			r = regexp.MustCompile(".*")
+		}
+		for _, p := range paths {
+			if r.MatchString(p) {
+				for _, u := range ra.Users {
+					users[strings.TrimPrefix(u, "@")] = true
+				}
+				for _, t := range ra.Teams {
+					teams[normalizeTeamSlug(t)] = true
+				}
+				break
+			}
+		}
+	}
+	return sortedApprovers(users, teams)
+}
+
+// sortedApprovers converts user and team sets to requiredApprovers
+func sortedApprovers(users map[string]bool, teams map[string]bool) requiredApprovers {
+	result := requiredApprovers{
+		Users: maps.Keys(users),
+		Teams: maps.Keys(teams),
+	}
+	sort.Strings(result.Users)
+	sort.Strings(result.Teams)
+	return result
+}
+
+// requestRequiredReviews asks the required users and teams to review the PR
+func requestRequiredReviews(ghPrClientDetails GhPrClientDetails, prNumber int, ra requiredApprovers) error {
+	if ra.isEmpty() {
+		return nil
+	}
+	reviewersRequest := github.ReviewersRequest{
+		Reviewers:     ra.Users,
+		TeamReviewers: ra.Teams,
+	}
+	_, resp, err := retryGhWrite(ghPrClientDetails.Ctx, "request_reviewers", func() (*github.PullRequest, *github.Response, error) {
+		return ghPrClientDetails.GhClientPair.v3Client.PullRequests.RequestReviewers(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, prNumber, reviewersRequest)
+	})
+	if err != nil {
+		ghPrClientDetails.PrLogger.Errorf("Could not request reviews on PR %d: err=%s\n%v\n", prNumber, err, resp)
+		return err
+	}
+	ghPrClientDetails.PrLogger.Infof("Requested reviews from users %v and teams %v on PR %d", ra.Users, ra.Teams, prNumber)
+	return nil
+}
+
+// approvingReviewers returns the (lower case) logins of users whose latest state-changing review is an approval.
+// Reviews are expected in chronological order, as returned by the GitHub API.
+func approvingReviewers(reviews []*github.PullRequestReview) map[string]bool {
+	approvers := map[string]bool{}
+	for _, review := range reviews {
+		login := strings.ToLower(review.GetUser().GetLogin())
+		switch review.GetState() {
+		case "APPROVED":
+			approvers[login] = true
+		case "CHANGES_REQUESTED", "DISMISSED":
+			delete(approvers, login)
+		}
+	}
+	return approvers
+}
+
+func listPrReviews(ghPrClientDetails GhPrClientDetails, prNumber int) ([]*github.PullRequestReview, error) {
+	opts := &github.ListOptions{}
+	reviews, _, err := listAllPages(opts, func() ([]*github.PullRequestReview, *github.Response, error) {
+		return ghPrClientDetails.GhClientPair.v3Client.PullRequests.ListReviews(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, prNumber, opts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list reviews of PR %d: %w", prNumber, err)
+	}
+	return reviews, nil
+}
+
+func isActiveTeamMember(ghPrClientDetails GhPrClientDetails, teamSlug string, user string) bool {
+	membership, resp, err := ghPrClientDetails.GhClientPair.v3Client.Teams.GetTeamMembershipBySlug(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, teamSlug, user)
+	prom.InstrumentGhCall(resp)
+	if err != nil {
+		ghPrClientDetails.PrLogger.Debugf("%s is not a member of team %s: %v", user, teamSlug, err)
+		return false
+	}
+	return membership.GetState() == "active"
+}
+
+// missingRequiredApprovals returns the list of required users and teams(prefixed with the repo owner) that didn't approve the PR yet.
+// A team requirement is fulfilled by an approval of any active team member.
+func missingRequiredApprovals(ghPrClientDetails GhPrClientDetails, prNumber int, ra requiredApprovers) ([]string, error) {
+	missing := []string{}
+	if ra.isEmpty() {
+		return missing, nil
+	}
+	reviews, err := listPrReviews(ghPrClientDetails, prNumber)
+	if err != nil {
+		return nil, err
+	}
+	approvers := approvingReviewers(reviews)
+
+	for _, u := range ra.Users {
+		if !approvers[strings.ToLower(u)] {
+			missing = append(missing, u)
+		}
+	}
+
+	for _, t := range ra.Teams {
+		teamApproved := false
+		for approver := range approvers {
+			if isActiveTeamMember(ghPrClientDetails, t, approver) {
+				teamApproved = true
+				break
+			}
+		}
+		if !teamApproved {
+			missing = append(missing, ghPrClientDetails.Owner+"/"+t)
+		}
+	}
+	return missing, nil
+}
+
+// checkRequiredApprovals is used before auto-merging a PR, it returns false(and comments on the PR) if some of the required approvals are missing.
+// The PR is labeled so the auto-merge is retried when its reviews are submitted.
+func checkRequiredApprovals(ghPrClientDetails GhPrClientDetails, config *cfg.Config, prNumber int, paths []string) (bool, error) {
+	ra := generateRequiredApprovers(config, paths)
+	missing, err := missingRequiredApprovals(ghPrClientDetails, prNumber, ra)
+	if err != nil {
+		return false, err
+	}
+	if len(missing) > 0 {
+		ghPrClientDetails.PrLogger.Infof("Postponing auto-merge of PR %d, missing required approvals from: %v", prNumber, missing)
+		_, resp, err := retryGhWrite(ghPrClientDetails.Ctx, "add_labels", func() ([]*github.Label, *github.Response, error) {
+			return ghPrClientDetails.GhClientPair.v3Client.Issues.AddLabelsToIssue(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, prNumber, []string{pendingApprovalsLabel})
+		})
+		if err != nil {
+			ghPrClientDetails.PrLogger.Errorf("Could not label PR %d as pending approvals: err=%s\n%v\n", prNumber, err, resp)
+		}
+		commentDetails := ghPrClientDetails
+		commentDetails.PrNumber = prNumber
+		_ = commentDetails.CommentOnPr(fmt.Sprintf("Auto-merge postponed, this PR requires approval from: `%s`. It will be merged once they approve it.", strings.Join(missing, "`, `")))
+		return false, nil
+	}
+	return true, nil
+}
+
+// handlePrReviewEvent merges PRs whose auto-merge was postponed by checkRequiredApprovals once an approval completes their required approvals
+func handlePrReviewEvent(ghPrClientDetails GhPrClientDetails, eventPayload *github.PullRequestReviewEvent) error {
+	if eventPayload.GetAction() != "submitted" || !strings.EqualFold(eventPayload.GetReview().GetState(), "approved") {
+		return nil
+	}
+	if eventPayload.GetPullRequest().GetState() != "open" {
+		return nil
+	}
+	isPromotionPr := DoesPrHasLabel(eventPayload.GetPullRequest().Labels, "promotion")
+	pendingApprovals := DoesPrHasLabel(eventPayload.GetPullRequest().Labels, pendingApprovalsLabel)
+	if !isPromotionPr && !pendingApprovals {
+		return nil
+	}
+	defaultBranch, _ := ghPrClientDetails.GetDefaultBranch()
+	config, err := GetInRepoConfig(ghPrClientDetails, defaultBranch)
+	if err != nil {
+		return err
+	}
+	_ = ghPrClientDetails.getPrMetadata(eventPayload.GetPullRequest().GetBody())
+	if isPromotionPr && config.CommitStatusContexts.Promotion != "" {
+		paths, err := generateListOfChangedComponentPaths(ghPrClientDetails, config)
+		if err != nil {
+			return fmt.Errorf("get list of changed components: %w", err)
+		}
+		if err := setPromotionGateCommitStatus(ghPrClientDetails, config, paths); err != nil {
+			ghPrClientDetails.PrLogger.Errorf("Failed to set the promotion commit status: err=%v", err)
+		}
+	}
+	if !pendingApprovals {
+		return nil
+	}
+	return mergeIfRequiredApprovalsReceived(ghPrClientDetails, config)
+}
+
+func mergeIfRequiredApprovalsReceived(ghPrClientDetails GhPrClientDetails, config *cfg.Config) error {
+	paths, err := generateListOfChangedComponentPaths(ghPrClientDetails, config)
+	if err != nil {
+		return fmt.Errorf("get list of changed components: %w", err)
+	}
+	missing, err := missingRequiredApprovals(ghPrClientDetails, ghPrClientDetails.PrNumber, generateRequiredApprovers(config, paths))
+	if err != nil {
+		return fmt.Errorf("check required approvals: %w", err)
+	}
+	if len(missing) > 0 {
+		ghPrClientDetails.PrLogger.Infof("PR %d is still missing required approvals from: %v", ghPrClientDetails.PrNumber, missing)
+		return nil
+	}
+	ghPrClientDetails.PrLogger.Infof("Required approvals received, auto-merging PR %d", ghPrClientDetails.PrNumber)
+	_ = commentPR(ghPrClientDetails, "All required approvals were received, auto-merging this PR.")
+	return MergePr(ghPrClientDetails, &ghPrClientDetails.PrNumber)
+}
@@ -0,0 +1,164 @@
+package githubapi
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/google/go-github/v62/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+)
+
+func TestGenerateRequiredApprovers(t *testing.T) {
+	t.Parallel()
+	config := &cfg.Config{
+		RequiredApprovers: []cfg.RequiredApprovers{
+			{
+				TargetPathRegex: "^env/prod/.*",
+				Users:           []string{"@alice"},
+				Teams:           []string{"@AnOwner/sre"},
+			},
+			{
+				TargetPathRegex: "^env/prod/us-east4/.*",
+				Users:           []string{"bob", "alice"},
+			},
+			{
+				TargetPathRegex: "^env/staging/.*",
+				Teams:           []string{"qa"},
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		paths    []string
+		expected requiredApprovers
+	}{
+		"No matching path": {
+			paths:    []string{"env/dev/us-east4/c1/component"},
+			expected: requiredApprovers{Users: []string{}, Teams: []string{}},
+		},
+		"Single match": {
+			paths:    []string{"env/staging/us-east4/c1/component"},
+			expected: requiredApprovers{Users: []string{}, Teams: []string{"qa"}},
+		},
+		"Multiple matches are aggregated and deduplicated": {
+			paths:    []string{"env/prod/us-east4/c1/component", "env/prod/eu-west1/c1/component"},
+			expected: requiredApprovers{Users: []string{"alice", "bob"}, Teams: []string{"sre"}},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			result := generateRequiredApprovers(config, tc.paths)
+			if diff := deep.Equal(tc.expected, result); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestApprovingReviewers(t *testing.T) {
+	t.Parallel()
+	review := func(login string, state string) *github.PullRequestReview {
+		return &github.PullRequestReview{User: &github.User{Login: github.String(login)}, State: github.String(state)}
+	}
+	reviews := []*github.PullRequestReview{
+		review("Alice", "APPROVED"),
+		review("bob", "APPROVED"),
+		review("bob", "CHANGES_REQUESTED"),
+		review("carol", "CHANGES_REQUESTED"),
+		review("carol", "APPROVED"),
+		review("carol", "COMMENTED"),
+		review("dave", "COMMENTED"),
+	}
+	expected := map[string]bool{"alice": true, "carol": true}
+	if diff := deep.Equal(expected, approvingReviewers(reviews)); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestHandlePrReviewEventIgnoresOtherReviews(t *testing.T) {
+	t.Parallel()
+	pendingPr := &github.PullRequest{State: github.String("open"), Labels: []*github.Label{{Name: github.String(pendingApprovalsLabel)}}}
+	tests := map[string]*github.PullRequestReviewEvent{
+		"Comment review": {
+			Action:      github.String("submitted"),
+			Review:      &github.PullRequestReview{State: github.String("commented")},
+			PullRequest: pendingPr,
+		},
+		"Dismissed review": {
+			Action:      github.String("dismissed"),
+			Review:      &github.PullRequestReview{State: github.String("approved")},
+			PullRequest: pendingPr,
+		},
+		"PR without pending auto-merge": {
+			Action:      github.String("submitted"),
+			Review:      &github.PullRequestReview{State: github.String("approved")},
+			PullRequest: &github.PullRequest{State: github.String("open")},
+		},
+	}
+	for name, event := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			// No GitHub client, any API call would panic
+			assert.NoError(t, handlePrReviewEvent(GhPrClientDetails{}, event))
+		})
+	}
+}
+
+func TestMergeIfRequiredApprovalsReceived(t *testing.T) {
+	t.Parallel()
+	config := &cfg.Config{RequiredApprovers: []cfg.RequiredApprovers{{TargetPathRegex: "^env/prod/.*", Users: []string{"alice", "bob"}}}}
+	metadata := prMetadata{PromotedPaths: []string{"env/prod/c1"}}
+
+	tests := map[string]struct {
+		reviews        []github.PullRequestReview
+		expectedMerged bool
+	}{
+		"Missing approval": {
+			reviews:        []github.PullRequestReview{{User: &github.User{Login: github.String("alice")}, State: github.String("APPROVED")}},
+			expectedMerged: false,
+		},
+		"All approvals": {
+			reviews: []github.PullRequestReview{
+				{User: &github.User{Login: github.String("alice")}, State: github.String("APPROVED")},
+				{User: &github.User{Login: github.String("Bob")}, State: github.String("APPROVED")},
+			},
+			expectedMerged: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var merged atomic.Bool
+			mockedHTTPClient := mock.NewMockedHTTPClient(
+				mock.WithRequestMatch(mock.GetReposPullsReviewsByOwnerByRepoByPullNumber, tc.reviews),
+				mock.WithRequestMatch(mock.PostReposIssuesCommentsByOwnerByRepoByIssueNumber, github.IssueComment{}),
+				mock.WithRequestMatchHandler(
+					mock.PutReposPullsMergeByOwnerByRepoByPullNumber,
+					http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						merged.Store(true)
+						_, _ = w.Write(mock.MustMarshal(github.PullRequestMergeResult{Merged: github.Bool(true)}))
+					}),
+				),
+			)
+			ghPrClientDetails := GhPrClientDetails{
+				Ctx:          context.Background(),
+				GhClientPair: &GhClientPair{v3Client: github.NewClient(mockedHTTPClient)},
+				Owner:        "AnOwner",
+				Repo:         "Arepo",
+				PrNumber:     7,
+				PrLogger:     log.WithField("test", t.Name()),
+				PrMetadata:   metadata,
+			}
+
+			assert.NoError(t, mergeIfRequiredApprovalsReceived(ghPrClientDetails, config))
+			assert.Equal(t, tc.expectedMerged, merged.Load())
+		})
+	}
+}
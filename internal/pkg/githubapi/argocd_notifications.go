@@ -0,0 +1,228 @@
+package githubapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	lru "github.com/hashicorp/golang-lru/v2"
+	log "github.com/sirupsen/logrus"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/inflight"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/logging"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/maintenance"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/tenancy"
+)
+
+const (
+	degradedHealthStatus           = "Degraded"
+	defaultDegradedPromotionWindow = 60 * time.Minute
+)
+
+// ErrInvalidArgocdNotification is returned(wrapped) when an ArgoCD Notifications webhook payload is missing fields
+var ErrInvalidArgocdNotification = errors.New("invalid ArgoCD notification")
+
+// ArgocdNotification is the body the ArgoCD Notifications webhook template sends, see the docs for the template
+type ArgocdNotification struct {
+	App      string `json:"app"`
+	RepoURL  string `json:"repoURL"`
+	Path     string `json:"path"`
+	Revision string `json:"revision"`
+	Health   string `json:"health"`
+	Message  string `json:"message"`
+}
+
+// repoSlugFromURL returns the owner and name of a GitHub repo HTTPS or SSH URL
+func repoSlugFromURL(repoURL string) (owner string, repo string, ok bool) {
+	slug := strings.TrimSuffix(strings.TrimSuffix(repoURL, "/"), ".git")
+	if _, afterScheme, found := strings.Cut(slug, "://"); found {
+		_, slug, found = strings.Cut(afterScheme, "/")
+		if !found {
+			return "", "", false
+		}
+	} else if _, afterHost, found := strings.Cut(slug, ":"); found {
+		slug = afterHost
+	} else {
+		return "", "", false
+	}
+	owner, repo, ok = strings.Cut(slug, "/")
+	if !ok || owner == "" || repo == "" || strings.Contains(repo, "/") {
+		return "", "", false
+	}
+	return owner, repo, true
+}
+
+// HandleArgocdNotification checks the ArgoCD Notifications payload and, for Degraded apps, looks for the promotion PR that caused it in the background
+func HandleArgocdNotification(notification ArgocdNotification, mainGhClientCache *lru.Cache[string, GhClientPair]) error {
+	if notification.App == "" || notification.Path == "" || notification.Health == "" {
+		return fmt.Errorf("%w: app, path and health are required", ErrInvalidArgocdNotification)
+	}
+	owner, repo, ok := repoSlugFromURL(notification.RepoURL)
+	if !ok {
+		return fmt.Errorf("%w: repoURL %q isn't a GitHub repo URL", ErrInvalidArgocdNotification, notification.RepoURL)
+	}
+	if notification.Health != degradedHealthStatus {
+		log.Debugf("Ignoring ArgoCD notification of %s, its health is %s", notification.App, notification.Health)
+		return nil
+	}
+	if notification.Revision == "" {
+		return fmt.Errorf("%w: revision is required", ErrInvalidArgocdNotification)
+	}
+	repoSlug := owner + "/" + repo
+	if maintenance.SkipRepo(repoSlug, "argocd_notification") {
+		return nil
+	}
+	go func() {
+		ctx := withShadowModeState(tenancy.NewContext(context.Background(), tenancy.ForRepo(repoSlug)))
+		ctx, cancel := inflight.WithEventTimeout(ctx, "github", "argocd_notification", repoSlug)
+		defer cancel()
+		defer inflight.Track("github", "argocd_notification", repoSlug)()
+		var mainGithubClientPair GhClientPair
+		mainGithubClientPair.GetAndCache(mainGhClientCache, MainCredentialEnvVars(ctx), owner, ctx)
+		repoDetails := GhPrClientDetails{
+			Ctx:          ctx,
+			GhClientPair: &mainGithubClientPair,
+			Owner:        owner,
+			Repo:         repo,
+			PrLogger:     logging.ForRepo(repoSlug).WithFields(log.Fields{"app": notification.App, "event_type": "argocd_notification"}),
+		}
+		if err := handleDegradedApp(repoDetails, notification); err != nil {
+			repoDetails.PrLogger.Errorf("Failed to handle the Degraded ArgoCD app: err=%v", err)
+		}
+	}()
+	return nil
+}
+
+// recentPromotionPr returns the promotion PR among the PRs of the synced revision that was merged in the window before now, nil when there is none
+func recentPromotionPr(prs []*github.PullRequest, window time.Duration, now time.Time) *github.PullRequest {
+	for _, pr := range prs {
+		if pr.MergedAt == nil || !DoesPrHasLabel(pr.Labels, "promotion") {
+			continue
+		}
+		if now.Sub(pr.GetMergedAt().Time) <= window {
+			return pr
+		}
+	}
+	return nil
+}
+
+// downstreamPauseMarkers returns the promotion pause marker files that stop the promotions of componentPath to the next environments
+func downstreamPauseMarkers(config *cfg.Config, componentPath string) []string {
+	markers := []string{}
+	for _, promotionPath := range config.PromotionPaths {
+		components := getRelevantComponentsFromFileList([]string{componentPath + "/"}, &cfg.Config{PromotionPaths: []cfg.PromotionPath{promotionPath}})
+		if len(components) == 0 {
+			continue
+		}
+		for component := range components {
+			for _, promotionPr := range promotionPath.PromotionPrs {
+				for _, targetPath := range promotionPr.TargetPaths {
+					markers = append(markers, path.Join(targetPath, component.ComponentName, promotionPausedMarkerFile))
+				}
+			}
+		}
+		// Like changed files, a component belongs to a single promotion path
+		break
+	}
+	sort.Strings(markers)
+	return markers
+}
+
+// pauseDownstreamPromotions commits the pause marker files to the default branch
+func pauseDownstreamPromotions(ghPrClientDetails GhPrClientDetails, defaultBranch string, markers []string, reason string) error {
+	treeEntries := []*github.TreeEntry{}
+	for _, marker := range markers {
+		treeEntries = append(treeEntries, &github.TreeEntry{
+			Path:    github.String(marker),
+			Mode:    github.String("100644"),
+			Type:    github.String("blob"),
+			Content: github.String(reason + "\n"),
+		})
+	}
+	commit, err := createCommit(ghPrClientDetails, treeEntries, defaultBranch, "Pause promotions: "+reason)
+	if err != nil {
+		return fmt.Errorf("create pause commit: %w", err)
+	}
+	_, _, err = retryGhWrite(ghPrClientDetails.Ctx, "update_ref", func() (*github.Reference, *github.Response, error) {
+		return ghPrClientDetails.GhClientPair.v3Client.Git.UpdateRef(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, &github.Reference{
+			Ref:    github.String("refs/heads/" + defaultBranch),
+			Object: &github.GitObject{SHA: commit.SHA},
+		}, false)
+	})
+	if err != nil {
+		return fmt.Errorf("update %s: %w", defaultBranch, err)
+	}
+	return nil
+}
+
+func degradedAppComment(notification ArgocdNotification, pausedMarkers []string, pauseErr error) string {
+	comment := fmt.Sprintf("🔥 ArgoCD app `%s` (`%s`) became **Degraded** after this promotion was synced(revision `%s`)", notification.App, notification.Path, firstN(notification.Revision, 7))
+	if notification.Message != "" {
+		comment += fmt.Sprintf(":\n```\n%s\n```\n", notification.Message)
+	} else {
+		comment += ".\n"
+	}
+	if pauseErr != nil {
+		comment += fmt.Sprintf("\nFailed to pause the promotions of this component to the next environments, they should be paused manually\n```\n%s\n```\n", pauseErr)
+	} else if len(pausedMarkers) > 0 {
+		comment += "\n⏸️ Promotions of this component to the next environments were paused, delete these files to resume them:\n"
+		for _, marker := range pausedMarkers {
+			comment += fmt.Sprintf("* `%s`\n", marker)
+		}
+	}
+	return comment
+}
+
+// handleDegradedApp comments on the promotion PR recently merged with the revision the Degraded app synced and optionally pauses the next promotions of its component
+func handleDegradedApp(repoDetails GhPrClientDetails, notification ArgocdNotification) error {
+	defaultBranch, err := repoDetails.GetDefaultBranch()
+	if err != nil {
+		return fmt.Errorf("get default branch: %w", err)
+	}
+	config, err := GetInRepoConfig(repoDetails, defaultBranch)
+	if err != nil {
+		return fmt.Errorf("get in-repo configuration: %w", err)
+	}
+	if !config.Argocd.DegradedPromotion.Enabled {
+		return nil
+	}
+	window := defaultDegradedPromotionWindow
+	if config.Argocd.DegradedPromotion.WindowMinutes > 0 {
+		window = time.Duration(config.Argocd.DegradedPromotion.WindowMinutes) * time.Minute
+	}
+	listOpts := &github.ListOptions{}
+	prs, _, err := listAllPages(listOpts, func() ([]*github.PullRequest, *github.Response, error) {
+		return repoDetails.GhClientPair.v3Client.PullRequests.ListPullRequestsWithCommit(repoDetails.Ctx, repoDetails.Owner, repoDetails.Repo, notification.Revision, listOpts)
+	})
+	if err != nil {
+		return fmt.Errorf("list PRs of revision %s: %w", notification.Revision, err)
+	}
+	pr := recentPromotionPr(prs, window, time.Now())
+	if pr == nil {
+		repoDetails.PrLogger.Infof("No promotion PR was merged with revision %s in the last %s, ignoring", notification.Revision, window)
+		return nil
+	}
+	ghPrClientDetails := repoDetails
+	ghPrClientDetails.PrNumber = pr.GetNumber()
+	ghPrClientDetails.PrAuthor = pr.GetUser().GetLogin()
+	ghPrClientDetails.Labels = pr.Labels
+	ghPrClientDetails.PrLogger = repoDetails.PrLogger.WithFields(log.Fields{"prNumber": pr.GetNumber()})
+
+	var pausedMarkers []string
+	var pauseErr error
+	if config.Argocd.DegradedPromotion.PauseDownstream && !config.DryRunMode {
+		pausedMarkers = downstreamPauseMarkers(config, strings.Trim(notification.Path, "/"))
+		if len(pausedMarkers) > 0 {
+			pauseErr = pauseDownstreamPromotions(ghPrClientDetails, defaultBranch, pausedMarkers, fmt.Sprintf("ArgoCD app %s became Degraded after #%d", notification.App, pr.GetNumber()))
+			if pauseErr != nil {
+				ghPrClientDetails.PrLogger.Errorf("Failed to pause downstream promotions: err=%v", pauseErr)
+			}
+		}
+	}
+	return commentPR(ghPrClientDetails, degradedAppComment(notification, pausedMarkers, pauseErr))
+}
@@ -0,0 +1,76 @@
+package githubapi
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/stretchr/testify/assert"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+)
+
+func TestRepoSlugFromURL(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		repoURL       string
+		expectedOwner string
+		expectedRepo  string
+		expectedOk    bool
+	}{
+		"HTTPS":               {repoURL: "https://github.com/AnOwner/Arepo.git", expectedOwner: "AnOwner", expectedRepo: "Arepo", expectedOk: true},
+		"HTTPS trailing /":    {repoURL: "https://github.example.com/AnOwner/Arepo/", expectedOwner: "AnOwner", expectedRepo: "Arepo", expectedOk: true},
+		"SSH":                 {repoURL: "git@github.com:AnOwner/Arepo.git", expectedOwner: "AnOwner", expectedRepo: "Arepo", expectedOk: true},
+		"Not a repo URL":      {repoURL: "Arepo"},
+		"Too many path parts": {repoURL: "https://github.com/AnOwner/Arepo/tree/main"},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			owner, repo, ok := repoSlugFromURL(tc.repoURL)
+			assert.Equal(t, tc.expectedOk, ok)
+			assert.Equal(t, tc.expectedOwner, owner)
+			assert.Equal(t, tc.expectedRepo, repo)
+		})
+	}
+}
+
+func TestRecentPromotionPr(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2024, 6, 5, 12, 0, 0, 0, time.UTC)
+	promotionLabels := []*github.Label{{Name: github.String("promotion")}}
+	unmerged := &github.PullRequest{Number: github.Int(1), Labels: promotionLabels}
+	notPromotion := &github.PullRequest{Number: github.Int(2), MergedAt: &github.Timestamp{Time: now.Add(-time.Minute)}}
+	oldPromotion := &github.PullRequest{Number: github.Int(3), Labels: promotionLabels, MergedAt: &github.Timestamp{Time: now.Add(-2 * time.Hour)}}
+	recentPromotion := &github.PullRequest{Number: github.Int(4), Labels: promotionLabels, MergedAt: &github.Timestamp{Time: now.Add(-10 * time.Minute)}}
+
+	assert.Equal(t, recentPromotion, recentPromotionPr([]*github.PullRequest{unmerged, notPromotion, oldPromotion, recentPromotion}, time.Hour, now))
+	assert.Nil(t, recentPromotionPr([]*github.PullRequest{unmerged, notPromotion, oldPromotion}, time.Hour, now))
+}
+
+func TestDownstreamPauseMarkers(t *testing.T) {
+	t.Parallel()
+	config := &cfg.Config{
+		PromotionPaths: []cfg.PromotionPath{
+			{SourcePath: "env/staging/", PromotionPrs: []cfg.PromotionPr{{TargetPaths: []string{"env/prod/us-east4/", "env/prod/us-central1/"}}}},
+			{SourcePath: "env/dev/", PromotionPrs: []cfg.PromotionPr{{TargetPaths: []string{"env/staging/"}}}},
+		},
+	}
+	assert.Equal(t, []string{"env/prod/us-central1/c1/promotion-paused", "env/prod/us-east4/c1/promotion-paused"}, downstreamPauseMarkers(config, "env/staging/c1"))
+	assert.Empty(t, downstreamPauseMarkers(config, "env/prod/us-east4/c1"))
+}
+
+func TestDegradedAppComment(t *testing.T) {
+	t.Parallel()
+	notification := ArgocdNotification{App: "c1-staging", Path: "env/staging/c1", Revision: "0123456789abcdef", Health: "Degraded", Message: "Deployment has exceeded its progress deadline"}
+
+	comment := degradedAppComment(notification, []string{"env/prod/c1/promotion-paused"}, nil)
+	assert.Contains(t, comment, "`c1-staging` (`env/staging/c1`) became **Degraded** after this promotion was synced(revision `0123456`)")
+	assert.Contains(t, comment, "Deployment has exceeded its progress deadline")
+	assert.Contains(t, comment, "* `env/prod/c1/promotion-paused`")
+
+	comment = degradedAppComment(notification, []string{"env/prod/c1/promotion-paused"}, errors.New("protected branch"))
+	assert.Contains(t, comment, "should be paused manually")
+	assert.NotContains(t, comment, "were paused")
+}
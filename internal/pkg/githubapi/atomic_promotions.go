@@ -0,0 +1,113 @@
+package githubapi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+)
+
+const defaultAtomicPromotionBlockedLabel = "promotion-blocked"
+
+func atomicPromotionBlockedLabel(config *cfg.Config) string {
+	if config.AtomicPromotions.BlockedLabel != "" {
+		return config.AtomicPromotions.BlockedLabel
+	}
+	return defaultAtomicPromotionBlockedLabel
+}
+
+func appendMissing(s []string, elements ...string) []string {
+	for _, element := range elements {
+		if !contains(s, element) {
+			s = append(s, element)
+		}
+	}
+	return s
+}
+
+// combineAtomicPromotions combines the promotions that share a source path into a single promotion, so all their targets are updated by one merge.
+// Canary steps and ordered components keep their own promotions, they are chained by their PR metadata
+func combineAtomicPromotions(promotions map[string]PromotionInstance) map[string]PromotionInstance {
+	result := map[string]PromotionInstance{}
+	keysBySource := map[string][]string{}
+	for key, promotion := range promotions {
+		if promotion.Metadata.Steps != nil || promotion.Metadata.ComponentOrder != nil {
+			result[key] = promotion
+			continue
+		}
+		keysBySource[promotion.Metadata.SourcePath] = append(keysBySource[promotion.Metadata.SourcePath], key)
+	}
+	for sourcePath, keys := range keysBySource {
+		if len(keys) == 1 {
+			result[keys[0]] = promotions[keys[0]]
+			continue
+		}
+		sort.Strings(keys)
+		combined := PromotionInstance{
+			Metadata: PromotionInstanceMetaData{
+				SourcePath:                     sourcePath,
+				PerComponentSkippedTargetPaths: map[string][]string{},
+				AutoMerge:                      true,
+				Atomic:                         true,
+			},
+			ComputedSyncPaths: map[string]string{},
+		}
+		descriptions := []string{}
+		for _, key := range keys {
+			promotion := promotions[key]
+			combined.Metadata.TargetPaths = appendMissing(combined.Metadata.TargetPaths, promotion.Metadata.TargetPaths...)
+			combined.Metadata.ComponentNames = appendMissing(combined.Metadata.ComponentNames, promotion.Metadata.ComponentNames...)
+			combined.Metadata.HotfixSkippedTargetPaths = appendMissing(combined.Metadata.HotfixSkippedTargetPaths, promotion.Metadata.HotfixSkippedTargetPaths...)
+			// All the targets are merged together, so the PR is only auto-merged when all of them would be
+			combined.Metadata.AutoMerge = combined.Metadata.AutoMerge && promotion.Metadata.AutoMerge
+			if combined.Metadata.PathNames == nil {
+				combined.Metadata.PathNames = promotion.Metadata.PathNames
+			}
+			for component, skipped := range promotion.Metadata.PerComponentSkippedTargetPaths {
+				combined.Metadata.PerComponentSkippedTargetPaths[component] = appendMissing(combined.Metadata.PerComponentSkippedTargetPaths[component], skipped...)
+			}
+			for target, source := range promotion.ComputedSyncPaths {
+				combined.ComputedSyncPaths[target] = source
+			}
+			for target, marker := range promotion.PausedTargetPaths {
+				if combined.PausedTargetPaths == nil {
+					combined.PausedTargetPaths = map[string]string{}
+				}
+				combined.PausedTargetPaths[target] = marker
+			}
+			descriptions = appendMissing(descriptions, promotion.Metadata.TargetDescription)
+		}
+		sort.Strings(combined.Metadata.TargetPaths)
+		sort.Strings(combined.Metadata.ComponentNames)
+		combined.Metadata.TargetDescription = strings.Join(descriptions, " + ")
+		result[sourcePath+">"+strings.Join(combined.Metadata.TargetPaths, "|")] = combined
+	}
+	return result
+}
+
+// preparePromotionWave splits the promotions opened together(a wave) into their canary steps and ordered components, then combines them when atomicPromotions is enabled.
+// It's idempotent, so promotions held by gates can be prepared again when they are opened
+func preparePromotionWave(config *cfg.Config, promotions map[string]PromotionInstance) map[string]PromotionInstance {
+	promotions = splitPromotionSteps(promotions)
+	promotions = orderPromotionComponents(config.ComponentDependencies, promotions)
+	if config.AtomicPromotions.Enabled {
+		promotions = combineAtomicPromotions(promotions)
+	}
+	return promotions
+}
+
+func atomicPromotionBlockedComment(gateFailures []string) string {
+	return fmt.Sprintf("⛔ This atomic promotion failed these gates(see the comments on the original PR), it wasn't auto-merged and needs manual action:\n* %s\n", strings.Join(gateFailures, "\n* "))
+}
+
+// blockAtomicPromotionPr labels and comments on an atomic promotion PR a gate failed, so it's merged(or closed) manually instead of leaving some of its targets promoted
+func blockAtomicPromotionPr(ghPrClientDetails GhPrClientDetails, config *cfg.Config, prNumber int, gateFailures []string) {
+	ghPrClientDetails.PrLogger.Warnf("Atomic promotion PR %d failed gates %v, not auto-merging it", prNumber, gateFailures)
+	if err := labelPr(ghPrClientDetails, prNumber, []string{atomicPromotionBlockedLabel(config)}); err != nil {
+		ghPrClientDetails.PrLogger.Warnf("Failed to label blocked atomic promotion PR %d: err=%v", prNumber, err)
+	}
+	promotionPrDetails := ghPrClientDetails
+	promotionPrDetails.PrNumber = prNumber
+	_ = commentPR(promotionPrDetails, atomicPromotionBlockedComment(gateFailures))
+}
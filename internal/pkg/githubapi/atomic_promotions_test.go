@@ -0,0 +1,60 @@
+package githubapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCombineAtomicPromotions(t *testing.T) {
+	t.Parallel()
+	promotions := map[string]PromotionInstance{
+		"env/staging/>env/prod/us-east4/": {
+			Metadata: PromotionInstanceMetaData{
+				SourcePath:        "env/staging/",
+				TargetPaths:       []string{"env/prod/us-east4/"},
+				TargetDescription: "prod (us-east4)",
+				ComponentNames:    []string{"app1"},
+				AutoMerge:         true,
+			},
+			ComputedSyncPaths: map[string]string{"env/prod/us-east4/app1": "env/staging/app1"},
+		},
+		"env/staging/>env/prod/eu-west1/": {
+			Metadata: PromotionInstanceMetaData{
+				SourcePath:        "env/staging/",
+				TargetPaths:       []string{"env/prod/eu-west1/"},
+				TargetDescription: "prod (eu-west1)",
+				ComponentNames:    []string{"app1"},
+				AutoMerge:         false,
+			},
+			ComputedSyncPaths: map[string]string{"env/prod/eu-west1/app1": "env/staging/app1"},
+		},
+		"env/dev/>env/staging/": {
+			Metadata:          PromotionInstanceMetaData{SourcePath: "env/dev/", TargetPaths: []string{"env/staging/"}, ComponentNames: []string{"app2"}},
+			ComputedSyncPaths: map[string]string{"env/staging/app2": "env/dev/app2"},
+		},
+		"env/staging/>env/prod/asia-east1/": {
+			Metadata: PromotionInstanceMetaData{
+				SourcePath:     "env/staging/",
+				TargetPaths:    []string{"env/prod/asia-east1/"},
+				ComponentNames: []string{"app1"},
+				Steps:          &promotionStepsMetadata{Step: 1, Steps: 2},
+			},
+			ComputedSyncPaths: map[string]string{"env/prod/asia-east1/app1": "env/staging/app1"},
+		},
+	}
+
+	combined := combineAtomicPromotions(promotions)
+	assert.Len(t, combined, 3)
+	assert.False(t, combined["env/dev/>env/staging/"].Metadata.Atomic)
+	assert.NotNil(t, combined["env/staging/>env/prod/asia-east1/"].Metadata.Steps, "Canary steps keep their own promotion")
+
+	wave, ok := combined["env/staging/>env/prod/eu-west1/|env/prod/us-east4/"]
+	assert.True(t, ok)
+	assert.True(t, wave.Metadata.Atomic)
+	assert.False(t, wave.Metadata.AutoMerge, "A target that isn't auto-merged holds the whole wave")
+	assert.Equal(t, []string{"env/prod/eu-west1/", "env/prod/us-east4/"}, wave.Metadata.TargetPaths)
+	assert.Equal(t, "prod (eu-west1) + prod (us-east4)", wave.Metadata.TargetDescription)
+	assert.Equal(t, []string{"app1"}, wave.Metadata.ComponentNames)
+	assert.Equal(t, map[string]string{"env/prod/us-east4/app1": "env/staging/app1", "env/prod/eu-west1/app1": "env/staging/app1"}, wave.ComputedSyncPaths)
+}
@@ -0,0 +1,88 @@
+package githubapi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v62/github"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+	"golang.org/x/exp/maps"
+)
+
+type backPromotionPath struct {
+	Source string
+	Target string
+}
+
+func backPromotionLabel(config *cfg.Config) string {
+	if config.Hotfix.BackPromotionLabel != "" {
+		return config.Hotfix.BackPromotionLabel
+	}
+	return "back-promotion"
+}
+
+// generateBackPromotionPaths returns the paths a hotfix skipped, with the hotfixed path each one should be synced from, sorted by target
+func generateBackPromotionPaths(hotfixSkippedPaths map[string]string) []backPromotionPath {
+	targets := maps.Keys(hotfixSkippedPaths)
+	sort.Strings(targets)
+	paths := make([]backPromotionPath, 0, len(targets))
+	for _, target := range targets {
+		paths = append(paths, backPromotionPath{Source: hotfixSkippedPaths[target], Target: target})
+	}
+	return paths
+}
+
+// openBackPromotionPr opens a PR that syncs the hotfixed paths back to the intermediate environments the hotfix promotion skipped.
+// It's labeled with the back-promotion label instead of "promotion" and merging it doesn't trigger promotions
+func openBackPromotionPr(ghPrClientDetails GhPrClientDetails, config *cfg.Config, defaultBranch string) (*github.PullRequest, error) {
+	paths := generateBackPromotionPaths(ghPrClientDetails.PrMetadata.HotfixSkippedPaths)
+	var treeEntries []*github.TreeEntry
+	hotfixTargets := map[string]bool{}
+	for _, path := range paths {
+		err := GenerateSyncTreeEntriesForCommit(&treeEntries, ghPrClientDetails, path.Source, path.Target, defaultBranch)
+		if err != nil {
+			return nil, fmt.Errorf("generating tree entries for %s > %s: %w", path.Source, path.Target, err)
+		}
+		hotfixTargets[path.Source] = true
+	}
+	if len(treeEntries) < 1 {
+		ghPrClientDetails.PrLogger.Infof("Skipped paths are already in sync with the hotfix, no back-promotion needed")
+		return nil, nil
+	}
+
+	commitMsg := fmt.Sprintf("Back-promoting hotfix #%d", ghPrClientDetails.PrNumber)
+	if config.PromotionCommitMessageSuffix != "" {
+		commitMsg += " " + config.PromotionCommitMessageSuffix
+	}
+	commit, err := createCommit(ghPrClientDetails, treeEntries, defaultBranch, commitMsg)
+	if err != nil {
+		return nil, fmt.Errorf("creating commit: %w", err)
+	}
+	newBranchRef, err := createBranch(ghPrClientDetails, commit, fmt.Sprintf("back-promotions/%d", ghPrClientDetails.PrNumber))
+	if err != nil {
+		return nil, fmt.Errorf("creating branch: %w", err)
+	}
+
+	sortedHotfixTargets := maps.Keys(hotfixTargets)
+	sort.Strings(sortedHotfixTargets)
+	newPrBody, err := executeRepoTemplate(ghPrClientDetails, "backPromotionBody", "back-promotion-pr-body.gotmpl", map[string]interface{}{
+		"prNumber":      ghPrClientDetails.PrNumber,
+		"hotfixTargets": "`" + strings.Join(sortedHotfixTargets, "`, `") + "`",
+		"paths":         paths,
+	})
+	if err != nil {
+		return nil, err
+	}
+	newPrTitle := fmt.Sprintf("🔙 Back-promotion of hotfix #%d", ghPrClientDetails.PrNumber)
+
+	assignee := ghPrClientDetails.PrMetadata.OriginalPrAuthor
+	if assignee == "" {
+		assignee = ghPrClientDetails.PrAuthor
+	}
+	backPromotedPaths := []string{}
+	for _, path := range paths {
+		backPromotedPaths = append(backPromotedPaths, path.Target)
+	}
+	return createPrObject(ghPrClientDetails, newBranchRef, newPrTitle, newPrBody, defaultBranch, promotionPrRouting(config, backPromotedPaths, assignee), []string{backPromotionLabel(config)})
+}
@@ -0,0 +1,32 @@
+package githubapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateBackPromotionPaths(t *testing.T) {
+	t.Parallel()
+	paths := generateBackPromotionPaths(map[string]string{
+		"env/staging/componentA": "env/prod-eu/componentA",
+		"env/dev/componentA":     "env/prod-eu/componentA",
+	})
+	assert.Equal(t, []backPromotionPath{
+		{Source: "env/prod-eu/componentA", Target: "env/dev/componentA"},
+		{Source: "env/prod-eu/componentA", Target: "env/staging/componentA"},
+	}, paths)
+}
+
+func TestBackPromotionPrBodyTemplate(t *testing.T) {
+	t.Parallel()
+	details := repoTemplateTestClientDetails(nil)
+	output, err := executeTemplate("backPromotionBody", defaultTemplatesFullPath(details.Ctx, "back-promotion-pr-body.gotmpl"), map[string]interface{}{
+		"prNumber":      42,
+		"hotfixTargets": "`env/prod-eu/componentA`",
+		"paths":         []backPromotionPath{{Source: "env/prod-eu/componentA", Target: "env/staging/componentA"}},
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, output, "Hotfix #42 was promoted directly to `env/prod-eu/componentA`")
+	assert.Contains(t, output, "| `env/prod-eu/componentA` | `env/staging/componentA` |")
+}
@@ -0,0 +1,247 @@
+package githubapi
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/argocd"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+)
+
+const (
+	checkAnnotationsCheckRunName = "telefonistka"
+	// The GitHub API accepts up to 50 annotations per check-run create/update request
+	checkRunAnnotationsPerRequest = 50
+)
+
+var (
+	// YAML syntax errors name the line of the problem, e.g. "yaml: line 3: mapping values are not allowed in this context"
+	yamlErrorLineRegex     = regexp.MustCompile(`\bline (\d+)\b`)
+	manifestDocumentRegex  = regexp.MustCompile(`^document (\d+):`)
+	yamlDocumentSeparators = regexp.MustCompile(`^---(\s|$)`)
+)
+
+type checkAnnotation struct {
+	// A file path, or a component path when component is set
+	path string
+	// Component annotations are attached to the first file the PR changed in the component, diff errors can't be attributed to a single file
+	component bool
+	line      int
+	// "failure" or "warning"
+	level   string
+	title   string
+	message string
+}
+
+// checkAnnotations collects the problems found while handling a PR event for the annotations check-run, the annotate methods do nothing on nil(checkRunAnnotations is disabled)
+type checkAnnotations struct {
+	mu          sync.Mutex
+	annotations []checkAnnotation
+}
+
+func (a *checkAnnotations) add(annotation checkAnnotation) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.annotations = append(a.annotations, annotation)
+}
+
+func (a *checkAnnotations) annotateInvalidManifest(fileName string, content []byte, problems []string) {
+	for _, problem := range problems {
+		a.add(checkAnnotation{path: fileName, line: manifestProblemLine(content, problem), level: "failure", title: "Invalid Kubernetes manifest", message: problem})
+	}
+}
+
+// annotateConfigLint annotates the problems found in the telefonistka.yaml of the PR, problems without a line are annotated on the first one
+func (a *checkAnnotations) annotateConfigLint(problems []cfg.LintProblem) {
+	for _, problem := range problems {
+		level := "warning"
+		if problem.Severity == cfg.LintError {
+			level = "failure"
+		}
+		a.add(checkAnnotation{path: "telefonistka.yaml", line: max(problem.Line, 1), level: level, title: "Telefonistka configuration " + problem.Severity, message: problem.Message})
+	}
+}
+
+func (a *checkAnnotations) annotateDiffError(provider string, componentPaths []string, err error) {
+	for _, componentPath := range componentPaths {
+		a.add(checkAnnotation{path: componentPath, component: true, line: 1, level: "warning", title: provider + " failed", message: err.Error()})
+	}
+}
+
+func (a *checkAnnotations) annotateComponentDiffs(provider string, diffs []ComponentDiff) {
+	for _, diff := range diffs {
+		if diff.Err != nil {
+			a.annotateDiffError(provider, []string{diff.ComponentPath}, diff.Err)
+		}
+	}
+}
+
+func (a *checkAnnotations) annotateArgoCdDiffs(diffResults []argocd.DiffResult) {
+	for _, diffResult := range diffResults {
+		if diffResult.DiffError != nil {
+			a.annotateDiffError("ArgoCD diff", []string{diffResult.ComponentPath}, diffResult.DiffError)
+		}
+	}
+}
+
+// manifestDocumentStartLine returns the line the docIndex-th(1 based) YAML document of content starts at
+func manifestDocumentStartLine(content []byte, docIndex int) int {
+	currentDoc := 1
+	startLine := 1
+	// A separator before any content(e.g. after leading comments) starts the first document rather than a new one
+	docHasContent := false
+	lines := bufio.NewScanner(bytes.NewReader(content))
+	lines.Buffer(make([]byte, 0, 64*1024), len(content)+1)
+	for lineNumber := 1; lines.Scan(); lineNumber++ {
+		line := lines.Text()
+		if yamlDocumentSeparators.MatchString(line) {
+			if docHasContent {
+				if currentDoc == docIndex {
+					return startLine
+				}
+				currentDoc++
+				docHasContent = false
+			}
+			startLine = lineNumber
+			continue
+		}
+		if trimmed := strings.TrimSpace(line); trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			docHasContent = true
+		}
+	}
+	if currentDoc == docIndex {
+		return startLine
+	}
+	return 1
+}
+
+// manifestProblemLine returns the line a validateManifest problem refers to, the line of the YAML error or the start of the problematic document
+func manifestProblemLine(content []byte, problem string) int {
+	if match := yamlErrorLineRegex.FindStringSubmatch(problem); match != nil {
+		if line, err := strconv.Atoi(match[1]); err == nil && line > 0 {
+			return line
+		}
+	}
+	if match := manifestDocumentRegex.FindStringSubmatch(problem); match != nil {
+		if docIndex, err := strconv.Atoi(match[1]); err == nil {
+			return manifestDocumentStartLine(content, docIndex)
+		}
+	}
+	return 1
+}
+
+// resolveCheckAnnotations converts the collected annotations to check-run annotations, component annotations without a changed file in the PR are dropped
+func resolveCheckAnnotations(annotations []checkAnnotation, changedFiles []string) []*github.CheckRunAnnotation {
+	sortedFiles := append([]string{}, changedFiles...)
+	sort.Strings(sortedFiles)
+	resolved := []*github.CheckRunAnnotation{}
+	for _, annotation := range annotations {
+		annotationPath := annotation.path
+		if annotation.component {
+			annotationPath = ""
+			for _, changedFile := range sortedFiles {
+				if strings.HasPrefix(changedFile, strings.TrimSuffix(annotation.path, "/")+"/") {
+					annotationPath = changedFile
+					break
+				}
+			}
+			if annotationPath == "" {
+				continue
+			}
+		}
+		resolved = append(resolved, &github.CheckRunAnnotation{
+			Path:            github.String(annotationPath),
+			StartLine:       github.Int(annotation.line),
+			EndLine:         github.Int(annotation.line),
+			AnnotationLevel: github.String(annotation.level),
+			Title:           github.String(annotation.title),
+			Message:         github.String(annotation.message),
+		})
+	}
+	return resolved
+}
+
+func checkAnnotationsConclusion(annotations []*github.CheckRunAnnotation) string {
+	conclusion := "success"
+	for _, annotation := range annotations {
+		switch annotation.GetAnnotationLevel() {
+		case "failure":
+			return "failure"
+		case "warning":
+			conclusion = "neutral"
+		}
+	}
+	return conclusion
+}
+
+// publishCheckAnnotations creates the annotations check-run on the PR head commit, annotations beyond the per request limit are added by updating it
+func publishCheckAnnotations(ghPrClientDetails GhPrClientDetails) error {
+	collected := ghPrClientDetails.annotations
+	if collected == nil {
+		return nil
+	}
+	collected.mu.Lock()
+	annotations := append([]checkAnnotation{}, collected.annotations...)
+	collected.mu.Unlock()
+
+	var changedFiles []string
+	for _, annotation := range annotations {
+		if annotation.component {
+			var err error
+			changedFiles, err = listPrFiles(ghPrClientDetails)
+			if err != nil {
+				return fmt.Errorf("list PR files: %w", err)
+			}
+			break
+		}
+	}
+	resolved := resolveCheckAnnotations(annotations, changedFiles)
+	output := func(batch []*github.CheckRunAnnotation) *github.CheckRunOutput {
+		summary := "Telefonistka found no problems in this PR."
+		if len(resolved) > 0 {
+			summary = fmt.Sprintf("Telefonistka found %d problems in this PR, see the annotations in the Files Changed tab.", len(resolved))
+		}
+		return &github.CheckRunOutput{
+			Title:       github.String("Telefonistka checks"),
+			Summary:     github.String(summary),
+			Annotations: batch,
+		}
+	}
+
+	firstBatch := resolved[:min(len(resolved), checkRunAnnotationsPerRequest)]
+	checkRun, resp, err := retryGhWrite(ghPrClientDetails.Ctx, "create_check_run", func() (*github.CheckRun, *github.Response, error) {
+		return ghPrClientDetails.GhClientPair.v3Client.Checks.CreateCheckRun(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, github.CreateCheckRunOptions{
+			Name:       checkAnnotationsCheckRunName,
+			HeadSHA:    ghPrClientDetails.PrSHA,
+			Status:     github.String("completed"),
+			Conclusion: github.String(checkAnnotationsConclusion(resolved)),
+			Output:     output(firstBatch),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("create check-run: %w\n%v", err, resp)
+	}
+	for start := len(firstBatch); start < len(resolved); start += checkRunAnnotationsPerRequest {
+		batch := resolved[start:min(len(resolved), start+checkRunAnnotationsPerRequest)]
+		_, resp, err := retryGhWrite(ghPrClientDetails.Ctx, "update_check_run", func() (*github.CheckRun, *github.Response, error) {
+			return ghPrClientDetails.GhClientPair.v3Client.Checks.UpdateCheckRun(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, checkRun.GetID(), github.UpdateCheckRunOptions{
+				Name:   checkAnnotationsCheckRunName,
+				Output: output(batch),
+			})
+		})
+		if err != nil {
+			return fmt.Errorf("add annotations to check-run: %w\n%v", err, resp)
+		}
+	}
+	return nil
+}
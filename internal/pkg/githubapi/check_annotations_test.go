@@ -0,0 +1,122 @@
+package githubapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifestProblemLine(t *testing.T) {
+	t.Parallel()
+	content := []byte("# leading comment\n---\napiVersion: v1\nkind: ConfigMap\n---\n# second document\nkind: Deployment\n---\nkind: Service\n  bad: : :\n")
+	tests := map[string]struct {
+		problem  string
+		expected int
+	}{
+		"YAML syntax error line": {
+			problem:  "document 3: invalid YAML: yaml: line 10: mapping values are not allowed in this context",
+			expected: 10,
+		},
+		"first document after a leading separator": {
+			problem:  "document 1: ConfigMap has no metadata.name",
+			expected: 2,
+		},
+		"second document": {
+			problem:  "document 2: Deployment has no apiVersion",
+			expected: 5,
+		},
+		"unknown document": {
+			problem:  "document 7: Deployment has no apiVersion",
+			expected: 1,
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, manifestProblemLine(content, tc.problem))
+		})
+	}
+}
+
+func TestResolveCheckAnnotations(t *testing.T) {
+	t.Parallel()
+	annotations := &checkAnnotations{}
+	annotations.annotateInvalidManifest("env/prod/app/deployment.yaml", []byte("kind: Deployment\n"), []string{"document 1: Deployment has no apiVersion"})
+	annotations.annotateDiffError("Helm diff", []string{"env/prod/chart", "env/prod/untouched"}, errors.New("helm template failed"))
+
+	resolved := resolveCheckAnnotations(annotations.annotations, []string{"env/prod/chart/values.yaml", "env/prod/chart/Chart.yaml", "env/prod/app/deployment.yaml"})
+	assert.Equal(t, []*github.CheckRunAnnotation{
+		{
+			Path:            github.String("env/prod/app/deployment.yaml"),
+			StartLine:       github.Int(1),
+			EndLine:         github.Int(1),
+			AnnotationLevel: github.String("failure"),
+			Title:           github.String("Invalid Kubernetes manifest"),
+			Message:         github.String("document 1: Deployment has no apiVersion"),
+		},
+		{
+			Path:            github.String("env/prod/chart/Chart.yaml"),
+			StartLine:       github.Int(1),
+			EndLine:         github.Int(1),
+			AnnotationLevel: github.String("warning"),
+			Title:           github.String("Helm diff failed"),
+			Message:         github.String("helm template failed"),
+		},
+	}, resolved)
+	assert.Equal(t, "failure", checkAnnotationsConclusion(resolved))
+	assert.Equal(t, "neutral", checkAnnotationsConclusion(resolved[1:]))
+	assert.Equal(t, "success", checkAnnotationsConclusion(nil))
+}
+
+func TestAnnotationsDisabled(t *testing.T) {
+	t.Parallel()
+	var annotations *checkAnnotations
+	annotations.annotateDiffError("ArgoCD diff", []string{"env/prod/app"}, errors.New("boom"))
+	assert.Nil(t, annotations)
+	assert.NoError(t, publishCheckAnnotations(GhPrClientDetails{}))
+}
+
+func TestPublishCheckAnnotationsInBatches(t *testing.T) {
+	t.Parallel()
+	var created github.CreateCheckRunOptions
+	updatedBatches := []int{}
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.PostReposCheckRunsByOwnerByRepo,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_ = json.NewDecoder(r.Body).Decode(&created)
+				_, _ = w.Write(mock.MustMarshal(github.CheckRun{ID: github.Int64(7)}))
+			}),
+		),
+		mock.WithRequestMatchHandler(
+			mock.PatchReposCheckRunsByOwnerByRepoByCheckRunId,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/repos/AnOwner/Arepo/check-runs/7", r.URL.Path)
+				var update github.UpdateCheckRunOptions
+				_ = json.NewDecoder(r.Body).Decode(&update)
+				updatedBatches = append(updatedBatches, len(update.Output.Annotations))
+				_, _ = w.Write(mock.MustMarshal(github.CheckRun{ID: github.Int64(7)}))
+			}),
+		),
+	)
+	ghPrClientDetails := repoTemplateTestClientDetails(mockedHTTPClient)
+	ghPrClientDetails.PrSHA = "abc123"
+	ghPrClientDetails.annotations = &checkAnnotations{}
+	for i := 0; i < 120; i++ {
+		ghPrClientDetails.annotations.annotateInvalidManifest(fmt.Sprintf("env/prod/app/%d.yaml", i), []byte("kind: Deployment\n"), []string{"document 1: Deployment has no apiVersion"})
+	}
+
+	err := publishCheckAnnotations(ghPrClientDetails)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc123", created.HeadSHA)
+	assert.Equal(t, "failure", created.GetConclusion())
+	assert.Len(t, created.Output.Annotations, checkRunAnnotationsPerRequest)
+	assert.Equal(t, []int{50, 20}, updatedBatches)
+}
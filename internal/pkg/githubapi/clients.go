@@ -6,13 +6,14 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/bradleyfalzon/ghinstallation/v2"
 	"github.com/google/go-github/v62/github"
 	lru "github.com/hashicorp/golang-lru/v2"
 	"github.com/shurcooL/githubv4"
 	log "github.com/sirupsen/logrus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/tenancy"
 	"golang.org/x/oauth2"
 )
 
@@ -23,13 +24,31 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
-func getCrucialEnv(key string) string {
-	if value, ok := os.LookupEnv(key); ok {
-		return value
+// secretTokenSource resolves the token from its secret store every minute, so a rotated token is picked up without recreating the client
+type secretTokenSource struct {
+	name   string
+	tenant *tenancy.Tenant
+}
+
+func (s secretTokenSource) Token() (*oauth2.Token, error) {
+	token, ok := s.tenant.Lookup(s.name)
+	if !ok {
+		return nil, fmt.Errorf("%s is not set", s.name)
 	}
-	log.Fatalf("%s environment variable is required", key)
-	os.Exit(3)
-	return ""
+	return &oauth2.Token{AccessToken: token, Expiry: time.Now().Add(time.Minute)}, nil
+}
+
+// getGithubAppPrivateKey returns the PEM private key of the GitHub App, the key content can be provided as a secret named like the
+// path env var without its _PATH suffix(e.g. GITHUB_APP_PRIVATE_KEY), otherwise it's read from the file the path env var points to
+func getGithubAppPrivateKey(tenant *tenancy.Tenant, ghAppPKeyPathEnvVarName string) ([]byte, error) {
+	if key, ok := tenant.Lookup(strings.TrimSuffix(ghAppPKeyPathEnvVarName, "_PATH")); ok {
+		return []byte(key), nil
+	}
+	githubAppPrivateKeyPath, ok := tenant.Lookup(ghAppPKeyPathEnvVarName)
+	if !ok {
+		return nil, fmt.Errorf("neither %s nor %s are set", strings.TrimSuffix(ghAppPKeyPathEnvVarName, "_PATH"), ghAppPKeyPathEnvVarName)
+	}
+	return os.ReadFile(githubAppPrivateKeyPath)
 }
 
 type GhClientPair struct {
@@ -37,63 +56,41 @@ type GhClientPair struct {
 	v4Client *githubv4.Client
 }
 
-func getAppInstallationId(githubAppPrivateKeyPath string, githubAppId int64, githubRestAltURL string, ctx context.Context, owner string) (int64, error) {
-	atr, err := ghinstallation.NewAppsTransportKeyFromFile(http.DefaultTransport, githubAppId, githubAppPrivateKeyPath)
-	if err != nil {
-		panic(err)
-	}
-	tempClient := github.NewClient(
-		&http.Client{
-			Transport: atr,
-			Timeout:   time.Second * 30,
-		})
-
-	if githubRestAltURL != "" {
-		tempClient, err = tempClient.WithEnterpriseURLs(githubRestAltURL, githubRestAltURL)
-		if err != nil {
-			log.Fatalf("failed to create git client for app: %v\n", err)
+func getAppInstallationId(appsClient *github.Client, githubAppId int64, ctx context.Context, owner string) (int64, error) {
+	listOpts := &github.ListOptions{}
+	var installationId int64
+	_, err := forEachPage(listOpts, func() ([]*github.Installation, *github.Response, error) {
+		return appsClient.Apps.ListInstallations(ctx, listOpts)
+	}, func(installations []*github.Installation) bool {
+		for _, i := range installations {
+			if i.GetAccount().GetLogin() == owner {
+				installationId = i.GetID()
+				return false
+			}
 		}
-	}
-
-	installations, _, err := tempClient.Apps.ListInstallations(ctx, &github.ListOptions{})
+		return true
+	})
 	if err != nil {
-		log.Fatalf("failed to list installations: %v\n", err)
+		return 0, fmt.Errorf("failed to list installations: %w", err)
 	}
-
-	var installID int64
-	for _, i := range installations {
-		if *i.Account.Login == owner {
-			installID = i.GetID()
-			log.Infof("Installation ID for GitHub Application # %v is: %v", githubAppId, installID)
-			return installID, nil
-		}
+	if installationId == 0 {
+		return 0, fmt.Errorf("no installation of app %d found for %s", githubAppId, owner)
 	}
-
-	return 0, err
+	log.Infof("Installation ID for GitHub Application # %v is: %v", githubAppId, installationId)
+	return installationId, nil
 }
 
-func createGithubAppRestClient(githubAppPrivateKeyPath string, githubAppId int64, githubAppInstallationId int64, githubRestAltURL string, ctx context.Context) *github.Client {
-	itr, err := ghinstallation.NewKeyFromFile(http.DefaultTransport, githubAppId, githubAppInstallationId, githubAppPrivateKeyPath)
-	if err != nil {
-		log.Fatal(err)
-	}
-	var client *github.Client
-
-	if githubRestAltURL != "" {
-		itr.BaseURL = githubRestAltURL
-		client, _ = github.NewClient(&http.Client{Transport: itr}).WithEnterpriseURLs(githubRestAltURL, githubRestAltURL)
-	} else {
-		client = github.NewClient(&http.Client{Transport: itr})
-	}
-	return client
+// newTokenHTTPClient uses ts as is, oauth2.NewClient would wrap it in another ReuseTokenSource that ignores the early refresh of installation tokens
+func newTokenHTTPClient(ts oauth2.TokenSource) *http.Client {
+	return &http.Client{Transport: &oauth2.Transport{Source: ts}}
 }
 
-func createGithubRestClient(githubOauthToken string, githubRestAltURL string, ctx context.Context) *github.Client {
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: githubOauthToken},
-	)
-	tc := oauth2.NewClient(ctx, ts)
-	client := github.NewClient(tc)
+// createGithubRestClient returns a REST client authenticated by ts, scope identifies these credentials in the conditional reads cache.
+// The reads of the public repos of the tenant in ctx can use other credentials(see newPublicReadTransport)
+func createGithubRestClient(ctx context.Context, ts oauth2.TokenSource, scope string, githubRestAltURL string) *github.Client {
+	httpClient := newTokenHTTPClient(ts)
+	httpClient.Transport = newPublicReadTransport(ctx, newConditionalReadTransport(httpClient.Transport, scope))
+	client := github.NewClient(httpClient)
 	if githubRestAltURL != "" {
 		client, _ = client.WithEnterpriseURLs(githubRestAltURL, githubRestAltURL)
 	}
@@ -101,27 +98,8 @@ func createGithubRestClient(githubOauthToken string, githubRestAltURL string, ct
 	return client
 }
 
-func createGithubAppGraphQlClient(githubAppPrivateKeyPath string, githubAppId int64, githubAppInstallationId int64, githubGraphqlAltURL string, githubRestAltURL string, ctx context.Context) *githubv4.Client {
-	itr, err := ghinstallation.NewKeyFromFile(http.DefaultTransport, githubAppId, githubAppInstallationId, githubAppPrivateKeyPath)
-	if err != nil {
-		log.Fatal(err)
-	}
-	var client *githubv4.Client
-
-	if githubGraphqlAltURL != "" {
-		itr.BaseURL = githubRestAltURL
-		client = githubv4.NewEnterpriseClient(githubGraphqlAltURL, &http.Client{Transport: itr})
-	} else {
-		client = githubv4.NewClient(&http.Client{Transport: itr})
-	}
-	return client
-}
-
-func createGithubGraphQlClient(githubOauthToken string, githubGraphqlAltURL string) *githubv4.Client {
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: githubOauthToken},
-	)
-	httpClient := oauth2.NewClient(context.Background(), ts)
+func createGithubGraphQlClient(ts oauth2.TokenSource, githubGraphqlAltURL string) *githubv4.Client {
+	httpClient := newTokenHTTPClient(ts)
 	var client *githubv4.Client
 	if githubGraphqlAltURL != "" {
 		client = githubv4.NewEnterpriseClient(githubGraphqlAltURL, httpClient)
@@ -131,11 +109,15 @@ func createGithubGraphQlClient(githubOauthToken string, githubGraphqlAltURL stri
 	return client
 }
 
-func createGhAppClientPair(ctx context.Context, githubAppId int64, owner string, ghAppPKeyPathEnvVarName string) GhClientPair {
+func createGhAppClientPair(ctx context.Context, githubAppId int64, owner string, credentials CredentialEnvVars) GhClientPair {
 	var githubRestAltURL string
 	var githubGraphqlAltURL string
-	githubAppPrivateKeyPath := getCrucialEnv(ghAppPKeyPathEnvVarName)
-	githubHost := getEnv("GITHUB_HOST", "")
+	tenant := tenancy.FromContext(ctx)
+	githubAppPrivateKey, err := getGithubAppPrivateKey(tenant, credentials.AppPrivateKeyPath)
+	if err != nil {
+		log.Fatalf("failed to get GitHub App private key: %v", err)
+	}
+	githubHost := tenancy.Getenv(ctx, "GITHUB_HOST", "")
 	if githubHost != "" {
 		githubRestAltURL = fmt.Sprintf("https://%s/api/v3", githubHost)
 		githubGraphqlAltURL = fmt.Sprintf("https://%s/api/graphql", githubHost)
@@ -145,21 +127,33 @@ func createGhAppClientPair(ctx context.Context, githubAppId int64, owner string,
 		log.Debugf("Using public Github API endpoint")
 	}
 
-	githubAppInstallationId, err := getAppInstallationId(githubAppPrivateKeyPath, githubAppId, githubRestAltURL, ctx, owner)
+	appsClient, err := newGithubAppsClient(githubAppPrivateKey, githubAppId, githubRestAltURL)
 	if err != nil {
-		log.Errorf("Couldn't find installation for app ID %v and repo owner %s", githubAppId, owner)
+		log.Fatalf("failed to create git client for app: %v\n", err)
+	}
+	// Installations don't move, a shared cache saves listing them on every restart
+	installationKey := tenant.CacheKey(fmt.Sprintf("%d/%s", githubAppId, owner))
+	var githubAppInstallationId int64
+	if !getSharedCache().get(ctx, "installation_id", installationKey, &githubAppInstallationId) {
+		githubAppInstallationId, err = getAppInstallationId(appsClient, githubAppId, ctx, owner)
+		if err != nil {
+			log.Errorf("Couldn't find installation for app ID %v and repo owner %s: %v", githubAppId, owner, err)
+		} else {
+			getSharedCache().set(ctx, "installation_id", installationKey, githubAppInstallationId, 24*time.Hour)
+		}
 	}
 
+	ts := installationTokenSource(tenant.CacheKey(credentials.AppID), appsClient, githubAppId, githubAppInstallationId)
 	return GhClientPair{
-		v3Client: createGithubAppRestClient(githubAppPrivateKeyPath, githubAppId, githubAppInstallationId, githubRestAltURL, ctx),
-		v4Client: createGithubAppGraphQlClient(githubAppPrivateKeyPath, githubAppId, githubAppInstallationId, githubGraphqlAltURL, githubRestAltURL, ctx),
+		v3Client: createGithubRestClient(ctx, ts, tenant.CacheKey(fmt.Sprintf("%d/%d", githubAppId, githubAppInstallationId)), githubRestAltURL),
+		v4Client: createGithubGraphQlClient(ts, githubGraphqlAltURL),
 	}
 }
 
-func createGhTokenClientPair(ctx context.Context, ghOauthToken string) GhClientPair {
+func createGhTokenClientPair(ctx context.Context, ghOauthTokenEnvVarName string) GhClientPair {
 	var githubRestAltURL string
 	var githubGraphqlAltURL string
-	githubHost := getEnv("GITHUB_HOST", "")
+	githubHost := tenancy.Getenv(ctx, "GITHUB_HOST", "")
 	if githubHost != "" {
 		githubRestAltURL = fmt.Sprintf("https://%s/api/v3", githubHost)
 		githubGraphqlAltURL = fmt.Sprintf("https://%s/api/graphql", githubHost)
@@ -170,37 +164,66 @@ func createGhTokenClientPair(ctx context.Context, ghOauthToken string) GhClientP
 	}
 
 	return GhClientPair{
-		v3Client: createGithubRestClient(ghOauthToken, githubRestAltURL, ctx),
-		v4Client: createGithubGraphQlClient(ghOauthToken, githubGraphqlAltURL),
+		v3Client: createGithubRestClient(ctx, secretTokenSource{name: ghOauthTokenEnvVarName, tenant: tenancy.FromContext(ctx)}, tenancy.FromContext(ctx).CacheKey(ghOauthTokenEnvVarName), githubRestAltURL),
+		v4Client: createGithubGraphQlClient(secretTokenSource{name: ghOauthTokenEnvVarName, tenant: tenancy.FromContext(ctx)}, githubGraphqlAltURL),
 	}
 }
 
-func (gcp *GhClientPair) GetAndCache(ghClientCache *lru.Cache[string, GhClientPair], ghAppIdEnvVarName string, ghAppPKeyPathEnvVarName string, ghOauthTokenEnvVarName string, repoOwner string, ctx context.Context) {
-	githubAppId := getEnv(ghAppIdEnvVarName, "")
+// CredentialEnvVars are the names of the env vars(or secrets) holding a set of GitHub credentials, either a GitHub App ID and private key or an OAuth token
+type CredentialEnvVars struct {
+	AppID             string
+	AppPrivateKeyPath string
+	OauthToken        string
+}
+
+func credentialEnvVars(prefix string) CredentialEnvVars {
+	return CredentialEnvVars{
+		AppID:             prefix + "GITHUB_APP_ID",
+		AppPrivateKeyPath: prefix + "GITHUB_APP_PRIVATE_KEY_PATH",
+		OauthToken:        prefix + "GITHUB_OAUTH_TOKEN",
+	}
+}
+
+// MainCredentialEnvVars returns the env var names of the main credentials, GITHUB_CREDENTIALS_PREFIX(e.g. TEAM_A_ for TEAM_A_GITHUB_APP_ID) selects another credential set
+func MainCredentialEnvVars(ctx context.Context) CredentialEnvVars {
+	return credentialEnvVars(tenancy.Getenv(ctx, "GITHUB_CREDENTIALS_PREFIX", ""))
+}
+
+// ApproverCredentialEnvVars returns the env var names of the PR approver credentials, APPROVER_GITHUB_CREDENTIALS_PREFIX defaults to APPROVER_
+func ApproverCredentialEnvVars(ctx context.Context) CredentialEnvVars {
+	return credentialEnvVars(tenancy.Getenv(ctx, "APPROVER_GITHUB_CREDENTIALS_PREFIX", "APPROVER_"))
+}
+
+// GetAndCache uses the settings of the tenant in ctx(if any), cached clients are namespaced by tenant
+func (gcp *GhClientPair) GetAndCache(ghClientCache *lru.Cache[string, GhClientPair], credentials CredentialEnvVars, repoOwner string, ctx context.Context) {
+	tenant := tenancy.FromContext(ctx)
+	githubAppId := tenancy.Getenv(ctx, credentials.AppID, "")
 	var keyExist bool
 	if githubAppId != "" {
-		*gcp, keyExist = ghClientCache.Get(repoOwner)
+		*gcp, keyExist = ghClientCache.Get(tenant.CacheKey(repoOwner))
 		if keyExist {
 			log.Debugf("Found cached client for %s", repoOwner)
 		} else {
-			log.Infof("Did not found cached client for %s, creating one with %s/%s env vars", repoOwner, ghAppIdEnvVarName, ghAppPKeyPathEnvVarName)
+			log.Infof("Did not found cached client for %s, creating one with %s/%s env vars", repoOwner, credentials.AppID, credentials.AppPrivateKeyPath)
 			githubAppIdint, err := strconv.ParseInt(githubAppId, 10, 64)
 			if err != nil {
-				log.Fatalf("GITHUB_APP_ID value could not converted to int64, %v", err)
+				log.Fatalf("%s value could not converted to int64, %v", credentials.AppID, err)
 			}
-			*gcp = createGhAppClientPair(ctx, githubAppIdint, repoOwner, ghAppPKeyPathEnvVarName)
-			ghClientCache.Add(repoOwner, *gcp)
+			*gcp = createGhAppClientPair(ctx, githubAppIdint, repoOwner, credentials)
+			ghClientCache.Add(tenant.CacheKey(repoOwner), *gcp)
 		}
 	} else {
-		*gcp, keyExist = ghClientCache.Get("global")
+		*gcp, keyExist = ghClientCache.Get(tenant.CacheKey("global"))
 		if keyExist {
 			log.Debug("Found global cached client")
 		} else {
-			log.Infof("Did not found global cached client, creating one with %s env var", ghOauthTokenEnvVarName)
-			ghOauthToken := getCrucialEnv(ghOauthTokenEnvVarName)
+			log.Infof("Did not found global cached client, creating one with %s env var", credentials.OauthToken)
+			if _, ok := tenant.Lookup(credentials.OauthToken); !ok {
+				log.Fatalf("%s environment variable is required", credentials.OauthToken)
+			}
 
-			*gcp = createGhTokenClientPair(ctx, ghOauthToken)
-			ghClientCache.Add("global", *gcp)
+			*gcp = createGhTokenClientPair(ctx, credentials.OauthToken)
+			ghClientCache.Add(tenant.CacheKey("global"), *gcp)
 		}
 	}
 }
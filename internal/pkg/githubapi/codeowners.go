@@ -0,0 +1,155 @@
+package githubapi
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/argocd"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+)
+
+// codeOwnersFiles are the locations GitHub reads CODEOWNERS from, in the order it looks them up
+var codeOwnersFiles = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+type codeOwnersRule struct {
+	pattern *regexp.Regexp
+	// As written in the file, e.g. "@org/team" or "@user"
+	owners []string
+}
+
+// codeOwnersPatternRegex converts a CODEOWNERS(gitignore style) pattern to a regex matching the paths it owns, including everything under a matching directory
+func codeOwnersPatternRegex(pattern string) (*regexp.Regexp, error) {
+	// Patterns with a slash(other than a trailing one) are relative to the repo root, others match at any depth
+	anchored := strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	pattern = strings.Trim(pattern, "/")
+	var regex strings.Builder
+	if anchored {
+		regex.WriteString("^")
+	} else {
+		regex.WriteString("^(.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			regex.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			regex.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			regex.WriteString("[^/]*")
+		case pattern[i] == '?':
+			regex.WriteString("[^/]")
+		default:
+			regex.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	regex.WriteString("(/.*)?$")
+	return regexp.Compile(regex.String())
+}
+
+// parseCodeOwners returns the rules of a CODEOWNERS file, invalid lines are skipped like GitHub does
+func parseCodeOwners(content string) []codeOwnersRule {
+	rules := []codeOwnersRule{}
+	for _, line := range strings.Split(content, "\n") {
+		if i := strings.Index(line, "#"); i != -1 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		pattern, err := codeOwnersPatternRegex(fields[0])
+		if err != nil {
+			continue
+		}
+		owners := []string{}
+		for _, owner := range fields[1:] {
+			// Owners can also be emails, those can't be mentioned or requested as reviewers
+			if strings.HasPrefix(owner, "@") {
+				owners = append(owners, owner)
+			}
+		}
+		rules = append(rules, codeOwnersRule{pattern: pattern, owners: owners})
+	}
+	return rules
+}
+
+// codeOwnersOfPath returns the owners of the last rule matching filePath, as the last matching rule takes precedence
+func codeOwnersOfPath(rules []codeOwnersRule, filePath string) []string {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].pattern.MatchString(filePath) {
+			return rules[i].owners
+		}
+	}
+	return nil
+}
+
+// codeOwnersReviewers converts the owners of paths to review requests, teams must belong to the repo owner org
+func codeOwnersReviewers(rules []codeOwnersRule, paths []string) requiredApprovers {
+	users := map[string]bool{}
+	teams := map[string]bool{}
+	for _, p := range paths {
+		for _, owner := range codeOwnersOfPath(rules, p) {
+			if strings.Contains(owner, "/") {
+				teams[normalizeTeamSlug(owner)] = true
+			} else {
+				users[strings.TrimPrefix(owner, "@")] = true
+			}
+		}
+	}
+	return sortedApprovers(users, teams)
+}
+
+// loadCodeOwners returns the rules of the CODEOWNERS file of branch, no rules when the repo doesn't have one
+func loadCodeOwners(ghPrClientDetails GhPrClientDetails, branch string) ([]codeOwnersRule, error) {
+	for _, codeOwnersFile := range codeOwnersFiles {
+		fileContent, _, resp, err := ghPrClientDetails.GhClientPair.v3Client.Repositories.GetContents(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, codeOwnersFile, &github.RepositoryContentGetOptions{Ref: branch})
+		prom.InstrumentGhCall(resp)
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("get %s: %w", codeOwnersFile, err)
+		}
+		content, err := fileContent.GetContent()
+		if err != nil {
+			return nil, fmt.Errorf("decode %s: %w", codeOwnersFile, err)
+		}
+		return parseCodeOwners(content), nil
+	}
+	return nil, nil
+}
+
+// requestCodeOwnerReviews asks the owners of the promoted paths to review a promotion PR
+func requestCodeOwnerReviews(ghPrClientDetails GhPrClientDetails, prNumber int, promotedPaths []string, branch string) error {
+	rules, err := loadCodeOwners(ghPrClientDetails, branch)
+	if err != nil {
+		return err
+	}
+	return requestRequiredReviews(ghPrClientDetails, prNumber, codeOwnersReviewers(rules, promotedPaths))
+}
+
+// mentionDiffErrorOwners comments a mention of the owners of the components whose diff failed, components without owners aren't listed
+func mentionDiffErrorOwners(ghPrClientDetails GhPrClientDetails, diffOfChangedComponents []argocd.DiffResult, branch string) error {
+	rules, err := loadCodeOwners(ghPrClientDetails, branch)
+	if err != nil {
+		return err
+	}
+	comment := ""
+	for _, diffResult := range diffOfChangedComponents {
+		if diffResult.DiffError == nil {
+			continue
+		}
+		if owners := codeOwnersOfPath(rules, diffResult.ComponentPath); len(owners) > 0 {
+			comment += fmt.Sprintf("* `%s`: %s\n", diffResult.ComponentPath, strings.Join(owners, " "))
+		}
+	}
+	if comment == "" {
+		return nil
+	}
+	return commentPR(ghPrClientDetails, "⚠️ The diff of these components failed, calling their code owners:\n"+comment)
+}
@@ -0,0 +1,93 @@
+package githubapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/argocd"
+)
+
+const testCodeOwners = `# Default owners
+*                 @AnOwner/platform
+/env/prod/        @AnOwner/sre @alice  # prod needs SRE eyes
+env/*/payments    @AnOwner/payments bob@example.com
+**/experimental/  @bob
+`
+
+func TestCodeOwnersOfPath(t *testing.T) {
+	t.Parallel()
+	rules := parseCodeOwners(testCodeOwners)
+	tests := map[string]struct {
+		path     string
+		expected []string
+	}{
+		"default rule":              {path: "env/staging/app", expected: []string{"@AnOwner/platform"}},
+		"directory rule":            {path: "env/prod/app", expected: []string{"@AnOwner/sre", "@alice"}},
+		"later rule wins":           {path: "env/prod/payments", expected: []string{"@AnOwner/payments"}},
+		"wildcard doesn't nest":     {path: "env/prod/eu/payments", expected: []string{"@AnOwner/sre", "@alice"}},
+		"unanchored directory rule": {path: "env/dev/experimental/app", expected: []string{"@bob"}},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, codeOwnersOfPath(rules, tc.path))
+		})
+	}
+}
+
+func TestCodeOwnersReviewers(t *testing.T) {
+	t.Parallel()
+	reviewers := codeOwnersReviewers(parseCodeOwners(testCodeOwners), []string{"env/prod/app", "env/prod/payments", "env/staging/app"})
+	assert.Equal(t, requiredApprovers{Users: []string{"alice"}, Teams: []string{"payments", "platform", "sre"}}, reviewers)
+}
+
+func TestMentionDiffErrorOwners(t *testing.T) {
+	t.Parallel()
+	var commentBody string
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposContentsByOwnerByRepoByPath,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/repos/AnOwner/Arepo/contents/CODEOWNERS" {
+					mock.WriteError(w, http.StatusNotFound, "Not Found")
+					return
+				}
+				_, _ = w.Write(mock.MustMarshal(github.RepositoryContent{Content: github.String(testCodeOwners)}))
+			}),
+		),
+		mock.WithRequestMatchHandler(
+			mock.PostReposIssuesCommentsByOwnerByRepoByIssueNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				comment := github.IssueComment{}
+				_ = json.NewDecoder(r.Body).Decode(&comment)
+				commentBody = comment.GetBody()
+				_, _ = w.Write(mock.MustMarshal(comment))
+			}),
+		),
+	)
+	ghPrClientDetails := GhPrClientDetails{
+		Ctx:          context.Background(),
+		GhClientPair: &GhClientPair{v3Client: github.NewClient(mockedHTTPClient)},
+		Owner:        "AnOwner",
+		Repo:         "Arepo",
+		PrNumber:     1,
+		PrLogger:     log.WithFields(log.Fields{"repo": "AnOwner/Arepo"}),
+	}
+	diffResults := []argocd.DiffResult{
+		{ComponentPath: "env/prod/app", DiffError: errors.New("rpc error")},
+		{ComponentPath: "env/staging/app"},
+	}
+
+	err := mentionDiffErrorOwners(ghPrClientDetails, diffResults, "main")
+	assert.NoError(t, err)
+	assert.Contains(t, commentBody, "* `env/prod/app`: @AnOwner/sre @alice")
+	assert.NotContains(t, commentBody, "env/staging/app")
+}
@@ -0,0 +1,55 @@
+package githubapi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// splitCommentsIndex renders the index of a diff split in one comment per component, partURLs has the URLs of the parts posted so far(in componentPaths order)
+func splitCommentsIndex(title string, componentPaths []string, partURLs []string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "### %s index\n\nThe diff is too large for a single comment, it's split in %d comments:\n\n", title, len(componentPaths))
+	for i, componentPath := range componentPaths {
+		if i < len(partURLs) {
+			fmt.Fprintf(&sb, "- [x] [`%s`](%s)\n", componentPath, partURLs[i])
+		} else {
+			fmt.Fprintf(&sb, "- [ ] `%s`\n", componentPath)
+		}
+	}
+	if len(partURLs) == len(componentPaths) {
+		fmt.Fprintf(&sb, "\n:white_check_mark: All %d parts have been posted\n", len(componentPaths))
+	} else {
+		fmt.Fprintf(&sb, "\n:hourglass: %d of %d parts posted so far\n", len(partURLs), len(componentPaths))
+	}
+	return sb.String()
+}
+
+// postDiffComments posts the comments of a diff, when it's split in one comment per component(in componentPaths order) an index comment is posted first
+// and updated with a link to each part as it's posted, so reviewers can tell when all the parts have arrived
+func postDiffComments(ghPrClientDetails GhPrClientDetails, title string, componentPaths []string, comments []string) error {
+	if len(comments) < 2 || len(comments) != len(componentPaths) {
+		for _, comment := range comments {
+			err := commentPR(ghPrClientDetails, comment)
+			if err != nil {
+				return fmt.Errorf("commenting on PR: %w", err)
+			}
+		}
+		return nil
+	}
+
+	index, err := ghPrClientDetails.createPrComment(splitCommentsIndex(title, componentPaths, nil))
+	if err != nil {
+		return fmt.Errorf("commenting on PR: %w", err)
+	}
+	partURLs := []string{}
+	for _, comment := range comments {
+		part, err := ghPrClientDetails.createPrComment(comment)
+		if err != nil {
+			return fmt.Errorf("commenting on PR: %w", err)
+		}
+		partURLs = append(partURLs, part.GetHTMLURL())
+		// A stale index is only cosmetic, the parts are still posted
+		_ = ghPrClientDetails.editPrComment(index.GetID(), splitCommentsIndex(title, componentPaths, partURLs))
+	}
+	return nil
+}
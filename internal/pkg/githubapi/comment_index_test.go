@@ -0,0 +1,79 @@
+package githubapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitCommentsIndex(t *testing.T) {
+	t.Parallel()
+	componentPaths := []string{"clusters/prod/app1", "clusters/prod/app2"}
+
+	inProgress := splitCommentsIndex("ArgoCD diff", componentPaths, []string{"https://github.com/o/r/pull/1#issuecomment-1"})
+	assert.Contains(t, inProgress, "- [x] [`clusters/prod/app1`](https://github.com/o/r/pull/1#issuecomment-1)\n")
+	assert.Contains(t, inProgress, "- [ ] `clusters/prod/app2`\n")
+	assert.Contains(t, inProgress, "1 of 2 parts posted so far")
+
+	done := splitCommentsIndex("ArgoCD diff", componentPaths, []string{"https://github.com/o/r/pull/1#issuecomment-1", "https://github.com/o/r/pull/1#issuecomment-2"})
+	assert.Contains(t, done, "All 2 parts have been posted")
+}
+
+func TestPostDiffCommentsUpdatesIndex(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	var nextID int64
+	var createdBodies []string
+	var lastIndexBody string
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.PostReposIssuesCommentsByOwnerByRepoByIssueNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				var comment github.IssueComment
+				_ = json.NewDecoder(r.Body).Decode(&comment)
+				nextID++
+				createdBodies = append(createdBodies, comment.GetBody())
+				comment.ID = github.Int64(nextID)
+				comment.HTMLURL = github.String(fmt.Sprintf("https://github.com/AnOwner/Arepo/pull/1#issuecomment-%d", nextID))
+				_, _ = w.Write(mock.MustMarshal(comment))
+			}),
+		),
+		mock.WithRequestMatchHandler(
+			mock.PatchReposIssuesCommentsByOwnerByRepoByCommentId,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				assert.Equal(t, "/repos/AnOwner/Arepo/issues/comments/1", r.URL.Path)
+				var comment github.IssueComment
+				_ = json.NewDecoder(r.Body).Decode(&comment)
+				lastIndexBody = comment.GetBody()
+				_, _ = w.Write(mock.MustMarshal(comment))
+			}),
+		),
+	)
+	details := GhPrClientDetails{
+		Ctx:          context.Background(),
+		GhClientPair: &GhClientPair{v3Client: github.NewClient(mockedHTTPClient)},
+		Owner:        "AnOwner",
+		Repo:         "Arepo",
+		PrNumber:     1,
+		PrLogger:     log.WithFields(log.Fields{"repo": "AnOwner/Arepo"}),
+	}
+
+	err := postDiffComments(details, "ArgoCD diff", []string{"clusters/prod/app1", "clusters/prod/app2"}, []string{"part 1", "part 2"})
+	assert.NoError(t, err)
+	assert.Len(t, createdBodies, 3)
+	assert.Contains(t, createdBodies[0], "ArgoCD diff index")
+	assert.Contains(t, lastIndexBody, "[`clusters/prod/app2`](https://github.com/AnOwner/Arepo/pull/1#issuecomment-3)")
+	assert.Contains(t, lastIndexBody, "All 2 parts have been posted")
+}
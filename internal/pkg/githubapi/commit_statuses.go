@@ -0,0 +1,83 @@
+package githubapi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+)
+
+// setFeatureCommitStatus sets the commit status of a single feature on the PR head commit, next to the overall one set by SetCommitStatus
+func setFeatureCommitStatus(ghPrClientDetails GhPrClientDetails, statusContext string, state string, description string) {
+	commitStatus := &github.RepoStatus{
+		State:   github.String(state),
+		Context: github.String(statusContext),
+		// The description is limited to 140 characters
+		Description: github.String(firstN(description, 140)),
+	}
+	// Like SetCommitStatus, this shouldn't fail when the event processing times out
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ghPrClientDetails.Ctx), time.Minute)
+	defer cancel()
+	_, resp, err := retryGhWrite(ctx, "create_status", func() (*github.RepoStatus, *github.Response, error) {
+		return ghPrClientDetails.GhClientPair.v3Client.Repositories.CreateStatus(ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, ghPrClientDetails.PrSHA, commitStatus)
+	})
+	prom.IncCommitStatusUpdateCounter(ghPrClientDetails.Owner+"/"+ghPrClientDetails.Repo, state)
+	if err != nil {
+		ghPrClientDetails.PrLogger.Errorf("Failed to set %s commit status: err=%s\n%v", statusContext, err, resp)
+	}
+}
+
+// diffCommitStatus counts the component diffs that failed in a PR event for the diff commit status, the record method does nothing on nil(commitStatusContexts.diff is unset)
+type diffCommitStatus struct {
+	mu               sync.Mutex
+	failedComponents int
+}
+
+func (s *diffCommitStatus) recordFailures(count int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failedComponents += count
+}
+
+// diffCommitStatusState returns the state and description of the diff commit status, handlingErr is the error the event handling ended with
+func diffCommitStatusState(failedComponents int, handlingErr error) (string, string) {
+	switch {
+	case handlingErr != nil:
+		return "error", "Generating the diffs failed"
+	case failedComponents > 0:
+		return "failure", fmt.Sprintf("The diff of %d components failed", failedComponents)
+	default:
+		return "success", "Diffs generated"
+	}
+}
+
+func (s *diffCommitStatus) publish(ghPrClientDetails GhPrClientDetails, statusContext string, handlingErr error) {
+	s.mu.Lock()
+	failedComponents := s.failedComponents
+	s.mu.Unlock()
+	state, description := diffCommitStatusState(failedComponents, handlingErr)
+	setFeatureCommitStatus(ghPrClientDetails, statusContext, state, description)
+}
+
+// setPromotionGateCommitStatus sets the promotion commit status of a promotion PR, pending until the required approvals of its paths are received
+func setPromotionGateCommitStatus(ghPrClientDetails GhPrClientDetails, config *cfg.Config, paths []string) error {
+	missing, err := missingRequiredApprovals(ghPrClientDetails, ghPrClientDetails.PrNumber, generateRequiredApprovers(config, paths))
+	if err != nil {
+		setFeatureCommitStatus(ghPrClientDetails, config.CommitStatusContexts.Promotion, "error", "Checking the required approvals failed")
+		return err
+	}
+	if len(missing) > 0 {
+		setFeatureCommitStatus(ghPrClientDetails, config.CommitStatusContexts.Promotion, "pending", "Waiting for approval from "+strings.Join(missing, ", "))
+		return nil
+	}
+	setFeatureCommitStatus(ghPrClientDetails, config.CommitStatusContexts.Promotion, "success", "The promotion has its required approvals")
+	return nil
+}
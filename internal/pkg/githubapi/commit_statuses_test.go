@@ -0,0 +1,121 @@
+package githubapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+)
+
+func TestDiffCommitStatusState(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		failedComponents    int
+		handlingErr         error
+		expectedState       string
+		expectedDescription string
+	}{
+		"All diffs generated": {
+			expectedState:       "success",
+			expectedDescription: "Diffs generated",
+		},
+		"Some component diffs failed": {
+			failedComponents:    2,
+			expectedState:       "failure",
+			expectedDescription: "The diff of 2 components failed",
+		},
+		"Event handling failed": {
+			failedComponents:    2,
+			handlingErr:         errors.New("boom"),
+			expectedState:       "error",
+			expectedDescription: "Generating the diffs failed",
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			state, description := diffCommitStatusState(tc.failedComponents, tc.handlingErr)
+			assert.Equal(t, tc.expectedState, state)
+			assert.Equal(t, tc.expectedDescription, description)
+		})
+	}
+}
+
+func TestDiffCommitStatusRecordsNothingWhenDisabled(t *testing.T) {
+	t.Parallel()
+	ghPrClientDetails := GhPrClientDetails{}
+	ghPrClientDetails.recordDiffError("Helm diff", []string{"env/prod/chart"}, errors.New("boom"))
+	assert.Nil(t, ghPrClientDetails.diffStatus)
+
+	ghPrClientDetails.diffStatus = &diffCommitStatus{}
+	ghPrClientDetails.recordDiffError("Helm diff", []string{"env/prod/chart", "env/prod/other"}, errors.New("boom"))
+	ghPrClientDetails.recordComponentDiffs("Helm diff", []ComponentDiff{{ComponentPath: "env/prod/a", Err: errors.New("boom")}, {ComponentPath: "env/prod/b"}})
+	assert.Equal(t, 3, ghPrClientDetails.diffStatus.failedComponents)
+}
+
+func TestSetPromotionGateCommitStatus(t *testing.T) {
+	t.Parallel()
+	config := &cfg.Config{
+		RequiredApprovers:    []cfg.RequiredApprovers{{TargetPathRegex: "^env/prod/.*", Users: []string{"alice", "bob"}}},
+		CommitStatusContexts: cfg.CommitStatusContexts{Promotion: "telefonistka/promotion"},
+	}
+	tests := map[string]struct {
+		reviews             []github.PullRequestReview
+		expectedState       string
+		expectedDescription string
+	}{
+		"Missing approval": {
+			reviews:             []github.PullRequestReview{{User: &github.User{Login: github.String("alice")}, State: github.String("APPROVED")}},
+			expectedState:       "pending",
+			expectedDescription: "Waiting for approval from bob",
+		},
+		"All approvals": {
+			reviews: []github.PullRequestReview{
+				{User: &github.User{Login: github.String("alice")}, State: github.String("APPROVED")},
+				{User: &github.User{Login: github.String("bob")}, State: github.String("APPROVED")},
+			},
+			expectedState:       "success",
+			expectedDescription: "The promotion has its required approvals",
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var status github.RepoStatus
+			mockedHTTPClient := mock.NewMockedHTTPClient(
+				mock.WithRequestMatch(mock.GetReposPullsReviewsByOwnerByRepoByPullNumber, tc.reviews),
+				mock.WithRequestMatchHandler(
+					mock.PostReposStatusesByOwnerByRepoBySha,
+					http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						assert.Equal(t, "/repos/AnOwner/Arepo/statuses/abc123", r.URL.Path)
+						_ = json.NewDecoder(r.Body).Decode(&status)
+						_, _ = w.Write(mock.MustMarshal(status))
+					}),
+				),
+			)
+			ghPrClientDetails := GhPrClientDetails{
+				Ctx:          context.Background(),
+				GhClientPair: &GhClientPair{v3Client: github.NewClient(mockedHTTPClient)},
+				Owner:        "AnOwner",
+				Repo:         "Arepo",
+				PrNumber:     7,
+				PrSHA:        "abc123",
+				PrLogger:     log.WithField("test", t.Name()),
+			}
+
+			assert.NoError(t, setPromotionGateCommitStatus(ghPrClientDetails, config, []string{"env/prod/c1"}))
+			assert.Equal(t, "telefonistka/promotion", status.GetContext())
+			assert.Equal(t, tc.expectedState, status.GetState())
+			assert.Equal(t, tc.expectedDescription, status.GetDescription())
+		})
+	}
+}
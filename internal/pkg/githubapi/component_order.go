@@ -0,0 +1,166 @@
+package githubapi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+)
+
+// componentLayer is a group of components of a promotion whose dependencies were promoted by an earlier layer
+type componentLayer struct {
+	ComponentNames []string          `json:"componentNames"`
+	SyncPaths      map[string]string `json:"syncPaths"` // key is target, value is source
+}
+
+// componentOrderMetadata is persisted in the promotion PRs of ordered components(see cfg.ComponentDependency), merging one opens the promotion PR of the next layer
+type componentOrderMetadata struct {
+	// The promotion PR of the components this one waited for
+	DependsOnPr       int              `json:"dependsOnPr,omitempty"`
+	SourcePath        string           `json:"sourcePath"`
+	TargetPaths       []string         `json:"targetPaths"`
+	TargetDescription string           `json:"targetDescription"`
+	AutoMerge         bool             `json:"autoMerge"`
+	Next              []componentLayer `json:"next,omitempty"`
+	// The canary steps of the promotion continue once all its layers were merged
+	Steps *promotionStepsMetadata `json:"steps,omitempty"`
+}
+
+// componentLayers orders the components by their dependencies, each layer only depends on the earlier ones.
+// Dependencies on components that aren't promoted are ignored
+func componentLayers(dependencies []cfg.ComponentDependency, components []string) [][]string {
+	dependsOn := map[string][]string{}
+	for _, dependency := range dependencies {
+		if contains(components, dependency.Component) {
+			for _, d := range dependency.DependsOn {
+				if contains(components, d) && d != dependency.Component {
+					dependsOn[dependency.Component] = append(dependsOn[dependency.Component], d)
+				}
+			}
+		}
+	}
+	remaining := append([]string{}, components...)
+	sort.Strings(remaining)
+	promoted := map[string]bool{}
+	layers := [][]string{}
+	for len(remaining) > 0 {
+		layer := []string{}
+		waiting := []string{}
+		for _, component := range remaining {
+			ready := true
+			for _, d := range dependsOn[component] {
+				if !promoted[d] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				layer = append(layer, component)
+			} else {
+				waiting = append(waiting, component)
+			}
+		}
+		if len(layer) == 0 {
+			// Cycles are rejected when the configuration is parsed, this only guards the loop
+			layers = append(layers, waiting)
+			break
+		}
+		for _, component := range layer {
+			promoted[component] = true
+		}
+		layers = append(layers, layer)
+		remaining = waiting
+	}
+	return layers
+}
+
+// componentSyncPaths returns the sync paths of the promotion that sync one of the components
+func componentSyncPaths(syncPaths map[string]string, components []string) map[string]string {
+	result := map[string]string{}
+	for target, source := range syncPaths {
+		for _, component := range components {
+			if source == component || strings.HasSuffix(source, "/"+component) {
+				result[target] = source
+				break
+			}
+		}
+	}
+	return result
+}
+
+// orderPromotionComponents limits the promotions of dependent components to the components whose dependencies aren't promoted with them, the other layers are recorded in the promotion PR metadata
+func orderPromotionComponents(dependencies []cfg.ComponentDependency, promotions map[string]PromotionInstance) map[string]PromotionInstance {
+	if len(dependencies) == 0 {
+		return promotions
+	}
+	result := map[string]PromotionInstance{}
+	for key, promotion := range promotions {
+		layers := []componentLayer{}
+		for _, components := range componentLayers(dependencies, promotion.Metadata.ComponentNames) {
+			// Components can be skipped by their in-component configuration
+			if syncPaths := componentSyncPaths(promotion.ComputedSyncPaths, components); len(syncPaths) > 0 {
+				layers = append(layers, componentLayer{ComponentNames: components, SyncPaths: syncPaths})
+			}
+		}
+		if len(layers) < 2 {
+			result[key] = promotion
+			continue
+		}
+		promotion.Metadata.ComponentOrder = &componentOrderMetadata{
+			SourcePath:        promotion.Metadata.SourcePath,
+			TargetPaths:       promotion.Metadata.TargetPaths,
+			TargetDescription: promotion.Metadata.TargetDescription,
+			AutoMerge:         promotion.Metadata.AutoMerge,
+			Next:              layers[1:],
+			Steps:             promotion.Metadata.Steps,
+		}
+		promotion.Metadata.Steps = nil
+		promotion.Metadata.ComponentNames = layers[0].ComponentNames
+		promotion.ComputedSyncPaths = layers[0].SyncPaths
+		result[key] = promotion
+	}
+	return result
+}
+
+// nextComponentLayer returns the promotion of the components that waited for the merged promotion PR
+func nextComponentLayer(order *componentOrderMetadata, prNumber int, pathNames map[string]string) (string, PromotionInstance, bool) {
+	if order == nil || len(order.Next) == 0 {
+		return "", PromotionInstance{}, false
+	}
+	next := order.Next[0]
+	nextOrder := *order
+	nextOrder.DependsOnPr = prNumber
+	nextOrder.Next = order.Next[1:]
+	promotion := PromotionInstance{
+		Metadata: PromotionInstanceMetaData{
+			SourcePath:                     order.SourcePath,
+			TargetPaths:                    order.TargetPaths,
+			TargetDescription:              order.TargetDescription,
+			ComponentNames:                 next.ComponentNames,
+			PerComponentSkippedTargetPaths: map[string][]string{},
+			AutoMerge:                      order.AutoMerge,
+			PathNames:                      pathNames,
+			ComponentOrder:                 &nextOrder,
+		},
+		ComputedSyncPaths: next.SyncPaths,
+	}
+	if len(nextOrder.Next) == 0 {
+		// The last layer continues the canary steps
+		promotion.Metadata.Steps = nextOrder.Steps
+		nextOrder.Steps = nil
+	}
+	return order.SourcePath + ">" + strings.Join(order.TargetPaths, "|") + ">" + strings.Join(next.ComponentNames, ","), promotion, true
+}
+
+// componentOrderPrBody links the promotion PR the components waited for and lists the components that wait for this one
+func componentOrderPrBody(order *componentOrderMetadata) string {
+	body := ""
+	if order.DependsOnPr != 0 {
+		body += fmt.Sprintf("\n⛓️ These components were promoted after their dependencies in #%d.\n", order.DependsOnPr)
+	}
+	if len(order.Next) > 0 {
+		body += fmt.Sprintf("\n⛓️ `%s` depend on these components, their promotion PR is opened once this PR is merged.\n", strings.Join(order.Next[0].ComponentNames, "`, `"))
+	}
+	return body
+}
@@ -0,0 +1,81 @@
+package githubapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+)
+
+func TestComponentLayers(t *testing.T) {
+	t.Parallel()
+	dependencies := []cfg.ComponentDependency{
+		{Component: "controller", DependsOn: []string{"crds"}},
+		{Component: "app", DependsOn: []string{"controller", "crds"}},
+	}
+	tests := map[string]struct {
+		components []string
+		expected   [][]string
+	}{
+		"No dependencies promoted together": {
+			components: []string{"controller", "other"},
+			expected:   [][]string{{"controller", "other"}},
+		},
+		"Chain": {
+			components: []string{"app", "other", "controller", "crds"},
+			expected:   [][]string{{"crds", "other"}, {"controller"}, {"app"}},
+		},
+		"Skipped middle dependency": {
+			components: []string{"app", "crds"},
+			expected:   [][]string{{"crds"}, {"app"}},
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, componentLayers(dependencies, tc.components))
+		})
+	}
+}
+
+func TestOrderPromotionComponents(t *testing.T) {
+	t.Parallel()
+	dependencies := []cfg.ComponentDependency{{Component: "controller", DependsOn: []string{"crds"}}}
+	steps := &promotionStepsMetadata{Step: 1, Steps: 2}
+	promotions := map[string]PromotionInstance{
+		"env/staging/>env/prod/": {
+			Metadata: PromotionInstanceMetaData{
+				SourcePath:        "env/staging/",
+				TargetPaths:       []string{"env/prod/"},
+				TargetDescription: "prod",
+				ComponentNames:    []string{"controller", "crds"},
+				Steps:             steps,
+			},
+			ComputedSyncPaths: map[string]string{
+				"env/prod/controller": "env/staging/controller",
+				"env/prod/crds":       "env/staging/crds",
+			},
+		},
+	}
+
+	ordered := orderPromotionComponents(dependencies, promotions)
+	first := ordered["env/staging/>env/prod/"]
+	assert.Equal(t, []string{"crds"}, first.Metadata.ComponentNames)
+	assert.Equal(t, map[string]string{"env/prod/crds": "env/staging/crds"}, first.ComputedSyncPaths)
+	assert.Nil(t, first.Metadata.Steps)
+	assert.Contains(t, componentOrderPrBody(first.Metadata.ComponentOrder), "`controller` depend on these components")
+
+	key, second, ok := nextComponentLayer(first.Metadata.ComponentOrder, 7, nil)
+	assert.True(t, ok)
+	assert.Equal(t, "env/staging/>env/prod/>controller", key)
+	assert.Equal(t, []string{"controller"}, second.Metadata.ComponentNames)
+	assert.Equal(t, map[string]string{"env/prod/controller": "env/staging/controller"}, second.ComputedSyncPaths)
+	assert.Equal(t, 7, second.Metadata.ComponentOrder.DependsOnPr)
+	// The last layer continues the canary steps
+	assert.Equal(t, steps, second.Metadata.Steps)
+	assert.Nil(t, second.Metadata.ComponentOrder.Steps)
+
+	_, _, ok = nextComponentLayer(second.Metadata.ComponentOrder, 8, nil)
+	assert.False(t, ok)
+}
@@ -0,0 +1,178 @@
+package githubapi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"github.com/hashicorp/golang-lru/v2/simplelru"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+)
+
+const (
+	defaultCachedReadsBytes   = 64 << 20
+	defaultMaxCachedReadBytes = 256 << 10
+)
+
+// The contents and git trees reads, configurations and directory listings are read again on every event
+var conditionalReadPathRegex = regexp.MustCompile(`/repos/[^/]+/[^/]+/(contents|git/trees)(/|$)`)
+
+// cachedRead is a read response, it's served again when GitHub answers its ETag with a 304(which doesn't count against the rate limit)
+type cachedRead struct {
+	etag   string
+	status int
+	header http.Header
+	body   []byte
+}
+
+// cachedReads is an LRU of read responses bounded by the total size of their bodies, bigger responses than maxEntryBytes(e.g. large files) aren't cached
+type cachedReads struct {
+	mu            sync.Mutex
+	entries       *simplelru.LRU[string, cachedRead]
+	bytes         int
+	maxBytes      int
+	maxEntryBytes int
+}
+
+func newCachedReads(maxBytes int, maxEntryBytes int) *cachedReads {
+	c := &cachedReads{maxBytes: maxBytes, maxEntryBytes: min(maxEntryBytes, maxBytes)}
+	// The size is what bounds the cache, the entry count limit is only there because simplelru requires one
+	c.entries, _ = simplelru.NewLRU[string, cachedRead](1<<20, func(_ string, evicted cachedRead) {
+		c.bytes -= len(evicted.body)
+	})
+	return c
+}
+
+func (c *cachedReads) get(key string) (cachedRead, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries.Get(key)
+}
+
+func (c *cachedReads) add(key string, read cachedRead) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries.Remove(key)
+	c.entries.Add(key, read)
+	c.bytes += len(read.body)
+	for c.bytes > c.maxBytes {
+		c.entries.RemoveOldest()
+	}
+}
+
+func (c *cachedReads) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries.Len()
+}
+
+var (
+	conditionalReadCacheOnce sync.Once
+	conditionalReadCache     *cachedReads
+)
+
+// getConditionalReadCache returns the cache shared by the conditional and public reads, sized by GITHUB_CONDITIONAL_READS_CACHE_BYTES and GITHUB_CONDITIONAL_READS_MAX_ENTRY_BYTES
+func getConditionalReadCache() *cachedReads {
+	conditionalReadCacheOnce.Do(func() {
+		maxBytes, err := strconv.Atoi(getEnv("GITHUB_CONDITIONAL_READS_CACHE_BYTES", strconv.Itoa(defaultCachedReadsBytes)))
+		if err != nil || maxBytes <= 0 {
+			maxBytes = defaultCachedReadsBytes
+		}
+		maxEntryBytes, err := strconv.Atoi(getEnv("GITHUB_CONDITIONAL_READS_MAX_ENTRY_BYTES", strconv.Itoa(defaultMaxCachedReadBytes)))
+		if err != nil || maxEntryBytes <= 0 {
+			maxEntryBytes = defaultMaxCachedReadBytes
+		}
+		conditionalReadCache = newCachedReads(maxBytes, maxEntryBytes)
+	})
+	return conditionalReadCache
+}
+
+// conditionalRoundTrip sends req with the ETag of its cached response, a 304 is answered from the cache and 200s with an ETag are cached.
+// scope keeps the cached responses of different credentials apart. The second return value is true when the response is from the cache
+func conditionalRoundTrip(rt http.RoundTripper, cache *cachedReads, scope string, req *http.Request) (*http.Response, bool, error) {
+	// Contents are served as JSON or raw depending on the Accept header
+	key := scope + " " + req.Method + " " + req.URL.String() + " " + req.Header.Get("Accept")
+	cached, isCached := cache.get(key)
+	if isCached {
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return nil, false, err
+	}
+	if resp.StatusCode == http.StatusNotModified && isCached {
+		_ = resp.Body.Close()
+		header := cached.header.Clone()
+		// The rate limit headers of the 304 are current
+		for name, values := range resp.Header {
+			header[name] = values
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", cached.status, http.StatusText(cached.status)),
+			StatusCode:    cached.status,
+			Proto:         resp.Proto,
+			ProtoMajor:    resp.ProtoMajor,
+			ProtoMinor:    resp.ProtoMinor,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(cached.body)),
+			ContentLength: int64(len(cached.body)),
+			Request:       resp.Request,
+		}, true, nil
+	}
+	etag := resp.Header.Get("ETag")
+	if etag == "" || resp.StatusCode != http.StatusOK || resp.ContentLength > int64(cache.maxEntryBytes) {
+		return resp, false, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, int64(cache.maxEntryBytes)+1))
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, false, err
+	}
+	if len(body) > cache.maxEntryBytes {
+		// Too big to cache, the caller reads the rest
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, false, nil
+	}
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	cache.add(key, cachedRead{etag: etag, status: resp.StatusCode, header: resp.Header.Clone(), body: body})
+	return resp, false, nil
+}
+
+// conditionalReadTransport makes the contents and git trees reads conditional, unchanged configurations and directory listings cost a 304 instead of a full response
+type conditionalReadTransport struct {
+	base http.RoundTripper
+	// The credentials of base, e.g. the tenant and app installation
+	scope string
+	cache *cachedReads
+}
+
+func newConditionalReadTransport(base http.RoundTripper, scope string) http.RoundTripper {
+	if enabled, err := strconv.ParseBool(getEnv("GITHUB_CONDITIONAL_READS_ENABLED", "true")); err == nil && !enabled {
+		return base
+	}
+	return &conditionalReadTransport{base: base, scope: scope, cache: getConditionalReadCache()}
+}
+
+func (t *conditionalReadTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || !conditionalReadPathRegex.MatchString(req.URL.Path) {
+		return t.base.RoundTrip(req)
+	}
+	resp, fromCache, err := conditionalRoundTrip(t.base, t.cache, t.scope, req)
+	if err == nil {
+		if fromCache {
+			prom.InstrumentConditionalRead("not_modified")
+		} else {
+			prom.InstrumentConditionalRead("modified")
+		}
+	}
+	return resp, err
+}
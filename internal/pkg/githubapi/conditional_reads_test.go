@@ -0,0 +1,106 @@
+package githubapi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConditionalReadTransport(t *testing.T) {
+	t.Parallel()
+	var fullResponses, notModified atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := `"` + r.Header.Get("Accept") + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			notModified.Add(1)
+			w.Header().Set("X-RateLimit-Remaining", "4999")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fullResponses.Add(1)
+		w.Header().Set("ETag", etag)
+		w.Header().Set("X-RateLimit-Remaining", "5000")
+		_, _ = w.Write([]byte("content of " + r.URL.Path))
+	}))
+	defer server.Close()
+	cache := newCachedReads(1<<20, 1<<10)
+	newTransport := func(scope string) *conditionalReadTransport {
+		return &conditionalReadTransport{base: http.DefaultTransport, scope: scope, cache: cache}
+	}
+	read := func(transport http.RoundTripper, method string, path string, accept string) *http.Response {
+		req, _ := http.NewRequest(method, server.URL+path, nil) //nolint:noctx
+		req.Header.Set("Accept", accept)
+		resp, err := transport.RoundTrip(req)
+		assert.NoError(t, err)
+		return resp
+	}
+
+	app := newTransport("tenant-a/123/456")
+	for range 3 {
+		resp := read(app, http.MethodGet, "/repos/org/repo/contents/telefonistka.yaml", "application/json")
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "content of /repos/org/repo/contents/telefonistka.yaml", string(body))
+	}
+	assert.Equal(t, int32(1), fullResponses.Load())
+	assert.Equal(t, int32(2), notModified.Load())
+	assert.Equal(t, "4999", read(app, http.MethodGet, "/repos/org/repo/contents/telefonistka.yaml", "application/json").Header.Get("X-RateLimit-Remaining"), "the rate limit headers of the 304 are used")
+
+	// Raw contents, other credentials, other endpoints and writes aren't answered from the cache
+	read(app, http.MethodGet, "/repos/org/repo/contents/telefonistka.yaml", "application/vnd.github.raw")
+	read(newTransport("tenant-b/123/789"), http.MethodGet, "/repos/org/repo/contents/telefonistka.yaml", "application/json")
+	read(app, http.MethodGet, "/repos/org/repo/git/trees/main", "application/json")
+	assert.Equal(t, int32(4), fullResponses.Load())
+	read(app, http.MethodGet, "/repos/org/repo/git/trees/main", "application/json")
+	assert.Equal(t, int32(4), fullResponses.Load())
+	read(app, http.MethodGet, "/repos/org/repo/pulls/1", "application/json")
+	read(app, http.MethodGet, "/repos/org/repo/pulls/1", "application/json")
+	read(app, http.MethodPut, "/repos/org/repo/contents/telefonistka.yaml", "application/json")
+	assert.Equal(t, int32(7), fullResponses.Load())
+}
+
+func TestConditionalRoundTripDoesNotCacheBigResponses(t *testing.T) {
+	t.Parallel()
+	cache := newCachedReads(1<<20, 1<<10)
+	content := strings.Repeat("a", cache.maxEntryBytes+1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"big"`)
+		_, _ = w.Write([]byte(content))
+	}))
+	defer server.Close()
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/repos/org/repo/contents/big.yaml", nil) //nolint:noctx
+	resp, fromCache, err := conditionalRoundTrip(http.DefaultTransport, cache, "scope", req)
+	assert.NoError(t, err)
+	assert.False(t, fromCache)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Len(t, body, len(content), "the whole response is still returned")
+	assert.Equal(t, 0, cache.len())
+}
+
+func TestCachedReadsSizeBound(t *testing.T) {
+	t.Parallel()
+	cache := newCachedReads(10, 4)
+	assert.Equal(t, 4, cache.maxEntryBytes)
+	assert.Equal(t, 2, newCachedReads(2, 4).maxEntryBytes, "entries can't be bigger than the cache")
+
+	cache.add("a", cachedRead{body: []byte("aaaa")})
+	cache.add("b", cachedRead{body: []byte("bbbb")})
+	_, _ = cache.get("a")
+	cache.add("c", cachedRead{body: []byte("cc")})
+	assert.Equal(t, 10, cache.bytes)
+	cache.add("d", cachedRead{body: []byte("d")})
+	assert.Equal(t, 7, cache.bytes)
+	_, found := cache.get("b")
+	assert.False(t, found, "the least recently used entry is evicted first")
+	_, found = cache.get("a")
+	assert.True(t, found)
+
+	cache.add("a", cachedRead{body: []byte("a")})
+	assert.Equal(t, 4, cache.bytes, "a replaced entry doesn't count twice")
+	assert.Equal(t, 3, cache.len())
+}
@@ -0,0 +1,74 @@
+package githubapi
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+)
+
+func configLintComment(problems []cfg.LintProblem) string {
+	var sb strings.Builder
+	sb.WriteString("Telefonistka found problems in the `telefonistka.yaml` changed by this PR:\n\n")
+	for _, problem := range problems {
+		icon := ":warning:"
+		if problem.Severity == cfg.LintError {
+			icon = ":x:"
+		}
+		if problem.Line > 0 {
+			fmt.Fprintf(&sb, "* %s line %d: %s\n", icon, problem.Line, problem.Message)
+		} else {
+			fmt.Fprintf(&sb, "* %s %s\n", icon, problem.Message)
+		}
+	}
+	return sb.String()
+}
+
+// lintChangedInRepoConfig lints the telefonistka.yaml of PRs changing it, the problems are commented(unless check-run annotations replace the comment)
+// and the optional commitStatusContexts.config status fails when the changed configuration is invalid
+func lintChangedInRepoConfig(ghPrClientDetails GhPrClientDetails, config *cfg.Config) error {
+	changedFiles, err := listPrFiles(ghPrClientDetails)
+	if err != nil {
+		return fmt.Errorf("list PR files: %w", err)
+	}
+	if !slices.Contains(changedFiles, "telefonistka.yaml") {
+		return nil
+	}
+	content, _, err := GetFileContent(ghPrClientDetails, ghPrClientDetails.Ref, "telefonistka.yaml")
+	if errors.Is(err, ErrNotFound) {
+		// Removed by the PR, the repo is no longer configured
+		return nil
+	}
+	if err != nil {
+		if config.CommitStatusContexts.Config != "" {
+			setFeatureCommitStatus(ghPrClientDetails, config.CommitStatusContexts.Config, "error", "Reading the changed configuration failed")
+		}
+		return fmt.Errorf("get the changed configuration: %w", err)
+	}
+	problems := cfg.Lint(content)
+	errorCount := 0
+	for _, problem := range problems {
+		if problem.Severity == cfg.LintError {
+			errorCount++
+		}
+	}
+	ghPrClientDetails.summary.recordCheck("Configuration lint", errorCount == 0, fmt.Sprintf("%d errors and %d warnings in telefonistka.yaml", errorCount, len(problems)-errorCount))
+	ghPrClientDetails.annotations.annotateConfigLint(problems)
+	if config.CommitStatusContexts.Config != "" {
+		if errorCount > 0 {
+			setFeatureCommitStatus(ghPrClientDetails, config.CommitStatusContexts.Config, "failure", fmt.Sprintf("The changed telefonistka.yaml has %d errors", errorCount))
+		} else {
+			setFeatureCommitStatus(ghPrClientDetails, config.CommitStatusContexts.Config, "success", "The changed telefonistka.yaml is valid")
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	ghPrClientDetails.PrLogger.Infof("Found %d problems in the changed configuration", len(problems))
+	if config.CheckRunAnnotations.Enabled && config.CheckRunAnnotations.ReplaceComments {
+		return nil
+	}
+	return commentPR(ghPrClientDetails, configLintComment(problems))
+}
@@ -0,0 +1,32 @@
+package githubapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+)
+
+func TestConfigLintComment(t *testing.T) {
+	t.Parallel()
+	comment := configLintComment([]cfg.LintProblem{
+		{Line: 3, Severity: cfg.LintWarning, Message: "field promotionPRs not found in type configuration.PromotionPath, it's ignored"},
+		{Severity: cfg.LintError, Message: "componentDependencies has a cycle through a"},
+	})
+	assert.Equal(t, "Telefonistka found problems in the `telefonistka.yaml` changed by this PR:\n\n"+
+		"* :warning: line 3: field promotionPRs not found in type configuration.PromotionPath, it's ignored\n"+
+		"* :x: componentDependencies has a cycle through a\n", comment)
+}
+
+func TestAnnotateConfigLint(t *testing.T) {
+	t.Parallel()
+	annotations := &checkAnnotations{}
+	annotations.annotateConfigLint([]cfg.LintProblem{
+		{Line: 4, Severity: cfg.LintError, Message: "invalid"},
+		{Severity: cfg.LintWarning, Message: "suspicious"},
+	})
+	assert.Equal(t, []checkAnnotation{
+		{path: "telefonistka.yaml", line: 4, level: "failure", title: "Telefonistka configuration error", message: "invalid"},
+		{path: "telefonistka.yaml", line: 1, level: "warning", title: "Telefonistka configuration warning", message: "suspicious"},
+	}, annotations.annotations)
+}
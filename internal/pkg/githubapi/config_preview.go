@@ -0,0 +1,54 @@
+package githubapi
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/go-github/v62/github"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+)
+
+// previewConfigLabel makes the changed PR checks use the telefonistka.yaml of the PR branch, when the default branch configuration enables configPreview
+const previewConfigLabel = "preview-config"
+
+// previewConfig returns the default branch configuration with only the settings that change how the PR is rendered taken from the PR branch configuration:
+// the promotion paths(and the environments and component dependencies they come from) and the diff display options.
+// Everything else(approvals, merging, commit status contexts, validation scope, the ArgoCD objects telefonistka creates...) can't be changed by the PR it gates
+func previewConfig(defaultConfig *cfg.Config, branchConfig *cfg.Config) *cfg.Config {
+	c := *defaultConfig
+	c.PromotionPaths = branchConfig.PromotionPaths
+	c.Environments = branchConfig.Environments
+	c.ComponentDependencies = branchConfig.ComponentDependencies
+	c.DiffProviders = branchConfig.DiffProviders
+	c.HelmDiff = branchConfig.HelmDiff
+	c.PrSummaryComment = branchConfig.PrSummaryComment
+	c.Argocd.IgnoreDifferences = branchConfig.Argocd.IgnoreDifferences
+	c.Argocd.IgnoreAggregatedRoles = branchConfig.Argocd.IgnoreAggregatedRoles
+	c.Argocd.ServerSideDiff = branchConfig.Argocd.ServerSideDiff
+	c.Argocd.DiffNormalization = branchConfig.Argocd.DiffNormalization
+	return &c
+}
+
+// changedPrConfig returns the configuration the changed PR checks use: the default branch one, or the previewed PR branch one when the PR is labeled preview-config.
+// The PR gets a comment saying which one was used, an invalid PR branch configuration is reported and the default branch one is used instead
+func changedPrConfig(ghPrClientDetails GhPrClientDetails, defaultConfig *cfg.Config, labels []*github.Label) *cfg.Config {
+	if !defaultConfig.ConfigPreview || !DoesPrHasLabel(labels, previewConfigLabel) {
+		return defaultConfig
+	}
+	content, _, err := GetFileContent(ghPrClientDetails, ghPrClientDetails.Ref, "telefonistka.yaml")
+	if errors.Is(err, ErrNotFound) {
+		content, err = "", nil
+	}
+	var branchConfig *cfg.Config
+	if err == nil {
+		branchConfig, err = cfg.ParseConfigFromYaml(content)
+	}
+	if err != nil {
+		ghPrClientDetails.PrLogger.Warnf("Failed to read the preview configuration of %s: err=%v", ghPrClientDetails.Ref, err)
+		_ = commentPR(ghPrClientDetails, fmt.Sprintf(":warning: **Preview config**: the `telefonistka.yaml` of `%s` is invalid, this PR was checked with the default branch configuration:\n```\n%s\n```\n", ghPrClientDetails.Ref, err))
+		return defaultConfig
+	}
+	ghPrClientDetails.PrLogger.Infof("Using the preview configuration of %s", ghPrClientDetails.Ref)
+	_ = commentPR(ghPrClientDetails, fmt.Sprintf(":test_tube: **Preview config**: this PR was checked with the `telefonistka.yaml` of `%s`(commit %s) instead of the default branch one. Only the promotion paths and diff display options are previewed, everything else still uses the default branch configuration, remove the `%s` label to go back to it.", ghPrClientDetails.Ref, ghPrClientDetails.PrSHA, previewConfigLabel))
+	return previewConfig(defaultConfig, branchConfig)
+}
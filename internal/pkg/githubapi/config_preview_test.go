@@ -0,0 +1,72 @@
+package githubapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+)
+
+func TestPreviewConfig(t *testing.T) {
+	t.Parallel()
+	defaultConfig := &cfg.Config{
+		ConfigPreview:        true,
+		PromotionPaths:       []cfg.PromotionPath{{SourcePath: "env/staging/"}},
+		RequiredApprovers:    []cfg.RequiredApprovers{{TargetPathRegex: "^prod/", Users: []string{"sre-lead"}}},
+		CommitStatusContexts: cfg.CommitStatusContexts{Promotion: "telefonistka/promotion"},
+		ManifestValidation:   cfg.ManifestValidationConfig{Enabled: true, CommitStatusContext: "telefonistka/manifests"},
+		Argocd: cfg.ArgocdConfig{
+			CommentDiffonPR: true,
+			TempAppObject:   cfg.TempAppObjectConfig{Project: "previews", Namespace: "argocd"},
+		},
+	}
+	branchConfig := &cfg.Config{
+		PromotionPaths:       []cfg.PromotionPath{{SourcePath: "env/dev/"}},
+		PrSummaryComment:     true,
+		HelmDiff:             cfg.HelmDiffConfig{Enabled: true},
+		ShadowMode:           true,
+		CommitStatusContexts: cfg.CommitStatusContexts{Promotion: "ci/green", Diff: "ci/required"},
+		ManifestValidation:   cfg.ManifestValidationConfig{IgnoreFileRegexes: []string{".*"}, CommitStatusContext: "ci/required"},
+		Argocd: cfg.ArgocdConfig{
+			CommentDiffonPR:     false,
+			AutoMergeNoDiffPRs:  true,
+			ServerSideDiff:      true,
+			OversizedDiffUpload: "gist",
+			TempAppObject:       cfg.TempAppObjectConfig{Project: "default", Namespace: "kube-system"},
+			NoDiff:              cfg.NoDiffConfig{CommitStatusContext: "ci/required"},
+		},
+	}
+
+	c := previewConfig(defaultConfig, branchConfig)
+	// How the PR is rendered follows the PR branch
+	assert.Equal(t, branchConfig.PromotionPaths, c.PromotionPaths)
+	assert.True(t, c.PrSummaryComment)
+	assert.True(t, c.HelmDiff.Enabled)
+	assert.True(t, c.Argocd.ServerSideDiff)
+	// Everything else doesn't
+	tests := map[string]struct {
+		expected interface{}
+		actual   interface{}
+	}{
+		"configPreview":                          {expected: true, actual: c.ConfigPreview},
+		"shadowMode":                             {expected: false, actual: c.ShadowMode},
+		"requiredApprovers":                      {expected: defaultConfig.RequiredApprovers, actual: c.RequiredApprovers},
+		"commitStatusContexts":                   {expected: defaultConfig.CommitStatusContexts, actual: c.CommitStatusContexts},
+		"manifestValidation.commitStatusContext": {expected: "telefonistka/manifests", actual: c.ManifestValidation.CommitStatusContext},
+		"manifestValidation.ignoreFileRegexes":   {expected: []string(nil), actual: c.ManifestValidation.IgnoreFileRegexes},
+		"argocd.commentDiffonPR":                 {expected: true, actual: c.Argocd.CommentDiffonPR},
+		"argocd.autoMergeNoDiffPRs":              {expected: false, actual: c.Argocd.AutoMergeNoDiffPRs},
+		"argocd.oversizedDiffUpload":             {expected: "", actual: c.Argocd.OversizedDiffUpload},
+		"argocd.tempAppObject":                   {expected: defaultConfig.Argocd.TempAppObject, actual: c.Argocd.TempAppObject},
+		"argocd.noDiff.commitStatusContext":      {expected: "", actual: c.Argocd.NoDiff.CommitStatusContext},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, tc.actual, "%s must come from the default branch configuration", name)
+		})
+	}
+	// The PR branch configuration isn't changed
+	assert.True(t, branchConfig.Argocd.AutoMergeNoDiffPRs)
+}
@@ -0,0 +1,183 @@
+package githubapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/google/go-github/v62/github"
+	log "github.com/sirupsen/logrus"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/tenancy"
+)
+
+const rebaseConflictingPromotionPrsTimeout = 10 * time.Minute
+
+var (
+	// GitHub computes mergeability asynchronously, the mergeable field is null until it's done
+	mergeabilityPollInterval = 5 * time.Second
+	mergeabilityPollRetries  = uint64(12)
+
+	errMergeabilityUnknown = errors.New("mergeability not computed yet")
+)
+
+// rebaseConflictingPromotionPrs rebuilds the open promotion PRs that conflict with the default branch, e.g. when a target path was changed
+// after the promotion branch was created. The promoted paths are synced again from their source paths(per the promotion PR metadata)
+// as found on the default branch HEAD, and the promotion branch is force-pushed.
+func rebaseConflictingPromotionPrs(ghPrClientDetails GhPrClientDetails, defaultBranch string) {
+	// The tenant and shadow mode of the event have to be carried over to the new context
+	ctx, cancel := context.WithTimeout(withShadowModeStateOf(tenancy.NewContext(context.Background(), tenancy.FromContext(ghPrClientDetails.Ctx)), ghPrClientDetails.Ctx), rebaseConflictingPromotionPrsTimeout)
+	defer cancel()
+	ghPrClientDetails.Ctx = ctx
+
+	prs, err := listOpenPromotionPrs(ghPrClientDetails, defaultBranch)
+	if err != nil {
+		ghPrClientDetails.PrLogger.Errorf("Failed to list open promotion PRs: err=%v", err)
+		return
+	}
+	for _, pr := range prs {
+		if pr.GetNumber() == ghPrClientDetails.PrNumber {
+			continue
+		}
+		promotionPrClientDetails := ghPrClientDetails
+		promotionPrClientDetails.PrNumber = pr.GetNumber()
+		promotionPrClientDetails.Ref = pr.GetHead().GetRef()
+		promotionPrClientDetails.PrSHA = pr.GetHead().GetSHA()
+		promotionPrClientDetails.PrMetadata = prMetadata{}
+		promotionPrClientDetails.PrLogger = ghPrClientDetails.PrLogger.WithFields(log.Fields{"promotion_pr": pr.GetNumber()})
+
+		mergeable, err := waitForMergeability(promotionPrClientDetails)
+		if err != nil {
+			promotionPrClientDetails.PrLogger.Warnf("Failed to get PR mergeability: err=%v", err)
+			continue
+		}
+		if mergeable {
+			continue
+		}
+		err = rebasePromotionPr(promotionPrClientDetails, pr, defaultBranch)
+		if err != nil {
+			promotionPrClientDetails.PrLogger.Errorf("Failed to rebase conflicting promotion PR: err=%v", err)
+			_ = promotionPrClientDetails.CommentOnPr(fmt.Sprintf("This promotion PR conflicts with `%s` and Telefonistka failed to rebuild it, please resolve the conflicts manually:\n```\n%s\n```\n", defaultBranch, err))
+		}
+	}
+}
+
+func listOpenPromotionPrs(ghPrClientDetails GhPrClientDetails, defaultBranch string) ([]*github.PullRequest, error) {
+	prListOpts := &github.PullRequestListOptions{
+		State: "open",
+		Base:  defaultBranch,
+	}
+	prs, _, err := listAllPages(&prListOpts.ListOptions, func() ([]*github.PullRequest, *github.Response, error) {
+		return ghPrClientDetails.GhClientPair.v3Client.PullRequests.List(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, prListOpts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	var promotionPrs []*github.PullRequest
+	for _, pr := range prs {
+		if DoesPrHasLabel(pr.Labels, "promotion") {
+			promotionPrs = append(promotionPrs, pr)
+		}
+	}
+	return promotionPrs, nil
+}
+
+func waitForMergeability(ghPrClientDetails GhPrClientDetails) (bool, error) {
+	var mergeable bool
+	operation := func() error {
+		pr, resp, err := ghPrClientDetails.GhClientPair.v3Client.PullRequests.Get(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, ghPrClientDetails.PrNumber)
+		prom.InstrumentGhCall(resp)
+		if err != nil {
+			return backoff.Permanent(err)
+		}
+		if pr.Mergeable == nil {
+			return errMergeabilityUnknown
+		}
+		mergeable = pr.GetMergeable()
+		return nil
+	}
+	err := backoff.Retry(operation, backoff.WithContext(backoff.WithMaxRetries(backoff.NewConstantBackOff(mergeabilityPollInterval), mergeabilityPollRetries), ghPrClientDetails.Ctx))
+	return mergeable, err
+}
+
+// promotedPathSources maps the promoted paths of a promotion PR to the component paths they are synced from, based on its last promotion step
+func promotedPathSources(metadata prMetadata) (map[string]string, error) {
+	lastStep := -1
+	for k := range metadata.PreviousPromotionMetadata {
+		if k > lastStep {
+			lastStep = k
+		}
+	}
+	if lastStep == -1 {
+		return nil, errors.New("the promotion PR metadata doesn't have the promotion source path")
+	}
+	step := metadata.PreviousPromotionMetadata[lastStep]
+	sources := map[string]string{}
+	for _, promotedPath := range metadata.PromotedPaths {
+		for _, targetPath := range step.TargetPaths {
+			if componentName, found := strings.CutPrefix(promotedPath, targetPath); found && componentName != "" {
+				sources[promotedPath] = step.SourcePath + componentName
+				break
+			}
+		}
+		if _, found := sources[promotedPath]; !found {
+			return nil, fmt.Errorf("promoted path %s doesn't match any target path of the promotion", promotedPath)
+		}
+	}
+	return sources, nil
+}
+
+// rebasePromotionPr recreates the promotion commit on top of the default branch HEAD, syncing the promoted paths again from their source paths on the default branch.
+// Syncing from the promotion branch instead would revert whatever changed the target paths after the branch was created
+func rebasePromotionPr(ghPrClientDetails GhPrClientDetails, pr *github.PullRequest, defaultBranch string) error {
+	if err := ghPrClientDetails.getPrMetadata(pr.GetBody()); err != nil {
+		return fmt.Errorf("failed to read the promotion PR metadata: %w", err)
+	}
+	promotedPaths := append([]string{}, ghPrClientDetails.PrMetadata.PromotedPaths...)
+	if len(promotedPaths) == 0 {
+		return errors.New("the promotion PR metadata doesn't list the promoted paths")
+	}
+	sort.Strings(promotedPaths)
+	sources, err := promotedPathSources(ghPrClientDetails.PrMetadata)
+	if err != nil {
+		return err
+	}
+
+	var treeEntries []*github.TreeEntry
+	for _, promotedPath := range promotedPaths {
+		err := generateSyncTreeEntries(&treeEntries, ghPrClientDetails, sources[promotedPath], defaultBranch, promotedPath, defaultBranch)
+		if err != nil {
+			return fmt.Errorf("failed to generate tree entries for %s: %w", promotedPath, err)
+		}
+	}
+	if len(treeEntries) == 0 {
+		return errors.New("no tree entries were generated for the promoted paths")
+	}
+
+	if skipInShadowMode(ghPrClientDetails.Ctx, "force_push "+ghPrClientDetails.Ref) {
+		return nil
+	}
+	commit, err := createCommit(ghPrClientDetails, treeEntries, defaultBranch, fmt.Sprintf("Rebuilding promotion PR #%d on top of %s", ghPrClientDetails.PrNumber, defaultBranch))
+	if err != nil {
+		return fmt.Errorf("failed to create commit: %w", err)
+	}
+
+	branchRef := &github.Reference{
+		Ref:    github.String("refs/heads/" + ghPrClientDetails.Ref),
+		Object: &github.GitObject{SHA: commit.SHA},
+	}
+	_, _, err = retryGhWrite(ghPrClientDetails.Ctx, "update_ref", func() (*github.Reference, *github.Response, error) {
+		return ghPrClientDetails.GhClientPair.v3Client.Git.UpdateRef(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, branchRef, true)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to force-push %s: %w", ghPrClientDetails.Ref, err)
+	}
+	ghPrClientDetails.PrLogger.Infof("Rebased conflicting promotion PR on %s, new head %s", defaultBranch, commit.GetSHA())
+
+	return ghPrClientDetails.CommentOnPr(fmt.Sprintf("This promotion PR conflicted with `%s`, Telefonistka rebuilt it on top of `%s` and force-pushed `%s`(previous head %s).\nPromoted paths:\n```\n%s\n```\n",
+		defaultBranch, defaultBranch, ghPrClientDetails.Ref, ghPrClientDetails.PrSHA, strings.Join(promotedPaths, "\n")))
+}
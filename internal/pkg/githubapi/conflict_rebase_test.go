@@ -0,0 +1,161 @@
+package githubapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// Not parallel, the test shortens the package level mergeability poll interval
+func TestRebaseConflictingPromotionPrs(t *testing.T) {
+	mergeabilityPollInterval = time.Millisecond
+	t.Cleanup(func() { mergeabilityPollInterval = 5 * time.Second })
+
+	metadata, _ := prMetadata{
+		PromotedPaths:             []string{"env/prod/c1"},
+		PreviousPromotionMetadata: map[int]promotionInstanceMetaData{1: {SourcePath: "env/staging/", TargetPaths: []string{"env/prod/"}}},
+	}.serialize()
+	promotionLabel := []*github.Label{{Name: github.String("promotion")}}
+	var (
+		conflictingPrGets atomic.Int32
+		mu                sync.Mutex
+		createdTree       []*github.TreeEntry
+		updatedRef        map[string]interface{}
+		comments          = map[string]string{}
+	)
+
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetReposPullsByOwnerByRepo,
+			[]*github.PullRequest{
+				{Number: github.Int(1), Labels: promotionLabel},
+				{Number: github.Int(7), Labels: promotionLabel, Body: github.String(prMetadataComment(prMetadataBinding{}, metadata, nil)), Head: &github.PullRequestBranch{Ref: github.String("promotions/7"), SHA: github.String("oldhead")}},
+				{Number: github.Int(8), Labels: promotionLabel, Head: &github.PullRequestBranch{Ref: github.String("promotions/8")}},
+				{Number: github.Int(9)},
+			},
+		),
+		mock.WithRequestMatchHandler(
+			mock.GetReposPullsByOwnerByRepoByPullNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/repos/AnOwner/Arepo/pulls/7":
+					// The first response comes before GitHub computed the mergeability
+					if conflictingPrGets.Add(1) == 1 {
+						_, _ = w.Write(mock.MustMarshal(github.PullRequest{Number: github.Int(7)}))
+						return
+					}
+					_, _ = w.Write(mock.MustMarshal(github.PullRequest{Number: github.Int(7), Mergeable: github.Bool(false)}))
+				default:
+					_, _ = w.Write(mock.MustMarshal(github.PullRequest{Number: github.Int(8), Mergeable: github.Bool(true)}))
+				}
+			}),
+		),
+		mock.WithRequestMatchHandler(
+			mock.GetReposContentsByOwnerByRepoByPath,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ref := r.URL.Query().Get("ref")
+				switch {
+				case r.URL.Path == "/repos/AnOwner/Arepo/contents/env/staging" && ref == "main":
+					_, _ = w.Write(mock.MustMarshal([]github.RepositoryContent{{Type: github.String("dir"), Path: github.String("env/staging/c1"), SHA: github.String("sourcetree")}}))
+				case r.URL.Path == "/repos/AnOwner/Arepo/contents/env/staging/c1" && ref == "main":
+					_, _ = w.Write(mock.MustMarshal([]github.RepositoryContent{{Type: github.String("file"), Path: github.String("env/staging/c1/values.yaml"), SHA: github.String("a")}}))
+				case r.URL.Path == "/repos/AnOwner/Arepo/contents/env/prod/c1" && ref == "main":
+					_, _ = w.Write(mock.MustMarshal([]github.RepositoryContent{
+						{Type: github.String("file"), Path: github.String("env/prod/c1/values.yaml"), SHA: github.String("b")},
+						{Type: github.String("file"), Path: github.String("env/prod/c1/added-on-main.yaml"), SHA: github.String("c")},
+					}))
+				default:
+					mock.WriteError(w, http.StatusNotFound, "Not Found")
+				}
+			}),
+		),
+		mock.WithRequestMatch(
+			mock.GetReposGitRefByOwnerByRepoByRef,
+			github.Reference{Object: &github.GitObject{SHA: github.String("mainhead")}},
+		),
+		mock.WithRequestMatchHandler(
+			mock.PostReposGitTreesByOwnerByRepo,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body struct {
+					Tree []*github.TreeEntry `json:"tree"`
+				}
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				mu.Lock()
+				createdTree = body.Tree
+				mu.Unlock()
+				_, _ = w.Write(mock.MustMarshal(github.Tree{SHA: github.String("newtree")}))
+			}),
+		),
+		mock.WithRequestMatch(
+			mock.GetReposGitCommitsByOwnerByRepoByCommitSha,
+			github.Commit{SHA: github.String("mainhead")},
+		),
+		mock.WithRequestMatch(
+			mock.PostReposGitCommitsByOwnerByRepo,
+			github.Commit{SHA: github.String("newhead")},
+		),
+		mock.WithRequestMatchHandler(
+			mock.PatchReposGitRefsByOwnerByRepoByRef,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/repos/AnOwner/Arepo/git/refs/heads/promotions/7", r.URL.Path)
+				mu.Lock()
+				_ = json.NewDecoder(r.Body).Decode(&updatedRef)
+				mu.Unlock()
+				_, _ = w.Write(mock.MustMarshal(github.Reference{}))
+			}),
+		),
+		mock.WithRequestMatchHandler(
+			mock.PostReposIssuesCommentsByOwnerByRepoByIssueNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var comment github.IssueComment
+				_ = json.NewDecoder(r.Body).Decode(&comment)
+				mu.Lock()
+				comments[r.URL.Path] = comment.GetBody()
+				mu.Unlock()
+				_, _ = w.Write(mock.MustMarshal(comment))
+			}),
+		),
+	)
+
+	rebaseConflictingPromotionPrs(GhPrClientDetails{
+		Ctx:          context.Background(),
+		GhClientPair: &GhClientPair{v3Client: github.NewClient(mockedHTTPClient)},
+		Owner:        "AnOwner",
+		Repo:         "Arepo",
+		PrNumber:     1,
+		PrLogger:     log.WithFields(log.Fields{"repo": "AnOwner/Arepo", "prNumber": 1}),
+	}, "main")
+
+	assert.Equal(t, []*github.TreeEntry{
+		{Path: github.String("env/prod/c1"), Mode: github.String("040000"), Type: github.String("tree"), SHA: github.String("sourcetree")},
+		{Path: github.String("env/prod/c1/added-on-main.yaml"), Mode: github.String("100644"), Type: github.String("blob")},
+	}, createdTree)
+	assert.Equal(t, map[string]interface{}{"sha": "newhead", "force": true}, updatedRef)
+	assert.Len(t, comments, 1)
+	assert.Contains(t, comments["/repos/AnOwner/Arepo/issues/7/comments"], "force-pushed `promotions/7`(previous head oldhead)")
+}
+
+func TestPromotedPathSources(t *testing.T) {
+	t.Parallel()
+	sources, err := promotedPathSources(prMetadata{
+		PromotedPaths: []string{"env/prod/us/c1", "env/prod/eu/team/c1"},
+		PreviousPromotionMetadata: map[int]promotionInstanceMetaData{
+			1: {SourcePath: "env/dev/", TargetPaths: []string{"env/staging/"}},
+			2: {SourcePath: "env/staging/", TargetPaths: []string{"env/prod/eu/", "env/prod/us/"}},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"env/prod/us/c1": "env/staging/c1", "env/prod/eu/team/c1": "env/staging/team/c1"}, sources)
+
+	_, err = promotedPathSources(prMetadata{PromotedPaths: []string{"env/prod/c1"}})
+	assert.Error(t, err)
+}
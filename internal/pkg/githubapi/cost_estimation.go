@@ -0,0 +1,89 @@
+package githubapi
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+
+	"github.com/nao1215/markdown"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/argocd"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+)
+
+const bytesPerGiB = 1 << 30
+
+type componentResourceDelta struct {
+	ComponentPath string
+	CPUMilli      int64
+	MemoryBytes   int64
+}
+
+// resourceRequestDeltas sums the pod resource requests change of the diffed objects for each component matching one of pathRegexes, components without a change are left out
+func resourceRequestDeltas(diffResults []argocd.DiffResult, pathRegexes []string) []componentResourceDelta {
+	deltas := []componentResourceDelta{}
+	for _, diffResult := range diffResults {
+		matched := false
+		for _, pathRegex := range pathRegexes {
+			if match, _ := regexp.MatchString(pathRegex, diffResult.ComponentPath); match {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		delta := componentResourceDelta{ComponentPath: diffResult.ComponentPath}
+		for _, diffElement := range diffResult.DiffElements {
+			delta.CPUMilli += diffElement.TargetRequests.CPUMilli - diffElement.LiveRequests.CPUMilli
+			delta.MemoryBytes += diffElement.TargetRequests.MemoryBytes - diffElement.LiveRequests.MemoryBytes
+		}
+		if delta.CPUMilli != 0 || delta.MemoryBytes != 0 {
+			deltas = append(deltas, delta)
+		}
+	}
+	return deltas
+}
+
+func formatCPUDelta(milli int64) string {
+	return fmt.Sprintf("%+.2f cores", float64(milli)/1000)
+}
+
+func formatMemoryDelta(bytes int64) string {
+	return fmt.Sprintf("%+.2f GiB", float64(bytes)/bytesPerGiB)
+}
+
+func monthlyCostDelta(delta componentResourceDelta, costConfig cfg.CostEstimationConfig) float64 {
+	return float64(delta.CPUMilli)/1000*costConfig.CPUCoreMonthlyCost + float64(delta.MemoryBytes)/bytesPerGiB*costConfig.MemoryGiBMonthlyCost
+}
+
+func costEstimationComment(deltas []componentResourceDelta, costConfig cfg.CostEstimationConfig) (string, error) {
+	currency := costConfig.Currency
+	if currency == "" {
+		currency = "$"
+	}
+	withCost := costConfig.CPUCoreMonthlyCost != 0 || costConfig.MemoryGiBMonthlyCost != 0
+	header := []string{"Component", "CPU requests", "Memory requests"}
+	if withCost {
+		header = append(header, "Monthly cost")
+	}
+	rows := [][]string{}
+	var totalCost float64
+	for _, delta := range deltas {
+		row := []string{markdown.Code(delta.ComponentPath), formatCPUDelta(delta.CPUMilli), formatMemoryDelta(delta.MemoryBytes)}
+		if withCost {
+			cost := monthlyCostDelta(delta, costConfig)
+			totalCost += cost
+			row = append(row, fmt.Sprintf("%s%+.2f", currency, cost))
+		}
+		rows = append(rows, row)
+	}
+	buf := new(bytes.Buffer)
+	md := markdown.NewMarkdown(buf)
+	md.PlainText("Pod resource requests change of this PR(all replicas, DaemonSets are counted per node):\n")
+	md.CustomTable(markdown.TableSet{Header: header, Rows: rows}, markdown.TableOptions{AutoWrapText: false})
+	if withCost {
+		md.PlainTextf("Estimated monthly cost change: %s", markdown.Bold(fmt.Sprintf("%s%+.2f", currency, totalCost)))
+	}
+	err := md.Build()
+	return buf.String(), err
+}
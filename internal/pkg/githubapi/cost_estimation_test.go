@@ -0,0 +1,47 @@
+package githubapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/argocd"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+)
+
+func TestResourceRequestDeltas(t *testing.T) {
+	t.Parallel()
+	diffResults := []argocd.DiffResult{
+		{
+			ComponentPath: "clusters/prod/karpenter",
+			DiffElements: []argocd.DiffElement{
+				{LiveRequests: argocd.ResourceRequests{CPUMilli: 1000, MemoryBytes: 1 << 30}, TargetRequests: argocd.ResourceRequests{CPUMilli: 3000, MemoryBytes: 1 << 30}},
+				{TargetRequests: argocd.ResourceRequests{MemoryBytes: 1 << 29}},
+			},
+		},
+		{
+			ComponentPath: "clusters/prod/unchanged",
+			DiffElements:  []argocd.DiffElement{{LiveRequests: argocd.ResourceRequests{CPUMilli: 100}, TargetRequests: argocd.ResourceRequests{CPUMilli: 100}}},
+		},
+		{
+			ComponentPath: "clusters/dev/karpenter",
+			DiffElements:  []argocd.DiffElement{{TargetRequests: argocd.ResourceRequests{CPUMilli: 100}}},
+		},
+	}
+	expected := []componentResourceDelta{{ComponentPath: "clusters/prod/karpenter", CPUMilli: 2000, MemoryBytes: 1 << 29}}
+	assert.Equal(t, expected, resourceRequestDeltas(diffResults, []string{"^clusters/prod/"}))
+}
+
+func TestCostEstimationComment(t *testing.T) {
+	t.Parallel()
+	deltas := []componentResourceDelta{{ComponentPath: "clusters/prod/app", CPUMilli: 2000, MemoryBytes: -(1 << 30)}}
+
+	comment, err := costEstimationComment(deltas, cfg.CostEstimationConfig{})
+	assert.NoError(t, err)
+	assert.Contains(t, comment, "+2.00 cores")
+	assert.Contains(t, comment, "-1.00 GiB")
+	assert.NotContains(t, comment, "Monthly cost")
+
+	comment, err = costEstimationComment(deltas, cfg.CostEstimationConfig{CPUCoreMonthlyCost: 20, MemoryGiBMonthlyCost: 3, Currency: "€"})
+	assert.NoError(t, err)
+	assert.Contains(t, comment, "Estimated monthly cost change: **€+37.00**")
+}
@@ -0,0 +1,37 @@
+package githubapi
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const deliveryClaimKeyPrefix = "telefonistka:delivery:"
+
+// redisDeliveryClaims records the webhook deliveries in Redis, so a delivery received by several replicas(or redelivered to another one) is only handled once
+type redisDeliveryClaims struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func newRedisDeliveryClaims(addr string, password string, ttl time.Duration) *redisDeliveryClaims {
+	return &redisDeliveryClaims{
+		client: redis.NewClient(&redis.Options{Addr: addr, Password: password}),
+		ttl:    ttl,
+	}
+}
+
+// claim returns false when another replica(or this one) already claimed the delivery within the TTL
+func (rc *redisDeliveryClaims) claim(ctx context.Context, deliveryID string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	return rc.client.SetNX(ctx, deliveryClaimKeyPrefix+deliveryID, time.Now().Unix(), rc.ttl).Result()
+}
+
+// release deletes the claim of a delivery that failed to be handled, so its redelivery is handled
+func (rc *redisDeliveryClaims) release(ctx context.Context, deliveryID string) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	return rc.client.Del(ctx, deliveryClaimKeyPrefix+deliveryID).Err()
+}
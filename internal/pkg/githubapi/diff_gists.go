@@ -0,0 +1,109 @@
+package githubapi
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v62/github"
+	log "github.com/sirupsen/logrus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/tenancy"
+)
+
+// gistDiffUploadUnavailable returns why the oversized diffs can't be uploaded as gists, empty when they can.
+// Gists are created with the server credentials in the account of their user, so repos can only use them when GIST_DIFF_UPLOAD_ENABLED is set
+func gistDiffUploadUnavailable(ctx context.Context) string {
+	if enabled, _ := strconv.ParseBool(tenancy.Getenv(ctx, "GIST_DIFF_UPLOAD_ENABLED", "false")); !enabled {
+		return "GIST_DIFF_UPLOAD_ENABLED isn't set"
+	}
+	if tenancy.Getenv(ctx, MainCredentialEnvVars(ctx).AppID, "") != "" {
+		return "GitHub Apps can't create gists"
+	}
+	return ""
+}
+
+// CheckGistDiffUpload logs an error when GIST_DIFF_UPLOAD_ENABLED is set with GitHub App credentials, the diffs are attached to check-runs instead
+func CheckGistDiffUpload() {
+	ctx := context.Background()
+	if enabled, _ := strconv.ParseBool(getEnv("GIST_DIFF_UPLOAD_ENABLED", "false")); enabled && gistDiffUploadUnavailable(ctx) != "" {
+		log.Errorf("GIST_DIFF_UPLOAD_ENABLED requires GITHUB_OAUTH_TOKEN credentials, %s. Oversized diffs are attached to check-runs instead", gistDiffUploadUnavailable(ctx))
+	}
+}
+
+// prDiffGistDescriptionSuffix identifies the gists of a PR, so they can be updated and deleted
+func prDiffGistDescriptionSuffix(ghPrClientDetails GhPrClientDetails) string {
+	return fmt.Sprintf(" for %s/%s#%d", ghPrClientDetails.Owner, ghPrClientDetails.Repo, ghPrClientDetails.PrNumber)
+}
+
+// listPrDiffGists returns the diff gists of the PR, by description
+func listPrDiffGists(ghPrClientDetails GhPrClientDetails) (map[string]*github.Gist, error) {
+	suffix := prDiffGistDescriptionSuffix(ghPrClientDetails)
+	gists := map[string]*github.Gist{}
+	listOpts := &github.GistListOptions{}
+	_, err := forEachPage(&listOpts.ListOptions, func() ([]*github.Gist, *github.Response, error) {
+		return ghPrClientDetails.GhClientPair.v3Client.Gists.List(ghPrClientDetails.Ctx, "", listOpts)
+	}, func(page []*github.Gist) bool {
+		for _, gist := range page {
+			if strings.HasPrefix(gist.GetDescription(), "Telefonistka ArgoCD diff of ") && strings.HasSuffix(gist.GetDescription(), suffix) {
+				gists[gist.GetDescription()] = gist
+			}
+		}
+		return true
+	})
+	return gists, err
+}
+
+// uploadDiffAsGist uploads the full diff as a secret gist, the gist of a previous event of the PR is updated instead of adding another one
+func uploadDiffAsGist(ghPrClientDetails GhPrClientDetails, componentPath string, fullDiffComment string) (string, error) {
+	description := "Telefonistka ArgoCD diff of " + componentPath + prDiffGistDescriptionSuffix(ghPrClientDetails)
+	fileName := github.GistFilename(strings.ReplaceAll(componentPath, "/", "_") + ".md")
+	gist := &github.Gist{
+		Description: github.String(description),
+		Public:      github.Bool(false),
+		Files: map[github.GistFilename]github.GistFile{
+			fileName: {Content: github.String(fullDiffComment)},
+		},
+	}
+	existingGists, err := listPrDiffGists(ghPrClientDetails)
+	if err != nil {
+		ghPrClientDetails.PrLogger.Warnf("Could not list the diff gists of the PR, creating a new one: err=%v", err)
+	}
+	operation := "create_gist"
+	call := func() (*github.Gist, *github.Response, error) {
+		return ghPrClientDetails.GhClientPair.v3Client.Gists.Create(ghPrClientDetails.Ctx, gist)
+	}
+	if existing, ok := existingGists[description]; ok {
+		operation = "edit_gist"
+		call = func() (*github.Gist, *github.Response, error) {
+			return ghPrClientDetails.GhClientPair.v3Client.Gists.Edit(ghPrClientDetails.Ctx, existing.GetID(), gist)
+		}
+	}
+	uploadedGist, resp, err := retryGhWrite(ghPrClientDetails.Ctx, operation, call)
+	if err != nil {
+		ghPrClientDetails.PrLogger.Errorf("Could not upload gist: err=%s\n%v\n", err, resp)
+		return "", err
+	}
+	return uploadedGist.GetHTMLURL(), nil
+}
+
+// deletePrDiffGists deletes the diff gists of a closed(or merged) PR, they would otherwise pile up in the account of the server credentials
+func deletePrDiffGists(ghPrClientDetails GhPrClientDetails) {
+	if gistDiffUploadUnavailable(ghPrClientDetails.Ctx) != "" {
+		return
+	}
+	gists, err := listPrDiffGists(ghPrClientDetails)
+	if err != nil {
+		ghPrClientDetails.PrLogger.Errorf("Could not list the diff gists of the PR: err=%v", err)
+		return
+	}
+	for _, gist := range gists {
+		_, _, err := retryGhWrite(ghPrClientDetails.Ctx, "delete_gist", func() (*github.Response, *github.Response, error) {
+			resp, err := ghPrClientDetails.GhClientPair.v3Client.Gists.Delete(ghPrClientDetails.Ctx, gist.GetID())
+			return resp, resp, err
+		})
+		if err != nil {
+			ghPrClientDetails.PrLogger.Errorf("Could not delete gist %s: err=%v", gist.GetID(), err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package githubapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+var testPrDiffGists = []github.Gist{
+	{ID: github.String("1"), Description: github.String("Telefonistka ArgoCD diff of env/prod/app for AnOwner/Arepo#7")},
+	{ID: github.String("2"), Description: github.String("Telefonistka ArgoCD diff of env/prod/app for AnOwner/Arepo#77")},
+	{ID: github.String("3"), Description: github.String("Telefonistka ArgoCD diff of env/prod/other for AnOwner/Arepo#7")},
+	{ID: github.String("4"), Description: github.String("Someone else's notes for AnOwner/Arepo#7")},
+}
+
+func TestUploadDiffAsGistUpdatesTheGistOfThePr(t *testing.T) {
+	t.Parallel()
+	var edited github.Gist
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(mock.GetGists, testPrDiffGists),
+		mock.WithRequestMatchHandler(
+			mock.PatchGistsByGistId,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/gists/1", r.URL.Path)
+				_ = json.NewDecoder(r.Body).Decode(&edited)
+				_, _ = w.Write(mock.MustMarshal(github.Gist{HTMLURL: github.String("https://gist.github.com/1")}))
+			}),
+		),
+		mock.WithRequestMatchHandler(
+			mock.PostGists,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				t.Error("expected the existing gist to be updated")
+			}),
+		),
+	)
+	url, err := uploadDiffAsGist(approveCommandTestClientDetails(mockedHTTPClient), "env/prod/app", "full diff")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://gist.github.com/1", url)
+	file := edited.Files["env_prod_app.md"]
+	assert.Equal(t, "full diff", file.GetContent())
+}
+
+// Not parallel, it sets env vars
+func TestDeletePrDiffGists(t *testing.T) {
+	t.Setenv("GIST_DIFF_UPLOAD_ENABLED", "true")
+	t.Setenv("GITHUB_APP_ID", "")
+	var mu sync.Mutex
+	deleted := []string{}
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(mock.GetGists, testPrDiffGists),
+		mock.WithRequestMatchHandler(
+			mock.DeleteGistsByGistId,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				deleted = append(deleted, r.URL.Path)
+				w.WriteHeader(http.StatusNoContent)
+			}),
+		),
+	)
+	deletePrDiffGists(approveCommandTestClientDetails(mockedHTTPClient))
+	assert.ElementsMatch(t, []string{"/gists/1", "/gists/3"}, deleted)
+}
+
+// Not parallel, it sets env vars
+func TestGistDiffUploadUnavailable(t *testing.T) {
+	tests := map[string]struct {
+		enabled  string
+		appID    string
+		expected string
+	}{
+		"disabled by default": {expected: "GIST_DIFF_UPLOAD_ENABLED isn't set"},
+		"enabled":             {enabled: "true"},
+		"GitHub App":          {enabled: "true", appID: "123", expected: "GitHub Apps can't create gists"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("GIST_DIFF_UPLOAD_ENABLED", tc.enabled)
+			t.Setenv("GITHUB_APP_ID", tc.appID)
+			assert.Equal(t, tc.expected, gistDiffUploadUnavailable(approveCommandTestClientDetails(nil).Ctx))
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package githubapi
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/argocd"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+)
+
+// ComponentDiff is the diff of a single component, as produced by a DiffProvider
+type ComponentDiff struct {
+	ComponentPath string
+	// Summary is an optional one line description of the change, e.g. the chart version change or the plan summary, concise comments only show it
+	Summary string
+	Diff    string
+	Err     error
+}
+
+// recordDiffError reports a diff that failed for all of componentPaths to the summary comment, check-run annotations and diff commit status enabled for the event
+func (p *GhPrClientDetails) recordDiffError(provider string, componentPaths []string, err error) {
+	p.summary.recordDiffError(provider, componentPaths, err)
+	p.annotations.annotateDiffError(provider, componentPaths, err)
+	p.diffStatus.recordFailures(len(componentPaths))
+}
+
+// recordComponentDiffs reports the diffs of a provider to the summary comment, check-run annotations and diff commit status enabled for the event
+func (p *GhPrClientDetails) recordComponentDiffs(provider string, diffs []ComponentDiff) {
+	p.summary.recordComponentDiffs(provider, diffs)
+	p.annotations.annotateComponentDiffs(provider, diffs)
+	for _, diff := range diffs {
+		if diff.Err != nil {
+			p.diffStatus.recordFailures(1)
+		}
+	}
+}
+
+// recordArgoCdDiffs reports the ArgoCD diff results to the summary comment, check-run annotations and diff commit status enabled for the event
+func (p *GhPrClientDetails) recordArgoCdDiffs(diffResults []argocd.DiffResult) {
+	p.summary.recordArgoCdDiffs(diffResults)
+	p.annotations.annotateArgoCdDiffs(diffResults)
+	for _, diffResult := range diffResults {
+		if diffResult.DiffError != nil {
+			p.diffStatus.recordFailures(1)
+		}
+	}
+}
+
+// DiffProvider renders the change a PR makes to its components, e.g. with helm template or terraform plan.
+// Components the provider doesn't handle(e.g. a directory without a Chart.yaml for Helm) are left out of the result
+type DiffProvider interface {
+	// Title heads the PR comments, e.g. "Helm diff"
+	Title() string
+	Diff(ghPrClientDetails GhPrClientDetails, componentPaths []string, baseBranch string) ([]ComponentDiff, error)
+}
+
+// newDiffProvider returns the provider registered under name, ArgoCD has its own comment flow so it isn't a DiffProvider
+func newDiffProvider(name string, config *cfg.Config) (DiffProvider, error) {
+	switch name {
+	case cfg.DiffProviderHelm:
+		return helmDiffProvider{helmDiffConfig: config.HelmDiff}, nil
+	case cfg.DiffProviderKustomize:
+		return kustomizeDiffProvider{}, nil
+	case cfg.DiffProviderTerraform:
+		return terraformDiffProvider{}, nil
+	default:
+		return nil, fmt.Errorf("unknown diff provider %q", name)
+	}
+}
+
+// routeComponentsToDiffProviders maps each provider name to the components its diffProviders entries match(first match wins),
+// components no entry matches are left out
+func routeComponentsToDiffProviders(componentPaths []string, diffProviders []cfg.DiffProviderConfig) map[string][]string {
+	routes := map[string][]string{}
+	for _, componentPath := range componentPaths {
+		for _, diffProvider := range diffProviders {
+			if match, _ := regexp.MatchString(diffProvider.PathRegex, componentPath); match {
+				routes[diffProvider.Provider] = append(routes[diffProvider.Provider], componentPath)
+				break
+			}
+		}
+	}
+	for provider := range routes {
+		sort.Strings(routes[provider])
+	}
+	return routes
+}
+
+// componentDiffsComment renders diffs under title, concise comments only have the summary line of each component
+func componentDiffsComment(title string, diffs []ComponentDiff, beConcise bool, partNumber int, totalParts int, fullURL string) string {
+	var sb strings.Builder
+	sb.WriteString("### " + title)
+	if partNumber > 0 {
+		fmt.Fprintf(&sb, " (part %d of %d)", partNumber, totalParts)
+	}
+	sb.WriteString("\n")
+	for _, diff := range diffs {
+		fmt.Fprintf(&sb, "\n#### `%s`\n", diff.ComponentPath)
+		if diff.Summary != "" {
+			fmt.Fprintf(&sb, "%s\n", diff.Summary)
+		}
+		switch {
+		case diff.Err != nil:
+			fmt.Fprintf(&sb, "\n:warning: Failed to render the component: `%s`\n", diff.Err)
+		case strings.TrimSpace(diff.Diff) == "":
+			sb.WriteString("\nNo changes\n")
+		case beConcise && fullURL != "":
+			fmt.Fprintf(&sb, "\nThe diff is too large for a GitHub comment, see the [full diff](%s)\n", fullURL)
+		case beConcise:
+			sb.WriteString("\nThe diff is too large for a GitHub comment\n")
+		default:
+			fmt.Fprintf(&sb, "\n<details><summary>Diff</summary>\n\n```diff\n%s\n```\n</details>\n", strings.Trim(diff.Diff, "\n"))
+		}
+	}
+	return sb.String()
+}
+
+// generateComponentDiffComments fits the diffs in as few comments as possible, splitting them like the ArgoCD diff comments
+func generateComponentDiffComments(title string, diffs []ComponentDiff, githubCommentMaxSize int, upload diffUploader) ([]string, error) {
+	componentPaths := []string{}
+	for _, diff := range diffs {
+		componentPaths = append(componentPaths, diff.ComponentPath)
+	}
+	buildComment := func(componentIndexes []int, beConcise bool, partNumber int, totalParts int, fullURL string) (string, error) {
+		componentDiffs := []ComponentDiff{}
+		for _, i := range componentIndexes {
+			componentDiffs = append(componentDiffs, diffs[i])
+		}
+		return componentDiffsComment(title, componentDiffs, beConcise, partNumber, totalParts, fullURL), nil
+	}
+	return splitComponentComments(componentPaths, buildComment, githubCommentMaxSize, upload)
+}
+
+// commentComponentDiffs runs provider on componentPaths and comments the diffs on the PR
+func commentComponentDiffs(ghPrClientDetails GhPrClientDetails, provider DiffProvider, componentPaths []string, baseBranch string, upload diffUploader) error {
+	if len(componentPaths) == 0 {
+		return nil
+	}
+	diffs, err := provider.Diff(ghPrClientDetails, componentPaths, baseBranch)
+	if err != nil {
+		ghPrClientDetails.recordDiffError(provider.Title(), componentPaths, err)
+		return err
+	}
+	ghPrClientDetails.recordComponentDiffs(provider.Title(), diffs)
+	if len(diffs) == 0 {
+		ghPrClientDetails.PrLogger.Debugf("No component handled by %s", provider.Title())
+		return nil
+	}
+	comments, err := generateComponentDiffComments(provider.Title(), diffs, githubCommentMaxSize, upload)
+	if err != nil {
+		return err
+	}
+	diffComponentPaths := []string{}
+	for _, diff := range diffs {
+		diffComponentPaths = append(diffComponentPaths, diff.ComponentPath)
+	}
+	return postDiffComments(ghPrClientDetails, provider.Title(), diffComponentPaths, comments)
+}
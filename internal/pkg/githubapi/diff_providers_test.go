@@ -0,0 +1,93 @@
+package githubapi
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+)
+
+func TestRouteComponentsToDiffProviders(t *testing.T) {
+	t.Parallel()
+	diffProviders := []cfg.DiffProviderConfig{
+		{PathRegex: "^terraform/", Provider: cfg.DiffProviderTerraform},
+		{PathRegex: "^clusters/.*/charts/", Provider: cfg.DiffProviderHelm},
+		{PathRegex: "^clusters/", Provider: cfg.DiffProviderArgoCD},
+	}
+	componentPaths := []string{"terraform/prod/vpc", "clusters/prod/charts/app", "clusters/prod/app", "other/app", "terraform/dev/vpc"}
+	expected := map[string][]string{
+		cfg.DiffProviderTerraform: {"terraform/dev/vpc", "terraform/prod/vpc"},
+		cfg.DiffProviderHelm:      {"clusters/prod/charts/app"},
+		cfg.DiffProviderArgoCD:    {"clusters/prod/app"},
+	}
+	assert.Equal(t, expected, routeComponentsToDiffProviders(componentPaths, diffProviders))
+}
+
+func TestComponentDiffsComment(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		diffs     []ComponentDiff
+		beConcise bool
+		fullURL   string
+		expected  string
+	}{
+		"version bump with diff": {
+			diffs: []ComponentDiff{{ComponentPath: "clusters/prod/app", Summary: "Chart version: `1.0.0` → `1.1.0`", Diff: "-a\n+b\n"}},
+			expected: "### Helm diff\n\n#### `clusters/prod/app`\nChart version: `1.0.0` → `1.1.0`\n" +
+				"\n<details><summary>Diff</summary>\n\n```diff\n-a\n+b\n```\n</details>\n",
+		},
+		"no changes and render error": {
+			diffs: []ComponentDiff{
+				{ComponentPath: "clusters/prod/app1"},
+				{ComponentPath: "clusters/prod/app2", Err: errors.New("boom")},
+			},
+			expected: "### Helm diff\n\n#### `clusters/prod/app1`\n\nNo changes\n" +
+				"\n#### `clusters/prod/app2`\n\n:warning: Failed to render the component: `boom`\n",
+		},
+		"concise with full diff link": {
+			diffs:     []ComponentDiff{{ComponentPath: "clusters/prod/app", Diff: "-a\n+b\n"}},
+			beConcise: true,
+			fullURL:   "https://example.com/diff",
+			expected:  "### Helm diff\n\n#### `clusters/prod/app`\n\nThe diff is too large for a GitHub comment, see the [full diff](https://example.com/diff)\n",
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, componentDiffsComment("Helm diff", tc.diffs, tc.beConcise, 0, 0, tc.fullURL))
+		})
+	}
+}
+
+func TestGenerateComponentDiffComments(t *testing.T) {
+	t.Parallel()
+	diffs := []ComponentDiff{
+		{ComponentPath: "infra/prod/vpc", Summary: "Plan: 1 to add, 0 to change, 0 to destroy.", Diff: strings.Repeat("  + tag = \"a\"\n", 200)},
+		{ComponentPath: "infra/prod/dns", Summary: "Plan: 0 to add, 1 to change, 0 to destroy.", Diff: strings.Repeat("  ~ ttl = 60 -> 300\n", 100)},
+	}
+	tests := map[string]struct {
+		maxSize          int
+		expectedComments int
+		expectConcise    bool
+	}{
+		"single comment":        {maxSize: 65535, expectedComments: 1},
+		"comment per component": {maxSize: 4000, expectedComments: 2},
+		"concise comment":       {maxSize: 1000, expectedComments: 2, expectConcise: true},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			comments, err := generateComponentDiffComments("Terraform plan", diffs, tc.maxSize, nil)
+			assert.NoError(t, err)
+			assert.Len(t, comments, tc.expectedComments)
+			if tc.expectedComments > 1 {
+				assert.Contains(t, comments[0], "(part 1 of 2)")
+			}
+			assert.Equal(t, tc.expectConcise, strings.Contains(comments[0], "too large for a GitHub comment"))
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package githubapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/google/go-github/v62/github"
+	lru "github.com/hashicorp/golang-lru/v2"
+	log "github.com/sirupsen/logrus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/logging"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/tenancy"
+)
+
+// repository_dispatch event types Telefonistka acts on, other event types are left to other automation
+const (
+	dispatchPromote = "telefonistka-promote"
+	dispatchBump    = "telefonistka-bump"
+	dispatchRediff  = "telefonistka-rediff"
+)
+
+// dispatchPayload is the client_payload of the Telefonistka repository_dispatch events
+type dispatchPayload struct {
+	// The merged PR to promote(again) or the open PR to diff again
+	PR int `json:"pr"`
+	// The version and files of a bump, the bump PR is opened in the repo the event was sent to
+	Version           string               `json:"version"`
+	Targets           []dispatchBumpTarget `json:"targets"`
+	AutoMerge         bool                 `json:"autoMerge"`
+	TriggeringRepo    string               `json:"triggeringRepo"`
+	TriggeringRepoSHA string               `json:"triggeringRepoSHA"`
+}
+
+// dispatchBumpTarget is a file of a bump, like the releaseBumps server configuration targets
+type dispatchBumpTarget struct {
+	File        string `json:"file"`
+	YamlAddress string `json:"yamlAddress"`
+	Regex       string `json:"regex"`
+	Replacement string `json:"replacement"`
+}
+
+// parseDispatchPayload parses and validates the client_payload of a Telefonistka repository_dispatch event
+func parseDispatchPayload(eventType string, clientPayload json.RawMessage) (dispatchPayload, error) {
+	var payload dispatchPayload
+	if len(clientPayload) > 0 {
+		if err := json.Unmarshal(clientPayload, &payload); err != nil {
+			return payload, fmt.Errorf("parse client_payload: %w", err)
+		}
+	}
+	switch eventType {
+	case dispatchPromote, dispatchRediff:
+		if payload.PR <= 0 {
+			return payload, errors.New("client_payload has no pr")
+		}
+	case dispatchBump:
+		if payload.Version == "" {
+			return payload, errors.New("client_payload has no version")
+		}
+		if len(payload.Targets) == 0 {
+			return payload, errors.New("client_payload has no targets")
+		}
+		for i, target := range payload.Targets {
+			if target.File == "" {
+				return payload, fmt.Errorf("targets[%d] has no file", i)
+			}
+			if (target.YamlAddress == "") == (target.Regex == "") {
+				return payload, fmt.Errorf("targets[%d] needs either a yamlAddress or a regex", i)
+			}
+			if _, err := regexp.Compile(target.Regex); err != nil {
+				return payload, fmt.Errorf("targets[%d] regex: %w", i, err)
+			}
+		}
+	default:
+		return payload, fmt.Errorf("unknown event type %s", eventType)
+	}
+	return payload, nil
+}
+
+// dispatchPrEventAction returns the pull_request event action replayed for a promote/rediff dispatch of pr
+func dispatchPrEventAction(eventType string, pr *github.PullRequest) (string, error) {
+	if eventType == dispatchPromote {
+		if !pr.GetMerged() {
+			return "", fmt.Errorf("PR #%d isn't merged", pr.GetNumber())
+		}
+		return "closed", nil
+	}
+	if pr.GetState() != "open" {
+		return "", fmt.Errorf("PR #%d isn't open", pr.GetNumber())
+	}
+	return "synchronize", nil
+}
+
+// handleRepositoryDispatchEvent runs the promote, bump and rediff actions other automation(e.g. GitHub Actions workflows) requests with repository_dispatch events.
+// Sending those events already requires write access to the repo, failures are logged as there might be no PR to report them on
+func handleRepositoryDispatchEvent(ctx context.Context, eventPayload *github.RepositoryDispatchEvent, mainGhClientCache *lru.Cache[string, GhClientPair], prApproverGhClientCache *lru.Cache[string, GhClientPair]) {
+	eventType := eventPayload.GetAction()
+	if eventType != dispatchPromote && eventType != dispatchBump && eventType != dispatchRediff {
+		log.Debugf("Ignoring repository_dispatch event type %s", eventType)
+		return
+	}
+	repoOwner := eventPayload.GetRepo().GetOwner().GetLogin()
+	logger := logging.ForRepo(eventPayload.GetRepo().GetFullName()).WithFields(log.Fields{
+		"event_type":    "repository_dispatch",
+		"dispatch_type": eventType,
+	})
+	payload, err := parseDispatchPayload(eventType, eventPayload.ClientPayload)
+	if err != nil {
+		logger.Errorf("Invalid repository_dispatch event: err=%v", err)
+		return
+	}
+
+	var mainGithubClientPair GhClientPair
+	mainGithubClientPair.GetAndCache(mainGhClientCache, MainCredentialEnvVars(ctx), repoOwner, ctx)
+	repoDetails := GhPrClientDetails{
+		Ctx:          ctx,
+		GhClientPair: &mainGithubClientPair,
+		Owner:        repoOwner,
+		Repo:         eventPayload.GetRepo().GetName(),
+		RepoURL:      eventPayload.GetRepo().GetHTMLURL(),
+		PrLogger:     logger,
+	}
+
+	if eventType == dispatchBump {
+		targets := make([]tenancy.ReleaseBumpTarget, 0, len(payload.Targets))
+		for _, target := range payload.Targets {
+			targets = append(targets, tenancy.ReleaseBumpTarget{File: target.File, YamlAddress: target.YamlAddress, Regex: target.Regex, Replacement: target.Replacement})
+		}
+		triggeringRepo := payload.TriggeringRepo
+		if triggeringRepo == "" {
+			triggeringRepo = eventPayload.GetRepo().GetFullName()
+		}
+		pr, err := bumpReleaseTargets(repoDetails, targets, payload.Version, triggeringRepo, payload.TriggeringRepoSHA, eventPayload.GetSender().GetLogin(), payload.AutoMerge)
+		if err != nil {
+			logger.Errorf("Dispatched bump failed: err=%v", err)
+		} else if pr != nil {
+			logger.Infof("Opened dispatched bump PR %s", pr.GetHTMLURL())
+		}
+		return
+	}
+
+	pr, resp, err := mainGithubClientPair.v3Client.PullRequests.Get(ctx, repoOwner, repoDetails.Repo, payload.PR)
+	prom.InstrumentGhCall(resp)
+	if err != nil {
+		logger.Errorf("Failed to get PR #%d: err=%v", payload.PR, err)
+		return
+	}
+	action, err := dispatchPrEventAction(eventType, pr)
+	if err != nil {
+		logger.Errorf("Ignoring repository_dispatch event: %v", err)
+		return
+	}
+	var approverGithubClientPair GhClientPair
+	approverGithubClientPair.GetAndCache(prApproverGhClientCache, ApproverCredentialEnvVars(ctx), repoOwner, ctx)
+
+	ghPrClientDetails := repoDetails
+	ghPrClientDetails.Labels = pr.Labels
+	ghPrClientDetails.PrNumber = pr.GetNumber()
+	ghPrClientDetails.Ref = pr.GetHead().GetRef()
+	ghPrClientDetails.PrAuthor = pr.GetUser().GetLogin()
+	ghPrClientDetails.PrSHA = pr.GetHead().GetSHA()
+	ghPrClientDetails.PrLogger = logger.WithFields(log.Fields{"prNumber": pr.GetNumber()})
+	ghPrClientDetails.PrLogger.Infof("Handling dispatched %s of PR #%d, requested by %s", eventType, pr.GetNumber(), eventPayload.GetSender().GetLogin())
+
+	// Replaying the matching pull_request event keeps the dispatched actions identical to the webhook triggered ones
+	HandlePREvent(&github.PullRequestEvent{
+		Action:      github.String(action),
+		Number:      pr.Number,
+		PullRequest: pr,
+		Repo:        eventPayload.GetRepo(),
+		Sender:      eventPayload.GetSender(),
+	}, ghPrClientDetails, mainGithubClientPair, approverGithubClientPair, ctx)
+}
@@ -0,0 +1,83 @@
+package githubapi
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDispatchPayload(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		eventType     string
+		clientPayload string
+		expectedErr   bool
+	}{
+		"Promote": {
+			eventType:     dispatchPromote,
+			clientPayload: `{"pr": 12}`,
+		},
+		"Rediff without a PR": {
+			eventType:   dispatchRediff,
+			expectedErr: true,
+		},
+		"Bump": {
+			eventType:     dispatchBump,
+			clientPayload: `{"version": "1.2.3", "targets": [{"file": "values.yaml", "yamlAddress": ".image.tag"}]}`,
+		},
+		"Bump without a version": {
+			eventType:     dispatchBump,
+			clientPayload: `{"targets": [{"file": "values.yaml", "yamlAddress": ".image.tag"}]}`,
+			expectedErr:   true,
+		},
+		"Bump target with a yamlAddress and a regex": {
+			eventType:     dispatchBump,
+			clientPayload: `{"version": "1.2.3", "targets": [{"file": "values.yaml", "yamlAddress": ".image.tag", "regex": "tag: .*"}]}`,
+			expectedErr:   true,
+		},
+		"Bump target with an invalid regex": {
+			eventType:     dispatchBump,
+			clientPayload: `{"version": "1.2.3", "targets": [{"file": "values.yaml", "regex": "tag: (", "replacement": "tag: {version}"}]}`,
+			expectedErr:   true,
+		},
+		"Malformed payload": {
+			eventType:     dispatchPromote,
+			clientPayload: `{"pr": "twelve"}`,
+			expectedErr:   true,
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			_, err := parseDispatchPayload(tc.eventType, json.RawMessage(tc.clientPayload))
+			if tc.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDispatchPrEventAction(t *testing.T) {
+	t.Parallel()
+	openPr := &github.PullRequest{Number: github.Int(1), State: github.String("open")}
+	mergedPr := &github.PullRequest{Number: github.Int(2), State: github.String("closed"), Merged: github.Bool(true)}
+
+	action, err := dispatchPrEventAction(dispatchPromote, mergedPr)
+	assert.NoError(t, err)
+	assert.Equal(t, "closed", action)
+
+	action, err = dispatchPrEventAction(dispatchRediff, openPr)
+	assert.NoError(t, err)
+	assert.Equal(t, "synchronize", action)
+
+	_, err = dispatchPrEventAction(dispatchPromote, openPr)
+	assert.Error(t, err)
+
+	_, err = dispatchPrEventAction(dispatchRediff, mergedPr)
+	assert.Error(t, err)
+}
@@ -86,8 +86,8 @@ func CompareRepoDirectories(ghPrClientDetails GhPrClientDetails, sourcePath stri
 		targetFilesSHAs := make(map[string]string)
 		hasDiff := false
 
-		generateFlatMapfromFileTree(&ghPrClientDetails, &sourcePath, &sourcePath, &defaultBranch, sourceFilesSHAs)
-		generateFlatMapfromFileTree(&ghPrClientDetails, &targetPath, &targetPath, &defaultBranch, targetFilesSHAs)
+		generateFlatMapfromFileTree(&ghPrClientDetails, &sourcePath, &defaultBranch, sourceFilesSHAs)
+		generateFlatMapfromFileTree(&ghPrClientDetails, &targetPath, &defaultBranch, targetFilesSHAs)
 		// ghPrClientDetails.PrLogger.Infoln(sourceFilesSHAs)
 		hasDiff, diffOutput, err := generateDiffOutput(ghPrClientDetails, defaultBranch, sourceFilesSHAs, targetFilesSHAs, sourcePath, targetPath)
 
@@ -95,7 +95,23 @@ func CompareRepoDirectories(ghPrClientDetails GhPrClientDetails, sourcePath stri
 	}
 }
 
-func generateFlatMapfromFileTree(ghPrClientDetails *GhPrClientDetails, workingPath *string, rootPath *string, branch *string, listOfFiles map[string]string) {
+// generateFlatMapfromFileTree fills listOfFiles with the blob SHAs of the files under rootPath, keyed by their path relative to it.
+// The files are listed with a single recursive tree call, trees too large for one response(or failed calls) are walked directory by directory
+func generateFlatMapfromFileTree(ghPrClientDetails *GhPrClientDetails, rootPath *string, branch *string, listOfFiles map[string]string) {
+	entries, err := getDirectoryTree(*ghPrClientDetails, *rootPath, *branch)
+	if err != nil {
+		ghPrClientDetails.PrLogger.Infof("Couldn't get %s tree, walking it directory by directory: err=%v", *rootPath, err)
+		walkFileTree(ghPrClientDetails, rootPath, rootPath, branch, listOfFiles)
+		return
+	}
+	for _, entry := range entries {
+		if entry.GetType() == "blob" {
+			listOfFiles[entry.GetPath()] = entry.GetSHA()
+		}
+	}
+}
+
+func walkFileTree(ghPrClientDetails *GhPrClientDetails, workingPath *string, rootPath *string, branch *string, listOfFiles map[string]string) {
 	getContentOpts := &github.RepositoryContentGetOptions{
 		Ref: *branch,
 	}
@@ -106,7 +122,7 @@ func generateFlatMapfromFileTree(ghPrClientDetails *GhPrClientDetails, workingPa
 			relativeName := strings.TrimPrefix(*elementInDir.Path, *rootPath+"/")
 			listOfFiles[relativeName] = *elementInDir.SHA
 		} else if *elementInDir.Type == "dir" {
-			generateFlatMapfromFileTree(ghPrClientDetails, elementInDir.Path, rootPath, branch, listOfFiles)
+			walkFileTree(ghPrClientDetails, elementInDir.Path, rootPath, branch, listOfFiles)
 		} else {
 			ghPrClientDetails.PrLogger.Infof("Ignoring type %s for path %s", *elementInDir.Type, *elementInDir.Path)
 		}
@@ -2,6 +2,7 @@ package githubapi
 
 import (
 	"context"
+	"net/http"
 	"testing"
 
 	"github.com/go-test/deep"
@@ -11,6 +12,7 @@ import (
 	"github.com/hexops/gotextdiff/span"
 	"github.com/migueleliasweb/go-github-mock/src/mock"
 	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestGenerateFlatMapfromFileTree(t *testing.T) {
@@ -22,42 +24,28 @@ func TestGenerateFlatMapfromFileTree(t *testing.T) {
 		mock.WithRequestMatch(
 			mock.GetReposContentsByOwnerByRepoByPath,
 			[]github.RepositoryContent{
-				{
-					Type: github.String("file"),
-					Path: github.String("some/path/file1"),
-					SHA:  github.String("fffff1"),
-				},
-				{
-					Type: github.String("file"),
-					Path: github.String("some/path/file2"),
-					SHA:  github.String("fffff2"),
-				},
-				{
-					Type: github.String("dir"),
-					Path: github.String("some/path/dir1"),
-					SHA:  github.String("fffff3"),
-				},
-			},
-			[]github.RepositoryContent{
-				{
-					Type: github.String("file"),
-					Path: github.String("some/path/dir1/file4"),
-					SHA:  github.String("fffff4"),
-				},
 				{
 					Type: github.String("dir"),
-					Path: github.String("some/path/dir1/nested_dir1/"),
-					SHA:  github.String("fffff3"),
-				},
-			},
-			[]github.RepositoryContent{
-				{
-					Type: github.String("file"),
-					Path: github.String("some/path/dir1/nested_dir1/file5"),
-					SHA:  github.String("fffff5"),
+					Path: github.String("some/path"),
+					SHA:  github.String("pathsha"),
 				},
 			},
 		),
+		mock.WithRequestMatchHandler(
+			mock.GetReposGitTreesByOwnerByRepoByTreeSha,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/repos/AnOwner/Arepo/git/trees/pathsha", r.URL.Path)
+				assert.Equal(t, "1", r.URL.Query().Get("recursive"))
+				_, _ = w.Write(mock.MustMarshal(github.Tree{Entries: []*github.TreeEntry{
+					{Path: github.String("file1"), Type: github.String("blob"), SHA: github.String("fffff1")},
+					{Path: github.String("file2"), Type: github.String("blob"), SHA: github.String("fffff2")},
+					{Path: github.String("dir1"), Type: github.String("tree"), SHA: github.String("fffff3")},
+					{Path: github.String("dir1/file4"), Type: github.String("blob"), SHA: github.String("fffff4")},
+					{Path: github.String("dir1/nested_dir1"), Type: github.String("tree"), SHA: github.String("fffff6")},
+					{Path: github.String("dir1/nested_dir1/file5"), Type: github.String("blob"), SHA: github.String("fffff5")},
+				}}))
+			}),
+		),
 	)
 	ghClientPair := GhClientPair{v3Client: github.NewClient(mockedHTTPClient)}
 
@@ -82,7 +70,7 @@ func TestGenerateFlatMapfromFileTree(t *testing.T) {
 
 	defaultBranch := "main"
 	targetPath := "some/path"
-	generateFlatMapfromFileTree(&ghPrClientDetails, &targetPath, &targetPath, &defaultBranch, filesSHAs)
+	generateFlatMapfromFileTree(&ghPrClientDetails, &targetPath, &defaultBranch, filesSHAs)
 	if diff := deep.Equal(expectedFilesSHAs, filesSHAs); diff != nil {
 		for _, l := range diff {
 			t.Error(l)
@@ -90,6 +78,64 @@ func TestGenerateFlatMapfromFileTree(t *testing.T) {
 	}
 }
 
+func TestGenerateFlatMapfromFileTreeTruncatedTree(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	filesSHAs := make(map[string]string)
+
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetReposContentsByOwnerByRepoByPath,
+			[]github.RepositoryContent{
+				{
+					Type: github.String("dir"),
+					Path: github.String("some/path"),
+					SHA:  github.String("pathsha"),
+				},
+			},
+			[]github.RepositoryContent{
+				{
+					Type: github.String("file"),
+					Path: github.String("some/path/file1"),
+					SHA:  github.String("fffff1"),
+				},
+				{
+					Type: github.String("dir"),
+					Path: github.String("some/path/dir1"),
+					SHA:  github.String("fffff3"),
+				},
+			},
+			[]github.RepositoryContent{
+				{
+					Type: github.String("file"),
+					Path: github.String("some/path/dir1/file4"),
+					SHA:  github.String("fffff4"),
+				},
+			},
+		),
+		mock.WithRequestMatch(
+			mock.GetReposGitTreesByOwnerByRepoByTreeSha,
+			github.Tree{Truncated: github.Bool(true), Entries: []*github.TreeEntry{
+				{Path: github.String("file1"), Type: github.String("blob"), SHA: github.String("fffff1")},
+			}},
+		),
+	)
+	ghClientPair := GhClientPair{v3Client: github.NewClient(mockedHTTPClient)}
+
+	ghPrClientDetails := GhPrClientDetails{
+		Ctx:          ctx,
+		GhClientPair: &ghClientPair,
+		Owner:        "AnOwner",
+		Repo:         "Arepo",
+		PrLogger:     log.WithFields(log.Fields{"repo": "AnOwner/Arepo"}),
+	}
+
+	defaultBranch := "main"
+	targetPath := "some/path"
+	generateFlatMapfromFileTree(&ghPrClientDetails, &targetPath, &defaultBranch, filesSHAs)
+	assert.Equal(t, map[string]string{"file1": "fffff1", "dir1/file4": "fffff4"}, filesSHAs, "truncated trees are walked directory by directory")
+}
+
 func TestGenerateDiffOutputDiffFileContent(t *testing.T) {
 	t.Parallel()
 	ctx := context.Background()
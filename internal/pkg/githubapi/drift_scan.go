@@ -0,0 +1,248 @@
+package githubapi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	lru "github.com/hashicorp/golang-lru/v2"
+	log "github.com/sirupsen/logrus"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/leader"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/logging"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/maintenance"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/tenancy"
+)
+
+const (
+	driftScanRepoTimeout = 10 * time.Minute
+	// The drift scan keeps a single open issue with this label per repo
+	driftScanIssueLabel = "telefonistka-drift"
+	driftScanIssueTitle = "⚠️ Drift between environments"
+)
+
+type driftedPathPair struct {
+	Source           string
+	Target           string
+	Diff             string
+	TargetHistoryURL string
+}
+
+type driftScanIssueData struct {
+	Branch  string
+	Concise bool
+	Paths   []driftedPathPair
+}
+
+// DriftScanLoop periodically scans the repos that enable driftScan for drift between their promotion paths, independently of PR traffic.
+// Like the promotion PR janitor it relies on GitHub App authentication to list the relevant repos
+func DriftScanLoop(mainGhClientCache *lru.Cache[string, GhClientPair], interval time.Duration) {
+	for t := range time.Tick(interval) {
+		log.Debugf("Running drift scan at %v", t)
+		if leader.Skip("drift_scan") || maintenance.Skip("drift_scan") {
+			continue
+		}
+		for _, cacheKey := range mainGhClientCache.Keys() {
+			ghClient, ok := mainGhClientCache.Get(cacheKey)
+			if !ok {
+				continue
+			}
+			repos, err := listInstallationRepos(ghClient)
+			if err != nil {
+				log.Errorf("error getting repos for %s: %v", cacheKey, err)
+				continue
+			}
+			for _, repo := range repos {
+				if maintenance.SkipRepo(repo.GetFullName(), "drift_scan") {
+					continue
+				}
+				scanRepoDrift(ghClient, repo)
+			}
+		}
+	}
+}
+
+func scanRepoDrift(ghClient GhClientPair, repo *github.Repository) {
+	ctx, cancel := context.WithTimeout(withShadowModeState(tenancy.NewContext(context.Background(), tenancy.ForRepo(repo.GetFullName()))), driftScanRepoTimeout)
+	defer cancel()
+	ghPrClientDetails := GhPrClientDetails{
+		GhClientPair:  &ghClient,
+		Ctx:           ctx,
+		DefaultBranch: repo.GetDefaultBranch(),
+		Owner:         repo.GetOwner().GetLogin(),
+		Repo:          repo.GetName(),
+		RepoURL:       repo.GetHTMLURL(),
+		PrLogger:      logging.ForRepo(repo.GetFullName()).WithFields(log.Fields{"job": "drift_scan"}),
+	}
+	config, err := GetInRepoConfig(ghPrClientDetails, repo.GetDefaultBranch())
+	if err != nil {
+		ghPrClientDetails.PrLogger.Debugf("Skipping repo without a valid configuration: err=%v", err)
+		return
+	}
+	if !config.DriftScan.Enabled {
+		return
+	}
+	err = refreshRepoDrift(ghPrClientDetails, config, repo.GetDefaultBranch())
+	if err != nil {
+		ghPrClientDetails.PrLogger.Errorf("Drift scan failed: err=%v", err)
+	}
+}
+
+// refreshRepoDrift scans branch for drift and opens, updates or closes the drift issue accordingly
+func refreshRepoDrift(ghPrClientDetails GhPrClientDetails, config *cfg.Config, branch string) error {
+	drift, err := findRepoDrift(ghPrClientDetails, config, branch)
+	if err != nil {
+		return err
+	}
+	prom.InstrumentDriftedPaths(ghPrClientDetails.Owner+"/"+ghPrClientDetails.Repo, len(drift))
+	err = syncDriftIssue(ghPrClientDetails, branch, drift)
+	if err != nil {
+		return fmt.Errorf("updating the drift issue: %w", err)
+	}
+	return nil
+}
+
+// listRepoFiles returns the paths of all the files of the branch
+func listRepoFiles(ghPrClientDetails GhPrClientDetails, branch string) ([]string, error) {
+	tree, resp, err := ghPrClientDetails.GhClientPair.v3Client.Git.GetTree(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, branch, true)
+	prom.InstrumentGhCall(resp)
+	if err != nil {
+		return nil, err
+	}
+	if tree.GetTruncated() {
+		ghPrClientDetails.PrLogger.Warnf("The %s tree is too large for a single GitHub API response, the drift scan only covers part of it", branch)
+	}
+	files := []string{}
+	for _, entry := range tree.Entries {
+		if entry.GetType() == "blob" {
+			files = append(files, entry.GetPath())
+		}
+	}
+	return files, nil
+}
+
+// findRepoDrift compares every component of the promotion source paths with its promotion targets, like a PR changing all of them would.
+// Promotion paths conditioned on PR labels are skipped as there is no PR
+func findRepoDrift(ghPrClientDetails GhPrClientDetails, config *cfg.Config, branch string) ([]driftedPathPair, error) {
+	files, err := listRepoFiles(ghPrClientDetails, branch)
+	if err != nil {
+		return nil, fmt.Errorf("listing %s files: %w", branch, err)
+	}
+	relevantComponents := getRelevantComponentsFromFileList(files, config)
+	if len(config.DriftScan.PathRegexes) > 0 {
+		for component := range relevantComponents {
+			if !containMatchingRegex(config.DriftScan.PathRegexes, component.SourcePath+component.ComponentName) {
+				delete(relevantComponents, component)
+			}
+		}
+	}
+	getConfig := func(componentPath string) (*cfg.ComponentConfig, error) {
+		return getComponentConfig(ghPrClientDetails, componentPath, branch)
+	}
+	promotions := generatePlanForComponents(ghPrClientDetails.PrLogger, config, relevantComponents, nil, getConfig)
+
+	drift := []driftedPathPair{}
+	for _, promotion := range promotions {
+		for target, source := range promotion.ComputedSyncPaths {
+			hasDiff, diffOutput, err := CompareRepoDirectories(ghPrClientDetails, source, target, branch)
+			if err != nil {
+				// e.g. a target path that doesn't exist yet, DetectDrift ignores those as well
+				continue
+			}
+			if hasDiff {
+				drift = append(drift, driftedPathPair{
+					Source:           source,
+					Target:           target,
+					Diff:             diffOutput,
+					TargetHistoryURL: fmt.Sprintf("%s/commits/%s/%s", ghPrClientDetails.RepoURL, branch, target),
+				})
+			}
+		}
+	}
+	sort.Slice(drift, func(i, j int) bool { return drift[i].Target < drift[j].Target })
+	return drift, nil
+}
+
+// driftIssueBody renders the drift issue, without the diffs when they don't fit in an issue
+func driftIssueBody(ghPrClientDetails GhPrClientDetails, branch string, drift []driftedPathPair) (string, error) {
+	data := driftScanIssueData{Branch: branch, Paths: drift}
+	body, err := executeRepoTemplate(ghPrClientDetails, "driftScanIssue", "drift-scan-issue.gotmpl", data)
+	if err != nil || len(body) <= githubCommentMaxSize {
+		return body, err
+	}
+	data.Concise = true
+	return executeRepoTemplate(ghPrClientDetails, "driftScanIssue", "drift-scan-issue.gotmpl", data)
+}
+
+// findDriftIssue returns the open drift issue of the repo, if there is one
+func findDriftIssue(ghPrClientDetails GhPrClientDetails) (*github.Issue, error) {
+	listOpts := &github.IssueListByRepoOptions{State: "open", Labels: []string{driftScanIssueLabel}}
+	var found *github.Issue
+	_, err := forEachPage(&listOpts.ListOptions, func() ([]*github.Issue, *github.Response, error) {
+		return ghPrClientDetails.GhClientPair.v3Client.Issues.ListByRepo(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, listOpts)
+	}, func(issues []*github.Issue) bool {
+		for _, issue := range issues {
+			if !issue.IsPullRequest() {
+				found = issue
+				return false
+			}
+		}
+		return true
+	})
+	return found, err
+}
+
+// syncDriftIssue opens or updates the drift issue of the repo, and closes it once there is no drift
+func syncDriftIssue(ghPrClientDetails GhPrClientDetails, branch string, drift []driftedPathPair) error {
+	issue, err := findDriftIssue(ghPrClientDetails)
+	if err != nil {
+		return fmt.Errorf("looking for the drift issue: %w", err)
+	}
+	if len(drift) == 0 {
+		if issue == nil {
+			return nil
+		}
+		ghPrClientDetails.PrLogger.Infof("Drift is gone, closing issue %d", issue.GetNumber())
+		_, _, err = retryGhWrite(ghPrClientDetails.Ctx, "create_comment", func() (*github.IssueComment, *github.Response, error) {
+			return ghPrClientDetails.GhClientPair.v3Client.Issues.CreateComment(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, issue.GetNumber(), &github.IssueComment{
+				Body: github.String("✅ The last drift scan found no drift, closing this issue."),
+			})
+		})
+		if err != nil {
+			ghPrClientDetails.PrLogger.Warnf("Failed to comment on drift issue %d: err=%v", issue.GetNumber(), err)
+		}
+		_, _, err = retryGhWrite(ghPrClientDetails.Ctx, "edit_issue", func() (*github.Issue, *github.Response, error) {
+			return ghPrClientDetails.GhClientPair.v3Client.Issues.Edit(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, issue.GetNumber(), &github.IssueRequest{State: github.String("closed")})
+		})
+		return err
+	}
+
+	body, err := driftIssueBody(ghPrClientDetails, branch, drift)
+	if err != nil {
+		return fmt.Errorf("rendering the drift issue: %w", err)
+	}
+	if issue == nil {
+		newIssue, _, err := retryGhWrite(ghPrClientDetails.Ctx, "create_issue", func() (*github.Issue, *github.Response, error) {
+			return ghPrClientDetails.GhClientPair.v3Client.Issues.Create(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, &github.IssueRequest{
+				Title:  github.String(driftScanIssueTitle),
+				Body:   github.String(body),
+				Labels: &[]string{driftScanIssueLabel},
+			})
+		})
+		if err != nil {
+			return err
+		}
+		ghPrClientDetails.PrLogger.Infof("Opened drift issue %d", newIssue.GetNumber())
+		return nil
+	}
+	if issue.GetBody() == body {
+		return nil
+	}
+	_, _, err = retryGhWrite(ghPrClientDetails.Ctx, "edit_issue", func() (*github.Issue, *github.Response, error) {
+		return ghPrClientDetails.GhClientPair.v3Client.Issues.Edit(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, issue.GetNumber(), &github.IssueRequest{Body: github.String(body)})
+	})
+	return err
+}
@@ -0,0 +1,93 @@
+package githubapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func noRepoTemplateOverride() mock.MockBackendOption {
+	return mock.WithRequestMatchHandler(
+		mock.GetReposContentsByOwnerByRepoByPath,
+		http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			mock.WriteError(w, http.StatusNotFound, "Not Found")
+		}),
+	)
+}
+
+func TestDriftIssueBody(t *testing.T) {
+	t.Parallel()
+	drift := []driftedPathPair{
+		{Source: "env/staging/app", Target: "env/prod/app", Diff: "\n```diff\n-replicas: 1\n+replicas: 2\n```\n", TargetHistoryURL: "https://github.com/AnOwner/Arepo/commits/main/env/prod/app"},
+	}
+	ghPrClientDetails := repoTemplateTestClientDetails(mock.NewMockedHTTPClient(noRepoTemplateOverride()))
+
+	body, err := driftIssueBody(ghPrClientDetails, "main", drift)
+	assert.NoError(t, err)
+	assert.Contains(t, body, "* `env/staging/app` ↔️  `env/prod/app` ([target history](https://github.com/AnOwner/Arepo/commits/main/env/prod/app))")
+	assert.Contains(t, body, "+replicas: 2")
+
+	drift[0].Diff = strings.Repeat("+replicas: 2\n", githubCommentMaxSize/10)
+	body, err = driftIssueBody(ghPrClientDetails, "main", drift)
+	assert.NoError(t, err)
+	assert.Contains(t, body, "([target history](https://github.com/AnOwner/Arepo/commits/main/env/prod/app))")
+	assert.Contains(t, body, "The diffs are too large for an issue")
+	assert.NotContains(t, body, "+replicas: 2")
+}
+
+func TestSyncDriftIssueOpensIssue(t *testing.T) {
+	t.Parallel()
+	var created github.IssueRequest
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		noRepoTemplateOverride(),
+		mock.WithRequestMatch(mock.GetReposIssuesByOwnerByRepo, []github.Issue{}),
+		mock.WithRequestMatchHandler(
+			mock.PostReposIssuesByOwnerByRepo,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_ = json.NewDecoder(r.Body).Decode(&created)
+				_, _ = w.Write(mock.MustMarshal(github.Issue{Number: github.Int(3)}))
+			}),
+		),
+	)
+	drift := []driftedPathPair{{Source: "env/staging/app", Target: "env/prod/app", Diff: "diff", TargetHistoryURL: "https://github.com/AnOwner/Arepo/commits/main/env/prod/app"}}
+
+	err := syncDriftIssue(repoTemplateTestClientDetails(mockedHTTPClient), "main", drift)
+	assert.NoError(t, err)
+	assert.Equal(t, driftScanIssueTitle, created.GetTitle())
+	assert.Equal(t, []string{driftScanIssueLabel}, created.GetLabels())
+	assert.Contains(t, created.GetBody(), "`env/prod/app`")
+}
+
+func TestSyncDriftIssueClosesIssueWithoutDrift(t *testing.T) {
+	t.Parallel()
+	var edit github.IssueRequest
+	commented := false
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(mock.GetReposIssuesByOwnerByRepo, []github.Issue{{Number: github.Int(3), Body: github.String("old drift")}}),
+		mock.WithRequestMatchHandler(
+			mock.PostReposIssuesCommentsByOwnerByRepoByIssueNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/repos/AnOwner/Arepo/issues/3/comments", r.URL.Path)
+				commented = true
+				_, _ = w.Write(mock.MustMarshal(github.IssueComment{}))
+			}),
+		),
+		mock.WithRequestMatchHandler(
+			mock.PatchReposIssuesByOwnerByRepoByIssueNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_ = json.NewDecoder(r.Body).Decode(&edit)
+				_, _ = w.Write(mock.MustMarshal(github.Issue{Number: github.Int(3)}))
+			}),
+		),
+	)
+
+	err := syncDriftIssue(repoTemplateTestClientDetails(mockedHTTPClient), "main", []driftedPathPair{})
+	assert.NoError(t, err)
+	assert.True(t, commented)
+	assert.Equal(t, "closed", edit.GetState())
+}
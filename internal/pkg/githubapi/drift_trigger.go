@@ -0,0 +1,96 @@
+package githubapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/go-github/v62/github"
+	lru "github.com/hashicorp/golang-lru/v2"
+	log "github.com/sirupsen/logrus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/inflight"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/logging"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/tenancy"
+)
+
+const driftCommand = "/telefonistka drift"
+
+// ErrDriftTargetNotFound is returned(wrapped) when the repo or PR of an on demand drift detection doesn't exist or isn't open
+var ErrDriftTargetNotFound = errors.New("repo or open PR not found")
+
+// isDriftCommand checks the comment has a "/telefonistka drift" line
+func isDriftCommand(commentBody string) bool {
+	return commentHasCommand(commentBody, driftCommand)
+}
+
+// TriggerDriftDetection refreshes the drift report of the open PRs of a repo(or only of prNumber when it isn't 0) on demand.
+// The repo and PRs are looked up before returning so callers can report missing ones, the detection itself runs in the background like webhook events
+func TriggerDriftDetection(owner string, repo string, prNumber int, mainGhClientCache *lru.Cache[string, GhClientPair]) error {
+	repoSlug := owner + "/" + repo
+	ctx := withShadowModeState(tenancy.NewContext(context.Background(), tenancy.ForRepo(repoSlug)))
+	ctx, cancel := inflight.WithEventTimeout(ctx, "github", "drift_detection", repoSlug)
+	var mainGithubClientPair GhClientPair
+	mainGithubClientPair.GetAndCache(mainGhClientCache, MainCredentialEnvVars(ctx), owner, ctx)
+	repoDetails := GhPrClientDetails{
+		Ctx:          ctx,
+		GhClientPair: &mainGithubClientPair,
+		Owner:        owner,
+		Repo:         repo,
+		PrLogger:     logging.ForRepo(repoSlug).WithFields(log.Fields{"event_type": "drift_detection"}),
+	}
+	prs, err := driftDetectionPrs(repoDetails, prNumber)
+	if err != nil {
+		cancel()
+		return err
+	}
+	go func() {
+		defer cancel()
+		defer inflight.Track("github", "drift_detection", repoSlug)()
+		for _, pr := range prs {
+			ghPrClientDetails := repoDetails
+			ghPrClientDetails.RepoURL = pr.GetBase().GetRepo().GetHTMLURL()
+			ghPrClientDetails.PrNumber = pr.GetNumber()
+			ghPrClientDetails.PrAuthor = pr.GetUser().GetLogin()
+			ghPrClientDetails.PrSHA = pr.GetHead().GetSHA()
+			ghPrClientDetails.Ref = pr.GetHead().GetRef()
+			ghPrClientDetails.Labels = pr.Labels
+			ghPrClientDetails.PrLogger = repoDetails.PrLogger.WithFields(log.Fields{"prNumber": pr.GetNumber()})
+			_ = ghPrClientDetails.getPrMetadata(pr.GetBody())
+			if err := detectDrift(ghPrClientDetails, true); err != nil {
+				ghPrClientDetails.PrLogger.Errorf("On demand drift detection failed: err=%v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// driftDetectionPrs returns the open PRs an on demand drift detection runs on
+func driftDetectionPrs(repoDetails GhPrClientDetails, prNumber int) ([]*github.PullRequest, error) {
+	if prNumber != 0 {
+		pr, resp, err := repoDetails.GhClientPair.v3Client.PullRequests.Get(repoDetails.Ctx, repoDetails.Owner, repoDetails.Repo, prNumber)
+		prom.InstrumentGhCall(resp)
+		err = classifyGhError(resp, err)
+		if errors.Is(err, ErrNotFound) {
+			return nil, fmt.Errorf("%w: %s/%s#%d", ErrDriftTargetNotFound, repoDetails.Owner, repoDetails.Repo, prNumber)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if pr.GetState() != "open" {
+			return nil, fmt.Errorf("%w: %s/%s#%d is %s", ErrDriftTargetNotFound, repoDetails.Owner, repoDetails.Repo, prNumber, pr.GetState())
+		}
+		return []*github.PullRequest{pr}, nil
+	}
+	listOpts := &github.PullRequestListOptions{State: "open"}
+	prs, _, err := listAllPages(&listOpts.ListOptions, func() ([]*github.PullRequest, *github.Response, error) {
+		return repoDetails.GhClientPair.v3Client.PullRequests.List(repoDetails.Ctx, repoDetails.Owner, repoDetails.Repo, listOpts)
+	})
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s/%s", ErrDriftTargetNotFound, repoDetails.Owner, repoDetails.Repo)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return prs, nil
+}
@@ -0,0 +1,70 @@
+package githubapi
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsDriftCommand(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		commentBody string
+		expected    bool
+	}{
+		"command only":         {commentBody: "/telefonistka drift", expected: true},
+		"command in a comment": {commentBody: "Main was changed manually\n  /telefonistka drift  \n", expected: true},
+		"mentioned inline":     {commentBody: "Run /telefonistka drift to check", expected: false},
+		"other command":        {commentBody: "/telefonistka sync clusters/prod/app", expected: false},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, isDriftCommand(tc.commentBody))
+		})
+	}
+}
+
+func TestDriftDetectionPrsRejectsClosedPr(t *testing.T) {
+	t.Parallel()
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetReposPullsByOwnerByRepoByPullNumber,
+			github.PullRequest{Number: github.Int(5), State: github.String("closed")},
+		),
+	)
+	repoDetails := GhPrClientDetails{
+		Ctx:          context.Background(),
+		GhClientPair: &GhClientPair{v3Client: github.NewClient(mockedHTTPClient)},
+		Owner:        "AnOwner",
+		Repo:         "Arepo",
+		PrLogger:     log.WithFields(log.Fields{"repo": "AnOwner/Arepo"}),
+	}
+	_, err := driftDetectionPrs(repoDetails, 5)
+	assert.ErrorIs(t, err, ErrDriftTargetNotFound)
+}
+
+func TestDriftDetectionPrsListsOpenPrs(t *testing.T) {
+	t.Parallel()
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetReposPullsByOwnerByRepo,
+			[]github.PullRequest{{Number: github.Int(5)}, {Number: github.Int(6)}},
+		),
+	)
+	repoDetails := GhPrClientDetails{
+		Ctx:          context.Background(),
+		GhClientPair: &GhClientPair{v3Client: github.NewClient(mockedHTTPClient)},
+		Owner:        "AnOwner",
+		Repo:         "Arepo",
+		PrLogger:     log.WithFields(log.Fields{"repo": "AnOwner/Arepo"}),
+	}
+	prs, err := driftDetectionPrs(repoDetails, 0)
+	assert.NoError(t, err)
+	assert.Len(t, prs, 2)
+}
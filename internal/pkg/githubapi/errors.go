@@ -0,0 +1,83 @@
+package githubapi
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v62/github"
+)
+
+// The kinds of failed GitHub calls, the errors of the githubapi helpers wrap them so callers can branch on them with errors.Is instead of matching status codes and messages
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrRateLimited = errors.New("rate limited")
+	// The request conflicts with the current state of the repo, e.g. a PR that isn't mergeable or a base branch modified during a merge
+	ErrConflict = errors.New("conflict")
+	// GitHub refused the request as is(422), e.g. a reference or PR that already exists
+	ErrValidationFailed = errors.New("validation failed")
+)
+
+// Conflicts GitHub reports for races that go away on retry, 405 for merges and 422 for the rest
+var transientConflictMessages = []string{
+	"try again",
+	"Reference update failed",
+	"Base branch was modified",
+}
+
+// GhError is a failed GitHub call of a known kind, errors.Is matches both its Kind and the go-github error it wraps
+type GhError struct {
+	Kind       error
+	StatusCode int
+	// A race that might not happen again, e.g. a merge right after the PR was updated
+	Transient bool
+	Err       error
+}
+
+func (e *GhError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *GhError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// classifyGhError wraps the error of a GitHub call in a GhError of its kind, errors of other kinds(network errors, 5xx...) are returned as is
+func classifyGhError(resp *github.Response, err error) error {
+	var ghErr *GhError
+	if err == nil || errors.As(err, &ghErr) {
+		return err
+	}
+	var rateLimitErr *github.RateLimitError
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &rateLimitErr) || errors.As(err, &abuseErr) {
+		return &GhError{Kind: ErrRateLimited, StatusCode: http.StatusForbidden, Err: err}
+	}
+	if resp == nil || resp.Response == nil {
+		return err
+	}
+	ghErr = &GhError{StatusCode: resp.StatusCode, Err: err}
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		ghErr.Kind = ErrNotFound
+	case http.StatusTooManyRequests:
+		ghErr.Kind = ErrRateLimited
+	case http.StatusConflict:
+		ghErr.Kind = ErrConflict
+	case http.StatusMethodNotAllowed, http.StatusUnprocessableEntity:
+		ghErr.Kind = ErrValidationFailed
+		for _, message := range transientConflictMessages {
+			if strings.Contains(err.Error(), message) {
+				ghErr.Kind = ErrConflict
+				ghErr.Transient = true
+			}
+		}
+		// Merges of PRs that aren't mergeable
+		if resp.StatusCode == http.StatusMethodNotAllowed {
+			ghErr.Kind = ErrConflict
+		}
+	default:
+		return err
+	}
+	return ghErr
+}
@@ -0,0 +1,94 @@
+package githubapi
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyGhError(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		resp              func() (*github.Response, error)
+		expectedKind      error
+		expectedTransient bool
+	}{
+		"Not found": {
+			resp:         func() (*github.Response, error) { return ghErrorResponse(http.StatusNotFound, "Not Found") },
+			expectedKind: ErrNotFound,
+		},
+		"Too many requests": {
+			resp:         func() (*github.Response, error) { return ghErrorResponse(http.StatusTooManyRequests, "slow down") },
+			expectedKind: ErrRateLimited,
+		},
+		"Hourly rate limit": {
+			resp: func() (*github.Response, error) {
+				resp, _ := ghErrorResponse(http.StatusForbidden, "")
+				return resp, &github.RateLimitError{Response: resp.Response, Rate: github.Rate{Reset: github.Timestamp{Time: time.Now().Add(40 * time.Minute)}}}
+			},
+			expectedKind: ErrRateLimited,
+		},
+		"Head SHA mismatch": {
+			resp: func() (*github.Response, error) {
+				return ghErrorResponse(http.StatusConflict, "Head branch was modified")
+			},
+			expectedKind: ErrConflict,
+		},
+		"Merge race": {
+			resp: func() (*github.Response, error) {
+				return ghErrorResponse(http.StatusMethodNotAllowed, "Base branch was modified. Review and try the merge again.")
+			},
+			expectedKind:      ErrConflict,
+			expectedTransient: true,
+		},
+		"Unmergeable PR": {
+			resp: func() (*github.Response, error) {
+				return ghErrorResponse(http.StatusMethodNotAllowed, "Pull Request is not mergeable")
+			},
+			expectedKind: ErrConflict,
+		},
+		"Transient 422": {
+			resp: func() (*github.Response, error) {
+				return ghErrorResponse(http.StatusUnprocessableEntity, "Reference update failed")
+			},
+			expectedKind:      ErrConflict,
+			expectedTransient: true,
+		},
+		"Validation 422": {
+			resp: func() (*github.Response, error) {
+				return ghErrorResponse(http.StatusUnprocessableEntity, "Reference already exists")
+			},
+			expectedKind: ErrValidationFailed,
+		},
+		"Server error": {
+			resp: func() (*github.Response, error) { return ghErrorResponse(http.StatusBadGateway, "Bad Gateway") },
+		},
+		"Network error": {
+			resp: func() (*github.Response, error) { return nil, errors.New("connection reset by peer") },
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			resp, err := tc.resp()
+			classified := classifyGhError(resp, err)
+			assert.ErrorIs(t, classified, err, "the go-github error is still wrapped")
+			assert.Equal(t, err.Error(), classified.Error())
+			var ghErr *GhError
+			if tc.expectedKind == nil {
+				assert.False(t, errors.As(classified, &ghErr))
+				return
+			}
+			assert.ErrorIs(t, classified, tc.expectedKind)
+			assert.True(t, errors.As(classified, &ghErr))
+			assert.Equal(t, tc.expectedTransient, ghErr.Transient)
+			assert.Same(t, classified, classifyGhError(resp, classified), "classified errors are returned as is")
+		})
+	}
+	assert.NoError(t, classifyGhError(ghResponse(http.StatusOK), nil))
+}
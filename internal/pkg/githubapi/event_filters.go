@@ -0,0 +1,59 @@
+package githubapi
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/google/go-github/v62/github"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+)
+
+// matchesAny returns true if s matches any of the regexes, invalid regexes are ignored
+func matchesAny(regexes []string, s string) bool {
+	for _, regex := range regexes {
+		r, err := regexp.Compile(regex)
+		if err != nil {
+			continue
+		}
+		if r.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// prEventFilteredOut checks the PR against the eventFilters in-repo configuration, it returns the reason if the PR should be ignored or an empty string.
+// changedFiles is only called when path filters are configured as it requires an API call.
+func prEventFilteredOut(filters cfg.EventFilters, pr *github.PullRequest, changedFiles func() ([]string, error)) (reason string, err error) {
+	if len(filters.TargetBranches) > 0 && !matchesAny(filters.TargetBranches, pr.GetBase().GetRef()) {
+		return fmt.Sprintf("target branch %s doesn't match eventFilters.targetBranches", pr.GetBase().GetRef()), nil
+	}
+	if matchesAny(filters.IgnoreAuthors, pr.GetUser().GetLogin()) {
+		return fmt.Sprintf("author %s matches eventFilters.ignoreAuthors", pr.GetUser().GetLogin()), nil
+	}
+	hasRequiredLabel := false
+	for _, l := range pr.Labels {
+		if matchesAny(filters.IgnoreLabels, l.GetName()) {
+			return fmt.Sprintf("label %s matches eventFilters.ignoreLabels", l.GetName()), nil
+		}
+		if matchesAny(filters.RequiredLabels, l.GetName()) {
+			hasRequiredLabel = true
+		}
+	}
+	if len(filters.RequiredLabels) > 0 && !hasRequiredLabel {
+		return "no label matches eventFilters.requiredLabels", nil
+	}
+	if len(filters.Paths) > 0 {
+		files, err := changedFiles()
+		if err != nil {
+			return "", err
+		}
+		for _, f := range files {
+			if matchesAny(filters.Paths, f) {
+				return "", nil
+			}
+		}
+		return "no changed file matches eventFilters.paths", nil
+	}
+	return "", nil
+}
@@ -0,0 +1,50 @@
+package githubapi
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/stretchr/testify/assert"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+)
+
+func TestPrEventFilteredOut(t *testing.T) {
+	t.Parallel()
+	pr := &github.PullRequest{
+		Base:   &github.PullRequestBranch{Ref: github.String("main")},
+		User:   &github.User{Login: github.String("renovate[bot]")},
+		Labels: []*github.Label{{Name: github.String("dependencies")}},
+	}
+	changedFiles := func() ([]string, error) { return []string{"env/prod/foo/values.yaml"}, nil }
+	tests := map[string]struct {
+		filters        cfg.EventFilters
+		expectFiltered bool
+	}{
+		"No filters":                       {filters: cfg.EventFilters{}, expectFiltered: false},
+		"Matching target branch":           {filters: cfg.EventFilters{TargetBranches: []string{"^main$"}}, expectFiltered: false},
+		"Non matching target branch":       {filters: cfg.EventFilters{TargetBranches: []string{"^release-.*"}}, expectFiltered: true},
+		"Ignored author":                   {filters: cfg.EventFilters{IgnoreAuthors: []string{`^renovate\[bot\]$`}}, expectFiltered: true},
+		"Ignored label":                    {filters: cfg.EventFilters{IgnoreLabels: []string{"dependencies"}}, expectFiltered: true},
+		"Missing required label":           {filters: cfg.EventFilters{RequiredLabels: []string{"gitops"}}, expectFiltered: true},
+		"Changed file matches path":        {filters: cfg.EventFilters{Paths: []string{"^env/prod/"}}, expectFiltered: false},
+		"No changed file matches the path": {filters: cfg.EventFilters{Paths: []string{"^env/staging/"}}, expectFiltered: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			reason, err := prEventFilteredOut(tc.filters, pr, changedFiles)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectFiltered, reason != "", reason)
+		})
+	}
+}
+
+func TestPrEventFilteredOutOnlyListsFilesForPathFilters(t *testing.T) {
+	t.Parallel()
+	pr := &github.PullRequest{Base: &github.PullRequestBranch{Ref: github.String("main")}}
+	failingChangedFiles := func() ([]string, error) { return nil, errors.New("should not be called") }
+	reason, err := prEventFilteredOut(cfg.EventFilters{TargetBranches: []string{"main"}}, pr, failingChangedFiles)
+	assert.NoError(t, err)
+	assert.Equal(t, "", reason)
+}
@@ -8,19 +8,23 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
 	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
 
+	argoappv1 "github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
 	"github.com/cenkalti/backoff/v4"
 	"github.com/google/go-github/v62/github"
 	lru "github.com/hashicorp/golang-lru/v2"
@@ -28,7 +32,13 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/wayfair-incubator/telefonistka/internal/pkg/argocd"
 	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/inflight"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/logging"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/maintenance"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/notifications"
 	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/tenancy"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/ticketing"
 	"golang.org/x/exp/maps"
 )
 
@@ -41,8 +51,13 @@ type DiffCommentData struct {
 	DiffOfChangedComponents   []argocd.DiffResult
 	DisplaySyncBranchCheckBox bool
 	BranchName                string
+	FullDiffURL               string // Set when the full diff didn't fit in a comment and was uploaded elsewhere
+	customTemplate            string // The repo override of the comment template, the built-in comment is used when empty
 }
 
+// diffUploader uploads the full diff comment of a single component when it doesn't fit in a GitHub comment, it returns a URL to link from the concise comment
+type diffUploader func(componentPath string, fullDiffComment string) (string, error)
+
 type promotionInstanceMetaData struct {
 	SourcePath  string   `json:"sourcePath"`
 	TargetPaths []string `json:"targetPaths"`
@@ -60,9 +75,18 @@ type GhPrClientDetails struct {
 	PrSHA         string
 	Ref           string
 	RepoURL       string
+	PrBody        string
 	PrLogger      *log.Entry
 	Labels        []*github.Label
 	PrMetadata    prMetadata
+	// Set by enrichPrDetails, nil when the PR details weren't enriched
+	enrichment *prEnrichment
+	// Collects the check results of a PR event for the summary comment, nil unless prSummaryComment is enabled
+	summary *prSummary
+	// Collects the problems found in a PR event for the annotations check-run, nil unless checkRunAnnotations is enabled
+	annotations *checkAnnotations
+	// Counts the failed component diffs of a PR event for the diff commit status, nil unless commitStatusContexts.diff is set
+	diffStatus *diffCommitStatus
 }
 
 type prMetadata struct {
@@ -70,6 +94,14 @@ type prMetadata struct {
 	OriginalPrNumber          int                               `json:"originalPrNumber"`
 	PromotedPaths             []string                          `json:"promotedPaths"`
 	PreviousPromotionMetadata map[int]promotionInstanceMetaData `json:"previousPromotionPaths"`
+	// Issue tracker keys mentioned in the original PR title or body
+	IssueKeys []string `json:"issueKeys,omitempty"`
+	// Component paths a hotfix promotion skipped, mapped to the hotfixed component path they should be back-filled from
+	HotfixSkippedPaths map[string]string `json:"hotfixSkippedPaths,omitempty"`
+	// Set on the promotion PRs of canary steps
+	PromotionSteps *promotionStepsMetadata `json:"promotionSteps,omitempty"`
+	// Set on the promotion PRs of components ordered by componentDependencies
+	ComponentOrder *componentOrderMetadata `json:"componentOrder,omitempty"`
 }
 
 func (pm prMetadata) serialize() (string, error) {
@@ -80,18 +112,27 @@ func (pm prMetadata) serialize() (string, error) {
 	return base64.StdEncoding.EncodeToString(pmJson), nil
 }
 
-func (ghPrClientDetails *GhPrClientDetails) getPrMetadata(prBody string) {
-	prMetadataRegex := regexp.MustCompile(`<!--\|.*\|(.*)\|-->`)
-	serializedPrMetadata := prMetadataRegex.FindStringSubmatch(prBody)
-	if len(serializedPrMetadata) == 2 {
-		if serializedPrMetadata[1] != "" {
-			ghPrClientDetails.PrLogger.Info("Found PR metadata")
-			err := ghPrClientDetails.PrMetadata.DeSerialize(serializedPrMetadata[1])
-			if err != nil {
-				ghPrClientDetails.PrLogger.Errorf("Fail to parser PR metadata %v", err)
-			}
-		}
+// getPrMetadata populates PrMetadata from the PR body, metadata with a missing(unless allowed) or mismatching signature is ignored
+func (ghPrClientDetails *GhPrClientDetails) getPrMetadata(prBody string) error {
+	serializedPrMetadata, signature, found := parsePrMetadataComment(prBody)
+	if !found {
+		return nil
+	}
+	ghPrClientDetails.PrLogger.Info("Found PR metadata")
+	if err := verifyPrMetadataSignature(ghPrClientDetails.prMetadataBinding(), serializedPrMetadata, signature, prMetadataSigningKey(), prMetadataUnsignedAllowed()); err != nil {
+		ghPrClientDetails.PrLogger.Errorf("Ignoring PR metadata: err=%v", err)
+		prom.InstrumentUnverifiedPrMetadata(ghPrClientDetails.Owner+"/"+ghPrClientDetails.Repo, unverifiedPrMetadataReason(err))
+		return err
+	}
+	if signature == "" && len(prMetadataSigningKey()) > 0 {
+		ghPrClientDetails.PrLogger.Warn("PR metadata is not signed, accepting it since PR_METADATA_ALLOW_UNSIGNED is set")
+		prom.InstrumentUnverifiedPrMetadata(ghPrClientDetails.Owner+"/"+ghPrClientDetails.Repo, "unsigned_allowed")
+	}
+	err := ghPrClientDetails.PrMetadata.DeSerialize(serializedPrMetadata)
+	if err != nil {
+		ghPrClientDetails.PrLogger.Errorf("Fail to parser PR metadata %v", err)
 	}
+	return err
 }
 
 func (ghPrClientDetails *GhPrClientDetails) getBlameURLPrefix() string {
@@ -131,13 +172,44 @@ func HandlePREvent(eventPayload *github.PullRequestEvent, ghPrClientDetails GhPr
 		}
 	}()
 
-	ghPrClientDetails.getPrMetadata(eventPayload.PullRequest.GetBody())
+	prMetadataErr := ghPrClientDetails.getPrMetadata(eventPayload.PullRequest.GetBody())
+	if len(ghPrClientDetails.PrMetadata.IssueKeys) == 0 && !DoesPrHasLabel(eventPayload.PullRequest.Labels, "promotion") {
+		ghPrClientDetails.PrMetadata.IssueKeys = ticketing.ParseIssueKeys(eventPayload.PullRequest.GetTitle() + "\n" + eventPayload.PullRequest.GetBody())
+	}
 
 	stat, ok := eventToHandle(eventPayload)
 	if !ok {
 		// nothing to do
 		return
 	}
+	if err := enrichPrDetails(&ghPrClientDetails); err != nil {
+		ghPrClientDetails.PrLogger.Warnf("Failed to enrich the PR details, they will be fetched when needed: err=%v", err)
+	}
+
+	if stat == "merged" && errors.Is(prMetadataErr, errPrMetadataTampered) {
+		_ = ghPrClientDetails.CommentOnPr("Telefonistka metadata in this PR description doesn't match its signature and was ignored, promotion history/paths from previous PRs won't be carried over.")
+	} else if stat == "merged" && errors.Is(prMetadataErr, errPrMetadataUnsigned) {
+		_ = ghPrClientDetails.CommentOnPr("Telefonistka metadata in this PR description isn't signed and was ignored, promotion history/paths from previous PRs won't be carried over.")
+	}
+
+	// Runs after the final commit status is(or would have been) set
+	defer commentShadowModeActions(ghPrClientDetails)
+
+	if stat == "closed" || stat == "merged" {
+		deletePrDiffGists(ghPrClientDetails)
+	}
+
+	// PRs closed without merging only need their branch-synced apps reverted, the commit status of an abandoned head isn't interesting
+	if stat == "closed" {
+		if err := handleClosedPrEvent(ghPrClientDetails); err != nil {
+			ghPrClientDetails.PrLogger.Errorf("Handling of PR event failed: err=%s\n", err)
+		}
+		return
+	}
+
+	if shouldSkipPrEvent(ghPrClientDetails, eventPayload.PullRequest) {
+		return
+	}
 
 	SetCommitStatus(ghPrClientDetails, "pending")
 
@@ -153,7 +225,7 @@ func HandlePREvent(eventPayload *github.PullRequestEvent, ghPrClientDetails GhPr
 
 	switch stat {
 	case "merged":
-		err = handleMergedPrEvent(ghPrClientDetails, approverGithubClientPair.v3Client)
+		err = handleMergedPrEvent(ghPrClientDetails, approverGithubClientPair.v3Client, eventPayload.PullRequest.GetMergeCommitSHA())
 	case "changed":
 		err = handleChangedPREvent(ctx, mainGithubClientPair, ghPrClientDetails, eventPayload)
 	case "show-plan":
@@ -162,9 +234,35 @@ func HandlePREvent(eventPayload *github.PullRequestEvent, ghPrClientDetails GhPr
 
 	if err != nil {
 		ghPrClientDetails.PrLogger.Errorf("Handling of PR event failed: err=%s\n", err)
+		// Merged PRs already get a comment about their configuration
+		if errors.Is(err, ErrInRepoConfigUnreadable) && stat != "merged" {
+			_ = ghPrClientDetails.CommentOnPr(fmt.Sprintf("Telefonistka didn't handle this PR, its configuration can't be read so diffs, gates and approvals couldn't be checked:\n```\n%s\n```\n", err))
+		}
+	}
+}
+
+// shouldSkipPrEvent applies the eventFilters in-repo configuration, errors are logged and don't skip the event
+func shouldSkipPrEvent(ghPrClientDetails GhPrClientDetails, pr *github.PullRequest) bool {
+	defaultBranch, _ := ghPrClientDetails.GetDefaultBranch()
+	config, err := GetInRepoConfig(ghPrClientDetails, defaultBranch)
+	if err != nil {
+		return false
+	}
+	reason, err := prEventFilteredOut(config.EventFilters, pr, func() ([]string, error) { return listPrFiles(ghPrClientDetails) })
+	if err != nil {
+		ghPrClientDetails.PrLogger.Errorf("Failed to evaluate event filters: err=%s\n", err)
+		return false
+	}
+	if reason != "" {
+		ghPrClientDetails.PrLogger.Infof("Ignoring PR event: %s", reason)
+		return true
 	}
+	return false
 }
 
+// draftDiffLabel opts a draft PR into diff generation
+const draftDiffLabel = "diff-draft"
+
 // eventToHandle returns the event to be handled, translated from a Github
 // world into the Telefonistka world. If no event should be handled, ok is
 // false.
@@ -172,7 +270,20 @@ func eventToHandle(eventPayload *github.PullRequestEvent) (event string, ok bool
 	switch {
 	case *eventPayload.Action == "closed" && *eventPayload.PullRequest.Merged:
 		return "merged", true
+	case *eventPayload.Action == "closed":
+		return "closed", true
 	case *eventPayload.Action == "opened" || *eventPayload.Action == "reopened" || *eventPayload.Action == "synchronize":
+		// Draft PRs tend to get lots of pushes, so diffs are only generated once they are ready for review, unless requested with a label
+		if eventPayload.PullRequest.GetDraft() && !DoesPrHasLabel(eventPayload.PullRequest.Labels, draftDiffLabel) {
+			return "", false
+		}
+		return "changed", true
+	case *eventPayload.Action == "ready_for_review":
+		return "changed", true
+	case *eventPayload.Action == "labeled" && eventPayload.PullRequest.GetDraft() && eventPayload.GetLabel().GetName() == draftDiffLabel:
+		return "changed", true
+	case (*eventPayload.Action == "labeled" || *eventPayload.Action == "unlabeled") && eventPayload.GetLabel().GetName() == previewConfigLabel && (!eventPayload.PullRequest.GetDraft() || DoesPrHasLabel(eventPayload.PullRequest.Labels, draftDiffLabel)):
+		// Checked again with(or without) the preview configuration
 		return "changed", true
 	case *eventPayload.Action == "labeled" && DoesPrHasLabel(eventPayload.PullRequest.Labels, "show-plan"):
 		return "show-plan", true
@@ -189,13 +300,15 @@ func handleShowPlanPREvent(ctx context.Context, ghPrClientDetails GhPrClientDeta
 		return fmt.Errorf("get in-repo configuration: %w", err)
 	}
 	promotions, _ := GeneratePromotionPlan(ghPrClientDetails, config, *eventPayload.PullRequest.Head.Ref)
+	applyPromotionPauses(ghPrClientDetails, promotions, defaultBranch)
+	generatePromotionFileChanges(ghPrClientDetails, promotions, *eventPayload.PullRequest.Head.Ref, defaultBranch)
 	commentPlanInPR(ghPrClientDetails, promotions)
 	return nil
 }
 
-func handleChangedPREvent(ctx context.Context, mainGithubClientPair GhClientPair, ghPrClientDetails GhPrClientDetails, eventPayload *github.PullRequestEvent) error {
-	botIdentity, _ := GetBotGhIdentity(mainGithubClientPair.v4Client, ctx)
-	err := MimizeStalePrComments(ghPrClientDetails, mainGithubClientPair.v4Client, botIdentity)
+func handleChangedPREvent(ctx context.Context, mainGithubClientPair GhClientPair, ghPrClientDetails GhPrClientDetails, eventPayload *github.PullRequestEvent) (err error) {
+	botIdentity, _ := ghPrClientDetails.botIdentity()
+	err = MimizeStalePrComments(ghPrClientDetails, mainGithubClientPair.v4Client, botIdentity)
 	if err != nil {
 		return fmt.Errorf("minimizing stale PR comments: %w", err)
 	}
@@ -204,16 +317,102 @@ func handleChangedPREvent(ctx context.Context, mainGithubClientPair GhClientPair
 	if err != nil {
 		return fmt.Errorf("get in-repo configuration: %w", err)
 	}
-	if config.Argocd.CommentDiffonPR {
-		componentPathList, err := generateListOfChangedComponentPaths(ghPrClientDetails, config)
+	config = changedPrConfig(ghPrClientDetails, config, eventPayload.PullRequest.Labels)
+	if config.PrSummaryComment {
+		ghPrClientDetails.summary = &prSummary{}
+		defer func() {
+			// The summary is updated even when a check failed, it shows the results collected so far and the error
+			if summaryErr := updatePrSummaryComment(ghPrClientDetails, config, err); summaryErr != nil {
+				ghPrClientDetails.PrLogger.Errorf("Failed to update the PR summary comment: err=%v", summaryErr)
+			}
+		}()
+	}
+	if config.CheckRunAnnotations.Enabled {
+		ghPrClientDetails.annotations = &checkAnnotations{}
+		defer func() {
+			if annotationsErr := publishCheckAnnotations(ghPrClientDetails); annotationsErr != nil {
+				ghPrClientDetails.PrLogger.Errorf("Failed to publish the check-run annotations: err=%v", annotationsErr)
+			}
+		}()
+	}
+	if config.CommitStatusContexts.Diff != "" {
+		ghPrClientDetails.diffStatus = &diffCommitStatus{}
+		setFeatureCommitStatus(ghPrClientDetails, config.CommitStatusContexts.Diff, "pending", "Generating diffs")
+		defer func() {
+			ghPrClientDetails.diffStatus.publish(ghPrClientDetails, config.CommitStatusContexts.Diff, err)
+		}()
+	}
+	// Configuration problems are reported but don't prevent the diff
+	if lintErr := lintChangedInRepoConfig(ghPrClientDetails, config); lintErr != nil {
+		ghPrClientDetails.PrLogger.Errorf("Failed to lint the changed configuration: err=%v", lintErr)
+	}
+	isPromotionPr := DoesPrHasLabel(eventPayload.PullRequest.Labels, "promotion")
+	var componentPathList []string
+	if config.ManifestValidation.Enabled || config.HelmDiff.Enabled || config.Argocd.CommentDiffonPR || len(config.Terraform.PathRegexes) > 0 || len(config.DiffProviders) > 0 || (isPromotionPr && config.CommitStatusContexts.Promotion != "") {
+		componentPathList, err = generateListOfChangedComponentPaths(ghPrClientDetails, config)
 		if err != nil {
 			return fmt.Errorf("generate list of changed components: %w", err)
 		}
-
+	}
+	if isPromotionPr && config.CommitStatusContexts.Promotion != "" {
+		if gateErr := setPromotionGateCommitStatus(ghPrClientDetails, config, componentPathList); gateErr != nil {
+			ghPrClientDetails.PrLogger.Errorf("Failed to set the promotion commit status: err=%v", gateErr)
+		}
+	}
+	if config.ManifestValidation.Enabled {
+		// Invalid manifests are reported but don't prevent the diff
+		err = checkChangedManifests(ghPrClientDetails, config, componentPathList)
+		if err != nil {
+			ghPrClientDetails.PrLogger.Errorf("Failed to validate changed manifests: err=%s\n", err)
+		}
+	}
+	// Components matching a diffProviders entry are only diffed by that provider, the others keep the helmDiff, terraform and ArgoCD settings
+	routedComponents := routeComponentsToDiffProviders(componentPathList, config.DiffProviders)
+	unroutedComponentPaths := []string{}
+	for _, componentPath := range componentPathList {
+		routed := false
+		for _, providerComponentPaths := range routedComponents {
+			routed = routed || slices.Contains(providerComponentPaths, componentPath)
+		}
+		if !routed {
+			unroutedComponentPaths = append(unroutedComponentPaths, componentPath)
+		}
+	}
+	if config.HelmDiff.Enabled {
+		sortedPaths := append([]string{}, unroutedComponentPaths...)
+		sort.Strings(sortedPaths)
+		err = commentComponentDiffs(ghPrClientDetails, helmDiffProvider{helmDiffConfig: config.HelmDiff}, sortedPaths, defaultBranch, nil)
+		if err != nil {
+			return fmt.Errorf("helm diff: %w", err)
+		}
+	}
+	if len(config.Terraform.PathRegexes) > 0 {
+		err = commentComponentDiffs(ghPrClientDetails, terraformDiffProvider{}, terraformComponentPaths(unroutedComponentPaths, config.Terraform.PathRegexes), defaultBranch, nil)
+		if err != nil {
+			return fmt.Errorf("terraform plan: %w", err)
+		}
+	}
+	providerNames := maps.Keys(routedComponents)
+	sort.Strings(providerNames)
+	for _, providerName := range providerNames {
+		if providerName == cfg.DiffProviderArgoCD {
+			continue
+		}
+		provider, err := newDiffProvider(providerName, config)
+		if err != nil {
+			return err
+		}
+		err = commentComponentDiffs(ghPrClientDetails, provider, routedComponents[providerName], defaultBranch, nil)
+		if err != nil {
+			return fmt.Errorf("%s: %w", provider.Title(), err)
+		}
+	}
+	argoCdComponentPaths := append(append([]string{}, unroutedComponentPaths...), routedComponents[cfg.DiffProviderArgoCD]...)
+	if config.Argocd.CommentDiffonPR && (len(argoCdComponentPaths) > 0 || len(routedComponents) == 0) {
 		// Building a map component's path and a boolean value that indicates if we should diff it not.
 		// I'm avoiding doing this in the ArgoCD package to avoid circular dependencies and keep package scope clean
 		componentsToDiff := map[string]bool{}
-		for _, componentPath := range componentPathList {
+		for _, componentPath := range argoCdComponentPaths {
 			c, err := getComponentConfig(ghPrClientDetails, componentPath, ghPrClientDetails.Ref)
 			if err != nil {
 				return fmt.Errorf("get component (%s) config:  %w", componentPath, err)
@@ -224,32 +423,66 @@ func handleChangedPREvent(ctx context.Context, mainGithubClientPair GhClientPair
 				ghPrClientDetails.PrLogger.Debugf("ArgoCD diff disabled for %s\n", componentPath)
 			}
 		}
-		argoClients, err := argocd.CreateArgoCdClients()
+		argoClients, err := argocd.CreateArgoCdClients(ctx)
 		if err != nil {
-			return fmt.Errorf("error creating ArgoCD clients: %w", err)
+			ghPrClientDetails.recordDiffError("ArgoCD diff", argoCdComponentPaths, err)
+			return argoCdDiffFallback(ghPrClientDetails, config.Argocd.KustomizeDiffFallback, argoCdComponentPaths, defaultBranch, fmt.Errorf("error creating ArgoCD clients: %w", err))
 		}
 
-		hasComponentDiff, hasComponentDiffErrors, diffOfChangedComponents, err := argocd.GenerateDiffOfChangedComponents(ctx, componentsToDiff, ghPrClientDetails.Ref, ghPrClientDetails.RepoURL, config.Argocd.UseSHALabelForAppDiscovery, config.Argocd.CreateTempAppObjectFroNewApps, argoClients)
+		hasComponentDiff, hasComponentDiffErrors, diffOfChangedComponents, err := argocd.GenerateDiffOfChangedComponents(ctx, componentsToDiff, ghPrClientDetails.Ref, ghPrClientDetails.RepoURL, config.Argocd.UseSHALabelForAppDiscovery, config.Argocd.CreateTempAppObjectFroNewApps, argoDiffSettings(config.Argocd), argoClients)
 		if err != nil {
-			return fmt.Errorf("getting diff information: %w", err)
+			ghPrClientDetails.recordDiffError("ArgoCD diff", argoCdComponentPaths, err)
+			if errors.Is(err, argocd.ErrCircuitOpen) {
+				_ = commentPR(ghPrClientDetails, fmt.Sprintf(":warning: The ArgoCD diff was skipped: %s", err))
+			}
+			return argoCdDiffFallback(ghPrClientDetails, config.Argocd.KustomizeDiffFallback, argoCdComponentPaths, defaultBranch, fmt.Errorf("getting diff information: %w", err))
 		}
+		ghPrClientDetails.recordArgoCdDiffs(diffOfChangedComponents)
 		ghPrClientDetails.PrLogger.Debugf("Successfully got ArgoCD diff(comparing live objects against objects rendered form git ref %s)", ghPrClientDetails.Ref)
-		if !hasComponentDiffErrors && !hasComponentDiff {
+		// Components routed to other diff providers aren't covered by the ArgoCD diff, so an empty diff doesn't mean the PR has no effect
+		if !hasComponentDiffErrors && !hasComponentDiff && len(argoCdComponentPaths) == len(componentPathList) {
 			ghPrClientDetails.PrLogger.Debugf("ArgoCD diff is empty, this PR will not change cluster state\n")
-			prLables, resp, err := ghPrClientDetails.GhClientPair.v3Client.Issues.AddLabelsToIssue(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, *eventPayload.PullRequest.Number, []string{"noop"})
-			prom.InstrumentGhCall(resp)
+			prLables, resp, err := retryGhWrite(ghPrClientDetails.Ctx, "add_labels", func() ([]*github.Label, *github.Response, error) {
+				return ghPrClientDetails.GhClientPair.v3Client.Issues.AddLabelsToIssue(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, *eventPayload.PullRequest.Number, []string{noDiffLabel(config.Argocd.NoDiff)})
+			})
 			if err != nil {
 				ghPrClientDetails.PrLogger.Errorf("Could not label GitHub PR: err=%s\n%v\n", err, resp)
 			} else {
 				ghPrClientDetails.PrLogger.Debugf("PR %v labeled\n%+v", *eventPayload.PullRequest.Number, prLables)
 			}
+			if config.Argocd.NoDiff.CommitStatusContext != "" {
+				setNoDiffCommitStatus(ghPrClientDetails, config.Argocd.NoDiff.CommitStatusContext)
+			}
+			if !DoesPrHasLabel(eventPayload.PullRequest.Labels, "promotion") && config.Argocd.NoDiff.AutoCloseNonPromotionPrs && len(componentPathList) > 0 {
+				ghPrClientDetails.PrLogger.Infof("Closing (no diff) PR %d", *eventPayload.PullRequest.Number)
+				err := closeNoDiffPr(ghPrClientDetails)
+				if err != nil {
+					return fmt.Errorf("closing PR with no diff: %w", err)
+				}
+			}
 			// If the PR is a promotion PR and the diff is empty, we can auto-merge it
 			// "len(componentPathList) > 0"  validates we are not auto-merging a PR that we failed to understand which apps it affects
 			if DoesPrHasLabel(eventPayload.PullRequest.Labels, "promotion") && config.Argocd.AutoMergeNoDiffPRs && len(componentPathList) > 0 {
-				ghPrClientDetails.PrLogger.Infof("Auto-merging (no diff) PR %d", *eventPayload.PullRequest.Number)
-				err := MergePr(ghPrClientDetails, eventPayload.PullRequest.Number)
+				approved, err := checkRequiredApprovals(ghPrClientDetails, config, *eventPayload.PullRequest.Number, componentPathList)
+				if err != nil {
+					return fmt.Errorf("check required approvals: %w", err)
+				}
+				if approved {
+					ghPrClientDetails.PrLogger.Infof("Auto-merging (no diff) PR %d", *eventPayload.PullRequest.Number)
+					err := MergePr(ghPrClientDetails, eventPayload.PullRequest.Number)
+					if err != nil {
+						return fmt.Errorf("PR auto merge: %w", err)
+					}
+				}
+			}
+		}
+
+		if hasComponentDiffErrors {
+			notifyDiffErrors(ghPrClientDetails, diffOfChangedComponents)
+			if config.CodeOwners.MentionOnDiffErrors {
+				err := mentionDiffErrorOwners(ghPrClientDetails, diffOfChangedComponents, defaultBranch)
 				if err != nil {
-					return fmt.Errorf("PR auto merge: %w", err)
+					ghPrClientDetails.PrLogger.Warnf("Failed to mention the code owners of components with diff errors: err=%v", err)
 				}
 			}
 		}
@@ -259,22 +492,47 @@ func handleChangedPREvent(ctx context.Context, mainGithubClientPair GhClientPair
 				DiffOfChangedComponents: diffOfChangedComponents,
 				BranchName:              ghPrClientDetails.Ref,
 			}
+			if customTemplate, found := getInRepoTemplate(ghPrClientDetails, argoCdDiffTemplateFile); found {
+				diffCommentData.customTemplate = customTemplate
+			}
 
 			diffCommentData.DisplaySyncBranchCheckBox = shouldSyncBranchCheckBoxBeDisplayed(componentPathList, config.Argocd.AllowSyncfromBranchPathRegex, diffOfChangedComponents)
 			componentsToDiffJSON, _ := json.Marshal(componentsToDiff)
 			log.Infof("Generating ArgoCD Diff Comment for components: %+v, length of diff elements: %d", string(componentsToDiffJSON), len(diffCommentData.DiffOfChangedComponents))
-			comments, err := generateArgoCdDiffComments(diffCommentData, githubCommentMaxSize)
+			comments, err := generateArgoCdDiffComments(diffCommentData, githubCommentMaxSize, oversizedDiffUploader(ghPrClientDetails, config.Argocd.OversizedDiffUpload))
 			if err != nil {
 				return fmt.Errorf("generate diff comment: %w", err)
 			}
-			for _, comment := range comments {
+			diffComponentPaths := []string{}
+			for _, diffResult := range diffOfChangedComponents {
+				diffComponentPaths = append(diffComponentPaths, diffResult.ComponentPath)
+			}
+			err = postDiffComments(ghPrClientDetails, "ArgoCD diff", diffComponentPaths, comments)
+			if err != nil {
+				return err
+			}
+		} else {
+			ghPrClientDetails.PrLogger.Debugf("Diff not find affected ArogCD apps")
+		}
+
+		if len(config.CostEstimation.PathRegexes) > 0 {
+			if deltas := resourceRequestDeltas(diffOfChangedComponents, config.CostEstimation.PathRegexes); len(deltas) > 0 {
+				comment, err := costEstimationComment(deltas, config.CostEstimation)
+				if err != nil {
+					return fmt.Errorf("generate cost estimation comment: %w", err)
+				}
 				err = commentPR(ghPrClientDetails, comment)
 				if err != nil {
 					return fmt.Errorf("commenting on PR: %w", err)
 				}
 			}
-		} else {
-			ghPrClientDetails.PrLogger.Debugf("Diff not find affected ArogCD apps")
+		}
+
+		if config.Argocd.KustomizeDiffFallback {
+			err = commentComponentDiffs(ghPrClientDetails, kustomizeDiffProvider{}, kustomizeFallbackPaths(componentsToDiff, diffOfChangedComponents), defaultBranch, nil)
+			if err != nil {
+				return fmt.Errorf("kustomize diff: %w", err)
+			}
 		}
 	}
 	err = DetectDrift(ghPrClientDetails)
@@ -284,7 +542,152 @@ func handleChangedPREvent(ctx context.Context, mainGithubClientPair GhClientPair
 	return nil
 }
 
+func notifyDiffErrors(ghPrClientDetails GhPrClientDetails, diffOfChangedComponents []argocd.DiffResult) {
+	facts := map[string]string{}
+	for _, diffResult := range diffOfChangedComponents {
+		if diffResult.DiffError != nil {
+			facts[diffResult.ComponentPath] = diffResult.DiffError.Error()
+		}
+	}
+	notifications.Send(ghPrClientDetails.Ctx, notifications.Event{
+		Type:     notifications.ArgoCdDiffError,
+		Repo:     ghPrClientDetails.Owner + "/" + ghPrClientDetails.Repo,
+		PrNumber: ghPrClientDetails.PrNumber,
+		PrURL:    fmt.Sprintf("%s/pull/%d", ghPrClientDetails.RepoURL, ghPrClientDetails.PrNumber),
+		Title:    fmt.Sprintf("ArgoCD diff failed for PR #%d", ghPrClientDetails.PrNumber),
+		Text:     fmt.Sprintf("Telefonistka couldn't generate the ArgoCD diff of %d components", len(facts)),
+		Facts:    facts,
+	})
+}
+
+// argoDiffSettings converts the in-repo ArgoCD diff configuration to the argocd package types
+func argoDiffSettings(argocdConfig cfg.ArgocdConfig) argocd.DiffSettings {
+	diffSettings := argocd.DiffSettings{
+		IgnoreAggregatedRoles: argocdConfig.IgnoreAggregatedRoles,
+		ServerSideDiff:        argocdConfig.ServerSideDiff,
+		TempApp: argocd.TempAppSettings{
+			Project:   argocdConfig.TempAppObject.Project,
+			Namespace: argocdConfig.TempAppObject.Namespace,
+			Labels:    argocdConfig.TempAppObject.Labels,
+		},
+		Normalization: argocd.NormalizationSettings{
+			StripMetadataNoise: argocdConfig.DiffNormalization.StripMetadataNoise,
+			StripStatus:        argocdConfig.DiffNormalization.StripStatus,
+			IgnoreListOrder:    argocdConfig.DiffNormalization.IgnoreListOrder,
+		},
+		Concurrency:      argocdConfig.DiffConcurrency,
+		ComponentTimeout: time.Duration(argocdConfig.ComponentDiffTimeoutSeconds) * time.Second,
+	}
+	for _, id := range argocdConfig.IgnoreDifferences {
+		diffSettings.IgnoreDifferences = append(diffSettings.IgnoreDifferences, argoappv1.ResourceIgnoreDifferences{
+			Group:                 id.Group,
+			Kind:                  id.Kind,
+			Name:                  id.Name,
+			Namespace:             id.Namespace,
+			JSONPointers:          id.JSONPointers,
+			JQPathExpressions:     id.JQPathExpressions,
+			ManagedFieldsManagers: id.ManagedFieldsManagers,
+		})
+	}
+	return diffSettings
+}
+
+// imageChangeRows returns a summary table row for every container image change found in the diffs
+func imageChangeRows(diffResults []argocd.DiffResult) [][]string {
+	rows := [][]string{}
+	for _, diffResult := range diffResults {
+		for _, diffElement := range diffResult.DiffElements {
+			for _, imageChange := range diffElement.ImageChanges {
+				oldRepo, oldTag := argocd.SplitImage(imageChange.OldImage)
+				newRepo, newTag := argocd.SplitImage(imageChange.NewImage)
+				image := newRepo
+				var change string
+				switch {
+				case imageChange.OldImage == "":
+					change = fmt.Sprintf("(added) → `%s`", newTag)
+				case imageChange.NewImage == "":
+					image = oldRepo
+					change = fmt.Sprintf("`%s` → (removed)", oldTag)
+				case oldRepo != newRepo:
+					image = ""
+					change = fmt.Sprintf("`%s` → `%s`", imageChange.OldImage, imageChange.NewImage)
+				default:
+					change = fmt.Sprintf("`%s` → `%s`", oldTag, newTag)
+				}
+				if image != "" {
+					image = markdown.Code(image)
+				}
+				rows = append(rows, []string{
+					diffResult.ArgoCdAppName,
+					fmt.Sprintf("%s/%s/%s", diffElement.ObjectKind, diffElement.ObjectName, imageChange.Container),
+					image,
+					change,
+				})
+			}
+		}
+	}
+	return rows
+}
+
+// changeSummary counts the added/changed/deleted objects by kind, e.g. "1 added (1 Deployment), 3 changed (2 ConfigMap, 1 Service)"
+func changeSummary(diffElements []argocd.DiffElement) string {
+	kindCounts := map[argocd.ChangeType]map[string]int{}
+	for _, diffElement := range diffElements {
+		if diffElement.ChangeType == "" {
+			continue
+		}
+		if kindCounts[diffElement.ChangeType] == nil {
+			kindCounts[diffElement.ChangeType] = map[string]int{}
+		}
+		kindCounts[diffElement.ChangeType][diffElement.ObjectKind]++
+	}
+
+	parts := []string{}
+	for _, changeType := range []argocd.ChangeType{argocd.ObjectAdded, argocd.ObjectChanged, argocd.ObjectDeleted} {
+		counts := kindCounts[changeType]
+		if len(counts) == 0 {
+			continue
+		}
+		kinds := make([]string, 0, len(counts))
+		for kind := range counts {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		total := 0
+		kindParts := []string{}
+		for _, kind := range kinds {
+			total += counts[kind]
+			kindParts = append(kindParts, fmt.Sprintf("%d %s", counts[kind], kind))
+		}
+		parts = append(parts, fmt.Sprintf("%d %s (%s)", total, changeType, strings.Join(kindParts, ", ")))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// riskyChanges lists the objects that deserve extra reviewer attention(deletions, CRDs, RBAC...)
+func riskyChanges(diffElements []argocd.DiffElement) []string {
+	risky := []string{}
+	for _, diffElement := range diffElements {
+		if reason := diffElement.RiskReason(); reason != "" {
+			risky = append(risky, fmt.Sprintf("%s (%s)", markdown.Code(fmt.Sprintf("%s/%s/%s", diffElement.ObjectNamespace, diffElement.ObjectKind, diffElement.ObjectName)), reason))
+		}
+	}
+	return risky
+}
+
 func buildArgoCdDiffComment(diffCommentData DiffCommentData, beConcise bool, partNumber int, totalParts int) (string, error) {
+	if diffCommentData.customTemplate != "" {
+		comment, err := executeTemplateString(argoCdDiffTemplateName, diffCommentData.customTemplate, argoCdDiffTemplateData{
+			DiffCommentData: diffCommentData,
+			Concise:         beConcise,
+			PartNumber:      partNumber,
+			TotalParts:      totalParts,
+		})
+		if err == nil {
+			return comment, nil
+		}
+		log.Warnf("Failed to render in-repo template %s%s, using the default comment: err=%s", inRepoTemplatesPath, argoCdDiffTemplateFile, err)
+	}
 	buf := new(bytes.Buffer)
 	md := markdown.NewMarkdown(buf)
 	const argoSmallLogo = `<img src="https://argo-cd.readthedocs.io/en/stable/assets/favicon.png" width="20"/>`
@@ -293,9 +696,18 @@ func buildArgoCdDiffComment(diffCommentData DiffCommentData, beConcise bool, par
 	}
 	if !beConcise {
 		md.PlainText("Diff of ArgoCD applications:\n")
+	} else if diffCommentData.FullDiffURL != "" {
+		md.PlainTextf("Diff of ArgoCD applications (concise view, full diff didn't fit GH comment and is available %s):\n", markdown.Link("here", diffCommentData.FullDiffURL))
 	} else {
 		md.PlainText("Diff of ArgoCD applications (concise view, full diff didn't fit GH comment):\n")
 	}
+	if imageRows := imageChangeRows(diffCommentData.DiffOfChangedComponents); len(imageRows) > 0 {
+		md.PlainText("Changed images:\n")
+		md.CustomTable(markdown.TableSet{
+			Header: []string{"App", "Container", "Image", "Change"},
+			Rows:   imageRows,
+		}, markdown.TableOptions{AutoWrapText: false})
+	}
 	for _, appDiffResult := range diffCommentData.DiffOfChangedComponents {
 		if appDiffResult.DiffError != nil {
 			md.Cautionf("%s (%s) ", markdown.Bold("Error getting diff from ArgoCD"), markdown.Code(appDiffResult.ComponentPath))
@@ -322,17 +734,29 @@ func buildArgoCdDiffComment(diffCommentData DiffCommentData, beConcise bool, par
 				}
 			}
 			if appDiffResult.HasDiff {
-				md.PlainText("\n<details><summary>ArgoCD Diff(Click to expand):</summary>\n\n```diff\n")
+				if summary := changeSummary(appDiffResult.DiffElements); summary != "" {
+					md.PlainTextf("%s %s", markdown.Bold("Summary:"), summary)
+				}
+				if risky := riskyChanges(appDiffResult.DiffElements); len(risky) > 0 {
+					md.Warningf("High-risk changes, please review carefully: %s", strings.Join(risky, ", "))
+				}
+				// Each object gets its own collapsed section, so large app diffs can be reviewed an object at a time
+				conciseObjectList := ""
 				for _, objectDiff := range appDiffResult.DiffElements {
-					if objectDiff.Diff != "" {
-						if !beConcise {
-							md.PlainTextf("%s/%s/%s:\n%s", objectDiff.ObjectNamespace, objectDiff.ObjectKind, objectDiff.ObjectName, objectDiff.Diff)
-						} else {
-							md.PlainTextf("%s/%s/%s", objectDiff.ObjectNamespace, objectDiff.ObjectKind, objectDiff.ObjectName)
-						}
+					if objectDiff.Diff == "" {
+						continue
+					}
+					added, removed := diffLineCounts(objectDiff.Diff)
+					objectSummary := fmt.Sprintf("%s/%s/%s (+%d -%d)", objectDiff.ObjectNamespace, objectDiff.ObjectKind, objectDiff.ObjectName, added, removed)
+					if !beConcise {
+						md.PlainTextf("\n<details><summary>%s</summary>\n\n```diff\n%s\n```\n\n</details>\n", objectSummary, strings.TrimRight(objectDiff.Diff, "\n"))
+					} else {
+						conciseObjectList += "- " + objectSummary + "\n"
 					}
 				}
-				md.PlainText("\n\n```\n\n</details>\n")
+				if conciseObjectList != "" {
+					md.PlainText("\n" + conciseObjectList)
+				}
 			} else {
 				if appDiffResult.AppSyncedFromPRBranch {
 					md.Note("The app already has this branch set as the source target revision, and autosync is enabled. Diff calculation was skipped.")
@@ -344,46 +768,98 @@ func buildArgoCdDiffComment(diffCommentData DiffCommentData, beConcise bool, par
 	}
 	if diffCommentData.DisplaySyncBranchCheckBox {
 		md.PlainTextf("- [ ] <!-- telefonistka-argocd-branch-sync --> Set ArgoCD apps Target Revision to `%s`", diffCommentData.BranchName)
+		md.PlainTextf("\nTo point only a single component's app at this branch, comment `%s<component path>`", syncCommandPrefix)
 	}
 	err := md.Build()
 	return buf.String(), err
 }
 
-func generateArgoCdDiffComments(diffCommentData DiffCommentData, githubCommentMaxSize int) (comments []string, err error) {
-	commentBody, err := buildArgoCdDiffComment(diffCommentData, false, 0, 0)
+// diffLineCounts counts the added and removed lines of a unified diff, the file headers aren't counted
+func diffLineCounts(diff string) (added int, removed int) {
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++ "), strings.HasPrefix(line, "--- "):
+		case strings.HasPrefix(line, "+"):
+			added++
+		case strings.HasPrefix(line, "-"):
+			removed++
+		}
+	}
+	return added, removed
+}
+
+func generateArgoCdDiffComments(diffCommentData DiffCommentData, githubCommentMaxSize int, uploadOversizedDiff diffUploader) (comments []string, err error) {
+	componentPaths := []string{}
+	for _, diffResult := range diffCommentData.DiffOfChangedComponents {
+		componentPaths = append(componentPaths, diffResult.ComponentPath)
+	}
+	buildComment := func(componentIndexes []int, beConcise bool, partNumber int, totalParts int, fullDiffURL string) (string, error) {
+		componentTemplateData := diffCommentData
+		componentTemplateData.DiffOfChangedComponents = []argocd.DiffResult{}
+		for _, i := range componentIndexes {
+			componentTemplateData.DiffOfChangedComponents = append(componentTemplateData.DiffOfChangedComponents, diffCommentData.DiffOfChangedComponents[i])
+		}
+		if fullDiffURL != "" {
+			componentTemplateData.FullDiffURL = fullDiffURL
+		}
+		commentBody, err := buildArgoCdDiffComment(componentTemplateData, beConcise, partNumber, totalParts)
+		if err != nil {
+			log.Errorf("Failed to build ArgoCD diff comment: err=%s\n", err)
+		}
+		return commentBody, err
+	}
+	return splitComponentComments(componentPaths, buildComment, githubCommentMaxSize, uploadOversizedDiff)
+}
+
+// componentCommentBuilder renders the comment of the components at componentIndexes, partNumber is 0 when all the components are in a single comment
+// and concise comments leave out the per object details, linking to fullURL when it's set
+type componentCommentBuilder func(componentIndexes []int, beConcise bool, partNumber int, totalParts int, fullURL string) (string, error)
+
+// splitComponentComments fits per component output(ArgoCD diffs, Terraform plans) in GitHub comments: a single comment if possible, otherwise a comment per
+// component, falling back to a concise comment for components that still don't fit(linking to the full comment uploaded with upload, when configured)
+func splitComponentComments(componentPaths []string, buildComment componentCommentBuilder, githubCommentMaxSize int, upload diffUploader) (comments []string, err error) {
+	allComponents := make([]int, len(componentPaths))
+	for i := range componentPaths {
+		allComponents[i] = i
+	}
+	commentBody, err := buildComment(allComponents, false, 0, 0, "")
 	if err != nil {
-		log.Errorf("Failed to build ArgoCD diff comment: err=%s\n", err)
 		return comments, err
 	}
 
-	// Happy path, the diff comment is small enough to be posted in one comment
+	// Happy path, the comment is small enough to be posted in one comment
 	if len(commentBody) < githubCommentMaxSize {
 		comments = append(comments, commentBody)
 		return comments, nil
 	}
 
-	// If the diff comment is too large, we'll split it into multiple comments, one per component
-	totalComponents := len(diffCommentData.DiffOfChangedComponents)
-	for i, singleComponentDiff := range diffCommentData.DiffOfChangedComponents {
-		componentTemplateData := diffCommentData
-		componentTemplateData.DiffOfChangedComponents = []argocd.DiffResult{singleComponentDiff}
-		commentBody, err := buildArgoCdDiffComment(diffCommentData, false, i+1, totalComponents)
+	// If the comment is too large, we'll split it into multiple comments, one per component
+	totalComponents := len(componentPaths)
+	for i, componentPath := range componentPaths {
+		commentBody, err := buildComment([]int{i}, false, i+1, totalComponents, "")
 		if err != nil {
-			log.Errorf("Failed to build ArgoCD diff comment: err=%s\n", err)
 			return comments, err
 		}
 
 		// Even per component comments can be too large, in that case we'll just use the concise template
-		// Somewhat Happy path, the per-component diff comment is small enough to be posted in one comment
+		// Somewhat Happy path, the per-component comment is small enough to be posted in one comment
 		if len(commentBody) < githubCommentMaxSize {
 			comments = append(comments, commentBody)
 			continue
 		}
 
 		// now we don't have much choice, this is the saddest path, we'll use the concise template
-		commentBody, err = buildArgoCdDiffComment(diffCommentData, true, i+1, totalComponents)
+		// but if configured, the full comment is uploaded elsewhere and linked from the concise comment
+		fullURL := ""
+		if upload != nil {
+			fullURL, err = upload(componentPath, commentBody)
+			if err != nil {
+				log.Errorf("Failed to upload full comment of %s: err=%s\n", componentPath, err)
+				fullURL = ""
+			}
+		}
+		commentBody, err = buildComment([]int{i}, true, i+1, totalComponents, fullURL)
 		if err != nil {
-			log.Errorf("Failed to build ArgoCD diff comment: err=%s\n", err)
 			return comments, err
 		}
 		comments = append(comments, commentBody)
@@ -392,6 +868,59 @@ func generateArgoCdDiffComments(diffCommentData DiffCommentData, githubCommentMa
 	return comments, nil
 }
 
+// oversizedDiffUploader returns the diffUploader configured by the argocd.oversizedDiffUpload in-repo configuration, nil means the concise comment is used without a link
+func oversizedDiffUploader(ghPrClientDetails GhPrClientDetails, target string) diffUploader {
+	switch target {
+	case "gist":
+		if reason := gistDiffUploadUnavailable(ghPrClientDetails.Ctx); reason != "" {
+			ghPrClientDetails.PrLogger.Warnf("argocd.oversizedDiffUpload is gist but %s, attaching the diffs to check-runs instead", reason)
+			return func(componentPath string, fullDiffComment string) (string, error) {
+				return uploadDiffAsCheckRun(ghPrClientDetails, componentPath, fullDiffComment)
+			}
+		}
+		return func(componentPath string, fullDiffComment string) (string, error) {
+			return uploadDiffAsGist(ghPrClientDetails, componentPath, fullDiffComment)
+		}
+	case "checkRun":
+		return func(componentPath string, fullDiffComment string) (string, error) {
+			return uploadDiffAsCheckRun(ghPrClientDetails, componentPath, fullDiffComment)
+		}
+	case "":
+		return nil
+	default:
+		ghPrClientDetails.PrLogger.Warnf("Unknown argocd.oversizedDiffUpload value %q, falling back to concise diff comments", target)
+		return nil
+	}
+}
+
+// uploadDiffAsCheckRun attaches the full diff to a (neutral) check-run on the PR head commit, check-run output can hold roughly twice the size of a comment
+func uploadDiffAsCheckRun(ghPrClientDetails GhPrClientDetails, componentPath string, fullDiffComment string) (string, error) {
+	summary := firstN(fullDiffComment, githubCommentMaxSize-1)
+	text := ""
+	if len(fullDiffComment) > len(summary) {
+		text = firstN(fullDiffComment[len(summary):], githubCommentMaxSize-1)
+	}
+	checkRunOptions := github.CreateCheckRunOptions{
+		Name:       "telefonistka-argocd-diff/" + componentPath,
+		HeadSHA:    ghPrClientDetails.PrSHA,
+		Status:     github.String("completed"),
+		Conclusion: github.String("neutral"),
+		Output: &github.CheckRunOutput{
+			Title:   github.String("ArgoCD diff of " + componentPath),
+			Summary: github.String(summary),
+			Text:    github.String(text),
+		},
+	}
+	checkRun, resp, err := retryGhWrite(ghPrClientDetails.Ctx, "create_check_run", func() (*github.CheckRun, *github.Response, error) {
+		return ghPrClientDetails.GhClientPair.v3Client.Checks.CreateCheckRun(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, checkRunOptions)
+	})
+	if err != nil {
+		ghPrClientDetails.PrLogger.Errorf("Could not create check-run: err=%s\n%v\n", err, resp)
+		return "", err
+	}
+	return checkRun.GetHTMLURL(), nil
+}
+
 // ReciveEventFile this one is similar to ReciveWebhook but it's used for CLI triggering, i  simulates a webhook event to use the same code path as the webhook handler.
 func ReciveEventFile(eventType string, eventFilePath string, mainGhClientCache *lru.Cache[string, GhClientPair], prApproverGhClientCache *lru.Cache[string, GhClientPair]) {
 	log.Infof("Event type: %s", eventType)
@@ -416,32 +945,128 @@ func ReciveEventFile(eventType string, eventFilePath string, mainGhClientCache *
 }
 
 // ReciveWebhook is the main entry point for the webhook handling it starts parases the webhook payload and start a thread to handle the event success/failure are dependant on the payload parsing only
-func ReciveWebhook(r *http.Request, mainGhClientCache *lru.Cache[string, GhClientPair], prApproverGhClientCache *lru.Cache[string, GhClientPair], githubWebhookSecret []byte) error {
-	payload, err := github.ValidatePayload(r, githubWebhookSecret)
+// guard is optional, when set the request source and delivery ID are checked as well
+func ReciveWebhook(r *http.Request, mainGhClientCache *lru.Cache[string, GhClientPair], prApproverGhClientCache *lru.Cache[string, GhClientPair], githubWebhookSecret []byte, guard *WebhookGuard) error {
+	// Tenants can have their own webhook secret, the tenant is picked from the not yet validated payload, that's fine since
+	// a forged payload still has to be signed with the secret of the tenant it claims to belong to
+	// GITHUB_WEBHOOK_SECRET_PREVIOUS only applies to the secret of the global GitHub App
+	webhookSecrets := webhookSecretCandidates(githubWebhookSecret)
+	if tenancy.Configured() {
+		if webhookSecret, ok := tenancy.ForRepo(peekWebhookRepoSlug(r)).Lookup("GITHUB_WEBHOOK_SECRET"); ok {
+			webhookSecrets = [][]byte{[]byte(webhookSecret)}
+		}
+	}
+	return receiveWebhook(r, mainGhClientCache, prApproverGhClientCache, webhookSecrets, guard, nil)
+}
+
+// ReceiveTenantWebhook handles the webhooks sent to the endpoint of a tenant(see tenancy.Tenant.WebhookPath), they are only validated with the tenant webhook secret
+// and events of repos that belong to another tenant are rejected, so a GitHub App can't trigger the handling of another tenant
+func ReceiveTenantWebhook(r *http.Request, tenant *tenancy.Tenant, mainGhClientCache *lru.Cache[string, GhClientPair], prApproverGhClientCache *lru.Cache[string, GhClientPair], guard *WebhookGuard) error {
+	webhookSecret, _ := tenant.Lookup("GITHUB_WEBHOOK_SECRET")
+	return receiveWebhook(r, mainGhClientCache, prApproverGhClientCache, [][]byte{[]byte(webhookSecret)}, guard, tenant)
+}
+
+func receiveWebhook(r *http.Request, mainGhClientCache *lru.Cache[string, GhClientPair], prApproverGhClientCache *lru.Cache[string, GhClientPair], webhookSecrets [][]byte, guard *WebhookGuard, endpointTenant *tenancy.Tenant) error {
+	if err := guard.CheckSource(r); err != nil {
+		log.Errorf("rejecting webhook: err=%s\n", err)
+		prom.InstrumentWebhookHit("source_not_allowed")
+		return err
+	}
+	// github.ValidatePayload skips the signature check with an empty secret
+	if len(webhookSecrets[0]) == 0 {
+		log.Errorf("rejecting webhook: no webhook secret is configured for it")
+		prom.InstrumentWebhookHit("validation_failed")
+		return ErrNoWebhookSecret
+	}
+	payload, err := validateWebhookPayload(r, webhookSecrets)
 	if err != nil {
 		log.Errorf("error reading request body: err=%s\n", err)
 		prom.InstrumentWebhookHit("validation_failed")
 		return err
 	}
+	if err := guard.checkDelivery(r); err != nil {
+		log.Warnf("rejecting webhook: err=%s\n", err)
+		prom.InstrumentWebhookHit("duplicate_delivery")
+		return err
+	}
 	eventType := github.WebHookType(r)
 
 	eventPayloadInterface, err := github.ParseWebHook(eventType, payload)
 	if err != nil {
 		log.Errorf("could not parse webhook: err=%s\n", err)
 		prom.InstrumentWebhookHit("parsing_failed")
+		guard.releaseDelivery(r)
 		return err
 	}
+	if endpointTenant != nil {
+		if repoSlug := eventRepoSlug(eventPayloadInterface); repoSlug != "" && tenancy.ForRepo(repoSlug) != endpointTenant {
+			log.Errorf("rejecting webhook: %s doesn't belong to tenant %s", repoSlug, endpointTenant.Name)
+			prom.InstrumentWebhookHit("tenant_mismatch")
+			guard.releaseDelivery(r)
+			return ErrWebhookTenantMismatch
+		}
+	}
 	prom.InstrumentWebhookHit("successful")
 
-	go handleEvent(eventPayloadInterface, mainGhClientCache, prApproverGhClientCache, r, payload)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				log.Errorf("Recovered from handling webhook delivery %s: %v", github.DeliveryID(r), p)
+				guard.releaseDelivery(r)
+			}
+		}()
+		handleEvent(eventPayloadInterface, mainGhClientCache, prApproverGhClientCache, r, payload)
+	}()
 	return nil
 }
 
+// peekWebhookRepoSlug returns the repo full name of a webhook payload without consuming the request body
+func peekWebhookRepoSlug(r *http.Request) string {
+	body, err := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+	payload := body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return ""
+		}
+		payload = []byte(form.Get("payload"))
+	}
+	var event struct {
+		Repository struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return ""
+	}
+	return event.Repository.FullName
+}
+
+// eventRepoSlug returns the owner/name of the repo of a webhook event, if it has one
+func eventRepoSlug(eventPayloadInterface interface{}) string {
+	if e, ok := eventPayloadInterface.(interface{ GetRepo() *github.Repository }); ok {
+		return e.GetRepo().GetFullName()
+	}
+	return ""
+}
+
 func handleEvent(eventPayloadInterface interface{}, mainGhClientCache *lru.Cache[string, GhClientPair], prApproverGhClientCache *lru.Cache[string, GhClientPair], r *http.Request, payload []byte) {
 	// We don't use the request context as it might have a short deadline and we don't want to stop event handling based on that
-	// But we do want to stop the event handling after a certain point, so:
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	// But we do want to stop the event handling after a certain(configurable per event type and tenant) point, so:
+	ctx := tenancy.NewContext(context.Background(), tenancy.ForRepo(eventRepoSlug(eventPayloadInterface)))
+	ctx, cancel := inflight.WithEventTimeout(ctx, "github", github.WebHookType(r), eventRepoSlug(eventPayloadInterface))
 	defer cancel()
+	ctx = withIdempotencyKey(ctx, github.DeliveryID(r), eventHeadSHA(eventPayloadInterface))
+	ctx = withShadowModeState(ctx)
+	defer inflight.Track("github", github.WebHookType(r), eventRepoSlug(eventPayloadInterface))()
+	if state := maintenance.ForRepo(eventRepoSlug(eventPayloadInterface)); state.Enabled {
+		handleEventInMaintenance(ctx, state, github.WebHookType(r), eventPayloadInterface, mainGhClientCache)
+		return
+	}
 	var mainGithubClientPair GhClientPair
 	var approverGithubClientPair GhClientPair
 
@@ -451,35 +1076,37 @@ func handleEvent(eventPayloadInterface interface{}, mainGhClientCache *lru.Cache
 	case *github.PushEvent:
 		// this is a commit push, do something with it?
 		repoOwner := *eventPayload.Repo.Owner.Login
-		mainGithubClientPair.GetAndCache(mainGhClientCache, "GITHUB_APP_ID", "GITHUB_APP_PRIVATE_KEY_PATH", "GITHUB_OAUTH_TOKEN", repoOwner, ctx)
+		mainGithubClientPair.GetAndCache(mainGhClientCache, MainCredentialEnvVars(ctx), repoOwner, ctx)
 
-		prLogger := log.WithFields(log.Fields{
-			"event_type": "push",
+		prLogger := logging.ForRepo(repoOwner + "/" + *eventPayload.Repo.Name).WithFields(log.Fields{
+			"event_type":  "push",
+			"delivery_id": github.DeliveryID(r),
 		})
 
 		ghPrClientDetails := GhPrClientDetails{
-			Ctx:          ctx,
-			GhClientPair: &mainGithubClientPair,
-			Owner:        repoOwner,
-			Repo:         *eventPayload.Repo.Name,
-			RepoURL:      *eventPayload.Repo.HTMLURL,
-			PrLogger:     prLogger,
+			Ctx:           ctx,
+			GhClientPair:  &mainGithubClientPair,
+			DefaultBranch: eventPayload.Repo.GetDefaultBranch(),
+			Owner:         repoOwner,
+			Repo:          *eventPayload.Repo.Name,
+			RepoURL:       *eventPayload.Repo.HTMLURL,
+			PrLogger:      prLogger,
 		}
 
 		handlePushEvent(ctx, eventPayload, r, payload, ghPrClientDetails)
 	case *github.PullRequestEvent:
 		log.Infof("is PullRequestEvent(%s)", *eventPayload.Action)
 
-		prLogger := log.WithFields(log.Fields{
-			"repo":       *eventPayload.Repo.Owner.Login + "/" + *eventPayload.Repo.Name,
-			"prNumber":   *eventPayload.PullRequest.Number,
-			"event_type": "pr",
+		prLogger := logging.ForRepo(*eventPayload.Repo.Owner.Login + "/" + *eventPayload.Repo.Name).WithFields(log.Fields{
+			"prNumber":    *eventPayload.PullRequest.Number,
+			"event_type":  "pr",
+			"delivery_id": github.DeliveryID(r),
 		})
 
 		repoOwner := *eventPayload.Repo.Owner.Login
 
-		mainGithubClientPair.GetAndCache(mainGhClientCache, "GITHUB_APP_ID", "GITHUB_APP_PRIVATE_KEY_PATH", "GITHUB_OAUTH_TOKEN", repoOwner, ctx)
-		approverGithubClientPair.GetAndCache(prApproverGhClientCache, "APPROVER_GITHUB_APP_ID", "APPROVER_GITHUB_APP_PRIVATE_KEY_PATH", "APPROVER_GITHUB_OAUTH_TOKEN", repoOwner, ctx)
+		mainGithubClientPair.GetAndCache(mainGhClientCache, MainCredentialEnvVars(ctx), repoOwner, ctx)
+		approverGithubClientPair.GetAndCache(prApproverGhClientCache, ApproverCredentialEnvVars(ctx), repoOwner, ctx)
 
 		ghPrClientDetails := GhPrClientDetails{
 			Ctx:          ctx,
@@ -491,39 +1118,84 @@ func handleEvent(eventPayloadInterface interface{}, mainGhClientCache *lru.Cache
 			PrNumber:     *eventPayload.PullRequest.Number,
 			Ref:          *eventPayload.PullRequest.Head.Ref,
 			PrAuthor:     *eventPayload.PullRequest.User.Login,
+			PrBody:       eventPayload.PullRequest.GetBody(),
 			PrLogger:     prLogger,
 			PrSHA:        *eventPayload.PullRequest.Head.SHA,
 		}
 
 		HandlePREvent(eventPayload, ghPrClientDetails, mainGithubClientPair, approverGithubClientPair, ctx)
 
+	case *github.PullRequestReviewEvent:
+		repoOwner := *eventPayload.Repo.Owner.Login
+		mainGithubClientPair.GetAndCache(mainGhClientCache, MainCredentialEnvVars(ctx), repoOwner, ctx)
+
+		prLogger := logging.ForRepo(*eventPayload.Repo.Owner.Login + "/" + *eventPayload.Repo.Name).WithFields(log.Fields{
+			"prNumber":    *eventPayload.PullRequest.Number,
+			"event_type":  "pr_review",
+			"delivery_id": github.DeliveryID(r),
+		})
+
+		ghPrClientDetails := GhPrClientDetails{
+			Ctx:          ctx,
+			GhClientPair: &mainGithubClientPair,
+			Labels:       eventPayload.PullRequest.Labels,
+			Owner:        repoOwner,
+			Repo:         *eventPayload.Repo.Name,
+			RepoURL:      *eventPayload.Repo.HTMLURL,
+			PrNumber:     *eventPayload.PullRequest.Number,
+			Ref:          *eventPayload.PullRequest.Head.Ref,
+			PrAuthor:     *eventPayload.PullRequest.User.Login,
+			PrLogger:     prLogger,
+			PrSHA:        *eventPayload.PullRequest.Head.SHA,
+		}
+		if err := handlePrReviewEvent(ghPrClientDetails, eventPayload); err != nil {
+			prLogger.Errorf("Failed to handle PR review event: err=%v", err)
+		}
+
 	case *github.IssueCommentEvent:
 		repoOwner := *eventPayload.Repo.Owner.Login
-		mainGithubClientPair.GetAndCache(mainGhClientCache, "GITHUB_APP_ID", "GITHUB_APP_PRIVATE_KEY_PATH", "GITHUB_OAUTH_TOKEN", repoOwner, ctx)
+		mainGithubClientPair.GetAndCache(mainGhClientCache, MainCredentialEnvVars(ctx), repoOwner, ctx)
+		approverGithubClientPair.GetAndCache(prApproverGhClientCache, ApproverCredentialEnvVars(ctx), repoOwner, ctx)
 
-		botIdentity, _ := GetBotGhIdentity(mainGithubClientPair.v4Client, ctx)
-		prLogger := log.WithFields(log.Fields{
-			"repo":       *eventPayload.Repo.Owner.Login + "/" + *eventPayload.Repo.Name,
-			"prNumber":   *eventPayload.Issue.Number,
-			"event_type": "issue_comment",
+		prLogger := logging.ForRepo(*eventPayload.Repo.Owner.Login + "/" + *eventPayload.Repo.Name).WithFields(log.Fields{
+			"prNumber":    *eventPayload.Issue.Number,
+			"event_type":  "issue_comment",
+			"delivery_id": github.DeliveryID(r),
 		})
+		ghPrClientDetails := GhPrClientDetails{
+			Ctx:          ctx,
+			GhClientPair: &mainGithubClientPair,
+			Owner:        repoOwner,
+			Repo:         *eventPayload.Repo.Name,
+			RepoURL:      *eventPayload.Repo.HTMLURL,
+			PrNumber:     *eventPayload.Issue.Number,
+			PrAuthor:     *eventPayload.Issue.User.Login,
+			PrBody:       eventPayload.Issue.GetBody(),
+			PrLogger:     prLogger,
+		}
+		// Issue comments have no head ref/SHA, the PR details and the bot identity are fetched together
+		if eventPayload.Issue.IsPullRequest() {
+			if err := enrichPrDetails(&ghPrClientDetails); err != nil {
+				prLogger.Warnf("Failed to enrich the PR details, they will be fetched when needed: err=%v", err)
+			}
+		}
+		botIdentity, _ := ghPrClientDetails.botIdentity()
 		// Ignore comment events sent by the bot (this is about who trigger the event not who wrote the comment)
 		if *eventPayload.Sender.Login != botIdentity {
-			ghPrClientDetails := GhPrClientDetails{
-				Ctx:          ctx,
-				GhClientPair: &mainGithubClientPair,
-				Owner:        repoOwner,
-				Repo:         *eventPayload.Repo.Name,
-				RepoURL:      *eventPayload.Repo.HTMLURL,
-				PrNumber:     *eventPayload.Issue.Number,
-				PrAuthor:     *eventPayload.Issue.User.Login,
-				PrLogger:     prLogger,
-			}
-			_ = handleCommentPrEvent(ghPrClientDetails, eventPayload, botIdentity)
+			_ = handleCommentPrEvent(ghPrClientDetails, eventPayload, botIdentity, approverGithubClientPair.v3Client)
 		} else {
 			log.Debug("Ignoring self comment")
 		}
 
+	case *github.ReleaseEvent:
+		handleReleaseEvent(ctx, eventPayload, mainGhClientCache)
+
+	case *github.CreateEvent:
+		handleCreateEvent(ctx, eventPayload, mainGhClientCache)
+
+	case *github.RepositoryDispatchEvent:
+		handleRepositoryDispatchEvent(ctx, eventPayload, mainGhClientCache, prApproverGhClientCache)
+
 	default:
 		return
 	}
@@ -560,7 +1232,7 @@ func isSyncFromBranchAllowedForThisPath(allowedPathRegex string, path string) bo
 	return allowedPathsRegex.MatchString(path)
 }
 
-func handleCommentPrEvent(ghPrClientDetails GhPrClientDetails, ce *github.IssueCommentEvent, botIdentity string) error {
+func handleCommentPrEvent(ghPrClientDetails GhPrClientDetails, ce *github.IssueCommentEvent, botIdentity string, prApproverGithubClient *github.Client) error {
 	defaultBranch, _ := ghPrClientDetails.GetDefaultBranch()
 	config, err := GetInRepoConfig(ghPrClientDetails, defaultBranch)
 	if err != nil {
@@ -577,7 +1249,7 @@ func handleCommentPrEvent(ghPrClientDetails GhPrClientDetails, ce *github.IssueC
 		if !checkboxWaschecked && checkboxIsChecked {
 			ghPrClientDetails.PrLogger.Infof("Sync Checkbox was checked")
 			if config.Argocd.AllowSyncfromBranchPathRegex != "" {
-				ghPrClientDetails.getPrMetadata(ce.Issue.GetBody())
+				_ = ghPrClientDetails.getPrMetadata(ce.Issue.GetBody())
 				componentPathList, err := generateListOfChangedComponentPaths(ghPrClientDetails, config)
 				if err != nil {
 					ghPrClientDetails.PrLogger.Errorf("Failed to get list of changed components: err=%s\n", err)
@@ -585,6 +1257,10 @@ func handleCommentPrEvent(ghPrClientDetails GhPrClientDetails, ce *github.IssueC
 
 				for _, componentPath := range componentPathList {
 					if isSyncFromBranchAllowedForThisPath(config.Argocd.AllowSyncfromBranchPathRegex, componentPath) {
+						if skipInShadowMode(ghPrClientDetails.Ctx, "argocd_set_revision") {
+							_ = ghPrClientDetails.CommentOnPr(fmt.Sprintf("🕶️ This repo is in shadow mode, Telefonistka would have set the Target Revision of the ArgoCD app of `%s` to `%s`", componentPath, ghPrClientDetails.Ref))
+							continue
+						}
 						err := argocd.SetArgoCDAppRevision(ghPrClientDetails.Ctx, componentPath, ghPrClientDetails.Ref, ghPrClientDetails.RepoURL, config.Argocd.UseSHALabelForAppDiscovery)
 						if err != nil {
 							ghPrClientDetails.PrLogger.Errorf("Failed to sync ArgoCD app from branch: err=%s\n", err)
@@ -595,6 +1271,23 @@ func handleCommentPrEvent(ghPrClientDetails GhPrClientDetails, ce *github.IssueC
 		}
 	}
 
+	if *ce.Action == "created" && ce.Comment.User.GetLogin() != botIdentity && ce.Issue.GetState() == "open" && ce.Issue.IsPullRequest() {
+		if requestedPaths := parseSyncCommand(ce.Comment.GetBody()); len(requestedPaths) > 0 {
+			_ = ghPrClientDetails.getPrMetadata(ce.Issue.GetBody())
+			handleSyncCommand(ghPrClientDetails, config, requestedPaths, ce.Comment.User.GetLogin())
+		}
+		if isDriftCommand(ce.Comment.GetBody()) {
+			_ = ghPrClientDetails.getPrMetadata(ce.Issue.GetBody())
+			if err := detectDrift(ghPrClientDetails, true); err != nil {
+				ghPrClientDetails.PrLogger.Errorf("On demand drift detection failed: err=%v", err)
+			}
+		}
+		if isApproveCommand(ce.Comment.GetBody()) {
+			_ = ghPrClientDetails.getPrMetadata(ce.Issue.GetBody())
+			handleApproveCommand(ghPrClientDetails, config, prApproverGithubClient, ce.Issue.Labels, ce.Comment.User.GetLogin())
+		}
+	}
+
 	// I should probably deprecated this whole part altogether - it was designed to solve a *very* specific problem that is probably no longer relevant with GitHub Rulesets
 	// The only reason I'm keeping it is that I don't have a clear feature depreciation policy and if I do remove it should be in a distinct PR
 	for commentSubstring, commitStatusContext := range config.ToggleCommitStatus {
@@ -611,8 +1304,89 @@ func handleCommentPrEvent(ghPrClientDetails GhPrClientDetails, ce *github.IssueC
 	return err
 }
 
+const syncCommandPrefix = "/telefonistka sync "
+
+// parseSyncCommand returns the component paths of "/telefonistka sync <componentPath>" lines in a comment
+func parseSyncCommand(commentBody string) []string {
+	componentPaths := []string{}
+	for _, line := range strings.Split(commentBody, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, syncCommandPrefix) {
+			continue
+		}
+		componentPath := strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, syncCommandPrefix)), "`/")
+		if componentPath != "" {
+			componentPaths = append(componentPaths, componentPath)
+		}
+	}
+	return componentPaths
+}
+
+// handleSyncCommand sets the ArgoCD app Target Revision of the requested components to the PR branch, unlike the branch-sync checkbox this only touches the requested components.
+// Anyone can comment on a PR, so the commenter needs write access to the repo, like editing the checkbox in the bot comment does
+func handleSyncCommand(ghPrClientDetails GhPrClientDetails, config *cfg.Config, requestedPaths []string, commenter string) {
+	if config.Argocd.AllowSyncfromBranchPathRegex == "" {
+		_ = commentPR(ghPrClientDetails, "Syncing ArgoCD apps from PR branches is not enabled in this repo(`argocd.allowSyncfromBranchPathRegex`)")
+		return
+	}
+	canWrite, err := ghPrClientDetails.userHasWriteAccess(commenter)
+	if err != nil {
+		ghPrClientDetails.PrLogger.Errorf("Failed to get the repo permission of %s: err=%s\n", commenter, err)
+	}
+	if !canWrite {
+		ghPrClientDetails.PrLogger.Warnf("Refusing sync command of %s, the user doesn't have write access to the repo", commenter)
+		_ = commentPR(ghPrClientDetails, fmt.Sprintf("@%s syncing ArgoCD apps from the PR branch requires write access to this repo", commenter))
+		return
+	}
+	componentPathList, err := generateListOfChangedComponentPaths(ghPrClientDetails, config)
+	if err != nil {
+		ghPrClientDetails.PrLogger.Errorf("Failed to get list of changed components: err=%s\n", err)
+		return
+	}
+	changedComponents := map[string]bool{}
+	for _, componentPath := range componentPathList {
+		changedComponents[strings.Trim(componentPath, "/")] = true
+	}
+
+	results := []string{}
+	for _, componentPath := range requestedPaths {
+		switch {
+		case !changedComponents[componentPath]:
+			results = append(results, fmt.Sprintf("❌ `%s` is not a component changed by this PR", componentPath))
+		case !isSyncFromBranchAllowedForThisPath(config.Argocd.AllowSyncfromBranchPathRegex, componentPath):
+			results = append(results, fmt.Sprintf("❌ `%s` is not allowed to sync from a branch", componentPath))
+		case skipInShadowMode(ghPrClientDetails.Ctx, "argocd_set_revision"):
+			results = append(results, fmt.Sprintf("🕶️ `%s`: shadow mode, would have set the ArgoCD app Target Revision to `%s`", componentPath, ghPrClientDetails.Ref))
+		default:
+			err := argocd.SetArgoCDAppRevision(ghPrClientDetails.Ctx, componentPath, ghPrClientDetails.Ref, ghPrClientDetails.RepoURL, config.Argocd.UseSHALabelForAppDiscovery)
+			if err != nil {
+				ghPrClientDetails.PrLogger.Errorf("Failed to sync ArgoCD app of %s from branch: err=%s\n", componentPath, err)
+				results = append(results, fmt.Sprintf("❌ `%s`: failed to set the ArgoCD app Target Revision: %s", componentPath, err))
+			} else {
+				results = append(results, fmt.Sprintf("✅ `%s`: ArgoCD app Target Revision set to `%s`", componentPath, ghPrClientDetails.Ref))
+			}
+		}
+	}
+	_ = commentPR(ghPrClientDetails, strings.Join(results, "\n"))
+}
+
+// userHasWriteAccess checks the user has write(or admin) permission on the repo, maintainers get "write" from this API as well
+func (ghPrClientDetails *GhPrClientDetails) userHasWriteAccess(login string) (bool, error) {
+	permissionLevel, resp, err := ghPrClientDetails.GhClientPair.v3Client.Repositories.GetPermissionLevel(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, login)
+	prom.InstrumentGhCall(resp)
+	if err != nil {
+		return false, err
+	}
+	switch permissionLevel.GetPermission() {
+	case "admin", "write":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
 func commentPlanInPR(ghPrClientDetails GhPrClientDetails, promotions map[string]PromotionInstance) {
-	templateOutput, err := executeTemplate("dryRunMsg", defaultTemplatesFullPath("dry-run-pr-comment.gotmpl"), promotions)
+	templateOutput, err := executeRepoTemplate(ghPrClientDetails, "dryRunMsg", "dry-run-pr-comment.gotmpl", promotions)
 	if err != nil {
 		ghPrClientDetails.PrLogger.Errorf("Failed to generate dry-run comment template: err=%s\n", err)
 		return
@@ -622,7 +1396,7 @@ func commentPlanInPR(ghPrClientDetails GhPrClientDetails, promotions map[string]
 
 func executeTemplate(templateName string, templateFile string, data interface{}) (string, error) {
 	var templateOutput bytes.Buffer
-	messageTemplate, err := template.New(templateName).ParseFiles(templateFile)
+	messageTemplate, err := template.New(templateName).Funcs(templateFuncs).ParseFiles(templateFile)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse template: %w", err)
 	}
@@ -633,8 +1407,8 @@ func executeTemplate(templateName string, templateFile string, data interface{})
 	return templateOutput.String(), nil
 }
 
-func defaultTemplatesFullPath(templateFile string) string {
-	return filepath.Join(getEnv("TEMPLATES_PATH", "templates/") + templateFile)
+func defaultTemplatesFullPath(ctx context.Context, templateFile string) string {
+	return filepath.Join(tenancy.Getenv(ctx, "TEMPLATES_PATH", "templates/") + templateFile)
 }
 
 func commentPR(ghPrClientDetails GhPrClientDetails, commentBody string) error {
@@ -646,164 +1420,450 @@ func commentPR(ghPrClientDetails GhPrClientDetails, commentBody string) error {
 	return nil
 }
 
-func BumpVersion(ghPrClientDetails GhPrClientDetails, defaultBranch string, filePath string, newFileContent string, triggeringRepo string, triggeringRepoSHA string, triggeringActor string, autoMerge bool) error {
+// BumpVersion updates a single file and returns the opened PR
+func BumpVersion(ghPrClientDetails GhPrClientDetails, defaultBranch string, filePath string, newFileContent string, triggeringRepo string, triggeringRepoSHA string, triggeringActor string, autoMerge bool) (*github.PullRequest, error) {
+	return BumpVersionMultiFile(ghPrClientDetails, defaultBranch, map[string]string{filePath: newFileContent}, triggeringRepo, triggeringRepoSHA, triggeringActor, autoMerge)
+}
+
+// bumpDescription returns the commit/PR title suffix of a bump, multi-file bumps list the files in the PR body instead
+func bumpDescription(filePaths []string) string {
+	if len(filePaths) == 1 {
+		return "Bumping version @ " + filePaths[0]
+	}
+	return fmt.Sprintf("Bumping version @ %d files", len(filePaths))
+}
+
+// BumpVersionMultiFile updates several files(path to new content) in a single commit and PR and returns the opened PR
+func BumpVersionMultiFile(ghPrClientDetails GhPrClientDetails, defaultBranch string, newFileContents map[string]string, triggeringRepo string, triggeringRepoSHA string, triggeringActor string, autoMerge bool) (*github.PullRequest, error) {
 	var treeEntries []*github.TreeEntry
 
-	generateBumpTreeEntiesForCommit(&treeEntries, ghPrClientDetails, defaultBranch, filePath, newFileContent)
+	filePaths := maps.Keys(newFileContents)
+	sort.Strings(filePaths)
+	for _, filePath := range filePaths {
+		generateBumpTreeEntiesForCommit(&treeEntries, ghPrClientDetails, defaultBranch, filePath, newFileContents[filePath])
+	}
 
-	commit, err := createCommit(ghPrClientDetails, treeEntries, defaultBranch, "Bumping version @ "+filePath)
+	commit, err := createCommit(ghPrClientDetails, treeEntries, defaultBranch, bumpDescription(filePaths))
 	if err != nil {
 		ghPrClientDetails.PrLogger.Errorf("Commit creation failed: err=%v", err)
-		return err
+		return nil, err
 	}
 	newBranchRef, err := createBranch(ghPrClientDetails, commit, "artifact_version_bump/"+triggeringRepo+"/"+triggeringRepoSHA) // TODO figure out branch name!!!!
 	if err != nil {
 		ghPrClientDetails.PrLogger.Errorf("Branch creation failed: err=%v", err)
+		return nil, err
+	}
+
+	newPrTitle := triggeringRepo + "🚠 " + bumpDescription(filePaths)
+	newPrBody := fmt.Sprintf("Bumping version triggered by %s@%s", triggeringRepo, triggeringRepoSHA)
+	if len(filePaths) > 1 {
+		newPrBody += "\n\nUpdated files:\n* `" + strings.Join(filePaths, "`\n* `") + "`"
+	}
+	pr, err := createPrObject(ghPrClientDetails, newBranchRef, newPrTitle, newPrBody, defaultBranch, assignedTo(triggeringActor), []string{"promotion"})
+	if err != nil {
+		ghPrClientDetails.PrLogger.Errorf("PR opening failed: err=%v", err)
+		return nil, err
+	}
+
+	ghPrClientDetails.PrLogger.Infof("New PR URL: %s", *pr.HTMLURL)
+
+	if autoMerge {
+		ghPrClientDetails.PrLogger.Infof("Auto-merging PR %d", *pr.Number)
+		err := MergePr(ghPrClientDetails, pr.Number)
+		if err != nil {
+			ghPrClientDetails.PrLogger.Errorf("PR auto merge failed: err=%v", err)
+			return pr, err
+		}
+	}
+
+	return pr, nil
+}
+
+func handleMergedPrEvent(ghPrClientDetails GhPrClientDetails, prApproverGithubClient *github.Client, mergeCommitSHA string) error {
+	defaultBranch, _ := ghPrClientDetails.GetDefaultBranch()
+	config, err := GetInRepoConfig(ghPrClientDetails, defaultBranch)
+	if err != nil {
+		_ = ghPrClientDetails.CommentOnPr(fmt.Sprintf("Failed to get configuration\n```\n%s\n```\n", err))
 		return err
 	}
 
-	newPrTitle := triggeringRepo + "🚠 Bumping version @ " + filePath
-	newPrBody := fmt.Sprintf("Bumping version triggered by %s@%s", triggeringRepo, triggeringRepoSHA)
-	pr, err := createPrObject(ghPrClientDetails, newBranchRef, newPrTitle, newPrBody, defaultBranch, triggeringActor)
-	if err != nil {
-		ghPrClientDetails.PrLogger.Errorf("PR opening failed: err=%v", err)
-		return err
-	}
+	// configBranch = default branch as the PR is closed at this and its branch deleted.
+	// If we'l ever want to generate this plan on an unmerged PR the PR branch (ghPrClientDetails.Ref) should be used
+	promotions, _ := GeneratePromotionPlan(ghPrClientDetails, config, defaultBranch)
+	if DoesPrHasLabel(ghPrClientDetails.Labels, backPromotionLabel(config)) {
+		// Back-promotion PRs sync earlier environments with an already promoted hotfix, promoting them again would loop
+		ghPrClientDetails.PrLogger.Infof("Not promoting back-promotion PR")
+		promotions = map[string]PromotionInstance{}
+	}
+	if key, nextStep, ok := nextPromotionStep(ghPrClientDetails.PrMetadata.PromotionSteps, ghPrClientDetails.PrNumber, config.EnvironmentPathNames()); ok {
+		promotions[key] = nextStep
+	}
+	if key, nextLayer, ok := nextComponentLayer(ghPrClientDetails.PrMetadata.ComponentOrder, ghPrClientDetails.PrNumber, config.EnvironmentPathNames()); ok {
+		promotions[key] = nextLayer
+	}
+	pausedTargets := applyPromotionPauses(ghPrClientDetails, promotions, defaultBranch)
+	if pausedTargets > 0 {
+		prom.InstrumentPausedPromotionTargets(ghPrClientDetails.Owner+"/"+ghPrClientDetails.Repo, pausedTargets)
+		if !config.DryRunMode {
+			// The dry run comment already lists the paused targets
+			_ = ghPrClientDetails.CommentOnPr(pausedPromotionsComment(promotions))
+		}
+	}
+	if !config.DryRunMode {
+		// The dry run plan comment shows the plan before the canary steps, the component order and atomicPromotions change it
+		promotions = preparePromotionWave(config, promotions)
+		readyPromotions, heldPromotions := splitHeldPromotions(config, promotions, time.Now())
+		if len(heldPromotions) > 0 {
+			if err := holdPromotions(ghPrClientDetails, heldPromotions); err != nil {
+				ghPrClientDetails.PrLogger.Errorf("Failed to mark promotions as held by a promotion train: err=%v", err)
+			}
+		}
+		if len(readyPromotions) > 0 && config.Argocd.Rollouts.Enabled && mergeCommitSHA != "" {
+			if componentPaths := rolloutComponents(ghPrClientDetails, config); len(componentPaths) > 0 {
+				_ = ghPrClientDetails.CommentOnPr(rolloutHoldComment(componentPaths))
+				// Rollouts can take much longer than the event handling timeout
+				go promoteAfterRollouts(ghPrClientDetails, config, readyPromotions, componentPaths, defaultBranch, prApproverGithubClient, mergeCommitSHA, time.Now())
+				readyPromotions = map[string]PromotionInstance{}
+			}
+		}
+		err = openPromotionPrs(ghPrClientDetails, config, readyPromotions, defaultBranch, prApproverGithubClient, mergeCommitSHA)
+	} else {
+		commentPlanInPR(ghPrClientDetails, promotions)
+	}
+
+	if config.Argocd.AllowSyncfromBranchPathRegex != "" {
+		componentPathList, err := generateListOfChangedComponentPaths(ghPrClientDetails, config)
+		if err != nil {
+			ghPrClientDetails.PrLogger.Errorf("Failed to get list of changed components for setting ArgoCD app targetRef to HEAD: err=%s\n", err)
+		}
+		for _, componentPath := range componentPathList {
+			if isSyncFromBranchAllowedForThisPath(config.Argocd.AllowSyncfromBranchPathRegex, componentPath) && !skipInShadowMode(ghPrClientDetails.Ctx, "argocd_set_revision") {
+				ghPrClientDetails.PrLogger.Infof("Ensuring ArgoCD app %s is set to HEAD\n", componentPath)
+				err := argocd.SetArgoCDAppRevision(ghPrClientDetails.Ctx, componentPath, "HEAD", ghPrClientDetails.RepoURL, config.Argocd.UseSHALabelForAppDiscovery)
+				if err != nil {
+					ghPrClientDetails.PrLogger.Errorf("Failed to set ArgoCD app @  %s, to HEAD: err=%s\n", componentPath, err)
+				}
+			}
+		}
+	}
+
+	if DoesPrHasLabel(ghPrClientDetails.Labels, "promotion") {
+		notifications.Send(ghPrClientDetails.Ctx, notifications.Event{
+			Type:     notifications.PromotionPrMerged,
+			Repo:     ghPrClientDetails.Owner + "/" + ghPrClientDetails.Repo,
+			PrNumber: ghPrClientDetails.PrNumber,
+			PrURL:    fmt.Sprintf("%s/pull/%d", ghPrClientDetails.RepoURL, ghPrClientDetails.PrNumber),
+			Title:    fmt.Sprintf("Promotion PR #%d was merged", ghPrClientDetails.PrNumber),
+			Text:     fmt.Sprintf("Promoted to %s", strings.Join(ghPrClientDetails.PrMetadata.PromotedPaths, ", ")),
+			Facts:    map[string]string{"Targets": strings.Join(ghPrClientDetails.PrMetadata.PromotedPaths, ", "), "Merge commit": mergeCommitSHA},
+		})
+	}
+
+	if len(config.GrafanaAnnotations) > 0 && DoesPrHasLabel(ghPrClientDetails.Labels, "promotion") {
+		annotatePromotionMerge(ghPrClientDetails, config)
+	}
+
+	if config.IssueTracker.FinalPromotionTransition != "" && DoesPrHasLabel(ghPrClientDetails.Labels, "promotion") && isFinalPromotionTarget(config, ghPrClientDetails.PrMetadata.PromotedPaths) {
+		transitionPromotedIssues(ghPrClientDetails, config.IssueTracker.FinalPromotionTransition)
+	}
+
+	if config.Hotfix.BackPromotion && !config.DryRunMode && len(ghPrClientDetails.PrMetadata.HotfixSkippedPaths) > 0 {
+		pull, err := openBackPromotionPr(ghPrClientDetails, config, defaultBranch)
+		if err != nil {
+			ghPrClientDetails.PrLogger.Errorf("Back-promotion PR opening failed: err=%v", err)
+			_ = ghPrClientDetails.CommentOnPr(fmt.Sprintf("Failed to open a back-promotion PR for the paths this hotfix skipped, they should be synced manually\n```\n%s\n```\n", err))
+		} else if pull != nil {
+			_ = ghPrClientDetails.CommentOnPr(fmt.Sprintf("Opened back-promotion PR #%d to sync the paths this hotfix skipped", pull.GetNumber()))
+		}
+	}
+
+	if config.AutoRebaseConflictingPromotionPrs && !config.DryRunMode {
+		// GitHub computes the mergeability of the other PRs in the background, so this waits for it outside the event handling
+		go rebaseConflictingPromotionPrs(ghPrClientDetails, defaultBranch)
+	}
+
+	if config.Argocd.PostMergeSync.Enabled && !config.DryRunMode && mergeCommitSHA != "" {
+		// Waiting for the apps can take much longer than the event handling timeout
+		go postMergeSync(ghPrClientDetails, config, mergeCommitSHA)
+	}
+
+	return err
+}
+
+// sendPromotionGateFailed notifies about a promotion of the merged PR that a gate blocked from being opened
+func sendPromotionGateFailed(ghPrClientDetails GhPrClientDetails, promotion PromotionInstance, gate string, reason string) {
+	notifications.Send(ghPrClientDetails.Ctx, notifications.Event{
+		Type:     notifications.PromotionGateFailed,
+		Repo:     ghPrClientDetails.Owner + "/" + ghPrClientDetails.Repo,
+		PrNumber: ghPrClientDetails.PrNumber,
+		PrURL:    fmt.Sprintf("%s/pull/%d", ghPrClientDetails.RepoURL, ghPrClientDetails.PrNumber),
+		Title:    fmt.Sprintf("Promotion of #%d blocked by %s", ghPrClientDetails.PrNumber, gate),
+		Text:     reason,
+		Facts:    map[string]string{"Gate": gate, "Source": promotion.Metadata.SourcePath, "Targets": strings.Join(promotion.Metadata.TargetPaths, ", ")},
+	})
+}
+
+// openPromotionPrs opens(or updates, see supersedeOpenPromotionPrs) the promotion PRs of a merged PR, then approves and auto-merges them when configured
+// sourceSHA keys the progress recorded on the source PR, so promotions opened by an earlier delivery of the same merge are skipped
+func openPromotionPrs(ghPrClientDetails GhPrClientDetails, config *cfg.Config, promotions map[string]PromotionInstance, defaultBranch string, prApproverGithubClient *github.Client, sourceSHA string) error {
+	var err error
+	var progress *promotionProgress
+	allPromotionKeys := promotionKeys(promotions)
+	var scanner *secretScanner
+	if config.SecretScanning.Mode != "" {
+		scanner = newSecretScanner(config.SecretScanning)
+	}
+	if sourceSHA != "" && len(promotions) > 0 {
+		progress, err = loadPromotionProgress(ghPrClientDetails, sourceSHA)
+		if err != nil {
+			ghPrClientDetails.PrLogger.Errorf("Failed to load the promotion progress of %s: err=%v", sourceSHA, err)
+			return err
+		}
+	}
+	for _, promotion := range promotions {
+		promotionKey := promotionKeyFor(promotion.Metadata.SourcePath, maps.Keys(promotion.ComputedSyncPaths))
+		// Atomic promotions are opened even when a gate fails, so none of their targets are merged while others wait
+		var gateFailures []string
+		if progress != nil {
+			if prNumber, ok := progress.opened[promotionKey]; ok {
+				ghPrClientDetails.PrLogger.Infof("Promotion %s was already opened in PR %d, skipping", promotionKey, prNumber)
+				continue
+			}
+		}
+		if promotionFilePolicyEnabled(config.PromotionFilePolicy) {
+			violations := checkPromotionFilePolicy(ghPrClientDetails, config.PromotionFilePolicy, promotion, defaultBranch)
+			if len(violations) > 0 {
+				ghPrClientDetails.PrLogger.Warnf("Promotion %s violates the promotion file policy, not opening it", promotionKey)
+				_ = ghPrClientDetails.CommentOnPr(promotionFilePolicyComment(promotion, violations))
+				sendPromotionGateFailed(ghPrClientDetails, promotion, "promotion file policy", fmt.Sprintf("%d files violate the promotion file policy", len(violations)))
+				if !promotion.Metadata.Atomic {
+					continue
+				}
+				gateFailures = append(gateFailures, "promotion file policy")
+			}
+		}
+		if scanner != nil {
+			findings := scanner.scanPromotion(ghPrClientDetails, promotion, defaultBranch)
+			if len(findings) > 0 {
+				prom.InstrumentSecretScanFindings(ghPrClientDetails.Owner+"/"+ghPrClientDetails.Repo, config.SecretScanning.Mode)
+				_ = ghPrClientDetails.CommentOnPr(secretScanningComment(promotion, findings, config.SecretScanning.Mode))
+				if config.SecretScanning.Mode == cfg.SecretScanningBlock {
+					ghPrClientDetails.PrLogger.Warnf("Secret scanning found possible credentials in promotion %s, not opening it", promotionKey)
+					sendPromotionGateFailed(ghPrClientDetails, promotion, "secret scanning", fmt.Sprintf("%d possible credentials found", len(findings)))
+					if !promotion.Metadata.Atomic {
+						continue
+					}
+					gateFailures = append(gateFailures, "secret scanning")
+				}
+			}
+		}
+
+		// TODO this whole part shouldn't be in main, but I need to refactor some circular dep's
 
-	ghPrClientDetails.PrLogger.Infof("New PR URL: %s", *pr.HTMLURL)
+		// because I use GitHub low level (tree) API the order of operation is somewhat different compared to regular git CLI flow:
+		// I create the sync commit against HEAD, create a new branch based on that commit and finally open a PR based on that branch
 
-	if autoMerge {
-		ghPrClientDetails.PrLogger.Infof("Auto-merging PR %d", *pr.Number)
-		err := MergePr(ghPrClientDetails, pr.Number)
+		var treeEntries []*github.TreeEntry
+		for trgt, src := range promotion.ComputedSyncPaths {
+			err = GenerateSyncTreeEntriesForCommit(&treeEntries, ghPrClientDetails, src, trgt, defaultBranch)
+			if err != nil {
+				ghPrClientDetails.PrLogger.Errorf("Failed to generate treeEntries for %s > %s,  err=%v", src, trgt, err)
+			} else {
+				ghPrClientDetails.PrLogger.Debugf("Generated treeEntries for %s > %s", src, trgt)
+			}
+		}
+
+		if len(treeEntries) < 1 {
+			ghPrClientDetails.PrLogger.Infof("TreeEntries list is empty")
+			continue
+		}
+
+		commit, err := createCommit(ghPrClientDetails, treeEntries, defaultBranch, promotionCommitMessage(config, promotion.Metadata.SourcePath))
 		if err != nil {
-			ghPrClientDetails.PrLogger.Errorf("PR auto merge failed: err=%v", err)
+			ghPrClientDetails.PrLogger.Errorf("Commit creation failed: err=%v", err)
 			return err
 		}
-	}
 
-	return nil
-}
-
-func handleMergedPrEvent(ghPrClientDetails GhPrClientDetails, prApproverGithubClient *github.Client) error {
-	defaultBranch, _ := ghPrClientDetails.GetDefaultBranch()
-	config, err := GetInRepoConfig(ghPrClientDetails, defaultBranch)
-	if err != nil {
-		_ = ghPrClientDetails.CommentOnPr(fmt.Sprintf("Failed to get configuration\n```\n%s\n```\n", err))
-		return err
-	}
+		components := strings.Join(promotion.Metadata.ComponentNames, ",")
+		newPrTitle := fmt.Sprintf("🚀 Promotion: %s ➡️  %s", components, promotion.Metadata.TargetDescription)
 
-	// configBranch = default branch as the PR is closed at this and its branch deleted.
-	// If we'l ever want to generate this plan on an unmerged PR the PR branch (ghPrClientDetails.Ref) should be used
-	promotions, _ := GeneratePromotionPlan(ghPrClientDetails, config, defaultBranch)
-	if !config.DryRunMode {
-		for _, promotion := range promotions {
-			// TODO this whole part shouldn't be in main, but I need to refactor some circular dep's
+		var originalPrAuthor string
+		// If the triggering PR was opened manually and it doesn't include in-body metadata, use the PR author
+		// If the triggering PR as opened by Telefonistka and it has in-body metadata, fetch the original author from there
+		if ghPrClientDetails.PrMetadata.OriginalPrAuthor != "" {
+			originalPrAuthor = ghPrClientDetails.PrMetadata.OriginalPrAuthor
+		} else {
+			originalPrAuthor = ghPrClientDetails.PrAuthor
+		}
 
-			// because I use GitHub low level (tree) API the order of operation is somewhat different compared to regular git CLI flow:
-			// I create the sync commit against HEAD, create a new branch based on that commit and finally open a PR based on that branch
+		newPrLabels, err := promotionPrLabels(config, ghPrClientDetails.Labels)
+		if err != nil {
+			ghPrClientDetails.PrLogger.Warnf("Failed to match labels to propagate to the promotion PR: err=%v", err)
+		}
+		promotedPaths := maps.Keys(promotion.ComputedSyncPaths)
 
-			var treeEntries []*github.TreeEntry
-			for trgt, src := range promotion.ComputedSyncPaths {
-				err = GenerateSyncTreeEntriesForCommit(&treeEntries, ghPrClientDetails, src, trgt, defaultBranch)
-				if err != nil {
-					ghPrClientDetails.PrLogger.Errorf("Failed to generate treeEntries for %s > %s,  err=%v", src, trgt, err)
-				} else {
-					ghPrClientDetails.PrLogger.Debugf("Generated treeEntries for %s > %s", src, trgt)
-				}
+		var supersededPr *github.PullRequest
+		switch config.SupersedeOpenPromotionPrs {
+		case "":
+		case supersedeModeUpdate, supersedeModeClose:
+			supersededPr, err = findSupersededPromotionPr(ghPrClientDetails, defaultBranch, promotionKeyFor(promotion.Metadata.SourcePath, promotedPaths))
+			if err != nil {
+				ghPrClientDetails.PrLogger.Warnf("Failed to look for open promotion PRs of the same paths: err=%v", err)
 			}
+		default:
+			ghPrClientDetails.PrLogger.Warnf("Ignoring unknown supersedeOpenPromotionPrs value %q", config.SupersedeOpenPromotionPrs)
+		}
 
-			if len(treeEntries) < 1 {
-				ghPrClientDetails.PrLogger.Infof("TreeEntries list is empty")
-				continue
-			}
+		// The PR metadata is signed for the branch of the promotion PR
+		newBranchName := GenerateSafePromotionBranchName(ghPrClientDetails.PrNumber, ghPrClientDetails.Ref, promotion.Metadata.TargetPaths)
+		if supersededPr != nil && config.SupersedeOpenPromotionPrs == supersedeModeUpdate {
+			newBranchName = supersededPr.GetHead().GetRef()
+		}
+		newPrBody := generatePromotionPrBody(ghPrClientDetails, components, promotion, originalPrAuthor, newBranchName)
 
-			commit, err := createCommit(ghPrClientDetails, treeEntries, defaultBranch, "Syncing from "+promotion.Metadata.SourcePath)
+		var pull *github.PullRequest
+		if supersededPr != nil && config.SupersedeOpenPromotionPrs == supersedeModeUpdate {
+			pull, err = updatePromotionPrInPlace(ghPrClientDetails, supersededPr, commit, newPrTitle, newPrBody)
 			if err != nil {
-				ghPrClientDetails.PrLogger.Errorf("Commit creation failed: err=%v", err)
+				ghPrClientDetails.PrLogger.Errorf("Updating open promotion PR failed: err=%v", err)
 				return err
 			}
-
-			newBranchName := generateSafePromotionBranchName(ghPrClientDetails.PrNumber, ghPrClientDetails.Ref, promotion.Metadata.TargetPaths)
-
-			newBranchRef, err := createBranch(ghPrClientDetails, commit, newBranchName)
+			err = labelPr(ghPrClientDetails, pull.GetNumber(), newPrLabels)
+			if err != nil {
+				ghPrClientDetails.PrLogger.Warnf("Could not label updated promotion PR: err=%v", err)
+			}
+		} else {
+			newBranchRef, err := createOrResetBranch(ghPrClientDetails, commit, newBranchName)
 			if err != nil {
 				ghPrClientDetails.PrLogger.Errorf("Branch creation failed: err=%v", err)
 				return err
 			}
 
-			components := strings.Join(promotion.Metadata.ComponentNames, ",")
-			newPrTitle := fmt.Sprintf("🚀 Promotion: %s ➡️  %s", components, promotion.Metadata.TargetDescription)
-
-			var originalPrAuthor string
-			// If the triggering PR was opened manually and it doesn't include in-body metadata, use the PR author
-			// If the triggering PR as opened by Telefonistka and it has in-body metadata, fetch the original author from there
-			if ghPrClientDetails.PrMetadata.OriginalPrAuthor != "" {
-				originalPrAuthor = ghPrClientDetails.PrMetadata.OriginalPrAuthor
-			} else {
-				originalPrAuthor = ghPrClientDetails.PrAuthor
-			}
-
-			newPrBody := generatePromotionPrBody(ghPrClientDetails, components, promotion, originalPrAuthor)
-
-			pull, err := createPrObject(ghPrClientDetails, newBranchRef, newPrTitle, newPrBody, defaultBranch, originalPrAuthor)
+			pull, err = createPrObject(ghPrClientDetails, newBranchRef, newPrTitle, newPrBody, defaultBranch, promotionPrRouting(config, promotedPaths, originalPrAuthor), newPrLabels)
 			if err != nil {
 				ghPrClientDetails.PrLogger.Errorf("PR opening failed: err=%v", err)
 				return err
 			}
-			if config.AutoApprovePromotionPrs {
-				err := ApprovePr(prApproverGithubClient, ghPrClientDetails, pull.Number)
+			notifications.Send(ghPrClientDetails.Ctx, notifications.Event{
+				Type:     notifications.PromotionPrOpened,
+				Repo:     ghPrClientDetails.Owner + "/" + ghPrClientDetails.Repo,
+				PrNumber: pull.GetNumber(),
+				PrURL:    pull.GetHTMLURL(),
+				Title:    newPrTitle,
+				Text:     fmt.Sprintf("Promotion PR #%d was opened for #%d", pull.GetNumber(), ghPrClientDetails.PrNumber),
+				Facts:    map[string]string{"Source": promotion.Metadata.SourcePath, "Targets": strings.Join(promotion.Metadata.TargetPaths, ", ")},
+			})
+			if supersededPr != nil {
+				err := closePromotionPr(ghPrClientDetails, supersededPr, fmt.Sprintf("Closing this promotion PR, it was superseded by #%d which promotes the same paths.", pull.GetNumber()))
 				if err != nil {
-					ghPrClientDetails.PrLogger.Errorf("PR auto approval failed: err=%v", err)
-					return err
+					ghPrClientDetails.PrLogger.Warnf("Failed to close superseded promotion PR %d: err=%v", supersededPr.GetNumber(), err)
 				}
 			}
-			if promotion.Metadata.AutoMerge {
-				ghPrClientDetails.PrLogger.Infof("Auto-merging PR %d", *pull.Number)
-				templateData := map[string]interface{}{
-					"prNumber": *pull.Number,
-				}
-				templateOutput, err := executeTemplate("autoMerge", defaultTemplatesFullPath("auto-merge-comment.gotmpl"), templateData)
-				if err != nil {
-					return err
-				}
-				err = commentPR(ghPrClientDetails, templateOutput)
-				if err != nil {
-					return err
-				}
+		}
+		if progress != nil {
+			err := progress.record(ghPrClientDetails, allPromotionKeys, promotionKey, pull.GetNumber())
+			if err != nil {
+				ghPrClientDetails.PrLogger.Warnf("Failed to record the promotion progress: err=%v", err)
+			}
+		}
+		err = requestRequiredReviews(ghPrClientDetails, *pull.Number, generateRequiredApprovers(config, promotedPaths))
+		if err != nil {
+			ghPrClientDetails.PrLogger.Warnf("Failed to request required reviews: err=%v", err)
+		}
+		if config.CodeOwners.RequestReviews {
+			err := requestCodeOwnerReviews(ghPrClientDetails, pull.GetNumber(), promotedPaths, defaultBranch)
+			if err != nil {
+				ghPrClientDetails.PrLogger.Warnf("Failed to request code owner reviews: err=%v", err)
+			}
+		}
+		if len(gateFailures) > 0 {
+			blockAtomicPromotionPr(ghPrClientDetails, config, pull.GetNumber(), gateFailures)
+			continue
+		}
+		if config.AutoApprovePromotionPrs {
+			err := ApprovePr(prApproverGithubClient, ghPrClientDetails, pull.Number)
+			if err != nil {
+				ghPrClientDetails.PrLogger.Errorf("PR auto approval failed: err=%v", err)
+				return err
+			}
+		}
+		if promotion.Metadata.AutoMerge {
+			approved, err := checkRequiredApprovals(ghPrClientDetails, config, *pull.Number, promotedPaths)
+			if err != nil {
+				ghPrClientDetails.PrLogger.Errorf("Failed to check required approvals: err=%v", err)
+				return err
+			}
+			if !approved {
+				continue
+			}
+			ghPrClientDetails.PrLogger.Infof("Auto-merging PR %d", *pull.Number)
+			templateData := map[string]interface{}{
+				"prNumber": *pull.Number,
+			}
+			templateOutput, err := executeRepoTemplate(ghPrClientDetails, "autoMerge", "auto-merge-comment.gotmpl", templateData)
+			if err != nil {
+				return err
+			}
+			err = commentPR(ghPrClientDetails, templateOutput)
+			if err != nil {
+				return err
+			}
 
-				err = MergePr(ghPrClientDetails, pull.Number)
-				if err != nil {
-					ghPrClientDetails.PrLogger.Errorf("PR auto merge failed: err=%v", err)
-					return err
-				}
+			err = MergePr(ghPrClientDetails, pull.Number)
+			if errors.Is(err, ErrConflict) || errors.Is(err, ErrValidationFailed) {
+				// GitHub refused to merge this PR(conflicts, branch protections...), the other promotions can still be opened and merged
+				ghPrClientDetails.PrLogger.Warnf("PR %d can't be auto merged: err=%v", *pull.Number, err)
+				_ = commentPR(ghPrClientDetails, fmt.Sprintf("Telefonistka couldn't auto merge promotion PR #%d, GitHub refused the merge: %v", *pull.Number, err))
+				err = nil
+				continue
+			}
+			if err != nil {
+				ghPrClientDetails.PrLogger.Errorf("PR auto merge failed: err=%v", err)
+				return err
 			}
 		}
-	} else {
-		commentPlanInPR(ghPrClientDetails, promotions)
 	}
+	return err
+}
 
-	if config.Argocd.AllowSyncfromBranchPathRegex != "" {
-		componentPathList, err := generateListOfChangedComponentPaths(ghPrClientDetails, config)
+// handleClosedPrEvent points ArgoCD apps that were synced from the branch of a PR closed without merging back to HEAD, as the branch is usually deleted
+func handleClosedPrEvent(ghPrClientDetails GhPrClientDetails) error {
+	defaultBranch, _ := ghPrClientDetails.GetDefaultBranch()
+	config, err := GetInRepoConfig(ghPrClientDetails, defaultBranch)
+	if err != nil {
+		return err
+	}
+	if config.Argocd.AllowSyncfromBranchPathRegex == "" {
+		return nil
+	}
+	componentPathList, err := generateListOfChangedComponentPaths(ghPrClientDetails, config)
+	if err != nil {
+		ghPrClientDetails.PrLogger.Errorf("Failed to get list of changed components for reverting ArgoCD apps targetRef: err=%s\n", err)
+		return err
+	}
+	revertedApps := []string{}
+	for _, componentPath := range componentPathList {
+		if !isSyncFromBranchAllowedForThisPath(config.Argocd.AllowSyncfromBranchPathRegex, componentPath) || skipInShadowMode(ghPrClientDetails.Ctx, "argocd_revert_revision") {
+			continue
+		}
+		reverted, appName, err := argocd.RevertArgoCDAppRevision(ghPrClientDetails.Ctx, componentPath, ghPrClientDetails.Ref, "HEAD", ghPrClientDetails.RepoURL, config.Argocd.UseSHALabelForAppDiscovery)
 		if err != nil {
-			ghPrClientDetails.PrLogger.Errorf("Failed to get list of changed components for setting ArgoCD app targetRef to HEAD: err=%s\n", err)
+			ghPrClientDetails.PrLogger.Errorf("Failed to revert ArgoCD app @ %s to HEAD: err=%s\n", componentPath, err)
+			continue
 		}
-		for _, componentPath := range componentPathList {
-			if isSyncFromBranchAllowedForThisPath(config.Argocd.AllowSyncfromBranchPathRegex, componentPath) {
-				ghPrClientDetails.PrLogger.Infof("Ensuring ArgoCD app %s is set to HEAD\n", componentPath)
-				err := argocd.SetArgoCDAppRevision(ghPrClientDetails.Ctx, componentPath, "HEAD", ghPrClientDetails.RepoURL, config.Argocd.UseSHALabelForAppDiscovery)
-				if err != nil {
-					ghPrClientDetails.PrLogger.Errorf("Failed to set ArgoCD app @  %s, to HEAD: err=%s\n", componentPath, err)
-				}
-			}
+		if reverted {
+			revertedApps = append(revertedApps, fmt.Sprintf("* `%s` (component `%s`)", appName, componentPath))
 		}
 	}
-
-	return err
+	if len(revertedApps) > 0 {
+		_ = commentPR(ghPrClientDetails, fmt.Sprintf("This PR was closed without merging, the Target Revision of these ArgoCD apps was set back from `%s` to `HEAD`:\n%s", ghPrClientDetails.Ref, strings.Join(revertedApps, "\n")))
+	}
+	return nil
 }
 
 // Creating a unique branch name based on the PR number, PR ref and the promotion target paths
 // Max length of branch name is 250 characters
-func generateSafePromotionBranchName(prNumber int, originalBranchName string, targetPaths []string) string {
+func GenerateSafePromotionBranchName(prNumber int, originalBranchName string, targetPaths []string) string {
 	targetPathsBa := []byte(strings.Join(targetPaths, "_"))
 	hasher := sha1.New() //nolint:gosec // G505: Blocklisted import crypto/sha1: weak cryptographic primitive (gosec), this is not a cryptographic use case
 	hasher.Write(targetPathsBa)
@@ -821,40 +1881,24 @@ func firstN(str string, n int) string {
 }
 
 func MergePr(details GhPrClientDetails, number *int) error {
-	operation := func() error {
-		err := tryMergePR(details, number)
-		if err != nil {
-			if isMergeErrorRetryable(err.Error()) {
-				if err != nil {
-					details.PrLogger.Warnf("Failed to merge PR: transient err=%v", err)
-				}
-				return err
-			}
-			details.PrLogger.Errorf("Failed to merge PR: permanent err=%v", err)
-			return backoff.Permanent(err)
-		}
-		return nil
-	}
+	return mergePrWithMessage(details, number, "Auto-merge")
+}
 
-	// Using default values, see https://pkg.go.dev/github.com/cenkalti/backoff#pkg-constants
-	err := backoff.Retry(operation, backoff.NewExponentialBackOff())
+// mergePrWithMessage merges the PR, commitMessage is the extra detail GitHub appends to the merge commit message
+func mergePrWithMessage(details GhPrClientDetails, number *int, commitMessage string) error {
+	// Merges can wait for longer than other writes, GitHub takes a while to settle a PR that was just updated
+	_, _, err := deduplicateGhWrite(details.Ctx, "merge_pr", strconv.Itoa(*number), func() (*github.PullRequestMergeResult, *github.Response, error) {
+		return retryGhWriteWithBackOff(details.Ctx, "merge_pr", backoff.NewExponentialBackOff(), func() (*github.PullRequestMergeResult, *github.Response, error) {
+			return details.GhClientPair.v3Client.PullRequests.Merge(details.Ctx, details.Owner, details.Repo, *number, commitMessage, nil)
+		})
+	})
 	if err != nil {
-		details.PrLogger.Errorf("Failed to merge PR: backoff err=%v", err)
+		details.PrLogger.Errorf("Failed to merge PR: err=%v", err)
 	}
 
 	return err
 }
 
-func tryMergePR(details GhPrClientDetails, number *int) error {
-	_, resp, err := details.GhClientPair.v3Client.PullRequests.Merge(details.Ctx, details.Owner, details.Repo, *number, "Auto-merge", nil)
-	prom.InstrumentGhCall(resp)
-	return err
-}
-
-func isMergeErrorRetryable(errMessage string) bool {
-	return strings.Contains(errMessage, "405") && strings.Contains(errMessage, "try the merge again")
-}
-
 func (pm *prMetadata) DeSerialize(s string) error {
 	decoded, err := base64.StdEncoding.DecodeString(s)
 	if err != nil {
@@ -865,14 +1909,38 @@ func (pm *prMetadata) DeSerialize(s string) error {
 }
 
 func (p GhPrClientDetails) CommentOnPr(commentBody string) error {
+	_, err := p.createPrComment(commentBody)
+	return err
+}
+
+// createPrComment comments on the PR and returns the new comment, tagged so it's minimized once it's stale
+func (p GhPrClientDetails) createPrComment(commentBody string) (*github.IssueComment, error) {
 	commentBody = "<!-- telefonistka_tag -->\n" + commentBody
 
 	comment := &github.IssueComment{Body: &commentBody}
-	_, resp, err := p.GhClientPair.v3Client.Issues.CreateComment(p.Ctx, p.Owner, p.Repo, p.PrNumber, comment)
-	prom.InstrumentGhCall(resp)
+	writeTarget := fmt.Sprintf("%d/%x", p.PrNumber, sha1.Sum([]byte(commentBody))) //nolint:gosec // G401: Use of weak cryptographic primitive, this is not a cryptographic use case
+	newComment, resp, err := deduplicateGhWrite(p.Ctx, "create_comment", writeTarget, func() (*github.IssueComment, *github.Response, error) {
+		return retryGhWrite(p.Ctx, "create_comment", func() (*github.IssueComment, *github.Response, error) {
+			return p.GhClientPair.v3Client.Issues.CreateComment(p.Ctx, p.Owner, p.Repo, p.PrNumber, comment)
+		})
+	})
 	if err != nil {
 		p.PrLogger.Errorf("Could not comment in PR: err=%s\n%v\n", err, resp)
 	}
+	return newComment, err
+}
+
+// editPrComment replaces the body of a comment created by createPrComment
+func (p GhPrClientDetails) editPrComment(commentID int64, commentBody string) error {
+	commentBody = "<!-- telefonistka_tag -->\n" + commentBody
+
+	comment := &github.IssueComment{Body: &commentBody}
+	_, resp, err := retryGhWrite(p.Ctx, "edit_comment", func() (*github.IssueComment, *github.Response, error) {
+		return p.GhClientPair.v3Client.Issues.EditComment(p.Ctx, p.Owner, p.Repo, commentID, comment)
+	})
+	if err != nil {
+		p.PrLogger.Errorf("Could not edit PR comment %d: err=%s\n%v\n", commentID, err, resp)
+	}
 	return err
 }
 
@@ -889,35 +1957,45 @@ func (p *GhPrClientDetails) ToggleCommitStatus(context string, user string) erro
 	var r error
 	listOpts := &github.ListOptions{}
 
-	initialStatuses, resp, err := p.GhClientPair.v3Client.Repositories.ListStatuses(p.Ctx, p.Owner, p.Repo, p.Ref, listOpts)
-	prom.InstrumentGhCall(resp)
+	// Statuses are listed newest first, only the latest status of the context matters
+	var commitStatus *github.RepoStatus
+	_, err := forEachPage(listOpts, func() ([]*github.RepoStatus, *github.Response, error) {
+		return p.GhClientPair.v3Client.Repositories.ListStatuses(p.Ctx, p.Owner, p.Repo, p.Ref, listOpts)
+	}, func(statuses []*github.RepoStatus) bool {
+		for _, status := range statuses {
+			if status.GetContext() == context {
+				commitStatus = status
+				return false
+			}
+		}
+		return true
+	})
 	if err != nil {
 		p.PrLogger.Errorf("Failed to fetch  existing statuses for commit  %s, err=%s", p.Ref, err)
 		r = err
 	}
 
-	for _, commitStatus := range initialStatuses {
-		if *commitStatus.Context == context {
-			if *commitStatus.State != "success" {
-				p.PrLogger.Infof("%s Toggled  %s(%s) to success", user, context, *commitStatus.State)
-				*commitStatus.State = "success"
-				_, resp, err := p.GhClientPair.v3Client.Repositories.CreateStatus(p.Ctx, p.Owner, p.Repo, p.PrSHA, commitStatus)
-				prom.InstrumentGhCall(resp)
-				if err != nil {
-					p.PrLogger.Errorf("Failed to create context %s, err=%s", context, err)
-					r = err
-				}
-			} else {
-				p.PrLogger.Infof("%s Toggled %s(%s) to failure", user, context, *commitStatus.State)
-				*commitStatus.State = "failure"
-				_, resp, err := p.GhClientPair.v3Client.Repositories.CreateStatus(p.Ctx, p.Owner, p.Repo, p.PrSHA, commitStatus)
-				prom.InstrumentGhCall(resp)
-				if err != nil {
-					p.PrLogger.Errorf("Failed to create context %s, err=%s", context, err)
-					r = err
-				}
+	if commitStatus != nil {
+		if *commitStatus.State != "success" {
+			p.PrLogger.Infof("%s Toggled  %s(%s) to success", user, context, *commitStatus.State)
+			*commitStatus.State = "success"
+			_, _, err := retryGhWrite(p.Ctx, "create_status", func() (*github.RepoStatus, *github.Response, error) {
+				return p.GhClientPair.v3Client.Repositories.CreateStatus(p.Ctx, p.Owner, p.Repo, p.PrSHA, commitStatus)
+			})
+			if err != nil {
+				p.PrLogger.Errorf("Failed to create context %s, err=%s", context, err)
+				r = err
+			}
+		} else {
+			p.PrLogger.Infof("%s Toggled %s(%s) to failure", user, context, *commitStatus.State)
+			*commitStatus.State = "failure"
+			_, _, err := retryGhWrite(p.Ctx, "create_status", func() (*github.RepoStatus, *github.Response, error) {
+				return p.GhClientPair.v3Client.Repositories.CreateStatus(p.Ctx, p.Owner, p.Repo, p.PrSHA, commitStatus)
+			})
+			if err != nil {
+				p.PrLogger.Errorf("Failed to create context %s, err=%s", context, err)
+				r = err
 			}
-			break
 		}
 	}
 
@@ -943,12 +2021,13 @@ func SetCommitStatus(ghPrClientDetails GhPrClientDetails, state string) {
 	ghPrClientDetails.PrLogger.Debugf("Setting commit %s status to %s", ghPrClientDetails.PrSHA, state)
 
 	// use a separate context to avoid event processing timeout to cause
-	// failures in updating the commit status
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	// failures in updating the commit status, it keeps the values(tenant, shadow mode) of the event context
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ghPrClientDetails.Ctx), time.Minute)
 	defer cancel()
 
-	_, resp, err := ghPrClientDetails.GhClientPair.v3Client.Repositories.CreateStatus(ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, ghPrClientDetails.PrSHA, commitStatus)
-	prom.InstrumentGhCall(resp)
+	_, resp, err := retryGhWrite(ctx, "create_status", func() (*github.RepoStatus, *github.Response, error) {
+		return ghPrClientDetails.GhClientPair.v3Client.Repositories.CreateStatus(ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, ghPrClientDetails.PrSHA, commitStatus)
+	})
 	repoSlug := ghPrClientDetails.Owner + "/" + ghPrClientDetails.Repo
 	prom.IncCommitStatusUpdateCounter(repoSlug, state)
 	if err != nil {
@@ -1001,15 +2080,46 @@ func (p *GhPrClientDetails) GetDefaultBranch() (string, error) {
 	}
 }
 
+// generateDeletionTreeEntries creates the tree entries that delete all the files under path, the GH tree API doesn't allow deleting a whole dir.
+// The files are listed with a single recursive tree call, trees too large for one response are walked directory by directory
 func generateDeletionTreeEntries(ghPrClientDetails *GhPrClientDetails, path *string, branch *string, treeEntries *[]*github.TreeEntry) error {
-	// GH tree API doesn't allow deletion a whole dir, so this recursive function traverse the whole tree
-	// and create a tree entry array that would delete all the files in that path
+	entries, err := getDirectoryTree(*ghPrClientDetails, *path, *branch)
+	if errors.Is(err, errTruncatedTree) {
+		ghPrClientDetails.PrLogger.Infof("%s tree is truncated, walking it directory by directory", *path)
+		return walkDeletionTreeEntries(ghPrClientDetails, path, branch, treeEntries)
+	} else if err != nil {
+		ghPrClientDetails.PrLogger.Errorf("Could not fetch %s tree err=%s\n", *path, err)
+		return err
+	}
+	if entries == nil {
+		ghPrClientDetails.PrLogger.Infof("Skipping deletion of non-existing  %s", *path)
+		return nil
+	}
+	for _, entry := range entries {
+		if entry.GetType() != "blob" {
+			continue
+		}
+		*treeEntries = append(*treeEntries, &github.TreeEntry{ // https://docs.github.com/en/rest/git/trees?apiVersion=2022-11-28#create-a-tree
+			Path:    github.String(*path + "/" + entry.GetPath()),
+			Mode:    github.String("100644"),
+			Type:    github.String("blob"),
+			SHA:     nil,
+			Content: nil,
+		})
+	}
+	return nil
+}
+
+func walkDeletionTreeEntries(ghPrClientDetails *GhPrClientDetails, path *string, branch *string, treeEntries *[]*github.TreeEntry) error {
+	// This recursive function traverses the whole tree with one call per directory
+	// and creates a tree entry array that would delete all the files in that path
 	getContentOpts := &github.RepositoryContentGetOptions{
 		Ref: *branch,
 	}
 	_, directoryContent, resp, err := ghPrClientDetails.GhClientPair.v3Client.Repositories.GetContents(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, *path, getContentOpts)
 	prom.InstrumentGhCall(resp)
-	if resp.StatusCode == 404 {
+	err = classifyGhError(resp, err)
+	if errors.Is(err, ErrNotFound) {
 		ghPrClientDetails.PrLogger.Infof("Skipping deletion of non-existing  %s", *path)
 		return nil
 	} else if err != nil {
@@ -1027,7 +2137,7 @@ func generateDeletionTreeEntries(ghPrClientDetails *GhPrClientDetails, path *str
 			}
 			*treeEntries = append(*treeEntries, &treeEntry)
 		} else if *elementInDir.Type == "dir" {
-			err := generateDeletionTreeEntries(ghPrClientDetails, elementInDir.Path, branch, treeEntries)
+			err := walkDeletionTreeEntries(ghPrClientDetails, elementInDir.Path, branch, treeEntries)
 			if err != nil {
 				return err
 			}
@@ -1057,7 +2167,8 @@ func getDirecotyGitObjectSha(ghPrClientDetails GhPrClientDetails, dirPath string
 	// in GH API/go-github, to get directory SHA you need to scan the whole parent Dir 🤷
 	_, directoryContent, resp, err := ghPrClientDetails.GhClientPair.v3Client.Repositories.GetContents(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, path.Dir(dirPath), &repoContentGetOptions)
 	prom.InstrumentGhCall(resp)
-	if err != nil && resp.StatusCode != 404 {
+	err = classifyGhError(resp, err)
+	if err != nil && !errors.Is(err, ErrNotFound) {
 		ghPrClientDetails.PrLogger.Errorf("Could not fetch source directory SHA err=%s\n%v\n", err, resp)
 		return "", err
 	} else if err == nil { // scaning the parent dir
@@ -1072,12 +2183,37 @@ func getDirecotyGitObjectSha(ghPrClientDetails GhPrClientDetails, dirPath string
 	return direcotyGitObjectSha, nil
 }
 
+// errTruncatedTree is returned for trees with more entries than a single GitHub API response can hold
+var errTruncatedTree = errors.New("the tree has too many files for a single GitHub API response")
+
+// getDirectoryTree returns the recursive git tree of dirPath as found in branch(paths relative to dirPath), nil when the path doesn't exist(e.g. deletion promotions)
+func getDirectoryTree(ghPrClientDetails GhPrClientDetails, dirPath string, branch string) ([]*github.TreeEntry, error) {
+	dirPathSHA, err := getDirecotyGitObjectSha(ghPrClientDetails, dirPath, branch)
+	if err != nil || dirPathSHA == "" {
+		return nil, err
+	}
+	tree, resp, err := ghPrClientDetails.GhClientPair.v3Client.Git.GetTree(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, dirPathSHA, true)
+	prom.InstrumentGhCall(resp)
+	if err != nil {
+		return nil, err
+	}
+	if tree.GetTruncated() {
+		return nil, fmt.Errorf("%s: %w", dirPath, errTruncatedTree)
+	}
+	return tree.Entries, nil
+}
+
 func GenerateSyncTreeEntriesForCommit(treeEntries *[]*github.TreeEntry, ghPrClientDetails GhPrClientDetails, sourcePath string, targetPath string, defaultBranch string) error {
-	sourcePathSHA, err := getDirecotyGitObjectSha(ghPrClientDetails, sourcePath, defaultBranch)
+	return generateSyncTreeEntries(treeEntries, ghPrClientDetails, sourcePath, defaultBranch, targetPath, defaultBranch)
+}
+
+// generateSyncTreeEntries syncs sourcePath as found in sourceBranch over targetPath as found in targetBranch
+func generateSyncTreeEntries(treeEntries *[]*github.TreeEntry, ghPrClientDetails GhPrClientDetails, sourcePath string, sourceBranch string, targetPath string, targetBranch string) error {
+	sourcePathSHA, err := getDirecotyGitObjectSha(ghPrClientDetails, sourcePath, sourceBranch)
 
 	if sourcePathSHA == "" {
 		ghPrClientDetails.PrLogger.Infoln("Source directory wasn't found, assuming a deletion PR")
-		err := generateDeletionTreeEntries(&ghPrClientDetails, &targetPath, &defaultBranch, treeEntries)
+		err := generateDeletionTreeEntries(&ghPrClientDetails, &targetPath, &targetBranch, treeEntries)
 		if err != nil {
 			ghPrClientDetails.PrLogger.Errorf("Failed to build deletion tree: err=%s\n", err)
 			return err
@@ -1093,11 +2229,10 @@ func GenerateSyncTreeEntriesForCommit(treeEntries *[]*github.TreeEntry, ghPrClie
 
 		// Aperntly... the way we sync directories(set the target dir git tree object SHA) doesn't delete files!!!! GH just "merges" the old and new tree objects.
 		// So for now, I'll just go over all the files and add explicitly add  delete tree  entries  :(
-		// TODO compare sourcePath targetPath Git object SHA to avoid costly tree compare where possible?
 		sourceFilesSHAs := make(map[string]string)
 		targetFilesSHAs := make(map[string]string)
-		generateFlatMapfromFileTree(&ghPrClientDetails, &sourcePath, &sourcePath, &defaultBranch, sourceFilesSHAs)
-		generateFlatMapfromFileTree(&ghPrClientDetails, &targetPath, &targetPath, &defaultBranch, targetFilesSHAs)
+		generateFlatMapfromFileTree(&ghPrClientDetails, &sourcePath, &sourceBranch, sourceFilesSHAs)
+		generateFlatMapfromFileTree(&ghPrClientDetails, &targetPath, &targetBranch, targetFilesSHAs)
 
 		for filename := range targetFilesSHAs {
 			if _, found := sourceFilesSHAs[filename]; !found {
@@ -1128,8 +2263,9 @@ func createCommit(ghPrClientDetails GhPrClientDetails, treeEntries []*github.Tre
 		return nil, err
 	}
 	baseTreeSHA := ref.Object.SHA
-	tree, resp, err := ghPrClientDetails.GhClientPair.v3Client.Git.CreateTree(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, *baseTreeSHA, treeEntries)
-	prom.InstrumentGhCall(resp)
+	tree, resp, err := retryGhWrite(ghPrClientDetails.Ctx, "create_tree", func() (*github.Tree, *github.Response, error) {
+		return ghPrClientDetails.GhClientPair.v3Client.Git.CreateTree(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, *baseTreeSHA, treeEntries)
+	})
 	if err != nil {
 		ghPrClientDetails.PrLogger.Errorf("Failed to create Git Tree object: err=%s\n%+v", err, resp)
 		ghPrClientDetails.PrLogger.Errorf("These are the treeEntries: %+v", treeEntries)
@@ -1148,8 +2284,9 @@ func createCommit(ghPrClientDetails GhPrClientDetails, treeEntries []*github.Tre
 		Tree:    tree,
 	}
 
-	commit, resp, err := ghPrClientDetails.GhClientPair.v3Client.Git.CreateCommit(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, newCommitConfig, nil)
-	prom.InstrumentGhCall(resp)
+	commit, resp, err := retryGhWrite(ghPrClientDetails.Ctx, "create_commit", func() (*github.Commit, *github.Response, error) {
+		return ghPrClientDetails.GhClientPair.v3Client.Git.CreateCommit(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, newCommitConfig, nil)
+	})
 	if err != nil {
 		ghPrClientDetails.PrLogger.Errorf("Failed to create Git commit: err=%s\n", err) // TODO comment this error to PR
 		return nil, err
@@ -1171,8 +2308,11 @@ func createBranch(ghPrClientDetails GhPrClientDetails, commit *github.Commit, ne
 		Object: newRefGitObjct,
 	}
 
-	_, resp, err := ghPrClientDetails.GhClientPair.v3Client.Git.CreateRef(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, newRefConfig)
-	prom.InstrumentGhCall(resp)
+	_, resp, err := deduplicateGhWrite(ghPrClientDetails.Ctx, "create_ref", newBranchRef, func() (*github.Reference, *github.Response, error) {
+		return retryGhWrite(ghPrClientDetails.Ctx, "create_ref", func() (*github.Reference, *github.Response, error) {
+			return ghPrClientDetails.GhClientPair.v3Client.Git.CreateRef(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, newRefConfig)
+		})
+	})
 	if err != nil {
 		ghPrClientDetails.PrLogger.Errorf("Could not create Git Ref: err=%s\n%v\n", err, resp)
 		return "", err
@@ -1181,12 +2321,14 @@ func createBranch(ghPrClientDetails GhPrClientDetails, commit *github.Commit, ne
 	return newBranchRef, err
 }
 
-func generatePromotionPrBody(ghPrClientDetails GhPrClientDetails, components string, promotion PromotionInstance, originalPrAuthor string) string {
+// generatePromotionPrBody returns the body of the promotion PR of headBranch, opened for the PR of ghPrClientDetails
+func generatePromotionPrBody(ghPrClientDetails GhPrClientDetails, components string, promotion PromotionInstance, originalPrAuthor string, headBranch string) string {
 	// newPrMetadata will be serialized and persisted in the PR body for use when the PR is merged
 	var newPrMetadata prMetadata
 	var newPrBody string
 
 	newPrMetadata.OriginalPrAuthor = originalPrAuthor
+	newPrMetadata.IssueKeys = ghPrClientDetails.PrMetadata.IssueKeys
 
 	if ghPrClientDetails.PrMetadata.PreviousPromotionMetadata != nil {
 		newPrMetadata.PreviousPromotionMetadata = ghPrClientDetails.PrMetadata.PreviousPromotionMetadata
@@ -1213,15 +2355,62 @@ func generatePromotionPrBody(ghPrClientDetails GhPrClientDetails, components str
 	}
 	sort.Ints(keys)
 
-	newPrBody = prBody(keys, newPrMetadata, newPrBody, promotionSkipPaths)
+	newPrBody = prBody(keys, newPrMetadata, newPrBody, promotionSkipPaths, promotion.Metadata.PathNames)
+	if len(newPrMetadata.IssueKeys) > 0 {
+		newPrBody = newPrBody + "\nRelated issues: " + issueLinks(ticketing.FromEnv(ghPrClientDetails.Ctx), newPrMetadata.IssueKeys) + "\n"
+	}
+	newPrBody = newPrBody + "\n" + promotionChainMermaidGraph(keys, newPrMetadata, promotionSkipPaths)
+
+	newPrMetadata.ComponentOrder = promotion.Metadata.ComponentOrder
+	if newPrMetadata.ComponentOrder != nil {
+		newPrBody = newPrBody + componentOrderPrBody(newPrMetadata.ComponentOrder)
+	}
+	newPrMetadata.PromotionSteps = promotion.Metadata.Steps
+	if newPrMetadata.PromotionSteps != nil {
+		newPrBody = newPrBody + promotionStepsPrBody(newPrMetadata.PromotionSteps)
+	}
+
+	newPrMetadata.HotfixSkippedPaths = generateHotfixSkippedPaths(promotion)
+	if len(newPrMetadata.HotfixSkippedPaths) > 0 {
+		skippedPaths := maps.Keys(newPrMetadata.HotfixSkippedPaths)
+		sort.Strings(skippedPaths)
+		newPrBody = newPrBody + "\n🚑 This is a hotfix promotion, these intermediate paths were skipped and should be back-filled:\n* `" + strings.Join(skippedPaths, "`\n* `") + "`\n"
+	}
 
 	prMetadataString, _ := newPrMetadata.serialize()
 
-	newPrBody = newPrBody + "\n<!--|Telefonistka data, do not delete|" + prMetadataString + "|-->"
+	binding := prMetadataBinding{repoSlug: ghPrClientDetails.Owner + "/" + ghPrClientDetails.Repo, headBranch: headBranch}
+	newPrBody = newPrBody + "\n" + prMetadataComment(binding, prMetadataString, prMetadataSigningKey())
 
 	return newPrBody
 }
 
+// GeneratePromotionPrBodyForPr is used by non GitHub providers, it parses the Telefonistka metadata from the triggering PR(of sourceBranch in owner/repo) body
+// and returns the body of the new promotion PR(of headBranch) and the author that should be credited for it.
+func GeneratePromotionPrBodyForPr(logger *log.Entry, owner string, repo string, prNumber int, sourceBranch string, prBody string, prAuthor string, components string, promotion PromotionInstance, headBranch string) (newPrBody string, originalPrAuthor string) {
+	details := GhPrClientDetails{Owner: owner, Repo: repo, PrNumber: prNumber, Ref: sourceBranch, PrAuthor: prAuthor, PrLogger: logger}
+	_ = details.getPrMetadata(prBody)
+	originalPrAuthor = details.PrMetadata.OriginalPrAuthor
+	if originalPrAuthor == "" {
+		originalPrAuthor = prAuthor
+	}
+	return generatePromotionPrBody(details, components, promotion, originalPrAuthor, headBranch), originalPrAuthor
+}
+
+// generateHotfixSkippedPaths maps the component paths a hotfix promotion skipped to the first(sorted) hotfix target path of the same component
+func generateHotfixSkippedPaths(promotion PromotionInstance) map[string]string {
+	if len(promotion.Metadata.HotfixSkippedTargetPaths) == 0 || len(promotion.Metadata.TargetPaths) == 0 {
+		return nil
+	}
+	hotfixSkippedPaths := map[string]string{}
+	for _, componentName := range promotion.Metadata.ComponentNames {
+		for _, skippedTargetPath := range promotion.Metadata.HotfixSkippedTargetPaths {
+			hotfixSkippedPaths[skippedTargetPath+componentName] = promotion.Metadata.TargetPaths[0] + componentName
+		}
+	}
+	return hotfixSkippedPaths
+}
+
 // getPromotionSkipPaths returns a map of paths that are marked as skipped for this promotion
 // when we have multiple components, we are going to use the component that has the fewest skip paths
 func getPromotionSkipPaths(promotion PromotionInstance) map[string]bool {
@@ -1265,16 +2454,26 @@ func getPromotionSkipPaths(promotion PromotionInstance) map[string]bool {
 	return promotionSkipPaths
 }
 
-func prBody(keys []int, newPrMetadata prMetadata, newPrBody string, promotionSkipPaths map[string]bool) string {
+// prBody renders the promotion chain, paths of configured environments are rendered as their names(pathNames)
+func prBody(keys []int, newPrMetadata prMetadata, newPrBody string, promotionSkipPaths map[string]bool, pathNames map[string]string) string {
 	const mkTab = "&nbsp;&nbsp;&nbsp;&nbsp;"
 	sp := ""
 	tp := ""
+	displayName := func(p string) string {
+		if name, ok := pathNames[p]; ok {
+			return name
+		}
+		return p
+	}
 
 	for i, k := range keys {
-		sp = newPrMetadata.PreviousPromotionMetadata[k].SourcePath
+		sp = displayName(newPrMetadata.PreviousPromotionMetadata[k].SourcePath)
 		x := filterSkipPaths(newPrMetadata.PreviousPromotionMetadata[k].TargetPaths, promotionSkipPaths)
 		// sort the paths so that we have a predictable order for tests and better readability for users
 		sort.Strings(x)
+		for j := range x {
+			x[j] = displayName(x[j])
+		}
 		tp = strings.Join(x, fmt.Sprintf("`  \n%s`", strings.Repeat(mkTab, i+1)))
 		newPrBody = newPrBody + fmt.Sprintf("%s↘️  #%d  `%s` ➡️  \n%s`%s`  \n", strings.Repeat(mkTab, i), k, sp, strings.Repeat(mkTab, i+1), tp)
 	}
@@ -1304,7 +2503,7 @@ func filterSkipPaths(targetPaths []string, promotionSkipPaths map[string]bool) [
 	return paths
 }
 
-func createPrObject(ghPrClientDetails GhPrClientDetails, newBranchRef string, newPrTitle string, newPrBody string, defaultBranch string, assignee string) (*github.PullRequest, error) {
+func createPrObject(ghPrClientDetails GhPrClientDetails, newBranchRef string, newPrTitle string, newPrBody string, defaultBranch string, routing prRouting, labels []string) (*github.PullRequest, error) {
 	newPrConfig := &github.NewPullRequest{
 		Body:  github.String(newPrBody),
 		Title: github.String(newPrTitle),
@@ -1312,8 +2511,11 @@ func createPrObject(ghPrClientDetails GhPrClientDetails, newBranchRef string, ne
 		Head:  github.String(newBranchRef),
 	}
 
-	pull, resp, err := ghPrClientDetails.GhClientPair.v3Client.PullRequests.Create(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, newPrConfig)
-	prom.InstrumentGhCall(resp)
+	pull, resp, err := deduplicateGhWrite(ghPrClientDetails.Ctx, "create_pr", newBranchRef, func() (*github.PullRequest, *github.Response, error) {
+		return retryGhWrite(ghPrClientDetails.Ctx, "create_pr", func() (*github.PullRequest, *github.Response, error) {
+			return ghPrClientDetails.GhClientPair.v3Client.PullRequests.Create(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, newPrConfig)
+		})
+	})
 	if err != nil {
 		ghPrClientDetails.PrLogger.Errorf("Could not create GitHub PR: err=%s\n%v\n", err, resp)
 		return nil, err
@@ -1321,34 +2523,59 @@ func createPrObject(ghPrClientDetails GhPrClientDetails, newBranchRef string, ne
 		ghPrClientDetails.PrLogger.Infof("PR %d opened", *pull.Number)
 	}
 
-	prLables, resp, err := ghPrClientDetails.GhClientPair.v3Client.Issues.AddLabelsToIssue(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, *pull.Number, []string{"promotion"})
-	prom.InstrumentGhCall(resp)
+	err = labelPr(ghPrClientDetails, *pull.Number, labels)
 	if err != nil {
-		ghPrClientDetails.PrLogger.Errorf("Could not label GitHub PR: err=%s\n%v\n", err, resp)
 		return pull, err
-	} else {
-		ghPrClientDetails.PrLogger.Debugf("PR %v labeled\n%+v", pull.Number, prLables)
 	}
 
-	_, resp, err = ghPrClientDetails.GhClientPair.v3Client.Issues.AddAssignees(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, *pull.Number, []string{assignee})
-	prom.InstrumentGhCall(resp)
+	_, resp, err = retryGhWrite(ghPrClientDetails.Ctx, "add_assignees", func() (*github.Issue, *github.Response, error) {
+		return ghPrClientDetails.GhClientPair.v3Client.Issues.AddAssignees(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, *pull.Number, routing.Assignees)
+	})
 	if err != nil {
-		ghPrClientDetails.PrLogger.Warnf("Could not set %s as assignee on PR,  err=%s", assignee, err)
+		ghPrClientDetails.PrLogger.Warnf("Could not set %v as assignees on PR,  err=%s", routing.Assignees, err)
 		// return pull, err
 	} else {
-		ghPrClientDetails.PrLogger.Debugf(" %s was set as assignee on PR", assignee)
+		ghPrClientDetails.PrLogger.Debugf(" %v were set as assignees on PR", routing.Assignees)
+	}
+
+	err = requestRequiredReviews(ghPrClientDetails, *pull.Number, routing.Reviewers)
+	if err != nil {
+		ghPrClientDetails.PrLogger.Warnf("Could not request the routed reviews on PR, err=%s", err)
 	}
 
 	return pull, nil // TODO
 }
 
+func labelPr(ghPrClientDetails GhPrClientDetails, prNumber int, labels []string) error {
+	prLables, resp, err := retryGhWrite(ghPrClientDetails.Ctx, "add_labels", func() ([]*github.Label, *github.Response, error) {
+		return ghPrClientDetails.GhClientPair.v3Client.Issues.AddLabelsToIssue(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, prNumber, labels)
+	})
+	if err != nil {
+		ghPrClientDetails.PrLogger.Errorf("Could not label GitHub PR: err=%s\n%v\n", err, resp)
+		return err
+	}
+	ghPrClientDetails.PrLogger.Debugf("PR %v labeled\n%+v", prNumber, prLables)
+	return nil
+}
+
 func ApprovePr(approverClient *github.Client, ghPrClientDetails GhPrClientDetails, prNumber *int) error {
+	return approvePrWithBody(approverClient, ghPrClientDetails, prNumber, "")
+}
+
+// approvePrWithBody approves the PR with a review comment, e.g. recording who asked for the approval
+func approvePrWithBody(approverClient *github.Client, ghPrClientDetails GhPrClientDetails, prNumber *int, body string) error {
 	reviewRequest := &github.PullRequestReviewRequest{
 		Event: github.String("APPROVE"),
 	}
+	if body != "" {
+		reviewRequest.Body = github.String(body)
+	}
 
-	_, resp, err := approverClient.PullRequests.CreateReview(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, *prNumber, reviewRequest)
-	prom.InstrumentGhCall(resp)
+	_, resp, err := deduplicateGhWrite(ghPrClientDetails.Ctx, "create_review", strconv.Itoa(*prNumber), func() (*github.PullRequestReview, *github.Response, error) {
+		return retryGhWrite(ghPrClientDetails.Ctx, "create_review", func() (*github.PullRequestReview, *github.Response, error) {
+			return approverClient.PullRequests.CreateReview(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, *prNumber, reviewRequest)
+		})
+	})
 	if err != nil {
 		ghPrClientDetails.PrLogger.Errorf("Could not create review: err=%s\n%v\n", err, resp)
 		return err
@@ -1357,22 +2584,48 @@ func ApprovePr(approverClient *github.Client, ghPrClientDetails GhPrClientDetail
 	return nil
 }
 
+// ErrInRepoConfigUnreadable is returned by GetInRepoConfig in fail-closed mode(IN_REPO_CONFIG_FAIL_CLOSED) when telefonistka.yaml can't be read or parsed
+var ErrInRepoConfigUnreadable = errors.New("the in-repo configuration(telefonistka.yaml) can't be read")
+
+// inRepoConfigFailClosed is true when events should fail instead of being handled with an empty configuration, which could silently disable diffs, gates and approvals
+func inRepoConfigFailClosed(ctx context.Context) bool {
+	failClosed, _ := strconv.ParseBool(tenancy.Getenv(ctx, "IN_REPO_CONFIG_FAIL_CLOSED", "false"))
+	return failClosed
+}
+
+// GetInRepoConfig returns the configuration of the repo, an unreadable telefonistka.yaml is treated as an empty one unless fail-closed mode is enabled.
+// A missing telefonistka.yaml is always an empty configuration, the repo isn't configured
 func GetInRepoConfig(ghPrClientDetails GhPrClientDetails, defaultBranch string) (*cfg.Config, error) {
+	failClosed := inRepoConfigFailClosed(ghPrClientDetails.Ctx)
+	var readErr error
 	inRepoConfigFileContentString, _, err := GetFileContent(ghPrClientDetails, defaultBranch, "telefonistka.yaml")
 	if err != nil {
 		ghPrClientDetails.PrLogger.Errorf("Could not get in-repo configuration: err=%s\n", err)
+		if failClosed && !errors.Is(err, ErrNotFound) {
+			readErr = fmt.Errorf("%w: %w", ErrInRepoConfigUnreadable, err)
+		}
 		inRepoConfigFileContentString = ""
 	}
 	c, err := cfg.ParseConfigFromYaml(inRepoConfigFileContentString)
 	if err != nil {
 		ghPrClientDetails.PrLogger.Errorf("Failed to parse configuration: err=%s\n", err)
+		if failClosed {
+			err = fmt.Errorf("%w: %w", ErrInRepoConfigUnreadable, err)
+		}
+		return c, err
+	}
+	if readErr != nil {
+		// Callers that ignore the error still get the empty configuration
+		return c, readErr
 	}
+	markShadowMode(ghPrClientDetails.Ctx, ghPrClientDetails.Owner+"/"+ghPrClientDetails.Repo, c.ShadowMode)
 	return c, err
 }
 
 func GetFileContent(ghPrClientDetails GhPrClientDetails, branch string, filePath string) (string, int, error) {
 	rGetContentOps := github.RepositoryContentGetOptions{Ref: branch}
 	fileContent, _, resp, err := ghPrClientDetails.GhClientPair.v3Client.Repositories.GetContents(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, filePath, &rGetContentOps)
+	err = classifyGhError(resp, err)
 	if err != nil {
 		ghPrClientDetails.PrLogger.Errorf("Fail to get file:%s\n%v\n", err, resp)
 		if resp == nil {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"strings"
 
+	"github.com/google/go-github/v62/github"
 	"github.com/shurcooL/githubv4"
 	log "github.com/sirupsen/logrus"
 )
@@ -26,22 +27,96 @@ func GetBotGhIdentity(githubGraphQlClient *githubv4.Client, ctx context.Context)
 	return string(botIdentity), nil
 }
 
+// prComment is a PR comment as returned by the GraphQL API
+type prComment struct {
+	Id          githubv4.ID
+	IsMinimized githubv4.Boolean
+	Body        githubv4.String
+	Author      struct {
+		Login githubv4.String
+	}
+}
+
+// prEnrichment is what the event handling needs about a PR beyond the webhook payload, see enrichPrDetails
+type prEnrichment struct {
+	botIdentity string
+	// The last 100 comments of the PR
+	comments []prComment
+}
+
+// enrichPrDetails fetches the PR head SHA and ref, labels, body, the repo default branch, the bot identity and the PR comments in a single GraphQL query
+// at the start of the event handling, instead of the several REST calls that would fetch them lazily. Fields the webhook payload already set are kept
+func enrichPrDetails(ghPrClientDetails *GhPrClientDetails) error {
+	var prEnrichmentQuery struct {
+		Viewer struct {
+			Login githubv4.String
+		}
+		Repository struct {
+			DefaultBranchRef struct {
+				Name githubv4.String
+			}
+			PullRequest struct {
+				HeadRefName githubv4.String
+				HeadRefOid  githubv4.GitObjectID
+				Body        githubv4.String
+				Labels      struct {
+					Nodes []struct {
+						Name githubv4.String
+					}
+				} `graphql:"labels(first: 100)"`
+				Comments struct {
+					Nodes []prComment
+				} `graphql:"comments(last: 100)"`
+			} `graphql:"pullRequest(number: $prNumber)"`
+		} `graphql:"repository(owner: $owner, name: $repo)"`
+	}
+	params := map[string]interface{}{
+		"owner":    githubv4.String(ghPrClientDetails.Owner),
+		"repo":     githubv4.String(ghPrClientDetails.Repo),
+		"prNumber": githubv4.Int(ghPrClientDetails.PrNumber), //nolint:gosec // G115: type mismatch between shurcooL/githubv4 and google/go-github. Number taken from latter for use in query using former.
+	}
+	err := ghPrClientDetails.GhClientPair.v4Client.Query(ghPrClientDetails.Ctx, &prEnrichmentQuery, params)
+	if err != nil {
+		return err
+	}
+	pr := prEnrichmentQuery.Repository.PullRequest
+	if ghPrClientDetails.DefaultBranch == "" {
+		ghPrClientDetails.DefaultBranch = string(prEnrichmentQuery.Repository.DefaultBranchRef.Name)
+	}
+	if ghPrClientDetails.Ref == "" {
+		ghPrClientDetails.Ref = string(pr.HeadRefName)
+	}
+	if ghPrClientDetails.PrSHA == "" {
+		ghPrClientDetails.PrSHA = string(pr.HeadRefOid)
+	}
+	if ghPrClientDetails.PrBody == "" {
+		ghPrClientDetails.PrBody = string(pr.Body)
+	}
+	if ghPrClientDetails.Labels == nil {
+		ghPrClientDetails.Labels = []*github.Label{}
+		for _, label := range pr.Labels.Nodes {
+			ghPrClientDetails.Labels = append(ghPrClientDetails.Labels, &github.Label{Name: github.String(string(label.Name))})
+		}
+	}
+	ghPrClientDetails.enrichment = &prEnrichment{botIdentity: string(prEnrichmentQuery.Viewer.Login), comments: pr.Comments.Nodes}
+	return nil
+}
+
+// botIdentity returns the bot login fetched by enrichPrDetails, or queries it when the PR details weren't enriched
+func (p *GhPrClientDetails) botIdentity() (string, error) {
+	if p.enrichment != nil {
+		return p.enrichment.botIdentity, nil
+	}
+	return GetBotGhIdentity(p.GhClientPair.v4Client, p.Ctx)
+}
+
 func MimizeStalePrComments(ghPrClientDetails GhPrClientDetails, githubGraphQlClient *githubv4.Client, botIdentity string) error {
 	var getCommentNodeIdsQuery struct {
 		Repository struct {
 			PullRequest struct {
 				Title    githubv4.String
 				Comments struct {
-					Edges []struct {
-						Node struct {
-							Id          githubv4.ID
-							IsMinimized githubv4.Boolean
-							Body        githubv4.String
-							Author      struct {
-								Login githubv4.String
-							}
-						}
-					}
+					Nodes []prComment
 				} `graphql:"comments(last: 100)"`
 			} `graphql:"pullRequest(number: $prNumber )"`
 		} `graphql:"repository(owner: $owner, name: $repo)"`
@@ -64,24 +139,32 @@ func MimizeStalePrComments(ghPrClientDetails GhPrClientDetails, githubGraphQlCli
 		} `graphql:"minimizeComment(input: $input)"`
 	}
 
-	err := githubGraphQlClient.Query(ghPrClientDetails.Ctx, &getCommentNodeIdsQuery, getCommentNodeIdsParams)
-	if err != nil {
-		ghPrClientDetails.PrLogger.Errorf("Failed to minimize stale comments: err=%s\n", err)
+	var err error
+	var comments []prComment
+	// The comments were fetched with the rest of the PR details
+	if ghPrClientDetails.enrichment != nil {
+		comments = ghPrClientDetails.enrichment.comments
+	} else {
+		err = githubGraphQlClient.Query(ghPrClientDetails.Ctx, &getCommentNodeIdsQuery, getCommentNodeIdsParams)
+		if err != nil {
+			ghPrClientDetails.PrLogger.Errorf("Failed to minimize stale comments: err=%s\n", err)
+		}
+		comments = getCommentNodeIdsQuery.Repository.PullRequest.Comments.Nodes
 	}
 	bi := githubv4.String(strings.TrimSuffix(botIdentity, "[bot]"))
-	for _, prComment := range getCommentNodeIdsQuery.Repository.PullRequest.Comments.Edges {
-		if !prComment.Node.IsMinimized && prComment.Node.Author.Login == bi {
-			if strings.Contains(string(prComment.Node.Body), "<!-- telefonistka_tag -->") {
-				ghPrClientDetails.PrLogger.Infof("Minimizing Comment %s", prComment.Node.Id)
+	for _, prComment := range comments {
+		if !prComment.IsMinimized && prComment.Author.Login == bi {
+			if strings.Contains(string(prComment.Body), "<!-- telefonistka_tag -->") {
+				ghPrClientDetails.PrLogger.Infof("Minimizing Comment %s", prComment.Id)
 				minimizeCommentInput := githubv4.MinimizeCommentInput{
-					SubjectID:        prComment.Node.Id,
+					SubjectID:        prComment.Id,
 					Classifier:       githubv4.ReportedContentClassifiers("OUTDATED"),
 					ClientMutationID: &bi,
 				}
 				err := githubGraphQlClient.Mutate(ghPrClientDetails.Ctx, &minimizeCommentMutation, minimizeCommentInput, nil)
 				// As far as I can tell minimizeComment Github's grpahQL method doesn't accept list do doing one call per comment
 				if err != nil {
-					ghPrClientDetails.PrLogger.Errorf("Failed to minimize comment ID %s\n err=%s", prComment.Node.Id, err)
+					ghPrClientDetails.PrLogger.Errorf("Failed to minimize comment ID %s\n err=%s", prComment.Id, err)
 					// Handle error.
 				}
 			} else {
@@ -0,0 +1,94 @@
+package githubapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/shurcooL/githubv4"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+const prEnrichmentResponse = `{"data": {
+	"viewer": {"login": "telefonistka[bot]"},
+	"repository": {
+		"defaultBranchRef": {"name": "main"},
+		"pullRequest": {
+			"headRefName": "feature",
+			"headRefOid": "abcdef",
+			"body": "PR body",
+			"labels": {"nodes": [{"name": "promotion"}]},
+			"comments": {"nodes": [{"id": "C1", "isMinimized": false, "body": "<!-- telefonistka_tag --> diff", "author": {"login": "telefonistka"}}]}
+		}
+	}
+}}`
+
+func TestEnrichPrDetails(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Variables map[string]interface{} `json:"variables"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		assert.Equal(t, map[string]interface{}{"owner": "AnOwner", "repo": "Arepo", "prNumber": float64(120)}, request.Variables)
+		_, _ = w.Write([]byte(prEnrichmentResponse))
+	}))
+	t.Cleanup(server.Close)
+
+	tests := map[string]struct {
+		details        GhPrClientDetails
+		expectedRef    string
+		expectedSHA    string
+		expectedLabels []string
+	}{
+		"comment event": {
+			details:        GhPrClientDetails{},
+			expectedRef:    "feature",
+			expectedSHA:    "abcdef",
+			expectedLabels: []string{"promotion"},
+		},
+		"payload fields are kept": {
+			details:        GhPrClientDetails{Ref: "payload-ref", PrSHA: "payload-sha", Labels: []*github.Label{{Name: github.String("show-plan")}}},
+			expectedRef:    "payload-ref",
+			expectedSHA:    "payload-sha",
+			expectedLabels: []string{"show-plan"},
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			details := tc.details
+			details.Ctx = context.Background()
+			details.GhClientPair = &GhClientPair{v4Client: githubv4.NewEnterpriseClient(server.URL, server.Client())}
+			details.Owner = "AnOwner"
+			details.Repo = "Arepo"
+			details.PrNumber = 120
+			details.PrLogger = log.WithFields(log.Fields{"repo": "AnOwner/Arepo"})
+
+			err := enrichPrDetails(&details)
+			assert.NoError(t, err)
+			assert.Equal(t, "main", details.DefaultBranch)
+			assert.Equal(t, tc.expectedRef, details.Ref)
+			assert.Equal(t, tc.expectedSHA, details.PrSHA)
+			assert.Equal(t, "PR body", details.PrBody)
+			labels := []string{}
+			for _, label := range details.Labels {
+				labels = append(labels, label.GetName())
+			}
+			assert.Equal(t, tc.expectedLabels, labels)
+			botIdentity, err := details.botIdentity()
+			assert.NoError(t, err)
+			assert.Equal(t, "telefonistka[bot]", botIdentity)
+			assert.Len(t, details.enrichment.comments, 1)
+
+			// Already fetched, no REST calls
+			defaultBranch, _ := details.GetDefaultBranch()
+			assert.Equal(t, "main", defaultBranch)
+		})
+	}
+}
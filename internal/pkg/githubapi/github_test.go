@@ -2,14 +2,24 @@ package githubapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/google/go-github/v62/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/wayfair-incubator/telefonistka/internal/pkg/argocd"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/tenancy"
 )
 
 func TestGenerateSafePromotionBranchName(t *testing.T) {
@@ -17,7 +27,7 @@ func TestGenerateSafePromotionBranchName(t *testing.T) {
 	prNumber := 11
 	originBranch := "originBranch"
 	targetPaths := []string{"targetPath1", "targetPath2"}
-	result := generateSafePromotionBranchName(prNumber, originBranch, targetPaths)
+	result := GenerateSafePromotionBranchName(prNumber, originBranch, targetPaths)
 	expectedResult := "promotions/11-originBranch-676f02019f18"
 	if result != expectedResult {
 		t.Errorf("Expected %s, got %s", expectedResult, result)
@@ -31,7 +41,7 @@ func TestGenerateSafePromotionBranchNameLongBranchName(t *testing.T) {
 
 	originBranch := string(bytes.Repeat([]byte("originBranch"), 100))
 	targetPaths := []string{"targetPath1", "targetPath2"}
-	result := generateSafePromotionBranchName(prNumber, originBranch, targetPaths)
+	result := GenerateSafePromotionBranchName(prNumber, originBranch, targetPaths)
 	if len(result) > 250 {
 		t.Errorf("Expected branch name to be less than 250 characters, got %d", len(result))
 	}
@@ -64,7 +74,7 @@ func TestGenerateSafePromotionBranchNameLongTargets(t *testing.T) {
 		"loooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooong/target/path/19",
 		"loooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooong/target/path/20",
 	}
-	result := generateSafePromotionBranchName(prNumber, originBranch, targetPaths)
+	result := GenerateSafePromotionBranchName(prNumber, originBranch, targetPaths)
 	if len(result) > 250 {
 		t.Errorf("Expected branch name to be less than 250 characters, got %d", len(result))
 	}
@@ -195,7 +205,7 @@ func TestGenerateArgoCdDiffComments(t *testing.T) {
 			var diffCommentData DiffCommentData
 			readJSONFromFile(t, tc.diffCommentDataTestDataFileName, &diffCommentData)
 
-			result, err := generateArgoCdDiffComments(diffCommentData, tc.maxCommentLength)
+			result, err := generateArgoCdDiffComments(diffCommentData, tc.maxCommentLength, nil)
 			if err != nil {
 				t.Fatalf("Error generating ArgoCD diff comments: %s", err)
 			}
@@ -214,6 +224,136 @@ func TestGenerateArgoCdDiffComments(t *testing.T) {
 	}
 }
 
+func TestGenerateArgoCdDiffCommentsUploadsOversizedDiffs(t *testing.T) {
+	t.Parallel()
+	var diffCommentData DiffCommentData
+	readJSONFromFile(t, "./testdata/diff_comment_data_test.json", &diffCommentData)
+
+	uploadedComponents := []string{}
+	uploader := func(componentPath string, fullDiffComment string) (string, error) {
+		uploadedComponents = append(uploadedComponents, componentPath)
+		if !strings.Contains(fullDiffComment, "```diff") {
+			t.Errorf("Expected the full diff to be uploaded for %s", componentPath)
+		}
+		return "https://gist.github.com/telefonistka/" + componentPath, nil
+	}
+
+	result, err := generateArgoCdDiffComments(diffCommentData, 1000, uploader)
+	if err != nil {
+		t.Fatalf("Error generating ArgoCD diff comments: %s", err)
+	}
+	assert.Equal(t, len(diffCommentData.DiffOfChangedComponents), len(uploadedComponents))
+	for i, comment := range result {
+		assert.Contains(t, comment, "https://gist.github.com/telefonistka/"+diffCommentData.DiffOfChangedComponents[i].ComponentPath)
+	}
+}
+
+func TestImageChangeRows(t *testing.T) {
+	t.Parallel()
+	diffResults := []argocd.DiffResult{
+		{
+			ArgoCdAppName: "foo-prod",
+			DiffElements: []argocd.DiffElement{
+				{
+					ObjectKind: "Deployment",
+					ObjectName: "foo",
+					ImageChanges: []argocd.ImageChange{
+						{Container: "app", OldImage: "registry/foo:1.0", NewImage: "registry/foo:1.1"},
+						{Container: "sidecar", NewImage: "envoy:1.2"},
+					},
+				},
+				{ObjectKind: "ConfigMap", ObjectName: "foo-config"},
+			},
+		},
+	}
+	expected := [][]string{
+		{"foo-prod", "Deployment/foo/app", "`registry/foo`", "`1.0` → `1.1`"},
+		{"foo-prod", "Deployment/foo/sidecar", "`envoy`", "(added) → `1.2`"},
+	}
+	assert.Equal(t, expected, imageChangeRows(diffResults))
+}
+
+func TestChangeSummaryAndRiskyChanges(t *testing.T) {
+	t.Parallel()
+	diffElements := []argocd.DiffElement{
+		{ObjectNamespace: "foo", ObjectKind: "Deployment", ObjectName: "foo", ChangeType: argocd.ObjectChanged},
+		{ObjectNamespace: "foo", ObjectKind: "ConfigMap", ObjectName: "foo-a", ChangeType: argocd.ObjectChanged},
+		{ObjectNamespace: "foo", ObjectKind: "ConfigMap", ObjectName: "foo-b", ChangeType: argocd.ObjectAdded},
+		{ObjectNamespace: "foo", ObjectKind: "Secret", ObjectName: "foo", ChangeType: argocd.ObjectDeleted},
+		{ObjectNamespace: "foo", ObjectKind: "RoleBinding", ObjectName: "foo", ChangeType: argocd.ObjectChanged},
+	}
+	assert.Equal(t, "1 added (1 ConfigMap), 3 changed (1 ConfigMap, 1 Deployment, 1 RoleBinding), 1 deleted (1 Secret)", changeSummary(diffElements))
+	assert.Equal(t, []string{"`foo/Secret/foo` (deletion)", "`foo/RoleBinding/foo` (RBAC change)"}, riskyChanges(diffElements))
+}
+
+func TestParseSyncCommand(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		commentBody string
+		expected    []string
+	}{
+		"Single command": {
+			commentBody: "/telefonistka sync env/staging/foo",
+			expected:    []string{"env/staging/foo"},
+		},
+		"Multiple commands with code formatting and trailing slash": {
+			commentBody: "Let's test these:\r\n/telefonistka sync `env/staging/foo/`\n/telefonistka sync env/staging/bar\n",
+			expected:    []string{"env/staging/foo", "env/staging/bar"},
+		},
+		"No command": {
+			commentBody: "LGTM, please /telefonistka sync env/staging/foo",
+			expected:    []string{},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, parseSyncCommand(tc.commentBody))
+		})
+	}
+}
+
+func TestEventToHandle(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		action        string
+		merged        bool
+		draft         bool
+		labels        []string
+		eventLabel    string
+		expectedEvent string
+		expectedOk    bool
+	}{
+		"Merged":                         {action: "closed", merged: true, expectedEvent: "merged", expectedOk: true},
+		"Closed without merge":           {action: "closed", merged: false, expectedEvent: "closed", expectedOk: true},
+		"Opened":                         {action: "opened", expectedEvent: "changed", expectedOk: true},
+		"Assigned":                       {action: "assigned", expectedEvent: "", expectedOk: false},
+		"Draft push is skipped":          {action: "synchronize", draft: true, expectedEvent: "", expectedOk: false},
+		"Draft push with opt-in label":   {action: "synchronize", draft: true, labels: []string{"diff-draft"}, expectedEvent: "changed", expectedOk: true},
+		"Ready for review":               {action: "ready_for_review", expectedEvent: "changed", expectedOk: true},
+		"Opt-in label added to draft PR": {action: "labeled", draft: true, labels: []string{"diff-draft"}, eventLabel: "diff-draft", expectedEvent: "changed", expectedOk: true},
+		"Preview config label added":     {action: "labeled", labels: []string{"preview-config"}, eventLabel: "preview-config", expectedEvent: "changed", expectedOk: true},
+		"Preview config label removed":   {action: "unlabeled", eventLabel: "preview-config", expectedEvent: "changed", expectedOk: true},
+		"Preview config label on draft":  {action: "labeled", draft: true, labels: []string{"preview-config"}, eventLabel: "preview-config", expectedEvent: "", expectedOk: false},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			labels := []*github.Label{}
+			for _, l := range tc.labels {
+				labels = append(labels, &github.Label{Name: github.String(l)})
+			}
+			event, ok := eventToHandle(&github.PullRequestEvent{
+				Action:      github.String(tc.action),
+				Label:       &github.Label{Name: github.String(tc.eventLabel)},
+				PullRequest: &github.PullRequest{Merged: github.Bool(tc.merged), Draft: github.Bool(tc.draft), Labels: labels},
+			})
+			assert.Equal(t, tc.expectedEvent, event)
+			assert.Equal(t, tc.expectedOk, ok)
+		})
+	}
+}
+
 func TestMarkdownGenerator(t *testing.T) {
 	t.Parallel()
 	tests := map[string]struct {
@@ -321,7 +461,7 @@ func TestPrBody(t *testing.T) {
 			},
 		},
 	}
-	newPrBody := prBody(keys, newPrMetadata, "", promotionSkipPaths)
+	newPrBody := prBody(keys, newPrMetadata, "", promotionSkipPaths, nil)
 	expectedPrBody, err := os.ReadFile("testdata/pr_body.golden.md")
 	if err != nil {
 		t.Fatalf("Error loading golden file: %s", err)
@@ -348,7 +488,7 @@ func TestPrBodyMultiComponent(t *testing.T) {
 			},
 		},
 	}
-	newPrBody := prBody(keys, newPrMetadata, "", promotionSkipPaths)
+	newPrBody := prBody(keys, newPrMetadata, "", promotionSkipPaths, nil)
 	expectedPrBody, err := os.ReadFile("testdata/pr_body_multi_component.golden.md")
 	if err != nil {
 		t.Fatalf("Error loading golden file: %s", err)
@@ -356,6 +496,28 @@ func TestPrBodyMultiComponent(t *testing.T) {
 	assert.Equal(t, string(expectedPrBody), newPrBody)
 }
 
+func TestPrBodyEnvironmentNames(t *testing.T) {
+	t.Parallel()
+	keys := []int{1, 2}
+	newPrMetadata := prMetadata{
+		PreviousPromotionMetadata: map[int]promotionInstanceMetaData{
+			1: {
+				SourcePath:  "env/dev/",
+				TargetPaths: []string{"env/staging/"},
+			},
+			2: {
+				SourcePath:  "env/staging/",
+				TargetPaths: []string{"env/prod-us/", "env/unnamed/"},
+			},
+		},
+	}
+	pathNames := map[string]string{"env/dev/": "dev", "env/staging/": "staging", "env/prod-us/": "prod (prod-us)"}
+	newPrBody := prBody(keys, newPrMetadata, "", map[string]bool{}, pathNames)
+	expectedPrBody := "↘️  #1  `dev` ➡️  \n&nbsp;&nbsp;&nbsp;&nbsp;`staging`  \n" +
+		"&nbsp;&nbsp;&nbsp;&nbsp;↘️  #2  `staging` ➡️  \n&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;`prod (prod-us)`  \n&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;`env/unnamed/`  \n"
+	assert.Equal(t, expectedPrBody, newPrBody)
+}
+
 func TestGhPrClientDetailsGetBlameURLPrefix(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -565,3 +727,201 @@ func Test_getPromotionSkipPaths(t *testing.T) {
 		})
 	}
 }
+
+func TestEventRepoSlug(t *testing.T) {
+	t.Parallel()
+	repo := &github.Repository{FullName: github.String("AnOwner/Arepo")}
+	assert.Equal(t, "AnOwner/Arepo", eventRepoSlug(&github.PullRequestEvent{Repo: repo}))
+	assert.Equal(t, "AnOwner/Arepo", eventRepoSlug(&github.IssueCommentEvent{Repo: repo}))
+	assert.Equal(t, "", eventRepoSlug(&github.PingEvent{}))
+}
+
+func TestPeekWebhookRepoSlug(t *testing.T) {
+	t.Parallel()
+	payload := `{"action":"opened","repository":{"full_name":"AnOwner/Arepo"}}`
+	tests := map[string]struct {
+		contentType string
+		body        string
+		expected    string
+	}{
+		"JSON payload": {
+			contentType: "application/json",
+			body:        payload,
+			expected:    "AnOwner/Arepo",
+		},
+		"Form encoded payload": {
+			contentType: "application/x-www-form-urlencoded",
+			body:        "payload=" + url.QueryEscape(payload),
+			expected:    "AnOwner/Arepo",
+		},
+		"No repository": {
+			contentType: "application/json",
+			body:        `{"zen":"Keep it logically awesome."}`,
+		},
+		"Invalid payload": {
+			contentType: "application/json",
+			body:        "{",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			r, _ := http.NewRequest(http.MethodPost, "/webhook", strings.NewReader(tc.body)) //nolint:noctx
+			r.Header.Set("Content-Type", tc.contentType)
+			assert.Equal(t, tc.expected, peekWebhookRepoSlug(r))
+			// The body should still be readable for the payload validation
+			body, _ := io.ReadAll(r.Body)
+			assert.Equal(t, tc.body, string(body))
+		})
+	}
+}
+
+func TestHandleSyncCommandRequiresWriteAccess(t *testing.T) {
+	t.Parallel()
+	var comment github.IssueComment
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetReposCollaboratorsPermissionByOwnerByRepoByUsername,
+			github.RepositoryPermissionLevel{Permission: github.String("read")},
+		),
+		mock.WithRequestMatchHandler(
+			mock.PostReposIssuesCommentsByOwnerByRepoByIssueNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_ = json.NewDecoder(r.Body).Decode(&comment)
+				_, _ = w.Write(mock.MustMarshal(comment))
+			}),
+		),
+	)
+	ghPrClientDetails := GhPrClientDetails{
+		Ctx:          context.Background(),
+		GhClientPair: &GhClientPair{v3Client: github.NewClient(mockedHTTPClient)},
+		Owner:        "AnOwner",
+		Repo:         "Arepo",
+		PrNumber:     7,
+		Ref:          "feature",
+		PrLogger:     log.WithField("test", t.Name()),
+	}
+	config := &cfg.Config{Argocd: cfg.ArgocdConfig{AllowSyncfromBranchPathRegex: ".*"}}
+
+	handleSyncCommand(ghPrClientDetails, config, []string{"env/staging/nginx"}, "outsider")
+
+	assert.Equal(t, "@outsider syncing ArgoCD apps from the PR branch requires write access to this repo", comment.GetBody())
+}
+
+func TestDiffLineCounts(t *testing.T) {
+	t.Parallel()
+	added, removed := diffLineCounts("--- old.yaml\n+++ new.yaml\n@@ -1,3 +1,3 @@\n a: b\n-c: d\n+c: e\n+f: g\n")
+	assert.Equal(t, 2, added)
+	assert.Equal(t, 1, removed)
+}
+
+func TestGenerateDeletionTreeEntries(t *testing.T) {
+	t.Parallel()
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetReposContentsByOwnerByRepoByPath,
+			[]github.RepositoryContent{{Path: github.String("env/prod/app"), SHA: github.String("appsha"), Type: github.String("dir")}},
+			[]github.RepositoryContent{{Path: github.String("env/prod/app"), SHA: github.String("appsha"), Type: github.String("dir")}},
+		),
+		mock.WithRequestMatch(
+			mock.GetReposGitTreesByOwnerByRepoByTreeSha,
+			github.Tree{Entries: []*github.TreeEntry{
+				{Path: github.String("values.yaml"), Type: github.String("blob")},
+				{Path: github.String("templates"), Type: github.String("tree")},
+				{Path: github.String("templates/deployment.yaml"), Type: github.String("blob")},
+			}},
+		),
+	)
+	ghPrClientDetails := GhPrClientDetails{
+		Ctx:          context.Background(),
+		GhClientPair: &GhClientPair{v3Client: github.NewClient(mockedHTTPClient)},
+		Owner:        "AnOwner",
+		Repo:         "Arepo",
+		PrLogger:     log.WithFields(log.Fields{"repo": "AnOwner/Arepo"}),
+	}
+
+	treeEntries := []*github.TreeEntry{}
+	branch := "main"
+	targetPath := "env/prod/app"
+	err := generateDeletionTreeEntries(&ghPrClientDetails, &targetPath, &branch, &treeEntries)
+	assert.NoError(t, err)
+	paths := []string{}
+	for _, entry := range treeEntries {
+		assert.Nil(t, entry.SHA, "a nil SHA deletes the file")
+		paths = append(paths, entry.GetPath())
+	}
+	assert.Equal(t, []string{"env/prod/app/values.yaml", "env/prod/app/templates/deployment.yaml"}, paths)
+
+	missingPath := "env/prod/missing"
+	treeEntries = []*github.TreeEntry{}
+	err = generateDeletionTreeEntries(&ghPrClientDetails, &missingPath, &branch, &treeEntries)
+	assert.NoError(t, err)
+	assert.Empty(t, treeEntries, "missing directories have nothing to delete")
+}
+
+func TestGetInRepoConfigFailClosed(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		status        int
+		content       string
+		failClosed    bool
+		expectedError error
+	}{
+		"Fetch error": {
+			status: http.StatusInternalServerError,
+		},
+		"Fetch error, fail-closed": {
+			status:        http.StatusInternalServerError,
+			failClosed:    true,
+			expectedError: ErrInRepoConfigUnreadable,
+		},
+		"Missing configuration, fail-closed": {
+			status:     http.StatusNotFound,
+			failClosed: true,
+		},
+		"Invalid configuration, fail-closed": {
+			status:        http.StatusOK,
+			content:       "promotionPaths: [",
+			failClosed:    true,
+			expectedError: ErrInRepoConfigUnreadable,
+		},
+		"Valid configuration, fail-closed": {
+			status:     http.StatusOK,
+			content:    "autoApprovePromotionPrs: true",
+			failClosed: true,
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			mockedHTTPClient := mock.NewMockedHTTPClient(
+				mock.WithRequestMatchHandler(
+					mock.GetReposContentsByOwnerByRepoByPath,
+					http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						if tc.status != http.StatusOK {
+							mock.WriteError(w, tc.status, http.StatusText(tc.status))
+							return
+						}
+						_, _ = w.Write(mock.MustMarshal(github.RepositoryContent{Content: github.String(tc.content)}))
+					}),
+				),
+			)
+			ctx := tenancy.NewContext(context.Background(), &tenancy.Tenant{Name: "test", Env: map[string]string{"IN_REPO_CONFIG_FAIL_CLOSED": fmt.Sprint(tc.failClosed)}})
+			ghPrClientDetails := GhPrClientDetails{
+				Ctx:          ctx,
+				GhClientPair: &GhClientPair{v3Client: github.NewClient(mockedHTTPClient)},
+				Owner:        "AnOwner",
+				Repo:         "Arepo",
+				PrLogger:     log.WithFields(log.Fields{"repo": "AnOwner/Arepo"}),
+			}
+			config, err := GetInRepoConfig(ghPrClientDetails, "main")
+			assert.NotNil(t, config)
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package githubapi
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/notifications"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/tenancy"
+)
+
+const grafanaTokenEnvVarPrefix = "GRAFANA_"
+
+// generateGrafanaAnnotations returns the annotations of a merged promotion PR, one for each configured Grafana target its promoted paths match
+func generateGrafanaAnnotations(config *cfg.Config, promotedPaths []string, prURL string, mergedAt time.Time) map[int]notifications.GrafanaAnnotation {
+	annotations := map[int]notifications.GrafanaAnnotation{}
+	for i, target := range config.GrafanaAnnotations {
+		targetPathRegex, err := regexp.Compile(target.TargetPathRegex)
+		if err != nil {
+			continue
+		}
+		components := map[string]bool{}
+		for _, promotedPath := range promotedPaths {
+			if targetPathRegex.MatchString(promotedPath) {
+				components[path.Base(promotedPath)] = true
+			}
+		}
+		if len(components) == 0 {
+			continue
+		}
+		tags := []string{"telefonistka", "environment:" + target.Environment, "pr:" + prURL}
+		componentNames := make([]string, 0, len(components))
+		for component := range components {
+			componentNames = append(componentNames, component)
+		}
+		sort.Strings(componentNames)
+		for _, component := range componentNames {
+			tags = append(tags, "component:"+component)
+		}
+		tags = append(tags, target.Tags...)
+		annotation := notifications.NewGrafanaAnnotation(mergedAt, fmt.Sprintf("Promoted %v to %s: %s", componentNames, target.Environment, prURL), tags)
+		annotation.DashboardUID = target.DashboardUID
+		annotations[i] = annotation
+	}
+	return annotations
+}
+
+// annotatePromotionMerge posts deployment markers for a merged promotion PR, failures are logged as they shouldn't fail the event handling
+func annotatePromotionMerge(ghPrClientDetails GhPrClientDetails, config *cfg.Config) {
+	prURL := fmt.Sprintf("%s/pull/%d", ghPrClientDetails.RepoURL, ghPrClientDetails.PrNumber)
+	annotations := generateGrafanaAnnotations(config, ghPrClientDetails.PrMetadata.PromotedPaths, prURL, time.Now())
+	for i, annotation := range annotations {
+		target := config.GrafanaAnnotations[i]
+		token := ""
+		if target.TokenEnvVar != "" {
+			// The repo config picks the env var, limiting it to GRAFANA_ ones keeps repos from sending other server secrets to a URL they control
+			if !strings.HasPrefix(target.TokenEnvVar, grafanaTokenEnvVarPrefix) {
+				ghPrClientDetails.PrLogger.Errorf("Grafana tokenEnvVar %s doesn't start with %s, not posting the annotation", target.TokenEnvVar, grafanaTokenEnvVarPrefix)
+				continue
+			}
+			token = tenancy.Getenv(ghPrClientDetails.Ctx, target.TokenEnvVar, "")
+		}
+		err := notifications.PostGrafanaAnnotation(ghPrClientDetails.Ctx, target.URL, token, annotation)
+		if err != nil {
+			ghPrClientDetails.PrLogger.Errorf("Failed to post Grafana annotation to %s: err=%v", target.URL, err)
+		}
+	}
+}
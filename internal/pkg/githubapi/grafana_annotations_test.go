@@ -0,0 +1,32 @@
+package githubapi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/notifications"
+)
+
+func TestGenerateGrafanaAnnotations(t *testing.T) {
+	t.Parallel()
+	config := &cfg.Config{
+		GrafanaAnnotations: []cfg.GrafanaAnnotation{
+			{TargetPathRegex: "^env/staging/", Environment: "staging", URL: "https://grafana-staging.example.com"},
+			{TargetPathRegex: "^env/prod/", Environment: "prod", URL: "https://grafana.example.com", DashboardUID: "deploys", Tags: []string{"team:infra"}},
+		},
+	}
+	mergedAt := time.Date(2024, 6, 5, 12, 0, 0, 0, time.UTC)
+
+	annotations := generateGrafanaAnnotations(config, []string{"env/prod/us/nginx", "env/prod/eu/nginx", "env/prod/eu/redis"}, "https://github.com/AnOwner/Arepo/pull/7", mergedAt)
+
+	assert.Equal(t, map[int]notifications.GrafanaAnnotation{
+		1: {
+			DashboardUID: "deploys",
+			Time:         mergedAt.UnixMilli(),
+			Tags:         []string{"telefonistka", "environment:prod", "pr:https://github.com/AnOwner/Arepo/pull/7", "component:nginx", "component:redis", "team:infra"},
+			Text:         "Promoted [nginx redis] to prod: https://github.com/AnOwner/Arepo/pull/7",
+		},
+	}, annotations)
+}
@@ -0,0 +1,52 @@
+package githubapi
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v62/github"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/secrets"
+)
+
+// CheckGithubCredentials verifies the configured GitHub credentials work, for GitHub App deployments this mints an installation token
+func CheckGithubCredentials(ctx context.Context) error {
+	if getEnv("GITHUB_APP_ID", "") != "" {
+		client, err := createGithubAppJWTClient()
+		if err != nil {
+			return err
+		}
+		return checkAppCanMintToken(ctx, client)
+	}
+	if _, ok := secrets.Lookup("GITHUB_OAUTH_TOKEN"); !ok {
+		return fmt.Errorf("neither GITHUB_APP_ID nor GITHUB_OAUTH_TOKEN are set")
+	}
+	var githubRestAltURL string
+	if githubHost := getEnv("GITHUB_HOST", ""); githubHost != "" {
+		githubRestAltURL = fmt.Sprintf("https://%s/api/v3", githubHost)
+	}
+	_, resp, err := createGithubRestClient(ctx, secretTokenSource{name: "GITHUB_OAUTH_TOKEN"}, "GITHUB_OAUTH_TOKEN", githubRestAltURL).Users.Get(ctx, "")
+	prom.InstrumentGhCall(resp)
+	if err != nil {
+		return fmt.Errorf("GitHub OAuth token check failed: %w", err)
+	}
+	return nil
+}
+
+// checkAppCanMintToken mints an installation token for the first installation of the app
+func checkAppCanMintToken(ctx context.Context, client *github.Client) error {
+	installations, resp, err := client.Apps.ListInstallations(ctx, &github.ListOptions{PerPage: 1})
+	prom.InstrumentGhCall(resp)
+	if err != nil {
+		return fmt.Errorf("failed to list GitHub App installations: %w", err)
+	}
+	if len(installations) == 0 {
+		return fmt.Errorf("GitHub App has no installations")
+	}
+	_, resp, err = client.Apps.CreateInstallationToken(ctx, installations[0].GetID(), nil)
+	prom.InstrumentGhCall(resp)
+	if err != nil {
+		return fmt.Errorf("failed to mint GitHub App installation token: %w", err)
+	}
+	return nil
+}
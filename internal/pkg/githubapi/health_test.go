@@ -0,0 +1,50 @@
+package githubapi
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckAppCanMintToken(t *testing.T) {
+	t.Parallel()
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetAppInstallations,
+			[]github.Installation{{ID: github.Int64(1)}},
+		),
+		mock.WithRequestMatch(
+			mock.PostAppInstallationsAccessTokensByInstallationId,
+			github.InstallationToken{Token: github.String("t0ken")},
+		),
+	)
+	assert.NoError(t, checkAppCanMintToken(context.Background(), github.NewClient(mockedHTTPClient)))
+}
+
+func TestCheckAppCanMintTokenFailures(t *testing.T) {
+	t.Parallel()
+	tests := map[string]*http.Client{
+		"No installations": mock.NewMockedHTTPClient(
+			mock.WithRequestMatch(mock.GetAppInstallations, []github.Installation{}),
+		),
+		"Token minting fails": mock.NewMockedHTTPClient(
+			mock.WithRequestMatch(mock.GetAppInstallations, []github.Installation{{ID: github.Int64(1)}}),
+			mock.WithRequestMatchHandler(
+				mock.PostAppInstallationsAccessTokensByInstallationId,
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					mock.WriteError(w, http.StatusUnauthorized, "Bad credentials")
+				}),
+			),
+		),
+	}
+	for name, mockedHTTPClient := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Error(t, checkAppCanMintToken(context.Background(), github.NewClient(mockedHTTPClient)))
+		})
+	}
+}
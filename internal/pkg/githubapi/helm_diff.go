@@ -0,0 +1,155 @@
+package githubapi
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/hexops/gotextdiff"
+	"github.com/hexops/gotextdiff/myers"
+	"github.com/hexops/gotextdiff/span"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/helm"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// helmChartFiles fetches the files of componentPath on ref, keyed by their path relative to the component, a missing component returns an empty map
+func helmChartFiles(ghPrClientDetails GhPrClientDetails, componentPath string, ref string) (map[string]string, error) {
+	fileSHAs := map[string]string{}
+	generateFlatMapfromFileTree(&ghPrClientDetails, &componentPath, &ref, fileSHAs)
+	files := map[string]string{}
+	for fileName := range fileSHAs {
+		content, _, err := GetFileContent(ghPrClientDetails, ref, componentPath+"/"+fileName)
+		if err != nil {
+			return nil, fmt.Errorf("get %s/%s content from %s: %w", componentPath, fileName, ref, err)
+		}
+		files[fileName] = content
+	}
+	return files, nil
+}
+
+func chartVersion(files map[string]string) string {
+	chart := struct {
+		Version string `yaml:"version"`
+	}{}
+	_ = yaml.Unmarshal([]byte(files["Chart.yaml"]), &chart)
+	return chart.Version
+}
+
+// renderHelmChart writes the chart files to a temporary directory and runs helm template on it, a directory without a Chart.yaml renders nothing
+func renderHelmChart(ctx context.Context, files map[string]string, releaseName string, helmDiffConfig cfg.HelmDiffConfig) (string, error) {
+	if _, isChart := files["Chart.yaml"]; !isChart {
+		return "", nil
+	}
+	chartDir, err := os.MkdirTemp("", "telefonistka-helm-")
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = os.RemoveAll(chartDir) }()
+	for fileName, content := range files {
+		filePath := filepath.Join(chartDir, filepath.FromSlash(fileName))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0o700); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(filePath, []byte(content), 0o600); err != nil {
+			return "", err
+		}
+	}
+	// Values files are often per environment, so the ones missing from this component are skipped
+	valuesFiles := []string{}
+	for _, valuesFile := range helmDiffConfig.ValuesFiles {
+		if _, found := files[valuesFile]; found {
+			valuesFiles = append(valuesFiles, valuesFile)
+		}
+	}
+	return helm.Template(ctx, chartDir, helm.TemplateOptions{
+		ReleaseName: releaseName,
+		Namespace:   helmDiffConfig.Namespace,
+		ValuesFiles: valuesFiles,
+	})
+}
+
+// generateHelmDiff renders the Helm chart of componentPath on both branches and diffs the results, ok is false if the component isn't a Helm chart on either branch
+func generateHelmDiff(ghPrClientDetails GhPrClientDetails, helmDiffConfig cfg.HelmDiffConfig, componentPath string, baseBranch string) (result ComponentDiff, ok bool) {
+	result.ComponentPath = componentPath
+	baseFiles, err := helmChartFiles(ghPrClientDetails, componentPath, baseBranch)
+	if err != nil {
+		result.Err = err
+		return result, true
+	}
+	prFiles, err := helmChartFiles(ghPrClientDetails, componentPath, ghPrClientDetails.Ref)
+	if err != nil {
+		result.Err = err
+		return result, true
+	}
+	_, baseIsChart := baseFiles["Chart.yaml"]
+	_, prIsChart := prFiles["Chart.yaml"]
+	if !baseIsChart && !prIsChart {
+		return result, false
+	}
+	result.Summary = chartVersionChange(chartVersion(baseFiles), chartVersion(prFiles))
+
+	releaseName := path.Base(componentPath)
+	baseManifests, err := renderHelmChart(ghPrClientDetails.Ctx, baseFiles, releaseName, helmDiffConfig)
+	if err != nil {
+		result.Err = fmt.Errorf("rendering %s: %w", baseBranch, err)
+		return result, true
+	}
+	prManifests, err := renderHelmChart(ghPrClientDetails.Ctx, prFiles, releaseName, helmDiffConfig)
+	if err != nil {
+		result.Err = fmt.Errorf("rendering %s: %w", ghPrClientDetails.Ref, err)
+		return result, true
+	}
+	result.Diff = renderedManifestsDiff(baseBranch+"/"+componentPath, ghPrClientDetails.Ref+"/"+componentPath, baseManifests, prManifests)
+	return result, true
+}
+
+// renderedManifestsDiff returns the unified diff of two renders, or an empty string if they are identical
+func renderedManifestsDiff(oldName string, newName string, oldManifests string, newManifests string) string {
+	edits := myers.ComputeEdits(span.URIFromPath(newName), oldManifests, newManifests)
+	if len(edits) == 0 {
+		return ""
+	}
+	return fmt.Sprint(gotextdiff.ToUnified(oldName, newName, oldManifests, edits))
+}
+
+// chartVersionChange describes the chart version change, or returns an empty string if the version didn't change
+func chartVersionChange(oldVersion string, newVersion string) string {
+	if oldVersion == newVersion {
+		return ""
+	}
+	if oldVersion == "" {
+		oldVersion = "(none)"
+	}
+	if newVersion == "" {
+		newVersion = "(none)"
+	}
+	return fmt.Sprintf("Chart version: `%s` → `%s`", oldVersion, newVersion)
+}
+
+// helmDiffProvider diffs the changed components that are Helm charts with a local helm template render of both branches
+type helmDiffProvider struct {
+	helmDiffConfig cfg.HelmDiffConfig
+}
+
+func (p helmDiffProvider) Title() string {
+	return "Helm diff"
+}
+
+func (p helmDiffProvider) Diff(ghPrClientDetails GhPrClientDetails, componentPaths []string, baseBranch string) ([]ComponentDiff, error) {
+	diffs := []ComponentDiff{}
+	for _, componentPath := range componentPaths {
+		result, ok := generateHelmDiff(ghPrClientDetails, p.helmDiffConfig, componentPath, baseBranch)
+		if !ok {
+			ghPrClientDetails.PrLogger.Debugf("%s isn't a Helm chart, skipping Helm diff", componentPath)
+			continue
+		}
+		if result.Err != nil {
+			ghPrClientDetails.PrLogger.Errorf("Failed to generate Helm diff of %s: err=%s", componentPath, result.Err)
+		}
+		diffs = append(diffs, result)
+	}
+	return diffs, nil
+}
@@ -0,0 +1,20 @@
+package githubapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChartVersion(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "1.2.3", chartVersion(map[string]string{"Chart.yaml": "apiVersion: v2\nname: app\nversion: 1.2.3\n"}))
+	assert.Equal(t, "", chartVersion(map[string]string{"values.yaml": "a: b\n"}))
+}
+
+func TestChartVersionChange(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "Chart version: `1.0.0` → `1.1.0`", chartVersionChange("1.0.0", "1.1.0"))
+	assert.Equal(t, "Chart version: `(none)` → `1.0.0`", chartVersionChange("", "1.0.0"))
+	assert.Equal(t, "", chartVersionChange("1.0.0", "1.0.0"))
+}
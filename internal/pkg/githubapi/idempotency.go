@@ -0,0 +1,103 @@
+package githubapi
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	log "github.com/sirupsen/logrus"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+)
+
+// GitHub redelivers events it didn't get a timely 2xx for, and the redelivery keeps the delivery ID, so the writes that can't run twice
+// (comments, branches, PRs, merges) are remembered per delivery ID + head SHA and a redelivery gets the result of the first run instead of writing again
+type eventWrites struct {
+	key    string
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+type eventWritesContextKey struct{}
+
+var (
+	completedGhWritesOnce sync.Once
+	completedGhWrites     *expirable.LRU[string, any]
+	completedGhWritesTTL  time.Duration
+)
+
+// completedGhWriteCache returns the(possibly nil, i.e. disabled) cache of the writes done while handling recent events,
+// configured by GITHUB_WRITE_IDEMPOTENCY_WINDOW_SECONDS(default 3600, 0 disables it)
+func completedGhWriteCache() *expirable.LRU[string, any] {
+	completedGhWritesOnce.Do(func() {
+		window, err := strconv.Atoi(getEnv("GITHUB_WRITE_IDEMPOTENCY_WINDOW_SECONDS", "3600"))
+		if err != nil || window <= 0 {
+			return
+		}
+		completedGhWritesTTL = time.Duration(window) * time.Second
+		completedGhWrites = expirable.NewLRU[string, any](100000, nil, completedGhWritesTTL)
+	})
+	return completedGhWrites
+}
+
+// withIdempotencyKey scopes the deduplicated writes of ctx to an event delivery, writes of contexts without a delivery ID aren't deduplicated
+func withIdempotencyKey(ctx context.Context, deliveryID string, headSHA string) context.Context {
+	if deliveryID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, eventWritesContextKey{}, &eventWrites{key: deliveryID + "@" + headSHA, counts: map[string]int{}})
+}
+
+// eventHeadSHA returns the commit an event is about, if it has one
+func eventHeadSHA(eventPayloadInterface interface{}) string {
+	switch eventPayload := eventPayloadInterface.(type) {
+	case *github.PullRequestEvent:
+		return eventPayload.GetPullRequest().GetHead().GetSHA()
+	case *github.PullRequestReviewEvent:
+		return eventPayload.GetPullRequest().GetHead().GetSHA()
+	case *github.PushEvent:
+		return eventPayload.GetAfter()
+	default:
+		return ""
+	}
+}
+
+// deduplicateGhWrite runs write once per event delivery, target identifies the write within the event(e.g. the PR number and comment body hash).
+// The same write done again while handling the same event is numbered, so only the writes a redelivery repeats are skipped.
+// With a shared cache a redelivery handled by another replica(or after a restart) is deduplicated too
+func deduplicateGhWrite[T any](ctx context.Context, operation string, target string, write func() (T, *github.Response, error)) (T, *github.Response, error) {
+	writes, ok := ctx.Value(eventWritesContextKey{}).(*eventWrites)
+	cache := completedGhWriteCache()
+	if !ok || cache == nil {
+		return write()
+	}
+	writes.mu.Lock()
+	writeKey := operation + "|" + target
+	writes.counts[writeKey]++
+	key := fmt.Sprintf("%s|%s|%d", writes.key, writeKey, writes.counts[writeKey])
+	writes.mu.Unlock()
+
+	if cached, ok := cache.Get(key); ok {
+		if result, ok := cached.(T); ok {
+			log.Infof("Skipping GitHub %s of %s, it was already done for event delivery %s", operation, target, writes.key)
+			prom.InstrumentGhWrite(operation, "deduplicated")
+			return result, nil, nil
+		}
+	}
+	var shared T
+	if getSharedCache().get(ctx, "github_write", key, &shared) {
+		log.Infof("Skipping GitHub %s of %s, it was already done for event delivery %s by another replica", operation, target, writes.key)
+		prom.InstrumentGhWrite(operation, "deduplicated")
+		cache.Add(key, shared)
+		return shared, nil, nil
+	}
+	result, resp, err := write()
+	if err == nil {
+		cache.Add(key, result)
+		getSharedCache().set(ctx, "github_write", key, result, completedGhWritesTTL)
+	}
+	return result, resp, err
+}
@@ -0,0 +1,84 @@
+package githubapi
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeduplicateGhWrite(t *testing.T) {
+	t.Parallel()
+	calls := 0
+	write := func() (*github.IssueComment, *github.Response, error) {
+		calls++
+		return &github.IssueComment{ID: github.Int64(int64(calls))}, nil, nil
+	}
+
+	firstDelivery := withIdempotencyKey(context.Background(), "dedup-test-delivery", "abc123")
+	first, _, err := deduplicateGhWrite(firstDelivery, "create_comment", "1/body", write)
+	assert.NoError(t, err)
+	// The same comment posted twice while handling one event isn't a redelivery
+	second, _, err := deduplicateGhWrite(firstDelivery, "create_comment", "1/body", write)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.NotEqual(t, first.GetID(), second.GetID())
+
+	redelivery := withIdempotencyKey(context.Background(), "dedup-test-delivery", "abc123")
+	redelivered, _, err := deduplicateGhWrite(redelivery, "create_comment", "1/body", write)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, first.GetID(), redelivered.GetID())
+	_, _, err = deduplicateGhWrite(redelivery, "create_comment", "1/body", write)
+	assert.NoError(t, err)
+	_, _, err = deduplicateGhWrite(redelivery, "create_comment", "1/body", write)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls, "the third write wasn't done by the first delivery")
+
+	newCommit := withIdempotencyKey(context.Background(), "dedup-test-delivery", "def456")
+	_, _, err = deduplicateGhWrite(newCommit, "create_comment", "1/body", write)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, calls)
+}
+
+func TestDeduplicateGhWriteWithoutDeliveryID(t *testing.T) {
+	t.Parallel()
+	calls := 0
+	write := func() (*github.Reference, *github.Response, error) {
+		calls++
+		return &github.Reference{}, nil, nil
+	}
+	ctx := withIdempotencyKey(context.Background(), "", "abc123")
+	_, _, _ = deduplicateGhWrite(ctx, "create_ref", "refs/heads/promotions/1", write)
+	_, _, _ = deduplicateGhWrite(context.Background(), "create_ref", "refs/heads/promotions/1", write)
+	assert.Equal(t, 2, calls)
+}
+
+func TestEventHeadSHA(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		event       interface{}
+		expectedSHA string
+	}{
+		"pull request": {
+			event:       &github.PullRequestEvent{PullRequest: &github.PullRequest{Head: &github.PullRequestBranch{SHA: github.String("abc123")}}},
+			expectedSHA: "abc123",
+		},
+		"push": {
+			event:       &github.PushEvent{After: github.String("def456")},
+			expectedSHA: "def456",
+		},
+		"issue comment": {
+			event:       &github.IssueCommentEvent{},
+			expectedSHA: "",
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expectedSHA, eventHeadSHA(tc.event))
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package githubapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bradleyfalzon/ghinstallation/v2"
+	"github.com/google/go-github/v62/github"
+	log "github.com/sirupsen/logrus"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+	"golang.org/x/oauth2"
+)
+
+// Installation tokens are valid for an hour, they are refreshed this long before they expire so in-flight event handling never uses an expired token
+const installationTokenRefreshMargin = 5 * time.Minute
+
+var (
+	installationTokenSourcesMu sync.Mutex
+	// Token sources outlive the clients in the LRU caches, a client recreated after being evicted keeps using the existing token
+	installationTokenSources = map[string]oauth2.TokenSource{}
+)
+
+// installationTokenMinter mints GitHub App installation tokens, the REST and GraphQL clients of an installation share its tokens.
+// With a shared cache the replicas share them too
+type installationTokenMinter struct {
+	appsClient     *github.Client
+	appId          int64
+	installationId int64
+	cacheKey       string
+	shared         *sharedCache
+}
+
+func (m installationTokenMinter) Token() (*oauth2.Token, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	var cached oauth2.Token
+	if m.shared.get(ctx, "installation_token", m.cacheKey, &cached) && time.Until(cached.Expiry) > installationTokenRefreshMargin {
+		log.Debugf("Using shared installation token for app %d installation %d, expires at %v", m.appId, m.installationId, cached.Expiry)
+		return &cached, nil
+	}
+	token, resp, err := m.appsClient.Apps.CreateInstallationToken(ctx, m.installationId, nil)
+	prom.InstrumentGhCall(resp)
+	appId := strconv.FormatInt(m.appId, 10)
+	if err != nil {
+		prom.InstrumentInstallationTokenMint(appId, "failure")
+		return nil, fmt.Errorf("failed to create installation token for app %d installation %d: %w", m.appId, m.installationId, err)
+	}
+	prom.InstrumentInstallationTokenMint(appId, "success")
+	log.Debugf("Minted installation token for app %d installation %d, expires at %v", m.appId, m.installationId, token.GetExpiresAt())
+	minted := &oauth2.Token{AccessToken: token.GetToken(), Expiry: token.GetExpiresAt().Time}
+	m.shared.set(ctx, "installation_token", m.cacheKey, minted, time.Until(minted.Expiry)-installationTokenRefreshMargin)
+	return minted, nil
+}
+
+// newGithubAppsClient returns a client authenticated as the GitHub App itself(JWT), used for app level calls like listing installations and minting tokens
+func newGithubAppsClient(githubAppPrivateKey []byte, githubAppId int64, githubRestAltURL string) (*github.Client, error) {
+	atr, err := ghinstallation.NewAppsTransport(http.DefaultTransport, githubAppId, githubAppPrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	client := github.NewClient(&http.Client{Transport: atr, Timeout: time.Second * 30})
+	if githubRestAltURL != "" {
+		atr.BaseURL = githubRestAltURL
+		client, err = client.WithEnterpriseURLs(githubRestAltURL, githubRestAltURL)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+// installationTokenSource returns the shared, caching token source of an installation. cacheKey separates tenants/credential sets that use the same app
+func installationTokenSource(cacheKey string, appsClient *github.Client, githubAppId int64, githubAppInstallationId int64) oauth2.TokenSource {
+	key := fmt.Sprintf("%s/%d/%d", cacheKey, githubAppId, githubAppInstallationId)
+	installationTokenSourcesMu.Lock()
+	defer installationTokenSourcesMu.Unlock()
+	if ts, ok := installationTokenSources[key]; ok {
+		return ts
+	}
+	ts := oauth2.ReuseTokenSourceWithExpiry(nil, installationTokenMinter{appsClient: appsClient, appId: githubAppId, installationId: githubAppInstallationId, cacheKey: key, shared: getSharedCache()}, installationTokenRefreshMargin)
+	installationTokenSources[key] = ts
+	return ts
+}
@@ -0,0 +1,112 @@
+package githubapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/go-github/v62/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/tenancy"
+)
+
+func TestInstallationTokenSourceReusesTokens(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		tokenLifetime  time.Duration
+		expectedMints  int32
+		expectedTokens []string
+	}{
+		"Valid token is reused": {
+			tokenLifetime:  time.Hour,
+			expectedMints:  1,
+			expectedTokens: []string{"token-1", "token-1", "token-1"},
+		},
+		"Token close to expiry is refreshed": {
+			tokenLifetime:  installationTokenRefreshMargin - time.Minute,
+			expectedMints:  3,
+			expectedTokens: []string{"token-1", "token-2", "token-3"},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var mints atomic.Int32
+			mockedHTTPClient := mock.NewMockedHTTPClient(
+				mock.WithRequestMatchHandler(
+					mock.PostAppInstallationsAccessTokensByInstallationId,
+					http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						assert.Equal(t, "/app/installations/456/access_tokens", r.URL.Path)
+						n := mints.Add(1)
+						_, _ = w.Write(mock.MustMarshal(github.InstallationToken{
+							Token:     github.String(fmt.Sprintf("token-%d", n)),
+							ExpiresAt: &github.Timestamp{Time: time.Now().Add(tc.tokenLifetime)},
+						}))
+					}),
+				),
+			)
+			appsClient := github.NewClient(mockedHTTPClient)
+			cacheKey := "test/" + name
+			for _, expectedToken := range tc.expectedTokens {
+				// The REST/GraphQL clients and clients recreated after an LRU eviction get the same token source
+				token, err := installationTokenSource(cacheKey, appsClient, 123, 456).Token()
+				assert.NoError(t, err)
+				assert.Equal(t, expectedToken, token.AccessToken)
+			}
+			assert.Equal(t, tc.expectedMints, mints.Load())
+		})
+	}
+}
+
+func TestInstallationTokensAreShared(t *testing.T) {
+	t.Parallel()
+	var mints atomic.Int32
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.PostAppInstallationsAccessTokensByInstallationId,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := mints.Add(1)
+				_, _ = w.Write(mock.MustMarshal(github.InstallationToken{
+					Token:     github.String(fmt.Sprintf("token-%d", n)),
+					ExpiresAt: &github.Timestamp{Time: time.Now().Add(time.Hour)},
+				}))
+			}),
+		),
+	)
+	redisServer := miniredis.RunT(t)
+	// Two replicas sharing the Redis
+	replica := func() installationTokenMinter {
+		return installationTokenMinter{appsClient: github.NewClient(mockedHTTPClient), appId: 123, installationId: 456, cacheKey: "test/123/456", shared: newSharedCache(redisServer.Addr(), "")}
+	}
+	for _, minter := range []installationTokenMinter{replica(), replica(), replica()} {
+		token, err := minter.Token()
+		assert.NoError(t, err)
+		assert.Equal(t, "token-1", token.AccessToken)
+	}
+	assert.Equal(t, int32(1), mints.Load())
+
+	// Shared tokens are dropped before they need to be refreshed
+	redisServer.FastForward(time.Hour - installationTokenRefreshMargin)
+	token, err := replica().Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "token-2", token.AccessToken)
+}
+
+func TestCredentialEnvVars(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	assert.Equal(t, CredentialEnvVars{AppID: "GITHUB_APP_ID", AppPrivateKeyPath: "GITHUB_APP_PRIVATE_KEY_PATH", OauthToken: "GITHUB_OAUTH_TOKEN"}, MainCredentialEnvVars(ctx))
+	assert.Equal(t, "APPROVER_GITHUB_APP_ID", ApproverCredentialEnvVars(ctx).AppID)
+
+	tenantCtx := tenancy.NewContext(ctx, &tenancy.Tenant{Name: "team-a", Env: map[string]string{
+		"GITHUB_CREDENTIALS_PREFIX":          "TEAM_A_",
+		"APPROVER_GITHUB_CREDENTIALS_PREFIX": "TEAM_A_APPROVER_",
+	}})
+	assert.Equal(t, CredentialEnvVars{AppID: "TEAM_A_GITHUB_APP_ID", AppPrivateKeyPath: "TEAM_A_GITHUB_APP_PRIVATE_KEY_PATH", OauthToken: "TEAM_A_GITHUB_OAUTH_TOKEN"}, MainCredentialEnvVars(tenantCtx))
+	assert.Equal(t, "TEAM_A_APPROVER_GITHUB_OAUTH_TOKEN", ApproverCredentialEnvVars(tenantCtx).OauthToken)
+}
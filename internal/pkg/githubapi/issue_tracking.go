@@ -0,0 +1,60 @@
+package githubapi
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/ticketing"
+)
+
+// issueLinks renders the issue keys as links when an issue tracker is configured
+func issueLinks(tracker ticketing.Tracker, issueKeys []string) string {
+	links := make([]string, 0, len(issueKeys))
+	for _, key := range issueKeys {
+		if tracker != nil {
+			links = append(links, fmt.Sprintf("[%s](%s)", key, tracker.IssueURL(key)))
+		} else {
+			links = append(links, "`"+key+"`")
+		}
+	}
+	return strings.Join(links, ", ")
+}
+
+// transitionPromotedIssues transitions the issues of the original PR once its last promotion PR is merged
+func transitionPromotedIssues(ghPrClientDetails GhPrClientDetails, transition string) {
+	tracker := ticketing.FromEnv(ghPrClientDetails.Ctx)
+	if tracker == nil || len(ghPrClientDetails.PrMetadata.IssueKeys) == 0 {
+		return
+	}
+	transitioned := []string{}
+	for _, key := range ghPrClientDetails.PrMetadata.IssueKeys {
+		err := tracker.TransitionIssue(ghPrClientDetails.Ctx, key, transition)
+		if err != nil {
+			ghPrClientDetails.PrLogger.Warnf("Failed to transition %s issue %s to %q: err=%v", tracker.Name(), key, transition, err)
+			continue
+		}
+		transitioned = append(transitioned, key)
+	}
+	if len(transitioned) > 0 {
+		_ = ghPrClientDetails.CommentOnPr(fmt.Sprintf("Moved %s to %q, this was the last promotion of the change", issueLinks(tracker, transitioned), transition))
+	}
+}
+
+// isFinalPromotionTarget checks the promotion config has no further promotion step from any of the promoted paths,
+// the promotion plan itself can't be used as skipped, paused or held targets also leave it empty
+func isFinalPromotionTarget(config *cfg.Config, promotedPaths []string) bool {
+	if len(promotedPaths) == 0 {
+		return false
+	}
+	for _, promotedPath := range promotedPaths {
+		for _, promotionPath := range config.PromotionPaths {
+			if match, _ := regexp.MatchString("^"+promotionPath.SourcePath+".*", promotedPath+"/"); match {
+				return false
+			}
+		}
+	}
+	return true
+}
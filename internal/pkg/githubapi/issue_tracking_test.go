@@ -0,0 +1,33 @@
+package githubapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/ticketing"
+)
+
+func TestIssueLinks(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "`OPS-1`, `OPS-2`", issueLinks(nil, []string{"OPS-1", "OPS-2"}))
+}
+
+func TestIssueLinksWithTracker(t *testing.T) {
+	t.Setenv("JIRA_URL", "https://example.atlassian.net")
+	tracker := ticketing.FromEnv(t.Context())
+	assert.Equal(t, "[OPS-1](https://example.atlassian.net/browse/OPS-1)", issueLinks(tracker, []string{"OPS-1"}))
+}
+
+func TestIsFinalPromotionTarget(t *testing.T) {
+	t.Parallel()
+	config := &cfg.Config{
+		PromotionPaths: []cfg.PromotionPath{
+			{SourcePath: "env/staging/", PromotionPrs: []cfg.PromotionPr{{TargetPaths: []string{"env/prod/us/", "env/prod/eu/"}}}},
+		},
+	}
+	assert.False(t, isFinalPromotionTarget(config, []string{"env/staging/nginx"}))
+	assert.True(t, isFinalPromotionTarget(config, []string{"env/prod/us/nginx", "env/prod/eu/nginx"}))
+	assert.False(t, isFinalPromotionTarget(config, []string{"env/prod/us/nginx", "env/staging/nginx"}))
+	assert.False(t, isFinalPromotionTarget(config, nil))
+}
@@ -0,0 +1,202 @@
+package githubapi
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/argocd"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/kustomize"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+)
+
+// maxSnapshotFileSize caps the size of a single extracted repo file, GitOps repos shouldn't have anything close to it
+const maxSnapshotFileSize = 100 << 20
+
+// extractRepoTarball extracts a GitHub repo tarball to dir, dropping the "<owner>-<repo>-<sha>/" prefix of its entries
+func extractRepoTarball(tarball io.Reader, dir string) error {
+	gzipReader, err := gzip.NewReader(tarball)
+	if err != nil {
+		return err
+	}
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		_, relativeName, found := strings.Cut(header.Name, "/")
+		if !found || relativeName == "" {
+			continue
+		}
+		targetPath := filepath.Join(dir, filepath.FromSlash(relativeName))
+		if !strings.HasPrefix(targetPath, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return fmt.Errorf("tarball entry %s is outside the extraction directory", header.Name)
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(targetPath, 0o700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(targetPath), 0o700); err != nil {
+				return err
+			}
+			file, err := os.OpenFile(targetPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(file, io.LimitReader(tarReader, maxSnapshotFileSize))
+			closeErr := file.Close()
+			if err != nil {
+				return err
+			}
+			if closeErr != nil {
+				return closeErr
+			}
+		}
+	}
+}
+
+// downloadRepoSnapshot extracts the repo content of ref to a new temporary directory, kustomize overlays can reference any path in the repo so a single component isn't enough
+func downloadRepoSnapshot(ghPrClientDetails GhPrClientDetails, ref string) (string, error) {
+	archiveURL, resp, err := ghPrClientDetails.GhClientPair.v3Client.Repositories.GetArchiveLink(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, github.Tarball, &github.RepositoryContentGetOptions{Ref: ref}, 3)
+	prom.InstrumentGhCall(resp)
+	if err != nil {
+		return "", fmt.Errorf("get %s tarball link: %w", ref, err)
+	}
+	req, err := http.NewRequestWithContext(ghPrClientDetails.Ctx, http.MethodGet, archiveURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	archiveResp, err := ghPrClientDetails.GhClientPair.v3Client.Client().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("download %s tarball: %w", ref, err)
+	}
+	defer archiveResp.Body.Close()
+	if archiveResp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download %s tarball: unexpected status %s", ref, archiveResp.Status)
+	}
+	dir, err := os.MkdirTemp("", "telefonistka-repo-")
+	if err != nil {
+		return "", err
+	}
+	err = extractRepoTarball(archiveResp.Body, dir)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return "", fmt.Errorf("extract %s tarball: %w", ref, err)
+	}
+	return dir, nil
+}
+
+// generateKustomizeDiff builds componentPath in both repo snapshots and diffs the results, ok is false if the component isn't a kustomization in either snapshot
+func generateKustomizeDiff(ctx context.Context, baseDir string, prDir string, componentPath string, baseName string, prName string) (result ComponentDiff, ok bool) {
+	result.ComponentPath = componentPath
+	baseComponentDir := filepath.Join(baseDir, filepath.FromSlash(componentPath))
+	prComponentDir := filepath.Join(prDir, filepath.FromSlash(componentPath))
+	baseIsKustomization := kustomize.HasKustomization(baseComponentDir)
+	prIsKustomization := kustomize.HasKustomization(prComponentDir)
+	if !baseIsKustomization && !prIsKustomization {
+		return result, false
+	}
+	var baseManifests, prManifests string
+	var err error
+	if baseIsKustomization {
+		baseManifests, err = kustomize.Build(ctx, baseComponentDir)
+		if err != nil {
+			result.Err = fmt.Errorf("rendering %s: %w", baseName, err)
+			return result, true
+		}
+	}
+	if prIsKustomization {
+		prManifests, err = kustomize.Build(ctx, prComponentDir)
+		if err != nil {
+			result.Err = fmt.Errorf("rendering %s: %w", prName, err)
+			return result, true
+		}
+	}
+	result.Diff = renderedManifestsDiff(baseName+"/"+componentPath, prName+"/"+componentPath, baseManifests, prManifests)
+	return result, true
+}
+
+// kustomizeFallbackPaths returns the components ArgoCD didn't diff, either because the diff is disabled in their telefonistka.yaml or because it failed
+func kustomizeFallbackPaths(componentsToDiff map[string]bool, diffOfChangedComponents []argocd.DiffResult) []string {
+	fallback := map[string]bool{}
+	for componentPath, shouldDiff := range componentsToDiff {
+		if !shouldDiff {
+			fallback[componentPath] = true
+		}
+	}
+	for _, diffResult := range diffOfChangedComponents {
+		if diffResult.DiffError != nil {
+			fallback[diffResult.ComponentPath] = true
+		}
+	}
+	fallbackPaths := []string{}
+	for componentPath := range fallback {
+		fallbackPaths = append(fallbackPaths, componentPath)
+	}
+	sort.Strings(fallbackPaths)
+	return fallbackPaths
+}
+
+// kustomizeDiffProvider diffs the changed components that are kustomizations with a local kustomize build of both branches
+type kustomizeDiffProvider struct{}
+
+func (p kustomizeDiffProvider) Title() string {
+	return "Kustomize diff"
+}
+
+func (p kustomizeDiffProvider) Diff(ghPrClientDetails GhPrClientDetails, componentPaths []string, baseBranch string) ([]ComponentDiff, error) {
+	baseDir, err := downloadRepoSnapshot(ghPrClientDetails, baseBranch)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(baseDir) }()
+	prDir, err := downloadRepoSnapshot(ghPrClientDetails, ghPrClientDetails.PrSHA)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(prDir) }()
+
+	diffs := []ComponentDiff{}
+	for _, componentPath := range componentPaths {
+		result, ok := generateKustomizeDiff(ghPrClientDetails.Ctx, baseDir, prDir, componentPath, baseBranch, ghPrClientDetails.Ref)
+		if !ok {
+			ghPrClientDetails.PrLogger.Debugf("%s isn't a kustomization, skipping kustomize diff", componentPath)
+			continue
+		}
+		if result.Err != nil {
+			ghPrClientDetails.PrLogger.Errorf("Failed to generate kustomize diff of %s: err=%s", componentPath, result.Err)
+		}
+		diffs = append(diffs, result)
+	}
+	return diffs, nil
+}
+
+// argoCdDiffFallback comments the kustomize diff of all the changed components when the ArgoCD diff couldn't be generated at all, argoCdErr is returned as is when the fallback is disabled
+func argoCdDiffFallback(ghPrClientDetails GhPrClientDetails, kustomizeDiffFallback bool, componentPaths []string, baseBranch string, argoCdErr error) error {
+	if !kustomizeDiffFallback {
+		return argoCdErr
+	}
+	ghPrClientDetails.PrLogger.Errorf("ArgoCD diff failed, falling back to a local kustomize diff: err=%s\n", argoCdErr)
+	sortedPaths := append([]string{}, componentPaths...)
+	sort.Strings(sortedPaths)
+	err := commentComponentDiffs(ghPrClientDetails, kustomizeDiffProvider{}, sortedPaths, baseBranch, nil)
+	if err != nil {
+		return fmt.Errorf("kustomize diff fallback: %w", err)
+	}
+	return nil
+}
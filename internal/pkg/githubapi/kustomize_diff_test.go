@@ -0,0 +1,70 @@
+package githubapi
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/argocd"
+)
+
+func repoTarball(t *testing.T, files map[string]string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for name, content := range files {
+		assert.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tarWriter.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tarWriter.Close())
+	assert.NoError(t, gzipWriter.Close())
+	return &buf
+}
+
+func TestExtractRepoTarball(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	err := extractRepoTarball(repoTarball(t, map[string]string{
+		"owner-repo-abc123/clusters/prod/app/kustomization.yaml": "resources: []\n",
+	}), dir)
+	assert.NoError(t, err)
+	content, err := os.ReadFile(filepath.Join(dir, "clusters/prod/app/kustomization.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, "resources: []\n", string(content))
+}
+
+func TestExtractRepoTarballRejectsPathTraversal(t *testing.T) {
+	t.Parallel()
+	err := extractRepoTarball(repoTarball(t, map[string]string{
+		"owner-repo-abc123/../../etc/passwd": "root",
+	}), t.TempDir())
+	assert.Error(t, err)
+}
+
+func TestKustomizeFallbackPaths(t *testing.T) {
+	t.Parallel()
+	componentsToDiff := map[string]bool{
+		"clusters/prod/app1": true,
+		"clusters/prod/app2": false,
+		"clusters/prod/app3": true,
+	}
+	diffResults := []argocd.DiffResult{
+		{ComponentPath: "clusters/prod/app1"},
+		{ComponentPath: "clusters/prod/app3", DiffError: errors.New("ArgoCD app not found")},
+	}
+	assert.Equal(t, []string{"clusters/prod/app2", "clusters/prod/app3"}, kustomizeFallbackPaths(componentsToDiff, diffResults))
+}
+
+func TestGenerateKustomizeDiffSkipsNonKustomizations(t *testing.T) {
+	t.Parallel()
+	_, ok := generateKustomizeDiff(context.Background(), t.TempDir(), t.TempDir(), "clusters/prod/app", "main", "my-branch")
+	assert.False(t, ok)
+}
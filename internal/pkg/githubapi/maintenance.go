@@ -0,0 +1,67 @@
+package githubapi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	lru "github.com/hashicorp/golang-lru/v2"
+	log "github.com/sirupsen/logrus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/logging"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/maintenance"
+)
+
+// hasTelefonistkaCommand checks the comment has a "/telefonistka ..." line, other comments don't expect an answer
+func hasTelefonistkaCommand(body string) bool {
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "/telefonistka") {
+			return true
+		}
+	}
+	return false
+}
+
+func maintenanceComment(state maintenance.State) string {
+	comment := fmt.Sprintf("🚧 Telefonistka is in maintenance mode(read-only) since %s", state.Since.UTC().Format(time.RFC3339))
+	if state.Repo != "" {
+		comment = fmt.Sprintf("🚧 Telefonistka is paused for this repo since %s", state.Since.UTC().Format(time.RFC3339))
+	}
+	if state.Reason != "" {
+		comment += ": " + state.Reason
+	}
+	return comment + ".\nThis event wasn't handled, push a commit(or repeat the command) once the maintenance is over.\n"
+}
+
+// handleEventInMaintenance drops an event received during maintenance(or while its repo is paused), PR changes and Telefonistka commands get a comment explaining it
+func handleEventInMaintenance(ctx context.Context, state maintenance.State, eventType string, eventPayloadInterface interface{}, mainGhClientCache *lru.Cache[string, GhClientPair]) {
+	var owner, repo string
+	var prNumber int
+	switch eventPayload := eventPayloadInterface.(type) {
+	case *github.PullRequestEvent:
+		if _, ok := eventToHandle(eventPayload); ok {
+			owner, repo, prNumber = eventPayload.Repo.GetOwner().GetLogin(), eventPayload.Repo.GetName(), eventPayload.PullRequest.GetNumber()
+		}
+	case *github.IssueCommentEvent:
+		// Comments of bots(including this one) are ignored so the maintenance comment isn't answered
+		if eventPayload.GetAction() == "created" && eventPayload.Sender.GetType() != "Bot" && hasTelefonistkaCommand(eventPayload.Comment.GetBody()) {
+			owner, repo, prNumber = eventPayload.Repo.GetOwner().GetLogin(), eventPayload.Repo.GetName(), eventPayload.Issue.GetNumber()
+		}
+	}
+	maintenance.SkipRepo(eventRepoSlug(eventPayloadInterface), eventType+"_event")
+	if prNumber == 0 {
+		return
+	}
+	var mainGithubClientPair GhClientPair
+	mainGithubClientPair.GetAndCache(mainGhClientCache, MainCredentialEnvVars(ctx), owner, ctx)
+	ghPrClientDetails := GhPrClientDetails{
+		Ctx:          ctx,
+		GhClientPair: &mainGithubClientPair,
+		Owner:        owner,
+		Repo:         repo,
+		PrNumber:     prNumber,
+		PrLogger:     logging.ForRepo(owner + "/" + repo).WithFields(log.Fields{"prNumber": prNumber, "event_type": "maintenance"}),
+	}
+	_ = commentPR(ghPrClientDetails, maintenanceComment(state))
+}
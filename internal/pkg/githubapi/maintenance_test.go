@@ -0,0 +1,40 @@
+package githubapi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/maintenance"
+)
+
+func TestHasTelefonistkaCommand(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		body string
+		want bool
+	}{
+		"Sync command":       {body: "/telefonistka sync env/prod/c1", want: true},
+		"Command after text": {body: "Please deploy\n  /telefonistka approve", want: true},
+		"Plain comment":      {body: "LGTM", want: false},
+		"Mention":            {body: "ask telefonistka /telefonistka", want: false},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.want, hasTelefonistkaCommand(tc.body))
+		})
+	}
+}
+
+func TestMaintenanceComment(t *testing.T) {
+	t.Parallel()
+	since := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, "🚧 Telefonistka is in maintenance mode(read-only) since 2024-05-01T10:00:00Z: ArgoCD upgrade.\nThis event wasn't handled, push a commit(or repeat the command) once the maintenance is over.\n",
+		maintenanceComment(maintenance.State{Enabled: true, Reason: "ArgoCD upgrade", Since: since}))
+	assert.Equal(t, "🚧 Telefonistka is in maintenance mode(read-only) since 2024-05-01T10:00:00Z.\nThis event wasn't handled, push a commit(or repeat the command) once the maintenance is over.\n",
+		maintenanceComment(maintenance.State{Enabled: true, Since: since}))
+	assert.Equal(t, "🚧 Telefonistka is paused for this repo since 2024-05-01T10:00:00Z: migration.\nThis event wasn't handled, push a commit(or repeat the command) once the maintenance is over.\n",
+		maintenanceComment(maintenance.State{Enabled: true, Reason: "migration", Since: since, Repo: "owner/repo"}))
+}
@@ -0,0 +1,224 @@
+package githubapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+	yaml3 "gopkg.in/yaml.v3"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// knownManifestTypes are strictly decoded, so misspelled or misplaced fields are reported, other kinds only get a structural check
+var knownManifestTypes = map[string]func() any{
+	"v1/ConfigMap":                                    func() any { return &v1.ConfigMap{} },
+	"v1/Secret":                                       func() any { return &v1.Secret{} },
+	"v1/Service":                                      func() any { return &v1.Service{} },
+	"v1/ServiceAccount":                               func() any { return &v1.ServiceAccount{} },
+	"v1/Namespace":                                    func() any { return &v1.Namespace{} },
+	"v1/Pod":                                          func() any { return &v1.Pod{} },
+	"v1/PersistentVolumeClaim":                        func() any { return &v1.PersistentVolumeClaim{} },
+	"apps/v1/Deployment":                              func() any { return &appsv1.Deployment{} },
+	"apps/v1/StatefulSet":                             func() any { return &appsv1.StatefulSet{} },
+	"apps/v1/DaemonSet":                               func() any { return &appsv1.DaemonSet{} },
+	"batch/v1/Job":                                    func() any { return &batchv1.Job{} },
+	"batch/v1/CronJob":                                func() any { return &batchv1.CronJob{} },
+	"networking.k8s.io/v1/Ingress":                    func() any { return &networkingv1.Ingress{} },
+	"networking.k8s.io/v1/NetworkPolicy":              func() any { return &networkingv1.NetworkPolicy{} },
+	"policy/v1/PodDisruptionBudget":                   func() any { return &policyv1.PodDisruptionBudget{} },
+	"rbac.authorization.k8s.io/v1/Role":               func() any { return &rbacv1.Role{} },
+	"rbac.authorization.k8s.io/v1/RoleBinding":        func() any { return &rbacv1.RoleBinding{} },
+	"rbac.authorization.k8s.io/v1/ClusterRole":        func() any { return &rbacv1.ClusterRole{} },
+	"rbac.authorization.k8s.io/v1/ClusterRoleBinding": func() any { return &rbacv1.ClusterRoleBinding{} },
+	"autoscaling/v2/HorizontalPodAutoscaler":          func() any { return &autoscalingv2.HorizontalPodAutoscaler{} },
+}
+
+// kustomizeKinds are allowed to omit apiVersion and metadata
+var kustomizeKinds = map[string]bool{
+	"Kustomization": true,
+	"Component":     true,
+}
+
+// validateManifest returns the problems found in the YAML documents of a file, documents without a kind(e.g. Helm values) are only checked for YAML syntax
+func validateManifest(content []byte) []string {
+	problems := []string{}
+	decoder := yaml3.NewDecoder(bytes.NewReader(content))
+	for docIndex := 1; ; docIndex++ {
+		var doc any
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// The decoder can't recover from syntax errors, so the rest of the file isn't checked
+			problems = append(problems, fmt.Sprintf("document %d: invalid YAML: %s", docIndex, err))
+			break
+		}
+		obj, ok := doc.(map[string]any)
+		if !ok {
+			continue
+		}
+		kind, hasKind := obj["kind"]
+		if !hasKind {
+			continue
+		}
+		kindName, _ := kind.(string)
+		if kindName == "" {
+			problems = append(problems, fmt.Sprintf("document %d: kind should be a non empty string", docIndex))
+			continue
+		}
+		if kustomizeKinds[kindName] {
+			continue
+		}
+		apiVersion, _ := obj["apiVersion"].(string)
+		if apiVersion == "" {
+			problems = append(problems, fmt.Sprintf("document %d: %s has no apiVersion", docIndex, kindName))
+			continue
+		}
+		metadata, _ := obj["metadata"].(map[string]any)
+		if name, _ := metadata["name"].(string); name == "" {
+			if generateName, _ := metadata["generateName"].(string); generateName == "" {
+				problems = append(problems, fmt.Sprintf("document %d: %s has no metadata.name", docIndex, kindName))
+			}
+		}
+		newObject, known := knownManifestTypes[apiVersion+"/"+kindName]
+		if !known {
+			continue
+		}
+		jsonDoc, err := json.Marshal(obj)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("document %d: %s %s", docIndex, kindName, err))
+			continue
+		}
+		jsonDecoder := json.NewDecoder(bytes.NewReader(jsonDoc))
+		jsonDecoder.DisallowUnknownFields()
+		if err := jsonDecoder.Decode(newObject()); err != nil {
+			problems = append(problems, fmt.Sprintf("document %d: %s %s", docIndex, kindName, strings.TrimPrefix(err.Error(), "json: ")))
+		}
+	}
+	return problems
+}
+
+// isManifestFileToValidate selects the changed YAML files under the component paths, Helm templates aren't valid YAML before rendering so they are skipped
+func isManifestFileToValidate(fileName string, componentPaths []string, ignoreFileRegexes []string) bool {
+	if ext := path.Ext(fileName); ext != ".yaml" && ext != ".yml" {
+		return false
+	}
+	if strings.Contains(fileName, "/templates/") {
+		return false
+	}
+	inComponent := false
+	for _, componentPath := range componentPaths {
+		if strings.HasPrefix(fileName, strings.TrimSuffix(componentPath, "/")+"/") {
+			inComponent = true
+			break
+		}
+	}
+	if !inComponent {
+		return false
+	}
+	for _, ignoreRegex := range ignoreFileRegexes {
+		if match, _ := regexp.MatchString(ignoreRegex, fileName); match {
+			return false
+		}
+	}
+	return true
+}
+
+// validateChangedManifests validates the YAML files changed in the PR under componentPaths, the result maps file names to their problems
+func validateChangedManifests(ghPrClientDetails GhPrClientDetails, validationConfig cfg.ManifestValidationConfig, componentPaths []string) (map[string][]string, error) {
+	changedFiles, err := listPrFiles(ghPrClientDetails)
+	if err != nil {
+		return nil, err
+	}
+	invalidFiles := map[string][]string{}
+	for _, fileName := range changedFiles {
+		if !isManifestFileToValidate(fileName, componentPaths, validationConfig.IgnoreFileRegexes) {
+			continue
+		}
+		content, _, err := GetFileContent(ghPrClientDetails, ghPrClientDetails.Ref, fileName)
+		if errors.Is(err, ErrNotFound) {
+			// Deleted by the PR
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("get %s content: %w", fileName, err)
+		}
+		if strings.Contains(content, "{{") {
+			continue
+		}
+		if problems := validateManifest([]byte(content)); len(problems) > 0 {
+			invalidFiles[fileName] = problems
+			ghPrClientDetails.annotations.annotateInvalidManifest(fileName, []byte(content), problems)
+		}
+	}
+	return invalidFiles, nil
+}
+
+func manifestValidationComment(invalidFiles map[string][]string) string {
+	fileNames := make([]string, 0, len(invalidFiles))
+	for fileName := range invalidFiles {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+	var sb strings.Builder
+	sb.WriteString("Telefonistka found problems in the Kubernetes manifests changed by this PR, ArgoCD is likely to fail applying them:\n")
+	for _, fileName := range fileNames {
+		fmt.Fprintf(&sb, "\n`%s`:\n", fileName)
+		for _, problem := range invalidFiles[fileName] {
+			fmt.Fprintf(&sb, "* %s\n", problem)
+		}
+	}
+	return sb.String()
+}
+
+// checkChangedManifests reports invalid manifests as a PR comment(unless check-run annotations replace it) and the optional commit statuses
+func checkChangedManifests(ghPrClientDetails GhPrClientDetails, config *cfg.Config, componentPaths []string) error {
+	invalidFiles, err := validateChangedManifests(ghPrClientDetails, config.ManifestValidation, componentPaths)
+	if err != nil {
+		if config.CommitStatusContexts.Policy != "" {
+			setFeatureCommitStatus(ghPrClientDetails, config.CommitStatusContexts.Policy, "error", "Validating the changed manifests failed")
+		}
+		return err
+	}
+	ghPrClientDetails.summary.recordCheck("Manifest validation", len(invalidFiles) == 0, fmt.Sprintf("%d invalid manifest files", len(invalidFiles)))
+	if config.ManifestValidation.CommitStatusContext != "" {
+		setManifestValidationCommitStatus(ghPrClientDetails, config.ManifestValidation.CommitStatusContext, len(invalidFiles))
+	}
+	if config.CommitStatusContexts.Policy != "" {
+		setManifestValidationCommitStatus(ghPrClientDetails, config.CommitStatusContexts.Policy, len(invalidFiles))
+	}
+	if len(invalidFiles) == 0 {
+		return nil
+	}
+	ghPrClientDetails.PrLogger.Infof("Found %d invalid manifest files", len(invalidFiles))
+	if config.CheckRunAnnotations.Enabled && config.CheckRunAnnotations.ReplaceComments {
+		return nil
+	}
+	err = commentPR(ghPrClientDetails, manifestValidationComment(invalidFiles))
+	if err != nil {
+		return fmt.Errorf("commenting on PR: %w", err)
+	}
+	return nil
+}
+
+func setManifestValidationCommitStatus(ghPrClientDetails GhPrClientDetails, statusContext string, invalidFileCount int) {
+	if invalidFileCount > 0 {
+		setFeatureCommitStatus(ghPrClientDetails, statusContext, "failure", fmt.Sprintf("%d changed manifest files are invalid", invalidFileCount))
+		return
+	}
+	setFeatureCommitStatus(ghPrClientDetails, statusContext, "success", "Changed Kubernetes manifests are valid")
+}
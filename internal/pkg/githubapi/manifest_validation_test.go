@@ -0,0 +1,124 @@
+package githubapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateManifest(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		content  string
+		expected []string
+	}{
+		"valid deployment and unknown kind": {
+			content: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  replicas: 2
+  selector:
+    matchLabels:
+      app: app
+  template:
+    metadata:
+      labels:
+        app: app
+    spec:
+      containers:
+      - name: app
+        image: app:1.0
+        resources:
+          limits:
+            cpu: 500m
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: widget
+spec:
+  anything: goes
+`,
+			expected: []string{},
+		},
+		"helm values and kustomization are skipped": {
+			content: `image:
+  tag: "1.0"
+---
+resources:
+- deployment.yaml
+kind: Kustomization
+`,
+			expected: []string{},
+		},
+		"misspelled field": {
+			content: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      contianers:
+      - name: app
+`,
+			expected: []string{`document 1: Deployment unknown field "contianers"`},
+		},
+		"missing apiVersion and name": {
+			content: `kind: ConfigMap
+data:
+  a: b
+---
+apiVersion: v1
+kind: ConfigMap
+data:
+  a: b
+`,
+			expected: []string{
+				"document 1: ConfigMap has no apiVersion",
+				"document 2: ConfigMap has no metadata.name",
+			},
+		},
+		"invalid YAML": {
+			content: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+ data: b
+`,
+			expected: []string{"document 1: invalid YAML: yaml: line 4: did not find expected key"},
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, validateManifest([]byte(tc.content)))
+		})
+	}
+}
+
+func TestIsManifestFileToValidate(t *testing.T) {
+	t.Parallel()
+	componentPaths := []string{"clusters/prod/c1/app"}
+	tests := map[string]struct {
+		fileName string
+		expected bool
+	}{
+		"manifest in component":    {fileName: "clusters/prod/c1/app/deployment.yaml", expected: true},
+		"yml extension":            {fileName: "clusters/prod/c1/app/service.yml", expected: true},
+		"not YAML":                 {fileName: "clusters/prod/c1/app/README.md", expected: false},
+		"other component":          {fileName: "clusters/prod/c1/app2/deployment.yaml", expected: false},
+		"helm template":            {fileName: "clusters/prod/c1/app/templates/deployment.yaml", expected: false},
+		"ignored by configuration": {fileName: "clusters/prod/c1/app/values.yaml", expected: false},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, isManifestFileToValidate(tc.fileName, componentPaths, []string{`/values\.yaml$`}))
+		})
+	}
+}
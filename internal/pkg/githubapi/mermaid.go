@@ -0,0 +1,118 @@
+package githubapi
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// mermaidGraph builds a Mermaid flowchart, GitHub renders ```mermaid code blocks as diagrams
+type mermaidGraph struct {
+	nodeIDs map[string]string
+	lines   []string
+}
+
+func newMermaidGraph() *mermaidGraph {
+	return &mermaidGraph{nodeIDs: map[string]string{}}
+}
+
+// node returns the ID of the node with the label, paths can contain characters Mermaid doesn't allow in IDs so labels are quoted
+func (g *mermaidGraph) node(label string) string {
+	if id, ok := g.nodeIDs[label]; ok {
+		return id
+	}
+	id := fmt.Sprintf("n%d", len(g.nodeIDs))
+	g.nodeIDs[label] = id
+	g.lines = append(g.lines, fmt.Sprintf("    %s[\"%s\"]", id, mermaidEscape(label)))
+	return id
+}
+
+func (g *mermaidGraph) edge(from string, to string, label string, dotted bool) {
+	arrow := "-->"
+	if dotted {
+		arrow = "-.->"
+	}
+	if label != "" {
+		arrow += "|\"" + mermaidEscape(label) + "\"|"
+	}
+	g.lines = append(g.lines, fmt.Sprintf("    %s %s %s", g.node(from), arrow, g.node(to)))
+}
+
+func (g *mermaidGraph) String() string {
+	if len(g.lines) == 0 {
+		return ""
+	}
+	return "```mermaid\nflowchart LR\n" + strings.Join(g.lines, "\n") + "\n```\n"
+}
+
+func mermaidEscape(s string) string {
+	return strings.ReplaceAll(s, `"`, "#quot;")
+}
+
+// promotionChainMermaidGraph renders the promotion chain persisted in the PR metadata, from the original PR to the current promotion.
+// Each promotion step is linked to the targets of the previous one that its source path matches
+func promotionChainMermaidGraph(keys []int, newPrMetadata prMetadata, promotionSkipPaths map[string]bool) string {
+	g := newMermaidGraph()
+	var previousTargets []string
+	previousSource := ""
+	for _, k := range keys {
+		sourcePath := newPrMetadata.PreviousPromotionMetadata[k].SourcePath
+		targetPaths := filterSkipPaths(newPrMetadata.PreviousPromotionMetadata[k].TargetPaths, promotionSkipPaths)
+		sort.Strings(targetPaths)
+
+		if previousSource != "" {
+			linked := false
+			if sourceRegex, err := regexp.Compile(sourcePath); err == nil {
+				for _, previousTarget := range previousTargets {
+					if previousTarget != sourcePath && sourceRegex.MatchString(previousTarget) {
+						g.edge(previousTarget, sourcePath, "", true)
+						linked = true
+					}
+				}
+			}
+			if !linked && !slices.Contains(previousTargets, sourcePath) {
+				g.edge(previousSource, sourcePath, "", true)
+			}
+		}
+		for _, targetPath := range targetPaths {
+			g.edge(sourcePath, targetPath, fmt.Sprintf("PR #%d", k), false)
+		}
+		previousSource = sourcePath
+		previousTargets = targetPaths
+	}
+	return g.String()
+}
+
+// promotionPlanMermaidGraph renders the promotion PRs that would be opened, from the changed components to their targets
+func promotionPlanMermaidGraph(promotions map[string]PromotionInstance) string {
+	g := newMermaidGraph()
+	promotionKeys := make([]string, 0, len(promotions))
+	for key := range promotions {
+		promotionKeys = append(promotionKeys, key)
+	}
+	sort.Strings(promotionKeys)
+	for _, key := range promotionKeys {
+		promotion := promotions[key]
+		targets := make([]string, 0, len(promotion.ComputedSyncPaths))
+		for target := range promotion.ComputedSyncPaths {
+			targets = append(targets, target)
+		}
+		sort.Strings(targets)
+		for _, target := range targets {
+			g.edge(promotion.ComputedSyncPaths[target], target, promotion.Metadata.TargetDescription, false)
+		}
+		components := make([]string, 0, len(promotion.Metadata.PerComponentSkippedTargetPaths))
+		for component := range promotion.Metadata.PerComponentSkippedTargetPaths {
+			components = append(components, component)
+		}
+		sort.Strings(components)
+		for _, component := range components {
+			for _, skippedTarget := range promotion.Metadata.PerComponentSkippedTargetPaths[component] {
+				g.edge(strings.TrimSuffix(promotion.Metadata.SourcePath, "/")+"/"+component, skippedTarget, "skipped", true)
+			}
+		}
+	}
+	return g.String()
+}
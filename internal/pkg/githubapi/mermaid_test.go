@@ -0,0 +1,63 @@
+package githubapi
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPromotionChainMermaidGraph(t *testing.T) {
+	t.Parallel()
+	newPrMetadata := prMetadata{
+		PreviousPromotionMetadata: map[int]promotionInstanceMetaData{
+			1: {
+				SourcePath:  "env/dev/",
+				TargetPaths: []string{"env/staging/us-east4/c1", "env/staging/europe-west4/c1"},
+			},
+			2: {
+				SourcePath:  "env/staging/us-east4/",
+				TargetPaths: []string{"env/prod/us-east4/c1", "env/prod/us-west1/c1"},
+			},
+			3: {
+				SourcePath:  "env/prod/canary/",
+				TargetPaths: []string{"env/prod/\"quoted\"/c1", "env/prod/skipped/c1"},
+			},
+		},
+	}
+	graph := promotionChainMermaidGraph([]int{1, 2, 3}, newPrMetadata, map[string]bool{"env/prod/skipped/c1": true})
+	expectedGraph, err := os.ReadFile("testdata/promotion_chain.golden.md")
+	if err != nil {
+		t.Fatalf("Error loading golden file: %s", err)
+	}
+	assert.Equal(t, string(expectedGraph), graph)
+}
+
+func TestPromotionPlanMermaidGraph(t *testing.T) {
+	t.Parallel()
+	promotions := map[string]PromotionInstance{
+		"env/staging/": {
+			Metadata: PromotionInstanceMetaData{
+				SourcePath:                     "env/staging/",
+				TargetDescription:              "prod",
+				PerComponentSkippedTargetPaths: map[string][]string{"c2": {"env/prod/us-west1/c2"}},
+			},
+			ComputedSyncPaths: map[string]string{
+				"env/prod/us-west1/c1": "env/staging/c1",
+				"env/prod/us-east4/c1": "env/staging/c1",
+			},
+		},
+	}
+	expected := "```mermaid\nflowchart LR\n" +
+		"    n0[\"env/staging/c1\"]\n" +
+		"    n1[\"env/prod/us-east4/c1\"]\n" +
+		"    n0 -->|\"prod\"| n1\n" +
+		"    n2[\"env/prod/us-west1/c1\"]\n" +
+		"    n0 -->|\"prod\"| n2\n" +
+		"    n3[\"env/staging/c2\"]\n" +
+		"    n4[\"env/prod/us-west1/c2\"]\n" +
+		"    n3 -.->|\"skipped\"| n4\n" +
+		"```\n"
+	assert.Equal(t, expected, promotionPlanMermaidGraph(promotions))
+	assert.Equal(t, "", promotionPlanMermaidGraph(map[string]PromotionInstance{}))
+}
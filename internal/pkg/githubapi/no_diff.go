@@ -0,0 +1,32 @@
+package githubapi
+
+import (
+	"github.com/google/go-github/v62/github"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+)
+
+const defaultNoDiffLabel = "noop"
+
+func noDiffLabel(noDiffConfig cfg.NoDiffConfig) string {
+	if noDiffConfig.Label != "" {
+		return noDiffConfig.Label
+	}
+	return defaultNoDiffLabel
+}
+
+// setNoDiffCommitStatus marks the PR head commit as not changing the target clusters, CI can use it to skip expensive steps
+func setNoDiffCommitStatus(ghPrClientDetails GhPrClientDetails, statusContext string) {
+	setFeatureCommitStatus(ghPrClientDetails, statusContext, "success", "ArgoCD diff is empty, this PR will not change cluster state")
+}
+
+// closeNoDiffPr closes a PR that won't change the target clusters, its branch is kept as the PR wasn't opened by Telefonistka
+func closeNoDiffPr(ghPrClientDetails GhPrClientDetails) error {
+	err := ghPrClientDetails.CommentOnPr("Closing this PR, the ArgoCD diff is empty so it will not change cluster state. Reopen it if it should be merged anyway.")
+	if err != nil {
+		return err
+	}
+	_, _, err = retryGhWrite(ghPrClientDetails.Ctx, "edit_pr", func() (*github.PullRequest, *github.Response, error) {
+		return ghPrClientDetails.GhClientPair.v3Client.PullRequests.Edit(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, ghPrClientDetails.PrNumber, &github.PullRequest{State: github.String("closed")})
+	})
+	return err
+}
@@ -0,0 +1,75 @@
+package githubapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+)
+
+func TestNoDiffLabel(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		config   cfg.NoDiffConfig
+		expected string
+	}{
+		"default": {
+			config:   cfg.NoDiffConfig{},
+			expected: "noop",
+		},
+		"configured": {
+			config:   cfg.NoDiffConfig{Label: "no-cluster-changes"},
+			expected: "no-cluster-changes",
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, noDiffLabel(tc.config))
+		})
+	}
+}
+
+func TestCloseNoDiffPr(t *testing.T) {
+	t.Parallel()
+	var editedPr github.PullRequest
+	var comment github.IssueComment
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.PostReposIssuesCommentsByOwnerByRepoByIssueNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/repos/AnOwner/Arepo/issues/7/comments", r.URL.Path)
+				_ = json.NewDecoder(r.Body).Decode(&comment)
+				_, _ = w.Write(mock.MustMarshal(comment))
+			}),
+		),
+		mock.WithRequestMatchHandler(
+			mock.PatchReposPullsByOwnerByRepoByPullNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/repos/AnOwner/Arepo/pulls/7", r.URL.Path)
+				_ = json.NewDecoder(r.Body).Decode(&editedPr)
+				_, _ = w.Write(mock.MustMarshal(editedPr))
+			}),
+		),
+	)
+	details := GhPrClientDetails{
+		Ctx:          context.Background(),
+		GhClientPair: &GhClientPair{v3Client: github.NewClient(mockedHTTPClient)},
+		Owner:        "AnOwner",
+		Repo:         "Arepo",
+		PrNumber:     7,
+		PrLogger:     log.WithFields(log.Fields{"repo": "AnOwner/Arepo"}),
+	}
+
+	err := closeNoDiffPr(details)
+	assert.NoError(t, err)
+	assert.Contains(t, comment.GetBody(), "diff is empty")
+	assert.Equal(t, "closed", editedPr.GetState())
+}
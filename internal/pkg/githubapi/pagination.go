@@ -0,0 +1,38 @@
+package githubapi
+
+import (
+	"github.com/google/go-github/v62/github"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+)
+
+// The largest page size of the GitHub list APIs, bigger pages mean fewer calls
+const githubListMaxPageSize = 100
+
+// forEachPage calls list for each page of a GitHub list API until the last page, or until handle returns false(e.g. it found what it looked for).
+// opts is the ListOptions of the options list uses, its Page is set before each call and its PerPage defaults to the max. The last response is returned, errors are classified(see GhError)
+func forEachPage[T any](opts *github.ListOptions, list func() ([]T, *github.Response, error), handle func([]T) bool) (*github.Response, error) {
+	if opts.PerPage == 0 {
+		opts.PerPage = githubListMaxPageSize
+	}
+	for {
+		items, resp, err := list()
+		prom.InstrumentGhCall(resp)
+		if err != nil {
+			return resp, classifyGhError(resp, err)
+		}
+		if !handle(items) || resp.NextPage == 0 {
+			return resp, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// listAllPages returns the items of all the pages of a GitHub list API, see forEachPage
+func listAllPages[T any](opts *github.ListOptions, list func() ([]T, *github.Response, error)) ([]T, *github.Response, error) {
+	var all []T
+	resp, err := forEachPage(opts, list, func(items []T) bool {
+		all = append(all, items...)
+		return true
+	})
+	return all, resp, err
+}
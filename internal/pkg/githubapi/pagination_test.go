@@ -0,0 +1,60 @@
+package githubapi
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePages returns a list func serving pages(1 based, like the GitHub API) of the items, the page it got is read from opts
+func fakePages(opts *github.ListOptions, pages [][]int, requestedPages *[]int) func() ([]int, *github.Response, error) {
+	return func() ([]int, *github.Response, error) {
+		page := max(opts.Page, 1)
+		*requestedPages = append(*requestedPages, page)
+		resp := &github.Response{}
+		if page < len(pages) {
+			resp.NextPage = page + 1
+		}
+		return pages[page-1], resp, nil
+	}
+}
+
+func TestListAllPages(t *testing.T) {
+	t.Parallel()
+	opts := &github.ListOptions{}
+	requestedPages := []int{}
+	items, _, err := listAllPages(opts, fakePages(opts, [][]int{{1, 2}, {3, 4}, {5}}, &requestedPages))
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, items)
+	assert.Equal(t, []int{1, 2, 3}, requestedPages)
+	assert.Equal(t, githubListMaxPageSize, opts.PerPage, "the max page size is used by default")
+}
+
+func TestForEachPageStopsWhenHandled(t *testing.T) {
+	t.Parallel()
+	opts := &github.ListOptions{PerPage: 2}
+	requestedPages := []int{}
+	_, err := forEachPage(opts, fakePages(opts, [][]int{{1, 2}, {3, 4}, {5}}, &requestedPages), func(items []int) bool {
+		return !assert.ObjectsAreEqual([]int{3, 4}, items)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, requestedPages, "pages after the one with the searched item aren't requested")
+	assert.Equal(t, 2, opts.PerPage)
+}
+
+func TestListAllPagesError(t *testing.T) {
+	t.Parallel()
+	opts := &github.ListOptions{}
+	calls := 0
+	_, _, err := listAllPages(opts, func() ([]int, *github.Response, error) {
+		calls++
+		if calls == 2 {
+			return nil, nil, errors.New("rate limited")
+		}
+		return []int{calls}, &github.Response{NextPage: calls + 1}, nil
+	})
+	assert.EqualError(t, err, "rate limited")
+	assert.Equal(t, 2, calls)
+}
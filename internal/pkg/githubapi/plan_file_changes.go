@@ -0,0 +1,92 @@
+package githubapi
+
+import (
+	"sort"
+
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+)
+
+// FileChangeSummary lists the files a promotion would add, modify or delete under a target path, relative to it
+type FileChangeSummary struct {
+	Added    []string
+	Modified []string
+	Deleted  []string
+}
+
+func (s FileChangeSummary) IsEmpty() bool {
+	return len(s.Added) == 0 && len(s.Modified) == 0 && len(s.Deleted) == 0
+}
+
+// listTreeFiles returns the blob SHAs of all the files under a git tree object, keyed by their path relative to it.
+// An empty treeSHA(missing directory) has no files
+func listTreeFiles(ghPrClientDetails GhPrClientDetails, treeSHA string) (map[string]string, error) {
+	files := map[string]string{}
+	if treeSHA == "" {
+		return files, nil
+	}
+	tree, resp, err := ghPrClientDetails.GhClientPair.v3Client.Git.GetTree(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, treeSHA, true)
+	prom.InstrumentGhCall(resp)
+	if err != nil {
+		return nil, err
+	}
+	if tree.GetTruncated() {
+		ghPrClientDetails.PrLogger.Warnf("Git tree %s is too large and was truncated, the file change summary is partial", treeSHA)
+	}
+	for _, entry := range tree.Entries {
+		if entry.GetType() == "blob" {
+			files[entry.GetPath()] = entry.GetSHA()
+		}
+	}
+	return files, nil
+}
+
+func compareFileMaps(sourceFiles map[string]string, targetFiles map[string]string) FileChangeSummary {
+	summary := FileChangeSummary{}
+	for file, sourceSHA := range sourceFiles {
+		targetSHA, found := targetFiles[file]
+		if !found {
+			summary.Added = append(summary.Added, file)
+		} else if targetSHA != sourceSHA {
+			summary.Modified = append(summary.Modified, file)
+		}
+	}
+	for file := range targetFiles {
+		if _, found := sourceFiles[file]; !found {
+			summary.Deleted = append(summary.Deleted, file)
+		}
+	}
+	sort.Strings(summary.Added)
+	sort.Strings(summary.Modified)
+	sort.Strings(summary.Deleted)
+	return summary
+}
+
+// generatePromotionFileChanges compares each source path(as found in sourceBranch) with its target path(as found in targetBranch)
+// and sets the FileChanges of the promotions, so the plan shows the blast radius of each promotion and not only the path mapping
+func generatePromotionFileChanges(ghPrClientDetails GhPrClientDetails, promotions map[string]PromotionInstance, sourceBranch string, targetBranch string) {
+	for key, promotion := range promotions {
+		promotion.FileChanges = map[string]FileChangeSummary{}
+		for target, source := range promotion.ComputedSyncPaths {
+			sourceFiles, err := getDirectoryFiles(ghPrClientDetails, source, sourceBranch)
+			if err != nil {
+				ghPrClientDetails.PrLogger.Warnf("Failed to list files of %s: err=%v", source, err)
+				continue
+			}
+			targetFiles, err := getDirectoryFiles(ghPrClientDetails, target, targetBranch)
+			if err != nil {
+				ghPrClientDetails.PrLogger.Warnf("Failed to list files of %s: err=%v", target, err)
+				continue
+			}
+			promotion.FileChanges[target] = compareFileMaps(sourceFiles, targetFiles)
+		}
+		promotions[key] = promotion
+	}
+}
+
+func getDirectoryFiles(ghPrClientDetails GhPrClientDetails, dirPath string, branch string) (map[string]string, error) {
+	treeSHA, err := getDirecotyGitObjectSha(ghPrClientDetails, dirPath, branch)
+	if err != nil {
+		return nil, err
+	}
+	return listTreeFiles(ghPrClientDetails, treeSHA)
+}
@@ -0,0 +1,107 @@
+package githubapi
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareFileMaps(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		sourceFiles map[string]string
+		targetFiles map[string]string
+		expected    FileChangeSummary
+	}{
+		"Identical": {
+			sourceFiles: map[string]string{"values.yaml": "a"},
+			targetFiles: map[string]string{"values.yaml": "a"},
+			expected:    FileChangeSummary{},
+		},
+		"Mixed changes": {
+			sourceFiles: map[string]string{"values.yaml": "b", "templates/new.yaml": "c", "Chart.yaml": "d"},
+			targetFiles: map[string]string{"values.yaml": "a", "templates/old.yaml": "e", "Chart.yaml": "d"},
+			expected: FileChangeSummary{
+				Added:    []string{"templates/new.yaml"},
+				Modified: []string{"values.yaml"},
+				Deleted:  []string{"templates/old.yaml"},
+			},
+		},
+		"New target": {
+			sourceFiles: map[string]string{"values.yaml": "a", "Chart.yaml": "d"},
+			targetFiles: map[string]string{},
+			expected:    FileChangeSummary{Added: []string{"Chart.yaml", "values.yaml"}},
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			summary := compareFileMaps(tc.sourceFiles, tc.targetFiles)
+			assert.Equal(t, tc.expected, summary)
+			assert.Equal(t, tc.expected.IsEmpty(), summary.IsEmpty())
+		})
+	}
+}
+
+func TestListTreeFiles(t *testing.T) {
+	t.Parallel()
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposGitTreesByOwnerByRepoByTreeSha,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/repos/AnOwner/Arepo/git/trees/treesha", r.URL.Path)
+				assert.Equal(t, "1", r.URL.Query().Get("recursive"))
+				_, _ = w.Write(mock.MustMarshal(github.Tree{
+					SHA: github.String("treesha"),
+					Entries: []*github.TreeEntry{
+						{Path: github.String("values.yaml"), Type: github.String("blob"), SHA: github.String("a")},
+						{Path: github.String("templates"), Type: github.String("tree"), SHA: github.String("b")},
+						{Path: github.String("templates/deployment.yaml"), Type: github.String("blob"), SHA: github.String("c")},
+					},
+				}))
+			}),
+		),
+	)
+	details := GhPrClientDetails{
+		Ctx:          context.Background(),
+		GhClientPair: &GhClientPair{v3Client: github.NewClient(mockedHTTPClient)},
+		Owner:        "AnOwner",
+		Repo:         "Arepo",
+		PrLogger:     log.WithFields(log.Fields{"repo": "AnOwner/Arepo"}),
+	}
+
+	files, err := listTreeFiles(details, "treesha")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"values.yaml": "a", "templates/deployment.yaml": "c"}, files)
+
+	files, err = listTreeFiles(details, "")
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestDryRunTemplateFileChanges(t *testing.T) {
+	t.Parallel()
+	details := repoTemplateTestClientDetails(nil)
+	promotions := map[string]PromotionInstance{
+		"env/staging/>env/prod/": {
+			Metadata:          PromotionInstanceMetaData{SourcePath: "env/staging/", TargetPaths: []string{"env/prod/"}},
+			ComputedSyncPaths: map[string]string{"env/prod/c1": "env/staging/c1", "env/prod/c2": "env/staging/c2"},
+			FileChanges: map[string]FileChangeSummary{
+				"env/prod/c1": {Added: []string{"new.yaml"}, Modified: []string{"values.yaml"}},
+				"env/prod/c2": {},
+			},
+		},
+	}
+	output, err := executeTemplate("dryRunMsg", defaultTemplatesFullPath(details.Ctx, "dry-run-pr-comment.gotmpl"), promotions)
+	assert.NoError(t, err)
+	assert.Contains(t, output, "<code>env/prod/c1</code>: 1 added, 1 modified, 0 deleted")
+	assert.Contains(t, output, "* ➕ `new.yaml`")
+	assert.Contains(t, output, "* ✏️ `values.yaml`")
+	assert.Contains(t, output, "`env/prod/c2`: no changes")
+}
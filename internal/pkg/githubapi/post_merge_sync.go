@@ -0,0 +1,124 @@
+package githubapi
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/argocd"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/tenancy"
+)
+
+const (
+	postMergeSyncStatusContext = "telefonistka/argocd-sync"
+	postMergeSyncPollInterval  = 15 * time.Second
+	defaultPostMergeSyncWait   = 10 * time.Minute
+)
+
+func setMergeCommitStatus(ghPrClientDetails GhPrClientDetails, sha string, state string, description string) {
+	tcontext := postMergeSyncStatusContext
+	commitStatus := &github.RepoStatus{
+		Description: &description,
+		State:       &state,
+		Context:     &tcontext,
+	}
+	// The sync wait might have exhausted the context, the tenant and shadow mode of it still apply
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ghPrClientDetails.Ctx), time.Minute)
+	defer cancel()
+	_, resp, err := retryGhWrite(ctx, "create_status", func() (*github.RepoStatus, *github.Response, error) {
+		return ghPrClientDetails.GhClientPair.v3Client.Repositories.CreateStatus(ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, sha, commitStatus)
+	})
+	prom.IncCommitStatusUpdateCounter(ghPrClientDetails.Owner+"/"+ghPrClientDetails.Repo, state)
+	if err != nil {
+		ghPrClientDetails.PrLogger.Errorf("Failed to set merge commit status: err=%s\n%v", err, resp)
+	}
+}
+
+// componentsToSyncAfterMerge filters the changed components by the optional postMergeSync.pathRegex
+func componentsToSyncAfterMerge(componentPaths []string, pathRegex string) []string {
+	if pathRegex == "" {
+		return componentPaths
+	}
+	r, err := regexp.Compile(pathRegex)
+	if err != nil {
+		return nil
+	}
+	filtered := []string{}
+	for _, componentPath := range componentPaths {
+		if r.MatchString(componentPath) {
+			filtered = append(filtered, componentPath)
+		}
+	}
+	return filtered
+}
+
+// postMergeSync triggers a sync of the ArgoCD apps of the merged PR components and reports the outcome as a commit status on the merge commit and a PR comment.
+// It runs after the event handling is done, so it uses its own context.
+func postMergeSync(ghPrClientDetails GhPrClientDetails, config *cfg.Config, mergeCommitSHA string) {
+	waitTimeout := defaultPostMergeSyncWait
+	if config.Argocd.PostMergeSync.TimeoutMinutes > 0 {
+		waitTimeout = time.Duration(config.Argocd.PostMergeSync.TimeoutMinutes) * time.Minute
+	}
+	// The event context is canceled once the event handling returns, so this has to happen before any API call.
+	// The tenant and shadow mode of the event have to be carried over to the new context
+	ctx, cancel := context.WithTimeout(withShadowModeStateOf(tenancy.NewContext(context.Background(), tenancy.FromContext(ghPrClientDetails.Ctx)), ghPrClientDetails.Ctx), waitTimeout)
+	defer cancel()
+	ghPrClientDetails.Ctx = ctx
+
+	componentPaths, err := generateListOfChangedComponentPaths(ghPrClientDetails, config)
+	if err != nil {
+		ghPrClientDetails.PrLogger.Errorf("Failed to get list of changed components for post-merge sync: err=%s\n", err)
+		return
+	}
+	componentPaths = componentsToSyncAfterMerge(componentPaths, config.Argocd.PostMergeSync.PathRegex)
+	if len(componentPaths) == 0 {
+		return
+	}
+	if skipInShadowMode(ctx, "argocd_sync") {
+		commentShadowModeActions(ghPrClientDetails)
+		return
+	}
+
+	setMergeCommitStatus(ghPrClientDetails, mergeCommitSHA, "pending", "Syncing ArgoCD apps")
+
+	resultsChan := make(chan argocd.AppSyncResult)
+	for _, componentPath := range componentPaths {
+		go func(componentPath string) {
+			resultsChan <- argocd.SyncAndWaitForComponentApp(ctx, componentPath, ghPrClientDetails.RepoURL, config.Argocd.UseSHALabelForAppDiscovery, config.Argocd.PostMergeSync.Wait, postMergeSyncPollInterval)
+		}(componentPath)
+	}
+	success := true
+	results := []argocd.AppSyncResult{}
+	for range componentPaths {
+		result := <-resultsChan
+		if result.Err != nil {
+			ghPrClientDetails.PrLogger.Errorf("Post-merge sync of %s failed: err=%s", result.ComponentPath, result.Err)
+			success = false
+		}
+		results = append(results, result)
+	}
+
+	// The context might be exhausted by the wait, reporting should still happen
+	reportCtx, reportCancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+	defer reportCancel()
+	ghPrClientDetails.Ctx = reportCtx
+
+	if success {
+		setMergeCommitStatus(ghPrClientDetails, mergeCommitSHA, "success", "ArgoCD apps synced")
+	} else {
+		setMergeCommitStatus(ghPrClientDetails, mergeCommitSHA, "failure", "ArgoCD apps sync failed or timed out")
+	}
+	templateOutput, err := executeRepoTemplate(ghPrClientDetails, "postMergeSync", "post-merge-sync-comment.gotmpl", map[string]interface{}{
+		"success":        success,
+		"mergeCommitSHA": mergeCommitSHA,
+		"results":        results,
+	})
+	if err != nil {
+		ghPrClientDetails.PrLogger.Errorf("Failed to render post-merge sync comment: err=%s", err)
+		return
+	}
+	_ = commentPR(ghPrClientDetails, templateOutput)
+}
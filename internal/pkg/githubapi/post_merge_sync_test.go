@@ -0,0 +1,26 @@
+package githubapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComponentsToSyncAfterMerge(t *testing.T) {
+	t.Parallel()
+	componentPaths := []string{"env/prod/foo", "env/staging/foo"}
+	tests := map[string]struct {
+		pathRegex string
+		expected  []string
+	}{
+		"No regex syncs all components": {pathRegex: "", expected: componentPaths},
+		"Regex filters components":      {pathRegex: "^env/staging/.*", expected: []string{"env/staging/foo"}},
+		"Invalid regex syncs nothing":   {pathRegex: "(", expected: nil},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, componentsToSyncAfterMerge(componentPaths, tc.pathRegex))
+		})
+	}
+}
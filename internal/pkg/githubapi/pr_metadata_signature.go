@@ -0,0 +1,122 @@
+package githubapi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/secrets"
+)
+
+const prMetadataCommentPrefix = "<!--|Telefonistka data, do not delete|"
+
+var (
+	errPrMetadataTampered     = errors.New("PR metadata signature doesn't match its content, it was probably edited outside of Telefonistka")
+	errPrMetadataUnsigned     = errors.New("PR metadata is not signed")
+	errPrMetadataNoSigningKey = errors.New("PR_METADATA_SIGNING_KEY isn't set, promotion PR metadata can't be signed(set PR_METADATA_SIGNING_DISABLED to run without signing)")
+
+	// Matches both the legacy <!--|label|data|--> block and the signed <!--|label|data|signature|--> one
+	prMetadataRegex = regexp.MustCompile(`<!--\|[^|]*\|([^|]*)(?:\|([^|]*))?\|-->`)
+)
+
+// prMetadataSigningKey returns the key used to sign the metadata persisted in promotion PR bodies, it's empty when signing is disabled
+func prMetadataSigningKey() []byte {
+	if prMetadataSigningDisabled() {
+		return nil
+	}
+	return []byte(secrets.Get("PR_METADATA_SIGNING_KEY", ""))
+}
+
+// prMetadataSigningDisabled is true when PR_METADATA_SIGNING_DISABLED is set, the metadata is then neither signed nor verified
+func prMetadataSigningDisabled() bool {
+	disabled, _ := strconv.ParseBool(getEnv("PR_METADATA_SIGNING_DISABLED", "false"))
+	return disabled
+}
+
+// CheckPrMetadataSigningKey fails when PR_METADATA_SIGNING_KEY isn't set in any secret source and signing wasn't disabled, so the server doesn't start
+// with promotion PR metadata anyone able to edit a PR description can forge
+func CheckPrMetadataSigningKey() error {
+	if prMetadataSigningDisabled() || len(prMetadataSigningKey()) > 0 {
+		return nil
+	}
+	return errPrMetadataNoSigningKey
+}
+
+// prMetadataUnsignedAllowed is a migration flag for promotion PRs opened before signing was enabled(or before the signature covered their repo and branch),
+// deleting the signature is otherwise an easy way around it
+func prMetadataUnsignedAllowed() bool {
+	return getEnv("PR_METADATA_ALLOW_UNSIGNED", "false") == "true"
+}
+
+// prMetadataBinding is what the metadata is signed for, so a signed block copied to a PR of another repo or branch doesn't verify
+type prMetadataBinding struct {
+	repoSlug   string // owner/repo
+	headBranch string
+}
+
+func (ghPrClientDetails GhPrClientDetails) prMetadataBinding() prMetadataBinding {
+	return prMetadataBinding{repoSlug: ghPrClientDetails.Owner + "/" + ghPrClientDetails.Repo, headBranch: ghPrClientDetails.Ref}
+}
+
+func signPrMetadata(binding prMetadataBinding, serializedPrMetadata string, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	// Repo slugs are case insensitive, branch names aren't
+	mac.Write([]byte(strings.ToLower(binding.repoSlug) + "\n" + binding.headBranch + "\n" + serializedPrMetadata))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// signLegacyPrMetadata is the signature of the PRs opened before it covered their repo and branch
+func signLegacyPrMetadata(serializedPrMetadata string, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(serializedPrMetadata))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyPrMetadataSignature checks the signature of serialized PR metadata.
+// Unsigned metadata is rejected when a key is configured, unless allowUnsigned is set(e.g. for PRs opened before signing was enabled), which also accepts the legacy signatures.
+func verifyPrMetadataSignature(binding prMetadataBinding, serializedPrMetadata string, signature string, key []byte, allowUnsigned bool) error {
+	if len(key) == 0 {
+		return nil
+	}
+	if signature == "" {
+		if allowUnsigned {
+			return nil
+		}
+		return errPrMetadataUnsigned
+	}
+	if hmac.Equal([]byte(signPrMetadata(binding, serializedPrMetadata, key)), []byte(signature)) {
+		return nil
+	}
+	if allowUnsigned && hmac.Equal([]byte(signLegacyPrMetadata(serializedPrMetadata, key)), []byte(signature)) {
+		return nil
+	}
+	return errPrMetadataTampered
+}
+
+// prMetadataComment renders the hidden PR body block that persists the serialized metadata, signed for the PR of binding when a key is available.
+func prMetadataComment(binding prMetadataBinding, serializedPrMetadata string, key []byte) string {
+	if len(key) == 0 {
+		return prMetadataCommentPrefix + serializedPrMetadata + "|-->"
+	}
+	return prMetadataCommentPrefix + serializedPrMetadata + "|" + signPrMetadata(binding, serializedPrMetadata, key) + "|-->"
+}
+
+// parsePrMetadataComment extracts the serialized metadata and its signature(if any) from a PR body.
+func parsePrMetadataComment(prBody string) (serializedPrMetadata string, signature string, found bool) {
+	match := prMetadataRegex.FindStringSubmatch(prBody)
+	if len(match) != 3 || match[1] == "" {
+		return "", "", false
+	}
+	return match[1], match[2], true
+}
+
+func unverifiedPrMetadataReason(err error) string {
+	if errors.Is(err, errPrMetadataUnsigned) {
+		return "unsigned"
+	}
+	return "tampered"
+}
@@ -0,0 +1,152 @@
+package githubapi
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestPrMetadataSignatureRoundTrip(t *testing.T) {
+	t.Parallel()
+	key := []byte("s3cr3t")
+	serialized, err := prMetadata{OriginalPrAuthor: "octocat", OriginalPrNumber: 12}.serialize()
+	if err != nil {
+		t.Fatalf("serialize: %v", err)
+	}
+
+	binding := prMetadataBinding{repoSlug: "wayfair/k8s-gitops", headBranch: "promotions/12-foo-abcdef"}
+
+	tests := map[string]struct {
+		prBody        string
+		binding       prMetadataBinding
+		key           []byte
+		allowUnsigned bool
+		expectedErr   error
+	}{
+		"signed metadata is accepted": {
+			prBody:  "Promotion path(foo):\n" + prMetadataComment(binding, serialized, key),
+			binding: binding,
+			key:     key,
+		},
+		"repo slug case is ignored": {
+			prBody:  prMetadataComment(binding, serialized, key),
+			binding: prMetadataBinding{repoSlug: "Wayfair/K8s-Gitops", headBranch: binding.headBranch},
+			key:     key,
+		},
+		"metadata signed with another key is rejected": {
+			prBody:      prMetadataComment(binding, serialized, []byte("other")),
+			binding:     binding,
+			key:         key,
+			expectedErr: errPrMetadataTampered,
+		},
+		"edited metadata is rejected": {
+			prBody:      prMetadataCommentPrefix + serialized + "AA|" + signPrMetadata(binding, serialized, key) + "|-->",
+			binding:     binding,
+			key:         key,
+			expectedErr: errPrMetadataTampered,
+		},
+		"metadata copied from another repo is rejected": {
+			prBody:      prMetadataComment(prMetadataBinding{repoSlug: "wayfair/other", headBranch: binding.headBranch}, serialized, key),
+			binding:     binding,
+			key:         key,
+			expectedErr: errPrMetadataTampered,
+		},
+		"metadata copied from another branch is rejected": {
+			prBody:      prMetadataComment(prMetadataBinding{repoSlug: binding.repoSlug, headBranch: "promotions/13-bar-abcdef"}, serialized, key),
+			binding:     binding,
+			key:         key,
+			expectedErr: errPrMetadataTampered,
+		},
+		"unsigned metadata is rejected by default": {
+			prBody:      prMetadataComment(binding, serialized, nil),
+			binding:     binding,
+			key:         key,
+			expectedErr: errPrMetadataUnsigned,
+		},
+		"legacy unsigned metadata is accepted when unsigned metadata is allowed": {
+			prBody:        prMetadataComment(binding, serialized, nil),
+			binding:       binding,
+			key:           key,
+			allowUnsigned: true,
+		},
+		"legacy signature is rejected by default": {
+			prBody:      prMetadataCommentPrefix + serialized + "|" + signLegacyPrMetadata(serialized, key) + "|-->",
+			binding:     binding,
+			key:         key,
+			expectedErr: errPrMetadataTampered,
+		},
+		"legacy signature is accepted when unsigned metadata is allowed": {
+			prBody:        prMetadataCommentPrefix + serialized + "|" + signLegacyPrMetadata(serialized, key) + "|-->",
+			binding:       binding,
+			key:           key,
+			allowUnsigned: true,
+		},
+		"signature is ignored when signing is disabled": {
+			prBody:  prMetadataComment(binding, serialized, []byte("other")),
+			binding: binding,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			gotSerialized, signature, found := parsePrMetadataComment(tc.prBody)
+			if !found {
+				t.Fatalf("metadata block not found in %q", tc.prBody)
+			}
+			err := verifyPrMetadataSignature(tc.binding, gotSerialized, signature, tc.key, tc.allowUnsigned)
+			if !errors.Is(err, tc.expectedErr) {
+				t.Errorf("expected error %v, got %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+// Not parallel, it sets env vars
+func TestCheckPrMetadataSigningKey(t *testing.T) {
+	tests := map[string]struct {
+		key         string
+		disabled    string
+		expectedErr error
+	}{
+		"missing key fails even with a webhook secret": {
+			expectedErr: errPrMetadataNoSigningKey,
+		},
+		"configured key passes": {
+			key: "s3cr3t",
+		},
+		"missing key passes when signing is disabled": {
+			disabled: "true",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("GITHUB_WEBHOOK_SECRET", "webhook-secret")
+			t.Setenv("PR_METADATA_SIGNING_KEY", tc.key)
+			t.Setenv("PR_METADATA_SIGNING_DISABLED", tc.disabled)
+			if tc.key == "" {
+				os.Unsetenv("PR_METADATA_SIGNING_KEY")
+			}
+			err := CheckPrMetadataSigningKey()
+			if !errors.Is(err, tc.expectedErr) {
+				t.Errorf("expected error %v, got %v", tc.expectedErr, err)
+			}
+			if tc.key == "" && len(prMetadataSigningKey()) != 0 {
+				t.Errorf("expected no signing key, got %q", prMetadataSigningKey())
+			}
+		})
+	}
+}
+
+func TestParsePrMetadataCommentLegacyFormat(t *testing.T) {
+	t.Parallel()
+	serialized, signature, found := parsePrMetadataComment("body\n<!--|Telefonistka data, do not delete|eyJhIjoxfQ==|-->")
+	if !found || serialized != "eyJhIjoxfQ==" || signature != "" {
+		t.Errorf("unexpected parse result: serialized=%q signature=%q found=%v", serialized, signature, found)
+	}
+	if _, _, found := parsePrMetadataComment("no metadata here"); found {
+		t.Error("expected no metadata to be found")
+	}
+}
@@ -2,6 +2,7 @@ package githubapi
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/google/go-github/v62/github"
@@ -28,20 +29,12 @@ func getRepoPrMetrics(ctx context.Context, ghClient GhClientPair, repo *github.R
 	prListOpts := &github.PullRequestListOptions{
 		State: "open",
 	}
-	prs := []*github.PullRequest{}
-
 	// paginate through PRs, there might be lots of them.
-	for {
-		perPagePrs, resp, err := ghClient.v3Client.PullRequests.List(ctx, ghOwner, repo.GetName(), prListOpts)
-		_ = prom.InstrumentGhCall(resp)
-		if err != nil {
-			log.Errorf("error getting PRs for %s/%s: %v", ghOwner, repo.GetName(), err)
-		}
-		prs = append(prs, perPagePrs...)
-		if resp.NextPage == 0 {
-			break
-		}
-		prListOpts.Page = resp.NextPage
+	prs, _, err := listAllPages(&prListOpts.ListOptions, func() ([]*github.PullRequest, *github.Response, error) {
+		return ghClient.v3Client.PullRequests.List(ctx, ghOwner, repo.GetName(), prListOpts)
+	})
+	if err != nil {
+		return pc, fmt.Errorf("error getting PRs for %s/%s: %w", ghOwner, repo.GetName(), err)
 	}
 
 	for _, pr := range prs {
@@ -90,13 +83,12 @@ func getPrMetrics(mainGhClientCache *lru.Cache[string, GhClientPair]) {
 	for _, ghOwner := range mainGhClientCache.Keys() {
 		log.Debugf("Checking gh Owner %s", ghOwner)
 		ghClient, _ := mainGhClientCache.Get(ghOwner)
-		repos, resp, err := ghClient.v3Client.Apps.ListRepos(ctx, nil)
-		_ = prom.InstrumentGhCall(resp)
+		repos, err := listInstallationRepos(ghClient)
 		if err != nil {
 			log.Errorf("error getting repos for %s: %v", ghOwner, err)
 			continue
 		}
-		for _, repo := range repos.Repositories {
+		for _, repo := range repos {
 			pc, err := getRepoPrMetrics(ctx, ghClient, repo)
 			if err != nil {
 				log.Errorf("error getting repos for %s: %v", ghOwner, err)
@@ -0,0 +1,56 @@
+package githubapi
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+	"golang.org/x/exp/maps"
+)
+
+// prRouting holds who a new PR is assigned to and whose review is requested when it's opened
+type prRouting struct {
+	Assignees []string
+	Reviewers requiredApprovers
+}
+
+// assignedTo routes a PR to a single assignee, without requesting reviews
+func assignedTo(assignee string) prRouting {
+	return prRouting{Assignees: []string{assignee}}
+}
+
+// promotionPrRouting returns the routing of a PR promoting to paths based on the promotionPrRouting in-repo configuration,
+// fallbackAssignee(the original PR author) is assigned when none of the matching entries sets assignees
+func promotionPrRouting(config *cfg.Config, paths []string, fallbackAssignee string) prRouting {
+	assignees := map[string]bool{}
+	users := map[string]bool{}
+	teams := map[string]bool{}
+	for _, routing := range config.PromotionPrRouting {
+		// The regexes are validated when the configuration is parsed
+		r, err := regexp.Compile(routing.TargetPathRegex)
+		if err != nil {
+			continue
+		}
+		for _, p := range paths {
+			if r.MatchString(p) {
+				for _, a := range routing.Assignees {
+					assignees[strings.TrimPrefix(a, "@")] = true
+				}
+				for _, u := range routing.Reviewers {
+					users[strings.TrimPrefix(u, "@")] = true
+				}
+				for _, t := range routing.TeamReviewers {
+					teams[normalizeTeamSlug(t)] = true
+				}
+				break
+			}
+		}
+	}
+	if len(assignees) == 0 {
+		return prRouting{Assignees: []string{fallbackAssignee}, Reviewers: sortedApprovers(users, teams)}
+	}
+	routedAssignees := maps.Keys(assignees)
+	sort.Strings(routedAssignees)
+	return prRouting{Assignees: routedAssignees, Reviewers: sortedApprovers(users, teams)}
+}
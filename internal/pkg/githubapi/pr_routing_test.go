@@ -0,0 +1,43 @@
+package githubapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+)
+
+func TestPromotionPrRouting(t *testing.T) {
+	t.Parallel()
+	config := &cfg.Config{
+		PromotionPrRouting: []cfg.PromotionPrRouting{
+			{TargetPathRegex: "^env/prod/.*", TeamReviewers: []string{"@AnOwner/sre"}, Assignees: []string{"@oncall-bot"}},
+			{TargetPathRegex: "^env/prod/payments$", Reviewers: []string{"@alice"}},
+			{TargetPathRegex: "^env/staging/.*", Reviewers: []string{"bob"}},
+		},
+	}
+	tests := map[string]struct {
+		paths    []string
+		expected prRouting
+	}{
+		"routed assignees replace the author": {
+			paths:    []string{"env/prod/payments", "env/prod/orders"},
+			expected: prRouting{Assignees: []string{"oncall-bot"}, Reviewers: requiredApprovers{Users: []string{"alice"}, Teams: []string{"sre"}}},
+		},
+		"author is assigned without routed assignees": {
+			paths:    []string{"env/staging/orders"},
+			expected: prRouting{Assignees: []string{"author"}, Reviewers: requiredApprovers{Users: []string{"bob"}, Teams: []string{}}},
+		},
+		"no matching entry": {
+			paths:    []string{"env/dev/orders"},
+			expected: prRouting{Assignees: []string{"author"}, Reviewers: requiredApprovers{Users: []string{}, Teams: []string{}}},
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, promotionPrRouting(config, tc.paths, "author"))
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package githubapi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/argocd"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+	"golang.org/x/exp/maps"
+)
+
+// The summary comment is found by this marker and edited in place, it isn't tagged so it's never minimized as stale
+const prSummaryMarker = "<!-- telefonistka-pr-summary -->"
+
+type prSummaryDiff struct {
+	ComponentPath string
+	Provider      string
+	// "changed", "unchanged" or "error"
+	Status string
+	Error  string
+}
+
+type prSummaryCheck struct {
+	Name   string
+	Passed bool
+	Detail string
+}
+
+type prSummaryPromotion struct {
+	SourcePath string
+	Targets    []string
+	Gates      []string
+}
+
+// prSummaryData is the data of the pr-summary-comment.gotmpl template
+type prSummaryData struct {
+	SHA          string
+	Diffs        []prSummaryDiff
+	Checks       []prSummaryCheck
+	DriftChecked bool
+	DriftedPaths []string
+	Promotions   []prSummaryPromotion
+	Error        string
+}
+
+// prSummary collects the results of the checks of a PR event for the summary comment, the record methods do nothing on a nil summary(prSummaryComment is disabled)
+type prSummary struct {
+	mu   sync.Mutex
+	data prSummaryData
+}
+
+func (s *prSummary) recordComponentDiffs(provider string, diffs []ComponentDiff) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, diff := range diffs {
+		summaryDiff := prSummaryDiff{ComponentPath: diff.ComponentPath, Provider: provider, Status: "changed"}
+		switch {
+		case diff.Err != nil:
+			summaryDiff.Status = "error"
+			summaryDiff.Error = diff.Err.Error()
+		case strings.TrimSpace(diff.Diff) == "":
+			summaryDiff.Status = "unchanged"
+		}
+		s.data.Diffs = append(s.data.Diffs, summaryDiff)
+	}
+}
+
+func (s *prSummary) recordDiffError(provider string, componentPaths []string, err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, componentPath := range componentPaths {
+		s.data.Diffs = append(s.data.Diffs, prSummaryDiff{ComponentPath: componentPath, Provider: provider, Status: "error", Error: err.Error()})
+	}
+}
+
+func (s *prSummary) recordArgoCdDiffs(diffResults []argocd.DiffResult) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, diffResult := range diffResults {
+		summaryDiff := prSummaryDiff{ComponentPath: diffResult.ComponentPath, Provider: "ArgoCD diff", Status: "unchanged"}
+		switch {
+		case diffResult.DiffError != nil:
+			summaryDiff.Status = "error"
+			summaryDiff.Error = diffResult.DiffError.Error()
+		case diffResult.HasDiff:
+			summaryDiff.Status = "changed"
+		}
+		s.data.Diffs = append(s.data.Diffs, summaryDiff)
+	}
+}
+
+func (s *prSummary) recordCheck(name string, passed bool, detail string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Checks = append(s.data.Checks, prSummaryCheck{Name: name, Passed: passed, Detail: detail})
+}
+
+func (s *prSummary) recordDrift(driftedPaths []string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.DriftChecked = true
+	s.data.DriftedPaths = append([]string{}, driftedPaths...)
+	sort.Strings(s.data.DriftedPaths)
+}
+
+// recordPromotionPlan summarizes the promotions a merge of the PR would open and the gates that hold or block them
+func (s *prSummary) recordPromotionPlan(config *cfg.Config, promotions map[string]PromotionInstance, now time.Time) {
+	if s == nil {
+		return
+	}
+	summaryPromotions := []prSummaryPromotion{}
+	for _, promotion := range promotions {
+		summaryPromotion := prSummaryPromotion{SourcePath: promotion.Metadata.SourcePath, Targets: maps.Keys(promotion.ComputedSyncPaths), Gates: []string{}}
+		sort.Strings(summaryPromotion.Targets)
+		pausedTargets := maps.Keys(promotion.PausedTargetPaths)
+		sort.Strings(pausedTargets)
+		for _, target := range pausedTargets {
+			summaryPromotion.Gates = append(summaryPromotion.Gates, fmt.Sprintf("⏸️ `%s` is paused by `%s`", target, promotion.PausedTargetPaths[target]))
+		}
+		if len(summaryPromotion.Targets) > 0 && promotionHeld(config, promotion, now) {
+			summaryPromotion.Gates = append(summaryPromotion.Gates, "🚂 waits for its promotion train window")
+		}
+		if ra := generateRequiredApprovers(config, summaryPromotion.Targets); !ra.isEmpty() {
+			approvers := append([]string{}, ra.Users...)
+			for _, team := range ra.Teams {
+				approvers = append(approvers, "team "+team)
+			}
+			summaryPromotion.Gates = append(summaryPromotion.Gates, fmt.Sprintf("👀 requires approval from %s", strings.Join(approvers, ", ")))
+		}
+		summaryPromotions = append(summaryPromotions, summaryPromotion)
+	}
+	sort.Slice(summaryPromotions, func(i, j int) bool { return summaryPromotions[i].SourcePath < summaryPromotions[j].SourcePath })
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Promotions = summaryPromotions
+}
+
+// findCommentWithMarker returns the first PR comment containing marker, nil when there isn't one
+func findCommentWithMarker(ghPrClientDetails GhPrClientDetails, marker string) (*github.IssueComment, error) {
+	listOpts := &github.IssueListCommentsOptions{}
+	var found *github.IssueComment
+	_, err := forEachPage(&listOpts.ListOptions, func() ([]*github.IssueComment, *github.Response, error) {
+		return ghPrClientDetails.GhClientPair.v3Client.Issues.ListComments(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, ghPrClientDetails.PrNumber, listOpts)
+	}, func(comments []*github.IssueComment) bool {
+		for _, comment := range comments {
+			if strings.Contains(comment.GetBody(), marker) {
+				found = comment
+				return false
+			}
+		}
+		return true
+	})
+	return found, err
+}
+
+// updatePrSummaryComment renders the summary of the event and edits the summary comment of the PR, or creates it on the first event
+func updatePrSummaryComment(ghPrClientDetails GhPrClientDetails, config *cfg.Config, handlingErr error) error {
+	summary := ghPrClientDetails.summary
+	if summary == nil {
+		return nil
+	}
+	promotions, err := GeneratePromotionPlan(ghPrClientDetails, config, ghPrClientDetails.Ref)
+	if err != nil {
+		ghPrClientDetails.PrLogger.Warnf("Failed to generate the promotion plan of the summary: err=%v", err)
+	} else {
+		defaultBranch, _ := ghPrClientDetails.GetDefaultBranch()
+		applyPromotionPauses(ghPrClientDetails, promotions, defaultBranch)
+		summary.recordPromotionPlan(config, promotions, time.Now())
+	}
+
+	summary.mu.Lock()
+	data := summary.data
+	summary.mu.Unlock()
+	data.SHA = ghPrClientDetails.PrSHA
+	if handlingErr != nil {
+		data.Error = handlingErr.Error()
+	}
+	sort.SliceStable(data.Diffs, func(i, j int) bool { return data.Diffs[i].ComponentPath < data.Diffs[j].ComponentPath })
+	body, err := executeRepoTemplate(ghPrClientDetails, "prSummary", "pr-summary-comment.gotmpl", data)
+	if err != nil {
+		return err
+	}
+	body = prSummaryMarker + "\n" + body
+
+	existing, err := findCommentWithMarker(ghPrClientDetails, prSummaryMarker)
+	if err != nil {
+		return fmt.Errorf("looking for the summary comment: %w", err)
+	}
+	if existing != nil {
+		if existing.GetBody() == body {
+			return nil
+		}
+		_, _, err = retryGhWrite(ghPrClientDetails.Ctx, "edit_comment", func() (*github.IssueComment, *github.Response, error) {
+			return ghPrClientDetails.GhClientPair.v3Client.Issues.EditComment(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, existing.GetID(), &github.IssueComment{Body: github.String(body)})
+		})
+		return err
+	}
+	_, _, err = deduplicateGhWrite(ghPrClientDetails.Ctx, "create_comment", fmt.Sprintf("%d/summary", ghPrClientDetails.PrNumber), func() (*github.IssueComment, *github.Response, error) {
+		return retryGhWrite(ghPrClientDetails.Ctx, "create_comment", func() (*github.IssueComment, *github.Response, error) {
+			return ghPrClientDetails.GhClientPair.v3Client.Issues.CreateComment(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, ghPrClientDetails.PrNumber, &github.IssueComment{Body: github.String(body)})
+		})
+	})
+	return err
+}
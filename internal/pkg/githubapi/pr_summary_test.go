@@ -0,0 +1,69 @@
+package githubapi
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/argocd"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+)
+
+func TestPrSummaryRecordsNothingWhenDisabled(t *testing.T) {
+	t.Parallel()
+	var summary *prSummary
+	summary.recordCheck("Manifest validation", true, "")
+	summary.recordDrift([]string{"env/staging/app"})
+	summary.recordDiffError("ArgoCD diff", []string{"env/staging/app"}, errors.New("boom"))
+	assert.Nil(t, summary)
+}
+
+func TestPrSummaryRecordPromotionPlan(t *testing.T) {
+	t.Parallel()
+	config := &cfg.Config{
+		RequiredApprovers: []cfg.RequiredApprovers{{TargetPathRegex: "^env/prod/", Users: []string{"alice"}, Teams: []string{"sre"}}},
+	}
+	promotions := map[string]PromotionInstance{
+		"env/staging/>env/prod/": {
+			Metadata:          PromotionInstanceMetaData{SourcePath: "env/staging/"},
+			ComputedSyncPaths: map[string]string{"env/prod/us/app": "env/staging/app", "env/prod/eu/app": "env/staging/app"},
+			PausedTargetPaths: map[string]string{"env/prod/ap/app": "env/prod/ap/.telefonistka-pause"},
+		},
+	}
+	summary := &prSummary{}
+	summary.recordPromotionPlan(config, promotions, time.Now())
+
+	assert.Equal(t, []prSummaryPromotion{{
+		SourcePath: "env/staging/",
+		Targets:    []string{"env/prod/eu/app", "env/prod/us/app"},
+		Gates: []string{
+			"⏸️ `env/prod/ap/app` is paused by `env/prod/ap/.telefonistka-pause`",
+			"👀 requires approval from alice, team sre",
+		},
+	}}, summary.data.Promotions)
+}
+
+func TestPrSummaryTemplate(t *testing.T) {
+	t.Parallel()
+	summary := &prSummary{}
+	summary.recordArgoCdDiffs([]argocd.DiffResult{
+		{ComponentPath: "env/staging/app", HasDiff: true},
+		{ComponentPath: "env/staging/db"},
+	})
+	summary.recordComponentDiffs("Helm diff", []ComponentDiff{{ComponentPath: "env/staging/chart", Err: errors.New("helm template failed")}})
+	summary.recordCheck("Manifest validation", false, "2 invalid manifest files")
+	summary.recordDrift([]string{"`env/staging/app` ↔️  `env/prod/app`"})
+	summary.data.SHA = "abc123"
+
+	body, err := executeRepoTemplate(repoTemplateTestClientDetails(mock.NewMockedHTTPClient(noRepoTemplateOverride())), "prSummary", "pr-summary-comment.gotmpl", summary.data)
+	assert.NoError(t, err)
+	assert.Contains(t, body, "Results for `abc123`")
+	assert.Contains(t, body, "| `env/staging/app` | ArgoCD diff | 📝 Changed |")
+	assert.Contains(t, body, "| `env/staging/db` | ArgoCD diff | ✅ No changes |")
+	assert.Contains(t, body, "| `env/staging/chart` | Helm diff | ❌ helm template failed |")
+	assert.Contains(t, body, "| Manifest validation | ❌ 2 invalid manifest files |")
+	assert.Contains(t, body, "| Drift | ⚠️ `env/staging/app` ↔️  `env/prod/app` |")
+	assert.Contains(t, body, "Merging this PR doesn't promote anything.")
+}
@@ -9,13 +9,19 @@ import (
 	"github.com/google/go-github/v62/github"
 	log "github.com/sirupsen/logrus"
 	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/notifications"
 	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+	"golang.org/x/exp/maps"
 	yaml "gopkg.in/yaml.v2"
 )
 
 type PromotionInstance struct {
 	Metadata          PromotionInstanceMetaData `deep:"-"` // Unit tests ignore Metadata currently
 	ComputedSyncPaths map[string]string         // key is target, value is source
+	// Only set for the show-plan comment, key is target
+	FileChanges map[string]FileChangeSummary `deep:"-"`
+	// Target paths that were removed from ComputedSyncPaths because promotions to them are paused, value is the marker file that paused them
+	PausedTargetPaths map[string]string `deep:"-"`
 }
 
 type PromotionInstanceMetaData struct {
@@ -25,6 +31,12 @@ type PromotionInstanceMetaData struct {
 	PerComponentSkippedTargetPaths map[string][]string // ComponentName is the key,
 	ComponentNames                 []string
 	AutoMerge                      bool
+	HotfixSkippedTargetPaths       []string          // Intermediate target paths the hotfix promotion skipped
+	PathNames                      map[string]string // Environment paths to their display names, see cfg.Config.EnvironmentPathNames
+	StepTargetPaths                [][]string        // Target paths of each canary step, see splitPromotionSteps
+	Steps                          *promotionStepsMetadata
+	ComponentOrder                 *componentOrderMetadata
+	Atomic                         bool // Combined by atomicPromotions, gate failures block auto-merging it instead of opening it
 }
 
 func containMatchingRegex(patterns []string, str string) bool {
@@ -51,11 +63,17 @@ func contains(s []string, str string) bool {
 }
 
 func DetectDrift(ghPrClientDetails GhPrClientDetails) error {
+	return detectDrift(ghPrClientDetails, false)
+}
+
+// detectDrift comments the drift between the promotion paths of the PR, reportNoDrift is set for on demand runs so the requester gets an answer either way
+func detectDrift(ghPrClientDetails GhPrClientDetails, reportNoDrift bool) error {
 	ghPrClientDetails.PrLogger.Debugln("Checking for Drift")
 	if ghPrClientDetails.Ctx.Err() != nil {
 		return ghPrClientDetails.Ctx.Err()
 	}
 	diffOutputMap := make(map[string]string)
+	driftingPaths := make(map[string]string) // target to source, for notifications
 	defaultBranch, _ := ghPrClientDetails.GetDefaultBranch()
 	config, err := GetInRepoConfig(ghPrClientDetails, defaultBranch)
 	if err != nil {
@@ -72,12 +90,23 @@ func DetectDrift(ghPrClientDetails GhPrClientDetails) error {
 			if hasDiff {
 				mapKey := fmt.Sprintf("`%s` ↔️  `%s`", src, trgt)
 				diffOutputMap[mapKey] = diffOutput
+				driftingPaths[trgt] = "differs from " + src
 				ghPrClientDetails.PrLogger.Debugf("Found diff @ %s", mapKey)
 			}
 		}
 	}
+	ghPrClientDetails.summary.recordDrift(maps.Keys(diffOutputMap))
 	if len(diffOutputMap) != 0 {
-		templateOutput, err := executeTemplate("driftMsg", defaultTemplatesFullPath("drift-pr-comment.gotmpl"), diffOutputMap)
+		notifications.Send(ghPrClientDetails.Ctx, notifications.Event{
+			Type:     notifications.DriftDetected,
+			Repo:     ghPrClientDetails.Owner + "/" + ghPrClientDetails.Repo,
+			PrNumber: ghPrClientDetails.PrNumber,
+			PrURL:    fmt.Sprintf("%s/pull/%d", ghPrClientDetails.RepoURL, ghPrClientDetails.PrNumber),
+			Title:    fmt.Sprintf("Drift detected while checking PR #%d", ghPrClientDetails.PrNumber),
+			Text:     fmt.Sprintf("%d environment pairs are out of sync", len(diffOutputMap)),
+			Facts:    driftingPaths,
+		})
+		templateOutput, err := executeRepoTemplate(ghPrClientDetails, "driftMsg", "drift-pr-comment.gotmpl", diffOutputMap)
 		if err != nil {
 			return err
 		}
@@ -88,6 +117,9 @@ func DetectDrift(ghPrClientDetails GhPrClientDetails) error {
 		}
 	} else {
 		ghPrClientDetails.PrLogger.Infof("No drift found")
+		if reportNoDrift {
+			return commentPR(ghPrClientDetails, "✅ No drift found between the promotion paths of this PR")
+		}
 	}
 
 	return nil
@@ -116,29 +148,37 @@ func getComponentConfig(ghPrClientDetails GhPrClientDetails, componentPath strin
 
 // This function generates a list of "components" that where changed in the PR and are relevant for promotion)
 func generateListOfRelevantComponents(ghPrClientDetails GhPrClientDetails, config *cfg.Config) (relevantComponents map[relevantComponent]struct{}, err error) {
-	relevantComponents = make(map[relevantComponent]struct{})
+	changedFiles, err := listPrFiles(ghPrClientDetails)
+	if err != nil {
+		return nil, err
+	}
+	return getRelevantComponentsFromFileList(changedFiles, config), nil
+}
 
-	// Get the list of files in the PR, with pagination
+// listPrFiles returns the paths of the files changed in the PR, with pagination
+func listPrFiles(ghPrClientDetails GhPrClientDetails) ([]string, error) {
 	opts := &github.ListOptions{}
-	prFiles := []*github.CommitFile{}
-
-	for {
-		perPagePrFiles, resp, err := ghPrClientDetails.GhClientPair.v3Client.PullRequests.ListFiles(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, ghPrClientDetails.PrNumber, opts)
-		prom.InstrumentGhCall(resp)
-		if err != nil {
-			ghPrClientDetails.PrLogger.Errorf("could not get file list from GH API: err=%s\nstatus code=%v", err, resp.Response.Status)
-			return nil, err
-		}
-		prFiles = append(prFiles, perPagePrFiles...)
-		if resp.NextPage == 0 {
-			break
-		}
-		opts.Page = resp.NextPage
+	prFiles, resp, err := listAllPages(opts, func() ([]*github.CommitFile, *github.Response, error) {
+		return ghPrClientDetails.GhClientPair.v3Client.PullRequests.ListFiles(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, ghPrClientDetails.PrNumber, opts)
+	})
+	if err != nil {
+		ghPrClientDetails.PrLogger.Errorf("could not get file list from GH API: err=%s\nresponse=%v", err, resp)
+		return nil, err
 	}
 
+	changedFiles := []string{}
 	for _, changedFile := range prFiles {
+		changedFiles = append(changedFiles, changedFile.GetFilename())
+	}
+	return changedFiles, nil
+}
+
+// getRelevantComponentsFromFileList maps a list of changed files to the "components" they belong to, based on the in-repo promotion configuration
+func getRelevantComponentsFromFileList(changedFiles []string, config *cfg.Config) (relevantComponents map[relevantComponent]struct{}) {
+	relevantComponents = make(map[relevantComponent]struct{})
+	for _, changedFile := range changedFiles {
 		for _, promotionPathConfig := range config.PromotionPaths {
-			if match, _ := regexp.MatchString("^"+promotionPathConfig.SourcePath+".*", *changedFile.Filename); match {
+			if match, _ := regexp.MatchString("^"+promotionPathConfig.SourcePath+".*", changedFile); match {
 				// "components" here are the sub directories of the SourcePath
 				// but with promotionPathConfig.ComponentPathExtraDepth we can grab multiple levels of subdirectories,
 				// to support cases where components are nested deeper(e.g. [SourcePath]/owningTeam/namespace/component1)
@@ -148,10 +188,10 @@ func generateListOfRelevantComponents(ghPrClientDetails GhPrClientDetails, confi
 				}
 				componentPathRegexSubString := strings.Join(componentPathRegexSubSstrings, "/")
 				getComponentRegexString := regexp.MustCompile("^" + promotionPathConfig.SourcePath + "(" + componentPathRegexSubString + ")/.*")
-				componentName := getComponentRegexString.ReplaceAllString(*changedFile.Filename, "${1}")
+				componentName := getComponentRegexString.ReplaceAllString(changedFile, "${1}")
 
 				getSourcePathRegexString := regexp.MustCompile("^(" + promotionPathConfig.SourcePath + ")" + componentName + "/.*")
-				compiledSourcePath := getSourcePathRegexString.ReplaceAllString(*changedFile.Filename, "${1}")
+				compiledSourcePath := getSourcePathRegexString.ReplaceAllString(changedFile, "${1}")
 				relevantComponentsElement := relevantComponent{
 					SourcePath:    compiledSourcePath,
 					ComponentName: componentName,
@@ -162,7 +202,7 @@ func generateListOfRelevantComponents(ghPrClientDetails GhPrClientDetails, confi
 			}
 		}
 	}
-	return relevantComponents, nil
+	return relevantComponents
 }
 
 type relevantComponent struct {
@@ -189,13 +229,33 @@ func generateListOfChangedComponentPaths(ghPrClientDetails GhPrClientDetails, co
 	return changedComponentPaths, nil
 }
 
+// ComponentConfigGetter fetches the optional in-component configuration of a component path, this allows generating promotion plans without a GitHub client
+type ComponentConfigGetter func(componentPath string) (*cfg.ComponentConfig, error)
+
 // This function generates a promotion plan based on the list of relevant components that where "touched" and the in-repo telefonitka  configuration
 func generatePlanBasedOnChangeddComponent(ghPrClientDetails GhPrClientDetails, config *cfg.Config, relevantComponents map[relevantComponent]struct{}, configBranch string) (promotions map[string]PromotionInstance, err error) {
+	prLabels := []string{}
+	for _, l := range ghPrClientDetails.Labels {
+		prLabels = append(prLabels, l.GetName())
+	}
+	getConfig := func(componentPath string) (*cfg.ComponentConfig, error) {
+		return getComponentConfig(ghPrClientDetails, componentPath, configBranch)
+	}
+	return generatePlanForComponents(ghPrClientDetails.PrLogger, config, relevantComponents, prLabels, getConfig), nil
+}
+
+// GeneratePromotionPlanForFiles is the SCM agnostic flavor of GeneratePromotionPlan, it is used by non GitHub providers that supply the changed files, PR labels and in-component configuration themselves.
+func GeneratePromotionPlanForFiles(logger *log.Entry, config *cfg.Config, changedFiles []string, prLabels []string, getConfig ComponentConfigGetter) map[string]PromotionInstance {
+	return generatePlanForComponents(logger, config, getRelevantComponentsFromFileList(changedFiles, config), prLabels, getConfig)
+}
+
+func generatePlanForComponents(logger *log.Entry, config *cfg.Config, relevantComponents map[relevantComponent]struct{}, prLabels []string, getConfig ComponentConfigGetter) (promotions map[string]PromotionInstance) {
 	promotions = make(map[string]PromotionInstance)
+	pathNames := config.EnvironmentPathNames()
 	for componentToPromote := range relevantComponents {
-		componentConfig, err := getComponentConfig(ghPrClientDetails, componentToPromote.SourcePath+componentToPromote.ComponentName, configBranch)
+		componentConfig, err := getConfig(componentToPromote.SourcePath + componentToPromote.ComponentName)
 		if err != nil {
-			ghPrClientDetails.PrLogger.Errorf("Failed to get in component configuration, err=%s\nskipping %s", err, componentToPromote.SourcePath+componentToPromote.ComponentName)
+			logger.Errorf("Failed to get in component configuration, err=%s\nskipping %s", err, componentToPromote.SourcePath+componentToPromote.ComponentName)
 		}
 
 		for _, configPromotionPath := range config.PromotionPaths {
@@ -203,8 +263,8 @@ func generatePlanBasedOnChangeddComponent(ghPrClientDetails GhPrClientDetails, c
 				// This section checks if a PromotionPath has a condition and skips it if needed
 				if configPromotionPath.Conditions.PrHasLabels != nil {
 					thisPrHasTheRightLabel := false
-					for _, l := range ghPrClientDetails.Labels {
-						if contains(configPromotionPath.Conditions.PrHasLabels, *l.Name) {
+					for _, l := range prLabels {
+						if contains(configPromotionPath.Conditions.PrHasLabels, l) {
 							thisPrHasTheRightLabel = true
 							break
 						}
@@ -214,12 +274,25 @@ func generatePlanBasedOnChangeddComponent(ghPrClientDetails GhPrClientDetails, c
 					}
 				}
 
-				for _, ppr := range configPromotionPath.PromotionPrs {
+				promotionPrs := configPromotionPath.PromotionPrs
+				var hotfixSkippedTargetPaths []string
+				if isHotfix(config, prLabels) && !contains(config.Hotfix.TargetPaths, componentToPromote.SourcePath) {
+					hotfixSkippedTargetPaths = generateHotfixSkippedTargetPaths(config, promotionPrs)
+					promotionPrs = []cfg.PromotionPr{{TargetPaths: append([]string{}, config.Hotfix.TargetPaths...), TargetDescription: config.Hotfix.TargetDescription}}
+				}
+
+				for _, ppr := range promotionPrs {
+					var stepTargetPaths [][]string
+					if len(ppr.Steps) > 0 {
+						stepTargetPaths = promotionStepTargetPaths(ppr.TargetPaths, ppr.Steps)
+					}
+					// The configured order of the target paths picks the canary targets, so the configuration isn't sorted in place
+					ppr.TargetPaths = append([]string{}, ppr.TargetPaths...)
 					sort.Strings(ppr.TargetPaths)
 
 					mapKey := configPromotionPath.SourcePath + ">" + strings.Join(ppr.TargetPaths, "|") // This key is used to aggregate the PR based on source and target combination
 					if entry, ok := promotions[mapKey]; !ok {
-						ghPrClientDetails.PrLogger.Debugf("Adding key %s", mapKey)
+						logger.Debugf("Adding key %s", mapKey)
 						if ppr.TargetDescription == "" {
 							ppr.TargetDescription = strings.Join(ppr.TargetPaths, " ")
 						}
@@ -231,6 +304,9 @@ func generatePlanBasedOnChangeddComponent(ghPrClientDetails GhPrClientDetails, c
 								ComponentNames:                 []string{componentToPromote.ComponentName},
 								PerComponentSkippedTargetPaths: map[string][]string{},
 								AutoMerge:                      componentToPromote.AutoMerge,
+								HotfixSkippedTargetPaths:       hotfixSkippedTargetPaths,
+								PathNames:                      pathNames,
+								StepTargetPaths:                stepTargetPaths,
 							},
 							ComputedSyncPaths: map[string]string{},
 						}
@@ -262,7 +338,47 @@ func generatePlanBasedOnChangeddComponent(ghPrClientDetails GhPrClientDetails, c
 			}
 		}
 	}
-	return promotions, nil
+	return promotions
+}
+
+func hotfixLabel(config *cfg.Config) string {
+	if config.Hotfix.Label != "" {
+		return config.Hotfix.Label
+	}
+	return "hotfix"
+}
+
+func isHotfix(config *cfg.Config, prLabels []string) bool {
+	return len(config.Hotfix.TargetPaths) > 0 && contains(prLabels, hotfixLabel(config))
+}
+
+// generateHotfixSkippedTargetPaths follows the promotion chain from the configured promotion PRs until the hotfix targets and returns the intermediate target paths
+func generateHotfixSkippedTargetPaths(config *cfg.Config, promotionPrs []cfg.PromotionPr) []string {
+	var pending []string
+	for _, ppr := range promotionPrs {
+		pending = append(pending, ppr.TargetPaths...)
+	}
+	visited := map[string]bool{}
+	skipped := []string{}
+	for len(pending) > 0 {
+		targetPath := pending[0]
+		pending = pending[1:]
+		if visited[targetPath] || contains(config.Hotfix.TargetPaths, targetPath) {
+			continue
+		}
+		visited[targetPath] = true
+		skipped = append(skipped, targetPath)
+		for _, configPromotionPath := range config.PromotionPaths {
+			if match, _ := regexp.MatchString(configPromotionPath.SourcePath, targetPath); match {
+				for _, ppr := range configPromotionPath.PromotionPrs {
+					pending = append(pending, ppr.TargetPaths...)
+				}
+				break
+			}
+		}
+	}
+	sort.Strings(skipped)
+	return skipped
 }
 
 func GeneratePromotionPlan(ghPrClientDetails GhPrClientDetails, config *cfg.Config, configBranch string) (map[string]PromotionInstance, error) {
@@ -0,0 +1,92 @@
+package githubapi
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v62/github"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+	"golang.org/x/exp/maps"
+)
+
+const symlinkFileMode = "120000"
+
+func promotionFilePolicyEnabled(policy cfg.PromotionFilePolicy) bool {
+	return len(policy.AllowedFilePatterns) > 0 || policy.MaxFileSizeMB > 0 || policy.BlockSymlinks
+}
+
+// promotionFileViolation returns why a file of a promotion source path violates the policy, or "" when it doesn't
+func promotionFileViolation(policy cfg.PromotionFilePolicy, entry *github.TreeEntry) string {
+	if entry.GetType() != "blob" {
+		return ""
+	}
+	if policy.BlockSymlinks && entry.GetMode() == symlinkFileMode {
+		return "symlinks can't be promoted"
+	}
+	if len(policy.AllowedFilePatterns) > 0 {
+		allowed := false
+		for _, pattern := range policy.AllowedFilePatterns {
+			// Patterns are validated when the configuration is parsed
+			if matched, _ := path.Match(pattern, path.Base(entry.GetPath())); matched {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Sprintf("the file name doesn't match any of the allowed patterns(%s)", strings.Join(policy.AllowedFilePatterns, ", "))
+		}
+	}
+	if policy.MaxFileSizeMB > 0 && entry.GetSize() > policy.MaxFileSizeMB*1024*1024 {
+		return fmt.Sprintf("the file is larger than %dMB", policy.MaxFileSizeMB)
+	}
+	return ""
+}
+
+// promotionFileViolations checks the files of sourcePath as found in branch against the policy and returns the violations keyed by file path
+func promotionFileViolations(ghPrClientDetails GhPrClientDetails, policy cfg.PromotionFilePolicy, sourcePath string, branch string) (map[string]string, error) {
+	entries, err := getDirectoryTree(ghPrClientDetails, sourcePath, branch)
+	if err != nil {
+		return nil, err
+	}
+	violations := map[string]string{}
+	for _, entry := range entries {
+		if violation := promotionFileViolation(policy, entry); violation != "" {
+			violations[sourcePath+"/"+entry.GetPath()] = violation
+		}
+	}
+	return violations, nil
+}
+
+// checkPromotionFilePolicy returns the violations of all the source paths of a promotion, source paths that can't be checked are reported as violations
+func checkPromotionFilePolicy(ghPrClientDetails GhPrClientDetails, policy cfg.PromotionFilePolicy, promotion PromotionInstance, branch string) map[string]string {
+	violations := map[string]string{}
+	checkedSources := map[string]bool{}
+	for _, source := range promotion.ComputedSyncPaths {
+		if checkedSources[source] {
+			continue
+		}
+		checkedSources[source] = true
+		sourceViolations, err := promotionFileViolations(ghPrClientDetails, policy, source, branch)
+		if err != nil {
+			ghPrClientDetails.PrLogger.Errorf("Failed to check the files of %s against the promotion file policy: err=%v", source, err)
+			violations[source] = fmt.Sprintf("the files couldn't be checked: %s", err)
+			continue
+		}
+		maps.Copy(violations, sourceViolations)
+	}
+	return violations
+}
+
+func promotionFilePolicyComment(promotion PromotionInstance, violations map[string]string) string {
+	targets := maps.Keys(promotion.ComputedSyncPaths)
+	sort.Strings(targets)
+	comment := fmt.Sprintf("🚫 The promotion of `%s` to `%s` was blocked by the `promotionFilePolicy` configuration:\n", promotion.Metadata.SourcePath, strings.Join(targets, "`, `"))
+	files := maps.Keys(violations)
+	sort.Strings(files)
+	for _, file := range files {
+		comment += fmt.Sprintf("* `%s`: %s\n", file, violations[file])
+	}
+	return comment + "\nRemove these files(or change the policy) and merge again, or promote this change manually."
+}
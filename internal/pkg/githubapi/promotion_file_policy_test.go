@@ -0,0 +1,72 @@
+package githubapi
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+)
+
+func TestPromotionFileViolation(t *testing.T) {
+	t.Parallel()
+	policy := cfg.PromotionFilePolicy{AllowedFilePatterns: []string{"*.yaml", "*.yml"}, MaxFileSizeMB: 1, BlockSymlinks: true}
+	tests := map[string]struct {
+		entry             github.TreeEntry
+		expectedViolation bool
+	}{
+		"allowed file":     {entry: github.TreeEntry{Path: github.String("base/deployment.yaml"), Type: github.String("blob"), Mode: github.String("100644"), Size: github.Int(2048)}},
+		"directory":        {entry: github.TreeEntry{Path: github.String("base"), Type: github.String("tree"), Mode: github.String("040000")}},
+		"not allowed type": {entry: github.TreeEntry{Path: github.String("image.tar.gz"), Type: github.String("blob"), Mode: github.String("100644"), Size: github.Int(2048)}, expectedViolation: true},
+		"too large":        {entry: github.TreeEntry{Path: github.String("values.yaml"), Type: github.String("blob"), Mode: github.String("100644"), Size: github.Int(2 * 1024 * 1024)}, expectedViolation: true},
+		"symlink":          {entry: github.TreeEntry{Path: github.String("values.yaml"), Type: github.String("blob"), Mode: github.String("120000"), Size: github.Int(20)}, expectedViolation: true},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			violation := promotionFileViolation(policy, &tc.entry)
+			assert.Equal(t, tc.expectedViolation, violation != "", violation)
+		})
+	}
+}
+
+func TestCheckPromotionFilePolicy(t *testing.T) {
+	t.Parallel()
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetReposContentsByOwnerByRepoByPath,
+			[]github.RepositoryContent{{Path: github.String("env/staging/app"), SHA: github.String("stagingsha"), Type: github.String("dir")}},
+		),
+		mock.WithRequestMatchHandler(
+			mock.GetReposGitTreesByOwnerByRepoByTreeSha,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/repos/AnOwner/Arepo/git/trees/stagingsha", r.URL.Path)
+				_, _ = w.Write(mock.MustMarshal(github.Tree{Entries: []*github.TreeEntry{
+					{Path: github.String("values.yaml"), Type: github.String("blob"), Mode: github.String("100644"), Size: github.Int(100)},
+					{Path: github.String("chart.tgz"), Type: github.String("blob"), Mode: github.String("100644"), Size: github.Int(100)},
+				}}))
+			}),
+		),
+	)
+	ghPrClientDetails := GhPrClientDetails{
+		Ctx:          context.Background(),
+		GhClientPair: &GhClientPair{v3Client: github.NewClient(mockedHTTPClient)},
+		Owner:        "AnOwner",
+		Repo:         "Arepo",
+		PrLogger:     log.WithFields(log.Fields{"repo": "AnOwner/Arepo"}),
+	}
+	promotion := PromotionInstance{
+		Metadata:          PromotionInstanceMetaData{SourcePath: "env/staging/"},
+		ComputedSyncPaths: map[string]string{"env/prod/app": "env/staging/app"},
+	}
+
+	violations := checkPromotionFilePolicy(ghPrClientDetails, cfg.PromotionFilePolicy{AllowedFilePatterns: []string{"*.yaml"}}, promotion, "main")
+	assert.Len(t, violations, 1)
+	assert.Contains(t, violations, "env/staging/app/chart.tgz")
+	assert.Contains(t, promotionFilePolicyComment(promotion, violations), "* `env/staging/app/chart.tgz`: the file name doesn't match")
+}
@@ -0,0 +1,78 @@
+package githubapi
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v62/github"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+	"golang.org/x/exp/maps"
+)
+
+// A file with this name pauses promotions to the directory it's in and to all of its subdirectories, e.g. env/prod/promotion-paused pauses promotions of all the prod components
+const promotionPausedMarkerFile = "promotion-paused"
+
+// promotionPausedMarker returns the marker file that pauses promotions to targetPath, or "" when they aren't paused.
+// Markers are looked up from the target path up to the repo root, checkedDirs caches the lookups of a single event
+func promotionPausedMarker(ghPrClientDetails GhPrClientDetails, targetPath string, branch string, checkedDirs map[string]bool) string {
+	for dir := strings.TrimSuffix(targetPath, "/"); ; dir = path.Dir(dir) {
+		markerPath := path.Join(dir, promotionPausedMarkerFile)
+		paused, checked := checkedDirs[dir]
+		if !checked {
+			_, _, resp, err := ghPrClientDetails.GhClientPair.v3Client.Repositories.GetContents(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, markerPath, &github.RepositoryContentGetOptions{Ref: branch})
+			prom.InstrumentGhCall(resp)
+			if err != nil && (resp == nil || resp.StatusCode != http.StatusNotFound) {
+				// Failing open keeps a GitHub hiccup from halting all promotions, but it's worth knowing about
+				ghPrClientDetails.PrLogger.Errorf("Failed to check for promotion pause marker %s: err=%v", markerPath, err)
+			}
+			paused = err == nil
+			checkedDirs[dir] = paused
+		}
+		if paused {
+			return markerPath
+		}
+		if dir == "." || dir == "/" {
+			return ""
+		}
+	}
+}
+
+// applyPromotionPauses removes the paused target paths from the promotions(the marker files are read from branch) and returns how many were removed
+func applyPromotionPauses(ghPrClientDetails GhPrClientDetails, promotions map[string]PromotionInstance, branch string) int {
+	checkedDirs := map[string]bool{}
+	pausedCount := 0
+	for key, promotion := range promotions {
+		for target := range promotion.ComputedSyncPaths {
+			markerPath := promotionPausedMarker(ghPrClientDetails, target, branch, checkedDirs)
+			if markerPath == "" {
+				continue
+			}
+			ghPrClientDetails.PrLogger.Infof("Promotions to %s are paused by %s, skipping it", target, markerPath)
+			if promotion.PausedTargetPaths == nil {
+				promotion.PausedTargetPaths = map[string]string{}
+			}
+			promotion.PausedTargetPaths[target] = markerPath
+			delete(promotion.ComputedSyncPaths, target)
+			pausedCount++
+		}
+		promotions[key] = promotion
+	}
+	return pausedCount
+}
+
+func pausedPromotionsComment(promotions map[string]PromotionInstance) string {
+	pausedTargets := map[string]string{}
+	for _, promotion := range promotions {
+		maps.Copy(pausedTargets, promotion.PausedTargetPaths)
+	}
+	targets := maps.Keys(pausedTargets)
+	sort.Strings(targets)
+	comment := "⏸️ Promotions to these paths are paused, they were not promoted:\n"
+	for _, target := range targets {
+		comment += fmt.Sprintf("* `%s` (paused by `%s`)\n", target, pausedTargets[target])
+	}
+	return comment + "\nDelete the marker file and merge this change again(or promote it manually) once promotions are resumed."
+}
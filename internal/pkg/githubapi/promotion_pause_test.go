@@ -0,0 +1,72 @@
+package githubapi
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyPromotionPauses(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	requestedPaths := map[string]int{}
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposContentsByOwnerByRepoByPath,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "main", r.URL.Query().Get("ref"))
+				mu.Lock()
+				requestedPaths[r.URL.Path]++
+				mu.Unlock()
+				if r.URL.Path == "/repos/AnOwner/Arepo/contents/env/prod/promotion-paused" {
+					_, _ = w.Write(mock.MustMarshal(github.RepositoryContent{Content: github.String("incident 123")}))
+					return
+				}
+				mock.WriteError(w, http.StatusNotFound, "Not Found")
+			}),
+		),
+	)
+	details := GhPrClientDetails{
+		Ctx:          context.Background(),
+		GhClientPair: &GhClientPair{v3Client: github.NewClient(mockedHTTPClient)},
+		Owner:        "AnOwner",
+		Repo:         "Arepo",
+		PrLogger:     log.WithFields(log.Fields{"repo": "AnOwner/Arepo"}),
+	}
+	promotions := map[string]PromotionInstance{
+		"env/staging/>env/prod/": {
+			ComputedSyncPaths: map[string]string{
+				"env/prod/c1": "env/staging/c1",
+				"env/prod/c2": "env/staging/c2",
+			},
+		},
+		"env/dev/>env/staging/": {
+			ComputedSyncPaths: map[string]string{
+				"env/staging/c1": "env/dev/c1",
+			},
+		},
+	}
+
+	pausedCount := applyPromotionPauses(details, promotions, "main")
+
+	assert.Equal(t, 2, pausedCount)
+	assert.Empty(t, promotions["env/staging/>env/prod/"].ComputedSyncPaths)
+	assert.Equal(t, map[string]string{
+		"env/prod/c1": "env/prod/promotion-paused",
+		"env/prod/c2": "env/prod/promotion-paused",
+	}, promotions["env/staging/>env/prod/"].PausedTargetPaths)
+	assert.Equal(t, map[string]string{"env/staging/c1": "env/dev/c1"}, promotions["env/dev/>env/staging/"].ComputedSyncPaths)
+	assert.Empty(t, promotions["env/dev/>env/staging/"].PausedTargetPaths)
+	// Shared parent directories are only checked once
+	assert.Equal(t, 1, requestedPaths["/repos/AnOwner/Arepo/contents/env/prod/promotion-paused"])
+	assert.Equal(t, 1, requestedPaths["/repos/AnOwner/Arepo/contents/promotion-paused"])
+
+	comment := pausedPromotionsComment(promotions)
+	assert.Contains(t, comment, "* `env/prod/c1` (paused by `env/prod/promotion-paused`)\n* `env/prod/c2` (paused by `env/prod/promotion-paused`)\n")
+}
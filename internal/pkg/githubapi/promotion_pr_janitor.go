@@ -0,0 +1,208 @@
+package githubapi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	lru "github.com/hashicorp/golang-lru/v2"
+	log "github.com/sirupsen/logrus"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/leader"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/logging"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/maintenance"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/tenancy"
+)
+
+const promotionPrJanitorRepoTimeout = 5 * time.Minute
+
+// PromotionPrJanitorLoop periodically closes abandoned promotion PRs in the repos that configure promotionPrJanitor.
+// Like the PR metrics it relies on GitHub App authentication to list the relevant repos
+func PromotionPrJanitorLoop(mainGhClientCache *lru.Cache[string, GhClientPair], interval time.Duration) {
+	for t := range time.Tick(interval) {
+		log.Debugf("Running promotion PR janitor at %v", t)
+		if leader.Skip("promotion_pr_janitor") || maintenance.Skip("promotion_pr_janitor") {
+			continue
+		}
+		for _, cacheKey := range mainGhClientCache.Keys() {
+			ghClient, ok := mainGhClientCache.Get(cacheKey)
+			if !ok {
+				continue
+			}
+			repos, err := listInstallationRepos(ghClient)
+			if err != nil {
+				log.Errorf("error getting repos for %s: %v", cacheKey, err)
+				continue
+			}
+			for _, repo := range repos {
+				if maintenance.SkipRepo(repo.GetFullName(), "promotion_pr_janitor") {
+					continue
+				}
+				cleanupStalePromotionPrs(ghClient, repo, t)
+			}
+		}
+	}
+}
+
+func listInstallationRepos(ghClient GhClientPair) ([]*github.Repository, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+	listOpts := &github.ListOptions{}
+	repos, _, err := listAllPages(listOpts, func() ([]*github.Repository, *github.Response, error) {
+		perPageRepos, resp, err := ghClient.v3Client.Apps.ListRepos(ctx, listOpts)
+		if err != nil {
+			return nil, resp, err
+		}
+		return perPageRepos.Repositories, resp, nil
+	})
+	return repos, err
+}
+
+func cleanupStalePromotionPrs(ghClient GhClientPair, repo *github.Repository, now time.Time) {
+	ctx, cancel := context.WithTimeout(withShadowModeState(tenancy.NewContext(context.Background(), tenancy.ForRepo(repo.GetFullName()))), promotionPrJanitorRepoTimeout)
+	defer cancel()
+	ghPrClientDetails := GhPrClientDetails{
+		GhClientPair:  &ghClient,
+		Ctx:           ctx,
+		DefaultBranch: repo.GetDefaultBranch(),
+		Owner:         repo.GetOwner().GetLogin(),
+		Repo:          repo.GetName(),
+		PrLogger:      logging.ForRepo(repo.GetFullName()).WithFields(log.Fields{"job": "promotion_pr_janitor"}),
+	}
+	config, err := GetInRepoConfig(ghPrClientDetails, repo.GetDefaultBranch())
+	if err != nil {
+		ghPrClientDetails.PrLogger.Debugf("Skipping repo without a valid configuration: err=%v", err)
+		return
+	}
+	if config.PromotionPrJanitor.MaxAgeDays <= 0 && !config.PromotionPrJanitor.CloseSuperseded {
+		return
+	}
+	prs, err := listOpenPromotionPrs(ghPrClientDetails, repo.GetDefaultBranch())
+	if err != nil {
+		ghPrClientDetails.PrLogger.Errorf("Failed to list open promotion PRs: err=%v", err)
+		return
+	}
+	stale := stalePromotionPrs(ghPrClientDetails, prs, config.PromotionPrJanitor, now)
+	for _, pr := range prs {
+		reason, ok := stale[pr.GetNumber()]
+		if !ok {
+			continue
+		}
+		err := closeStalePromotionPr(ghPrClientDetails, pr, reason)
+		status := "success"
+		if err != nil {
+			status = "failure"
+			ghPrClientDetails.PrLogger.Errorf("Failed to close stale promotion PR %d: err=%v", pr.GetNumber(), err)
+		}
+		prom.InstrumentPromotionPrJanitorClosure(repo.GetFullName(), reason, status)
+	}
+}
+
+// promotionKey identifies what a promotion PR promotes: the source path of its last promotion step and the promoted(target) paths
+func promotionKey(metadata prMetadata) string {
+	lastStep := -1
+	for k := range metadata.PreviousPromotionMetadata {
+		if k > lastStep {
+			lastStep = k
+		}
+	}
+	if lastStep == -1 {
+		return ""
+	}
+	return promotionKeyFor(metadata.PreviousPromotionMetadata[lastStep].SourcePath, metadata.PromotedPaths)
+}
+
+func promotionKeyFor(sourcePath string, promotedPaths []string) string {
+	if len(promotedPaths) == 0 {
+		return ""
+	}
+	sortedPaths := append([]string{}, promotedPaths...)
+	sort.Strings(sortedPaths)
+	return sourcePath + ">" + strings.Join(sortedPaths, ",")
+}
+
+// stalePromotionPrs returns the promotion PRs that should be closed and why(max_age or superseded).
+// Only PRs with valid Telefonistka metadata are considered, so manually opened PRs with a promotion label are left alone
+func stalePromotionPrs(ghPrClientDetails GhPrClientDetails, prs []*github.PullRequest, janitorConfig cfg.PromotionPrJanitorConfig, now time.Time) map[int]string {
+	stale := map[int]string{}
+	newestByKey := map[string]int{}
+	keys := map[int]string{}
+	for _, pr := range prs {
+		prDetails := ghPrClientDetails
+		prDetails.PrMetadata = prMetadata{}
+		prDetails.Ref = pr.GetHead().GetRef()
+		if err := prDetails.getPrMetadata(pr.GetBody()); err != nil {
+			continue
+		}
+		key := promotionKey(prDetails.PrMetadata)
+		if key == "" {
+			continue
+		}
+		keys[pr.GetNumber()] = key
+		if pr.GetNumber() > newestByKey[key] {
+			newestByKey[key] = pr.GetNumber()
+		}
+		if janitorConfig.MaxAgeDays > 0 && pr.GetCreatedAt().Before(now.AddDate(0, 0, -janitorConfig.MaxAgeDays)) {
+			stale[pr.GetNumber()] = "max_age"
+		}
+	}
+	if janitorConfig.CloseSuperseded {
+		for prNumber, key := range keys {
+			if newestByKey[key] != prNumber {
+				stale[prNumber] = "superseded"
+			}
+		}
+	}
+	return stale
+}
+
+func closeStalePromotionPr(ghPrClientDetails GhPrClientDetails, pr *github.PullRequest, reason string) error {
+	ghPrClientDetails.PrLogger.Infof("Closing stale promotion PR %d: reason=%s", pr.GetNumber(), reason)
+	var comment string
+	switch reason {
+	case "superseded":
+		comment = "Closing this promotion PR, it was superseded by a newer promotion of the same paths."
+	default:
+		comment = fmt.Sprintf("Closing this promotion PR, it was opened on %s and wasn't merged. Merge the original change again(or reopen this PR) to promote it.", pr.GetCreatedAt().Format("2006-01-02"))
+	}
+	return closePromotionPr(ghPrClientDetails, pr, comment)
+}
+
+// closePromotionPr comments on a promotion PR, closes it and deletes its branch
+func closePromotionPr(ghPrClientDetails GhPrClientDetails, pr *github.PullRequest, comment string) error {
+	ghPrClientDetails.PrNumber = pr.GetNumber()
+	ghPrClientDetails.PrLogger = ghPrClientDetails.PrLogger.WithFields(log.Fields{"prNumber": pr.GetNumber()})
+	if err := ghPrClientDetails.CommentOnPr(comment); err != nil {
+		return err
+	}
+	_, _, err := retryGhWrite(ghPrClientDetails.Ctx, "edit_pr", func() (*github.PullRequest, *github.Response, error) {
+		return ghPrClientDetails.GhClientPair.v3Client.PullRequests.Edit(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, pr.GetNumber(), &github.PullRequest{State: github.String("closed")})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to close PR: %w", err)
+	}
+
+	// Promotion branches are created by Telefonistka in the same repo, a PR from a fork doesn't have a branch we should delete
+	if !isSameRepoPr(pr) {
+		ghPrClientDetails.PrLogger.Infof("Not deleting the branch of PR %d, it's not from this repo", pr.GetNumber())
+		return nil
+	}
+	_, _, err = retryGhWrite(ghPrClientDetails.Ctx, "delete_ref", func() (struct{}, *github.Response, error) {
+		resp, err := ghPrClientDetails.GhClientPair.v3Client.Git.DeleteRef(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, "heads/"+pr.GetHead().GetRef())
+		return struct{}{}, resp, err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete branch %s: %w", pr.GetHead().GetRef(), err)
+	}
+	return nil
+}
+
+// isSameRepoPr checks the PR head branch is in the base repo, a PR from a deleted fork has no head repo and its branch name can match an unrelated branch
+func isSameRepoPr(pr *github.PullRequest) bool {
+	headRepo := pr.GetHead().GetRepo().GetFullName()
+	return headRepo != "" && headRepo == pr.GetBase().GetRepo().GetFullName()
+}
@@ -0,0 +1,95 @@
+package githubapi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+)
+
+func testPromotionPr(t *testing.T, number int, createdAt time.Time, sourcePath string, promotedPaths ...string) *github.PullRequest {
+	t.Helper()
+	metadata, err := prMetadata{
+		PromotedPaths: promotedPaths,
+		PreviousPromotionMetadata: map[int]promotionInstanceMetaData{
+			number - 1: {SourcePath: sourcePath, TargetPaths: promotedPaths},
+		},
+	}.serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &github.PullRequest{
+		Number:    github.Int(number),
+		CreatedAt: &github.Timestamp{Time: createdAt},
+		Body:      github.String("Promotion path:\n" + prMetadataComment(prMetadataBinding{}, metadata, nil)),
+	}
+}
+
+func TestStalePromotionPrs(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2024, 6, 30, 12, 0, 0, 0, time.UTC)
+	prs := []*github.PullRequest{
+		testPromotionPr(t, 10, now.AddDate(0, 0, -20), "env/staging/", "env/prod/c1"),
+		testPromotionPr(t, 20, now.AddDate(0, 0, -2), "env/staging/", "env/prod/c1"),
+		testPromotionPr(t, 30, now.AddDate(0, 0, -1), "env/staging/", "env/prod/c2"),
+		testPromotionPr(t, 40, now.AddDate(0, 0, -30), "env/dev/", "env/staging/c1"),
+		// Manually opened PRs with a promotion label don't have Telefonistka metadata
+		{Number: github.Int(50), CreatedAt: &github.Timestamp{Time: now.AddDate(-1, 0, 0)}, Body: github.String("manual")},
+	}
+	tests := map[string]struct {
+		config   cfg.PromotionPrJanitorConfig
+		expected map[int]string
+	}{
+		"Disabled": {
+			config:   cfg.PromotionPrJanitorConfig{},
+			expected: map[int]string{},
+		},
+		"Max age": {
+			config:   cfg.PromotionPrJanitorConfig{MaxAgeDays: 14},
+			expected: map[int]string{10: "max_age", 40: "max_age"},
+		},
+		"Superseded": {
+			config:   cfg.PromotionPrJanitorConfig{CloseSuperseded: true},
+			expected: map[int]string{10: "superseded"},
+		},
+		"Both": {
+			config:   cfg.PromotionPrJanitorConfig{MaxAgeDays: 25, CloseSuperseded: true},
+			expected: map[int]string{10: "superseded", 40: "max_age"},
+		},
+	}
+	details := GhPrClientDetails{Ctx: context.Background(), PrLogger: log.WithFields(log.Fields{"repo": "AnOwner/Arepo"})}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, stalePromotionPrs(details, prs, tc.config, now))
+		})
+	}
+}
+
+func TestIsSameRepoPr(t *testing.T) {
+	t.Parallel()
+	branch := func(repo string) *github.PullRequestBranch {
+		if repo == "" {
+			return &github.PullRequestBranch{Ref: github.String("promotions/7")}
+		}
+		return &github.PullRequestBranch{Ref: github.String("promotions/7"), Repo: &github.Repository{FullName: github.String(repo)}}
+	}
+	tests := map[string]struct {
+		head     string
+		expected bool
+	}{
+		"Same repo":         {head: "AnOwner/Arepo", expected: true},
+		"Fork":              {head: "AFork/Arepo", expected: false},
+		"Deleted head repo": {head: "", expected: false},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, isSameRepoPr(&github.PullRequest{Head: branch(tc.head), Base: branch("AnOwner/Arepo")}))
+		})
+	}
+}
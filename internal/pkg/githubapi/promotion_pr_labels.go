@@ -0,0 +1,45 @@
+package githubapi
+
+import (
+	"regexp"
+
+	"github.com/google/go-github/v62/github"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+)
+
+// promotionPrLabels returns the labels of a new promotion PR: "promotion", the configured extra labels and the labels of the original PR that match propagatedPrLabels.
+// Promotion PRs carry the propagated labels too, so they keep following multi step promotions
+func promotionPrLabels(config *cfg.Config, originalPrLabels []*github.Label) ([]string, error) {
+	labels := []string{"promotion"}
+	seen := map[string]bool{"promotion": true}
+	add := func(label string) {
+		if !seen[label] {
+			seen[label] = true
+			labels = append(labels, label)
+		}
+	}
+	for _, label := range config.PromtionPrLables {
+		add(label)
+	}
+	for _, expression := range config.PropagatedPrLabels {
+		labelRegex, err := regexp.Compile(expression)
+		if err != nil {
+			return labels, err
+		}
+		for _, label := range originalPrLabels {
+			if labelRegex.MatchString(label.GetName()) {
+				add(label.GetName())
+			}
+		}
+	}
+	return labels, nil
+}
+
+// promotionCommitMessage appends the configured suffix(e.g. "[skip ci]") to the message of promotion sync commits
+func promotionCommitMessage(config *cfg.Config, sourcePath string) string {
+	commitMsg := "Syncing from " + sourcePath
+	if config.PromotionCommitMessageSuffix != "" {
+		commitMsg += " " + config.PromotionCommitMessageSuffix
+	}
+	return commitMsg
+}
@@ -0,0 +1,61 @@
+package githubapi
+
+import (
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/stretchr/testify/assert"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+)
+
+func TestPromotionPrLabels(t *testing.T) {
+	t.Parallel()
+	originalPrLabels := []*github.Label{
+		{Name: github.String("team/foo")},
+		{Name: github.String("hotfix")},
+		{Name: github.String("promotion")},
+		{Name: github.String("noop")},
+	}
+	tests := map[string]struct {
+		config        *cfg.Config
+		expected      []string
+		expectedError bool
+	}{
+		"default": {
+			config:   &cfg.Config{},
+			expected: []string{"promotion"},
+		},
+		"extra labels": {
+			config:   &cfg.Config{PromtionPrLables: []string{"deploy", "promotion"}},
+			expected: []string{"promotion", "deploy"},
+		},
+		"propagated labels": {
+			config:   &cfg.Config{PromtionPrLables: []string{"hotfix"}, PropagatedPrLabels: []string{"^team/", "^hotfix$", "^promotion$"}},
+			expected: []string{"promotion", "hotfix", "team/foo"},
+		},
+		"invalid regex": {
+			config:        &cfg.Config{PropagatedPrLabels: []string{"("}},
+			expected:      []string{"promotion"},
+			expectedError: true,
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			labels, err := promotionPrLabels(tc.config, originalPrLabels)
+			if tc.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expected, labels)
+		})
+	}
+}
+
+func TestPromotionCommitMessage(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "Syncing from env/staging/", promotionCommitMessage(&cfg.Config{}, "env/staging/"))
+	assert.Equal(t, "Syncing from env/staging/ [skip ci]", promotionCommitMessage(&cfg.Config{PromotionCommitMessageSuffix: "[skip ci]"}, "env/staging/"))
+}
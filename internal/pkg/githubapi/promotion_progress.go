@@ -0,0 +1,124 @@
+package githubapi
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v62/github"
+	"golang.org/x/exp/maps"
+)
+
+const promotionProgressMarker = "<!-- telefonistka-promotion-progress %s -->"
+
+var openedPromotionRegex = regexp.MustCompile("(?m)^- \\[x\\] `([^`]*)` #(\\d+)$")
+
+// promotionProgress records the promotion PRs already opened for a merged commit in a comment on the source PR,
+// so a re-delivered merge event(e.g. after being rate limited halfway) opens only the missing ones
+type promotionProgress struct {
+	sourceSHA string
+	commentID int64
+	// opened maps the promotion key(see promotionKeyFor) to the number of its promotion PR
+	opened map[string]int
+}
+
+// promotionProgressComment lists promotionKeys and the promotions opened so far, the ones opened by an earlier run(e.g. before a promotion train departed) are kept
+func promotionProgressComment(sourceSHA string, promotionKeys []string, opened map[string]int) string {
+	keys := append([]string{}, promotionKeys...)
+	for key := range opened {
+		if !slices.Contains(promotionKeys, key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, promotionProgressMarker+"\n", sourceSHA)
+	fmt.Fprintf(&sb, "### Promotions of %s\n\n", sourceSHA)
+	for _, key := range keys {
+		if prNumber, ok := opened[key]; ok {
+			fmt.Fprintf(&sb, "- [x] `%s` #%d\n", key, prNumber)
+		} else {
+			fmt.Fprintf(&sb, "- [ ] `%s`\n", key)
+		}
+	}
+	if len(opened) < len(keys) {
+		fmt.Fprintf(&sb, "\n:hourglass: %d of %d promotion PRs opened so far\n", len(opened), len(keys))
+	}
+	return sb.String()
+}
+
+// parseOpenedPromotions reads the opened promotions back from a promotionProgressComment
+func parseOpenedPromotions(commentBody string) map[string]int {
+	opened := map[string]int{}
+	for _, match := range openedPromotionRegex.FindAllStringSubmatch(commentBody, -1) {
+		prNumber, err := strconv.Atoi(match[2])
+		if err != nil {
+			continue
+		}
+		opened[match[1]] = prNumber
+	}
+	return opened
+}
+
+// loadPromotionProgress finds the progress comment of sourceSHA on the source PR, a missing comment means nothing was opened yet
+func loadPromotionProgress(ghPrClientDetails GhPrClientDetails, sourceSHA string) (*promotionProgress, error) {
+	progress := &promotionProgress{sourceSHA: sourceSHA, opened: map[string]int{}}
+	comment, err := findCommentWithMarker(ghPrClientDetails, fmt.Sprintf(promotionProgressMarker, sourceSHA))
+	if err != nil {
+		return nil, err
+	}
+	if comment != nil {
+		progress.commentID = comment.GetID()
+		progress.opened = parseOpenedPromotions(comment.GetBody())
+	}
+	return progress, nil
+}
+
+// record marks the promotion as opened and updates(or creates) the progress comment
+func (p *promotionProgress) record(ghPrClientDetails GhPrClientDetails, promotionKeys []string, key string, prNumber int) error {
+	p.opened[key] = prNumber
+	body := promotionProgressComment(p.sourceSHA, promotionKeys, p.opened)
+	if p.commentID != 0 {
+		return ghPrClientDetails.editPrComment(p.commentID, body)
+	}
+	comment, err := ghPrClientDetails.createPrComment(body)
+	if err != nil {
+		return err
+	}
+	p.commentID = comment.GetID()
+	return nil
+}
+
+// promotionKeys returns the sorted keys of the promotions, the progress comment lists them in this order
+func promotionKeys(promotions map[string]PromotionInstance) []string {
+	keys := []string{}
+	for _, promotion := range promotions {
+		keys = append(keys, promotionKeyFor(promotion.Metadata.SourcePath, maps.Keys(promotion.ComputedSyncPaths)))
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// createOrResetBranch creates the promotion branch, a branch left behind by an earlier attempt that failed before opening its PR is reset to commit
+func createOrResetBranch(ghPrClientDetails GhPrClientDetails, commit *github.Commit, newBranchName string) (string, error) {
+	newBranchRef, err := createBranch(ghPrClientDetails, commit, newBranchName)
+	if !errors.Is(err, ErrValidationFailed) || !strings.Contains(err.Error(), "Reference already exists") {
+		return newBranchRef, err
+	}
+	ghPrClientDetails.PrLogger.Infof("Branch %s already exists, resetting it to %s", newBranchName, commit.GetSHA())
+	newBranchRef = "refs/heads/" + newBranchName
+	_, _, err = retryGhWrite(ghPrClientDetails.Ctx, "update_ref", func() (*github.Reference, *github.Response, error) {
+		return ghPrClientDetails.GhClientPair.v3Client.Git.UpdateRef(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, &github.Reference{
+			Ref:    github.String(newBranchRef),
+			Object: &github.GitObject{SHA: commit.SHA},
+		}, true)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to reset %s: %w", newBranchName, err)
+	}
+	return newBranchRef, nil
+}
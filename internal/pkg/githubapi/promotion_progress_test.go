@@ -0,0 +1,109 @@
+package githubapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPromotionProgressCommentRoundTrip(t *testing.T) {
+	t.Parallel()
+	keys := []string{"env/prod/app>env/prod/region1/app", "env/staging/app>env/prod/app"}
+	comment := promotionProgressComment("abc123", keys, map[string]int{"env/staging/app>env/prod/app": 12})
+	assert.Contains(t, comment, "<!-- telefonistka-promotion-progress abc123 -->")
+	assert.Contains(t, comment, "- [ ] `env/prod/app>env/prod/region1/app`\n")
+	assert.Contains(t, comment, "1 of 2 promotion PRs opened so far")
+	assert.Equal(t, map[string]int{"env/staging/app>env/prod/app": 12}, parseOpenedPromotions(comment))
+}
+
+func TestPromotionProgressCommentKeepsEarlierPromotions(t *testing.T) {
+	t.Parallel()
+	opened := map[string]int{"env/staging/app>env/prod/app": 12, "env/prod/app>env/prod/region1/app": 13}
+	comment := promotionProgressComment("abc123", []string{"env/prod/app>env/prod/region1/app"}, opened)
+	assert.Equal(t, opened, parseOpenedPromotions(comment))
+	assert.NotContains(t, comment, "opened so far")
+}
+
+func TestLoadPromotionProgress(t *testing.T) {
+	t.Parallel()
+	comments := []*github.IssueComment{
+		{ID: github.Int64(1), Body: github.String("<!-- telefonistka_tag -->\nSome other comment")},
+		{ID: github.Int64(2), Body: github.String("<!-- telefonistka_tag -->\n" + promotionProgressComment("abc123", []string{"a>b", "c>d"}, map[string]int{"a>b": 7}))},
+	}
+	var editedBody string
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(mock.GetReposIssuesCommentsByOwnerByRepoByIssueNumber, comments),
+		mock.WithRequestMatchHandler(
+			mock.PatchReposIssuesCommentsByOwnerByRepoByCommentId,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/repos/AnOwner/Arepo/issues/comments/2", r.URL.Path)
+				var comment github.IssueComment
+				_ = json.NewDecoder(r.Body).Decode(&comment)
+				editedBody = comment.GetBody()
+				_, _ = w.Write(mock.MustMarshal(comment))
+			}),
+		),
+	)
+	details := GhPrClientDetails{
+		Ctx:          context.Background(),
+		GhClientPair: &GhClientPair{v3Client: github.NewClient(mockedHTTPClient)},
+		Owner:        "AnOwner",
+		Repo:         "Arepo",
+		PrNumber:     1,
+		PrLogger:     log.WithFields(log.Fields{"repo": "AnOwner/Arepo"}),
+	}
+
+	progress, err := loadPromotionProgress(details, "abc123")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), progress.commentID)
+	assert.Equal(t, map[string]int{"a>b": 7}, progress.opened)
+
+	err = progress.record(details, []string{"a>b", "c>d"}, "c>d", 8)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"a>b": 7, "c>d": 8}, parseOpenedPromotions(editedBody))
+}
+
+func TestCreateOrResetBranchResetsLeftoverBranch(t *testing.T) {
+	t.Parallel()
+	var resetSHA string
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.PostReposGitRefsByOwnerByRepo,
+			http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				mock.WriteError(w, http.StatusUnprocessableEntity, "Reference already exists")
+			}),
+		),
+		mock.WithRequestMatchHandler(
+			mock.PatchReposGitRefsByOwnerByRepoByRef,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body struct {
+					SHA   string `json:"sha"`
+					Force bool   `json:"force"`
+				}
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				assert.True(t, body.Force)
+				resetSHA = body.SHA
+				_, _ = w.Write(mock.MustMarshal(github.Reference{Ref: github.String("refs/heads/promotions/1-branch")}))
+			}),
+		),
+	)
+	details := GhPrClientDetails{
+		Ctx:          context.Background(),
+		GhClientPair: &GhClientPair{v3Client: github.NewClient(mockedHTTPClient)},
+		Owner:        "AnOwner",
+		Repo:         "Arepo",
+		PrNumber:     1,
+		PrLogger:     log.WithFields(log.Fields{"repo": "AnOwner/Arepo"}),
+	}
+
+	ref, err := createOrResetBranch(details, &github.Commit{SHA: github.String("def456")}, "promotions/1-branch")
+	assert.NoError(t, err)
+	assert.Equal(t, "refs/heads/promotions/1-branch", ref)
+	assert.Equal(t, "def456", resetSHA)
+}
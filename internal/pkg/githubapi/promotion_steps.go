@@ -0,0 +1,144 @@
+package githubapi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+	"golang.org/x/exp/maps"
+)
+
+// promotionStep is a step of a canary promotion that wasn't opened yet
+type promotionStep struct {
+	TargetPaths []string          `json:"targetPaths"`
+	SyncPaths   map[string]string `json:"syncPaths"` // key is target, value is source
+}
+
+// promotionStepsMetadata is persisted in the promotion PR of each canary step, merging it opens the promotion PR of the next step
+type promotionStepsMetadata struct {
+	Step  int `json:"step"` // 1 based
+	Steps int `json:"steps"`
+	// The promotion PR of the previous step, this step depends on it
+	PreviousStepPr    int             `json:"previousStepPr,omitempty"`
+	SourcePath        string          `json:"sourcePath"`
+	TargetDescription string          `json:"targetDescription"`
+	ComponentNames    []string        `json:"componentNames"`
+	AutoMerge         bool            `json:"autoMerge"`
+	NextSteps         []promotionStep `json:"nextSteps,omitempty"`
+}
+
+// promotionStepTargetPaths groups the target paths(in the configured order) by canary step, each step adds the target paths up to its percentage(rounded up) and the target paths the steps didn't reach get a last step
+func promotionStepTargetPaths(targetPaths []string, steps []cfg.PromotionStep) [][]string {
+	groups := [][]string{}
+	done := 0
+	for _, step := range steps {
+		count := (step.Percent*len(targetPaths) + 99) / 100
+		if count > len(targetPaths) {
+			count = len(targetPaths)
+		}
+		if count <= done {
+			continue
+		}
+		groups = append(groups, append([]string{}, targetPaths[done:count]...))
+		done = count
+	}
+	if done < len(targetPaths) {
+		groups = append(groups, append([]string{}, targetPaths[done:]...))
+	}
+	return groups
+}
+
+// splitPromotionStep splits the sync paths of a promotion by the canary step of their target path, steps without sync paths(e.g. blocked by the component configuration) are dropped
+func splitPromotionStep(promotion PromotionInstance) []promotionStep {
+	steps := []promotionStep{}
+	for _, targetPaths := range promotion.Metadata.StepTargetPaths {
+		step := promotionStep{TargetPaths: targetPaths, SyncPaths: map[string]string{}}
+		for target, source := range promotion.ComputedSyncPaths {
+			for _, targetPath := range targetPaths {
+				if strings.HasPrefix(target, targetPath) {
+					step.SyncPaths[target] = source
+					break
+				}
+			}
+		}
+		if len(step.SyncPaths) > 0 {
+			sort.Strings(step.TargetPaths)
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+func promotionStepDescription(targetDescription string, step int, steps int) string {
+	return fmt.Sprintf("%s (step %d/%d)", targetDescription, step, steps)
+}
+
+// splitPromotionSteps limits the promotions with canary steps to their first step, the next steps are recorded in the promotion PR metadata
+func splitPromotionSteps(promotions map[string]PromotionInstance) map[string]PromotionInstance {
+	result := map[string]PromotionInstance{}
+	for key, promotion := range promotions {
+		if len(promotion.Metadata.StepTargetPaths) < 2 {
+			result[key] = promotion
+			continue
+		}
+		steps := splitPromotionStep(promotion)
+		if len(steps) < 2 {
+			result[key] = promotion
+			continue
+		}
+		promotion.Metadata.Steps = &promotionStepsMetadata{
+			Step:              1,
+			Steps:             len(steps),
+			SourcePath:        promotion.Metadata.SourcePath,
+			TargetDescription: promotion.Metadata.TargetDescription,
+			ComponentNames:    promotion.Metadata.ComponentNames,
+			AutoMerge:         promotion.Metadata.AutoMerge,
+			NextSteps:         steps[1:],
+		}
+		promotion.Metadata.TargetPaths = steps[0].TargetPaths
+		promotion.Metadata.TargetDescription = promotionStepDescription(promotion.Metadata.TargetDescription, 1, len(steps))
+		promotion.ComputedSyncPaths = steps[0].SyncPaths
+		result[key] = promotion
+	}
+	return result
+}
+
+// nextPromotionStep returns the promotion of the canary step that follows the merged step promotion PR
+func nextPromotionStep(steps *promotionStepsMetadata, prNumber int, pathNames map[string]string) (string, PromotionInstance, bool) {
+	if steps == nil || len(steps.NextSteps) == 0 {
+		return "", PromotionInstance{}, false
+	}
+	next := steps.NextSteps[0]
+	nextSteps := *steps
+	nextSteps.Step++
+	nextSteps.PreviousStepPr = prNumber
+	nextSteps.NextSteps = steps.NextSteps[1:]
+	return steps.SourcePath + ">" + strings.Join(next.TargetPaths, "|"), PromotionInstance{
+		Metadata: PromotionInstanceMetaData{
+			SourcePath:                     steps.SourcePath,
+			TargetPaths:                    next.TargetPaths,
+			TargetDescription:              promotionStepDescription(steps.TargetDescription, nextSteps.Step, steps.Steps),
+			ComponentNames:                 steps.ComponentNames,
+			PerComponentSkippedTargetPaths: map[string][]string{},
+			AutoMerge:                      steps.AutoMerge,
+			PathNames:                      pathNames,
+			Steps:                          &nextSteps,
+		},
+		ComputedSyncPaths: next.SyncPaths,
+	}, true
+}
+
+// promotionStepsPrBody describes the canary step of a promotion PR and links the step it depends on
+func promotionStepsPrBody(steps *promotionStepsMetadata) string {
+	body := fmt.Sprintf("\n🐤 This is step %d/%d of a canary promotion to %s", steps.Step, steps.Steps, steps.TargetDescription)
+	if steps.PreviousStepPr != 0 {
+		body += fmt.Sprintf(", it follows #%d", steps.PreviousStepPr)
+	}
+	if len(steps.NextSteps) > 0 {
+		nextTargets := maps.Keys(steps.NextSteps[0].SyncPaths)
+		sort.Strings(nextTargets)
+		body += fmt.Sprintf(".\nThe next step(`%s`) is opened once this PR is merged", strings.Join(nextTargets, "`, `"))
+	}
+	return body + ".\n"
+}
@@ -0,0 +1,87 @@
+package githubapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+)
+
+func TestPromotionStepTargetPaths(t *testing.T) {
+	t.Parallel()
+	targetPaths := []string{"env/prod/us-east4/", "env/prod/eu-west1/", "env/prod/us-central1/", "env/prod/asia-east1/"}
+	tests := map[string]struct {
+		steps    []cfg.PromotionStep
+		expected [][]string
+	}{
+		"Single canary": {
+			steps:    []cfg.PromotionStep{{Percent: 10}},
+			expected: [][]string{{"env/prod/us-east4/"}, {"env/prod/eu-west1/", "env/prod/us-central1/", "env/prod/asia-east1/"}},
+		},
+		"Percentages are cumulative": {
+			steps:    []cfg.PromotionStep{{Percent: 25}, {Percent: 50}, {Percent: 100}},
+			expected: [][]string{{"env/prod/us-east4/"}, {"env/prod/eu-west1/"}, {"env/prod/us-central1/", "env/prod/asia-east1/"}},
+		},
+		"Steps that add no target are skipped": {
+			steps:    []cfg.PromotionStep{{Percent: 20}, {Percent: 25}, {Percent: 60}},
+			expected: [][]string{{"env/prod/us-east4/"}, {"env/prod/eu-west1/", "env/prod/us-central1/"}, {"env/prod/asia-east1/"}},
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, promotionStepTargetPaths(targetPaths, tc.steps))
+		})
+	}
+}
+
+func TestSplitPromotionSteps(t *testing.T) {
+	t.Parallel()
+	promotions := map[string]PromotionInstance{
+		"env/staging/>env/prod/eu-west1/|env/prod/us-east4/": {
+			Metadata: PromotionInstanceMetaData{
+				SourcePath:        "env/staging/",
+				TargetPaths:       []string{"env/prod/eu-west1/", "env/prod/us-east4/"},
+				TargetDescription: "prod",
+				ComponentNames:    []string{"app1", "app2"},
+				AutoMerge:         true,
+				StepTargetPaths:   [][]string{{"env/prod/us-east4/"}, {"env/prod/eu-west1/"}},
+			},
+			ComputedSyncPaths: map[string]string{
+				"env/prod/us-east4/app1": "env/staging/app1",
+				"env/prod/us-east4/app2": "env/staging/app2",
+				"env/prod/eu-west1/app1": "env/staging/app1",
+				"env/prod/eu-west1/app2": "env/staging/app2",
+			},
+		},
+		"env/dev/>env/staging/": {
+			Metadata:          PromotionInstanceMetaData{SourcePath: "env/dev/", TargetPaths: []string{"env/staging/"}},
+			ComputedSyncPaths: map[string]string{"env/staging/app3": "env/dev/app3"},
+		},
+	}
+
+	split := splitPromotionSteps(promotions)
+	assert.Len(t, split, 2)
+	assert.Nil(t, split["env/dev/>env/staging/"].Metadata.Steps)
+	firstStep := split["env/staging/>env/prod/eu-west1/|env/prod/us-east4/"]
+	assert.Equal(t, []string{"env/prod/us-east4/"}, firstStep.Metadata.TargetPaths)
+	assert.Equal(t, "prod (step 1/2)", firstStep.Metadata.TargetDescription)
+	assert.Equal(t, map[string]string{"env/prod/us-east4/app1": "env/staging/app1", "env/prod/us-east4/app2": "env/staging/app2"}, firstStep.ComputedSyncPaths)
+	assert.Equal(t, 1, firstStep.Metadata.Steps.Step)
+	assert.Len(t, firstStep.Metadata.Steps.NextSteps, 1)
+
+	key, secondStep, ok := nextPromotionStep(firstStep.Metadata.Steps, 42, nil)
+	assert.True(t, ok)
+	assert.Equal(t, "env/staging/>env/prod/eu-west1/", key)
+	assert.Equal(t, "prod (step 2/2)", secondStep.Metadata.TargetDescription)
+	assert.Equal(t, []string{"app1", "app2"}, secondStep.Metadata.ComponentNames)
+	assert.True(t, secondStep.Metadata.AutoMerge)
+	assert.Equal(t, map[string]string{"env/prod/eu-west1/app1": "env/staging/app1", "env/prod/eu-west1/app2": "env/staging/app2"}, secondStep.ComputedSyncPaths)
+	assert.Equal(t, 42, secondStep.Metadata.Steps.PreviousStepPr)
+	assert.Empty(t, secondStep.Metadata.Steps.NextSteps)
+	assert.Contains(t, promotionStepsPrBody(secondStep.Metadata.Steps), "step 2/2 of a canary promotion to prod, it follows #42")
+
+	_, _, ok = nextPromotionStep(secondStep.Metadata.Steps, 43, nil)
+	assert.False(t, ok)
+}
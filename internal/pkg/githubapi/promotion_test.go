@@ -707,3 +707,43 @@ func TestGeneratePromotionMetadataWithOutDesc(t *testing.T) {
 	)
 	generatePromotionPlanMetadataTestHelper(t, config, expectedPromotion, mockedHTTPClient)
 }
+
+func TestGenerateHotfixPromotionPlan(t *testing.T) {
+	t.Parallel()
+	config := &cfg.Config{
+		PromotionPaths: []cfg.PromotionPath{
+			{
+				SourcePath:   "env/dev/",
+				PromotionPrs: []cfg.PromotionPr{{TargetPaths: []string{"env/staging/"}}},
+			},
+			{
+				SourcePath:   "env/staging/",
+				PromotionPrs: []cfg.PromotionPr{{TargetPaths: []string{"env/prod-us/", "env/prod-eu/"}}},
+			},
+		},
+		Hotfix: cfg.HotfixConfig{
+			TargetPaths:       []string{"env/prod-us/", "env/prod-eu/"},
+			TargetDescription: "prod",
+		},
+	}
+	getConfig := func(componentPath string) (*cfg.ComponentConfig, error) { return nil, nil }
+	logger := log.WithFields(log.Fields{"test": t.Name()})
+
+	promotions := GeneratePromotionPlanForFiles(logger, config, []string{"env/dev/componentA/file.yaml"}, []string{"bug"}, getConfig)
+	assert.Contains(t, promotions, "env/dev/>env/staging/")
+
+	promotions = GeneratePromotionPlanForFiles(logger, config, []string{"env/dev/componentA/file.yaml"}, []string{"hotfix"}, getConfig)
+	expectedKey := "env/dev/>env/prod-eu/|env/prod-us/"
+	assert.Len(t, promotions, 1)
+	assert.Equal(t, map[string]string{
+		"env/prod-eu/componentA": "env/dev/componentA",
+		"env/prod-us/componentA": "env/dev/componentA",
+	}, promotions[expectedKey].ComputedSyncPaths)
+	assert.Equal(t, "prod", promotions[expectedKey].Metadata.TargetDescription)
+	assert.Equal(t, []string{"env/staging/"}, promotions[expectedKey].Metadata.HotfixSkippedTargetPaths)
+	assert.Equal(t, map[string]string{"env/staging/componentA": "env/prod-eu/componentA"}, generateHotfixSkippedPaths(promotions[expectedKey]))
+
+	// The last hop is already a hotfix target, so it's promoted as usual
+	promotions = GeneratePromotionPlanForFiles(logger, config, []string{"env/staging/componentA/file.yaml"}, []string{"hotfix"}, getConfig)
+	assert.Empty(t, promotions["env/staging/>env/prod-eu/|env/prod-us/"].Metadata.HotfixSkippedTargetPaths)
+}
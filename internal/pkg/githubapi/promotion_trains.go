@@ -0,0 +1,251 @@
+package githubapi
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	lru "github.com/hashicorp/golang-lru/v2"
+	log "github.com/sirupsen/logrus"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/leader"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/logging"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/maintenance"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/tenancy"
+)
+
+// Merged PRs with promotions held by a promotion train carry this label until the held promotions are opened
+const promotionTrainPendingLabel = "promotion-train-pending"
+
+const promotionTrainRepoTimeout = 10 * time.Minute
+
+// promotionTrainWindowOpen checks if now is inside the train window, a misconfigured train is considered open so it doesn't hold promotions forever
+func promotionTrainWindowOpen(train cfg.PromotionTrain, now time.Time) bool {
+	loc := time.UTC
+	if train.TimeZone != "" {
+		var err error
+		loc, err = time.LoadLocation(train.TimeZone)
+		if err != nil {
+			log.Errorf("Promotion train %s has an invalid timeZone, ignoring its window: err=%v", train.TargetPathRegex, err)
+			return true
+		}
+	}
+	now = now.In(loc)
+	if len(train.Days) > 0 && !contains(train.Days, now.Weekday().String()[:3]) {
+		return false
+	}
+	minuteOfDay := now.Hour()*60 + now.Minute()
+	start, err := parseMinuteOfDay(train.StartTime, 0)
+	if err != nil {
+		log.Errorf("Promotion train %s has an invalid startTime, ignoring its window: err=%v", train.TargetPathRegex, err)
+		return true
+	}
+	end, err := parseMinuteOfDay(train.EndTime, 24*60)
+	if err != nil {
+		log.Errorf("Promotion train %s has an invalid endTime, ignoring its window: err=%v", train.TargetPathRegex, err)
+		return true
+	}
+	return minuteOfDay >= start && minuteOfDay < end
+}
+
+func parseMinuteOfDay(clock string, defaultMinute int) (int, error) {
+	if clock == "" {
+		return defaultMinute, nil
+	}
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// promotionTrains returns the trains that promotions to any of the promotion target paths ride
+func promotionTrains(config *cfg.Config, promotion PromotionInstance) []cfg.PromotionTrain {
+	var trains []cfg.PromotionTrain
+	for _, train := range config.PromotionTrains {
+		for _, targetPath := range promotion.Metadata.TargetPaths {
+			if match, _ := regexp.MatchString(train.TargetPathRegex, targetPath); match {
+				trains = append(trains, train)
+				break
+			}
+		}
+	}
+	return trains
+}
+
+func promotionHeld(config *cfg.Config, promotion PromotionInstance, now time.Time) bool {
+	for _, train := range promotionTrains(config, promotion) {
+		if !promotionTrainWindowOpen(train, now) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitHeldPromotions separates the promotions that wait for a closed promotion train window
+func splitHeldPromotions(config *cfg.Config, promotions map[string]PromotionInstance, now time.Time) (ready map[string]PromotionInstance, held map[string]PromotionInstance) {
+	ready = map[string]PromotionInstance{}
+	held = map[string]PromotionInstance{}
+	for key, promotion := range promotions {
+		if promotionHeld(config, promotion, now) {
+			held[key] = promotion
+		} else {
+			ready[key] = promotion
+		}
+	}
+	return ready, held
+}
+
+// holdPromotions marks the merged PR so the promotion train job opens its held promotions once their window is open
+func holdPromotions(ghPrClientDetails GhPrClientDetails, held map[string]PromotionInstance) error {
+	err := labelPr(ghPrClientDetails, ghPrClientDetails.PrNumber, []string{promotionTrainPendingLabel})
+	if err != nil {
+		return err
+	}
+	heldDescriptions := []string{}
+	for _, promotion := range held {
+		heldDescriptions = append(heldDescriptions, fmt.Sprintf("* %s ➡️ %s", strings.Join(promotion.Metadata.ComponentNames, ","), promotion.Metadata.TargetDescription))
+	}
+	sort.Strings(heldDescriptions)
+	return ghPrClientDetails.CommentOnPr("🚂 These promotions wait for their promotion train window, they will be opened once it's open:\n" + strings.Join(heldDescriptions, "\n"))
+}
+
+// PromotionTrainLoop periodically opens the promotions held by promotion trains whose window is open, in the repos that configure promotionTrains
+func PromotionTrainLoop(mainGhClientCache *lru.Cache[string, GhClientPair], prApproverGhClientCache *lru.Cache[string, GhClientPair], interval time.Duration) {
+	for t := range time.Tick(interval) {
+		log.Debugf("Running promotion trains at %v", t)
+		if leader.Skip("promotion_trains") || maintenance.Skip("promotion_trains") {
+			continue
+		}
+		for _, cacheKey := range mainGhClientCache.Keys() {
+			ghClient, ok := mainGhClientCache.Get(cacheKey)
+			if !ok {
+				continue
+			}
+			var approverClient *github.Client
+			if approverGhClient, ok := prApproverGhClientCache.Get(cacheKey); ok {
+				approverClient = approverGhClient.v3Client
+			}
+			repos, err := listInstallationRepos(ghClient)
+			if err != nil {
+				log.Errorf("error getting repos for %s: %v", cacheKey, err)
+				continue
+			}
+			for _, repo := range repos {
+				if maintenance.SkipRepo(repo.GetFullName(), "promotion_trains") {
+					continue
+				}
+				departPromotionTrains(ghClient, approverClient, repo, t)
+			}
+		}
+	}
+}
+
+func departPromotionTrains(ghClient GhClientPair, approverClient *github.Client, repo *github.Repository, now time.Time) {
+	ctx, cancel := context.WithTimeout(withShadowModeState(tenancy.NewContext(context.Background(), tenancy.ForRepo(repo.GetFullName()))), promotionTrainRepoTimeout)
+	defer cancel()
+	repoDetails := GhPrClientDetails{
+		GhClientPair:  &ghClient,
+		Ctx:           ctx,
+		DefaultBranch: repo.GetDefaultBranch(),
+		Owner:         repo.GetOwner().GetLogin(),
+		Repo:          repo.GetName(),
+		RepoURL:       repo.GetHTMLURL(),
+		PrLogger:      logging.ForRepo(repo.GetFullName()).WithFields(log.Fields{"job": "promotion_train"}),
+	}
+	config, err := GetInRepoConfig(repoDetails, repo.GetDefaultBranch())
+	if err != nil || len(config.PromotionTrains) == 0 {
+		return
+	}
+	pendingPrs, err := listPromotionTrainPendingPrs(repoDetails)
+	if err != nil {
+		repoDetails.PrLogger.Errorf("Failed to list PRs with held promotions: err=%v", err)
+		return
+	}
+	// Older PRs go first so their promotions are opened(and possibly superseded) in merge order
+	sort.Slice(pendingPrs, func(i, j int) bool { return pendingPrs[i].GetNumber() < pendingPrs[j].GetNumber() })
+	for _, pr := range pendingPrs {
+		err := departHeldPromotions(repoDetails, approverClient, config, pr, now)
+		if err != nil {
+			repoDetails.PrLogger.Errorf("Failed to open held promotions of PR %d: err=%v", pr.GetNumber(), err)
+		}
+	}
+}
+
+func listPromotionTrainPendingPrs(ghPrClientDetails GhPrClientDetails) ([]*github.PullRequest, error) {
+	listOpts := &github.IssueListByRepoOptions{State: "closed", Labels: []string{promotionTrainPendingLabel}}
+	issues, _, err := listAllPages(&listOpts.ListOptions, func() ([]*github.Issue, *github.Response, error) {
+		return ghPrClientDetails.GhClientPair.v3Client.Issues.ListByRepo(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, listOpts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	var prs []*github.PullRequest
+	for _, issue := range issues {
+		if !issue.IsPullRequest() {
+			continue
+		}
+		pr, resp, err := ghPrClientDetails.GhClientPair.v3Client.PullRequests.Get(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, issue.GetNumber())
+		prom.InstrumentGhCall(resp)
+		if err != nil {
+			return nil, err
+		}
+		if pr.GetMerged() {
+			prs = append(prs, pr)
+		}
+	}
+	return prs, nil
+}
+
+// departHeldPromotions opens the promotions of a merged PR that were held by promotion trains, once all of their windows are open
+func departHeldPromotions(repoDetails GhPrClientDetails, approverClient *github.Client, config *cfg.Config, pr *github.PullRequest, now time.Time) error {
+	ghPrClientDetails := repoDetails
+	ghPrClientDetails.PrNumber = pr.GetNumber()
+	ghPrClientDetails.PrAuthor = pr.GetUser().GetLogin()
+	ghPrClientDetails.PrSHA = pr.GetHead().GetSHA()
+	ghPrClientDetails.Ref = pr.GetHead().GetRef()
+	ghPrClientDetails.Labels = pr.Labels
+	ghPrClientDetails.PrLogger = repoDetails.PrLogger.WithFields(log.Fields{"prNumber": pr.GetNumber()})
+	_ = ghPrClientDetails.getPrMetadata(pr.GetBody())
+
+	promotions, err := GeneratePromotionPlan(ghPrClientDetails, config, repoDetails.DefaultBranch)
+	if err != nil {
+		return err
+	}
+	trainPromotions := map[string]PromotionInstance{}
+	for key, promotion := range promotions {
+		// The promotions that don't ride a train were opened when the PR was merged
+		if len(promotionTrains(config, promotion)) > 0 {
+			trainPromotions[key] = promotion
+		}
+	}
+	_, held := splitHeldPromotions(config, trainPromotions, now)
+	if len(held) > 0 {
+		ghPrClientDetails.PrLogger.Debugf("%d promotions are still held", len(held))
+		return nil
+	}
+	if config.AutoApprovePromotionPrs && approverClient == nil {
+		return fmt.Errorf("promotion PRs should be auto approved but there is no approver client")
+	}
+	ghPrClientDetails.PrLogger.Infof("Opening %d promotions held by promotion trains", len(trainPromotions))
+	trainPromotions = preparePromotionWave(config, trainPromotions)
+	pausedTargets := applyPromotionPauses(ghPrClientDetails, trainPromotions, repoDetails.DefaultBranch)
+	if pausedTargets > 0 {
+		prom.InstrumentPausedPromotionTargets(ghPrClientDetails.Owner+"/"+ghPrClientDetails.Repo, pausedTargets)
+		_ = ghPrClientDetails.CommentOnPr(pausedPromotionsComment(trainPromotions))
+	}
+	err = openPromotionPrs(ghPrClientDetails, config, trainPromotions, repoDetails.DefaultBranch, approverClient, pr.GetMergeCommitSHA())
+	if err != nil {
+		return err
+	}
+	_, _, err = retryGhWrite(ghPrClientDetails.Ctx, "remove_label", func() (struct{}, *github.Response, error) {
+		resp, err := ghPrClientDetails.GhClientPair.v3Client.Issues.RemoveLabelForIssue(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, pr.GetNumber(), promotionTrainPendingLabel)
+		return struct{}{}, resp, err
+	})
+	return err
+}
@@ -0,0 +1,85 @@
+package githubapi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+)
+
+func TestPromotionTrainWindowOpen(t *testing.T) {
+	t.Parallel()
+	weekdays := cfg.PromotionTrain{Days: []string{"Mon", "Tue", "Wed", "Thu", "Fri"}, StartTime: "10:00", EndTime: "16:00"}
+	tests := map[string]struct {
+		train    cfg.PromotionTrain
+		now      time.Time
+		expected bool
+	}{
+		"Inside the window": {
+			train:    weekdays,
+			now:      time.Date(2024, 6, 5, 12, 0, 0, 0, time.UTC), // Wednesday
+			expected: true,
+		},
+		"Start time is inclusive": {
+			train:    weekdays,
+			now:      time.Date(2024, 6, 5, 10, 0, 0, 0, time.UTC),
+			expected: true,
+		},
+		"End time is exclusive": {
+			train:    weekdays,
+			now:      time.Date(2024, 6, 5, 16, 0, 0, 0, time.UTC),
+			expected: false,
+		},
+		"Weekend": {
+			train:    weekdays,
+			now:      time.Date(2024, 6, 8, 12, 0, 0, 0, time.UTC), // Saturday
+			expected: false,
+		},
+		"Time zone": {
+			train:    cfg.PromotionTrain{StartTime: "10:00", EndTime: "16:00", TimeZone: "America/New_York"},
+			now:      time.Date(2024, 6, 5, 12, 0, 0, 0, time.UTC), // 08:00 in New York
+			expected: false,
+		},
+		"No days or times means always open": {
+			train:    cfg.PromotionTrain{},
+			now:      time.Date(2024, 6, 8, 3, 0, 0, 0, time.UTC),
+			expected: true,
+		},
+		"Invalid time zone doesn't hold promotions": {
+			train:    cfg.PromotionTrain{StartTime: "10:00", EndTime: "16:00", TimeZone: "Nowhere/Special"},
+			now:      time.Date(2024, 6, 5, 3, 0, 0, 0, time.UTC),
+			expected: true,
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, promotionTrainWindowOpen(tc.train, tc.now))
+		})
+	}
+}
+
+func TestSplitHeldPromotions(t *testing.T) {
+	t.Parallel()
+	config := &cfg.Config{
+		PromotionTrains: []cfg.PromotionTrain{
+			{TargetPathRegex: "^env/prod/", StartTime: "10:00", EndTime: "16:00"},
+		},
+	}
+	promotions := map[string]PromotionInstance{
+		"env/dev/>env/staging/":                  {Metadata: PromotionInstanceMetaData{TargetPaths: []string{"env/staging/"}}},
+		"env/staging/>env/prod/us/|env/prod/eu/": {Metadata: PromotionInstanceMetaData{TargetPaths: []string{"env/prod/eu/", "env/prod/us/"}}},
+	}
+
+	ready, held := splitHeldPromotions(config, promotions, time.Date(2024, 6, 5, 20, 0, 0, 0, time.UTC))
+	assert.Contains(t, ready, "env/dev/>env/staging/")
+	assert.Contains(t, held, "env/staging/>env/prod/us/|env/prod/eu/")
+	assert.Len(t, ready, 1)
+	assert.Len(t, held, 1)
+
+	ready, held = splitHeldPromotions(config, promotions, time.Date(2024, 6, 5, 11, 0, 0, 0, time.UTC))
+	assert.Len(t, ready, 2)
+	assert.Empty(t, held)
+}
@@ -0,0 +1,95 @@
+package githubapi
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/tenancy"
+	"golang.org/x/oauth2"
+)
+
+// publicReadTransport sends the reads(GET/HEAD) of the configured public repos with a lower privileged token(or anonymously), so read heavy workflows don't use the rate limit of the GitHub App.
+// Reads the read credentials can't do(rate limited, 401/403/404) fall back to the App, writes always use it
+type publicReadTransport struct {
+	// Lower case owner/repo slugs or owners
+	repos map[string]bool
+	read  http.RoundTripper
+	base  http.RoundTripper
+	cache *cachedReads
+}
+
+// newPublicReadTransport returns base as is when the tenant in ctx doesn't configure PUBLIC_REPOS, PUBLIC_REPOS_READ_TOKEN is the read token(anonymous reads when it isn't set)
+func newPublicReadTransport(ctx context.Context, base http.RoundTripper) http.RoundTripper {
+	tenant := tenancy.FromContext(ctx)
+	publicRepos, ok := tenant.Lookup("PUBLIC_REPOS")
+	if !ok || publicRepos == "" {
+		return base
+	}
+	t := &publicReadTransport{repos: map[string]bool{}, read: http.DefaultTransport, base: base, cache: getConditionalReadCache()}
+	for _, repo := range strings.Split(publicRepos, ",") {
+		if repo = strings.ToLower(strings.TrimSpace(repo)); repo != "" {
+			t.repos[repo] = true
+		}
+	}
+	if _, ok := tenant.Lookup("PUBLIC_REPOS_READ_TOKEN"); ok {
+		t.read = &oauth2.Transport{Source: secretTokenSource{name: "PUBLIC_REPOS_READ_TOKEN", tenant: tenant}}
+	}
+	return t
+}
+
+// isPublicRepoPath returns true for the /repos/<owner>/<repo> API paths of the public repos
+func (t *publicReadTransport) isPublicRepoPath(path string) bool {
+	_, repoPath, ok := strings.Cut(path, "/repos/")
+	if !ok {
+		return false
+	}
+	parts := strings.SplitN(strings.ToLower(repoPath), "/", 3)
+	if len(parts) < 2 {
+		return false
+	}
+	return t.repos[parts[0]] || t.repos[parts[0]+"/"+parts[1]]
+}
+
+// The read credentials can't do these, the App might
+func publicReadFallsBack(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusTooManyRequests:
+		return true
+	}
+	return false
+}
+
+func (t *publicReadTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if (req.Method != http.MethodGet && req.Method != http.MethodHead) || !t.isPublicRepoPath(req.URL.Path) {
+		return t.base.RoundTrip(req)
+	}
+	// Responses of public repos are the same for every client, so they share a cache scope
+	resp, fromCache, err := conditionalRoundTrip(t.read, t.cache, "public", req.Clone(req.Context()))
+	if err != nil {
+		log.Debugf("Public read of %s failed, falling back to the App: err=%v", req.URL.Path, err)
+		prom.InstrumentPublicRead("fallback")
+		return t.base.RoundTrip(req)
+	}
+	// go-github tracks the rate limit of the responses, the limit of the read credentials shouldn't block the App calls
+	for header := range resp.Header {
+		if strings.HasPrefix(header, "X-Ratelimit-") {
+			resp.Header.Del(header)
+		}
+	}
+	resp.Request = req
+	switch {
+	case fromCache:
+		prom.InstrumentPublicRead("cached")
+	case publicReadFallsBack(resp):
+		_ = resp.Body.Close()
+		log.Debugf("Public read of %s got %d, falling back to the App", req.URL.Path, resp.StatusCode)
+		prom.InstrumentPublicRead("fallback")
+		return t.base.RoundTrip(req)
+	default:
+		prom.InstrumentPublicRead("read")
+	}
+	return resp, nil
+}
@@ -0,0 +1,85 @@
+package githubapi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type countingTransport struct {
+	calls atomic.Int32
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.calls.Add(1)
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(http.NoBody), Header: http.Header{}, Request: req}, nil
+}
+
+func TestPublicReadTransport(t *testing.T) {
+	t.Parallel()
+	var reads atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reads.Add(1)
+		assert.Empty(t, r.Header.Get("Authorization"), "public reads are anonymous without PUBLIC_REPOS_READ_TOKEN")
+		if r.URL.Path == "/repos/oss-org/gitops/contents/missing.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(`{"name": "values.yaml"}`))
+	}))
+	defer server.Close()
+	app := &countingTransport{}
+	cache := newCachedReads(1<<20, 1<<10)
+	transport := &publicReadTransport{repos: map[string]bool{"oss-org/gitops": true}, read: http.DefaultTransport, base: app, cache: cache}
+	roundTrip := func(method string, path string) *http.Response {
+		req, _ := http.NewRequest(method, server.URL+path, nil) //nolint:noctx
+		resp, err := transport.RoundTrip(req)
+		assert.NoError(t, err)
+		return resp
+	}
+
+	resp := roundTrip(http.MethodGet, "/repos/OSS-org/gitops/contents/values.yaml")
+	body, _ := io.ReadAll(resp.Body)
+	assert.JSONEq(t, `{"name": "values.yaml"}`, string(body))
+	assert.Empty(t, resp.Header.Get("X-RateLimit-Remaining"), "the rate limit of the read credentials shouldn't reach go-github")
+
+	// The ETag is revalidated, the 304 is answered from the cache
+	resp = roundTrip(http.MethodGet, "/repos/oss-org/gitops/contents/values.yaml")
+	body, _ = io.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"name": "values.yaml"}`, string(body))
+	assert.Equal(t, int32(2), reads.Load())
+	assert.Equal(t, int32(0), app.calls.Load())
+
+	// Reads the read credentials can't do, writes and other repos use the App
+	roundTrip(http.MethodGet, "/repos/oss-org/gitops/contents/missing.yaml")
+	roundTrip(http.MethodPost, "/repos/oss-org/gitops/issues/1/comments")
+	roundTrip(http.MethodGet, "/repos/private-org/gitops/contents/values.yaml")
+	assert.Equal(t, int32(3), reads.Load())
+	assert.Equal(t, int32(3), app.calls.Load())
+}
+
+func TestIsPublicRepoPath(t *testing.T) {
+	t.Parallel()
+	transport := &publicReadTransport{repos: map[string]bool{"oss-org": true, "other-org/gitops": true}}
+	tests := map[string]bool{
+		"/repos/oss-org/anything/pulls/1":         true,
+		"/api/v3/repos/other-org/gitops/contents": true,
+		"/repos/other-org/private":                false,
+		"/repos/other-org":                        false,
+		"/user":                                   false,
+	}
+	for path, expected := range tests {
+		assert.Equal(t, expected, transport.isPublicRepoPath(path), path)
+	}
+}
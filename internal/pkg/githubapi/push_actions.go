@@ -0,0 +1,66 @@
+package githubapi
+
+import (
+	"regexp"
+	"sort"
+
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/argocd"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+	"golang.org/x/exp/maps"
+)
+
+// pushActionsToRun returns what the pushActions entries matching the changed files ask for, hardRefreshFiles are the matching files of entries with argocdHardRefresh
+func pushActionsToRun(actions []cfg.PushAction, changedFiles []string) (refreshDrift bool, hardRefreshFiles []string) {
+	hardRefresh := map[string]bool{}
+	for _, action := range actions {
+		// The regexes are validated when the configuration is parsed
+		r, err := regexp.Compile(action.PathRegex)
+		if err != nil {
+			continue
+		}
+		for _, changedFile := range changedFiles {
+			if !r.MatchString(changedFile) {
+				continue
+			}
+			refreshDrift = refreshDrift || action.RefreshDrift
+			if action.ArgocdHardRefresh {
+				hardRefresh[changedFile] = true
+			}
+		}
+	}
+	hardRefreshFiles = maps.Keys(hardRefresh)
+	sort.Strings(hardRefreshFiles)
+	return refreshDrift, hardRefreshFiles
+}
+
+// pushedComponentPaths maps the pushed files to the components of the promotion paths they belong to
+func pushedComponentPaths(config *cfg.Config, changedFiles []string) []string {
+	componentPaths := []string{}
+	for component := range getRelevantComponentsFromFileList(changedFiles, config) {
+		componentPaths = append(componentPaths, component.SourcePath+component.ComponentName)
+	}
+	sort.Strings(componentPaths)
+	return componentPaths
+}
+
+// handlePushActions runs the pushActions in-repo configuration for a push to the default branch, failures are logged as the push has nothing to report them on
+func handlePushActions(ghPrClientDetails GhPrClientDetails, config *cfg.Config, branch string, changedFiles []string) {
+	refreshDrift, hardRefreshFiles := pushActionsToRun(config.PushActions, changedFiles)
+	for _, componentPath := range pushedComponentPaths(config, hardRefreshFiles) {
+		if skipInShadowMode(ghPrClientDetails.Ctx, "argocd_hard_refresh") {
+			continue
+		}
+		appName, err := argocd.HardRefreshComponentApp(ghPrClientDetails.Ctx, componentPath, ghPrClientDetails.RepoURL, config.Argocd.UseSHALabelForAppDiscovery)
+		if err != nil {
+			ghPrClientDetails.PrLogger.Errorf("Failed to hard refresh the ArgoCD app of %s: err=%v", componentPath, err)
+			continue
+		}
+		ghPrClientDetails.PrLogger.Infof("Hard refreshed ArgoCD app %s of %s", appName, componentPath)
+	}
+	if refreshDrift {
+		err := refreshRepoDrift(ghPrClientDetails, config, branch)
+		if err != nil {
+			ghPrClientDetails.PrLogger.Errorf("Failed to refresh the drift state: err=%v", err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package githubapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+)
+
+func TestPushActionsToRun(t *testing.T) {
+	t.Parallel()
+	actions := []cfg.PushAction{
+		{PathRegex: "^env/prod/", ArgocdHardRefresh: true},
+		{PathRegex: "^env/", RefreshDrift: true},
+	}
+	tests := map[string]struct {
+		changedFiles             []string
+		expectedRefreshDrift     bool
+		expectedHardRefreshFiles []string
+	}{
+		"No matching files": {
+			changedFiles:             []string{"README.md"},
+			expectedHardRefreshFiles: []string{},
+		},
+		"Drift refresh only": {
+			changedFiles:             []string{"env/staging/app/values.yaml", "README.md"},
+			expectedRefreshDrift:     true,
+			expectedHardRefreshFiles: []string{},
+		},
+		"Both actions": {
+			changedFiles:             []string{"env/prod/b/values.yaml", "env/prod/a/values.yaml", "env/staging/app/values.yaml"},
+			expectedRefreshDrift:     true,
+			expectedHardRefreshFiles: []string{"env/prod/a/values.yaml", "env/prod/b/values.yaml"},
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			refreshDrift, hardRefreshFiles := pushActionsToRun(actions, tc.changedFiles)
+			assert.Equal(t, tc.expectedRefreshDrift, refreshDrift)
+			assert.Equal(t, tc.expectedHardRefreshFiles, hardRefreshFiles)
+		})
+	}
+}
+
+func TestPushedComponentPaths(t *testing.T) {
+	t.Parallel()
+	config := &cfg.Config{
+		PromotionPaths: []cfg.PromotionPath{{SourcePath: "env/prod/"}},
+	}
+	componentPaths := pushedComponentPaths(config, []string{"env/prod/b/values.yaml", "env/prod/a/values.yaml", "env/prod/a/deployment.yaml", "README.md"})
+	assert.Equal(t, []string{"env/prod/a", "env/prod/b"}, componentPaths)
+}
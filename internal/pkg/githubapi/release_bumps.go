@@ -0,0 +1,125 @@
+package githubapi
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v62/github"
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/mikefarah/yq/v4/pkg/yqlib"
+	log "github.com/sirupsen/logrus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/logging"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/tenancy"
+	"golang.org/x/exp/maps"
+)
+
+// releaseBumpFileContent returns content with the version of target set to version
+func releaseBumpFileContent(content string, target tenancy.ReleaseBumpTarget, version string) (string, error) {
+	if target.YamlAddress != "" {
+		preferences := yqlib.NewDefaultYamlPreferences()
+		return yqlib.NewStringEvaluator().Evaluate(fmt.Sprintf("(%s)=\"%s\"", target.YamlAddress, version), content, yqlib.NewYamlEncoder(preferences), yqlib.NewYamlDecoder(preferences))
+	}
+	// The regex is validated when the server configuration is parsed
+	r := regexp.MustCompile(target.Regex)
+	return r.ReplaceAllLiteralString(content, strings.ReplaceAll(target.Replacement, "{version}", version)), nil
+}
+
+// releaseBumpTargetsByRepo groups the targets of a release bump by repo, each repo gets a single PR
+func releaseBumpTargetsByRepo(targets []tenancy.ReleaseBumpTarget) map[string][]tenancy.ReleaseBumpTarget {
+	targetsByRepo := map[string][]tenancy.ReleaseBumpTarget{}
+	for _, target := range targets {
+		targetsByRepo[target.Repo] = append(targetsByRepo[target.Repo], target)
+	}
+	return targetsByRepo
+}
+
+// bumpReleaseTargets opens the bump PR of the targets of a single repo, nothing is opened when the files already have the version
+func bumpReleaseTargets(ghPrClientDetails GhPrClientDetails, targets []tenancy.ReleaseBumpTarget, version string, sourceRepo string, tag string, triggeringActor string, autoMerge bool) (*github.PullRequest, error) {
+	defaultBranch, err := ghPrClientDetails.GetDefaultBranch()
+	if err != nil {
+		return nil, fmt.Errorf("get default branch: %w", err)
+	}
+	// Bumps don't need the in-repo configuration, but reading it applies its shadowMode
+	_, _ = GetInRepoConfig(ghPrClientDetails, defaultBranch)
+	initialContents := map[string]string{}
+	newContents := map[string]string{}
+	for _, target := range targets {
+		content, ok := newContents[target.File]
+		if !ok {
+			content, _, err = GetFileContent(ghPrClientDetails, defaultBranch, target.File)
+			if err != nil {
+				return nil, fmt.Errorf("fetch %s content: %w", target.File, err)
+			}
+			initialContents[target.File] = content
+		}
+		newContents[target.File], err = releaseBumpFileContent(content, target, version)
+		if err != nil {
+			return nil, fmt.Errorf("bump %s: %w", target.File, err)
+		}
+	}
+	for file, content := range newContents {
+		if content == initialContents[file] {
+			delete(newContents, file)
+		}
+	}
+	if len(newContents) == 0 {
+		ghPrClientDetails.PrLogger.Infof("The release bump targets are already at version %s", version)
+		return nil, nil
+	}
+	return BumpVersionMultiFile(ghPrClientDetails, defaultBranch, newContents, sourceRepo, tag, triggeringActor, autoMerge)
+}
+
+// runReleaseBumps opens the bump PRs of the releaseBumps server configuration entries of sourceRepo triggered by on, failures are logged as there is no PR to report them on
+func runReleaseBumps(ctx context.Context, mainGhClientCache *lru.Cache[string, GhClientPair], sourceRepo string, tag string, triggeringActor string, on string) {
+	for _, bump := range tenancy.ReleaseBumpsFor(sourceRepo, on) {
+		version, ok := bump.Version(tag)
+		if !ok {
+			log.Debugf("Tag %s of %s doesn't match the release bump tag regex %s", tag, sourceRepo, bump.TagRegex)
+			continue
+		}
+		targetsByRepo := releaseBumpTargetsByRepo(bump.Targets)
+		targetRepos := maps.Keys(targetsByRepo)
+		sort.Strings(targetRepos)
+		for _, targetRepo := range targetRepos {
+			owner, repo, _ := strings.Cut(targetRepo, "/")
+			// The target repo can belong to another tenant than the source repo, and be in shadow mode
+			targetCtx := withShadowModeState(tenancy.NewContext(ctx, tenancy.ForRepo(targetRepo)))
+			var ghClientPair GhClientPair
+			ghClientPair.GetAndCache(mainGhClientCache, MainCredentialEnvVars(targetCtx), owner, targetCtx)
+			ghPrClientDetails := GhPrClientDetails{
+				Ctx:          targetCtx,
+				GhClientPair: &ghClientPair,
+				Owner:        owner,
+				Repo:         repo,
+				PrLogger:     logging.ForRepo(targetRepo).WithFields(log.Fields{"source_repo": sourceRepo, "tag": tag, "event_type": "release_bump"}),
+			}
+			pr, err := bumpReleaseTargets(ghPrClientDetails, targetsByRepo[targetRepo], version, sourceRepo, tag, triggeringActor, bump.AutoMerge)
+			if err != nil {
+				ghPrClientDetails.PrLogger.Errorf("Release bump failed: err=%v", err)
+				continue
+			}
+			if pr != nil {
+				ghPrClientDetails.PrLogger.Infof("Opened release bump PR %s", pr.GetHTMLURL())
+			}
+		}
+	}
+}
+
+// handleReleaseEvent bumps the version of published releases, drafts and prereleases are ignored
+func handleReleaseEvent(ctx context.Context, eventPayload *github.ReleaseEvent, mainGhClientCache *lru.Cache[string, GhClientPair]) {
+	if eventPayload.GetAction() != "published" || eventPayload.GetRelease().GetDraft() || eventPayload.GetRelease().GetPrerelease() {
+		return
+	}
+	runReleaseBumps(ctx, mainGhClientCache, eventPayload.GetRepo().GetFullName(), eventPayload.GetRelease().GetTagName(), eventPayload.GetSender().GetLogin(), tenancy.ReleaseBumpOnRelease)
+}
+
+// handleCreateEvent bumps the version of pushed tags, branch creations are ignored
+func handleCreateEvent(ctx context.Context, eventPayload *github.CreateEvent, mainGhClientCache *lru.Cache[string, GhClientPair]) {
+	if eventPayload.GetRefType() != "tag" {
+		return
+	}
+	runReleaseBumps(ctx, mainGhClientCache, eventPayload.GetRepo().GetFullName(), eventPayload.GetRef(), eventPayload.GetSender().GetLogin(), tenancy.ReleaseBumpOnTag)
+}
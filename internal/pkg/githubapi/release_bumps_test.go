@@ -0,0 +1,50 @@
+package githubapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/tenancy"
+)
+
+func TestReleaseBumpFileContent(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		content         string
+		target          tenancy.ReleaseBumpTarget
+		expectedContent string
+	}{
+		"YAML address": {
+			content:         "image:\n  repository: app\n  tag: \"1.0.0\"\n",
+			target:          tenancy.ReleaseBumpTarget{YamlAddress: ".image.tag"},
+			expectedContent: "image:\n  repository: app\n  tag: \"1.2.3\"\n",
+		},
+		"Regex": {
+			content:         "app:\n  tag: 1.0.0 # pinned\n",
+			target:          tenancy.ReleaseBumpTarget{Regex: `tag: \S+`, Replacement: "tag: {version}"},
+			expectedContent: "app:\n  tag: 1.2.3 # pinned\n",
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			content, err := releaseBumpFileContent(tc.content, tc.target, "1.2.3")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedContent, content)
+		})
+	}
+}
+
+func TestReleaseBumpTargetsByRepo(t *testing.T) {
+	t.Parallel()
+	targets := []tenancy.ReleaseBumpTarget{
+		{Repo: "org-a/gitops", File: "env/staging/values.yaml"},
+		{Repo: "org-b/gitops", File: "values.yaml"},
+		{Repo: "org-a/gitops", File: "env/prod/values.yaml"},
+	}
+	targetsByRepo := releaseBumpTargetsByRepo(targets)
+	assert.Len(t, targetsByRepo, 2)
+	assert.Equal(t, []tenancy.ReleaseBumpTarget{targets[0], targets[2]}, targetsByRepo["org-a/gitops"])
+	assert.Equal(t, []tenancy.ReleaseBumpTarget{targets[1]}, targetsByRepo["org-b/gitops"])
+}
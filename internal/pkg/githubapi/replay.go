@@ -0,0 +1,87 @@
+package githubapi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/google/go-github/v62/github"
+	lru "github.com/hashicorp/golang-lru/v2"
+	log "github.com/sirupsen/logrus"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+)
+
+// createGithubAppJWTClient creates a client authenticated as the GitHub App itself(not as an installation), this is required for the /app endpoints like webhook deliveries
+func createGithubAppJWTClient() (*github.Client, error) {
+	githubAppId, err := strconv.ParseInt(getEnv("GITHUB_APP_ID", ""), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("GITHUB_APP_ID is required to fetch webhook deliveries: %w", err)
+	}
+	githubAppPrivateKey, err := getGithubAppPrivateKey(nil, "GITHUB_APP_PRIVATE_KEY_PATH")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GitHub App private key: %w", err)
+	}
+	var githubRestAltURL string
+	if githubHost := getEnv("GITHUB_HOST", ""); githubHost != "" {
+		githubRestAltURL = fmt.Sprintf("https://%s/api/v3", githubHost)
+	}
+	client, err := newGithubAppsClient(githubAppPrivateKey, githubAppId, githubRestAltURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GitHub App client: %w", err)
+	}
+	return client, nil
+}
+
+// fetchHookDelivery returns the event type and payload of a GitHub App webhook delivery
+func fetchHookDelivery(ctx context.Context, client *github.Client, deliveryID int64) (string, []byte, error) {
+	delivery, resp, err := client.Apps.GetHookDelivery(ctx, deliveryID)
+	prom.InstrumentGhCall(resp)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get webhook delivery %d: %w", deliveryID, err)
+	}
+	if delivery.GetEvent() == "" || delivery.Request == nil || delivery.Request.RawPayload == nil {
+		return "", nil, fmt.Errorf("webhook delivery %d has no event payload", deliveryID)
+	}
+	return delivery.GetEvent(), *delivery.Request.RawPayload, nil
+}
+
+// ReplayHookDelivery fetches a GitHub App webhook delivery by its ID and handles it again as if it was just received.
+// When wait is false the event is handled in the background, like ReciveWebhook does
+func ReplayHookDelivery(deliveryID int64, mainGhClientCache *lru.Cache[string, GhClientPair], prApproverGhClientCache *lru.Cache[string, GhClientPair], wait bool) error {
+	client, err := createGithubAppJWTClient()
+	if err != nil {
+		return err
+	}
+	return replayHookDelivery(context.Background(), client, deliveryID, mainGhClientCache, prApproverGhClientCache, wait)
+}
+
+func replayHookDelivery(ctx context.Context, client *github.Client, deliveryID int64, mainGhClientCache *lru.Cache[string, GhClientPair], prApproverGhClientCache *lru.Cache[string, GhClientPair], wait bool) error {
+	eventType, payload, err := fetchHookDelivery(ctx, client, deliveryID)
+	if err != nil {
+		return err
+	}
+	log.Infof("Replaying webhook delivery %d, event type: %s", deliveryID, eventType)
+
+	eventPayloadInterface, err := github.ParseWebHook(eventType, payload)
+	if err != nil {
+		prom.InstrumentWebhookHit("parsing_failed")
+		return fmt.Errorf("could not parse webhook delivery %d: %w", deliveryID, err)
+	}
+	prom.InstrumentWebhookHit("replayed")
+
+	r, _ := http.NewRequest("POST", "", nil) //nolint:noctx
+	r.Body = io.NopCloser(bytes.NewReader(payload))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-GitHub-Event", eventType)
+	r.Header.Set("X-GitHub-Delivery", strconv.FormatInt(deliveryID, 10))
+
+	if wait {
+		handleEvent(eventPayloadInterface, mainGhClientCache, prApproverGhClientCache, r, payload)
+	} else {
+		go handleEvent(eventPayloadInterface, mainGhClientCache, prApproverGhClientCache, r, payload)
+	}
+	return nil
+}
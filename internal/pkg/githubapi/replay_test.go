@@ -0,0 +1,60 @@
+package githubapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchHookDelivery(t *testing.T) {
+	t.Parallel()
+	payload := json.RawMessage(`{"action":"opened","number":1}`)
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetAppHookDeliveriesByDeliveryId,
+			github.HookDelivery{
+				ID:      github.Int64(42),
+				Event:   github.String("pull_request"),
+				Request: &github.HookRequest{RawPayload: &payload},
+			},
+		),
+	)
+
+	eventType, gotPayload, err := fetchHookDelivery(context.Background(), github.NewClient(mockedHTTPClient), 42)
+	assert.NoError(t, err)
+	assert.Equal(t, "pull_request", eventType)
+	assert.JSONEq(t, string(payload), string(gotPayload))
+}
+
+func TestFetchHookDeliveryWithoutPayload(t *testing.T) {
+	t.Parallel()
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetAppHookDeliveriesByDeliveryId,
+			github.HookDelivery{ID: github.Int64(42), Event: github.String("pull_request")},
+		),
+	)
+
+	_, _, err := fetchHookDelivery(context.Background(), github.NewClient(mockedHTTPClient), 42)
+	assert.Error(t, err)
+}
+
+func TestFetchHookDeliveryNotFound(t *testing.T) {
+	t.Parallel()
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetAppHookDeliveriesByDeliveryId,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mock.WriteError(w, http.StatusNotFound, "Not Found")
+			}),
+		),
+	)
+
+	_, _, err := fetchHookDelivery(context.Background(), github.NewClient(mockedHTTPClient), 42)
+	assert.Error(t, err)
+}
@@ -0,0 +1,82 @@
+package githubapi
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"text/template"
+
+	"github.com/google/go-github/v62/github"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+)
+
+const (
+	inRepoTemplatesPath = ".telefonistka/templates/"
+	// There is no bundled template for the ArgoCD diff comment, it's only rendered from a template when the repo provides one
+	argoCdDiffTemplateFile = "argocd-diff-pr-comment.gotmpl"
+	argoCdDiffTemplateName = "argoCdDiff"
+)
+
+// templateFuncs are available to the bundled and in-repo templates
+var templateFuncs = template.FuncMap{
+	"promotionPlanGraph": promotionPlanMermaidGraph,
+}
+
+// argoCdDiffTemplateData is passed to the in-repo ArgoCD diff comment template
+type argoCdDiffTemplateData struct {
+	DiffCommentData
+	// Concise is set when the full diff doesn't fit in a comment, only the names of the changed objects should be listed
+	Concise    bool
+	PartNumber int
+	TotalParts int
+}
+
+// getInRepoTemplate returns the content of a template override from the default branch of the repo, found is false when the repo doesn't override it.
+// The default branch is used so PR authors can't change the bot comments of their own PRs
+func getInRepoTemplate(ghPrClientDetails GhPrClientDetails, templateFile string) (content string, found bool) {
+	defaultBranch, err := ghPrClientDetails.GetDefaultBranch()
+	if err != nil {
+		return "", false
+	}
+	fileContent, _, resp, err := ghPrClientDetails.GhClientPair.v3Client.Repositories.GetContents(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, inRepoTemplatesPath+templateFile, &github.RepositoryContentGetOptions{Ref: defaultBranch})
+	prom.InstrumentGhCall(resp)
+	if err != nil {
+		// Most repos don't override templates, that's not worth logging
+		if resp == nil || resp.StatusCode != http.StatusNotFound {
+			ghPrClientDetails.PrLogger.Errorf("Failed to get in-repo template %s: err=%s", templateFile, err)
+		}
+		return "", false
+	}
+	content, err = fileContent.GetContent()
+	if err != nil {
+		ghPrClientDetails.PrLogger.Errorf("Failed to decode in-repo template %s: err=%s", templateFile, err)
+		return "", false
+	}
+	return content, true
+}
+
+func executeTemplateString(templateName string, templateContent string, data interface{}) (string, error) {
+	var templateOutput bytes.Buffer
+	messageTemplate, err := template.New(templateName).Funcs(templateFuncs).Parse(templateContent)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template: %w", err)
+	}
+	err = messageTemplate.ExecuteTemplate(&templateOutput, templateName, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to execute template: %w", err)
+	}
+	return templateOutput.String(), nil
+}
+
+// executeRepoTemplate renders the repo override of templateFile(under .telefonistka/templates/) when there is one, otherwise the bundled template.
+// A broken override falls back to the bundled template as well, so it doesn't stop Telefonistka from commenting
+func executeRepoTemplate(ghPrClientDetails GhPrClientDetails, templateName string, templateFile string, data interface{}) (string, error) {
+	if templateContent, found := getInRepoTemplate(ghPrClientDetails, templateFile); found {
+		templateOutput, err := executeTemplateString(templateName, templateContent, data)
+		if err == nil {
+			return templateOutput, nil
+		}
+		ghPrClientDetails.PrLogger.Warnf("Failed to render in-repo template %s%s, using the default one: err=%s", inRepoTemplatesPath, templateFile, err)
+	}
+	return executeTemplate(templateName, defaultTemplatesFullPath(ghPrClientDetails.Ctx, templateFile), data)
+}
@@ -0,0 +1,90 @@
+package githubapi
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/argocd"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/tenancy"
+)
+
+func repoTemplateTestClientDetails(mockedHTTPClient *http.Client) GhPrClientDetails {
+	// The bundled templates are at the repo root, the tests run from the package directory
+	bundledTemplates := &tenancy.Tenant{Name: "test", Env: map[string]string{"TEMPLATES_PATH": "../../../templates/"}}
+	return GhPrClientDetails{
+		Ctx:           tenancy.NewContext(context.Background(), bundledTemplates),
+		GhClientPair:  &GhClientPair{v3Client: github.NewClient(mockedHTTPClient)},
+		DefaultBranch: "main",
+		Owner:         "AnOwner",
+		Repo:          "Arepo",
+		PrLogger:      log.WithFields(log.Fields{"repo": "AnOwner/Arepo"}),
+	}
+}
+
+func TestExecuteRepoTemplate(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		contentHandler http.HandlerFunc
+		expected       string
+	}{
+		"Repo override": {
+			contentHandler: func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/repos/AnOwner/Arepo/contents/.telefonistka/templates/auto-merge-comment.gotmpl", r.URL.Path)
+				assert.Equal(t, "main", r.URL.Query().Get("ref"))
+				_, _ = w.Write(mock.MustMarshal(github.RepositoryContent{
+					Content: github.String(`{{define "autoMerge"}}Merging #{{.prNumber}}, see https://wiki.example.com/deploys{{end}}`),
+				}))
+			},
+			expected: "Merging #42, see https://wiki.example.com/deploys",
+		},
+		"No override": {
+			contentHandler: func(w http.ResponseWriter, r *http.Request) {
+				mock.WriteError(w, http.StatusNotFound, "Not Found")
+			},
+			expected: "\n✅ Auto merge is enabled\n🚀 Merging promotion PR: #42\n",
+		},
+		"Broken override falls back to the bundled template": {
+			contentHandler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write(mock.MustMarshal(github.RepositoryContent{
+					Content: github.String(`{{define "autoMerge"}}{{.prNumber`),
+				}))
+			},
+			expected: "\n✅ Auto merge is enabled\n🚀 Merging promotion PR: #42\n",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			mockedHTTPClient := mock.NewMockedHTTPClient(
+				mock.WithRequestMatchHandler(mock.GetReposContentsByOwnerByRepoByPath, tc.contentHandler),
+			)
+			output, err := executeRepoTemplate(repoTemplateTestClientDetails(mockedHTTPClient), "autoMerge", "auto-merge-comment.gotmpl", map[string]interface{}{"prNumber": 42})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, output)
+		})
+	}
+}
+
+func TestBuildArgoCdDiffCommentWithRepoTemplate(t *testing.T) {
+	t.Parallel()
+	diffCommentData := DiffCommentData{
+		DiffOfChangedComponents: []argocd.DiffResult{
+			{ComponentPath: "clusters/prod/component-a", ArgoCdAppName: "component-a-prod", HasDiff: true},
+		},
+		customTemplate: `{{define "argoCdDiff"}}{{if .Concise}}(concise) {{end}}{{range .DiffOfChangedComponents}}{{.ArgoCdAppName}} has changes{{end}}{{end}}`,
+	}
+	comment, err := buildArgoCdDiffComment(diffCommentData, true, 1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "(concise) component-a-prod has changes", comment)
+
+	// A broken template falls back to the built-in comment
+	diffCommentData.customTemplate = `{{define "argoCdDiff"}}{{.NoSuchField}}{{end}}`
+	comment, err = buildArgoCdDiffComment(diffCommentData, false, 0, 0)
+	assert.NoError(t, err)
+	assert.Contains(t, comment, "Diff of ArgoCD applications")
+}
@@ -0,0 +1,119 @@
+package githubapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/google/go-github/v62/github"
+	log "github.com/sirupsen/logrus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/maintenance"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+)
+
+// GitHub writes happen while handling an event, so retries are capped well below the event handling timeout
+const ghWriteRetryMaxElapsedTime = time.Minute
+
+// Writes that can safely run twice, a network error or 5xx doesn't mean GitHub didn't apply the write, so only these are retried on such failures.
+// Creates(comments, PRs, commits...) would be duplicated, they are only retried when GitHub rejected the request(rate limits, transient 422s)
+var idempotentGhWrites = map[string]bool{
+	"add_assignees":     true,
+	"add_labels":        true,
+	"create_status":     true,
+	"create_tree":       true, // Trees are content addressed
+	"delete_gist":       true,
+	"delete_ref":        true,
+	"edit_comment":      true,
+	"edit_gist":         true,
+	"edit_issue":        true,
+	"edit_pr":           true,
+	"merge_pr":          true,
+	"remove_label":      true,
+	"request_reviewers": true,
+	"update_ref":        true,
+}
+
+func newGhWriteBackOff() backoff.BackOff {
+	b := backoff.NewExponentialBackOff()
+	b.MaxElapsedTime = ghWriteRetryMaxElapsedTime
+	return b
+}
+
+// retryGhWrite runs a GitHub write call, retrying transient failures(rate limits, some 422s and, for idempotent operations, network errors and 5xx) with exponential backoff.
+// Every attempt is instrumented, so call sites shouldn't call prom.InstrumentGhCall themselves
+func retryGhWrite[T any](ctx context.Context, operation string, call func() (T, *github.Response, error)) (T, *github.Response, error) {
+	return retryGhWriteWithBackOff(ctx, operation, newGhWriteBackOff(), call)
+}
+
+func retryGhWriteWithBackOff[T any](ctx context.Context, operation string, b backoff.BackOff, call func() (T, *github.Response, error)) (T, *github.Response, error) {
+	if !commentWrites[operation] && maintenance.Skip(operation) {
+		var result T
+		return result, nil, maintenance.ErrEnabled
+	}
+	if skipGhWriteInShadowMode(ctx, operation) {
+		var result T
+		return result, nil, errShadowMode
+	}
+	b.Reset()
+	for {
+		result, resp, err := call()
+		prom.InstrumentGhCall(resp)
+		err = classifyGhError(resp, err)
+		if err == nil {
+			prom.InstrumentGhWrite(operation, "success")
+			return result, resp, nil
+		}
+		retryable, retryAfter := isGhErrorRetryable(resp, err, idempotentGhWrites[operation])
+		if !retryable || ctx.Err() != nil {
+			prom.InstrumentGhWrite(operation, "permanent_error")
+			return result, resp, err
+		}
+		wait := b.NextBackOff()
+		if wait == backoff.Stop {
+			prom.InstrumentGhWrite(operation, "retries_exhausted")
+			return result, resp, err
+		}
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+		prom.InstrumentGhWrite(operation, "retryable_error")
+		log.Warnf("GitHub %s failed with a transient error, retrying in %v: err=%v", operation, wait, err)
+		select {
+		case <-ctx.Done():
+			return result, resp, err
+		case <-time.After(wait):
+		}
+	}
+}
+
+// isGhErrorRetryable classifies a failed GitHub call, retryAfter is set when GitHub asked to wait before retrying.
+// Network errors and 5xx are only retryable for idempotent calls, GitHub might have applied the call before failing
+func isGhErrorRetryable(resp *github.Response, err error, idempotent bool) (retryable bool, retryAfter time.Duration) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false, 0
+	}
+	var rateLimitErr *github.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		retryAfter = time.Until(rateLimitErr.Rate.Reset.Time)
+		// Waiting for the hourly rate limit reset isn't worth holding the event for
+		return retryAfter <= ghWriteRetryMaxElapsedTime, retryAfter
+	}
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &abuseErr) {
+		return true, abuseErr.GetRetryAfter()
+	}
+	if resp == nil || resp.Response == nil {
+		// Network errors
+		return idempotent, 0
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return idempotent, 0
+	}
+	var ghErr *GhError
+	if errors.As(classifyGhError(resp, err), &ghErr) {
+		return ghErr.Transient || errors.Is(ghErr, ErrRateLimited), 0
+	}
+	return false, 0
+}
@@ -0,0 +1,169 @@
+package githubapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/google/go-github/v62/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func ghResponse(statusCode int) *github.Response {
+	return &github.Response{Response: &http.Response{
+		StatusCode: statusCode,
+		Request:    &http.Request{Method: http.MethodPost, URL: &url.URL{Path: "/repos/AnOwner/Arepo/issues/1/comments"}},
+	}}
+}
+
+func ghErrorResponse(statusCode int, message string) (*github.Response, error) {
+	resp := ghResponse(statusCode)
+	return resp, &github.ErrorResponse{Response: resp.Response, Message: message}
+}
+
+func TestIsGhErrorRetryable(t *testing.T) {
+	t.Parallel()
+	abuseRetryAfter := 30 * time.Second
+	tests := map[string]struct {
+		resp               func() (*github.Response, error)
+		nonIdempotent      bool
+		expectedRetryable  bool
+		expectedRetryAfter time.Duration
+	}{
+		"Server error": {
+			resp:              func() (*github.Response, error) { return ghErrorResponse(http.StatusBadGateway, "Bad Gateway") },
+			expectedRetryable: true,
+		},
+		"Server error on a create": {
+			resp:              func() (*github.Response, error) { return ghErrorResponse(http.StatusBadGateway, "Bad Gateway") },
+			nonIdempotent:     true,
+			expectedRetryable: false,
+		},
+		"Network error on a create": {
+			resp:              func() (*github.Response, error) { return nil, errors.New("connection reset by peer") },
+			nonIdempotent:     true,
+			expectedRetryable: false,
+		},
+		"Too many requests on a create": {
+			resp:              func() (*github.Response, error) { return ghErrorResponse(http.StatusTooManyRequests, "slow down") },
+			nonIdempotent:     true,
+			expectedRetryable: true,
+		},
+		"Too many requests": {
+			resp:              func() (*github.Response, error) { return ghErrorResponse(http.StatusTooManyRequests, "slow down") },
+			expectedRetryable: true,
+		},
+		"Network error": {
+			resp:              func() (*github.Response, error) { return nil, errors.New("connection reset by peer") },
+			expectedRetryable: true,
+		},
+		"Transient 422": {
+			resp: func() (*github.Response, error) {
+				return ghErrorResponse(http.StatusUnprocessableEntity, "Reference update failed")
+			},
+			expectedRetryable: true,
+		},
+		"Validation 422": {
+			resp: func() (*github.Response, error) {
+				return ghErrorResponse(http.StatusUnprocessableEntity, "A pull request already exists")
+			},
+			expectedRetryable: false,
+		},
+		"Not found": {
+			resp:              func() (*github.Response, error) { return ghErrorResponse(http.StatusNotFound, "Not Found") },
+			expectedRetryable: false,
+		},
+		"Merge race": {
+			resp: func() (*github.Response, error) {
+				return ghErrorResponse(http.StatusMethodNotAllowed, "Base branch was modified. Review and try the merge again.")
+			},
+			expectedRetryable: true,
+		},
+		"Unmergeable PR": {
+			resp: func() (*github.Response, error) {
+				return ghErrorResponse(http.StatusMethodNotAllowed, "Pull Request is not mergeable")
+			},
+			expectedRetryable: false,
+		},
+		"Secondary rate limit": {
+			resp: func() (*github.Response, error) {
+				resp, _ := ghErrorResponse(http.StatusForbidden, "")
+				return resp, &github.AbuseRateLimitError{Response: resp.Response, RetryAfter: &abuseRetryAfter}
+			},
+			expectedRetryable:  true,
+			expectedRetryAfter: abuseRetryAfter,
+		},
+		"Hourly rate limit": {
+			resp: func() (*github.Response, error) {
+				resp, _ := ghErrorResponse(http.StatusForbidden, "")
+				return resp, &github.RateLimitError{Response: resp.Response, Rate: github.Rate{Reset: github.Timestamp{Time: time.Now().Add(40 * time.Minute)}}}
+			},
+			expectedRetryable: false,
+		},
+		"Canceled": {
+			resp:              func() (*github.Response, error) { return nil, context.Canceled },
+			expectedRetryable: false,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			resp, err := tc.resp()
+			retryable, retryAfter := isGhErrorRetryable(resp, err, !tc.nonIdempotent)
+			assert.Equal(t, tc.expectedRetryable, retryable)
+			if tc.expectedRetryAfter != 0 {
+				assert.Equal(t, tc.expectedRetryAfter, retryAfter)
+			}
+		})
+	}
+}
+
+func TestRetryGhWrite(t *testing.T) {
+	t.Parallel()
+	attempts := 0
+	result, _, err := retryGhWriteWithBackOff(context.Background(), "update_ref", &backoff.ZeroBackOff{}, func() (string, *github.Response, error) {
+		attempts++
+		if attempts < 3 {
+			resp, err := ghErrorResponse(http.StatusInternalServerError, "boom")
+			return "", resp, err
+		}
+		return "done", ghResponse(http.StatusCreated), nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "done", result)
+	assert.Equal(t, 3, attempts)
+
+	attempts = 0
+	_, _, err = retryGhWriteWithBackOff(context.Background(), "update_ref", &backoff.ZeroBackOff{}, func() (string, *github.Response, error) {
+		attempts++
+		resp, err := ghErrorResponse(http.StatusUnprocessableEntity, "Validation Failed")
+		return "", resp, err
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts, "permanent errors shouldn't be retried")
+
+	attempts = 0
+	_, _, err = retryGhWriteWithBackOff(context.Background(), "update_ref", backoff.WithMaxRetries(&backoff.ZeroBackOff{}, 2), func() (string, *github.Response, error) {
+		attempts++
+		resp, err := ghErrorResponse(http.StatusServiceUnavailable, "unavailable")
+		return "", resp, err
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 3, attempts, "retries should stop when the backoff gives up")
+}
+
+func TestRetryGhWriteDoesNotDuplicateCreates(t *testing.T) {
+	t.Parallel()
+	attempts := 0
+	_, _, err := retryGhWriteWithBackOff(context.Background(), "create_comment", &backoff.ZeroBackOff{}, func() (string, *github.Response, error) {
+		attempts++
+		resp, err := ghErrorResponse(http.StatusBadGateway, "Bad Gateway")
+		return "", resp, err
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts, "a create that failed with a 5xx might have been applied, it shouldn't be retried")
+}
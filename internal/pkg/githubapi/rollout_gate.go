@@ -0,0 +1,100 @@
+package githubapi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/argocd"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/tenancy"
+)
+
+const defaultRolloutWait = 60 * time.Minute
+
+// rolloutComponents returns the changed components of the merged PR whose ArgoCD apps use Argo Rollouts, components that can't be checked are logged and don't hold the promotions
+func rolloutComponents(ghPrClientDetails GhPrClientDetails, config *cfg.Config) []string {
+	componentPaths, err := generateListOfChangedComponentPaths(ghPrClientDetails, config)
+	if err != nil {
+		ghPrClientDetails.PrLogger.Errorf("Failed to get list of changed components for the rollout check: err=%v", err)
+		return nil
+	}
+	withRollouts := []string{}
+	for _, componentPath := range componentPaths {
+		rollouts, err := argocd.ComponentAppRollouts(ghPrClientDetails.Ctx, componentPath, ghPrClientDetails.RepoURL, config.Argocd.UseSHALabelForAppDiscovery)
+		if err != nil {
+			ghPrClientDetails.PrLogger.Errorf("Failed to check the rollouts of %s: err=%v", componentPath, err)
+			continue
+		}
+		if len(rollouts) > 0 {
+			withRollouts = append(withRollouts, componentPath)
+		}
+	}
+	sort.Strings(withRollouts)
+	return withRollouts
+}
+
+func rolloutHoldComment(componentPaths []string) string {
+	return fmt.Sprintf("⏳ The promotions to the next environments wait for the Argo Rollouts of `%s` to complete", strings.Join(componentPaths, "`, `"))
+}
+
+// rolloutsCompleted is true when all the rollouts completed, aborted and timed out rollouts hold the promotions
+func rolloutsCompleted(results []argocd.RolloutResult) bool {
+	for _, result := range results {
+		if result.Err != nil || !result.Completed {
+			return false
+		}
+	}
+	return true
+}
+
+// promoteAfterRollouts waits for the Argo Rollouts of the components the merged PR changed, reports them on the PR and opens the held promotions once all of them completed.
+// It runs after the event handling is done, so it uses its own context
+func promoteAfterRollouts(ghPrClientDetails GhPrClientDetails, config *cfg.Config, promotions map[string]PromotionInstance, componentPaths []string, defaultBranch string, prApproverGithubClient *github.Client, mergeCommitSHA string, since time.Time) {
+	waitTimeout := defaultRolloutWait
+	if config.Argocd.Rollouts.TimeoutMinutes > 0 {
+		waitTimeout = time.Duration(config.Argocd.Rollouts.TimeoutMinutes) * time.Minute
+	}
+	// The tenant and shadow mode of the event have to be carried over to the new context
+	ctx, cancel := context.WithTimeout(withShadowModeStateOf(tenancy.NewContext(context.Background(), tenancy.FromContext(ghPrClientDetails.Ctx)), ghPrClientDetails.Ctx), waitTimeout)
+	defer cancel()
+
+	resultsChan := make(chan argocd.RolloutResult)
+	for _, componentPath := range componentPaths {
+		go func(componentPath string) {
+			resultsChan <- argocd.WaitForComponentRollouts(ctx, componentPath, ghPrClientDetails.RepoURL, config.Argocd.UseSHALabelForAppDiscovery, mergeCommitSHA, since, postMergeSyncPollInterval)
+		}(componentPath)
+	}
+	results := []argocd.RolloutResult{}
+	for range componentPaths {
+		results = append(results, <-resultsChan)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].ComponentPath < results[j].ComponentPath })
+	completed := rolloutsCompleted(results)
+
+	// The context might be exhausted by the wait, reporting and promoting should still happen
+	reportCtx, reportCancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Minute)
+	defer reportCancel()
+	ghPrClientDetails.Ctx = reportCtx
+
+	templateOutput, err := executeRepoTemplate(ghPrClientDetails, "rollout", "rollout-comment.gotmpl", map[string]interface{}{
+		"completed":      completed,
+		"mergeCommitSHA": mergeCommitSHA,
+		"results":        results,
+	})
+	if err != nil {
+		ghPrClientDetails.PrLogger.Errorf("Failed to render rollout comment: err=%v", err)
+	} else {
+		_ = commentPR(ghPrClientDetails, templateOutput)
+	}
+	if !completed {
+		ghPrClientDetails.PrLogger.Warnf("Rollouts of %v didn't complete, not opening the promotions", componentPaths)
+		return
+	}
+	if err := openPromotionPrs(ghPrClientDetails, config, promotions, defaultBranch, prApproverGithubClient, mergeCommitSHA); err != nil {
+		ghPrClientDetails.PrLogger.Errorf("Failed to open the promotions held by rollouts: err=%v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package githubapi
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/argocd"
+)
+
+func TestRolloutsCompleted(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		results  []argocd.RolloutResult
+		expected bool
+	}{
+		"All completed": {
+			results:  []argocd.RolloutResult{{ComponentPath: "a", Completed: true}, {ComponentPath: "b", Completed: true}},
+			expected: true,
+		},
+		"One aborted": {
+			results:  []argocd.RolloutResult{{ComponentPath: "a", Completed: true}, {ComponentPath: "b", Aborted: true}},
+			expected: false,
+		},
+		"One timed out": {
+			results:  []argocd.RolloutResult{{ComponentPath: "a", Completed: true}, {ComponentPath: "b", Err: errors.New("timed out")}},
+			expected: false,
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, rolloutsCompleted(tc.results))
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package githubapi
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+)
+
+const (
+	// Larger files are unlikely to be hand written configuration, the promotion file policy can block them
+	secretScanMaxFileSize = 1024 * 1024
+	// Shorter strings don't carry enough characters for a meaningful entropy
+	secretScanMinTokenLength = 20
+)
+
+// builtinSecretRules match well known credential formats
+var builtinSecretRules = []cfg.SecretScanningRule{
+	{Name: "private key", Regex: `-----BEGIN [A-Z ]*PRIVATE KEY-----`},
+	{Name: "AWS access key", Regex: `\b(AKIA|ASIA)[0-9A-Z]{16}\b`},
+	{Name: "GitHub token", Regex: `\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`},
+	{Name: "Slack token", Regex: `\bxox[abprs]-[0-9A-Za-z-]{10,}`},
+	{Name: "Google API key", Regex: `\bAIza[0-9A-Za-z_\-]{35}\b`},
+}
+
+// secretTokenSeparators split lines into the strings the entropy check looks at
+var secretTokenSeparators = regexp.MustCompile(`[\s"'` + "`" + `=:,;(){}\[\]<>]+`)
+
+type compiledSecretRule struct {
+	name  string
+	regex *regexp.Regexp
+}
+
+// secretScanner finds possible secrets in file contents, the findings never include the secrets themselves
+type secretScanner struct {
+	rules             []compiledSecretRule
+	entropyThreshold  float64
+	ignorePathRegexes []string
+	// Blob SHA to its findings, the same source is often promoted to several targets
+	scannedBlobs map[string][]string
+}
+
+// newSecretScanner compiles the built-in and configured rules, the configured ones are validated when the configuration is parsed
+func newSecretScanner(config cfg.SecretScanningConfig) *secretScanner {
+	scanner := &secretScanner{
+		entropyThreshold:  config.EntropyThreshold,
+		ignorePathRegexes: config.IgnorePathRegexes,
+		scannedBlobs:      map[string][]string{},
+	}
+	for _, rule := range append(append([]cfg.SecretScanningRule{}, builtinSecretRules...), config.Rules...) {
+		regex, err := regexp.Compile(rule.Regex)
+		if err != nil {
+			continue
+		}
+		scanner.rules = append(scanner.rules, compiledSecretRule{name: rule.Name, regex: regex})
+	}
+	return scanner
+}
+
+// shannonEntropy returns the bits per character of s
+func shannonEntropy(s string) float64 {
+	counts := map[rune]int{}
+	length := 0
+	for _, r := range s {
+		counts[r]++
+		length++
+	}
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(length)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// scanContent returns the findings of a file, as "line N: rule"
+func (s *secretScanner) scanContent(content []byte) []string {
+	findings := []string{}
+	lines := bufio.NewScanner(bytes.NewReader(content))
+	lines.Buffer(make([]byte, 0, 64*1024), secretScanMaxFileSize)
+	for lineNumber := 1; lines.Scan(); lineNumber++ {
+		line := lines.Text()
+		matched := false
+		for _, rule := range s.rules {
+			if rule.regex.MatchString(line) {
+				findings = append(findings, fmt.Sprintf("line %d: %s", lineNumber, rule.name))
+				matched = true
+				break
+			}
+		}
+		if matched || s.entropyThreshold <= 0 {
+			continue
+		}
+		for _, token := range secretTokenSeparators.Split(line, -1) {
+			if len(token) >= secretScanMinTokenLength && shannonEntropy(token) > s.entropyThreshold {
+				findings = append(findings, fmt.Sprintf("line %d: high entropy string", lineNumber))
+				break
+			}
+		}
+	}
+	return findings
+}
+
+// scanSourcePath returns the findings of the files of sourcePath as found in branch, keyed by file path
+func (s *secretScanner) scanSourcePath(ghPrClientDetails GhPrClientDetails, sourcePath string, branch string) (map[string][]string, error) {
+	entries, err := getDirectoryTree(ghPrClientDetails, sourcePath, branch)
+	if err != nil {
+		return nil, err
+	}
+	findings := map[string][]string{}
+	for _, entry := range entries {
+		filePath := sourcePath + "/" + entry.GetPath()
+		if entry.GetType() != "blob" || entry.GetSize() > secretScanMaxFileSize || containMatchingRegex(s.ignorePathRegexes, filePath) {
+			continue
+		}
+		fileFindings, scanned := s.scannedBlobs[entry.GetSHA()]
+		if !scanned {
+			content, resp, err := ghPrClientDetails.GhClientPair.v3Client.Git.GetBlobRaw(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, entry.GetSHA())
+			prom.InstrumentGhCall(resp)
+			if err != nil {
+				return nil, fmt.Errorf("getting %s: %w", filePath, err)
+			}
+			fileFindings = s.scanContent(content)
+			s.scannedBlobs[entry.GetSHA()] = fileFindings
+		}
+		if len(fileFindings) > 0 {
+			findings[filePath] = fileFindings
+		}
+	}
+	return findings, nil
+}
+
+// scanPromotion returns the findings of all the source paths of a promotion, source paths that can't be scanned are reported as findings
+func (s *secretScanner) scanPromotion(ghPrClientDetails GhPrClientDetails, promotion PromotionInstance, branch string) map[string][]string {
+	findings := map[string][]string{}
+	scannedSources := map[string]bool{}
+	for _, source := range promotion.ComputedSyncPaths {
+		if scannedSources[source] {
+			continue
+		}
+		scannedSources[source] = true
+		sourceFindings, err := s.scanSourcePath(ghPrClientDetails, source, branch)
+		if err != nil {
+			ghPrClientDetails.PrLogger.Errorf("Failed to scan %s for secrets: err=%v", source, err)
+			findings[source] = []string{fmt.Sprintf("the files couldn't be scanned: %s", err)}
+			continue
+		}
+		for file, fileFindings := range sourceFindings {
+			findings[file] = fileFindings
+		}
+	}
+	return findings
+}
+
+func secretScanningComment(promotion PromotionInstance, findings map[string][]string, mode string) string {
+	var comment string
+	if mode == cfg.SecretScanningBlock {
+		comment = fmt.Sprintf("🔐 The promotion of `%s` was blocked, secret scanning found possible credentials in the promoted files:\n", promotion.Metadata.SourcePath)
+	} else {
+		comment = fmt.Sprintf("🔐 Secret scanning found possible credentials in the files promoted from `%s`:\n", promotion.Metadata.SourcePath)
+	}
+	files := make([]string, 0, len(findings))
+	for file := range findings {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		comment += fmt.Sprintf("* `%s`: %s\n", file, strings.Join(findings[file], ", "))
+	}
+	comment += "\nLeaked credentials should be rotated, removing them from the files isn't enough."
+	if mode == cfg.SecretScanningBlock {
+		comment += " Merge again once they are removed(or add false positives to `secretScanning.ignorePathRegexes`), or promote this change manually."
+	}
+	return comment
+}
@@ -0,0 +1,118 @@
+package githubapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+	"golang.org/x/exp/maps"
+)
+
+// errShadowMode is returned by the GitHub writes shadow mode skipped
+var errShadowMode = errors.New("skipped, the repo is in shadow mode")
+
+// Comments are how shadow and maintenance mode report what was skipped, gists hold the comment content that is too long for a comment
+var commentWrites = map[string]bool{
+	"create_comment": true,
+	"edit_comment":   true,
+	"create_gist":    true,
+}
+
+// shadowModeState is shared by the contexts of an event(or a job run), the in-repo configuration enables it once it's read
+type shadowModeState struct {
+	mu      sync.Mutex
+	enabled bool
+	repo    string
+	skipped map[string]int // by action
+}
+
+type shadowModeContextKey struct{}
+
+// withShadowModeState lets the writes of ctx be skipped when the in-repo configuration of the repo enables shadowMode
+func withShadowModeState(ctx context.Context) context.Context {
+	return context.WithValue(ctx, shadowModeContextKey{}, &shadowModeState{skipped: map[string]int{}})
+}
+
+// withShadowModeStateOf is withShadowModeState for the jobs that outlive the event of eventCtx, the job gets a state of its own that starts enabled like the event one
+func withShadowModeStateOf(ctx context.Context, eventCtx context.Context) context.Context {
+	ctx = withShadowModeState(ctx)
+	if state := shadowModeStateFrom(eventCtx); state != nil {
+		state.mu.Lock()
+		defer state.mu.Unlock()
+		markShadowMode(ctx, state.repo, state.enabled)
+	}
+	return ctx
+}
+
+func shadowModeStateFrom(ctx context.Context) *shadowModeState {
+	state, _ := ctx.Value(shadowModeContextKey{}).(*shadowModeState)
+	return state
+}
+
+// markShadowMode records the shadowMode in-repo configuration of repo in the state of ctx, if it has one
+func markShadowMode(ctx context.Context, repo string, enabled bool) {
+	state := shadowModeStateFrom(ctx)
+	if state == nil {
+		return
+	}
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.enabled = enabled
+	state.repo = repo
+}
+
+// skipInShadowMode returns true(and records the action) when the repo of ctx is in shadow mode and the action shouldn't run
+func skipInShadowMode(ctx context.Context, action string) bool {
+	state := shadowModeStateFrom(ctx)
+	if state == nil {
+		return false
+	}
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if !state.enabled {
+		return false
+	}
+	state.skipped[action]++
+	prom.InstrumentShadowModeAction(state.repo, action)
+	log.Infof("Shadow mode is enabled in %s, skipping %s", state.repo, action)
+	return true
+}
+
+// skipGhWriteInShadowMode is skipInShadowMode for GitHub writes, comments are still written
+func skipGhWriteInShadowMode(ctx context.Context, operation string) bool {
+	return !commentWrites[operation] && skipInShadowMode(ctx, operation)
+}
+
+// shadowModeActions returns the actions shadow mode skipped in the state of ctx, with their count
+func shadowModeActions(ctx context.Context) []string {
+	state := shadowModeStateFrom(ctx)
+	if state == nil {
+		return nil
+	}
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	actions := maps.Keys(state.skipped)
+	sort.Strings(actions)
+	for i, action := range actions {
+		if count := state.skipped[action]; count > 1 {
+			actions[i] = fmt.Sprintf("`%s` (%d times)", action, count)
+		} else {
+			actions[i] = fmt.Sprintf("`%s`", action)
+		}
+	}
+	return actions
+}
+
+// commentShadowModeActions posts the "would have done" comment of the writes shadow mode skipped while handling the PR event
+func commentShadowModeActions(ghPrClientDetails GhPrClientDetails) {
+	actions := shadowModeActions(ghPrClientDetails.Ctx)
+	if len(actions) == 0 {
+		return
+	}
+	_ = commentPR(ghPrClientDetails, "🕶️ This repo is in shadow mode, Telefonistka would have done these actions:\n* "+strings.Join(actions, "\n* ")+"\n")
+}
@@ -0,0 +1,122 @@
+package githubapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+)
+
+func TestSkipInShadowMode(t *testing.T) {
+	t.Parallel()
+	ctx := withShadowModeState(context.Background())
+	assert.False(t, skipGhWriteInShadowMode(ctx, "create_pr"), "writes are only skipped once the repo config enables shadow mode")
+
+	markShadowMode(ctx, "example/repo", true)
+	assert.True(t, skipGhWriteInShadowMode(ctx, "create_pr"))
+	assert.True(t, skipGhWriteInShadowMode(ctx, "merge_pr"))
+	assert.True(t, skipGhWriteInShadowMode(ctx, "merge_pr"))
+	assert.False(t, skipGhWriteInShadowMode(ctx, "create_comment"), "comments are how shadow mode reports")
+	assert.Equal(t, []string{"`create_pr`", "`merge_pr` (2 times)"}, shadowModeActions(ctx))
+}
+
+func TestSkipInShadowModeWithoutState(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	markShadowMode(ctx, "example/repo", true)
+	assert.False(t, skipInShadowMode(ctx, "argocd_set_revision"))
+	assert.Empty(t, shadowModeActions(ctx))
+}
+
+// Not parallel, the test shortens the package level mergeability poll interval
+func TestBackgroundJobsInShadowMode(t *testing.T) {
+	mergeabilityPollInterval = time.Millisecond
+	t.Cleanup(func() { mergeabilityPollInterval = 5 * time.Second })
+
+	metadata, _ := prMetadata{
+		PromotedPaths:             []string{"env/prod/c1"},
+		PreviousPromotionMetadata: map[int]promotionInstanceMetaData{1: {SourcePath: "env/staging/", TargetPaths: []string{"env/prod/"}}},
+	}.serialize()
+	var (
+		mu       sync.Mutex
+		comments []string
+	)
+	failOnWrite := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected write in shadow mode: %s %s", r.Method, r.URL.Path)
+		mock.WriteError(w, http.StatusInternalServerError, "shadow mode")
+	})
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetReposPullsByOwnerByRepo,
+			[]*github.PullRequest{
+				{Number: github.Int(7), Labels: []*github.Label{{Name: github.String("promotion")}}, Body: github.String(prMetadataComment(prMetadataBinding{}, metadata, nil)), Head: &github.PullRequestBranch{Ref: github.String("promotions/7"), SHA: github.String("oldhead")}},
+			},
+		),
+		mock.WithRequestMatch(
+			mock.GetReposPullsByOwnerByRepoByPullNumber,
+			github.PullRequest{Number: github.Int(7), Mergeable: github.Bool(false)},
+		),
+		mock.WithRequestMatchHandler(
+			mock.GetReposContentsByOwnerByRepoByPath,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/repos/AnOwner/Arepo/contents/env/staging":
+					_, _ = w.Write(mock.MustMarshal([]github.RepositoryContent{{Type: github.String("dir"), Path: github.String("env/staging/c1"), SHA: github.String("sourcetree")}}))
+				case "/repos/AnOwner/Arepo/contents/env/staging/c1":
+					_, _ = w.Write(mock.MustMarshal([]github.RepositoryContent{{Type: github.String("file"), Path: github.String("env/staging/c1/values.yaml"), SHA: github.String("a")}}))
+				default:
+					mock.WriteError(w, http.StatusNotFound, "Not Found")
+				}
+			}),
+		),
+		mock.WithRequestMatch(
+			mock.GetReposGitRefByOwnerByRepoByRef,
+			github.Reference{Object: &github.GitObject{SHA: github.String("mainhead")}},
+		),
+		mock.WithRequestMatchHandler(mock.PostReposGitTreesByOwnerByRepo, failOnWrite),
+		mock.WithRequestMatchHandler(mock.PostReposGitCommitsByOwnerByRepo, failOnWrite),
+		mock.WithRequestMatchHandler(mock.PatchReposGitRefsByOwnerByRepoByRef, failOnWrite),
+		mock.WithRequestMatchHandler(mock.PostReposStatusesByOwnerByRepoBySha, failOnWrite),
+		mock.WithRequestMatchHandler(
+			mock.PostReposIssuesCommentsByOwnerByRepoByIssueNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var comment github.IssueComment
+				_ = json.NewDecoder(r.Body).Decode(&comment)
+				mu.Lock()
+				comments = append(comments, comment.GetBody())
+				mu.Unlock()
+				_, _ = w.Write(mock.MustMarshal(comment))
+			}),
+		),
+	)
+	// The event context is canceled once the event is handled, the jobs must still know the repo is in shadow mode
+	eventCtx, cancel := context.WithCancel(withShadowModeState(context.Background()))
+	markShadowMode(eventCtx, "AnOwner/Arepo", true)
+	cancel()
+	ghPrClientDetails := GhPrClientDetails{
+		Ctx:          eventCtx,
+		GhClientPair: &GhClientPair{v3Client: github.NewClient(mockedHTTPClient)},
+		Owner:        "AnOwner",
+		Repo:         "Arepo",
+		PrNumber:     1,
+		PrLogger:     log.WithFields(log.Fields{"repo": "AnOwner/Arepo", "prNumber": 1}),
+	}
+
+	rebaseConflictingPromotionPrs(ghPrClientDetails, "main")
+	assert.Empty(t, comments, "a skipped rebase isn't reported as a failed one")
+
+	ghPrClientDetails.PrMetadata = prMetadata{PromotedPaths: []string{"env/prod/c1"}}
+	postMergeSync(ghPrClientDetails, &cfg.Config{}, "mergesha")
+	if assert.Len(t, comments, 1) {
+		assert.Contains(t, comments[0], "`argocd_sync`")
+	}
+	assert.Empty(t, shadowModeActions(eventCtx), "the jobs record their skipped actions in states of their own")
+}
@@ -0,0 +1,89 @@
+package githubapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/secrets"
+)
+
+const (
+	sharedCacheKeyPrefix = "telefonistka:cache:"
+	sharedCacheTimeout   = 2 * time.Second
+)
+
+// sharedCache keeps cache entries(JSON encoded) in Redis, so they survive restarts and are shared by the replicas.
+// The in-memory caches are still used, Redis is checked when they miss and errors are treated like a miss
+type sharedCache struct {
+	client *redis.Client
+}
+
+var (
+	sharedCacheOnce sync.Once
+	sharedCacheInst *sharedCache
+)
+
+// getSharedCache returns the(possibly nil, i.e. disabled) shared cache configured by CACHE_REDIS_ADDR
+func getSharedCache() *sharedCache {
+	sharedCacheOnce.Do(func() {
+		addr := getEnv("CACHE_REDIS_ADDR", "")
+		if addr == "" {
+			return
+		}
+		sharedCacheInst = newSharedCache(addr, secrets.Get("CACHE_REDIS_PASSWORD", ""))
+		log.Infof("Caches are shared in Redis at %s", addr)
+	})
+	return sharedCacheInst
+}
+
+func newSharedCache(addr string, password string) *sharedCache {
+	return &sharedCache{client: redis.NewClient(&redis.Options{Addr: addr, Password: password})}
+}
+
+// get decodes the cached value of key into v, it returns false when key isn't cached(or Redis failed)
+func (c *sharedCache) get(ctx context.Context, cache string, key string, v any) bool {
+	if c == nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sharedCacheTimeout)
+	defer cancel()
+	content, err := c.client.Get(ctx, sharedCacheKeyPrefix+cache+":"+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		prom.InstrumentSharedCacheRequest(cache, "miss")
+		return false
+	}
+	if err == nil {
+		err = json.Unmarshal(content, v)
+	}
+	if err != nil {
+		log.Warnf("Failed to get %s %s from the shared cache: err=%v", cache, key, err)
+		prom.InstrumentSharedCacheRequest(cache, "error")
+		return false
+	}
+	prom.InstrumentSharedCacheRequest(cache, "hit")
+	return true
+}
+
+// set caches v for ttl, failures are only logged since the in-memory caches still have it
+func (c *sharedCache) set(ctx context.Context, cache string, key string, v any, ttl time.Duration) {
+	if c == nil || ttl <= 0 {
+		return
+	}
+	content, err := json.Marshal(v)
+	if err != nil {
+		log.Warnf("Failed to encode %s %s for the shared cache: err=%v", cache, key, err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sharedCacheTimeout)
+	defer cancel()
+	if err := c.client.Set(ctx, sharedCacheKeyPrefix+cache+":"+key, content, ttl).Err(); err != nil {
+		log.Warnf("Failed to set %s %s in the shared cache: err=%v", cache, key, err)
+		prom.InstrumentSharedCacheRequest(cache, "error")
+	}
+}
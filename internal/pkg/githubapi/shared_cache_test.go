@@ -0,0 +1,42 @@
+package githubapi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/go-github/v62/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSharedCache(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	redisServer := miniredis.RunT(t)
+	cache := newSharedCache(redisServer.Addr(), "")
+
+	var comment *github.IssueComment
+	assert.False(t, cache.get(ctx, "github_write", "72d3162e@abc|create_comment|1/hash|1", &comment))
+
+	cache.set(ctx, "github_write", "72d3162e@abc|create_comment|1/hash|1", &github.IssueComment{ID: github.Int64(42)}, time.Minute)
+	// Another replica(or this one after a restart) gets the cached value
+	assert.True(t, newSharedCache(redisServer.Addr(), "").get(ctx, "github_write", "72d3162e@abc|create_comment|1/hash|1", &comment))
+	assert.Equal(t, int64(42), comment.GetID())
+
+	redisServer.FastForward(2 * time.Minute)
+	assert.False(t, cache.get(ctx, "github_write", "72d3162e@abc|create_comment|1/hash|1", &comment), "entries expire with their TTL")
+
+	redisServer.Close()
+	cache.set(ctx, "installation_id", "123/org", int64(456), time.Hour)
+	var installationId int64
+	assert.False(t, cache.get(ctx, "installation_id", "123/org", &installationId), "Redis errors are misses")
+}
+
+func TestNilSharedCacheIsDisabled(t *testing.T) {
+	t.Parallel()
+	var cache *sharedCache
+	cache.set(context.Background(), "installation_id", "123/org", int64(456), time.Hour)
+	var installationId int64
+	assert.False(t, cache.get(context.Background(), "installation_id", "123/org", &installationId))
+}
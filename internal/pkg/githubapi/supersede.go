@@ -0,0 +1,65 @@
+package githubapi
+
+import (
+	"fmt"
+
+	"github.com/google/go-github/v62/github"
+)
+
+const (
+	supersedeModeUpdate = "update"
+	supersedeModeClose  = "close"
+)
+
+// findSupersededPromotionPr returns the newest open promotion PR that promotes the same source and target paths, nil if there is none
+func findSupersededPromotionPr(ghPrClientDetails GhPrClientDetails, defaultBranch string, key string) (*github.PullRequest, error) {
+	if key == "" {
+		return nil, nil
+	}
+	prs, err := listOpenPromotionPrs(ghPrClientDetails, defaultBranch)
+	if err != nil {
+		return nil, err
+	}
+	var superseded *github.PullRequest
+	for _, pr := range prs {
+		prDetails := ghPrClientDetails
+		prDetails.PrMetadata = prMetadata{}
+		prDetails.Ref = pr.GetHead().GetRef()
+		if err := prDetails.getPrMetadata(pr.GetBody()); err != nil {
+			continue
+		}
+		if promotionKey(prDetails.PrMetadata) == key && pr.GetNumber() > superseded.GetNumber() {
+			superseded = pr
+		}
+	}
+	return superseded, nil
+}
+
+// updatePromotionPrInPlace force-pushes the new promotion commit to the branch of an open promotion PR and replaces its title and body
+func updatePromotionPrInPlace(ghPrClientDetails GhPrClientDetails, pr *github.PullRequest, commit *github.Commit, newPrTitle string, newPrBody string) (*github.PullRequest, error) {
+	branchRef := &github.Reference{
+		Ref:    github.String("refs/heads/" + pr.GetHead().GetRef()),
+		Object: &github.GitObject{SHA: commit.SHA},
+	}
+	_, _, err := retryGhWrite(ghPrClientDetails.Ctx, "update_ref", func() (*github.Reference, *github.Response, error) {
+		return ghPrClientDetails.GhClientPair.v3Client.Git.UpdateRef(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, branchRef, true)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to force-push %s: %w", pr.GetHead().GetRef(), err)
+	}
+	updatedPr, _, err := retryGhWrite(ghPrClientDetails.Ctx, "edit_pr", func() (*github.PullRequest, *github.Response, error) {
+		return ghPrClientDetails.GhClientPair.v3Client.PullRequests.Edit(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, pr.GetNumber(), &github.PullRequest{
+			Title: github.String(newPrTitle),
+			Body:  github.String(newPrBody),
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update PR %d: %w", pr.GetNumber(), err)
+	}
+	ghPrClientDetails.PrLogger.Infof("Updated open promotion PR %d in place", pr.GetNumber())
+
+	prDetails := ghPrClientDetails
+	prDetails.PrNumber = pr.GetNumber()
+	_ = prDetails.CommentOnPr(fmt.Sprintf("Telefonistka updated this promotion PR in place with the changes promoted from #%d, the previous head was %s.", ghPrClientDetails.PrNumber, pr.GetHead().GetSHA()))
+	return updatedPr, nil
+}
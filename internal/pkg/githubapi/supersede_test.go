@@ -0,0 +1,97 @@
+package githubapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindSupersededPromotionPr(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	promotionLabel := []*github.Label{{Name: github.String("promotion")}}
+	prs := []*github.PullRequest{
+		testPromotionPr(t, 10, now, "env/staging/", "env/prod/c1"),
+		testPromotionPr(t, 20, now, "env/staging/", "env/prod/c1"),
+		testPromotionPr(t, 30, now, "env/staging/", "env/prod/c1", "env/prod/c2"),
+	}
+	for _, pr := range prs {
+		pr.Labels = promotionLabel
+	}
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(mock.GetReposPullsByOwnerByRepo, prs, prs),
+	)
+	details := GhPrClientDetails{
+		Ctx:          context.Background(),
+		GhClientPair: &GhClientPair{v3Client: github.NewClient(mockedHTTPClient)},
+		Owner:        "AnOwner",
+		Repo:         "Arepo",
+		PrLogger:     log.WithFields(log.Fields{"repo": "AnOwner/Arepo"}),
+	}
+
+	pr, err := findSupersededPromotionPr(details, "main", promotionKeyFor("env/staging/", []string{"env/prod/c1"}))
+	assert.NoError(t, err)
+	assert.Equal(t, 20, pr.GetNumber())
+
+	pr, err = findSupersededPromotionPr(details, "main", promotionKeyFor("env/dev/", []string{"env/prod/c1"}))
+	assert.NoError(t, err)
+	assert.Nil(t, pr)
+}
+
+func TestUpdatePromotionPrInPlace(t *testing.T) {
+	t.Parallel()
+	var updatedRef map[string]interface{}
+	var editedPr github.PullRequest
+	var comment github.IssueComment
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.PatchReposGitRefsByOwnerByRepoByRef,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/repos/AnOwner/Arepo/git/refs/heads/promotions/20", r.URL.Path)
+				_ = json.NewDecoder(r.Body).Decode(&updatedRef)
+				_, _ = w.Write(mock.MustMarshal(github.Reference{}))
+			}),
+		),
+		mock.WithRequestMatchHandler(
+			mock.PatchReposPullsByOwnerByRepoByPullNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/repos/AnOwner/Arepo/pulls/20", r.URL.Path)
+				_ = json.NewDecoder(r.Body).Decode(&editedPr)
+				editedPr.Number = github.Int(20)
+				_, _ = w.Write(mock.MustMarshal(editedPr))
+			}),
+		),
+		mock.WithRequestMatchHandler(
+			mock.PostReposIssuesCommentsByOwnerByRepoByIssueNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/repos/AnOwner/Arepo/issues/20/comments", r.URL.Path)
+				_ = json.NewDecoder(r.Body).Decode(&comment)
+				_, _ = w.Write(mock.MustMarshal(comment))
+			}),
+		),
+	)
+	details := GhPrClientDetails{
+		Ctx:          context.Background(),
+		GhClientPair: &GhClientPair{v3Client: github.NewClient(mockedHTTPClient)},
+		Owner:        "AnOwner",
+		Repo:         "Arepo",
+		PrNumber:     42,
+		PrLogger:     log.WithFields(log.Fields{"repo": "AnOwner/Arepo", "prNumber": 42}),
+	}
+	openPr := &github.PullRequest{Number: github.Int(20), Head: &github.PullRequestBranch{Ref: github.String("promotions/20"), SHA: github.String("oldhead")}}
+
+	pull, err := updatePromotionPrInPlace(details, openPr, &github.Commit{SHA: github.String("newhead")}, "new title", "new body")
+	assert.NoError(t, err)
+	assert.Equal(t, 20, pull.GetNumber())
+	assert.Equal(t, map[string]interface{}{"sha": "newhead", "force": true}, updatedRef)
+	assert.Equal(t, "new title", editedPr.GetTitle())
+	assert.Equal(t, "new body", editedPr.GetBody())
+	assert.Contains(t, comment.GetBody(), "promoted from #42")
+}
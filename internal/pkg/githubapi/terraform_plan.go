@@ -0,0 +1,67 @@
+package githubapi
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/terraform"
+)
+
+// terraformComponentPaths returns the sorted changed components matching one of pathRegexes
+func terraformComponentPaths(componentPaths []string, pathRegexes []string) []string {
+	matchingPaths := []string{}
+	for _, componentPath := range componentPaths {
+		for _, pathRegex := range pathRegexes {
+			if match, _ := regexp.MatchString(pathRegex, componentPath); match {
+				matchingPaths = append(matchingPaths, componentPath)
+				break
+			}
+		}
+	}
+	sort.Strings(matchingPaths)
+	return matchingPaths
+}
+
+// terraformDiffProvider plans the changed Terraform components with the runner configured by TERRAFORM_PLAN_RUNNER
+type terraformDiffProvider struct{}
+
+func (p terraformDiffProvider) Title() string {
+	return "Terraform plan"
+}
+
+func (p terraformDiffProvider) Diff(ghPrClientDetails GhPrClientDetails, componentPaths []string, _ string) ([]ComponentDiff, error) {
+	runner, err := terraform.RunnerFromEnv(ghPrClientDetails.Ctx)
+	if err != nil {
+		return nil, err
+	}
+	prDir := ""
+	if runner.NeedsCheckout() {
+		prDir, err = downloadRepoSnapshot(ghPrClientDetails, ghPrClientDetails.PrSHA)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = os.RemoveAll(prDir) }()
+	}
+
+	diffs := []ComponentDiff{}
+	for _, componentPath := range componentPaths {
+		request := terraform.PlanRequest{
+			Repo:          ghPrClientDetails.Owner + "/" + ghPrClientDetails.Repo,
+			PrNumber:      ghPrClientDetails.PrNumber,
+			Ref:           ghPrClientDetails.Ref,
+			SHA:           ghPrClientDetails.PrSHA,
+			ComponentPath: componentPath,
+		}
+		if prDir != "" {
+			request.Dir = filepath.Join(prDir, filepath.FromSlash(componentPath))
+		}
+		plan, err := runner.Plan(ghPrClientDetails.Ctx, request)
+		if err != nil {
+			ghPrClientDetails.PrLogger.Errorf("Failed to plan Terraform component %s: err=%s", componentPath, err)
+		}
+		diffs = append(diffs, ComponentDiff{ComponentPath: componentPath, Summary: terraform.Summary(plan), Diff: plan, Err: err})
+	}
+	return diffs, nil
+}
@@ -0,0 +1,13 @@
+package githubapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTerraformComponentPaths(t *testing.T) {
+	t.Parallel()
+	componentPaths := []string{"infra/prod/vpc", "clusters/prod/app", "infra/dev/vpc"}
+	assert.Equal(t, []string{"infra/dev/vpc", "infra/prod/vpc"}, terraformComponentPaths(componentPaths, []string{"^infra/"}))
+}
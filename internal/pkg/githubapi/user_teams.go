@@ -0,0 +1,82 @@
+package githubapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+)
+
+type githubUserTeamsResult struct {
+	login string
+	teams []string
+}
+
+// githubUserTeamsCache saves the GitHub calls of the admin API requests of the same token, a removed team member keeps its access until the entry expires.
+// Entries are keyed by the token hash, failed lookups aren't cached
+var githubUserTeamsCache = expirable.NewLRU[string, githubUserTeamsResult](1024, nil, time.Minute)
+
+// GithubUserTeams authenticates a GitHub OAuth(or personal access) token and returns its user and which of teams("org/team-slug") it's an active member of.
+// The token needs the read:org scope to see team memberships
+func GithubUserTeams(ctx context.Context, token string, teams []string) (string, []string, error) {
+	return cachedGithubUserTeams(token, teams, func() (string, []string, error) {
+		client := github.NewClient(nil).WithAuthToken(token)
+		if githubHost := getEnv("GITHUB_HOST", ""); githubHost != "" {
+			githubRestAltURL := fmt.Sprintf("https://%s/api/v3", githubHost)
+			var err error
+			client, err = client.WithEnterpriseURLs(githubRestAltURL, githubRestAltURL)
+			if err != nil {
+				return "", nil, err
+			}
+		}
+		return githubUserTeams(ctx, client, teams)
+	})
+}
+
+func cachedGithubUserTeams(token string, teams []string, lookup func() (string, []string, error)) (string, []string, error) {
+	tokenHash := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(tokenHash[:]) + "|" + strings.Join(teams, ",")
+	if cached, ok := githubUserTeamsCache.Get(key); ok {
+		return cached.login, cached.teams, nil
+	}
+	login, memberOf, err := lookup()
+	if err != nil {
+		return login, memberOf, err
+	}
+	githubUserTeamsCache.Add(key, githubUserTeamsResult{login: login, teams: memberOf})
+	return login, memberOf, nil
+}
+
+func githubUserTeams(ctx context.Context, client *github.Client, teams []string) (string, []string, error) {
+	user, resp, err := client.Users.Get(ctx, "")
+	prom.InstrumentGhCall(resp)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get the user of the GitHub token: %w", err)
+	}
+	memberOf := []string{}
+	for _, team := range teams {
+		org, slug, ok := strings.Cut(team, "/")
+		if !ok {
+			continue
+		}
+		membership, resp, err := client.Teams.GetTeamMembershipBySlug(ctx, org, slug, user.GetLogin())
+		prom.InstrumentGhCall(resp)
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return user.GetLogin(), nil, fmt.Errorf("failed to get the %s team membership of %s: %w", team, user.GetLogin(), err)
+		}
+		if membership.GetState() == "active" {
+			memberOf = append(memberOf, team)
+		}
+	}
+	return user.GetLogin(), memberOf, nil
+}
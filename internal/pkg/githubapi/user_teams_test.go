@@ -0,0 +1,69 @@
+package githubapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGithubUserTeams(t *testing.T) {
+	t.Parallel()
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetUser,
+			github.User{Login: github.String("alice")},
+		),
+		mock.WithRequestMatchHandler(
+			mock.GetOrgsTeamsMembershipsByOrgByTeamSlugByUsername,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/orgs/acme/teams/sre/memberships/alice":
+					_, _ = w.Write(mock.MustMarshal(github.Membership{State: github.String("active")}))
+				case "/orgs/acme/teams/developers/memberships/alice":
+					_, _ = w.Write(mock.MustMarshal(github.Membership{State: github.String("pending")}))
+				default:
+					mock.WriteError(w, http.StatusNotFound, "Not Found")
+				}
+			}),
+		),
+	)
+	login, teams, err := githubUserTeams(context.Background(), github.NewClient(mockedHTTPClient), []string{"acme/sre", "acme/developers", "acme/marketing"})
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", login)
+	assert.Equal(t, []string{"acme/sre"}, teams, "pending and missing memberships don't count")
+}
+
+func TestCachedGithubUserTeams(t *testing.T) {
+	t.Parallel()
+	lookups := 0
+	lookup := func(login string, err error) func() (string, []string, error) {
+		return func() (string, []string, error) {
+			lookups++
+			return login, []string{"acme/sre"}, err
+		}
+	}
+	teams := []string{"acme/sre", "acme/cache-test"}
+
+	_, _, err := cachedGithubUserTeams("failing-token", teams, lookup("", errors.New("bad credentials")))
+	assert.Error(t, err)
+	_, _, err = cachedGithubUserTeams("failing-token", teams, lookup("", errors.New("bad credentials")))
+	assert.Error(t, err, "failed lookups aren't cached")
+	assert.Equal(t, 2, lookups)
+
+	login, memberOf, err := cachedGithubUserTeams("alice-token", teams, lookup("alice", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", login)
+	assert.Equal(t, []string{"acme/sre"}, memberOf)
+	login, _, _ = cachedGithubUserTeams("alice-token", teams, lookup("other", nil))
+	assert.Equal(t, "alice", login, "the cached result is returned")
+	assert.Equal(t, 3, lookups)
+
+	login, _, _ = cachedGithubUserTeams("bob-token", teams, lookup("bob", nil))
+	assert.Equal(t, "bob", login, "tokens don't share entries")
+	assert.Equal(t, 4, lookups)
+}
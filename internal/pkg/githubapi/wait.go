@@ -0,0 +1,35 @@
+package githubapi
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+)
+
+// ErrPrClosedWithoutMerge is returned by WaitForPrMerge when the PR was closed before it was merged
+var ErrPrClosedWithoutMerge = errors.New("PR was closed without being merged")
+
+// WaitForPrMerge polls the PR until it's merged and returns the merge commit SHA, the wait is bound by ghPrClientDetails.Ctx
+func WaitForPrMerge(ghPrClientDetails GhPrClientDetails, prNumber int, pollInterval time.Duration) (string, error) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		pr, resp, err := ghPrClientDetails.GhClientPair.v3Client.PullRequests.Get(ghPrClientDetails.Ctx, ghPrClientDetails.Owner, ghPrClientDetails.Repo, prNumber)
+		prom.InstrumentGhCall(resp)
+		if err != nil {
+			ghPrClientDetails.PrLogger.Warnf("Failed to get PR %d while waiting for merge: err=%v", prNumber, err)
+		} else if pr.GetMerged() {
+			return pr.GetMergeCommitSHA(), nil
+		} else if pr.GetState() == "closed" {
+			return "", ErrPrClosedWithoutMerge
+		}
+
+		select {
+		case <-ghPrClientDetails.Ctx.Done():
+			return "", fmt.Errorf("waiting for PR %d to be merged: %w", prNumber, ghPrClientDetails.Ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package githubapi
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func waitForPrMergeTestDetails(ctx context.Context, mockedHTTPClient *http.Client) GhPrClientDetails {
+	return GhPrClientDetails{
+		Ctx:          ctx,
+		GhClientPair: &GhClientPair{v3Client: github.NewClient(mockedHTTPClient)},
+		Owner:        "AnOwner",
+		Repo:         "Arepo",
+		PrLogger:     log.WithFields(log.Fields{"repo": "AnOwner/Arepo"}),
+	}
+}
+
+func TestWaitForPrMerge(t *testing.T) {
+	t.Parallel()
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetReposPullsByOwnerByRepoByPullNumber,
+			github.PullRequest{State: github.String("open")},
+			github.PullRequest{State: github.String("closed"), Merged: github.Bool(true), MergeCommitSHA: github.String("abc123")},
+		),
+	)
+	ghPrClientDetails := waitForPrMergeTestDetails(context.Background(), mockedHTTPClient)
+
+	sha, err := WaitForPrMerge(ghPrClientDetails, 1, time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc123", sha)
+}
+
+func TestWaitForPrMergeClosedPr(t *testing.T) {
+	t.Parallel()
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetReposPullsByOwnerByRepoByPullNumber,
+			github.PullRequest{State: github.String("closed"), Merged: github.Bool(false)},
+		),
+	)
+	ghPrClientDetails := waitForPrMergeTestDetails(context.Background(), mockedHTTPClient)
+
+	_, err := WaitForPrMerge(ghPrClientDetails, 1, time.Millisecond)
+	assert.ErrorIs(t, err, ErrPrClosedWithoutMerge)
+}
+
+func TestWaitForPrMergeTimeout(t *testing.T) {
+	t.Parallel()
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposPullsByOwnerByRepoByPullNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write(mock.MustMarshal(github.PullRequest{State: github.String("open")}))
+			}),
+		),
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	ghPrClientDetails := waitForPrMergeTestDetails(ctx, mockedHTTPClient)
+
+	_, err := WaitForPrMerge(ghPrClientDetails, 1, time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
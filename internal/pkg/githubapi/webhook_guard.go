@@ -0,0 +1,221 @@
+package githubapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	log "github.com/sirupsen/logrus"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/secrets"
+)
+
+var (
+	ErrWebhookSourceNotAllowed  = errors.New("webhook source IP is not in GitHub's hook IP ranges")
+	ErrDuplicateWebhookDelivery = errors.New("webhook delivery was already received")
+	ErrNoWebhookSecret          = errors.New("no webhook secret is configured for the webhook repo")
+	ErrWebhookTenantMismatch    = errors.New("webhook repo doesn't belong to the tenant of the webhook endpoint")
+)
+
+// WebhookGuard adds optional checks on top of the webhook HMAC validation: that requests come from GitHub's hook IP ranges
+// and that a delivery ID isn't handled twice within a time window, by this replica or, with a shared Redis, by any replica
+type WebhookGuard struct {
+	// Header holding the client IP when Telefonistka is behind a proxy, the last address in it is used
+	clientIPHeader string
+
+	metaClient   *github.Client
+	mu           sync.RWMutex
+	hookPrefixes []netip.Prefix
+
+	deliveriesMu   sync.Mutex
+	seenDeliveries *expirable.LRU[string, struct{}]
+	// Shared by the replicas, seenDeliveries is only used when it fails
+	sharedDeliveries *redisDeliveryClaims
+}
+
+// NewWebhookGuardFromEnv returns nil when none of the checks are enabled
+func NewWebhookGuardFromEnv(ctx context.Context) *WebhookGuard {
+	guard := &WebhookGuard{clientIPHeader: getEnv("WEBHOOK_CLIENT_IP_HEADER", "")}
+	enabled := false
+
+	redisAddr := getEnv("WEBHOOK_DELIVERY_CLAIM_REDIS_ADDR", "")
+	defaultReplayWindow := "0"
+	if redisAddr != "" {
+		defaultReplayWindow = "3600"
+	}
+	if replayWindow, err := strconv.Atoi(getEnv("WEBHOOK_REPLAY_WINDOW_SECONDS", defaultReplayWindow)); err == nil && replayWindow > 0 {
+		guard.seenDeliveries = expirable.NewLRU[string, struct{}](100000, nil, time.Duration(replayWindow)*time.Second)
+		if redisAddr != "" {
+			guard.sharedDeliveries = newRedisDeliveryClaims(redisAddr, secrets.Get("WEBHOOK_DELIVERY_CLAIM_REDIS_PASSWORD", ""), time.Duration(replayWindow)*time.Second)
+			log.Infof("Webhook deliveries are claimed in Redis at %s", redisAddr)
+		}
+		enabled = true
+	}
+
+	if allowlist, _ := strconv.ParseBool(getEnv("WEBHOOK_IP_ALLOWLIST_ENABLED", "false")); allowlist {
+		// The meta endpoint doesn't require authentication
+		guard.metaClient = github.NewClient(&http.Client{Timeout: 30 * time.Second})
+		if githubHost := getEnv("GITHUB_HOST", ""); githubHost != "" {
+			githubRestAltURL := fmt.Sprintf("https://%s/api/v3", githubHost)
+			guard.metaClient, _ = guard.metaClient.WithEnterpriseURLs(githubRestAltURL, githubRestAltURL)
+		}
+		refreshMinutes, err := strconv.Atoi(getEnv("WEBHOOK_IP_ALLOWLIST_REFRESH_MINUTES", "60"))
+		if err != nil || refreshMinutes <= 0 {
+			log.Fatalf("WEBHOOK_IP_ALLOWLIST_REFRESH_MINUTES should be a positive integer: %v", err)
+		}
+		if err := guard.refreshHookRanges(ctx); err != nil {
+			log.Errorf("Failed to fetch GitHub hook IP ranges, webhooks will be rejected until they are fetched: err=%v", err)
+		}
+		go guard.hookRangesRefreshLoop(ctx, time.Duration(refreshMinutes)*time.Minute)
+		enabled = true
+	}
+
+	if !enabled {
+		return nil
+	}
+	return guard
+}
+
+func (wg *WebhookGuard) hookRangesRefreshLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := wg.refreshHookRanges(ctx); err != nil {
+				// Keep using the previous ranges, they rarely change
+				log.Errorf("Failed to refresh GitHub hook IP ranges: err=%v", err)
+			}
+		}
+	}
+}
+
+func (wg *WebhookGuard) refreshHookRanges(ctx context.Context) error {
+	meta, resp, err := wg.metaClient.Meta.Get(ctx)
+	prom.InstrumentGhCall(resp)
+	if err != nil {
+		return err
+	}
+	prefixes, err := parseHookRanges(meta.Hooks)
+	if err != nil {
+		return err
+	}
+	wg.mu.Lock()
+	wg.hookPrefixes = prefixes
+	wg.mu.Unlock()
+	log.Infof("Loaded %d GitHub hook IP ranges", len(prefixes))
+	return nil
+}
+
+func parseHookRanges(hooks []string) ([]netip.Prefix, error) {
+	if len(hooks) == 0 {
+		return nil, fmt.Errorf("GitHub meta API returned no hook IP ranges")
+	}
+	prefixes := make([]netip.Prefix, 0, len(hooks))
+	for _, hook := range hooks {
+		prefix, err := netip.ParsePrefix(hook)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse hook IP range %q: %w", hook, err)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
+
+func (wg *WebhookGuard) clientIP(r *http.Request) (netip.Addr, error) {
+	source := r.RemoteAddr
+	if wg.clientIPHeader != "" {
+		if headerValue := r.Header.Get(wg.clientIPHeader); headerValue != "" {
+			addresses := strings.Split(headerValue, ",")
+			source = strings.TrimSpace(addresses[len(addresses)-1])
+		}
+	}
+	if host, _, err := net.SplitHostPort(source); err == nil {
+		source = host
+	}
+	addr, err := netip.ParseAddr(source)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("failed to parse webhook source IP %q: %w", source, err)
+	}
+	return addr.Unmap(), nil
+}
+
+// CheckSource verifies the request comes from one of GitHub's hook IP ranges, it's a no-op when the allowlist isn't enabled
+func (wg *WebhookGuard) CheckSource(r *http.Request) error {
+	if wg == nil || wg.metaClient == nil {
+		return nil
+	}
+	addr, err := wg.clientIP(r)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWebhookSourceNotAllowed, err)
+	}
+	wg.mu.RLock()
+	defer wg.mu.RUnlock()
+	for _, prefix := range wg.hookPrefixes {
+		if prefix.Contains(addr) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrWebhookSourceNotAllowed, addr)
+}
+
+// checkDelivery rejects delivery IDs that were already seen within the replay window, it should only be called on validated requests
+// so unauthenticated requests can't block future deliveries
+func (wg *WebhookGuard) checkDelivery(r *http.Request) error {
+	if wg == nil || wg.seenDeliveries == nil {
+		return nil
+	}
+	deliveryID := github.DeliveryID(r)
+	if deliveryID == "" {
+		return nil
+	}
+	if wg.sharedDeliveries != nil {
+		claimed, err := wg.sharedDeliveries.claim(r.Context(), deliveryID)
+		if err == nil {
+			if !claimed {
+				return fmt.Errorf("%w: %s", ErrDuplicateWebhookDelivery, deliveryID)
+			}
+			return nil
+		}
+		// Handling a delivery twice is better than dropping it
+		log.Errorf("Failed to claim webhook delivery %s in Redis, only checking the deliveries of this replica: err=%v", deliveryID, err)
+	}
+	wg.deliveriesMu.Lock()
+	defer wg.deliveriesMu.Unlock()
+	if wg.seenDeliveries.Contains(deliveryID) {
+		return fmt.Errorf("%w: %s", ErrDuplicateWebhookDelivery, deliveryID)
+	}
+	wg.seenDeliveries.Add(deliveryID, struct{}{})
+	return nil
+}
+
+// releaseDelivery forgets a delivery checkDelivery accepted, for deliveries that failed to be handled so GitHub's(or a manual) redelivery isn't rejected as a duplicate
+func (wg *WebhookGuard) releaseDelivery(r *http.Request) {
+	if wg == nil || wg.seenDeliveries == nil {
+		return
+	}
+	deliveryID := github.DeliveryID(r)
+	if deliveryID == "" {
+		return
+	}
+	if wg.sharedDeliveries != nil {
+		// The request context might be done already, the event is handled after the response
+		if err := wg.sharedDeliveries.release(context.Background(), deliveryID); err != nil {
+			log.Errorf("Failed to release webhook delivery %s in Redis, its redeliveries are rejected until the claim expires: err=%v", deliveryID, err)
+		}
+	}
+	wg.deliveriesMu.Lock()
+	defer wg.deliveriesMu.Unlock()
+	wg.seenDeliveries.Remove(deliveryID)
+}
@@ -0,0 +1,195 @@
+package githubapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/go-github/v62/github"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/tenancy"
+)
+
+func TestWebhookGuardCheckSource(t *testing.T) {
+	t.Parallel()
+	guard := &WebhookGuard{
+		clientIPHeader: "X-Forwarded-For",
+		metaClient: github.NewClient(mock.NewMockedHTTPClient(
+			mock.WithRequestMatch(mock.GetMeta, github.APIMeta{Hooks: []string{"192.30.252.0/22", "2a0a:a440::/29"}}),
+		)),
+	}
+	if err := guard.refreshHookRanges(context.Background()); err != nil {
+		t.Fatalf("refreshHookRanges: %v", err)
+	}
+
+	tests := map[string]struct {
+		remoteAddr    string
+		forwardedFor  string
+		expectedError error
+	}{
+		"GitHub IPv4": {
+			remoteAddr: "192.30.252.40:41234",
+		},
+		"GitHub IPv6": {
+			remoteAddr: "[2a0a:a440::1]:41234",
+		},
+		"IPv4 mapped IPv6": {
+			remoteAddr: "[::ffff:192.30.252.40]:41234",
+		},
+		"Unknown source": {
+			remoteAddr:    "10.0.0.1:41234",
+			expectedError: ErrWebhookSourceNotAllowed,
+		},
+		"Last forwarded address is used": {
+			remoteAddr:   "10.0.0.1:41234",
+			forwardedFor: "10.1.1.1, 192.30.252.40",
+		},
+		"Spoofed first forwarded address is ignored": {
+			remoteAddr:    "10.0.0.1:41234",
+			forwardedFor:  "192.30.252.40, 10.1.1.1",
+			expectedError: ErrWebhookSourceNotAllowed,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			r, _ := http.NewRequest(http.MethodPost, "/webhook", nil) //nolint:noctx
+			r.RemoteAddr = tc.remoteAddr
+			if tc.forwardedFor != "" {
+				r.Header.Set("X-Forwarded-For", tc.forwardedFor)
+			}
+			err := guard.CheckSource(r)
+			if !errors.Is(err, tc.expectedError) {
+				t.Errorf("expected error %v, got %v", tc.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestWebhookGuardRejectsEverythingBeforeRangesAreFetched(t *testing.T) {
+	t.Parallel()
+	guard := &WebhookGuard{metaClient: github.NewClient(nil)}
+	r, _ := http.NewRequest(http.MethodPost, "/webhook", nil) //nolint:noctx
+	r.RemoteAddr = "192.30.252.40:41234"
+	assert.ErrorIs(t, guard.CheckSource(r), ErrWebhookSourceNotAllowed)
+}
+
+func TestWebhookGuardCheckDelivery(t *testing.T) {
+	t.Parallel()
+	guard := &WebhookGuard{seenDeliveries: expirable.NewLRU[string, struct{}](10, nil, time.Minute)}
+	newRequest := func(deliveryID string) *http.Request {
+		r, _ := http.NewRequest(http.MethodPost, "/webhook", nil) //nolint:noctx
+		r.Header.Set("X-GitHub-Delivery", deliveryID)
+		return r
+	}
+
+	assert.NoError(t, guard.checkDelivery(newRequest("72d3162e-cc78-11e3-81ab-4c9367dc0958")))
+	assert.ErrorIs(t, guard.checkDelivery(newRequest("72d3162e-cc78-11e3-81ab-4c9367dc0958")), ErrDuplicateWebhookDelivery)
+	assert.NoError(t, guard.checkDelivery(newRequest("0b989ba4-242f-11e5-81e1-c7b6966d2516")))
+}
+
+func TestWebhookGuardSharedDeliveryClaims(t *testing.T) {
+	t.Parallel()
+	redisServer := miniredis.RunT(t)
+	// Two replicas sharing the Redis
+	replica := func() *WebhookGuard {
+		return &WebhookGuard{
+			seenDeliveries:   expirable.NewLRU[string, struct{}](10, nil, time.Minute),
+			sharedDeliveries: newRedisDeliveryClaims(redisServer.Addr(), "", time.Minute),
+		}
+	}
+	first, second := replica(), replica()
+	newRequest := func(deliveryID string) *http.Request {
+		r, _ := http.NewRequest(http.MethodPost, "/webhook", nil) //nolint:noctx
+		r.Header.Set("X-GitHub-Delivery", deliveryID)
+		return r
+	}
+
+	assert.NoError(t, first.checkDelivery(newRequest("72d3162e-cc78-11e3-81ab-4c9367dc0958")))
+	assert.ErrorIs(t, second.checkDelivery(newRequest("72d3162e-cc78-11e3-81ab-4c9367dc0958")), ErrDuplicateWebhookDelivery)
+
+	redisServer.FastForward(2 * time.Minute)
+	assert.NoError(t, second.checkDelivery(newRequest("72d3162e-cc78-11e3-81ab-4c9367dc0958")), "claims expire with the replay window")
+
+	// A released delivery, e.g. one that failed to be handled, can be redelivered to any replica
+	assert.NoError(t, first.checkDelivery(newRequest("5d4a4fd6-cc78-11e3-81ab-4c9367dc0958")))
+	first.releaseDelivery(newRequest("5d4a4fd6-cc78-11e3-81ab-4c9367dc0958"))
+	assert.NoError(t, second.checkDelivery(newRequest("5d4a4fd6-cc78-11e3-81ab-4c9367dc0958")))
+	assert.ErrorIs(t, first.checkDelivery(newRequest("5d4a4fd6-cc78-11e3-81ab-4c9367dc0958")), ErrDuplicateWebhookDelivery)
+
+	// Without Redis each replica only knows its own deliveries
+	redisServer.Close()
+	assert.NoError(t, first.checkDelivery(newRequest("0b989ba4-242f-11e5-81e1-c7b6966d2516")))
+	assert.ErrorIs(t, first.checkDelivery(newRequest("0b989ba4-242f-11e5-81e1-c7b6966d2516")), ErrDuplicateWebhookDelivery)
+	first.releaseDelivery(newRequest("0b989ba4-242f-11e5-81e1-c7b6966d2516"))
+	assert.NoError(t, first.checkDelivery(newRequest("0b989ba4-242f-11e5-81e1-c7b6966d2516")))
+}
+
+func TestNilWebhookGuardAllowsEverything(t *testing.T) {
+	t.Parallel()
+	var guard *WebhookGuard
+	r, _ := http.NewRequest(http.MethodPost, "/webhook", nil) //nolint:noctx
+	r.RemoteAddr = "10.0.0.1:41234"
+	r.Header.Set("X-GitHub-Delivery", "72d3162e-cc78-11e3-81ab-4c9367dc0958")
+	assert.NoError(t, guard.CheckSource(r))
+	assert.NoError(t, guard.checkDelivery(r))
+	assert.NoError(t, guard.checkDelivery(r))
+}
+
+// Not parallel, the tenancy configuration is global
+func TestReceiveTenantWebhook(t *testing.T) {
+	config, err := tenancy.ParseConfig([]byte(`
+tenants:
+  - name: sandbox
+    match: [sandbox-org]
+    webhookPath: sandbox
+    env:
+      GITHUB_WEBHOOK_SECRET: sandbox-secret
+  - name: prod
+    match: [prod-org]
+    env:
+      GITHUB_WEBHOOK_SECRET: prod-secret
+`))
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	tenancy.SetConfig(config)
+	defer tenancy.SetConfig(nil)
+	sandbox := tenancy.WebhookEndpoints()[0]
+
+	newRequest := func(repoSlug string, secret string) *http.Request {
+		payload := []byte(`{"ref": "refs/heads/main", "repository": {"full_name": "` + repoSlug + `"}}`)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(payload)
+		r := httptest.NewRequest(http.MethodPost, "/webhook/github/sandbox", bytes.NewReader(payload))
+		r.Header.Set("X-GitHub-Event", "push")
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set(github.SHA256SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		return r
+	}
+
+	// Only the secret of the endpoint tenant is accepted, even for the repos of another tenant
+	assert.Error(t, ReceiveTenantWebhook(newRequest("prod-org/gitops", "prod-secret"), sandbox, nil, nil, nil))
+	assert.ErrorIs(t, ReceiveTenantWebhook(newRequest("prod-org/gitops", "sandbox-secret"), sandbox, nil, nil, nil), ErrWebhookTenantMismatch)
+	assert.ErrorIs(t, ReceiveTenantWebhook(newRequest("unknown-org/gitops", "sandbox-secret"), sandbox, nil, nil, nil), ErrWebhookTenantMismatch)
+}
+
+func TestReciveWebhookWithoutSecret(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{"action": "opened"}`))
+	r.Header.Set("X-GitHub-Event", "pull_request")
+	r.Header.Set("Content-Type", "application/json")
+	err := ReciveWebhook(r, nil, nil, nil, nil)
+	assert.ErrorIs(t, err, ErrNoWebhookSecret)
+}
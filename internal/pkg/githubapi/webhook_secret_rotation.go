@@ -0,0 +1,41 @@
+package githubapi
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/secrets"
+)
+
+// webhookSecretCandidates returns the secrets webhooks of the global GitHub App can be signed with, configured is the GITHUB_WEBHOOK_SECRET value.
+// GITHUB_WEBHOOK_SECRET_PREVIOUS is accepted too while it's set, so the secret can be rotated in its secret store(shared by all the replicas)
+// before the GitHub App webhook configuration without rejecting the deliveries signed in between
+func webhookSecretCandidates(configured []byte) [][]byte {
+	candidates := [][]byte{configured}
+	if previous := secrets.Get("GITHUB_WEBHOOK_SECRET_PREVIOUS", ""); previous != "" && !bytes.Equal([]byte(previous), configured) {
+		candidates = append(candidates, []byte(previous))
+	}
+	return candidates
+}
+
+// validateWebhookPayload is github.ValidatePayload for multiple secrets, the payload is valid if it's signed with one of them
+func validateWebhookPayload(r *http.Request, secrets [][]byte) ([]byte, error) {
+	if len(secrets) == 1 {
+		return github.ValidatePayload(r, secrets[0])
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	for _, secret := range secrets {
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		var payload []byte
+		payload, err = github.ValidatePayload(r, secret)
+		if err == nil {
+			return payload, nil
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,46 @@
+package githubapi
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/google/go-github/v62/github"
+	"github.com/stretchr/testify/assert"
+)
+
+// Not parallel, it sets env vars
+func TestWebhookSecretCandidates(t *testing.T) {
+	t.Setenv("GITHUB_WEBHOOK_SECRET_PREVIOUS", "")
+	os.Unsetenv("GITHUB_WEBHOOK_SECRET_PREVIOUS")
+	assert.Equal(t, [][]byte{[]byte("configured")}, webhookSecretCandidates([]byte("configured")))
+
+	t.Setenv("GITHUB_WEBHOOK_SECRET_PREVIOUS", "previous")
+	assert.Equal(t, [][]byte{[]byte("configured"), []byte("previous")}, webhookSecretCandidates([]byte("configured")))
+	assert.Equal(t, [][]byte{[]byte("previous")}, webhookSecretCandidates([]byte("previous")), "an unchanged secret is checked once")
+}
+
+func TestValidateWebhookPayload(t *testing.T) {
+	t.Parallel()
+	payload := []byte(`{"zen": "Keep it logically awesome."}`)
+	mac := hmac.New(sha256.New, []byte("previous"))
+	mac.Write(payload)
+	newRequest := func() *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(payload))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set(github.SHA256SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		return r
+	}
+
+	validated, err := validateWebhookPayload(newRequest(), [][]byte{[]byte("current"), []byte("previous")})
+	assert.NoError(t, err)
+	assert.Equal(t, payload, validated)
+
+	_, err = validateWebhookPayload(newRequest(), [][]byte{[]byte("current")})
+	assert.Error(t, err)
+}
@@ -0,0 +1,49 @@
+package helm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/tenancy"
+)
+
+// templateTimeout bounds a single helm template run, charts with unreachable dependencies can hang otherwise
+const templateTimeout = 2 * time.Minute
+
+// TemplateOptions are passed to helm template, ValuesFiles are relative to the chart directory
+type TemplateOptions struct {
+	ReleaseName string
+	Namespace   string
+	ValuesFiles []string
+}
+
+// templateArgs builds the helm CLI arguments, the chart's own values.yaml is always used by helm so it isn't passed explicitly
+func templateArgs(chartDir string, opts TemplateOptions) []string {
+	args := []string{"template", opts.ReleaseName, chartDir}
+	if opts.Namespace != "" {
+		args = append(args, "--namespace", opts.Namespace)
+	}
+	for _, valuesFile := range opts.ValuesFiles {
+		args = append(args, "--values", chartDir+"/"+valuesFile)
+	}
+	return args
+}
+
+// Template renders the chart in chartDir with the helm binary(HELM_BINARY_PATH, defaults to helm from PATH)
+func Template(ctx context.Context, chartDir string, opts TemplateOptions) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, templateTimeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, tenancy.Getenv(ctx, "HELM_BINARY_PATH", "helm"), templateArgs(chartDir, opts)...) //nolint:gosec // the binary is set by the Telefonistka operator
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		return "", fmt.Errorf("helm template %s: %w: %s", opts.ReleaseName, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,31 @@
+package helm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplateArgs(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		opts     TemplateOptions
+		expected []string
+	}{
+		"release only": {
+			opts:     TemplateOptions{ReleaseName: "app"},
+			expected: []string{"template", "app", "/tmp/chart"},
+		},
+		"namespace and values files": {
+			opts:     TemplateOptions{ReleaseName: "app", Namespace: "apps", ValuesFiles: []string{"values-prod.yaml", "secrets.yaml"}},
+			expected: []string{"template", "app", "/tmp/chart", "--namespace", "apps", "--values", "/tmp/chart/values-prod.yaml", "--values", "/tmp/chart/secrets.yaml"},
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, templateArgs("/tmp/chart", tc.opts))
+		})
+	}
+}
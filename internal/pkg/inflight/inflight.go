@@ -0,0 +1,51 @@
+// Package inflight keeps track of the webhook event handlers that are currently running, event handling is fire-and-forget so this is the only way to see stuck handlers
+package inflight
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+)
+
+// Handler describes a running event handler
+type Handler struct {
+	ID         uint64    `json:"id"`
+	Provider   string    `json:"provider"`
+	EventType  string    `json:"eventType"`
+	Repo       string    `json:"repo"`
+	StartedAt  time.Time `json:"startedAt"`
+	AgeSeconds float64   `json:"ageSeconds"`
+}
+
+var (
+	handlers sync.Map // ID -> Handler
+	lastID   atomic.Uint64
+)
+
+// Track registers a running event handler(and its processing metrics), the returned function must be called once handling is done
+func Track(provider string, eventType string, repoSlug string) func() {
+	id := lastID.Add(1)
+	handlers.Store(id, Handler{ID: id, Provider: provider, EventType: eventType, Repo: repoSlug, StartedAt: time.Now()})
+	doneMetrics := prom.TrackEventProcessing(provider, eventType, repoSlug)
+	return func() {
+		handlers.Delete(id)
+		doneMetrics()
+	}
+}
+
+// Snapshot returns the running event handlers, oldest first
+func Snapshot() []Handler {
+	now := time.Now()
+	snapshot := []Handler{}
+	handlers.Range(func(_, v any) bool {
+		h := v.(Handler)
+		h.AgeSeconds = now.Sub(h.StartedAt).Seconds()
+		snapshot = append(snapshot, h)
+		return true
+	})
+	sort.Slice(snapshot, func(i, j int) bool { return snapshot[i].ID < snapshot[j].ID })
+	return snapshot
+}
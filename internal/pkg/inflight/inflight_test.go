@@ -0,0 +1,28 @@
+package inflight
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrack(t *testing.T) {
+	t.Parallel()
+	done := Track("github", "pull_request", "AnOwner/Arepo")
+
+	var found *Handler
+	for _, h := range Snapshot() {
+		if h.Repo == "AnOwner/Arepo" {
+			found = &h
+		}
+	}
+	if assert.NotNil(t, found) {
+		assert.Equal(t, "pull_request", found.EventType)
+		assert.GreaterOrEqual(t, found.AgeSeconds, 0.0)
+	}
+
+	done()
+	for _, h := range Snapshot() {
+		assert.NotEqual(t, "AnOwner/Arepo", h.Repo)
+	}
+}
@@ -0,0 +1,56 @@
+package inflight
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/tenancy"
+)
+
+const defaultEventTimeout = 5 * time.Minute
+
+// timeoutEnvVar returns the event type specific timeout env var, e.g. EVENT_TIMEOUT_SECONDS_PULL_REQUEST
+func timeoutEnvVar(eventType string) string {
+	return "EVENT_TIMEOUT_SECONDS_" + strings.ToUpper(strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, eventType))
+}
+
+// EventTimeout returns how long an event can be handled: EVENT_TIMEOUT_SECONDS_<EVENT TYPE>, then EVENT_TIMEOUT_SECONDS(default 300),
+// both can be overridden per tenant(see the tenancy package) so ctx should carry the event repo tenant
+func EventTimeout(ctx context.Context, eventType string) time.Duration {
+	for _, envVar := range []string{timeoutEnvVar(eventType), "EVENT_TIMEOUT_SECONDS"} {
+		value := tenancy.Getenv(ctx, envVar, "")
+		if value == "" {
+			continue
+		}
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds <= 0 {
+			log.Warnf("Ignoring invalid %s value %q", envVar, value)
+			continue
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultEventTimeout
+}
+
+// WithEventTimeout returns a copy of ctx with the event deadline, its cancel function counts events that hit the deadline
+func WithEventTimeout(ctx context.Context, provider string, eventType string, repoSlug string) (context.Context, context.CancelFunc) {
+	timeout := EventTimeout(ctx, eventType)
+	eventCtx, cancel := context.WithTimeout(ctx, timeout)
+	return eventCtx, func() {
+		if errors.Is(eventCtx.Err(), context.DeadlineExceeded) {
+			log.Errorf("Handling of %s %s event of %s hit its %v deadline", provider, eventType, repoSlug, timeout)
+			prom.InstrumentEventTimeout(provider, eventType, repoSlug)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,23 @@
+package inflight
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeoutEnvVar(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "EVENT_TIMEOUT_SECONDS_PULL_REQUEST", timeoutEnvVar("pull_request"))
+	assert.Equal(t, "EVENT_TIMEOUT_SECONDS_MERGE_REQUEST_HOOK", timeoutEnvVar("Merge Request Hook"))
+}
+
+func TestEventTimeout(t *testing.T) {
+	t.Setenv("EVENT_TIMEOUT_SECONDS", "600")
+	t.Setenv("EVENT_TIMEOUT_SECONDS_PULL_REQUEST", "1200")
+	t.Setenv("EVENT_TIMEOUT_SECONDS_PUSH", "invalid")
+	assert.Equal(t, 20*time.Minute, EventTimeout(context.Background(), "pull_request"))
+	assert.Equal(t, 10*time.Minute, EventTimeout(context.Background(), "push"))
+}
@@ -0,0 +1,45 @@
+package kustomize
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/tenancy"
+)
+
+// buildTimeout bounds a single kustomize build run, remote bases can hang otherwise
+const buildTimeout = 2 * time.Minute
+
+// kustomizationFileNames are the file names kustomize looks for in a directory
+var kustomizationFileNames = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+// HasKustomization reports whether dir is a kustomization root
+func HasKustomization(dir string) bool {
+	for _, fileName := range kustomizationFileNames {
+		if _, err := os.Stat(filepath.Join(dir, fileName)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Build renders the kustomization in dir with the kustomize binary(KUSTOMIZE_BINARY_PATH, defaults to kustomize from PATH)
+func Build(ctx context.Context, dir string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, buildTimeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, tenancy.Getenv(ctx, "KUSTOMIZE_BINARY_PATH", "kustomize"), "build", dir) //nolint:gosec // the binary is set by the Telefonistka operator
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		return "", fmt.Errorf("kustomize build %s: %w: %s", dir, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,17 @@
+package kustomize
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasKustomization(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	assert.False(t, HasKustomization(dir))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "kustomization.yml"), []byte("resources: []\n"), 0o600))
+	assert.True(t, HasKustomization(dir))
+}
@@ -0,0 +1,124 @@
+// Package leader elects the replica that runs the periodic jobs(janitor, trains, drift scans...) with a Kubernetes Lease, so running more than one replica doesn't run them twice
+package leader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const serviceAccountNamespacePath = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// Every replica is the leader until leader election is enabled
+var isLeader atomic.Bool
+
+func init() { //nolint:gochecknoinits
+	setLeader(true)
+}
+
+func setLeader(leader bool) {
+	isLeader.Store(leader)
+	prom.SetLeader(leader)
+}
+
+// IsLeader returns true when this replica should run the periodic jobs
+func IsLeader() bool {
+	return isLeader.Load()
+}
+
+// Skip returns true(and logs the job) when another replica is the leader, periodic jobs skip their run then
+func Skip(job string) bool {
+	if IsLeader() {
+		return false
+	}
+	log.Debugf("Not the leader, skipping %s", job)
+	return true
+}
+
+func namespace() (string, error) {
+	if ns := os.Getenv("POD_NAMESPACE"); ns != "" {
+		return ns, nil
+	}
+	content, err := os.ReadFile(serviceAccountNamespacePath)
+	if err != nil {
+		return "", fmt.Errorf("POD_NAMESPACE isn't set and the service account namespace can't be read: %w", err)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// RunFromEnv starts the leader election when LEADER_ELECTION_ENABLED is true, this replica isn't the leader until it acquires the LEADER_ELECTION_LEASE_NAME Lease
+func RunFromEnv(ctx context.Context) error {
+	if enabled, _ := strconv.ParseBool(os.Getenv("LEADER_ELECTION_ENABLED")); !enabled {
+		return nil
+	}
+	ns, err := namespace()
+	if err != nil {
+		return err
+	}
+	identity := os.Getenv("POD_NAME")
+	if identity == "" {
+		if identity, err = os.Hostname(); err != nil {
+			return fmt.Errorf("failed to get the leader election identity: %w", err)
+		}
+	}
+	leaseName := os.Getenv("LEADER_ELECTION_LEASE_NAME")
+	if leaseName == "" {
+		leaseName = "telefonistka"
+	}
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return fmt.Errorf("leader election needs to run in Kubernetes: %w", err)
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("failed to create the Kubernetes client: %w", err)
+	}
+	setLeader(false)
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Name: leaseName, Namespace: ns},
+		Client:     client.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+	}
+	go run(ctx, lock, identity)
+	return nil
+}
+
+// run keeps running the election, RunOrDie returns when the leadership is lost(e.g. the Lease couldn't be renewed) and this replica should run for it again
+func run(ctx context.Context, lock resourcelock.Interface, identity string) {
+	for ctx.Err() == nil {
+		leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+			Lock:            lock,
+			ReleaseOnCancel: true,
+			LeaseDuration:   15 * time.Second,
+			RenewDeadline:   10 * time.Second,
+			RetryPeriod:     2 * time.Second,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: func(context.Context) {
+					log.Infof("%s is now the leader, running the periodic jobs", identity)
+					setLeader(true)
+				},
+				OnStoppedLeading: func() {
+					log.Infof("%s stopped leading", identity)
+					setLeader(false)
+				},
+				OnNewLeader: func(leader string) {
+					if leader != identity {
+						log.Infof("%s is the leader", leader)
+					}
+				},
+			},
+		})
+	}
+}
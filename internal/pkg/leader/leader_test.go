@@ -0,0 +1,23 @@
+package leader
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The leadership is global, so these steps share a single test
+func TestSkip(t *testing.T) {
+	assert.True(t, IsLeader(), "replicas are leaders until leader election is enabled")
+	assert.False(t, Skip("drift_scan"))
+
+	setLeader(false)
+	defer setLeader(true)
+	assert.True(t, Skip("drift_scan"))
+}
+
+func TestRunFromEnvDisabled(t *testing.T) {
+	t.Setenv("LEADER_ELECTION_ENABLED", "false")
+	assert.NoError(t, RunFromEnv(t.Context()))
+	assert.True(t, IsLeader())
+}
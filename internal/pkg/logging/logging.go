@@ -0,0 +1,150 @@
+// Package logging configures the logrus logger: level, format(text or JSON with stable field names) and the repos logged at debug level regardless of the level
+package logging
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// JSON logs use these field names no matter how the code names them, log pipelines can rely on them
+var stableFieldNames = map[string]string{
+	"prNumber":   "pr",
+	"event_type": "event",
+}
+
+// Config is set by the --log-level/--log-format flags, the LOG_LEVEL/LOG_FORMAT/LOG_DEBUG_REPOS env vars or the logging section of the server configuration
+type Config struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+	// owner/repo slugs(case insensitive) logged at debug level
+	DebugRepos []string `yaml:"debugRepos"`
+}
+
+var (
+	mu      sync.RWMutex
+	current Config
+	// Set by Configure, the server configuration defaults are applied on top of it(again on every reload)
+	base Config
+	// Shares the output and formatter of the standard logger, at debug level
+	debugLogger *log.Logger
+	debugRepos  map[string]bool
+)
+
+// Validate checks the level and format, empty ones mean the default(info, text)
+func (c Config) Validate() error {
+	if c.Level != "" {
+		if _, err := log.ParseLevel(c.Level); err != nil {
+			return fmt.Errorf("invalid log level %q: %w", c.Level, err)
+		}
+	}
+	if c.Format != "" && c.Format != FormatText && c.Format != FormatJSON {
+		return fmt.Errorf("invalid log format %q, should be %s or %s", c.Format, FormatText, FormatJSON)
+	}
+	return nil
+}
+
+// stableFieldsFormatter renames the fields of the entries to their stable names before formatting them
+type stableFieldsFormatter struct {
+	log.Formatter
+}
+
+func (f stableFieldsFormatter) Format(entry *log.Entry) ([]byte, error) {
+	renamed := *entry
+	renamed.Data = make(log.Fields, len(entry.Data))
+	for key, value := range entry.Data {
+		if stable, ok := stableFieldNames[key]; ok {
+			key = stable
+		}
+		renamed.Data[key] = value
+	}
+	return f.Formatter.Format(&renamed)
+}
+
+func formatter(format string) log.Formatter {
+	if format == FormatJSON {
+		return stableFieldsFormatter{&log.JSONFormatter{
+			FieldMap: log.FieldMap{log.FieldKeyTime: "time", log.FieldKeyLevel: "level", log.FieldKeyMsg: "msg", log.FieldKeyFunc: "func", log.FieldKeyFile: "file"},
+		}}
+	}
+	return &log.TextFormatter{
+		DisableColors: false,
+		// ForceColors: true,
+		FullTimestamp: true,
+	} // TimestampFormat
+}
+
+// Configure applies c to the standard logger
+func Configure(c Config) error {
+	if err := apply(c); err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	base = c
+	return nil
+}
+
+func apply(c Config) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	level := log.InfoLevel
+	if c.Level != "" {
+		level, _ = log.ParseLevel(c.Level)
+	}
+	log.SetLevel(level)
+	log.SetReportCaller(level >= log.DebugLevel)
+	log.SetFormatter(formatter(c.Format))
+
+	repos := map[string]bool{}
+	for _, repo := range c.DebugRepos {
+		repos[strings.ToLower(repo)] = true
+	}
+	std := log.StandardLogger()
+	logger := log.New()
+	logger.SetOutput(std.Out)
+	logger.SetFormatter(std.Formatter)
+	logger.SetLevel(log.DebugLevel)
+	logger.SetReportCaller(true)
+
+	mu.Lock()
+	defer mu.Unlock()
+	current = c
+	debugLogger = logger
+	debugRepos = repos
+	return nil
+}
+
+// ApplyDefaults fills the settings the flags/env vars didn't set with c, e.g. from the server configuration.
+// The defaults of a previous call are replaced, not merged
+func ApplyDefaults(c Config) error {
+	mu.RLock()
+	merged := base
+	mu.RUnlock()
+	if merged.Level == "" {
+		merged.Level = c.Level
+	}
+	if merged.Format == "" {
+		merged.Format = c.Format
+	}
+	merged.DebugRepos = append(append([]string{}, merged.DebugRepos...), c.DebugRepos...)
+	return apply(merged)
+}
+
+// ForRepo returns a logger of an owner/repo slug, repos listed in debugRepos get a debug level logger
+func ForRepo(repoSlug string) *log.Entry {
+	mu.RLock()
+	defer mu.RUnlock()
+	if debugRepos[strings.ToLower(repoSlug)] && debugLogger != nil {
+		return debugLogger.WithField("repo", repoSlug)
+	}
+	return log.WithField("repo", repoSlug)
+}
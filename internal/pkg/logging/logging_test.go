@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// The standard logger is global, so these tests don't run in parallel
+func TestConfigureJSONFormat(t *testing.T) {
+	defer func() { _ = Configure(Config{}) }()
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(os.Stderr)
+
+	assert.NoError(t, Configure(Config{Format: FormatJSON}))
+	ForRepo("AnOwner/Arepo").WithFields(log.Fields{"prNumber": 42, "event_type": "pr", "delivery_id": "72d3162e"}).Info("Handling PR")
+
+	entry := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.Equal(t, "AnOwner/Arepo", entry["repo"])
+	assert.Equal(t, float64(42), entry["pr"])
+	assert.Equal(t, "pr", entry["event"])
+	assert.Equal(t, "72d3162e", entry["delivery_id"])
+	assert.Equal(t, "Handling PR", entry["msg"])
+	assert.Equal(t, "info", entry["level"])
+	assert.NotContains(t, entry, "prNumber")
+}
+
+func TestForRepoDebugOverride(t *testing.T) {
+	defer func() { _ = Configure(Config{}) }()
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(os.Stderr)
+
+	assert.NoError(t, Configure(Config{Level: "info", DebugRepos: []string{"anowner/debugged"}}))
+	ForRepo("AnOwner/Arepo").Debug("not logged")
+	assert.Empty(t, out.String())
+	ForRepo("AnOwner/Debugged").Debug("logged")
+	assert.Contains(t, out.String(), "logged")
+}
+
+func TestApplyDefaults(t *testing.T) {
+	defer func() { _ = Configure(Config{}) }()
+	assert.NoError(t, Configure(Config{Level: "warn"}))
+	// The flags/env vars take precedence over the server configuration
+	assert.NoError(t, ApplyDefaults(Config{Level: "debug", Format: FormatJSON}))
+	assert.Equal(t, log.WarnLevel, log.GetLevel())
+	assert.IsType(t, stableFieldsFormatter{}, log.StandardLogger().Formatter)
+	// A reloaded server configuration replaces the previous defaults
+	assert.NoError(t, ApplyDefaults(Config{Format: FormatText}))
+	assert.IsType(t, &log.TextFormatter{}, log.StandardLogger().Formatter)
+}
+
+func TestConfigValidate(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		config Config
+		valid  bool
+	}{
+		"Defaults":       {config: Config{}, valid: true},
+		"Debug JSON":     {config: Config{Level: "debug", Format: FormatJSON}, valid: true},
+		"Unknown level":  {config: Config{Level: "verbose"}},
+		"Unknown format": {config: Config{Format: "logfmt"}},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.valid, tc.config.Validate() == nil)
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package maintenance
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+)
+
+// ErrEnabled is returned by the writes skipped during maintenance
+var ErrEnabled = errors.New("skipped, Telefonistka is in maintenance mode")
+
+// State is the maintenance mode of the server, while enabled Telefonistka is read-only: no PRs are opened or merged and ArgoCD apps aren't changed, only comments are written
+type State struct {
+	Enabled bool      `json:"enabled"`
+	Reason  string    `json:"reason,omitempty"`
+	Since   time.Time `json:"since,omitempty"`
+	// Set when only this repo(owner/repo) is paused
+	Repo string `json:"repo,omitempty"`
+}
+
+var (
+	mu          sync.RWMutex
+	state       State
+	pausedRepos = map[string]State{}
+)
+
+// LoadFromEnv enables maintenance mode when MAINTENANCE_MODE is true, so the server can start read-only(e.g. during an upgrade)
+func LoadFromEnv() error {
+	value, ok := os.LookupEnv("MAINTENANCE_MODE")
+	if !ok || value == "" {
+		return nil
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("MAINTENANCE_MODE should be a boolean: %w", err)
+	}
+	// Disabled is the default, with a shared state a restarting replica mustn't end the maintenance of the others
+	if !enabled {
+		return nil
+	}
+	_, err = Set(true, os.Getenv("MAINTENANCE_MODE_REASON"))
+	return err
+}
+
+// Set enables or disables maintenance mode, Since is kept when an enabled maintenance only gets a new reason
+func Set(enabled bool, reason string) (State, error) {
+	refreshShared()
+	mu.Lock()
+	defer mu.Unlock()
+	next := State{}
+	switch {
+	case !enabled:
+	case state.Enabled:
+		next = state
+		next.Reason = reason
+	default:
+		next = State{Enabled: true, Reason: reason, Since: time.Now()}
+	}
+	if err := storeShared(serverField, next); err != nil {
+		return state, err
+	}
+	state = next
+	prom.SetMaintenanceMode(state.Enabled)
+	log.Infof("Maintenance mode enabled=%v reason=%q", state.Enabled, state.Reason)
+	return state, nil
+}
+
+// Get returns the current maintenance mode
+func Get() State {
+	refreshShared()
+	mu.RLock()
+	defer mu.RUnlock()
+	return state
+}
+
+// Enabled returns true while Telefonistka should be read-only
+func Enabled() bool {
+	return Get().Enabled
+}
+
+// PauseRepo stops the handling of the events of a repo(owner/repo) and its periodic jobs, like maintenance mode does for all repos
+func PauseRepo(repoSlug string, reason string) (State, error) {
+	refreshShared()
+	mu.Lock()
+	defer mu.Unlock()
+	key := strings.ToLower(repoSlug)
+	paused, ok := pausedRepos[key]
+	if !ok {
+		paused = State{Enabled: true, Since: time.Now(), Repo: repoSlug}
+	}
+	paused.Reason = reason
+	if err := storeShared(repoFieldPrefix+key, paused); err != nil {
+		return State{}, err
+	}
+	pausedRepos[key] = paused
+	log.Infof("Paused repo %s reason=%q", repoSlug, reason)
+	return paused, nil
+}
+
+// ResumeRepo undoes PauseRepo, it returns false when the repo wasn't paused
+func ResumeRepo(repoSlug string) (bool, error) {
+	refreshShared()
+	mu.Lock()
+	defer mu.Unlock()
+	key := strings.ToLower(repoSlug)
+	if _, ok := pausedRepos[key]; !ok {
+		return false, nil
+	}
+	if err := storeShared(repoFieldPrefix+key, State{}); err != nil {
+		return false, err
+	}
+	delete(pausedRepos, key)
+	log.Infof("Resumed repo %s", repoSlug)
+	return true, nil
+}
+
+// PausedRepos returns the paused repos, oldest pause first
+func PausedRepos() []State {
+	refreshShared()
+	mu.RLock()
+	defer mu.RUnlock()
+	paused := []State{}
+	for _, s := range pausedRepos {
+		paused = append(paused, s)
+	}
+	sort.Slice(paused, func(i, j int) bool { return paused[i].Since.Before(paused[j].Since) })
+	return paused
+}
+
+// ForRepo returns the maintenance mode that applies to a repo, the server wide one first
+func ForRepo(repoSlug string) State {
+	refreshShared()
+	mu.RLock()
+	defer mu.RUnlock()
+	if state.Enabled {
+		return state
+	}
+	return pausedRepos[strings.ToLower(repoSlug)]
+}
+
+// SkipRepo is Skip for the actions of a single repo, it also skips the actions of paused repos
+func SkipRepo(repoSlug string, action string) bool {
+	if !ForRepo(repoSlug).Enabled {
+		return false
+	}
+	log.Infof("Maintenance mode is enabled for %s, skipping %s", repoSlug, action)
+	prom.InstrumentMaintenanceModeSkip(action)
+	return true
+}
+
+// Skip returns true(and logs the action) while maintenance mode is enabled, callers skip the action
+func Skip(action string) bool {
+	if !Enabled() {
+		return false
+	}
+	log.Infof("Maintenance mode is enabled, skipping %s", action)
+	prom.InstrumentMaintenanceModeSkip(action)
+	return true
+}
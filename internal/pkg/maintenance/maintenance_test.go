@@ -0,0 +1,90 @@
+package maintenance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+// The state is global, so these steps share a single test
+func TestSet(t *testing.T) {
+	assert.False(t, Skip("merge_pr"))
+
+	enabled, err := Set(true, "ArgoCD upgrade")
+	assert.NoError(t, err)
+	assert.True(t, enabled.Enabled)
+	assert.False(t, enabled.Since.IsZero())
+	assert.True(t, Skip("merge_pr"))
+
+	// A new reason doesn't restart the maintenance
+	updated, _ := Set(true, "ArgoCD upgrade, extended")
+	assert.Equal(t, enabled.Since, updated.Since)
+	assert.Equal(t, "ArgoCD upgrade, extended", Get().Reason)
+
+	disabled, _ := Set(false, "")
+	assert.Equal(t, State{}, disabled)
+	assert.False(t, Enabled())
+}
+
+func TestPauseRepo(t *testing.T) {
+	assert.False(t, SkipRepo("Owner/Paused", "drift_scan"))
+
+	paused, err := PauseRepo("Owner/Paused", "migration")
+	assert.NoError(t, err)
+	assert.Equal(t, "Owner/Paused", paused.Repo)
+	assert.True(t, SkipRepo("owner/paused", "drift_scan"), "repo slugs are case insensitive")
+	assert.False(t, SkipRepo("owner/other", "drift_scan"))
+	assert.Contains(t, PausedRepos(), paused)
+
+	resumed, _ := ResumeRepo("owner/paused")
+	assert.True(t, resumed)
+	resumed, _ = ResumeRepo("owner/paused")
+	assert.False(t, resumed)
+	assert.False(t, ForRepo("Owner/Paused").Enabled)
+}
+
+func TestSharedState(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	share(redis.NewClient(&redis.Options{Addr: redisServer.Addr()}))
+	t.Cleanup(func() { share(nil) })
+	assert.True(t, Shared())
+
+	_, err := Set(true, "ArgoCD upgrade")
+	assert.NoError(t, err)
+	_, err = PauseRepo("Owner/Paused", "migration")
+	assert.NoError(t, err)
+	fields, _ := redisServer.HKeys(sharedStateKey)
+	assert.Equal(t, []string{repoFieldPrefix + "owner/paused", serverField}, fields)
+
+	// Another replica ends the maintenance and pauses a repo
+	redisServer.HDel(sharedStateKey, serverField)
+	redisServer.HSet(sharedStateKey, repoFieldPrefix+"owner/other", `{"enabled":true,"reason":"incident","repo":"Owner/Other"}`)
+	assert.True(t, Enabled(), "the shared state is only read again after sharedStateRefresh")
+	lastRefresh = time.Time{}
+	assert.False(t, Enabled())
+	assert.True(t, ForRepo("owner/other").Enabled)
+	assert.Len(t, PausedRepos(), 2)
+
+	// Resuming deletes the field, so every replica resumes the repo
+	resumed, err := ResumeRepo("owner/other")
+	assert.NoError(t, err)
+	assert.True(t, resumed)
+	fields, _ = redisServer.HKeys(sharedStateKey)
+	assert.Equal(t, []string{repoFieldPrefix + "owner/paused"}, fields)
+
+	// Writes fail instead of only changing the state of this replica when Redis is unavailable
+	redisServer.Close()
+	lastRefresh = time.Time{}
+	_, err = Set(true, "ArgoCD upgrade")
+	assert.Error(t, err)
+	assert.False(t, Enabled())
+	assert.True(t, ForRepo("owner/paused").Enabled, "the last known state is kept")
+
+	// Clean up the global state for the other tests
+	mu.Lock()
+	pausedRepos = map[string]State{}
+	mu.Unlock()
+}
@@ -0,0 +1,123 @@
+package maintenance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/secrets"
+)
+
+// With CACHE_REDIS_ADDR the maintenance mode and the paused repos are kept in this Redis hash, so a change made through any replica applies to all of them.
+// The server wide state is the "server" field, each paused repo is a "repo:<owner/repo>" field
+const (
+	sharedStateKey  = "telefonistka:maintenance"
+	serverField     = "server"
+	repoFieldPrefix = "repo:"
+	// How long the replicas use the last read shared state before reading it again
+	sharedStateRefresh = 5 * time.Second
+	sharedStateTimeout = 2 * time.Second
+)
+
+var (
+	shared      *redis.Client
+	lastRefresh time.Time
+)
+
+// ShareFromEnv keeps the state in the CACHE_REDIS_ADDR Redis, it has to run before LoadFromEnv so the MAINTENANCE_MODE of the env applies to all the replicas
+func ShareFromEnv() error {
+	addr := os.Getenv("CACHE_REDIS_ADDR")
+	if addr == "" {
+		return nil
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr, Password: secrets.Get("CACHE_REDIS_PASSWORD", "")})
+	ctx, cancel := context.WithTimeout(context.Background(), sharedStateTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("failed to connect to the maintenance state Redis %s: %w", addr, err)
+	}
+	share(client)
+	return nil
+}
+
+func share(client *redis.Client) {
+	mu.Lock()
+	shared = client
+	lastRefresh = time.Time{}
+	mu.Unlock()
+	refreshShared()
+}
+
+// Shared returns true when the state is shared by the replicas
+func Shared() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return shared != nil
+}
+
+// refreshShared reads the shared state once sharedStateRefresh passed since the last read, when Redis is unavailable the last known state is kept
+func refreshShared() {
+	mu.RLock()
+	fresh := shared == nil || time.Since(lastRefresh) < sharedStateRefresh
+	mu.RUnlock()
+	if fresh {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if time.Since(lastRefresh) < sharedStateRefresh {
+		return
+	}
+	lastRefresh = time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), sharedStateTimeout)
+	defer cancel()
+	fields, err := shared.HGetAll(ctx, sharedStateKey).Result()
+	if err != nil {
+		log.Errorf("Failed to read the shared maintenance state, keeping the last known one: %v", err)
+		return
+	}
+	state = State{}
+	pausedRepos = map[string]State{}
+	for field, value := range fields {
+		var s State
+		if err := json.Unmarshal([]byte(value), &s); err != nil {
+			log.Errorf("Ignoring the invalid shared maintenance state %s: %v", field, err)
+			continue
+		}
+		if field == serverField {
+			state = s
+		} else if key, ok := strings.CutPrefix(field, repoFieldPrefix); ok {
+			pausedRepos[key] = s
+		}
+	}
+	prom.SetMaintenanceMode(state.Enabled)
+}
+
+// storeShared writes a field of the shared state, a disabled state deletes it. The caller holds mu
+func storeShared(field string, s State) error {
+	if shared == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sharedStateTimeout)
+	defer cancel()
+	if !s.Enabled {
+		if err := shared.HDel(ctx, sharedStateKey, field).Err(); err != nil {
+			return fmt.Errorf("failed to store the shared maintenance state: %w", err)
+		}
+		return nil
+	}
+	value, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := shared.HSet(ctx, sharedStateKey, field, value).Err(); err != nil {
+		return fmt.Errorf("failed to store the shared maintenance state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package notifications
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+const (
+	cloudEventsFormat      = "cloudevents"
+	cloudEventsContentType = "application/cloudevents+json"
+	cloudEventsTypePrefix  = "com.github.wayfair-incubator.telefonistka."
+)
+
+// cloudEvent is the CloudEvents 1.0 JSON(structured mode) envelope of an event, consumed as is by Knative and Argo Events
+type cloudEvent struct {
+	SpecVersion     string `json:"specversion"`
+	ID              string `json:"id"`
+	Source          string `json:"source"`
+	Type            string `json:"type"`
+	Subject         string `json:"subject,omitempty"`
+	Time            string `json:"time"`
+	DataContentType string `json:"datacontenttype"`
+	Data            Event  `json:"data"`
+}
+
+func newCloudEvent(event Event, source string, now time.Time) (cloudEvent, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return cloudEvent{}, err
+	}
+	return cloudEvent{
+		SpecVersion:     "1.0",
+		ID:              hex.EncodeToString(id),
+		Source:          source,
+		Type:            cloudEventsTypePrefix + event.Type,
+		Subject:         event.Repo,
+		Time:            now.UTC().Format(time.RFC3339Nano),
+		DataContentType: "application/json",
+		Data:            event,
+	}, nil
+}
+
+// eventFormat renders events as plain JSON or as CloudEvents, source is the CloudEvents source attribute
+type eventFormat struct {
+	cloudEvents bool
+	source      string
+}
+
+// marshal returns the event JSON and its content type
+func (f eventFormat) marshal(event Event) ([]byte, string, error) {
+	value, err := f.value(event)
+	if err != nil {
+		return nil, "", err
+	}
+	payload, err := json.Marshal(value)
+	if f.cloudEvents {
+		return payload, cloudEventsContentType, err
+	}
+	return payload, "application/json", err
+}
+
+// value returns the event or its CloudEvents envelope, for sinks that embed it in their own payload
+func (f eventFormat) value(event Event) (interface{}, error) {
+	if !f.cloudEvents {
+		return event, nil
+	}
+	return newCloudEvent(event, f.source, time.Now())
+}
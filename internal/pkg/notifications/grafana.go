@@ -0,0 +1,56 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+)
+
+// GrafanaAnnotation is the body of Grafana's create annotation API
+type GrafanaAnnotation struct {
+	DashboardUID string   `json:"dashboardUID,omitempty"`
+	Time         int64    `json:"time"` // Epoch milliseconds
+	Tags         []string `json:"tags"`
+	Text         string   `json:"text"`
+}
+
+// NewGrafanaAnnotation returns an annotation marking an event that happened at t
+func NewGrafanaAnnotation(t time.Time, text string, tags []string) GrafanaAnnotation {
+	return GrafanaAnnotation{Time: t.UnixMilli(), Text: text, Tags: tags}
+}
+
+// PostGrafanaAnnotation creates an annotation with a Grafana service account token, grafanaURL is the Grafana root URL
+func PostGrafanaAnnotation(ctx context.Context, grafanaURL string, token string, annotation GrafanaAnnotation) error {
+	payload, err := json.Marshal(annotation)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(grafanaURL, "/")+"/api/annotations", strings.NewReader(string(payload)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := httpClient.Do(req)
+	status := "success"
+	if err == nil {
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			err = fmt.Errorf("grafana returned %s", resp.Status)
+		}
+	}
+	if err != nil {
+		status = "failure"
+	}
+	prom.InstrumentNotification("grafana", "promotion_merged", status)
+	return err
+}
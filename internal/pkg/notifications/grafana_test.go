@@ -0,0 +1,40 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostGrafanaAnnotation(t *testing.T) {
+	t.Parallel()
+	var received GrafanaAnnotation
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/annotations", r.URL.Path)
+		assert.Equal(t, "Bearer glsa_token", r.Header.Get("Authorization"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer server.Close()
+
+	annotation := NewGrafanaAnnotation(time.UnixMilli(1717588800000), "Promoted [nginx] to prod", []string{"environment:prod"})
+	err := PostGrafanaAnnotation(context.Background(), server.URL+"/", "glsa_token", annotation)
+
+	assert.NoError(t, err)
+	assert.Equal(t, annotation, received)
+}
+
+func TestPostGrafanaAnnotationError(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	err := PostGrafanaAnnotation(context.Background(), server.URL, "", NewGrafanaAnnotation(time.Now(), "text", nil))
+	assert.Error(t, err)
+}
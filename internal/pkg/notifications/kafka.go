@@ -0,0 +1,39 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// kafkaRestNotifier produces the event JSON(or its CloudEvents envelope) to a Kafka topic through a Kafka REST Proxy(v2 API), keyed by repo so the events of a repo stay ordered
+type kafkaRestNotifier struct {
+	url    string
+	topic  string
+	format eventFormat
+}
+
+func (n *kafkaRestNotifier) Name() string {
+	return "kafka"
+}
+
+func (n *kafkaRestNotifier) Notify(ctx context.Context, event Event) error {
+	value, err := n.format.value(event)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(kafkaRestRecords(event.Repo, value))
+	if err != nil {
+		return err
+	}
+	return postPayload(ctx, strings.TrimSuffix(n.url, "/")+"/topics/"+url.PathEscape(n.topic), "application/vnd.kafka.json.v2+json", payload)
+}
+
+func kafkaRestRecords(key string, value interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"records": []map[string]interface{}{
+			{"key": key, "value": value},
+		},
+	}
+}
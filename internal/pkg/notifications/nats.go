@@ -0,0 +1,104 @@
+package notifications
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// natsNotifier publishes the event JSON(or its CloudEvents envelope) to the "<subjectPrefix>.<event type>" NATS subject.
+// It speaks the plain text NATS client protocol on a connection per event, events are rare enough that a client library and a long lived connection aren't worth it
+type natsNotifier struct {
+	// nats://[user:password@|token@]host:port, tls:// for TLS connections
+	url           string
+	subjectPrefix string
+	format        eventFormat
+}
+
+func (n *natsNotifier) Name() string {
+	return "nats"
+}
+
+func (n *natsNotifier) Notify(ctx context.Context, event Event) error {
+	payload, _, err := n.format.marshal(event)
+	if err != nil {
+		return err
+	}
+	serverURL, err := url.Parse(n.url)
+	if err != nil {
+		return fmt.Errorf("parse NATS URL: %w", err)
+	}
+	conn, err := dialNats(ctx, serverURL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	return natsPublish(conn, serverURL.User, n.subjectPrefix+"."+event.Type, payload)
+}
+
+func dialNats(ctx context.Context, serverURL *url.URL) (net.Conn, error) {
+	host := serverURL.Host
+	if serverURL.Port() == "" {
+		host = net.JoinHostPort(serverURL.Hostname(), "4222")
+	}
+	dialer := &net.Dialer{Timeout: sendTimeout}
+	switch serverURL.Scheme {
+	case "nats":
+		return dialer.DialContext(ctx, "tcp", host)
+	case "tls":
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: serverURL.Hostname(), MinVersion: tls.VersionTLS12}}
+		return tlsDialer.DialContext(ctx, "tcp", host)
+	default:
+		return nil, fmt.Errorf("unsupported NATS URL scheme %s", serverURL.Scheme)
+	}
+}
+
+// natsPublish authenticates and publishes a single message, the PING after it makes the server report errors(e.g. auth or permission ones) before the connection is closed
+func natsPublish(conn net.Conn, user *url.Userinfo, subject string, payload []byte) error {
+	reader := bufio.NewReader(conn)
+	info, err := reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("read NATS server info: %w", err)
+	}
+	if !strings.HasPrefix(info, "INFO ") {
+		return fmt.Errorf("unexpected NATS server greeting: %s", strings.TrimSpace(info))
+	}
+	connectOptions := map[string]interface{}{"verbose": false, "pedantic": false, "name": "telefonistka", "lang": "go"}
+	if user != nil {
+		if password, ok := user.Password(); ok {
+			connectOptions["user"] = user.Username()
+			connectOptions["pass"] = password
+		} else {
+			connectOptions["auth_token"] = user.Username()
+		}
+	}
+	connect, err := json.Marshal(connectOptions)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(conn, "CONNECT %s\r\nPUB %s %d\r\n%s\r\nPING\r\n", connect, subject, len(payload), payload)
+	if err != nil {
+		return fmt.Errorf("publish to NATS: %w", err)
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("read NATS server reply: %w", err)
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("NATS server returned %s", line)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Package notifications sends Telefonistka events(promotion PR lifecycle, ArgoCD diff errors and drift) to Microsoft Teams, generic JSON webhooks,
+// NATS and Kafka(through a Kafka REST Proxy), e.g. to feed incident tooling or let other platforms react to promotions without polling GitHub.
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/tenancy"
+)
+
+// Event types
+const (
+	PromotionPrOpened   = "promotion_pr_opened"
+	PromotionPrMerged   = "promotion_pr_merged"
+	PromotionGateFailed = "promotion_gate_failed"
+	ArgoCdDiffError     = "argocd_diff_error"
+	DriftDetected       = "drift_detected"
+)
+
+const sendTimeout = 10 * time.Second
+
+// Event is passed as is(JSON) to the generic webhook or to its payload template
+type Event struct {
+	Type     string            `json:"type"`
+	Repo     string            `json:"repo"`
+	PrNumber int               `json:"prNumber"`
+	PrURL    string            `json:"prUrl"`
+	Title    string            `json:"title"`
+	Text     string            `json:"text"`
+	Facts    map[string]string `json:"facts,omitempty"`
+}
+
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, event Event) error
+}
+
+var httpClient = &http.Client{Timeout: sendTimeout}
+
+// FromEnv returns the notifiers configured for the tenant in ctx
+func FromEnv(ctx context.Context) []Notifier {
+	var notifiers []Notifier
+	if url := tenancy.Getenv(ctx, "TEAMS_WEBHOOK_URL", ""); url != "" {
+		notifiers = append(notifiers, &teamsNotifier{url: url})
+	}
+	format := eventFormat{
+		cloudEvents: tenancy.Getenv(ctx, "NOTIFICATION_FORMAT", "") == cloudEventsFormat,
+		source:      tenancy.Getenv(ctx, "NOTIFICATION_CLOUDEVENTS_SOURCE", "telefonistka"),
+	}
+	if url := tenancy.Getenv(ctx, "NOTIFICATION_WEBHOOK_URL", ""); url != "" {
+		notifier := &webhookNotifier{url: url, format: format}
+		if templatePath := tenancy.Getenv(ctx, "NOTIFICATION_WEBHOOK_TEMPLATE_PATH", ""); templatePath != "" {
+			payloadTemplate, err := parsePayloadTemplate(templatePath)
+			if err != nil {
+				log.Errorf("Failed to load the notification webhook payload template, sending the event JSON instead: err=%v", err)
+			} else {
+				notifier.payloadTemplate = payloadTemplate
+			}
+		}
+		notifiers = append(notifiers, notifier)
+	}
+	if url := tenancy.Getenv(ctx, "NOTIFICATION_NATS_URL", ""); url != "" {
+		notifiers = append(notifiers, &natsNotifier{url: url, subjectPrefix: tenancy.Getenv(ctx, "NOTIFICATION_NATS_SUBJECT", "telefonistka"), format: format})
+	}
+	if url := tenancy.Getenv(ctx, "NOTIFICATION_KAFKA_REST_URL", ""); url != "" {
+		notifiers = append(notifiers, &kafkaRestNotifier{url: url, topic: tenancy.Getenv(ctx, "NOTIFICATION_KAFKA_TOPIC", "telefonistka-events"), format: format})
+	}
+	return notifiers
+}
+
+func parsePayloadTemplate(templatePath string) (*template.Template, error) {
+	content, err := os.ReadFile(templatePath)
+	if err != nil {
+		return nil, err
+	}
+	return template.New("payload").Funcs(template.FuncMap{"json": toJSON}).Parse(string(content))
+}
+
+// toJSON lets payload templates embed event fields as properly escaped JSON strings
+func toJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// eventTypeEnabled checks NOTIFICATION_EVENT_TYPES, a comma separated list of event types, empty means all of them
+func eventTypeEnabled(ctx context.Context, eventType string) bool {
+	enabledTypes := tenancy.Getenv(ctx, "NOTIFICATION_EVENT_TYPES", "")
+	if enabledTypes == "" {
+		return true
+	}
+	for _, t := range strings.Split(enabledTypes, ",") {
+		if strings.TrimSpace(t) == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Send notifies all the configured notifiers, failures are logged and don't fail the event handling
+func Send(ctx context.Context, event Event) {
+	if !eventTypeEnabled(ctx, event.Type) {
+		return
+	}
+	for _, notifier := range FromEnv(ctx) {
+		sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+		err := notifier.Notify(sendCtx, event)
+		cancel()
+		status := "success"
+		if err != nil {
+			status = "failure"
+			log.Errorf("Failed to send %s notification to %s: err=%v", event.Type, notifier.Name(), err)
+		}
+		prom.InstrumentNotification(notifier.Name(), event.Type, status)
+	}
+}
+
+func postJSON(ctx context.Context, url string, payload []byte) error {
+	return postPayload(ctx, url, "application/json", payload)
+}
+
+func postPayload(ctx context.Context, url string, contentType string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, body)
+	}
+	return nil
+}
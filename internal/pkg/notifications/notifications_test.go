@@ -0,0 +1,219 @@
+package notifications
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/tenancy"
+)
+
+var testEvent = Event{
+	Type:     PromotionPrOpened,
+	Repo:     "AnOwner/Arepo",
+	PrNumber: 42,
+	PrURL:    "https://github.com/AnOwner/Arepo/pull/42",
+	Title:    "🚀 Promotion: c1 ➡️  prod",
+	Text:     "Promotion PR #42 was opened for #41",
+	Facts:    map[string]string{"Source": "env/staging/"},
+}
+
+func receiver(t *testing.T, payloads chan<- []byte) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, _ := io.ReadAll(r.Body)
+		payloads <- body
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func tenantContext(env map[string]string) context.Context {
+	return tenancy.NewContext(context.Background(), &tenancy.Tenant{Name: "test", Env: env})
+}
+
+func TestSendTeams(t *testing.T) {
+	t.Parallel()
+	payloads := make(chan []byte, 1)
+	server := receiver(t, payloads)
+
+	Send(tenantContext(map[string]string{"TEAMS_WEBHOOK_URL": server.URL}), testEvent)
+
+	var message struct {
+		Type        string `json:"type"`
+		Attachments []struct {
+			ContentType string `json:"contentType"`
+			Content     struct {
+				Type    string `json:"type"`
+				Actions []struct {
+					URL string `json:"url"`
+				} `json:"actions"`
+			} `json:"content"`
+		} `json:"attachments"`
+	}
+	assert.NoError(t, json.Unmarshal(<-payloads, &message))
+	assert.Equal(t, "message", message.Type)
+	assert.Equal(t, "application/vnd.microsoft.card.adaptive", message.Attachments[0].ContentType)
+	assert.Equal(t, "AdaptiveCard", message.Attachments[0].Content.Type)
+	assert.Equal(t, testEvent.PrURL, message.Attachments[0].Content.Actions[0].URL)
+}
+
+func TestSendWebhook(t *testing.T) {
+	t.Parallel()
+	templatePath := filepath.Join(t.TempDir(), "payload.tmpl")
+	err := os.WriteFile(templatePath, []byte(`{"summary": {{ json .Title }}, "severity": "info", "link": "{{ .PrURL }}"}`), 0o600)
+	assert.NoError(t, err)
+
+	tests := map[string]struct {
+		env      func(url string) map[string]string
+		expected string
+	}{
+		"Event JSON": {
+			env: func(url string) map[string]string {
+				return map[string]string{"NOTIFICATION_WEBHOOK_URL": url}
+			},
+			expected: `{"type":"promotion_pr_opened","repo":"AnOwner/Arepo","prNumber":42,"prUrl":"https://github.com/AnOwner/Arepo/pull/42","title":"🚀 Promotion: c1 ➡️  prod","text":"Promotion PR #42 was opened for #41","facts":{"Source":"env/staging/"}}`,
+		},
+		"Payload template": {
+			env: func(url string) map[string]string {
+				return map[string]string{"NOTIFICATION_WEBHOOK_URL": url, "NOTIFICATION_WEBHOOK_TEMPLATE_PATH": templatePath}
+			},
+			expected: `{"summary": "🚀 Promotion: c1 ➡️  prod", "severity": "info", "link": "https://github.com/AnOwner/Arepo/pull/42"}`,
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			payloads := make(chan []byte, 1)
+			server := receiver(t, payloads)
+			Send(tenantContext(tc.env(server.URL)), testEvent)
+			assert.JSONEq(t, tc.expected, string(<-payloads))
+		})
+	}
+}
+
+func TestEventTypeEnabled(t *testing.T) {
+	t.Parallel()
+	assert.True(t, eventTypeEnabled(tenantContext(map[string]string{}), DriftDetected))
+	ctx := tenantContext(map[string]string{"NOTIFICATION_EVENT_TYPES": "argocd_diff_error, drift_detected"})
+	assert.True(t, eventTypeEnabled(ctx, DriftDetected))
+	assert.False(t, eventTypeEnabled(ctx, PromotionPrOpened))
+}
+
+func TestSendKafka(t *testing.T) {
+	t.Parallel()
+	requests := make(chan *http.Request, 1)
+	payloads := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- r
+		payloads <- body
+	}))
+	t.Cleanup(server.Close)
+
+	Send(tenantContext(map[string]string{"NOTIFICATION_KAFKA_REST_URL": server.URL + "/", "NOTIFICATION_KAFKA_TOPIC": "gitops.events"}), testEvent)
+
+	r := <-requests
+	assert.Equal(t, "/topics/gitops.events", r.URL.Path)
+	assert.Equal(t, "application/vnd.kafka.json.v2+json", r.Header.Get("Content-Type"))
+	var records struct {
+		Records []struct {
+			Key   string `json:"key"`
+			Value Event  `json:"value"`
+		} `json:"records"`
+	}
+	assert.NoError(t, json.Unmarshal(<-payloads, &records))
+	assert.Equal(t, "AnOwner/Arepo", records.Records[0].Key)
+	assert.Equal(t, testEvent, records.Records[0].Value)
+}
+
+// fakeNatsServer accepts a single connection and sends the CONNECT and PUB lines it receives to commands, reply is sent to the PING
+func fakeNatsServer(t *testing.T, reply string, commands chan<- string) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = fmt.Fprint(conn, "INFO {\"server_id\":\"test\"}\r\n")
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSpace(line)
+			if line == "PING" {
+				_, _ = fmt.Fprint(conn, reply)
+				return
+			}
+			commands <- line
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestNatsNotifier(t *testing.T) {
+	t.Parallel()
+	commands := make(chan string, 3)
+	addr := fakeNatsServer(t, "PONG\r\n", commands)
+
+	notifier := &natsNotifier{url: "nats://s3cr3t@" + addr, subjectPrefix: "telefonistka"}
+	assert.NoError(t, notifier.Notify(context.Background(), testEvent))
+
+	assert.JSONEq(t, `{"verbose":false,"pedantic":false,"name":"telefonistka","lang":"go","auth_token":"s3cr3t"}`, strings.TrimPrefix(<-commands, "CONNECT "))
+	payload, _ := json.Marshal(testEvent)
+	assert.Equal(t, fmt.Sprintf("PUB telefonistka.promotion_pr_opened %d", len(payload)), <-commands)
+	assert.Equal(t, string(payload), <-commands)
+}
+
+func TestNatsNotifierError(t *testing.T) {
+	t.Parallel()
+	commands := make(chan string, 3)
+	addr := fakeNatsServer(t, "-ERR 'Authorization Violation'\r\n", commands)
+
+	notifier := &natsNotifier{url: "nats://" + addr, subjectPrefix: "telefonistka"}
+	assert.ErrorContains(t, notifier.Notify(context.Background(), testEvent), "Authorization Violation")
+}
+
+func TestSendCloudEvents(t *testing.T) {
+	t.Parallel()
+	requests := make(chan *http.Request, 1)
+	payloads := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- r
+		payloads <- body
+	}))
+	t.Cleanup(server.Close)
+
+	Send(tenantContext(map[string]string{"NOTIFICATION_WEBHOOK_URL": server.URL, "NOTIFICATION_FORMAT": "cloudevents", "NOTIFICATION_CLOUDEVENTS_SOURCE": "https://telefonistka.example.com"}), testEvent)
+
+	assert.Equal(t, "application/cloudevents+json", (<-requests).Header.Get("Content-Type"))
+	var envelope cloudEvent
+	assert.NoError(t, json.Unmarshal(<-payloads, &envelope))
+	assert.Equal(t, "1.0", envelope.SpecVersion)
+	assert.Len(t, envelope.ID, 32)
+	assert.Equal(t, "https://telefonistka.example.com", envelope.Source)
+	assert.Equal(t, "com.github.wayfair-incubator.telefonistka.promotion_pr_opened", envelope.Type)
+	assert.Equal(t, "AnOwner/Arepo", envelope.Subject)
+	assert.NotEmpty(t, envelope.Time)
+	assert.Equal(t, "application/json", envelope.DataContentType)
+	assert.Equal(t, testEvent, envelope.Data)
+}
@@ -0,0 +1,57 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// teamsNotifier posts Adaptive Cards, these are supported by both Teams Workflows webhooks and the older incoming webhook connectors
+type teamsNotifier struct {
+	url string
+}
+
+func (n *teamsNotifier) Name() string {
+	return "teams"
+}
+
+func (n *teamsNotifier) Notify(ctx context.Context, event Event) error {
+	payload, err := json.Marshal(teamsCard(event))
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, n.url, payload)
+}
+
+func teamsCard(event Event) map[string]interface{} {
+	body := []map[string]interface{}{
+		{"type": "TextBlock", "text": event.Title, "weight": "Bolder", "size": "Medium", "wrap": true},
+		{"type": "TextBlock", "text": event.Text, "wrap": true},
+	}
+	facts := []map[string]string{{"title": "Repo", "value": event.Repo}}
+	factNames := make([]string, 0, len(event.Facts))
+	for name := range event.Facts {
+		factNames = append(factNames, name)
+	}
+	sort.Strings(factNames)
+	for _, name := range factNames {
+		facts = append(facts, map[string]string{"title": name, "value": event.Facts[name]})
+	}
+	body = append(body, map[string]interface{}{"type": "FactSet", "facts": facts})
+	card := map[string]interface{}{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body":    body,
+	}
+	if event.PrURL != "" {
+		card["actions"] = []map[string]string{{"type": "Action.OpenUrl", "title": fmt.Sprintf("Open PR #%d", event.PrNumber), "url": event.PrURL}}
+	}
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []map[string]interface{}{
+			{"contentType": "application/vnd.microsoft.card.adaptive", "content": card},
+		},
+	}
+}
@@ -0,0 +1,35 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"text/template"
+)
+
+// webhookNotifier posts the event JSON(or its CloudEvents envelope), or the output of payloadTemplate(rendered with the event) when it's set
+type webhookNotifier struct {
+	url             string
+	payloadTemplate *template.Template
+	format          eventFormat
+}
+
+func (n *webhookNotifier) Name() string {
+	return "webhook"
+}
+
+func (n *webhookNotifier) Notify(ctx context.Context, event Event) error {
+	payload, contentType, err := n.payload(event)
+	if err != nil {
+		return err
+	}
+	return postPayload(ctx, n.url, contentType, payload)
+}
+
+func (n *webhookNotifier) payload(event Event) ([]byte, string, error) {
+	if n.payloadTemplate == nil {
+		return n.format.marshal(event)
+	}
+	var payload bytes.Buffer
+	err := n.payloadTemplate.Execute(&payload, event)
+	return payload.Bytes(), "application/json", err
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/go-github/v62/github"
 	"github.com/prometheus/client_golang/prometheus"
@@ -79,6 +80,200 @@ var (
 		Namespace: "telefonistka",
 		Subsystem: "webhook_proxy",
 	}, []string{"status", "method", "url"})
+
+	argocdTempAppCleanupsVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "temp_app_cleanups_total",
+		Help:      "The total number of temporary ArgoCD apps deleted by the garbage collector, and their status (success/failure)",
+		Namespace: "telefonistka",
+		Subsystem: "argocd",
+	}, []string{"status"})
+
+	httpRequestsVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "http_requests_total",
+		Help:      "The total number of HTTP requests, by route pattern, method and response code",
+		Namespace: "telefonistka",
+		Subsystem: "webhook_server",
+	}, []string{"route", "method", "code"})
+
+	httpRequestDurationHistogramVec = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:      "http_request_duration_seconds",
+		Help:      "The duration of HTTP requests, until the response is written(events are handled asynchronously)",
+		Namespace: "telefonistka",
+		Subsystem: "webhook_server",
+		Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"route", "method"})
+
+	eventProcessingDurationHistogramVec = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:      "event_processing_duration_seconds",
+		Help:      "The duration of webhook event handling, from receipt to the final comment/status",
+		Namespace: "telefonistka",
+		Subsystem: "webhook_server",
+		Buckets:   []float64{0.5, 1, 2.5, 5, 10, 20, 30, 60, 120, 300},
+	}, []string{"provider", "event_type", "repo_slug"})
+
+	eventsInProgressGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name:      "events_in_progress",
+		Help:      "The number of webhook events currently being handled",
+		Namespace: "telefonistka",
+		Subsystem: "webhook_server",
+	}, []string{"provider", "event_type"})
+
+	eventTimeoutsVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "event_timeouts_total",
+		Help:      "The total number of webhook events whose handling hit its deadline",
+		Namespace: "telefonistka",
+		Subsystem: "webhook_server",
+	}, []string{"provider", "event_type", "repo_slug"})
+
+	argocdDiffDurationHistogramVec = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:      "diff_duration_seconds",
+		Help:      "The duration of ArgoCD diff generation of a component, and its result (diff/no_diff/error)",
+		Namespace: "telefonistka",
+		Subsystem: "argocd",
+		Buckets:   []float64{0.5, 1, 2.5, 5, 10, 20, 30, 60, 120},
+	}, []string{"component_path", "result"})
+
+	argocdDiffErrorsVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "diff_errors_total",
+		Help:      "The total number of failed ArgoCD diffs per app",
+		Namespace: "telefonistka",
+		Subsystem: "argocd",
+	}, []string{"component_path", "app"})
+
+	argocdTempAppCreationsVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "temp_app_creations_total",
+		Help:      "The total number of temporary ArgoCD apps created for diffing new components, and their status (success/failure)",
+		Namespace: "telefonistka",
+		Subsystem: "argocd",
+	}, []string{"component_path", "status"})
+
+	argocdBranchSyncOpsVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "branch_sync_operations_total",
+		Help:      "The total number of ArgoCD app revision changes made by branch sync, their operation (set/revert) and status (success/failure)",
+		Namespace: "telefonistka",
+		Subsystem: "argocd",
+	}, []string{"component_path", "operation", "status"})
+
+	githubWriteOperationsVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "write_operation_attempts_total",
+		Help:      "The total number of GitHub write operation attempts, and their result (success/retryable_error/permanent_error/retries_exhausted)",
+		Namespace: "telefonistka",
+		Subsystem: "github",
+	}, []string{"operation", "result"})
+
+	installationTokenMintsVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "installation_token_mints_total",
+		Help:      "The total number of GitHub App installation tokens minted, and their status (success/failure)",
+		Namespace: "telefonistka",
+		Subsystem: "github",
+	}, []string{"app_id", "status"})
+
+	conditionalReadsVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "conditional_reads_total",
+		Help:      "The total number of conditional(ETag) contents and git trees reads, by result (not_modified/modified)",
+		Namespace: "telefonistka",
+		Subsystem: "github",
+	}, []string{"result"})
+
+	publicReadsVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "public_reads_total",
+		Help:      "The total number of public repo reads done without the GitHub App credentials, by result (read/cached/fallback)",
+		Namespace: "telefonistka",
+		Subsystem: "github",
+	}, []string{"result"})
+
+	sharedCacheRequestsVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "shared_cache_requests_total",
+		Help:      "The total number of shared(Redis) cache requests, by cache and result (hit/miss/error)",
+		Namespace: "telefonistka",
+	}, []string{"cache", "result"})
+
+	promotionPrJanitorClosuresVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "promotion_pr_janitor_closures_total",
+		Help:      "The total number of promotion PRs closed by the janitor, their reason (max_age/superseded) and status (success/failure)",
+		Namespace: "telefonistka",
+		Subsystem: "github",
+	}, []string{"repo_slug", "reason", "status"})
+
+	unverifiedPrMetadataVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "unverified_pr_metadata_total",
+		Help:      "The total number of PR metadata blocks that failed signature verification, by reason (tampered/unsigned/unsigned_allowed)",
+		Namespace: "telefonistka",
+		Subsystem: "github",
+	}, []string{"repo_slug", "reason"})
+
+	pausedPromotionTargetsVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "paused_promotion_targets_total",
+		Help:      "The total number of promotion target paths skipped because promotions to them are paused",
+		Namespace: "telefonistka",
+		Subsystem: "github",
+	}, []string{"repo_slug"})
+
+	shadowModeActionsVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "shadow_mode_actions_total",
+		Help:      "The total number of writes(GitHub API and ArgoCD) skipped because the repo is in shadow mode, by the skipped action",
+		Namespace: "telefonistka",
+		Subsystem: "github",
+	}, []string{"repo_slug", "action"})
+
+	maintenanceModeGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name:      "maintenance_mode",
+		Help:      "1 while Telefonistka is in maintenance mode(read-only), 0 otherwise",
+		Namespace: "telefonistka",
+	})
+
+	maintenanceModeSkipsVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "maintenance_mode_skips_total",
+		Help:      "The total number of events and writes skipped because Telefonistka is in maintenance mode, by the skipped event or action",
+		Namespace: "telefonistka",
+	}, []string{"action"})
+
+	leaderGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name:      "leader",
+		Help:      "1 while this replica is the leader that runs the periodic jobs, 0 otherwise",
+		Namespace: "telefonistka",
+	})
+
+	serverConfigReloadsVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "server_config_reloads_total",
+		Help:      "The total number of server configuration reloads, by result (success/failure)",
+		Namespace: "telefonistka",
+	}, []string{"result"})
+
+	adminActionsVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "actions_total",
+		Help:      "The total number of admin API requests, by action and response status code",
+		Namespace: "telefonistka",
+		Subsystem: "admin",
+	}, []string{"action", "status"})
+
+	secretScanFindingsVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "secret_scan_findings_total",
+		Help:      "The total number of promotions in which secret scanning found possible credentials, by secret scanning mode",
+		Namespace: "telefonistka",
+		Subsystem: "github",
+	}, []string{"repo_slug", "mode"})
+
+	driftedPathsGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name:      "drifted_paths",
+		Help:      "The number of promotion target paths that differ from their source path, as of the last drift scan",
+		Namespace: "telefonistka",
+		Subsystem: "github",
+	}, []string{"repo_slug"})
+
+	notificationsVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "sent_total",
+		Help:      "The total number of notifications sent, by notifier(teams/webhook/grafana), event type and status (success/failure)",
+		Namespace: "telefonistka",
+		Subsystem: "notifications",
+	}, []string{"notifier", "event_type", "status"})
+
+	ticketTransitionsVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name:      "issue_transitions_total",
+		Help:      "The total number of issue tracker ticket transitions, by tracker and status (success/failure)",
+		Namespace: "telefonistka",
+		Subsystem: "ticketing",
+	}, []string{"tracker", "status"})
 )
 
 func IncCommitStatusUpdateCounter(repoSlug string, status string) {
@@ -97,7 +292,135 @@ func PublishPrMetrics(pc PrCounters, repoSlug string) {
 	ghOpenPrsWithPendingCheckGauge.With(metricLables).Set(float64(pc.PrWithStaleChecks))
 }
 
+// This function instrument attempts of GitHub write operations made through the retry helper
+func InstrumentGhWrite(operation string, result string) {
+	githubWriteOperationsVec.With(prometheus.Labels{"operation": operation, "result": result}).Inc()
+}
+
+// This function instrument GitHub App installation token mints
+func InstrumentInstallationTokenMint(appId string, status string) {
+	installationTokenMintsVec.With(prometheus.Labels{"app_id": appId, "status": status}).Inc()
+}
+
+func InstrumentConditionalRead(result string) {
+	conditionalReadsVec.With(prometheus.Labels{"result": result}).Inc()
+}
+
+func InstrumentPublicRead(result string) {
+	publicReadsVec.With(prometheus.Labels{"result": result}).Inc()
+}
+
+func InstrumentSharedCacheRequest(cache string, result string) {
+	sharedCacheRequestsVec.With(prometheus.Labels{"cache": cache, "result": result}).Inc()
+}
+
+// This function instrument promotion PRs closed by the janitor
+func InstrumentPromotionPrJanitorClosure(repoSlug string, reason string, status string) {
+	promotionPrJanitorClosuresVec.With(prometheus.Labels{"repo_slug": repoSlug, "reason": reason, "status": status}).Inc()
+}
+
+func InstrumentUnverifiedPrMetadata(repoSlug string, reason string) {
+	unverifiedPrMetadataVec.With(prometheus.Labels{"repo_slug": repoSlug, "reason": reason}).Inc()
+}
+
+func InstrumentPausedPromotionTargets(repoSlug string, count int) {
+	pausedPromotionTargetsVec.With(prometheus.Labels{"repo_slug": repoSlug}).Add(float64(count))
+}
+
+func InstrumentShadowModeAction(repoSlug string, action string) {
+	shadowModeActionsVec.With(prometheus.Labels{"repo_slug": repoSlug, "action": action}).Inc()
+}
+
+func SetMaintenanceMode(enabled bool) {
+	if enabled {
+		maintenanceModeGauge.Set(1)
+	} else {
+		maintenanceModeGauge.Set(0)
+	}
+}
+
+func InstrumentMaintenanceModeSkip(action string) {
+	maintenanceModeSkipsVec.With(prometheus.Labels{"action": action}).Inc()
+}
+
+func SetLeader(leader bool) {
+	if leader {
+		leaderGauge.Set(1)
+	} else {
+		leaderGauge.Set(0)
+	}
+}
+
+func InstrumentServerConfigReload(result string) {
+	serverConfigReloadsVec.With(prometheus.Labels{"result": result}).Inc()
+}
+
+func InstrumentAdminAction(action string, status string) {
+	adminActionsVec.With(prometheus.Labels{"action": action, "status": status}).Inc()
+}
+
+func InstrumentSecretScanFindings(repoSlug string, mode string) {
+	secretScanFindingsVec.With(prometheus.Labels{"repo_slug": repoSlug, "mode": mode}).Inc()
+}
+
+func InstrumentDriftedPaths(repoSlug string, count int) {
+	driftedPathsGauge.With(prometheus.Labels{"repo_slug": repoSlug}).Set(float64(count))
+}
+
+func InstrumentNotification(notifier string, eventType string, status string) {
+	notificationsVec.With(prometheus.Labels{"notifier": notifier, "event_type": eventType, "status": status}).Inc()
+}
+
+func InstrumentTicketTransition(tracker string, status string) {
+	ticketTransitionsVec.With(prometheus.Labels{"tracker": tracker, "status": status}).Inc()
+}
+
+// This function instrument deletions of orphaned temporary ArgoCD apps
+func InstrumentTempAppCleanup(status string) {
+	argocdTempAppCleanupsVec.With(prometheus.Labels{"status": status}).Inc()
+}
+
+// This function instrument ArgoCD diff generation of a component, failed diffs are also counted per app
+func InstrumentArgocdDiff(componentPath string, appName string, result string, duration time.Duration) {
+	argocdDiffDurationHistogramVec.With(prometheus.Labels{"component_path": componentPath, "result": result}).Observe(duration.Seconds())
+	if result == "error" {
+		argocdDiffErrorsVec.With(prometheus.Labels{"component_path": componentPath, "app": appName}).Inc()
+	}
+}
+
+// This function instrument creations of temporary ArgoCD apps
+func InstrumentArgocdTempAppCreation(componentPath string, status string) {
+	argocdTempAppCreationsVec.With(prometheus.Labels{"component_path": componentPath, "status": status}).Inc()
+}
+
+// This function instrument ArgoCD app revision changes made by branch sync
+func InstrumentArgocdBranchSync(componentPath string, operation string, status string) {
+	argocdBranchSyncOpsVec.With(prometheus.Labels{"component_path": componentPath, "operation": operation, "status": status}).Inc()
+}
+
+// TrackEventProcessing marks an event as in progress, the returned function records its processing duration and should be called once handling is done
+func TrackEventProcessing(provider string, eventType string, repoSlug string) func() {
+	startTime := time.Now()
+	inProgress := eventsInProgressGauge.With(prometheus.Labels{"provider": provider, "event_type": eventType})
+	inProgress.Inc()
+	return func() {
+		inProgress.Dec()
+		eventProcessingDurationHistogramVec.With(prometheus.Labels{"provider": provider, "event_type": eventType, "repo_slug": repoSlug}).Observe(time.Since(startTime).Seconds())
+	}
+}
+
+// InstrumentEventTimeout counts an event whose handling was cut short by its deadline
+func InstrumentEventTimeout(provider string, eventType string, repoSlug string) {
+	eventTimeoutsVec.With(prometheus.Labels{"provider": provider, "event_type": eventType, "repo_slug": repoSlug}).Inc()
+}
+
 // This function instrument Webhook hits and parsing of their content
+// This function instrument the requests of the HTTP server, route is the matched ServeMux pattern so URLs with IDs don't add labels
+func InstrumentHTTPRequest(route string, method string, code int, duration time.Duration) {
+	httpRequestsVec.With(prometheus.Labels{"route": route, "method": method, "code": strconv.Itoa(code)}).Inc()
+	httpRequestDurationHistogramVec.With(prometheus.Labels{"route": route, "method": method}).Observe(duration.Seconds())
+}
+
 func InstrumentWebhookHit(parsing_status string) {
 	webhookHitsVec.With(prometheus.Labels{"parsing": parsing_status}).Inc()
 }
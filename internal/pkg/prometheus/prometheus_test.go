@@ -4,10 +4,13 @@ import (
 	"net/http"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/go-test/deep"
 	"github.com/google/go-github/v62/github"
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestUserGetUrl(t *testing.T) {
@@ -132,3 +135,41 @@ func instrumentGhCallTestHelper(t *testing.T, httpURL string, expectedLabels pro
 		t.Error(diff)
 	}
 }
+
+func counterValue(t *testing.T, c prometheus.Counter) float64 {
+	t.Helper()
+	m := &dto.Metric{}
+	if err := c.Write(m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+func TestInstrumentArgocdDiff(t *testing.T) {
+	t.Parallel()
+	InstrumentArgocdDiff("workspace/foo", "foo-app", "error", 2*time.Second)
+	InstrumentArgocdDiff("workspace/foo", "foo-app", "diff", time.Second)
+
+	assert.InDelta(t, 1, counterValue(t, argocdDiffErrorsVec.With(prometheus.Labels{"component_path": "workspace/foo", "app": "foo-app"})), 0)
+}
+
+func TestInstrumentArgocdBranchSync(t *testing.T) {
+	t.Parallel()
+	InstrumentArgocdBranchSync("workspace/bar", "revert", "success")
+	assert.InDelta(t, 1, counterValue(t, argocdBranchSyncOpsVec.With(prometheus.Labels{"component_path": "workspace/bar", "operation": "revert", "status": "success"})), 0)
+}
+
+func TestTrackEventProcessing(t *testing.T) {
+	t.Parallel()
+	gaugeValue := func() float64 {
+		m := &dto.Metric{}
+		if err := eventsInProgressGauge.With(prometheus.Labels{"provider": "github", "event_type": "test_event"}).Write(m); err != nil {
+			t.Fatal(err)
+		}
+		return m.GetGauge().GetValue()
+	}
+	done := TrackEventProcessing("github", "test_event", "AnOwner/Arepo")
+	assert.InDelta(t, 1, gaugeValue(), 0)
+	done()
+	assert.InDelta(t, 0, gaugeValue(), 0)
+}
@@ -0,0 +1,451 @@
+// Package bitbucket implements the scm.Provider interface for Bitbucket Cloud(REST API 2.0) and Bitbucket Server/Data Center(REST API 1.0)
+package bitbucket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/scm"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/secrets"
+)
+
+const cloudAPIBaseURL = "https://api.bitbucket.org/2.0"
+
+// Provider talks to Bitbucket Cloud when baseURL is empty and to a Bitbucket Server instance otherwise.
+// In Bitbucket Server terms the scm.Repo Owner is the project key and Name is the repository slug, in Bitbucket Cloud terms they are the workspace and repository slug.
+type Provider struct {
+	serverURL     string
+	username      string
+	token         string
+	webhookSecret []byte
+	httpClient    *http.Client
+}
+
+func New(serverURL string, username string, token string, webhookSecret string) *Provider {
+	return &Provider{
+		serverURL:     strings.TrimSuffix(serverURL, "/"),
+		username:      username,
+		token:         token,
+		webhookSecret: []byte(webhookSecret),
+		httpClient:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// NewFromEnv creates a Provider based on the BITBUCKET_* environment variables(the token and webhook secret can come from any secret source), it returns nil if BITBUCKET_TOKEN is not set.
+// BITBUCKET_WEBHOOK_SECRET is required as unsigned webhooks are rejected, without it the provider stays disabled.
+func NewFromEnv() *Provider {
+	token, ok := secrets.Lookup("BITBUCKET_TOKEN")
+	if !ok {
+		return nil
+	}
+	webhookSecret := secrets.Get("BITBUCKET_WEBHOOK_SECRET", "")
+	if webhookSecret == "" {
+		log.Errorf("BITBUCKET_TOKEN is set but BITBUCKET_WEBHOOK_SECRET isn't, Bitbucket support is disabled")
+		return nil
+	}
+	return New(os.Getenv("BITBUCKET_URL"), os.Getenv("BITBUCKET_USERNAME"), token, webhookSecret)
+}
+
+func (p *Provider) Name() string {
+	return "bitbucket"
+}
+
+func (p *Provider) isCloud() bool {
+	return p.serverURL == ""
+}
+
+func (p *Provider) repoURL(repo scm.Repo) string {
+	if p.isCloud() {
+		return fmt.Sprintf("%s/repositories/%s/%s", cloudAPIBaseURL, url.PathEscape(repo.Owner), url.PathEscape(repo.Name))
+	}
+	return fmt.Sprintf("%s/rest/api/1.0/projects/%s/repos/%s", p.serverURL, url.PathEscape(repo.Owner), url.PathEscape(repo.Name))
+}
+
+func escapePath(filePath string) string {
+	parts := strings.Split(strings.Trim(filePath, "/"), "/")
+	for i := range parts {
+		parts[i] = url.PathEscape(parts[i])
+	}
+	return strings.Join(parts, "/")
+}
+
+func (p *Provider) do(ctx context.Context, method string, reqURL string, contentType string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, body)
+	if err != nil {
+		return nil, err
+	}
+	if p.username != "" {
+		req.SetBasicAuth(p.username, p.token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%s %s: %w", method, reqURL, scm.ErrNotFound)
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s %s: unexpected status %d: %s", method, reqURL, resp.StatusCode, string(respBody))
+	}
+	return respBody, nil
+}
+
+func (p *Provider) doJSON(ctx context.Context, method string, reqURL string, in interface{}, out interface{}) error {
+	var body io.Reader
+	contentType := ""
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+		contentType = "application/json"
+	}
+	respBody, err := p.do(ctx, method, reqURL, contentType, body)
+	if err != nil {
+		return err
+	}
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+	return json.Unmarshal(respBody, out)
+}
+
+func (p *Provider) GetFileContent(ctx context.Context, repo scm.Repo, ref string, filePath string) ([]byte, error) {
+	if p.isCloud() {
+		return p.do(ctx, http.MethodGet, fmt.Sprintf("%s/src/%s/%s", p.repoURL(repo), url.PathEscape(ref), escapePath(filePath)), "", nil)
+	}
+	return p.do(ctx, http.MethodGet, fmt.Sprintf("%s/raw/%s?at=%s", p.repoURL(repo), escapePath(filePath), url.QueryEscape(ref)), "", nil)
+}
+
+// cloudPage is the pagination envelope of Bitbucket Cloud
+type cloudPage struct {
+	Values json.RawMessage `json:"values"`
+	Next   string          `json:"next"`
+}
+
+// serverPage is the pagination envelope of Bitbucket Server
+type serverPage struct {
+	Values        json.RawMessage `json:"values"`
+	IsLastPage    bool            `json:"isLastPage"`
+	NextPageStart int             `json:"nextPageStart"`
+}
+
+// paginate calls handlePage with the raw "values" of each page
+func (p *Provider) paginate(ctx context.Context, reqURL string, handlePage func(values json.RawMessage) error) error {
+	if p.isCloud() {
+		for reqURL != "" {
+			var page cloudPage
+			err := p.doJSON(ctx, http.MethodGet, reqURL, nil, &page)
+			if err != nil {
+				return err
+			}
+			err = handlePage(page.Values)
+			if err != nil {
+				return err
+			}
+			reqURL = page.Next
+		}
+		return nil
+	}
+	separator := "?"
+	if strings.Contains(reqURL, "?") {
+		separator = "&"
+	}
+	start := 0
+	for {
+		var page serverPage
+		err := p.doJSON(ctx, http.MethodGet, fmt.Sprintf("%s%slimit=1000&start=%d", reqURL, separator, start), nil, &page)
+		if err != nil {
+			return err
+		}
+		err = handlePage(page.Values)
+		if err != nil {
+			return err
+		}
+		if page.IsLastPage {
+			return nil
+		}
+		start = page.NextPageStart
+	}
+}
+
+func (p *Provider) ListPrFiles(ctx context.Context, repo scm.Repo, prNumber int) ([]string, error) {
+	files := []string{}
+	if p.isCloud() {
+		err := p.paginate(ctx, fmt.Sprintf("%s/pullrequests/%d/diffstat", p.repoURL(repo), prNumber), func(values json.RawMessage) error {
+			var diffstats []struct {
+				Old *struct {
+					Path string `json:"path"`
+				} `json:"old"`
+				New *struct {
+					Path string `json:"path"`
+				} `json:"new"`
+			}
+			err := json.Unmarshal(values, &diffstats)
+			for _, d := range diffstats {
+				if d.New != nil {
+					files = append(files, d.New.Path)
+				} else if d.Old != nil {
+					files = append(files, d.Old.Path)
+				}
+			}
+			return err
+		})
+		return files, err
+	}
+	err := p.paginate(ctx, fmt.Sprintf("%s/pull-requests/%d/changes", p.repoURL(repo), prNumber), func(values json.RawMessage) error {
+		var changes []struct {
+			Path struct {
+				ToString string `json:"toString"`
+			} `json:"path"`
+		}
+		err := json.Unmarshal(values, &changes)
+		for _, c := range changes {
+			files = append(files, c.Path.ToString)
+		}
+		return err
+	})
+	return files, err
+}
+
+func (p *Provider) ListFiles(ctx context.Context, repo scm.Repo, ref string, dirPath string) ([]string, error) {
+	dirPath = strings.Trim(dirPath, "/")
+	files := []string{}
+	if p.isCloud() {
+		// Bitbucket Cloud doesn't offer a recursive listing, so we walk the directory tree ourselves
+		dirs := []string{dirPath}
+		for len(dirs) > 0 {
+			dir := dirs[0]
+			dirs = dirs[1:]
+			err := p.paginate(ctx, fmt.Sprintf("%s/src/%s/%s/?pagelen=100", p.repoURL(repo), url.PathEscape(ref), escapePath(dir)), func(values json.RawMessage) error {
+				var entries []struct {
+					Type string `json:"type"`
+					Path string `json:"path"`
+				}
+				err := json.Unmarshal(values, &entries)
+				for _, e := range entries {
+					switch e.Type {
+					case "commit_directory":
+						dirs = append(dirs, e.Path)
+					case "commit_file":
+						files = append(files, strings.TrimPrefix(e.Path, dirPath+"/"))
+					}
+				}
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		err := p.paginate(ctx, fmt.Sprintf("%s/files/%s?at=%s", p.repoURL(repo), escapePath(dirPath), url.QueryEscape(ref)), func(values json.RawMessage) error {
+			var entries []string
+			err := json.Unmarshal(values, &entries)
+			files = append(files, entries...)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func (p *Provider) getBranchHead(ctx context.Context, repo scm.Repo, branch string) (string, error) {
+	if p.isCloud() {
+		var b struct {
+			Target struct {
+				Hash string `json:"hash"`
+			} `json:"target"`
+		}
+		err := p.doJSON(ctx, http.MethodGet, fmt.Sprintf("%s/refs/branches/%s", p.repoURL(repo), url.PathEscape(branch)), nil, &b)
+		return b.Target.Hash, err
+	}
+	var branches struct {
+		Values []struct {
+			DisplayID    string `json:"displayId"`
+			LatestCommit string `json:"latestCommit"`
+		} `json:"values"`
+	}
+	err := p.doJSON(ctx, http.MethodGet, fmt.Sprintf("%s/branches?filterText=%s", p.repoURL(repo), url.QueryEscape(branch)), nil, &branches)
+	if err != nil {
+		return "", err
+	}
+	for _, b := range branches.Values {
+		if b.DisplayID == branch {
+			return b.LatestCommit, nil
+		}
+	}
+	return "", fmt.Errorf("branch %s: %w", branch, scm.ErrNotFound)
+}
+
+func (p *Provider) CommitFiles(ctx context.Context, repo scm.Repo, baseBranch string, newBranch string, message string, changes map[string]*string) error {
+	baseCommit, err := p.getBranchHead(ctx, repo, baseBranch)
+	if err != nil {
+		return fmt.Errorf("get %s HEAD: %w", baseBranch, err)
+	}
+	if p.isCloud() {
+		return p.commitFilesCloud(ctx, repo, baseCommit, newBranch, message, changes)
+	}
+	return p.commitFilesServer(ctx, repo, baseCommit, newBranch, message, changes)
+}
+
+// commitFilesCloud uses the src endpoint that creates a single commit(and the branch) with all added, modified and deleted files
+func (p *Provider) commitFilesCloud(ctx context.Context, repo scm.Repo, baseCommit string, newBranch string, message string, changes map[string]*string) error {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	fields := [][2]string{
+		{"message", message},
+		{"branch", newBranch},
+		{"parents", baseCommit},
+	}
+	for path, content := range changes {
+		if content == nil {
+			// "files" can be repeated, listing a path there without content deletes it
+			fields = append(fields, [2]string{"files", strings.TrimPrefix(path, "/")})
+		} else {
+			fields = append(fields, [2]string{"/" + strings.TrimPrefix(path, "/"), *content})
+		}
+	}
+	for _, f := range fields {
+		err := w.WriteField(f[0], f[1])
+		if err != nil {
+			return err
+		}
+	}
+	err := w.Close()
+	if err != nil {
+		return err
+	}
+	_, err = p.do(ctx, http.MethodPost, p.repoURL(repo)+"/src", w.FormDataContentType(), body)
+	return err
+}
+
+// commitFilesServer creates the branch and commits each file separately, Bitbucket Server REST API doesn't support multi file commits or file deletions
+func (p *Provider) commitFilesServer(ctx context.Context, repo scm.Repo, baseCommit string, newBranch string, message string, changes map[string]*string) error {
+	branchURL := fmt.Sprintf("%s/rest/branch-utils/1.0/projects/%s/repos/%s/branches", p.serverURL, url.PathEscape(repo.Owner), url.PathEscape(repo.Name))
+	err := p.doJSON(ctx, http.MethodPost, branchURL, map[string]string{"name": newBranch, "startPoint": baseCommit}, nil)
+	if err != nil {
+		return fmt.Errorf("create branch %s: %w", newBranch, err)
+	}
+
+	paths := make([]string, 0, len(changes))
+	for path := range changes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	sourceCommit := baseCommit
+	for _, path := range paths {
+		content := changes[path]
+		if content == nil {
+			log.Warnf("Bitbucket Server doesn't support file deletion via REST API, skipping deletion of %s", path)
+			continue
+		}
+		body := &bytes.Buffer{}
+		w := multipart.NewWriter(body)
+		fields := map[string]string{
+			"content": *content,
+			"message": message,
+			"branch":  newBranch,
+		}
+		// sourceCommitId is required when updating an existing file and rejected when creating a new one
+		_, err := p.GetFileContent(ctx, repo, sourceCommit, path)
+		if err == nil {
+			fields["sourceCommitId"] = sourceCommit
+		}
+		for k, v := range fields {
+			err := w.WriteField(k, v)
+			if err != nil {
+				return err
+			}
+		}
+		err = w.Close()
+		if err != nil {
+			return err
+		}
+		respBody, err := p.do(ctx, http.MethodPut, fmt.Sprintf("%s/browse/%s", p.repoURL(repo), escapePath(path)), w.FormDataContentType(), body)
+		if err != nil {
+			return fmt.Errorf("commit %s: %w", path, err)
+		}
+		var commit struct {
+			ID string `json:"id"`
+		}
+		if json.Unmarshal(respBody, &commit) == nil && commit.ID != "" {
+			sourceCommit = commit.ID
+		}
+	}
+	return nil
+}
+
+func (p *Provider) CreatePr(ctx context.Context, repo scm.Repo, title string, body string, sourceBranch string, targetBranch string) (int, error) {
+	var pr struct {
+		ID int `json:"id"`
+	}
+	var err error
+	if p.isCloud() {
+		err = p.doJSON(ctx, http.MethodPost, p.repoURL(repo)+"/pullrequests", map[string]interface{}{
+			"title":               title,
+			"description":         body,
+			"source":              map[string]interface{}{"branch": map[string]string{"name": sourceBranch}},
+			"destination":         map[string]interface{}{"branch": map[string]string{"name": targetBranch}},
+			"close_source_branch": true,
+		}, &pr)
+	} else {
+		err = p.doJSON(ctx, http.MethodPost, p.repoURL(repo)+"/pull-requests", map[string]interface{}{
+			"title":       title,
+			"description": body,
+			"fromRef":     map[string]string{"id": "refs/heads/" + sourceBranch},
+			"toRef":       map[string]string{"id": "refs/heads/" + targetBranch},
+		}, &pr)
+	}
+	return pr.ID, err
+}
+
+func (p *Provider) CommentOnPr(ctx context.Context, repo scm.Repo, prNumber int, body string) error {
+	if p.isCloud() {
+		return p.doJSON(ctx, http.MethodPost, fmt.Sprintf("%s/pullrequests/%d/comments", p.repoURL(repo), prNumber), map[string]interface{}{"content": map[string]string{"raw": body}}, nil)
+	}
+	return p.doJSON(ctx, http.MethodPost, fmt.Sprintf("%s/pull-requests/%d/comments", p.repoURL(repo), prNumber), map[string]string{"text": body}, nil)
+}
+
+func (p *Provider) MergePr(ctx context.Context, repo scm.Repo, prNumber int) error {
+	if p.isCloud() {
+		return p.doJSON(ctx, http.MethodPost, fmt.Sprintf("%s/pullrequests/%d/merge", p.repoURL(repo), prNumber), map[string]interface{}{"close_source_branch": true}, nil)
+	}
+	// Bitbucket Server uses optimistic locking, the current PR version is required for the merge
+	var pr struct {
+		Version int `json:"version"`
+	}
+	prURL := fmt.Sprintf("%s/pull-requests/%d", p.repoURL(repo), prNumber)
+	err := p.doJSON(ctx, http.MethodGet, prURL, nil, &pr)
+	if err != nil {
+		return err
+	}
+	return p.doJSON(ctx, http.MethodPost, prURL+"/merge?version="+strconv.Itoa(pr.Version), nil, nil)
+}
@@ -0,0 +1,169 @@
+package bitbucket
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/scm"
+)
+
+// Both Bitbucket Cloud and Server send the event type in this header, this is used to detect Bitbucket webhooks sent to the generic /webhook endpoint
+const EventKeyHeader = "X-Event-Key"
+
+var eventTypes = map[string]scm.EventType{
+	// Bitbucket Cloud
+	"pullrequest:created":         scm.PrChangedEvent,
+	"pullrequest:updated":         scm.PrChangedEvent,
+	"pullrequest:fulfilled":       scm.PrMergedEvent,
+	"pullrequest:rejected":        scm.PrClosedEvent,
+	"pullrequest:comment_created": scm.PrCommentEvent,
+	// Bitbucket Server
+	"pr:opened":           scm.PrChangedEvent,
+	"pr:from_ref_updated": scm.PrChangedEvent,
+	"pr:modified":         scm.PrChangedEvent,
+	"pr:merged":           scm.PrMergedEvent,
+	"pr:declined":         scm.PrClosedEvent,
+	"pr:deleted":          scm.PrClosedEvent,
+	"pr:comment:added":    scm.PrCommentEvent,
+}
+
+type cloudPayload struct {
+	PullRequest struct {
+		ID          int    `json:"id"`
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Author      struct {
+			Nickname string `json:"nickname"`
+		} `json:"author"`
+		Source struct {
+			Branch struct {
+				Name string `json:"name"`
+			} `json:"branch"`
+		} `json:"source"`
+		Destination struct {
+			Branch struct {
+				Name string `json:"name"`
+			} `json:"branch"`
+		} `json:"destination"`
+	} `json:"pullrequest"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	Comment struct {
+		Content struct {
+			Raw string `json:"raw"`
+		} `json:"content"`
+	} `json:"comment"`
+}
+
+type serverRef struct {
+	DisplayID  string `json:"displayId"`
+	Repository struct {
+		Slug    string `json:"slug"`
+		Project struct {
+			Key string `json:"key"`
+		} `json:"project"`
+	} `json:"repository"`
+}
+
+type serverPayload struct {
+	PullRequest struct {
+		ID          int    `json:"id"`
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Author      struct {
+			User struct {
+				Name string `json:"name"`
+			} `json:"user"`
+		} `json:"author"`
+		FromRef serverRef `json:"fromRef"`
+		ToRef   serverRef `json:"toRef"`
+	} `json:"pullRequest"`
+	Comment struct {
+		Text string `json:"text"`
+	} `json:"comment"`
+}
+
+// validateSignature checks the X-Hub-Signature header, both Bitbucket flavors use the same "sha256=<hex HMAC>" format
+func validateSignature(r *http.Request, payload []byte, secret []byte) error {
+	if len(secret) == 0 {
+		return errors.New("no Bitbucket webhook secret is configured, refusing to accept unsigned webhooks")
+	}
+	signature := r.Header.Get("X-Hub-Signature")
+	hexSignature, found := strings.CutPrefix(signature, "sha256=")
+	if !found {
+		return errors.New("missing or unsupported X-Hub-Signature header")
+	}
+	receivedMAC, err := hex.DecodeString(hexSignature)
+	if err != nil {
+		return fmt.Errorf("decode X-Hub-Signature: %w", err)
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	if !hmac.Equal(receivedMAC, mac.Sum(nil)) {
+		return errors.New("payload signature check failed")
+	}
+	return nil
+}
+
+func (p *Provider) ParseWebhook(r *http.Request, payload []byte) (*scm.Event, error) {
+	err := validateSignature(r, payload, p.webhookSecret)
+	if err != nil {
+		return nil, err
+	}
+	eventKey := r.Header.Get(EventKeyHeader)
+	eventType, ok := eventTypes[eventKey]
+	if !ok {
+		return nil, fmt.Errorf("event %s: %w", eventKey, scm.ErrIgnoredEvent)
+	}
+
+	event := &scm.Event{
+		Type:       eventType,
+		DeliveryID: r.Header.Get("X-Request-UUID"), // Bitbucket Cloud
+	}
+	if event.DeliveryID == "" {
+		event.DeliveryID = r.Header.Get("X-Request-Id") // Bitbucket Server
+	}
+
+	if strings.HasPrefix(eventKey, "pullrequest:") {
+		var cp cloudPayload
+		err = json.Unmarshal(payload, &cp)
+		if err != nil {
+			return nil, fmt.Errorf("parse Bitbucket Cloud payload: %w", err)
+		}
+		owner, name, found := strings.Cut(cp.Repository.FullName, "/")
+		if !found {
+			return nil, fmt.Errorf("unexpected repository full name %q", cp.Repository.FullName)
+		}
+		event.Repo = scm.Repo{Owner: owner, Name: name}
+		event.PrNumber = cp.PullRequest.ID
+		event.PrTitle = cp.PullRequest.Title
+		event.PrBody = cp.PullRequest.Description
+		event.PrAuthor = cp.PullRequest.Author.Nickname
+		event.SourceBranch = cp.PullRequest.Source.Branch.Name
+		event.TargetBranch = cp.PullRequest.Destination.Branch.Name
+		event.CommentBody = cp.Comment.Content.Raw
+		return event, nil
+	}
+
+	var sp serverPayload
+	err = json.Unmarshal(payload, &sp)
+	if err != nil {
+		return nil, fmt.Errorf("parse Bitbucket Server payload: %w", err)
+	}
+	event.Repo = scm.Repo{Owner: sp.PullRequest.ToRef.Repository.Project.Key, Name: sp.PullRequest.ToRef.Repository.Slug}
+	event.PrNumber = sp.PullRequest.ID
+	event.PrTitle = sp.PullRequest.Title
+	event.PrBody = sp.PullRequest.Description
+	event.PrAuthor = sp.PullRequest.Author.User.Name
+	event.SourceBranch = sp.PullRequest.FromRef.DisplayID
+	event.TargetBranch = sp.PullRequest.ToRef.DisplayID
+	event.CommentBody = sp.Comment.Text
+	return event, nil
+}
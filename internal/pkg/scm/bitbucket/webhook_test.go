@@ -0,0 +1,135 @@
+package bitbucket
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/scm"
+)
+
+const cloudMergedPayload = `{
+  "pullrequest": {
+    "id": 12,
+    "title": "Bump foo",
+    "description": "some description",
+    "author": {"nickname": "alice"},
+    "source": {"branch": {"name": "feature/foo"}},
+    "destination": {"branch": {"name": "main"}}
+  },
+  "repository": {"full_name": "my-workspace/gitops"}
+}`
+
+const serverMergedPayload = `{
+  "eventKey": "pr:merged",
+  "pullRequest": {
+    "id": 7,
+    "title": "Bump foo",
+    "description": "some description",
+    "author": {"user": {"name": "bob"}},
+    "fromRef": {"displayId": "feature/foo", "repository": {"slug": "gitops", "project": {"key": "OPS"}}},
+    "toRef": {"displayId": "main", "repository": {"slug": "gitops", "project": {"key": "OPS"}}}
+  }
+}`
+
+func sign(payload string, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestParseWebhook(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		eventKey      string
+		payload       string
+		signature     string
+		expectedEvent *scm.Event
+		expectedErr   error
+	}{
+		"Cloud merged PR": {
+			eventKey:  "pullrequest:fulfilled",
+			payload:   cloudMergedPayload,
+			signature: sign(cloudMergedPayload, "secret"),
+			expectedEvent: &scm.Event{
+				Type:         scm.PrMergedEvent,
+				Repo:         scm.Repo{Owner: "my-workspace", Name: "gitops"},
+				PrNumber:     12,
+				PrTitle:      "Bump foo",
+				PrBody:       "some description",
+				PrAuthor:     "alice",
+				SourceBranch: "feature/foo",
+				TargetBranch: "main",
+				DeliveryID:   "delivery-1",
+			},
+		},
+		"Server merged PR": {
+			eventKey:  "pr:merged",
+			payload:   serverMergedPayload,
+			signature: sign(serverMergedPayload, "secret"),
+			expectedEvent: &scm.Event{
+				Type:         scm.PrMergedEvent,
+				Repo:         scm.Repo{Owner: "OPS", Name: "gitops"},
+				PrNumber:     7,
+				PrTitle:      "Bump foo",
+				PrBody:       "some description",
+				PrAuthor:     "bob",
+				SourceBranch: "feature/foo",
+				TargetBranch: "main",
+				DeliveryID:   "delivery-1",
+			},
+		},
+		"Unhandled event is ignored": {
+			eventKey:    "diagnostics:ping",
+			payload:     "{}",
+			signature:   sign("{}", "secret"),
+			expectedErr: scm.ErrIgnoredEvent,
+		},
+	}
+	p := New("", "", "token", "secret")
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			r := httptest.NewRequest(http.MethodPost, "/webhook/bitbucket", strings.NewReader(tc.payload))
+			r.Header.Set(EventKeyHeader, tc.eventKey)
+			r.Header.Set("X-Hub-Signature", tc.signature)
+			r.Header.Set("X-Request-UUID", "delivery-1")
+			event, err := p.ParseWebhook(r, []byte(tc.payload))
+			if !errors.Is(err, tc.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, err)
+			}
+			if diff := deep.Equal(tc.expectedEvent, event); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestParseWebhookBadSignature(t *testing.T) {
+	t.Parallel()
+	p := New("", "", "token", "secret")
+	r := httptest.NewRequest(http.MethodPost, "/webhook/bitbucket", strings.NewReader(cloudMergedPayload))
+	r.Header.Set(EventKeyHeader, "pullrequest:fulfilled")
+	r.Header.Set("X-Hub-Signature", sign(cloudMergedPayload, "wrong-secret"))
+	_, err := p.ParseWebhook(r, []byte(cloudMergedPayload))
+	if err == nil {
+		t.Fatal("expected signature validation to fail")
+	}
+}
+
+func TestParseWebhookWithoutSecret(t *testing.T) {
+	t.Parallel()
+	p := New("", "", "token", "")
+	r := httptest.NewRequest(http.MethodPost, "/webhook/bitbucket", strings.NewReader(cloudMergedPayload))
+	r.Header.Set(EventKeyHeader, "pullrequest:fulfilled")
+	_, err := p.ParseWebhook(r, []byte(cloudMergedPayload))
+	if err == nil {
+		t.Fatal("expected unsigned webhooks to be rejected when no secret is configured")
+	}
+}
@@ -0,0 +1,259 @@
+// Package gitea implements the scm.Provider interface for Gitea(and API compatible forks like Forgejo)
+package gitea
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/scm"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/secrets"
+)
+
+type Provider struct {
+	apiURL        string
+	token         string
+	webhookSecret []byte
+	httpClient    *http.Client
+}
+
+func New(serverURL string, token string, webhookSecret string) *Provider {
+	return &Provider{
+		apiURL:        strings.TrimSuffix(serverURL, "/") + "/api/v1",
+		token:         token,
+		webhookSecret: []byte(webhookSecret),
+		httpClient:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// NewFromEnv creates a Provider based on the GITEA_* environment variables(or any other secret source), it returns nil if GITEA_URL or GITEA_TOKEN are not set.
+// GITEA_WEBHOOK_SECRET is required as unsigned webhooks are rejected, without it the provider stays disabled.
+func NewFromEnv() *Provider {
+	serverURL, urlOk := secrets.Lookup("GITEA_URL")
+	token, tokenOk := secrets.Lookup("GITEA_TOKEN")
+	if !urlOk || !tokenOk {
+		return nil
+	}
+	webhookSecret := secrets.Get("GITEA_WEBHOOK_SECRET", "")
+	if webhookSecret == "" {
+		log.Errorf("GITEA_URL and GITEA_TOKEN are set but GITEA_WEBHOOK_SECRET isn't, Gitea support is disabled")
+		return nil
+	}
+	return New(serverURL, token, webhookSecret)
+}
+
+func (p *Provider) Name() string {
+	return "gitea"
+}
+
+func (p *Provider) repoURL(repo scm.Repo) string {
+	return fmt.Sprintf("%s/repos/%s/%s", p.apiURL, url.PathEscape(repo.Owner), url.PathEscape(repo.Name))
+}
+
+func escapePath(filePath string) string {
+	parts := strings.Split(strings.Trim(filePath, "/"), "/")
+	for i := range parts {
+		parts[i] = url.PathEscape(parts[i])
+	}
+	return strings.Join(parts, "/")
+}
+
+func (p *Provider) do(ctx context.Context, method string, reqURL string, in interface{}) ([]byte, error) {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "token "+p.token)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%s %s: %w", method, reqURL, scm.ErrNotFound)
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s %s: unexpected status %d: %s", method, reqURL, resp.StatusCode, string(respBody))
+	}
+	return respBody, nil
+}
+
+func (p *Provider) doJSON(ctx context.Context, method string, reqURL string, in interface{}, out interface{}) error {
+	respBody, err := p.do(ctx, method, reqURL, in)
+	if err != nil {
+		return err
+	}
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+	return json.Unmarshal(respBody, out)
+}
+
+func (p *Provider) GetFileContent(ctx context.Context, repo scm.Repo, ref string, filePath string) ([]byte, error) {
+	return p.do(ctx, http.MethodGet, fmt.Sprintf("%s/raw/%s?ref=%s", p.repoURL(repo), escapePath(filePath), url.QueryEscape(ref)), nil)
+}
+
+func (p *Provider) ListPrFiles(ctx context.Context, repo scm.Repo, prNumber int) ([]string, error) {
+	files := []string{}
+	for page := 1; ; page++ {
+		var prFiles []struct {
+			Filename string `json:"filename"`
+		}
+		err := p.doJSON(ctx, http.MethodGet, fmt.Sprintf("%s/pulls/%d/files?page=%d&limit=50", p.repoURL(repo), prNumber, page), nil, &prFiles)
+		if err != nil {
+			return nil, err
+		}
+		if len(prFiles) == 0 {
+			return files, nil
+		}
+		for _, f := range prFiles {
+			files = append(files, f.Filename)
+		}
+	}
+}
+
+type contentEntry struct {
+	Type string `json:"type"`
+	Path string `json:"path"`
+	SHA  string `json:"sha"`
+}
+
+// listDir returns the directory entries, the contents API returns a single object(not a list) when the path is a file
+func (p *Provider) listDir(ctx context.Context, repo scm.Repo, ref string, dirPath string) ([]contentEntry, error) {
+	respBody, err := p.do(ctx, http.MethodGet, fmt.Sprintf("%s/contents/%s?ref=%s", p.repoURL(repo), escapePath(dirPath), url.QueryEscape(ref)), nil)
+	if err != nil {
+		return nil, err
+	}
+	var entries []contentEntry
+	err = json.Unmarshal(respBody, &entries)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a directory: %w", dirPath, err)
+	}
+	return entries, nil
+}
+
+func (p *Provider) ListFiles(ctx context.Context, repo scm.Repo, ref string, dirPath string) ([]string, error) {
+	dirPath = strings.Trim(dirPath, "/")
+	files := []string{}
+	dirs := []string{dirPath}
+	for len(dirs) > 0 {
+		dir := dirs[0]
+		dirs = dirs[1:]
+		entries, err := p.listDir(ctx, repo, ref, dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			switch e.Type {
+			case "dir":
+				dirs = append(dirs, e.Path)
+			case "file", "symlink":
+				files = append(files, strings.TrimPrefix(e.Path, dirPath+"/"))
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// fileSHA returns the blob SHA of an existing file, the change files API requires it for updates and deletions
+func (p *Provider) fileSHA(ctx context.Context, repo scm.Repo, ref string, filePath string) (string, error) {
+	var entry contentEntry
+	err := p.doJSON(ctx, http.MethodGet, fmt.Sprintf("%s/contents/%s?ref=%s", p.repoURL(repo), escapePath(filePath), url.QueryEscape(ref)), nil, &entry)
+	if err != nil {
+		return "", err
+	}
+	return entry.SHA, nil
+}
+
+type changeFileOperation struct {
+	Operation string `json:"operation"`
+	Path      string `json:"path"`
+	Content   string `json:"content,omitempty"`
+	SHA       string `json:"sha,omitempty"`
+}
+
+// CommitFiles uses the "change files" API(Gitea 1.20+) that creates the new branch with a single commit
+func (p *Provider) CommitFiles(ctx context.Context, repo scm.Repo, baseBranch string, newBranch string, message string, changes map[string]*string) error {
+	paths := make([]string, 0, len(changes))
+	for path := range changes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	files := []changeFileOperation{}
+	for _, path := range paths {
+		content := changes[path]
+		sha, err := p.fileSHA(ctx, repo, baseBranch, path)
+		if err != nil && !errors.Is(err, scm.ErrNotFound) {
+			return err
+		}
+		switch {
+		case content == nil && sha == "":
+			continue // Nothing to delete
+		case content == nil:
+			files = append(files, changeFileOperation{Operation: "delete", Path: path, SHA: sha})
+		case sha == "":
+			files = append(files, changeFileOperation{Operation: "create", Path: path, Content: base64.StdEncoding.EncodeToString([]byte(*content))})
+		default:
+			files = append(files, changeFileOperation{Operation: "update", Path: path, SHA: sha, Content: base64.StdEncoding.EncodeToString([]byte(*content))})
+		}
+	}
+
+	return p.doJSON(ctx, http.MethodPost, p.repoURL(repo)+"/contents", map[string]interface{}{
+		"branch":     baseBranch,
+		"new_branch": newBranch,
+		"message":    message,
+		"files":      files,
+	}, nil)
+}
+
+func (p *Provider) CreatePr(ctx context.Context, repo scm.Repo, title string, body string, sourceBranch string, targetBranch string) (int, error) {
+	var pr struct {
+		Number int `json:"number"`
+	}
+	err := p.doJSON(ctx, http.MethodPost, p.repoURL(repo)+"/pulls", map[string]string{
+		"title": title,
+		"body":  body,
+		"head":  sourceBranch,
+		"base":  targetBranch,
+	}, &pr)
+	return pr.Number, err
+}
+
+func (p *Provider) CommentOnPr(ctx context.Context, repo scm.Repo, prNumber int, body string) error {
+	return p.doJSON(ctx, http.MethodPost, fmt.Sprintf("%s/issues/%d/comments", p.repoURL(repo), prNumber), map[string]string{"body": body}, nil)
+}
+
+func (p *Provider) MergePr(ctx context.Context, repo scm.Repo, prNumber int) error {
+	return p.doJSON(ctx, http.MethodPost, fmt.Sprintf("%s/pulls/%d/merge", p.repoURL(repo), prNumber), map[string]interface{}{
+		"Do":                        "merge",
+		"delete_branch_after_merge": true,
+	}, nil)
+}
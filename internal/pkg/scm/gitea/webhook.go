@@ -0,0 +1,137 @@
+package gitea
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/scm"
+)
+
+// Gitea sends the event type in this header(and in X-GitHub-Event for compatibility), Forgejo sends it too alongside X-Forgejo-Event
+const EventHeader = "X-Gitea-Event"
+
+type user struct {
+	Login string `json:"login"`
+}
+
+type webhookPayload struct {
+	Action      string `json:"action"`
+	PullRequest *struct {
+		Number int    `json:"number"`
+		Title  string `json:"title"`
+		Body   string `json:"body"`
+		User   user   `json:"user"`
+		Merged bool   `json:"merged"`
+		Head   struct {
+			Ref string `json:"ref"`
+		} `json:"head"`
+		Base struct {
+			Ref string `json:"ref"`
+		} `json:"base"`
+		Labels []struct {
+			Name string `json:"name"`
+		} `json:"labels"`
+	} `json:"pull_request"`
+	Issue *struct {
+		Number      int             `json:"number"`
+		Title       string          `json:"title"`
+		Body        string          `json:"body"`
+		User        user            `json:"user"`
+		PullRequest json.RawMessage `json:"pull_request"`
+	} `json:"issue"`
+	Comment struct {
+		Body string `json:"body"`
+	} `json:"comment"`
+	Repository struct {
+		Name  string `json:"name"`
+		Owner user   `json:"owner"`
+	} `json:"repository"`
+}
+
+// validateSignature checks the X-Gitea-Signature header, a hex encoded HMAC-SHA256 of the payload
+func validateSignature(r *http.Request, payload []byte, secret []byte) error {
+	if len(secret) == 0 {
+		return errors.New("no Gitea webhook secret is configured, refusing to accept unsigned webhooks")
+	}
+	signature := r.Header.Get("X-Gitea-Signature")
+	if signature == "" {
+		return errors.New("missing X-Gitea-Signature header")
+	}
+	receivedMAC, err := hex.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("decode X-Gitea-Signature: %w", err)
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	if !hmac.Equal(receivedMAC, mac.Sum(nil)) {
+		return errors.New("payload signature check failed")
+	}
+	return nil
+}
+
+func (p *Provider) ParseWebhook(r *http.Request, payload []byte) (*scm.Event, error) {
+	err := validateSignature(r, payload, p.webhookSecret)
+	if err != nil {
+		return nil, err
+	}
+	var wp webhookPayload
+	err = json.Unmarshal(payload, &wp)
+	if err != nil {
+		return nil, fmt.Errorf("parse Gitea payload: %w", err)
+	}
+
+	event := &scm.Event{
+		Repo:       scm.Repo{Owner: wp.Repository.Owner.Login, Name: wp.Repository.Name},
+		DeliveryID: r.Header.Get("X-Gitea-Delivery"),
+	}
+
+	eventType := r.Header.Get(EventHeader)
+	switch eventType {
+	case "pull_request":
+		if wp.PullRequest == nil {
+			return nil, errors.New("pull_request event without a pull_request object")
+		}
+		switch wp.Action {
+		case "opened", "reopened", "synchronized", "edited", "label_updated":
+			event.Type = scm.PrChangedEvent
+		case "closed":
+			event.Type = scm.PrClosedEvent
+			if wp.PullRequest.Merged {
+				event.Type = scm.PrMergedEvent
+			}
+		default:
+			return nil, fmt.Errorf("pull_request action %s: %w", wp.Action, scm.ErrIgnoredEvent)
+		}
+		event.PrNumber = wp.PullRequest.Number
+		event.PrTitle = wp.PullRequest.Title
+		event.PrBody = wp.PullRequest.Body
+		event.PrAuthor = wp.PullRequest.User.Login
+		event.SourceBranch = wp.PullRequest.Head.Ref
+		event.TargetBranch = wp.PullRequest.Base.Ref
+		for _, l := range wp.PullRequest.Labels {
+			event.Labels = append(event.Labels, l.Name)
+		}
+	case "issue_comment":
+		// Gitea sends PR comments as issue comments, PRs are the issues with a pull_request object
+		if wp.Issue == nil || len(wp.Issue.PullRequest) == 0 || string(wp.Issue.PullRequest) == "null" {
+			return nil, fmt.Errorf("comment on a non PR issue: %w", scm.ErrIgnoredEvent)
+		}
+		if wp.Action != "created" {
+			return nil, fmt.Errorf("issue_comment action %s: %w", wp.Action, scm.ErrIgnoredEvent)
+		}
+		event.Type = scm.PrCommentEvent
+		event.PrNumber = wp.Issue.Number
+		event.PrTitle = wp.Issue.Title
+		event.PrBody = wp.Issue.Body
+		event.PrAuthor = wp.Issue.User.Login
+		event.CommentBody = wp.Comment.Body
+	default:
+		return nil, fmt.Errorf("event %s: %w", eventType, scm.ErrIgnoredEvent)
+	}
+	return event, nil
+}
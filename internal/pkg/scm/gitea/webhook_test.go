@@ -0,0 +1,148 @@
+package gitea
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/scm"
+)
+
+const mergedPayload = `{
+  "action": "closed",
+  "number": 3,
+  "pull_request": {
+    "number": 3,
+    "title": "Bump foo",
+    "body": "some description",
+    "user": {"login": "alice"},
+    "merged": true,
+    "head": {"ref": "feature/foo"},
+    "base": {"ref": "main"},
+    "labels": [{"name": "promote"}]
+  },
+  "repository": {"name": "gitops", "owner": {"login": "ops"}}
+}`
+
+const closedPayload = `{
+  "action": "closed",
+  "pull_request": {"number": 4, "merged": false, "head": {"ref": "feature/bar"}, "base": {"ref": "main"}, "user": {"login": "bob"}},
+  "repository": {"name": "gitops", "owner": {"login": "ops"}}
+}`
+
+const issueCommentPayload = `{
+  "action": "created",
+  "issue": {"number": 5, "title": "Not a PR", "user": {"login": "bob"}, "pull_request": null},
+  "comment": {"body": "hello"},
+  "repository": {"name": "gitops", "owner": {"login": "ops"}}
+}`
+
+func sign(payload string, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestParseWebhook(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		eventType     string
+		payload       string
+		expectedEvent *scm.Event
+		expectedErr   error
+	}{
+		"Merged PR": {
+			eventType: "pull_request",
+			payload:   mergedPayload,
+			expectedEvent: &scm.Event{
+				Type:         scm.PrMergedEvent,
+				Repo:         scm.Repo{Owner: "ops", Name: "gitops"},
+				PrNumber:     3,
+				PrTitle:      "Bump foo",
+				PrBody:       "some description",
+				PrAuthor:     "alice",
+				SourceBranch: "feature/foo",
+				TargetBranch: "main",
+				Labels:       []string{"promote"},
+				DeliveryID:   "delivery-1",
+			},
+		},
+		"Closed without merge": {
+			eventType: "pull_request",
+			payload:   closedPayload,
+			expectedEvent: &scm.Event{
+				Type:         scm.PrClosedEvent,
+				Repo:         scm.Repo{Owner: "ops", Name: "gitops"},
+				PrNumber:     4,
+				PrAuthor:     "bob",
+				SourceBranch: "feature/bar",
+				TargetBranch: "main",
+				DeliveryID:   "delivery-1",
+			},
+		},
+		"Comment on a non PR issue is ignored": {
+			eventType:   "issue_comment",
+			payload:     issueCommentPayload,
+			expectedErr: scm.ErrIgnoredEvent,
+		},
+		"Push event is ignored": {
+			eventType:   "push",
+			payload:     "{}",
+			expectedErr: scm.ErrIgnoredEvent,
+		},
+	}
+	p := New("https://gitea.example.com", "token", "secret")
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			r := httptest.NewRequest(http.MethodPost, "/webhook/gitea", strings.NewReader(tc.payload))
+			r.Header.Set(EventHeader, tc.eventType)
+			r.Header.Set("X-Gitea-Signature", sign(tc.payload, "secret"))
+			r.Header.Set("X-Gitea-Delivery", "delivery-1")
+			event, err := p.ParseWebhook(r, []byte(tc.payload))
+			if !errors.Is(err, tc.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, err)
+			}
+			if diff := deep.Equal(tc.expectedEvent, event); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestParseWebhookBadSignature(t *testing.T) {
+	t.Parallel()
+	p := New("https://gitea.example.com", "token", "secret")
+	r := httptest.NewRequest(http.MethodPost, "/webhook/gitea", strings.NewReader(mergedPayload))
+	r.Header.Set(EventHeader, "pull_request")
+	r.Header.Set("X-Gitea-Signature", sign(mergedPayload, "wrong-secret"))
+	_, err := p.ParseWebhook(r, []byte(mergedPayload))
+	if err == nil {
+		t.Fatal("expected signature validation to fail")
+	}
+}
+
+func TestParseWebhookUnsigned(t *testing.T) {
+	t.Parallel()
+	tests := map[string]*Provider{
+		"Missing signature":    New("https://gitea.example.com", "token", "secret"),
+		"No configured secret": New("https://gitea.example.com", "token", ""),
+	}
+	for name, p := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			r := httptest.NewRequest(http.MethodPost, "/webhook/gitea", strings.NewReader(mergedPayload))
+			r.Header.Set(EventHeader, "pull_request")
+			_, err := p.ParseWebhook(r, []byte(mergedPayload))
+			if err == nil {
+				t.Fatal("expected unsigned webhook to be rejected")
+			}
+		})
+	}
+}
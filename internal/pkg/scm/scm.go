@@ -0,0 +1,273 @@
+// Package scm holds the SCM agnostic flavor of the Telefonistka promotion flow, it is used by the non GitHub providers(Bitbucket, Gitea...).
+// The GitHub flow still lives in the githubapi package as it uses GitHub specific features(low level tree API, GraphQL, commit statuses...)
+package scm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	cfg "github.com/wayfair-incubator/telefonistka/internal/pkg/configuration"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/githubapi"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/inflight"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/logging"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ErrNotFound should be returned(wrapped) by providers when a file or directory doesn't exist
+var ErrNotFound = errors.New("not found")
+
+// ErrIgnoredEvent is returned by ParseWebhook for valid events Telefonistka doesn't act on
+var ErrIgnoredEvent = errors.New("ignored event")
+
+type EventType string
+
+const (
+	PrChangedEvent EventType = "pr_changed"
+	PrMergedEvent  EventType = "pr_merged"
+	PrClosedEvent  EventType = "pr_closed"
+	PrCommentEvent EventType = "pr_comment"
+)
+
+type Repo struct {
+	Owner string
+	Name  string
+}
+
+func (r Repo) String() string {
+	return r.Owner + "/" + r.Name
+}
+
+// Event is the provider independent representation of a webhook event
+type Event struct {
+	Type         EventType
+	Repo         Repo
+	PrNumber     int
+	PrTitle      string
+	PrBody       string
+	PrAuthor     string
+	SourceBranch string
+	TargetBranch string
+	Labels       []string
+	CommentBody  string
+	DeliveryID   string
+}
+
+// Provider is implemented by each supported SCM
+type Provider interface {
+	Name() string
+	// ParseWebhook validates the webhook signature and converts the payload to an Event
+	ParseWebhook(r *http.Request, payload []byte) (*Event, error)
+	GetFileContent(ctx context.Context, repo Repo, ref string, filePath string) ([]byte, error)
+	ListPrFiles(ctx context.Context, repo Repo, prNumber int) ([]string, error)
+	// ListFiles returns all files under dirPath(recursively), relative to dirPath
+	ListFiles(ctx context.Context, repo Repo, ref string, dirPath string) ([]string, error)
+	// CommitFiles creates newBranch from baseBranch with a single commit, a nil content marks a deletion
+	CommitFiles(ctx context.Context, repo Repo, baseBranch string, newBranch string, message string, changes map[string]*string) error
+	CreatePr(ctx context.Context, repo Repo, title string, body string, sourceBranch string, targetBranch string) (int, error)
+	CommentOnPr(ctx context.Context, repo Repo, prNumber int, body string) error
+	MergePr(ctx context.Context, repo Repo, prNumber int) error
+}
+
+// ReceiveWebhook parses the webhook with the provided provider and starts a thread to handle the event, like githubapi.ReciveWebhook success/failure are dependant on the payload parsing only
+func ReceiveWebhook(p Provider, r *http.Request) error {
+	payload, err := io.ReadAll(r.Body)
+	if err != nil {
+		prom.InstrumentWebhookHit("validation_failed")
+		return fmt.Errorf("read request body: %w", err)
+	}
+	event, err := p.ParseWebhook(r, payload)
+	if errors.Is(err, ErrIgnoredEvent) {
+		log.Debugf("Ignoring %s webhook: %v", p.Name(), err)
+		prom.InstrumentWebhookHit("successful")
+		return nil
+	} else if err != nil {
+		log.Errorf("could not parse %s webhook: err=%s\n", p.Name(), err)
+		prom.InstrumentWebhookHit("parsing_failed")
+		return err
+	}
+	prom.InstrumentWebhookHit("successful")
+
+	go func() {
+		// Same as the GitHub flow, we don't use the request context as it might have a short deadline
+		ctx, cancel := inflight.WithEventTimeout(context.Background(), p.Name(), string(event.Type), event.Repo.String())
+		defer cancel()
+		defer inflight.Track(p.Name(), string(event.Type), event.Repo.String())()
+		err := HandleEvent(ctx, p, event)
+		if err != nil {
+			log.Errorf("Failed to handle %s event: err=%s", p.Name(), err)
+		}
+	}()
+	return nil
+}
+
+// HandleEvent runs the promotion flow for merged PRs, other events are currently ignored
+func HandleEvent(ctx context.Context, p Provider, event *Event) error {
+	logger := logging.ForRepo(event.Repo.String()).WithFields(log.Fields{
+		"provider":    p.Name(),
+		"prNumber":    event.PrNumber,
+		"delivery_id": event.DeliveryID,
+	})
+	if event.Type != PrMergedEvent {
+		logger.Debugf("Not handling %s event", event.Type)
+		return nil
+	}
+	return handleMergedPr(ctx, p, event, logger)
+}
+
+func getInRepoConfig(ctx context.Context, p Provider, repo Repo, branch string) (*cfg.Config, error) {
+	content, err := p.GetFileContent(ctx, repo, branch, "telefonistka.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("get in-repo configuration: %w", err)
+	}
+	return cfg.ParseConfigFromYaml(string(content))
+}
+
+func componentConfigGetter(ctx context.Context, p Provider, repo Repo, branch string) githubapi.ComponentConfigGetter {
+	return func(componentPath string) (*cfg.ComponentConfig, error) {
+		componentConfig := &cfg.ComponentConfig{}
+		content, err := p.GetFileContent(ctx, repo, branch, componentPath+"/telefonistka.yaml")
+		if errors.Is(err, ErrNotFound) { // The file is optional
+			return componentConfig, nil
+		} else if err != nil {
+			return nil, err
+		}
+		err = yaml.Unmarshal(content, componentConfig)
+		if err != nil {
+			return nil, err
+		}
+		return componentConfig, nil
+	}
+}
+
+func handleMergedPr(ctx context.Context, p Provider, event *Event, logger *log.Entry) error {
+	// The PR is merged, so the target branch holds both the configuration and the promoted content
+	branch := event.TargetBranch
+	config, err := getInRepoConfig(ctx, p, event.Repo, branch)
+	if err != nil {
+		_ = p.CommentOnPr(ctx, event.Repo, event.PrNumber, fmt.Sprintf("Failed to get configuration\n```\n%s\n```\n", err))
+		return err
+	}
+
+	prFiles, err := p.ListPrFiles(ctx, event.Repo, event.PrNumber)
+	if err != nil {
+		return fmt.Errorf("list PR files: %w", err)
+	}
+
+	promotions := githubapi.GeneratePromotionPlanForFiles(logger, config, prFiles, event.Labels, componentConfigGetter(ctx, p, event.Repo, branch))
+
+	if config.DryRunMode {
+		return p.CommentOnPr(ctx, event.Repo, event.PrNumber, planComment(promotions))
+	}
+
+	for _, promotion := range promotions {
+		changes := map[string]*string{}
+		for trgt, src := range promotion.ComputedSyncPaths {
+			err = generateSyncChanges(ctx, p, event.Repo, branch, src, trgt, changes)
+			if err != nil {
+				logger.Errorf("Failed to generate changes for %s > %s,  err=%v", src, trgt, err)
+			}
+		}
+		if len(changes) < 1 {
+			logger.Infof("Change list is empty")
+			continue
+		}
+
+		newBranchName := githubapi.GenerateSafePromotionBranchName(event.PrNumber, event.SourceBranch, promotion.Metadata.TargetPaths)
+		err = p.CommitFiles(ctx, event.Repo, branch, newBranchName, "Syncing from "+promotion.Metadata.SourcePath, changes)
+		if err != nil {
+			logger.Errorf("Commit creation failed: err=%v", err)
+			return err
+		}
+
+		components := strings.Join(promotion.Metadata.ComponentNames, ",")
+		newPrTitle := fmt.Sprintf("🚀 Promotion: %s ➡️  %s", components, promotion.Metadata.TargetDescription)
+		newPrBody, _ := githubapi.GeneratePromotionPrBodyForPr(logger, event.Repo.Owner, event.Repo.Name, event.PrNumber, event.SourceBranch, event.PrBody, event.PrAuthor, components, promotion, newBranchName)
+
+		newPrNumber, err := p.CreatePr(ctx, event.Repo, newPrTitle, newPrBody, newBranchName, branch)
+		if err != nil {
+			logger.Errorf("PR opening failed: err=%v", err)
+			return err
+		}
+		logger.Infof("Opened promotion PR %d", newPrNumber)
+
+		if promotion.Metadata.AutoMerge {
+			logger.Infof("Auto-merging PR %d", newPrNumber)
+			_ = p.CommentOnPr(ctx, event.Repo, event.PrNumber, fmt.Sprintf("Auto-merging promotion PR #%d", newPrNumber))
+			err = p.MergePr(ctx, event.Repo, newPrNumber)
+			if err != nil {
+				logger.Errorf("PR auto merge failed: err=%v", err)
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// generateSyncChanges adds the changes needed to make targetPath identical to sourcePath, including deletion of files that don't exist in sourcePath
+func generateSyncChanges(ctx context.Context, p Provider, repo Repo, branch string, sourcePath string, targetPath string, changes map[string]*string) error {
+	sourceFiles, err := p.ListFiles(ctx, repo, branch, sourcePath)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	targetFiles, err := p.ListFiles(ctx, repo, branch, targetPath)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	for _, f := range sourceFiles {
+		content, err := p.GetFileContent(ctx, repo, branch, sourcePath+"/"+f)
+		if err != nil {
+			return err
+		}
+		contentString := string(content)
+		changes[targetPath+"/"+f] = &contentString
+	}
+	for _, f := range deletedFiles(sourceFiles, targetFiles) {
+		changes[targetPath+"/"+f] = nil
+	}
+	return nil
+}
+
+// deletedFiles returns the target files that don't exist in the source, a missing source(empty list) means the whole target is deleted
+func deletedFiles(sourceFiles []string, targetFiles []string) []string {
+	inSource := make(map[string]bool, len(sourceFiles))
+	for _, f := range sourceFiles {
+		inSource[f] = true
+	}
+	deleted := []string{}
+	for _, f := range targetFiles {
+		if !inSource[f] {
+			deleted = append(deleted, f)
+		}
+	}
+	sort.Strings(deleted)
+	return deleted
+}
+
+func planComment(promotions map[string]githubapi.PromotionInstance) string {
+	keys := make([]string, 0, len(promotions))
+	for k := range promotions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	sb.WriteString("<!-- telefonistka_tag -->\nThis is the plan for the promotion:\n\n")
+	for _, k := range keys {
+		targets := make([]string, 0, len(promotions[k].ComputedSyncPaths))
+		for trgt, src := range promotions[k].ComputedSyncPaths {
+			targets = append(targets, fmt.Sprintf("`%s` ➡️  `%s`", src, trgt))
+		}
+		sort.Strings(targets)
+		for _, t := range targets {
+			sb.WriteString("* " + t + "\n")
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,40 @@
+package scm
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestDeletedFiles(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		sourceFiles []string
+		targetFiles []string
+		expected    []string
+	}{
+		"Identical file lists": {
+			sourceFiles: []string{"a.yaml", "dir/b.yaml"},
+			targetFiles: []string{"a.yaml", "dir/b.yaml"},
+			expected:    []string{},
+		},
+		"Extra target files are deleted": {
+			sourceFiles: []string{"a.yaml"},
+			targetFiles: []string{"dir/c.yaml", "a.yaml", "b.yaml"},
+			expected:    []string{"b.yaml", "dir/c.yaml"},
+		},
+		"Missing source deletes the whole target": {
+			sourceFiles: nil,
+			targetFiles: []string{"a.yaml"},
+			expected:    []string{"a.yaml"},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			if diff := deep.Equal(tc.expected, deletedFiles(tc.sourceFiles, tc.targetFiles)); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
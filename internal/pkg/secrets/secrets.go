@@ -0,0 +1,62 @@
+// Package secrets resolves credentials from env vars, files(e.g. mounted by the External Secrets Operator, a CSI driver or a Vault agent)
+// or HashiCorp Vault KV secrets.
+package secrets
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	defaultVaultOnce sync.Once
+	defaultVault     *vaultClient
+)
+
+// Lookup returns the value of the secret named like its env var, sources are checked in this order:
+//   - The <name> env var
+//   - The file referenced by the <name>_FILE env var, it's read on every lookup so rotated files are picked up
+//   - The <name> key of the Vault secret at VAULT_SECRET_PATH, when VAULT_ADDR is set
+func Lookup(name string) (string, bool) {
+	if value, ok := os.LookupEnv(name); ok {
+		return value, true
+	}
+	if path, ok := os.LookupEnv(name + "_FILE"); ok {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			log.Errorf("Failed to read %s from %s: %v", name, path, err)
+			return "", false
+		}
+		return strings.TrimRight(string(content), "\r\n"), true
+	}
+	defaultVaultOnce.Do(func() {
+		defaultVault = newVaultClientFromEnv()
+	})
+	if defaultVault != nil {
+		return defaultVault.lookup(name)
+	}
+	return "", false
+}
+
+// Get returns the value of the secret or fallback when it's not set in any source
+func Get(name string, fallback string) string {
+	if value, ok := Lookup(name); ok {
+		return value
+	}
+	return fallback
+}
+
+// Flush makes the next lookups re-read the Vault secret instead of waiting for its refresh interval, env vars and files are always read on lookup
+func Flush() {
+	defaultVaultOnce.Do(func() {
+		defaultVault = newVaultClientFromEnv()
+	})
+	if defaultVault != nil {
+		defaultVault.mu.Lock()
+		defaultVault.lastAttempt = time.Time{}
+		defaultVault.mu.Unlock()
+	}
+}
@@ -0,0 +1,29 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Not parallel, these tests modify the process env
+func TestLookupPrecedence(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(secretFile, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("TELEFONISTKA_TEST_SECRET_FILE", secretFile)
+	if value, ok := Lookup("TELEFONISTKA_TEST_SECRET"); !ok || value != "from-file" {
+		t.Errorf("expected the file content without trailing newline, got %q(%v)", value, ok)
+	}
+
+	t.Setenv("TELEFONISTKA_TEST_SECRET", "from-env")
+	if value := Get("TELEFONISTKA_TEST_SECRET", "fallback"); value != "from-env" {
+		t.Errorf("expected the env var to take precedence, got %q", value)
+	}
+
+	if value := Get("TELEFONISTKA_TEST_UNSET_SECRET", "fallback"); value != "fallback" {
+		t.Errorf("expected fallback, got %q", value)
+	}
+}
@@ -0,0 +1,209 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultKubernetesServiceAccountTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token" //nolint:gosec // G101: this is a path, not a credential
+
+var errVaultPermissionDenied = errors.New("vault permission denied")
+
+// vaultClient reads a single Vault KV secret and keeps its keys in memory, the secret is re-read every refreshInterval
+// so rotated values are picked up without a restart
+type vaultClient struct {
+	addr       string
+	namespace  string
+	secretPath string
+	// Kubernetes auth method, used when no static token is configured
+	authRole                string
+	authMount               string
+	serviceAccountTokenPath string
+
+	refreshInterval time.Duration
+	httpClient      *http.Client
+	now             func() time.Time
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+	data        map[string]string
+	lastAttempt time.Time
+}
+
+func newVaultClientFromEnv() *vaultClient {
+	addr := os.Getenv("VAULT_ADDR")
+	secretPath := os.Getenv("VAULT_SECRET_PATH")
+	if addr == "" || secretPath == "" {
+		return nil
+	}
+	refreshInterval := 5 * time.Minute
+	if seconds, err := strconv.Atoi(os.Getenv("VAULT_SECRET_REFRESH_SECONDS")); err == nil && seconds > 0 {
+		refreshInterval = time.Duration(seconds) * time.Second
+	}
+	authMount := os.Getenv("VAULT_AUTH_MOUNT")
+	if authMount == "" {
+		authMount = "kubernetes"
+	}
+	serviceAccountTokenPath := os.Getenv("VAULT_K8S_TOKEN_PATH")
+	if serviceAccountTokenPath == "" {
+		serviceAccountTokenPath = defaultKubernetesServiceAccountTokenPath
+	}
+	log.Infof("Reading secrets from Vault secret %s at %s", secretPath, addr)
+	return &vaultClient{
+		addr:                    strings.TrimSuffix(addr, "/"),
+		namespace:               os.Getenv("VAULT_NAMESPACE"),
+		secretPath:              strings.Trim(secretPath, "/"),
+		authRole:                os.Getenv("VAULT_AUTH_ROLE"),
+		authMount:               strings.Trim(authMount, "/"),
+		serviceAccountTokenPath: serviceAccountTokenPath,
+		refreshInterval:         refreshInterval,
+		httpClient:              &http.Client{Timeout: 10 * time.Second},
+		now:                     time.Now,
+	}
+}
+
+func (vc *vaultClient) lookup(name string) (string, bool) {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	// Failed reads are not retried before the next refresh either, so an unavailable Vault doesn't slow down every lookup
+	if vc.lastAttempt.IsZero() || vc.now().Sub(vc.lastAttempt) >= vc.refreshInterval {
+		vc.lastAttempt = vc.now()
+		data, err := vc.readSecret()
+		if err != nil {
+			// Keep serving the last known values, they are probably still valid
+			log.Errorf("Failed to read Vault secret %s: %v", vc.secretPath, err)
+		} else {
+			vc.data = data
+		}
+	}
+	value, ok := vc.data[name]
+	return value, ok
+}
+
+func (vc *vaultClient) readSecret() (map[string]string, error) {
+	data, err := vc.readSecretOnce()
+	if errors.Is(err, errVaultPermissionDenied) && vc.authRole != "" {
+		// The login token might have been revoked before its lease ended, log in again
+		vc.token = ""
+		data, err = vc.readSecretOnce()
+	}
+	return data, err
+}
+
+func (vc *vaultClient) readSecretOnce() (map[string]string, error) {
+	token, err := vc.currentToken()
+	if err != nil {
+		return nil, err
+	}
+	var secret struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := vc.do(http.MethodGet, vc.secretPath, token, nil, &secret); err != nil {
+		return nil, err
+	}
+	// KV v2 nests the key/value pairs under data.data, KV v1 returns them directly under data
+	fields := secret.Data
+	if nested, ok := secret.Data["data"].(map[string]any); ok {
+		fields = nested
+	}
+	data := make(map[string]string, len(fields))
+	for k, v := range fields {
+		if s, ok := v.(string); ok {
+			data[k] = s
+		}
+	}
+	return data, nil
+}
+
+// currentToken returns the static VAULT_TOKEN(or the content of VAULT_TOKEN_FILE, e.g. written by a Vault agent) or a token
+// obtained with the Kubernetes auth method, the latter is renewed by logging in again before its lease ends
+func (vc *vaultClient) currentToken() (string, error) {
+	if token, ok := os.LookupEnv("VAULT_TOKEN"); ok {
+		return token, nil
+	}
+	if path, ok := os.LookupEnv("VAULT_TOKEN_FILE"); ok {
+		token, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read VAULT_TOKEN_FILE: %w", err)
+		}
+		return strings.TrimSpace(string(token)), nil
+	}
+	if vc.authRole == "" {
+		return "", fmt.Errorf("none of VAULT_TOKEN, VAULT_TOKEN_FILE or VAULT_AUTH_ROLE are set")
+	}
+	if vc.token != "" && vc.now().Before(vc.tokenExpiry) {
+		return vc.token, nil
+	}
+	return vc.kubernetesLogin()
+}
+
+func (vc *vaultClient) kubernetesLogin() (string, error) {
+	jwt, err := os.ReadFile(vc.serviceAccountTokenPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read service account token: %w", err)
+	}
+	body, err := json.Marshal(map[string]string{"role": vc.authRole, "jwt": strings.TrimSpace(string(jwt))})
+	if err != nil {
+		return "", err
+	}
+	var login struct {
+		Auth struct {
+			ClientToken   string `json:"client_token"`
+			LeaseDuration int    `json:"lease_duration"`
+		} `json:"auth"`
+	}
+	if err := vc.do(http.MethodPost, "auth/"+vc.authMount+"/login", "", body, &login); err != nil {
+		return "", fmt.Errorf("vault kubernetes login failed: %w", err)
+	}
+	if login.Auth.ClientToken == "" {
+		return "", fmt.Errorf("vault kubernetes login returned no token")
+	}
+	vc.token = login.Auth.ClientToken
+	// Renew well before the lease ends, a zero lease means the token doesn't expire
+	vc.tokenExpiry = vc.now().AddDate(100, 0, 0)
+	if login.Auth.LeaseDuration > 0 {
+		vc.tokenExpiry = vc.now().Add(time.Duration(login.Auth.LeaseDuration) * time.Second * 4 / 5)
+	}
+	return vc.token, nil
+}
+
+func (vc *vaultClient) do(method string, path string, token string, body []byte, out any) error {
+	req, err := http.NewRequest(method, vc.addr+"/v1/"+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if vc.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", vc.namespace)
+	}
+	resp, err := vc.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusForbidden {
+		return errVaultPermissionDenied
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("vault returned %s for %s: %s", resp.Status, path, strings.TrimSpace(string(respBody)))
+	}
+	return json.Unmarshal(respBody, out)
+}
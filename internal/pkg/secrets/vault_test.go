@@ -0,0 +1,124 @@
+package secrets
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeVault struct {
+	value        atomic.Value
+	reads        atomic.Int32
+	logins       atomic.Int32
+	expectedAuth string
+	kvV1         bool
+}
+
+func (fv *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/v1/auth/kubernetes/login":
+		fv.logins.Add(1)
+		var login map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&login)
+		if login["role"] != "telefonistka" || login["jwt"] != "sa-jwt" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"auth": map[string]any{"client_token": fv.expectedAuth, "lease_duration": 3600}})
+	case "/v1/secret/data/telefonistka":
+		fv.reads.Add(1)
+		if r.Header.Get("X-Vault-Token") != fv.expectedAuth {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		data := map[string]any{"GITHUB_OAUTH_TOKEN": fv.value.Load(), "NOT_A_STRING": 42}
+		if !fv.kvV1 {
+			data = map[string]any{"data": data, "metadata": map[string]any{"version": 3}}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestVaultClient(t *testing.T, fv *fakeVault, authRole string) (*vaultClient, *time.Time) {
+	t.Helper()
+	srv := httptest.NewServer(fv)
+	t.Cleanup(srv.Close)
+	saTokenPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(saTokenPath, []byte("sa-jwt\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	return &vaultClient{
+		addr:                    srv.URL,
+		secretPath:              "secret/data/telefonistka",
+		authRole:                authRole,
+		authMount:               "kubernetes",
+		serviceAccountTokenPath: saTokenPath,
+		refreshInterval:         time.Minute,
+		httpClient:              srv.Client(),
+		now:                     func() time.Time { return now },
+	}, &now
+}
+
+func TestVaultClientKubernetesAuthAndRefresh(t *testing.T) {
+	t.Parallel()
+	fv := &fakeVault{expectedAuth: "vault-token"}
+	fv.value.Store("first")
+	vc, now := newTestVaultClient(t, fv, "telefonistka")
+
+	if value, ok := vc.lookup("GITHUB_OAUTH_TOKEN"); !ok || value != "first" {
+		t.Fatalf("expected first, got %q(%v)", value, ok)
+	}
+	if _, ok := vc.lookup("NOT_A_STRING"); ok {
+		t.Error("non string values should be ignored")
+	}
+	if _, ok := vc.lookup("MISSING"); ok {
+		t.Error("missing keys should not be found")
+	}
+	if fv.reads.Load() != 1 {
+		t.Errorf("expected the secret to be read once before the refresh interval, got %d reads", fv.reads.Load())
+	}
+
+	fv.value.Store("rotated")
+	*now = now.Add(2 * time.Minute)
+	if value, _ := vc.lookup("GITHUB_OAUTH_TOKEN"); value != "rotated" {
+		t.Errorf("expected the rotated value after the refresh interval, got %q", value)
+	}
+	if fv.logins.Load() != 1 {
+		t.Errorf("expected the login token to be reused, got %d logins", fv.logins.Load())
+	}
+
+	// Past 80% of the lease the token is renewed by logging in again
+	*now = now.Add(50 * time.Minute)
+	vc.lookup("GITHUB_OAUTH_TOKEN")
+	if fv.logins.Load() != 2 {
+		t.Errorf("expected a second login after the token lease, got %d logins", fv.logins.Load())
+	}
+}
+
+func TestVaultClientKeepsLastKnownValuesOnFailure(t *testing.T) {
+	t.Parallel()
+	fv := &fakeVault{expectedAuth: "vault-token", kvV1: true}
+	fv.value.Store("first")
+	vc, now := newTestVaultClient(t, fv, "telefonistka")
+
+	if value, _ := vc.lookup("GITHUB_OAUTH_TOKEN"); value != "first" {
+		t.Fatalf("expected first, got %q", value)
+	}
+	// Every read is now denied, including after logging in again
+	fv.expectedAuth = "another-token"
+	*now = now.Add(2 * time.Minute)
+	if value, _ := vc.lookup("GITHUB_OAUTH_TOKEN"); value != "first" {
+		t.Errorf("expected the last known value when Vault fails, got %q", value)
+	}
+	if fv.logins.Load() != 2 {
+		t.Errorf("expected a denied read to trigger a new login, got %d logins", fv.logins.Load())
+	}
+}
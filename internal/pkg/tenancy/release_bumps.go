@@ -0,0 +1,98 @@
+package tenancy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	ReleaseBumpOnRelease = "release"
+	ReleaseBumpOnTag     = "tag"
+)
+
+// ReleaseBump opens version bump PRs in GitOps repos when SourceRepo publishes a release(or pushes a tag), replacing a CI step calling the bump CLI commands
+type ReleaseBump struct {
+	// owner/repo slug of the application repo
+	SourceRepo string `yaml:"sourceRepo"`
+	// "release"(default) bumps on published releases, prereleases excluded, "tag" on every pushed tag
+	On string `yaml:"on"`
+	// Tags that don't match are ignored, the version is the first capture group or the whole tag. Any tag matches when empty
+	TagRegex  string              `yaml:"tagRegex"`
+	Targets   []ReleaseBumpTarget `yaml:"targets"`
+	AutoMerge bool                `yaml:"autoMerge"`
+}
+
+// ReleaseBumpTarget is a file of a GitOps repo holding the version, targets of the same repo are bumped in a single PR
+type ReleaseBumpTarget struct {
+	Repo string `yaml:"repo"`
+	File string `yaml:"file"`
+	// yq selector of the value set to the version, e.g. ".image.tag"
+	YamlAddress string `yaml:"yamlAddress"`
+	// Or a regex whose matches are replaced with Replacement, "{version}" in Replacement is replaced with the version
+	Regex       string `yaml:"regex"`
+	Replacement string `yaml:"replacement"`
+}
+
+func (c *Config) validateReleaseBumps() error {
+	for i, bump := range c.ReleaseBumps {
+		if bump.SourceRepo == "" {
+			return fmt.Errorf("releaseBumps[%d] has no sourceRepo", i)
+		}
+		if bump.On != "" && bump.On != ReleaseBumpOnRelease && bump.On != ReleaseBumpOnTag {
+			return fmt.Errorf("releaseBumps[%d] on should be %s or %s", i, ReleaseBumpOnRelease, ReleaseBumpOnTag)
+		}
+		if _, err := regexp.Compile(bump.TagRegex); err != nil {
+			return fmt.Errorf("releaseBumps[%d] tagRegex: %w", i, err)
+		}
+		if len(bump.Targets) == 0 {
+			return fmt.Errorf("releaseBumps[%d] has no targets", i)
+		}
+		for j, target := range bump.Targets {
+			if target.Repo == "" || target.File == "" {
+				return fmt.Errorf("releaseBumps[%d].targets[%d] needs a repo and a file", i, j)
+			}
+			if (target.YamlAddress == "") == (target.Regex == "") {
+				return fmt.Errorf("releaseBumps[%d].targets[%d] needs either a yamlAddress or a regex", i, j)
+			}
+			if _, err := regexp.Compile(target.Regex); err != nil {
+				return fmt.Errorf("releaseBumps[%d].targets[%d] regex: %w", i, j, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ReleaseBumpsFor returns the release bumps of an owner/repo slug triggered by on(ReleaseBumpOnRelease or ReleaseBumpOnTag)
+func ReleaseBumpsFor(repoSlug string, on string) []ReleaseBump {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	if config == nil {
+		return nil
+	}
+	bumps := []ReleaseBump{}
+	for _, bump := range config.ReleaseBumps {
+		bumpOn := bump.On
+		if bumpOn == "" {
+			bumpOn = ReleaseBumpOnRelease
+		}
+		if strings.EqualFold(bump.SourceRepo, repoSlug) && bumpOn == on {
+			bumps = append(bumps, bump)
+		}
+	}
+	return bumps
+}
+
+// Version returns the version a tag bumps to, ok is false when the tag doesn't match TagRegex
+func (b ReleaseBump) Version(tag string) (version string, ok bool) {
+	// The regex is validated when the configuration is parsed
+	r := regexp.MustCompile(b.TagRegex)
+	match := r.FindStringSubmatch(tag)
+	if match == nil {
+		return "", false
+	}
+	if len(match) > 1 {
+		return match[1], true
+	}
+	return tag, true
+}
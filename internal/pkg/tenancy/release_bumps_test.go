@@ -0,0 +1,85 @@
+package tenancy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const releaseBumpsConfig = `
+releaseBumps:
+  - sourceRepo: org-a/app
+    tagRegex: ^v(\d+\.\d+\.\d+)$
+    targets:
+      - repo: org-a/gitops
+        file: env/staging/app/values.yaml
+        yamlAddress: .image.tag
+  - sourceRepo: org-a/app
+    on: tag
+    targets:
+      - repo: org-a/gitops
+        file: env/dev/app/values.yaml
+        regex: 'tag: \S+'
+        replacement: 'tag: {version}'
+`
+
+// Not parallel, ReleaseBumpsFor uses the package level configuration
+func TestReleaseBumpsFor(t *testing.T) {
+	c, err := ParseConfig([]byte(releaseBumpsConfig))
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	SetConfig(c)
+	t.Cleanup(func() { SetConfig(nil) })
+
+	releaseBumps := ReleaseBumpsFor("Org-A/App", ReleaseBumpOnRelease)
+	assert.Len(t, releaseBumps, 1)
+	assert.Equal(t, ".image.tag", releaseBumps[0].Targets[0].YamlAddress)
+
+	tagBumps := ReleaseBumpsFor("org-a/app", ReleaseBumpOnTag)
+	assert.Len(t, tagBumps, 1)
+	assert.Equal(t, "env/dev/app/values.yaml", tagBumps[0].Targets[0].File)
+
+	assert.Empty(t, ReleaseBumpsFor("org-a/other-app", ReleaseBumpOnRelease))
+}
+
+func TestReleaseBumpVersion(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		tagRegex        string
+		tag             string
+		expectedVersion string
+		expectedOk      bool
+	}{
+		"Any tag": {
+			tag:             "v1.2.3",
+			expectedVersion: "v1.2.3",
+			expectedOk:      true,
+		},
+		"Capture group": {
+			tagRegex:        `^v(\d+\.\d+\.\d+)$`,
+			tag:             "v1.2.3",
+			expectedVersion: "1.2.3",
+			expectedOk:      true,
+		},
+		"Whole match without a capture group": {
+			tagRegex:        `^release-`,
+			tag:             "release-42",
+			expectedVersion: "release-42",
+			expectedOk:      true,
+		},
+		"Not matching": {
+			tagRegex: `^v(\d+\.\d+\.\d+)$`,
+			tag:      "v1.2.3-rc1",
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			version, ok := ReleaseBump{TagRegex: tc.tagRegex}.Version(tc.tag)
+			assert.Equal(t, tc.expectedOk, ok)
+			assert.Equal(t, tc.expectedVersion, version)
+		})
+	}
+}
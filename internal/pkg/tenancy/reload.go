@@ -0,0 +1,74 @@
+package tenancy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+)
+
+// WatchFromEnv reloads the SERVER_CONFIG_PATH file when its content changes, checking it every SERVER_CONFIG_RELOAD_INTERVAL_SECONDS(0 disables it).
+// The file is polled rather than watched, so ConfigMap mounts(updated by swapping a symlink) are picked up too. It returns once the watcher is started
+func WatchFromEnv(ctx context.Context) error {
+	path, ok := os.LookupEnv("SERVER_CONFIG_PATH")
+	if !ok || path == "" {
+		return nil
+	}
+	interval, err := strconv.Atoi(getEnv("SERVER_CONFIG_RELOAD_INTERVAL_SECONDS", "30"))
+	if err != nil || interval < 0 {
+		return fmt.Errorf("SERVER_CONFIG_RELOAD_INTERVAL_SECONDS should be a non negative integer: %w", err)
+	}
+	if interval == 0 {
+		return nil
+	}
+	// The configuration was loaded by LoadFromEnv, changes from then on are reloaded
+	seen, _ := os.ReadFile(path)
+	go func() {
+		ticker := time.NewTicker(time.Duration(interval) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				seen = reloadIfChanged(path, seen)
+			}
+		}
+	}()
+	return nil
+}
+
+// reloadIfChanged loads path when its content differs from the last seen content and returns the content it saw.
+// A failed reload keeps the current configuration, the same content isn't retried until the file changes again
+func reloadIfChanged(path string, seen []byte) []byte {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		// e.g. in the middle of a ConfigMap update
+		log.Warnf("Failed to read server configuration %s, keeping the current one: %v", path, err)
+		prom.InstrumentServerConfigReload("failure")
+		return seen
+	}
+	if bytes.Equal(content, seen) {
+		return seen
+	}
+	if loaded, err := load(path); err != nil {
+		log.Errorf("Failed to reload server configuration %s, keeping the current one: %v", path, err)
+		prom.InstrumentServerConfigReload("failure")
+	} else {
+		content = loaded
+		prom.InstrumentServerConfigReload("success")
+	}
+	return content
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
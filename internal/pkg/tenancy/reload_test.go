@@ -0,0 +1,52 @@
+package tenancy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Not parallel, reloads change the package level configuration
+func TestReloadIfChanged(t *testing.T) {
+	t.Setenv("GITHUB_WEBHOOK_SECRET", "global")
+	t.Cleanup(func() { SetConfig(nil) })
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(testConfig), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	seen, err := load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	// Unchanged
+	seen = reloadIfChanged(path, seen)
+	assert.Equal(t, "team-b", ForRepo("org-b/repo").Name)
+
+	updated := "tenants:\n  - name: team-c\n    match: [org-b]\n    webhookPath: c\n    env:\n      GITHUB_WEBHOOK_SECRET: s\n"
+	if err := os.WriteFile(path, []byte(updated), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	seen = reloadIfChanged(path, seen)
+	assert.Equal(t, updated, string(seen))
+	assert.Equal(t, "team-c", ForRepo("org-b/repo").Name)
+	assert.Equal(t, "team-c", ForWebhookPath("c").Name)
+
+	// An invalid configuration keeps the current one
+	if err := os.WriteFile(path, []byte("tenants:\n  - match: [org-b]\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	seen = reloadIfChanged(path, seen)
+	assert.Equal(t, "tenants:\n  - match: [org-b]\n", string(seen))
+	assert.Equal(t, "team-c", ForRepo("org-b/repo").Name)
+
+	// So does a missing file
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	reloadIfChanged(path, seen)
+	assert.Equal(t, "team-c", ForRepo("org-b/repo").Name)
+	assert.Nil(t, ForWebhookPath("a"))
+}
@@ -0,0 +1,260 @@
+// Package tenancy lets a single Telefonistka deployment serve several GitHub orgs/repos with different settings(GitHub App, webhook secret,
+// templates, ArgoCD endpoint...). Each tenant overrides the server env vars for the repos it matches, repos that don't match any tenant
+// use the env vars as is.
+package tenancy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/logging"
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/secrets"
+	yaml "gopkg.in/yaml.v2"
+)
+
+type Tenant struct {
+	Name string `yaml:"name"`
+	// GitHub org/user names or owner/repo slugs, a repo slug match takes precedence over an owner match
+	Match []string `yaml:"match"`
+	// Env var overrides, e.g. GITHUB_APP_ID, GITHUB_WEBHOOK_SECRET_FILE, TEMPLATES_PATH or ARGOCD_SERVER_ADDR
+	Env map[string]string `yaml:"env"`
+	// When set the tenant gets its own webhook endpoint, /webhook/github/<webhookPath>, only validated with its own webhook secret
+	WebhookPath string `yaml:"webhookPath"`
+}
+
+type Config struct {
+	Tenants      []*Tenant     `yaml:"tenants"`
+	ReleaseBumps []ReleaseBump `yaml:"releaseBumps"`
+	// Used for the settings the log flags/env vars don't set
+	Logging logging.Config `yaml:"logging"`
+}
+
+type tenantContextKey struct{}
+
+var webhookPathRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+var (
+	configMu sync.RWMutex
+	config   *Config
+)
+
+// ParseConfig parses and validates a server configuration file content
+func ParseConfig(content []byte) (*Config, error) {
+	c := &Config{}
+	if err := yaml.UnmarshalStrict(content, c); err != nil {
+		return nil, fmt.Errorf("failed to parse server configuration: %w", err)
+	}
+	names := map[string]bool{}
+	matches := map[string]string{}
+	webhookPaths := map[string]string{}
+	for i, t := range c.Tenants {
+		if t.Name == "" {
+			return nil, fmt.Errorf("tenant #%d has no name", i)
+		}
+		if names[t.Name] {
+			return nil, fmt.Errorf("tenant name %s is used more than once", t.Name)
+		}
+		names[t.Name] = true
+		if len(t.Match) == 0 {
+			return nil, fmt.Errorf("tenant %s doesn't match any org or repo", t.Name)
+		}
+		for _, m := range t.Match {
+			m = strings.ToLower(m)
+			if other, ok := matches[m]; ok {
+				return nil, fmt.Errorf("%s is matched by both tenant %s and %s", m, other, t.Name)
+			}
+			matches[m] = t.Name
+		}
+		if t.WebhookPath != "" {
+			if err := t.validateWebhookPath(); err != nil {
+				return nil, err
+			}
+			if other, ok := webhookPaths[t.WebhookPath]; ok {
+				return nil, fmt.Errorf("webhook path %s is used by both tenant %s and %s", t.WebhookPath, other, t.Name)
+			}
+			webhookPaths[t.WebhookPath] = t.Name
+		}
+	}
+	if err := c.validateReleaseBumps(); err != nil {
+		return nil, err
+	}
+	if err := c.Logging.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid logging configuration: %w", err)
+	}
+	return c, nil
+}
+
+// LoadFromEnv loads the server configuration file referenced by the SERVER_CONFIG_PATH env var, it's a no-op if it isn't set
+func LoadFromEnv() error {
+	path, ok := os.LookupEnv("SERVER_CONFIG_PATH")
+	if !ok || path == "" {
+		return nil
+	}
+	_, err := load(path)
+	return err
+}
+
+// load reads, validates and applies a server configuration file, it returns the file content. An invalid file leaves the current configuration as is
+func load(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read server configuration: %w", err)
+	}
+	c, err := ParseConfig(content)
+	if err != nil {
+		return nil, err
+	}
+	_, globalWebhookSecret := secrets.Lookup("GITHUB_WEBHOOK_SECRET")
+	if err := c.validateWebhookSecrets(globalWebhookSecret); err != nil {
+		return nil, err
+	}
+	SetConfig(c)
+	if err := logging.ApplyDefaults(c.Logging); err != nil {
+		return nil, err
+	}
+	log.Infof("Loaded %d tenants from %s", len(c.Tenants), path)
+	return content, nil
+}
+
+// validateWebhookSecrets makes sure webhooks of every tenant can be validated, without a global GITHUB_WEBHOOK_SECRET each tenant must set its own
+func (c *Config) validateWebhookSecrets(globalWebhookSecret bool) error {
+	if globalWebhookSecret {
+		return nil
+	}
+	for _, t := range c.Tenants {
+		_, secret := t.Env["GITHUB_WEBHOOK_SECRET"]
+		_, secretFile := t.Env["GITHUB_WEBHOOK_SECRET_FILE"]
+		if !secret && !secretFile {
+			return fmt.Errorf("tenant %s has no GITHUB_WEBHOOK_SECRET and no global GITHUB_WEBHOOK_SECRET is set", t.Name)
+		}
+	}
+	return nil
+}
+
+// validateWebhookPath makes sure the webhook endpoint of the tenant is a single path segment with its own secret, the endpoint never falls back to the global secret
+func (t *Tenant) validateWebhookPath() error {
+	if !webhookPathRegex.MatchString(t.WebhookPath) {
+		return fmt.Errorf("tenant %s webhook path %q should only have letters, digits, - and _", t.Name, t.WebhookPath)
+	}
+	_, secret := t.Env["GITHUB_WEBHOOK_SECRET"]
+	_, secretFile := t.Env["GITHUB_WEBHOOK_SECRET_FILE"]
+	if !secret && !secretFile {
+		return fmt.Errorf("tenant %s has a webhook path but no GITHUB_WEBHOOK_SECRET", t.Name)
+	}
+	return nil
+}
+
+// WebhookEndpoints returns the tenants with their own webhook endpoint
+func WebhookEndpoints() []*Tenant {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	endpoints := []*Tenant{}
+	if config == nil {
+		return endpoints
+	}
+	for _, t := range config.Tenants {
+		if t.WebhookPath != "" {
+			endpoints = append(endpoints, t)
+		}
+	}
+	return endpoints
+}
+
+// ForWebhookPath returns the tenant with the webhookPath, nil when no tenant has it
+func ForWebhookPath(webhookPath string) *Tenant {
+	for _, t := range WebhookEndpoints() {
+		if t.WebhookPath == webhookPath {
+			return t
+		}
+	}
+	return nil
+}
+
+// Configured reports whether a server configuration with at least one tenant is loaded
+func Configured() bool {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return config != nil && len(config.Tenants) > 0
+}
+
+func SetConfig(c *Config) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	config = c
+}
+
+// ForRepo returns the tenant of a owner/repo slug or nil when no tenant matches
+func ForRepo(repoSlug string) *Tenant {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	if config == nil || repoSlug == "" {
+		return nil
+	}
+	repoSlug = strings.ToLower(repoSlug)
+	owner, _, _ := strings.Cut(repoSlug, "/")
+	var ownerMatch *Tenant
+	for _, t := range config.Tenants {
+		for _, m := range t.Match {
+			switch strings.ToLower(m) {
+			case repoSlug:
+				return t
+			case owner:
+				ownerMatch = t
+			}
+		}
+	}
+	return ownerMatch
+}
+
+func NewContext(ctx context.Context, t *Tenant) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, t)
+}
+
+// FromContext returns the tenant attached to the context, nil when there is none
+func FromContext(ctx context.Context) *Tenant {
+	if ctx == nil {
+		return nil
+	}
+	t, _ := ctx.Value(tenantContextKey{}).(*Tenant)
+	return t
+}
+
+// Lookup returns the tenant override of an env var, a <key>_FILE override is read like secrets.Lookup does.
+// When the tenant doesn't override it(or the tenant is nil) the server env vars and secret stores are used
+func (t *Tenant) Lookup(key string) (string, bool) {
+	if t != nil {
+		if value, ok := t.Env[key]; ok {
+			return value, true
+		}
+		if path, ok := t.Env[key+"_FILE"]; ok {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				log.Errorf("Failed to read %s of tenant %s from %s: %v", key, t.Name, path, err)
+				return "", false
+			}
+			return strings.TrimRight(string(content), "\r\n"), true
+		}
+	}
+	return secrets.Lookup(key)
+}
+
+// CacheKey namespaces a cache key(e.g. a GitHub client cache key) by tenant, so tenants never share cached clients
+func (t *Tenant) CacheKey(key string) string {
+	if t == nil {
+		return key
+	}
+	return t.Name + "/" + key
+}
+
+// Getenv returns the value of an env var for the tenant in ctx
+func Getenv(ctx context.Context, key string, fallback string) string {
+	if value, ok := FromContext(ctx).Lookup(key); ok {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,115 @@
+package tenancy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testConfig = `
+tenants:
+  - name: team-a
+    match:
+      - org-a
+    env:
+      GITHUB_APP_ID: "1234"
+      TEMPLATES_PATH: /etc/telefonistka/team-a/
+  - name: team-b
+    match:
+      - org-a/special-repo
+      - Org-B
+    env:
+      ARGOCD_SERVER_ADDR: argocd.team-b:443
+`
+
+func TestParseConfigValidation(t *testing.T) {
+	t.Parallel()
+	tests := map[string]string{
+		"missing name":                           "tenants:\n  - match: [org-a]\n",
+		"duplicate name":                         "tenants:\n  - name: a\n    match: [org-a]\n  - name: a\n    match: [org-b]\n",
+		"no match":                               "tenants:\n  - name: a\n",
+		"duplicate match":                        "tenants:\n  - name: a\n    match: [org-a]\n  - name: b\n    match: [ORG-A]\n",
+		"unknown field":                          "tenants:\n  - name: a\n    match: [org-a]\n    githubAppId: 1\n",
+		"release bump without targets":           "releaseBumps:\n  - sourceRepo: org-a/app\n",
+		"release bump with an unknown trigger":   "releaseBumps:\n  - sourceRepo: org-a/app\n    on: push\n    targets:\n      - {repo: org-a/gitops, file: values.yaml, yamlAddress: .tag}\n",
+		"release bump target without a selector": "releaseBumps:\n  - sourceRepo: org-a/app\n    targets:\n      - {repo: org-a/gitops, file: values.yaml}\n",
+		"release bump invalid tag regex":         "releaseBumps:\n  - sourceRepo: org-a/app\n    tagRegex: ^v(\n    targets:\n      - {repo: org-a/gitops, file: values.yaml, yamlAddress: .tag}\n",
+		"webhook path without a secret":          "tenants:\n  - name: a\n    match: [org-a]\n    webhookPath: a\n",
+		"webhook path with a slash":              "tenants:\n  - name: a\n    match: [org-a]\n    webhookPath: a/b\n    env:\n      GITHUB_WEBHOOK_SECRET: s\n",
+		"duplicate webhook path":                 "tenants:\n  - name: a\n    match: [org-a]\n    webhookPath: a\n    env:\n      GITHUB_WEBHOOK_SECRET: s\n  - name: b\n    match: [org-b]\n    webhookPath: a\n    env:\n      GITHUB_WEBHOOK_SECRET_FILE: /s\n",
+		"unknown log level":                      "logging:\n  level: verbose\n",
+		"unknown log format":                     "logging:\n  format: logfmt\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			_, err := ParseConfig([]byte(content))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestValidateWebhookSecrets(t *testing.T) {
+	t.Parallel()
+	c, err := ParseConfig([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	assert.NoError(t, c.validateWebhookSecrets(true))
+	assert.Error(t, c.validateWebhookSecrets(false))
+
+	c.Tenants[0].Env["GITHUB_WEBHOOK_SECRET"] = "s3cr3t"
+	c.Tenants[1].Env["GITHUB_WEBHOOK_SECRET_FILE"] = "/etc/telefonistka/team-b/webhook-secret"
+	assert.NoError(t, c.validateWebhookSecrets(false))
+}
+
+// Not parallel, ForRepo uses the package level configuration
+func TestForRepo(t *testing.T) {
+	c, err := ParseConfig([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	SetConfig(c)
+	t.Cleanup(func() { SetConfig(nil) })
+
+	tests := map[string]string{
+		"org-a/some-repo":    "team-a",
+		"org-a/special-repo": "team-b",
+		"org-b/repo":         "team-b",
+		"ORG-A/Some-Repo":    "team-a",
+		"org-c/repo":         "",
+		"":                   "",
+	}
+	for repoSlug, expectedTenant := range tests {
+		tenant := ForRepo(repoSlug)
+		name := ""
+		if tenant != nil {
+			name = tenant.Name
+		}
+		assert.Equal(t, expectedTenant, name, "tenant of %q", repoSlug)
+	}
+}
+
+func TestTenantLookup(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "webhook-secret")
+	if err := os.WriteFile(secretFile, []byte("tenant-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TELEFONISTKA_TEST_GLOBAL", "global")
+	tenant := &Tenant{Name: "team-a", Env: map[string]string{
+		"TEMPLATES_PATH":             "/team-a/",
+		"GITHUB_WEBHOOK_SECRET_FILE": secretFile,
+	}}
+	ctx := NewContext(context.Background(), tenant)
+
+	assert.Equal(t, "/team-a/", Getenv(ctx, "TEMPLATES_PATH", "templates/"))
+	assert.Equal(t, "tenant-secret", Getenv(ctx, "GITHUB_WEBHOOK_SECRET", ""))
+	assert.Equal(t, "global", Getenv(ctx, "TELEFONISTKA_TEST_GLOBAL", ""), "missing overrides should fall back to the server env")
+	assert.Equal(t, "templates/", Getenv(context.Background(), "TEMPLATES_PATH", "templates/"))
+
+	assert.Equal(t, "team-a/org-a", tenant.CacheKey("org-a"))
+	assert.Equal(t, "org-a", FromContext(context.Background()).CacheKey("org-a"))
+}
@@ -0,0 +1,131 @@
+package terraform
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/tenancy"
+)
+
+// planTimeout bounds a single plan, provider downloads and large states can make it slow but it shouldn't block the event handler forever
+const planTimeout = 10 * time.Minute
+
+// PlanRequest describes the Terraform root module to plan
+type PlanRequest struct {
+	Repo          string `json:"repo"` // owner/repo
+	PrNumber      int    `json:"prNumber"`
+	Ref           string `json:"ref"`
+	SHA           string `json:"sha"`
+	ComponentPath string `json:"componentPath"`
+	// Dir is the component directory in a local checkout of SHA, it's only set for runners that need a checkout
+	Dir string `json:"-"`
+}
+
+// Runner produces the human readable plan of a Terraform root module
+type Runner interface {
+	Plan(ctx context.Context, request PlanRequest) (string, error)
+	// NeedsCheckout is true when the runner plans a local checkout(PlanRequest.Dir) of the repo
+	NeedsCheckout() bool
+}
+
+// RunnerFromEnv returns the runner configured by TERRAFORM_PLAN_RUNNER, "local"(default) runs the terraform binary and "webhook" asks TERRAFORM_PLAN_WEBHOOK_URL for the plan
+func RunnerFromEnv(ctx context.Context) (Runner, error) {
+	switch runner := tenancy.Getenv(ctx, "TERRAFORM_PLAN_RUNNER", "local"); runner {
+	case "local":
+		return localRunner{binary: tenancy.Getenv(ctx, "TERRAFORM_BINARY_PATH", "terraform")}, nil
+	case "webhook":
+		url := tenancy.Getenv(ctx, "TERRAFORM_PLAN_WEBHOOK_URL", "")
+		if url == "" {
+			return nil, fmt.Errorf("TERRAFORM_PLAN_WEBHOOK_URL is required by the webhook Terraform plan runner")
+		}
+		return webhookRunner{url: url, token: tenancy.Getenv(ctx, "TERRAFORM_PLAN_WEBHOOK_TOKEN", ""), client: &http.Client{Timeout: planTimeout}}, nil
+	default:
+		return nil, fmt.Errorf("unknown TERRAFORM_PLAN_RUNNER %q, it should be \"local\" or \"webhook\"", runner)
+	}
+}
+
+type localRunner struct {
+	binary string
+}
+
+func (r localRunner) NeedsCheckout() bool {
+	return true
+}
+
+func (r localRunner) run(ctx context.Context, dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, r.binary, args...) //nolint:gosec // the binary is set by the Telefonistka operator
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		return "", fmt.Errorf("terraform %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// Plan initializes the module and plans it without locking the state, the credentials of the backend and providers come from the Telefonistka environment
+func (r localRunner) Plan(ctx context.Context, request PlanRequest) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, planTimeout)
+	defer cancel()
+	_, err := r.run(ctx, request.Dir, "init", "-input=false", "-no-color")
+	if err != nil {
+		return "", err
+	}
+	return r.run(ctx, request.Dir, "plan", "-input=false", "-no-color", "-lock=false")
+}
+
+type webhookRunner struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func (r webhookRunner) NeedsCheckout() bool {
+	return false
+}
+
+// Plan posts the request as JSON, the runner(e.g. a small service in front of Atlantis or a CI system) responds with the plan output as the body
+func (r webhookRunner) Plan(ctx context.Context, request PlanRequest) (string, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("terraform plan webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	plan, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("terraform plan webhook: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("terraform plan webhook: unexpected status %s: %s", resp.Status, strings.TrimSpace(string(plan)))
+	}
+	return string(plan), nil
+}
+
+var planSummaryRegex = regexp.MustCompile(`(?m)^(Plan: \d+ to add, \d+ to change, \d+ to destroy\.|No changes\..*)$`)
+
+// Summary returns the one line summary of a plan output, e.g. "Plan: 1 to add, 0 to change, 0 to destroy.", or an empty string if it isn't found
+func Summary(plan string) string {
+	return planSummaryRegex.FindString(plan)
+}
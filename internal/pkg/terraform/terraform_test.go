@@ -0,0 +1,68 @@
+package terraform
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummary(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		plan     string
+		expected string
+	}{
+		"changes": {
+			plan:     "  # aws_instance.web will be created\n  + resource \"aws_instance\" \"web\" {}\n\nPlan: 1 to add, 0 to change, 2 to destroy.\n",
+			expected: "Plan: 1 to add, 0 to change, 2 to destroy.",
+		},
+		"no changes": {
+			plan:     "\nNo changes. Your infrastructure matches the configuration.\n\nTerraform has compared your real infrastructure against your configuration\n",
+			expected: "No changes. Your infrastructure matches the configuration.",
+		},
+		"unexpected output": {
+			plan:     "Error: something",
+			expected: "",
+		},
+	}
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, Summary(tc.plan))
+		})
+	}
+}
+
+func TestWebhookRunnerPlan(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request PlanRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		assert.Equal(t, "Bearer s3cr3t", r.Header.Get("Authorization"))
+		assert.Equal(t, "infra/prod/vpc", request.ComponentPath)
+		_, _ = w.Write([]byte("Plan: 1 to add, 0 to change, 0 to destroy.\n"))
+	}))
+	defer server.Close()
+
+	runner := webhookRunner{url: server.URL, token: "s3cr3t", client: server.Client()}
+	plan, err := runner.Plan(context.Background(), PlanRequest{Repo: "owner/repo", ComponentPath: "infra/prod/vpc"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Plan: 1 to add, 0 to change, 0 to destroy.\n", plan)
+}
+
+func TestWebhookRunnerPlanError(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no credentials", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	runner := webhookRunner{url: server.URL, client: server.Client()}
+	_, err := runner.Plan(context.Background(), PlanRequest{ComponentPath: "infra/prod/vpc"})
+	assert.ErrorContains(t, err, "no credentials")
+}
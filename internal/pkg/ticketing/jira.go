@@ -0,0 +1,103 @@
+package ticketing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	prom "github.com/wayfair-incubator/telefonistka/internal/pkg/prometheus"
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// jira uses the Jira REST API v2, which is available on both Jira Cloud and Jira Data Center
+type jira struct {
+	baseURL string
+	// Jira Cloud uses basic auth with the user email and an API token, without a user the token is sent as a Data Center personal access token
+	user  string
+	token string
+}
+
+type jiraTransitions struct {
+	Transitions []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+		To   struct {
+			Name string `json:"name"`
+		} `json:"to"`
+	} `json:"transitions"`
+}
+
+func (j *jira) Name() string {
+	return "jira"
+}
+
+func (j *jira) IssueURL(key string) string {
+	return strings.TrimSuffix(j.baseURL, "/") + "/browse/" + key
+}
+
+func (j *jira) TransitionIssue(ctx context.Context, key string, transition string) error {
+	err := j.transitionIssue(ctx, key, transition)
+	status := "success"
+	if err != nil {
+		status = "failure"
+	}
+	prom.InstrumentTicketTransition(j.Name(), status)
+	return err
+}
+
+func (j *jira) transitionIssue(ctx context.Context, key string, transition string) error {
+	var transitions jiraTransitions
+	if err := j.do(ctx, http.MethodGet, "/rest/api/2/issue/"+key+"/transitions", nil, &transitions); err != nil {
+		return err
+	}
+	for _, t := range transitions.Transitions {
+		if strings.EqualFold(t.Name, transition) || strings.EqualFold(t.To.Name, transition) {
+			body := map[string]interface{}{"transition": map[string]string{"id": t.ID}}
+			return j.do(ctx, http.MethodPost, "/rest/api/2/issue/"+key+"/transitions", body, nil)
+		}
+	}
+	return fmt.Errorf("issue %s has no %q transition, it might already be in that status", key, transition)
+}
+
+func (j *jira) do(ctx context.Context, method string, path string, body interface{}, result interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(j.baseURL, "/")+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if j.user != "" {
+		req.SetBasicAuth(j.user, j.token)
+	} else if j.token != "" {
+		req.Header.Set("Authorization", "Bearer "+j.token)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s returned %s: %s", method, path, resp.Status, respBody)
+	}
+	if result != nil {
+		return json.NewDecoder(resp.Body).Decode(result)
+	}
+	return nil
+}
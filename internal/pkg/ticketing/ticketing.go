@@ -0,0 +1,47 @@
+// Package ticketing links promotions to issue tracker tickets, Jira is the only supported tracker for now
+package ticketing
+
+import (
+	"context"
+	"regexp"
+	"sort"
+
+	"github.com/wayfair-incubator/telefonistka/internal/pkg/tenancy"
+)
+
+// Jira style keys, e.g. OPS-123
+var issueKeyRegex = regexp.MustCompile(`\b[A-Z][A-Z0-9_]+-[1-9][0-9]*\b`)
+
+// Tracker is implemented by the supported issue trackers
+type Tracker interface {
+	Name() string
+	IssueURL(key string) string
+	// TransitionIssue moves the issue with the transition named transition, or the one that leads to a status with that name
+	TransitionIssue(ctx context.Context, key string, transition string) error
+}
+
+// ParseIssueKeys returns the sorted, unique issue keys mentioned in text
+func ParseIssueKeys(text string) []string {
+	unique := map[string]bool{}
+	for _, key := range issueKeyRegex.FindAllString(text, -1) {
+		unique[key] = true
+	}
+	keys := make([]string, 0, len(unique))
+	for key := range unique {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// FromEnv returns the tracker configured for the tenant in ctx, or nil
+func FromEnv(ctx context.Context) Tracker {
+	if jiraURL := tenancy.Getenv(ctx, "JIRA_URL", ""); jiraURL != "" {
+		return &jira{
+			baseURL: jiraURL,
+			user:    tenancy.Getenv(ctx, "JIRA_USER", ""),
+			token:   tenancy.Getenv(ctx, "JIRA_API_TOKEN", ""),
+		}
+	}
+	return nil
+}
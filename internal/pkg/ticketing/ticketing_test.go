@@ -0,0 +1,48 @@
+package ticketing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIssueKeys(t *testing.T) {
+	t.Parallel()
+	keys := ParseIssueKeys("OPS-12 bump nginx\nFixes OPS-12 and INFRA_2-7, not lower-1, OPS-0 or https://example.com/X-1x")
+	assert.Equal(t, []string{"INFRA_2-7", "OPS-12"}, keys)
+}
+
+func TestJiraTransitionIssue(t *testing.T) {
+	t.Parallel()
+	var transitionedTo string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, token, _ := r.BasicAuth()
+		assert.Equal(t, "bot@example.com", user)
+		assert.Equal(t, "token", token)
+		assert.Equal(t, "/rest/api/2/issue/OPS-12/transitions", r.URL.Path)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`{"transitions": [{"id": "11", "name": "Start", "to": {"name": "In Progress"}}, {"id": "31", "name": "Deploy", "to": {"name": "In Production"}}]}`))
+			return
+		}
+		var body struct {
+			Transition struct {
+				ID string `json:"id"`
+			} `json:"transition"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		transitionedTo = body.Transition.ID
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	tracker := &jira{baseURL: server.URL + "/", user: "bot@example.com", token: "token"}
+
+	assert.NoError(t, tracker.TransitionIssue(context.Background(), "OPS-12", "in production"))
+	assert.Equal(t, "31", transitionedTo)
+	assert.Error(t, tracker.TransitionIssue(context.Background(), "OPS-12", "Done"))
+	assert.Equal(t, server.URL+"/browse/OPS-12", tracker.IssueURL("OPS-12"))
+}